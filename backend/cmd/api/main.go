@@ -4,8 +4,11 @@ import (
 	"log"
 	"net/http"
 	"os"
+	_ "time/tzdata" // user timezones must resolve even without system zoneinfo
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/middleware"
 
-	"github.com/didip/tollbooth"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/unrolled/secure"
@@ -19,6 +22,7 @@ func main() {
 	}
 
 	router := mux.NewRouter()
+	router.NotFoundHandler = apierror.NotFoundHandler()
 
 	// Security middleware
 	secureMiddleware := secure.New(secure.Options{
@@ -35,32 +39,19 @@ func main() {
 		PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
 	})
 
-	// Rate limiting middleware - 1 request per second per IP
-	lmt := tollbooth.NewLimiter(1, nil)
-
 	// Apply security headers
 	router.Use(func(next http.Handler) http.Handler {
 		return secureMiddleware.Handler(next)
 	})
 
-	// Apply rate limiting
-	router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			httpError := tollbooth.LimitByRequest(lmt, w, r)
-			if httpError != nil {
-				w.WriteHeader(httpError.StatusCode)
-				w.Write([]byte(httpError.Message))
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	})
+	// Rate limiting is applied per tier on the API router, see setupRoutes
 	// CORS middleware
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Idempotent-Replayed")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 			if r.Method == "OPTIONS" {
@@ -82,5 +73,7 @@ func main() {
 	}
 
 	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	// Wrapping the router rather than using router.Use also tags requests
+	// that match no route
+	log.Fatal(http.ListenAndServe(":"+port, middleware.RequestID(router)))
 }
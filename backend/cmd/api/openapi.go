@@ -0,0 +1,1085 @@
+package main
+
+import (
+	"saferelief/internal/middleware"
+	"saferelief/internal/openapi"
+)
+
+// apiOperations documents every route registered in setupRoutes, which
+// refuses to start while the two disagree
+var apiOperations = []openapi.Operation{
+	{
+		Method: "POST", Path: "/api/webhooks/{provider}", Tag: "Webhooks",
+		Security: openapi.Signature,
+		Summary:  "Provider callback (payment, SMS or email); verified, archived and processed asynchronously",
+	},
+	{
+		Method: "POST", Path: "/api/hooks/telegram", Tag: "Telegram Bot",
+		Security: openapi.Signature,
+		Summary:  "Legacy path for the Telegram bot webhook",
+	},
+	{
+		Method: "POST", Path: "/api/hooks/email/ses", Tag: "Email Events",
+		Security: openapi.Signature,
+		Summary:  "Legacy path for Amazon SES notifications",
+	},
+	{
+		Method: "POST", Path: "/api/hooks/email/sendgrid", Tag: "Email Events",
+		Security: openapi.Signature,
+		Summary:  "Legacy path for the SendGrid Event Webhook",
+	},
+	{
+		Method: "GET", Path: "/api/open-data", Tag: "Open Data",
+		Security: openapi.APIKey,
+		Summary:  "Dataset index and query parameters",
+	},
+	{
+		Method: "GET", Path: "/api/open-data/reports", Tag: "Open Data",
+		Security: openapi.APIKey, Query: []string{"groupBy", "interval", "from", "to"},
+		Summary: "Verified report counts per 1° region cell, severity and period",
+	},
+	{
+		Method: "GET", Path: "/api/open-data/funding", Tag: "Open Data",
+		Security: openapi.APIKey, Query: []string{"groupBy"},
+		Summary: "Completed donation totals per currency; groups with fewer than 3 donations are withheld",
+	},
+	{
+		Method: "GET", Path: "/api/public/reports", Tag: "Public",
+		Query:   []string{"q", "status", "severity", "type", "source", "limit", "offset"},
+		Summary: "Verified reports without reporter details, newest first (limit max 100). Public rate limit tier, cached for a minute",
+	},
+	{
+		Method: "GET", Path: "/api/public/reports/{id}", Tag: "Public",
+		Summary: "One verified report without reporter details. Public rate limit tier, cached for a minute",
+	},
+	{
+		Method: "POST", Path: "/api/auth/register", Tag: "Authentication",
+		Summary: "User registration",
+	},
+	{
+		Method: "POST", Path: "/api/auth/login", Tag: "Authentication",
+		Summary: "User login (response flags consentRequired after a policy update and includes passwordExpiresAt for admins/verifiers when PASSWORD_MAX_AGE_DAYS is set; an expired password gets 403 password_change_required until the login is retried with newPassword; rememberDevice with a valid mfaCode skips the MFA prompt on this device for 30 days)",
+	},
+	{
+		Method: "POST", Path: "/api/auth/logout", Tag: "Authentication",
+		Summary: "User logout",
+	},
+	{
+		Method: "POST", Path: "/api/auth/refresh", Tag: "Authentication",
+		Summary: "Exchange the refresh token cookie for a new access token",
+	},
+	{
+		Method: "POST", Path: "/api/auth/magic-link", Tag: "Authentication",
+		Summary: "Email a single-use sign-in link (always 202, whether or not the address has an account)",
+	},
+	{
+		Method: "POST", Path: "/api/auth/magic-link/redeem", Tag: "Authentication",
+		Summary: "Sign in with a link's token (plus mfaCode when MFA is on, unless the device is remembered, and newPassword when the password has expired); sets the session cookies",
+	},
+	{
+		Method: "GET", Path: "/api/auth/sso/discover", Tag: "Authentication", Query: []string{"email"},
+		Summary: "Whether the email's domain signs in through an organization's SSO, with the login URL",
+	},
+	{
+		Method: "GET", Path: "/api/auth/sso/{organizationId}/login", Tag: "Authentication", Query: []string{"redirect"},
+		Summary: "Redirect to the organization's OpenID Connect provider; redirect is a frontend path to return to",
+	},
+	{
+		Method: "GET", Path: "/api/auth/sso/callback", Tag: "Authentication", Query: []string{"code", "state"},
+		Summary: "Provider callback; provisions the user and organization membership, starts a session and redirects to the frontend (ssoError on failure). When an MFA code or a new password is still needed it redirects to /login/sso to finish with POST /api/auth/sso/complete; accounts with MFA or a privileged role are never matched by email and fail with link_required until linked",
+	},
+	{
+		Method: "POST", Path: "/api/auth/sso/complete", Tag: "Authentication",
+		Summary: "Finish an SSO sign-in with mfaCode, rememberDevice or newPassword as for a password login; sets the session cookies and returns redirectPath",
+	},
+	{
+		Method: "POST", Path: "/api/auth/email-change/confirm", Tag: "Users",
+		Summary: "Confirm an email change with a token from either address",
+	},
+	{
+		Method: "POST", Path: "/api/auth/email-change/cancel", Tag: "Users",
+		Summary: "Cancel a pending change from the current address",
+	},
+	{
+		Method: "POST", Path: "/api/auth/invitations/accept", Tag: "Users",
+		Summary: "Set a password from an invitation link and activate the account",
+	},
+	{
+		Method: "GET", Path: "/api/openapi.json", Tag: "Documentation",
+		Summary: "This OpenAPI 3 specification",
+	},
+	{
+		Method: "GET", Path: "/api/docs", Tag: "Documentation",
+		Summary: "Interactive API reference rendered from the specification",
+	},
+	{
+		Method: "GET", Path: "/api/errors", Tag: "Documentation",
+		Summary: "Catalog of error codes with their HTTP status and meaning",
+	},
+	{
+		Method: "GET", Path: "/api/policies", Tag: "Authentication",
+		Summary: "Current privacy policy and terms versions",
+	},
+	{
+		Method: "GET", Path: "/api/reporters/{id}", Tag: "Disaster Reports",
+		Summary: "Public reporter profile with verification stats and trust score",
+	},
+	{
+		Method: "GET", Path: "/api/organizations/{id}", Tag: "Organizations",
+		Summary: "Public organization profile with verification badge",
+	},
+	{
+		Method: "GET", Path: "/api/transparency/batches", Tag: "Transparency",
+		Summary: "Sealed batches with Merkle root, chain hash and anchor reference",
+	},
+	{
+		Method: "GET", Path: "/api/transparency/proof/{donationId}", Tag: "Transparency",
+		Summary: "Inclusion proof for a donation, checked against the root and the current record",
+	},
+	{
+		Method: "GET", Path: "/api/transparency/reports/{id}", Tag: "Transparency",
+		Summary: "A verified report's amount raised, disbursed and released from escrow, with each disbursement and release",
+	},
+	{
+		Method: "GET", Path: "/api/transparency/disbursements/{id}/evidence", Tag: "Transparency",
+		Summary: "Download the receipt or transfer slip of a disbursement",
+	},
+	{
+		Method: "GET", Path: "/api/users/me", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Get own profile (including role and permissions)",
+	},
+	{
+		Method: "PUT", Path: "/api/users/me", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Update own profile (username, locale and IANA timezone; email changes use the flow below)",
+	},
+	{
+		Method: "PUT", Path: "/api/users/me/profile", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Update displayName, phone (E.164), bio and locale; omitted fields are kept, empty strings clear them",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/notifications", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Email, SMS and push preferences per event type (report_verified, report_rejected, report_update, donation_received, security_alert)",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/subscriptions", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Reports you follow, most recently followed first",
+	},
+	{
+		Method: "PUT", Path: "/api/users/me/notifications", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Turn channels on or off per event type; security alerts by email cannot be turned off",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Delete own account (password, plus mfaCode when MFA is on); anonymized after a 30-day grace period",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/consents", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Own consent history plus any policy versions still awaiting consent",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/consents", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Agree to current policy versions, e.g. {\"privacy\": \"2\", \"terms\": \"3\"}",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/activity", Tag: "Users",
+		Security: openapi.Session, Query: []string{"limit", "offset"},
+		Summary: "Own reports, donations and verifications as one feed",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/login-history", Tag: "Users",
+		Security: openapi.SessionOnly, Query: []string{"limit", "offset"},
+		Summary: "Recent successful and failed sign-ins with IP address and user agent",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/sso/{organizationId}/link", Tag: "Users", Query: []string{"redirect"},
+		Security: openapi.SessionOnly,
+		Summary:  "Start linking an organization's SSO identity to this account; returns the provider authUrl to open. The callback links without starting a new session",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/devices", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Devices with active sessions or MFA trust, with last-seen times",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/devices", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Sign out every device except the current one",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/devices/{id}", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Sign a device out and revoke its MFA trust",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/devices/{id}/trust", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Make a remembered device ask for an MFA code again without signing it out",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/devices/trust", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Forget every remembered device, including this one",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/sessions", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Signed-in sessions with device, IP address, user agent and last-seen time",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/sessions/{id}", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Sign out a single session (use logout for the current one)",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/api-keys", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Your active API keys with today's usage",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/api-keys", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Issue a key (name, optional scopes, default open-data); the key is shown once",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/api-keys/{id}", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Revoke a key",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/password", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Change password (currentPassword, newPassword)",
+	},
+	{
+		Method: "PUT", Path: "/api/users/me/location", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Update your last known location (latitude, longitude) for emergency broadcasts",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/location", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Forget your last known location",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/email-change", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Start an email change (password required; both addresses must confirm)",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/avatar", Tag: "Users",
+		Security: openapi.Session, Multipart: true,
+		Summary: "Upload profile avatar (JPEG/PNG, max 1MB); stored at 64, 256 and 512 pixels, replacing the previous one",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/export", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Download a ZIP of all personal data (generated in the background, 202 until ready)",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/export", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Start a new personal data export (returns the one in progress if there is one)",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/export/status", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Poll the latest export; when ready it includes a one-hour downloadUrl",
+	},
+	{
+		Method: "GET", Path: "/api/exports/download", Tag: "Users",
+		Summary: "Download an export archive with the token from its downloadUrl",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/verifier-application", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Apply for the verifier role with uploaded documents",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/verifier-application", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Check the status of your latest application",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/mfa/setup", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Start TOTP enrollment; returns the secret and otpauth URL for a QR code (MFA stays off)",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/mfa/confirm", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Turn MFA on with a valid mfaCode; the response includes 10 one-time recovery codes",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/mfa", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Disable multi-factor authentication",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/mfa/recovery-codes", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Number of unused MFA recovery codes",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/mfa/recovery-codes", Tag: "Users",
+		Security: openapi.SessionOnly,
+		Summary:  "Replace all recovery codes (password, mfaCode); the new codes are shown once",
+	},
+	{
+		Method: "GET", Path: "/api/users/{id}/stats", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Contribution statistics (own stats via me; admins can read any user)",
+	},
+	{
+		Method: "POST", Path: "/api/reports", Tag: "Disaster Reports",
+		Security: openapi.Session, Multipart: true,
+		Summary: "Create disaster report",
+	},
+	{
+		Method: "GET", Path: "/api/reports", Tag: "Disaster Reports",
+		Security: openapi.Session, Query: []string{"q", "status", "severity", "type", "source", "limit", "offset", "after", "sort", "order"},
+		Summary: "List or search disaster reports with total and nextCursor; q ranks by relevance, or sort by createdAt, updatedAt or severity (limit max 100)",
+	},
+	{
+		Method: "POST", Path: "/api/reports/batch", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Sync up to 50 reports filed offline under client-generated UUIDs; resending a report updates it while pending. Attachments are earlier upload IDs. Returns a result per report",
+	},
+	{
+		Method: "GET", Path: "/api/reports.geojson", Tag: "Disaster Reports",
+		Security: openapi.Session, Query: []string{"q", "status", "severity", "type", "source"},
+		Summary: "Verified reports as a GeoJSON FeatureCollection with type, severity, status and completed donation totals per currency (up to 10000 features)",
+	},
+	{
+		Method: "GET", Path: "/api/reports/export", Tag: "Disaster Reports",
+		Security: openapi.Session, Query: []string{"format", "q", "status", "severity", "type", "source"},
+		Summary: "Download the filtered reports as CSV, newest first (up to 10000 rows; X-Export-Truncated marks a cut-off export). Audit-logged",
+	},
+	{
+		Method: "GET", Path: "/api/reports/clusters", Tag: "Disaster Reports",
+		Security: openapi.Session, Query: []string{"bbox", "zoom", "q", "status", "severity", "type", "source"},
+		Summary: "Report counts per geohash cell inside bbox (minLon,minLat,maxLon,maxLat), with each cell's centroid, bounds and dominant severity; zoom sets the cell size",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}.geojson", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "One verified report as a GeoJSON Feature",
+	},
+	{
+		Method: "GET", Path: "/api/reports/types", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Disaster types a report can be filed under (flood, earthquake, fire, ...)",
+	},
+	{
+		Method: "GET", Path: "/api/reports/types/stats", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Report counts per disaster type, split by status",
+	},
+	{
+		Method: "GET", Path: "/api/reports/stats", Tag: "Disaster Reports",
+		Security: openapi.Session, Query: []string{"from", "to", "interval"},
+		Summary: "Report counts by status, severity, disaster type and region, with a daily or weekly series",
+	},
+	{
+		Method: "GET", Path: "/api/reports/queue", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Query:   []string{"sort"},
+		Summary: "Pending reports, highest priority score first by default (sort=trust or sort=oldest for other orders), with the official-record match status (verifier or admin role)",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Get report details",
+	},
+	{
+		Method: "PUT", Path: "/api/reports/{id}", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Edit your own pending report (title, description, disasterType, severity, latitude, longitude); fields left out are kept",
+	},
+	{
+		Method: "DELETE", Path: "/api/reports/{id}", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Soft-delete a report (reporter or admin); its files, comments and donations are hidden with it. Reports with completed donations need an admin",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/verify", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Summary: "Cast an approving verification vote; the report is verified once the quorum approves. Organization members with reports:verify may vote on the organization's reports filed by others",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/verifications", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Summary: "Vote on a pending report with {decision: approve|reject, reason, note}; the vote that reaches the REPORT_VERIFICATION_QUORUM verifies or rejects it",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/verifications", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Summary: "Every verification vote on a report, by round, with the current round's tally and the quorum",
+	},
+	{
+		Method: "PATCH", Path: "/api/reports/{id}/status", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Move a report to another status (verified, in_progress, resolved, closed, rejected or back to pending); invalid transitions return 409",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/reject", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Summary: "Reject a report with a reason code and optional note; the reporter is emailed. Organization verifiers may reject their organization's pending reports",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/duplicates", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Summary: "Earlier reports of the same type within 5 km and 24 hours, flagged when the report was filed (verifier or admin role)",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/history", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Summary: "Every recorded change to title, description, type, coordinates, severity and status, oldest first (verifier or admin role)",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/merge", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermAdminAccess),
+		Summary: "Merge duplicateIds into this report, moving their donations, files, comments and volunteer requests (admin role)",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/attachments", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Attach your earlier uploads (uploadIds) to your report; JPEG or PNG up to 5MB, at most 10 attachments per report",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/subscribe", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Follow a report to get an email on each status change (report_update notification preference)",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/flag", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Flag a report as spam, fraud, misleading or offensive; enough flags hide it until an admin reviews them",
+	},
+	{
+		Method: "DELETE", Path: "/api/reports/{id}/subscribe", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Stop following a report",
+	},
+	{
+		Method: "PUT", Path: "/api/reports/{id}/goal", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Set or remove (null amount) the report's fundraising goal in IDR, optionally closing donations once it is reached (verifiers)",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/escrow", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Donations held in escrow for the report, amounts released to its organization and their approvals (donation managers, organization finance staff)",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/escrow/release", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermManageDonations),
+		Summary: "Request, or approve the pending, release of held donations to the report's organization; funds are released once enough managers approve",
+	},
+	{
+		Method: "DELETE", Path: "/api/reports/{id}/escrow/release", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermManageDonations),
+		Summary: "Cancel the report's pending escrow release",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/needs", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Items the report needs with quantities needed and fulfilled, plus overall progress",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/needs", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Add an item need (reporter, verifiers or the filing organization)",
+	},
+	{
+		Method: "PATCH", Path: "/api/reports/{id}/needs/{needId}", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Change a need's item, unit or quantity needed",
+	},
+	{
+		Method: "DELETE", Path: "/api/reports/{id}/needs/{needId}", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Remove a need and its recorded deliveries",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/needs/{needId}/fulfillments", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Deliveries recorded against a need, oldest first",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/needs/{needId}/fulfillments", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Record a delivery against a need; a negative quantity corrects an earlier one",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/comments", Tag: "Disaster Reports",
+		Security: openapi.Session, Query: []string{"limit", "offset"},
+		Summary: "Situation updates posted under a report, oldest first",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/comments", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Post a situation update under a report",
+	},
+	{
+		Method: "PATCH", Path: "/api/reports/{id}/comments/{commentId}", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Edit your own update within an hour of posting it",
+	},
+	{
+		Method: "DELETE", Path: "/api/reports/{id}/comments/{commentId}", Tag: "Disaster Reports",
+		Security: openapi.Session,
+		Summary:  "Delete an update; authors can delete their own, verifiers and admins anyone's",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/crosscheck", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Summary: "Matching BNPB (DIBI) events within 50 km and 3 days, scored by distance, timing and event type (verifier or admin role)",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/crosscheck", Tag: "Disaster Reports",
+		Security: openapi.Session, Permission: string(middleware.PermVerifyReports),
+		Summary: "Check against BNPB again now (verifier or admin role)",
+	},
+	{
+		Method: "POST", Path: "/api/graphql", Tag: "GraphQL",
+		Security: openapi.Session,
+		Summary:  "Run a GraphQL query (query, variables, operationName); reports with files, donation summaries, donations and volunteer assignments in one request",
+	},
+	{
+		Method: "GET", Path: "/api/graphql", Tag: "GraphQL",
+		Security: openapi.Session,
+		Summary:  "Queryable types and fields with the depth and complexity limits",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/telegram", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Telegram link status",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/telegram", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Unlink Telegram",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/telegram/link", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Get a one-time code and t.me deep link; sending /start <code> to the bot links the chat",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/digest", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Weekly digest preferences and followed regions",
+	},
+	{
+		Method: "PUT", Path: "/api/users/me/digest", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Subscribe or unsubscribe and choose sections (newDisasters, fundingProgress, deliveryUpdates)",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/digest/preview", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "The digest you would receive for the past week",
+	},
+	{
+		Method: "POST", Path: "/api/users/me/digest/regions", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Follow an area (name, latitude, longitude, radiusKm)",
+	},
+	{
+		Method: "DELETE", Path: "/api/users/me/digest/regions/{id}", Tag: "Users",
+		Security: openapi.Session,
+		Summary:  "Stop following an area",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/volunteer", Tag: "Volunteers",
+		Security: openapi.Session,
+		Summary:  "Own volunteer profile",
+	},
+	{
+		Method: "PUT", Path: "/api/users/me/volunteer", Tag: "Volunteers",
+		Security: openapi.Session,
+		Summary:  "Register or update skills, certifications, region and availability",
+	},
+	{
+		Method: "GET", Path: "/api/users/me/volunteer-requests", Tag: "Volunteers",
+		Security: openapi.Session,
+		Summary:  "Requests sent to you",
+	},
+	{
+		Method: "POST", Path: "/api/volunteer-requests/{id}/respond", Tag: "Volunteers",
+		Security: openapi.Session,
+		Summary:  "Accept or decline a request ({\"decision\": \"accept\"})",
+	},
+	{
+		Method: "GET", Path: "/api/volunteers", Tag: "Volunteers",
+		Security: openapi.Session, Permission: string(middleware.PermCoordinateVolunteers), Query: []string{"skill", "certification", "region", "availability"},
+		Summary: "Search volunteers (verifiers and admins)",
+	},
+	{
+		Method: "POST", Path: "/api/reports/{id}/volunteer-requests", Tag: "Volunteers",
+		Security: openapi.Session, Permission: string(middleware.PermCoordinateVolunteers),
+		Summary: "Ask a volunteer to help with a report (verifiers and admins)",
+	},
+	{
+		Method: "GET", Path: "/api/reports/{id}/volunteer-requests", Tag: "Volunteers",
+		Security: openapi.Session, Permission: string(middleware.PermCoordinateVolunteers),
+		Summary: "Requests and responses for a report (verifiers and admins)",
+	},
+	{
+		Method: "GET", Path: "/api/webhooks", Tag: "Webhooks",
+		Security: openapi.Session, Query: []string{"organizationId"},
+		Summary: "List your (or an owned organization's) webhook endpoints",
+	},
+	{
+		Method: "POST", Path: "/api/webhooks", Tag: "Webhooks",
+		Security: openapi.Session,
+		Summary:  "Register an endpoint (url, events, optional secret, scope, organizationId); the secret is returned once",
+	},
+	{
+		Method: "DELETE", Path: "/api/webhooks/{id}", Tag: "Webhooks",
+		Security: openapi.Session,
+		Summary:  "Remove an endpoint",
+	},
+	{
+		Method: "GET", Path: "/api/webhooks/{id}/deliveries", Tag: "Webhooks",
+		Security: openapi.Session, Query: []string{"status", "event", "limit"},
+		Summary: "Delivery log with attempts, response codes and the next retry",
+	},
+	{
+		Method: "GET", Path: "/api/webhooks/{id}/deliveries/{deliveryId}", Tag: "Webhooks",
+		Security: openapi.Session,
+		Summary:  "One delivery with the signed payload",
+	},
+	{
+		Method: "POST", Path: "/api/webhooks/{id}/deliveries/{deliveryId}/redeliver", Tag: "Webhooks",
+		Security: openapi.Session,
+		Summary:  "Send a delivery again",
+	},
+	{
+		Method: "POST", Path: "/api/donations", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Create donation; provider midtrans or paypal returns a checkout",
+	},
+	{
+		Method: "GET", Path: "/api/currencies", Tag: "Donations",
+		Summary: "ISO 4217 currencies donations accept, with decimals and the latest rate per US dollar",
+	},
+	{
+		Method: "GET", Path: "/api/donation-limits", Tag: "Donations",
+		Summary: "Minimum and maximum donation amounts per currency and payment method",
+	},
+	{
+		Method: "POST", Path: "/api/donations/guest", Tag: "Donations",
+		Summary: "Donate without an account (email required); returns a one-time claimToken",
+	},
+	{
+		Method: "POST", Path: "/api/donations/{id}/capture", Tag: "Donations",
+		Summary: "Capture a PayPal payment the donor approved",
+	},
+	{
+		Method: "POST", Path: "/api/donations/claim", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Attach a guest donation to your account (claimToken; verified email must match)",
+	},
+	{
+		Method: "GET", Path: "/api/donations", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "List donations",
+	},
+	{
+		Method: "GET", Path: "/api/donations/{id}", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Get donation details",
+	},
+	{
+		Method: "GET", Path: "/api/donations/{id}/receipt", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Download the numbered PDF receipt of your completed donation",
+	},
+	{
+		Method: "POST", Path: "/api/donations/{id}/refund", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Refund a completed donation in full through its gateway, with a reason; donation managers, or finance staff of the report's organization",
+	},
+	{
+		Method: "PATCH", Path: "/api/donations/{id}/logistics", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Mark a goods or services donation shipped (donor) or received (the report's responders), recording the delivery against its need",
+	},
+	{
+		Method: "POST", Path: "/api/disbursements", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Record a transfer to a verified organization (donation managers) or a purchase (also finance staff of the reports' organization) paid from donations, with evidence, split across reports",
+	},
+	{
+		Method: "GET", Path: "/api/disbursements", Tag: "Donations",
+		Security: openapi.Session, Permission: string(middleware.PermManageDonations),
+		Summary: "List disbursements, optionally those charged to one report",
+	},
+	{
+		Method: "PUT", Path: "/api/donations/{id}/status", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Update a donation status (admin role)",
+	},
+	{
+		Method: "POST", Path: "/api/donation-subscriptions", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Pledge a monthly donation to a report; returns the first cycle's checkout",
+	},
+	{
+		Method: "GET", Path: "/api/donation-subscriptions", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "List your monthly donation subscriptions",
+	},
+	{
+		Method: "POST", Path: "/api/donation-subscriptions/{id}/pause", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Pause an active subscription",
+	},
+	{
+		Method: "POST", Path: "/api/donation-subscriptions/{id}/resume", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Resume a paused subscription from its next billing day",
+	},
+	{
+		Method: "POST", Path: "/api/donation-subscriptions/{id}/cancel", Tag: "Donations",
+		Security: openapi.Session,
+		Summary:  "Cancel a subscription",
+	},
+	{
+		Method: "POST", Path: "/api/organizations", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Register an NGO",
+	},
+	{
+		Method: "POST", Path: "/api/organizations/{id}/verification", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Submit registration documents for verification (owner)",
+	},
+	{
+		Method: "GET", Path: "/api/organizations/{id}/members", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "List staff with their roles and permissions (owner)",
+	},
+	{
+		Method: "POST", Path: "/api/organizations/{id}/members", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Add a member by email with a role (owner, verifier, finance, staff) and extra permissions; unknown emails are invited (owner)",
+	},
+	{
+		Method: "PUT", Path: "/api/organizations/{id}/members/{userId}", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Change a member's role and permissions (owner)",
+	},
+	{
+		Method: "DELETE", Path: "/api/organizations/{id}/members/{userId}", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Revoke a staff member's access (owner)",
+	},
+	{
+		Method: "GET", Path: "/api/organizations/{id}/invitations", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Pending invitations (owner)",
+	},
+	{
+		Method: "DELETE", Path: "/api/organizations/{id}/invitations/{invitationId}", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Revoke a pending invitation (owner)",
+	},
+	{
+		Method: "POST", Path: "/api/organizations/invitations/accept", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Accept an invitation with its token; the account's verified email must match",
+	},
+	{
+		Method: "GET", Path: "/api/organizations/{id}/donations", Tag: "Organizations",
+		Security: openapi.Session,
+		Summary:  "Donations to the organization's reports (donations:view)",
+	},
+	{
+		Method: "POST", Path: "/api/uploads", Tag: "Uploads",
+		Security: openapi.Session, Multipart: true,
+		Summary: "Upload files (multipart field files)",
+	},
+	{
+		Method: "GET", Path: "/api/uploads/{id}", Tag: "Uploads",
+		Security: openapi.Session,
+		Summary:  "Download an uploaded file",
+	},
+	{
+		Method: "GET", Path: "/api/admin/security/summary", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Security aggregates for the last 24h/7d",
+	},
+	{
+		Method: "GET", Path: "/api/admin/audit-logs/export", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"format"},
+		Summary: "Stream filtered audit logs",
+	},
+	{
+		Method: "GET", Path: "/api/admin/audit-logs/policy", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Current audit verbosity policy",
+	},
+	{
+		Method: "PUT", Path: "/api/admin/audit-logs/policy", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Replace the audit verbosity policy",
+	},
+	{
+		Method: "GET", Path: "/api/admin/audit-logs/stream", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Live Server-Sent Events feed of MEDIUM+ audit events",
+	},
+	{
+		Method: "POST", Path: "/api/admin/policies", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Publish a new privacy policy or terms version (users re-consent once it takes effect)",
+	},
+	{
+		Method: "GET", Path: "/api/admin/users/search", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"q", "role", "locked", "suspended", "mfa", "sort", "order"},
+		Summary: "Search accounts",
+	},
+	{
+		Method: "POST", Path: "/api/admin/users/import", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Multipart: true,
+		Summary: "Bulk-invite staff from a CSV (email,name,role; max 1000 rows) as the file field",
+	},
+	{
+		Method: "GET", Path: "/api/admin/users/imports/{id}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Import progress with per-row success or failure",
+	},
+	{
+		Method: "DELETE", Path: "/api/admin/users/{id}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Soft-delete an account (anonymized after a 30-day grace period)",
+	},
+	{
+		Method: "POST", Path: "/api/admin/users/{id}/restore", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Restore a soft-deleted account within the grace period",
+	},
+	{
+		Method: "POST", Path: "/api/admin/users/{id}/suspension", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Suspend (optional expiresAt) or permanently ban a user with a reason",
+	},
+	{
+		Method: "DELETE", Path: "/api/admin/users/{id}/suspension", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Lift an active suspension or ban",
+	},
+	{
+		Method: "GET", Path: "/api/admin/users/{id}/suspensions", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Every suspension and ban on a user, with who applied and lifted it",
+	},
+	{
+		Method: "POST", Path: "/api/admin/users/{id}/unlock", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Clear failed login attempts and a lockout; emails the user unless notify is false",
+	},
+	{
+		Method: "GET", Path: "/api/admin/roles", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Roles and the permissions each grants",
+	},
+	{
+		Method: "GET", Path: "/api/admin/rate-limits", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Requests per minute for each rate limit tier and whether it comes from config or the database",
+	},
+	{
+		Method: "PUT", Path: "/api/admin/rate-limits", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Override tier limits, e.g. {\"limits\":{\"ngo\":1500}}; 0 restores the configured limit",
+	},
+	{
+		Method: "PUT", Path: "/api/admin/donation-limits", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Set the minAmount and maxAmount for a currency and paymentMethod (empty for all); leaving out both removes the limit",
+	},
+	{
+		Method: "GET", Path: "/api/admin/reports/deleted", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Query:   []string{"limit", "offset"},
+		Summary: "Soft-deleted reports, most recently deleted first",
+	},
+	{
+		Method: "POST", Path: "/api/admin/reports/{id}/restore", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Restore a soft-deleted report with the status it had",
+	},
+	{
+		Method: "GET", Path: "/api/admin/reports/flagged", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Query:   []string{"limit", "offset"},
+		Summary: "Moderation queue: reports with open abuse flags, hidden ones first",
+	},
+	{
+		Method: "POST", Path: "/api/admin/reports/{id}/flags/resolve", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Dismiss a report's open flags to show it again, or uphold them to delete it",
+	},
+	{
+		Method: "PUT", Path: "/api/admin/users/{id}/role", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Assign a role (donor, reporter, verifier, admin); ends the user's sessions so new tokens carry it (admin role)",
+	},
+	{
+		Method: "GET", Path: "/api/admin/organizations", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"status"},
+		Summary: "Organizations awaiting verification",
+	},
+	{
+		Method: "POST", Path: "/api/admin/organizations/{id}/verification", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Approve or reject an organization",
+	},
+	{
+		Method: "GET", Path: "/api/admin/organizations/{id}/sso", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Organization's SSO configuration (the client secret is never returned)",
+	},
+	{
+		Method: "PUT", Path: "/api/admin/organizations/{id}/sso", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Configure the OpenID Connect provider, email domains and group-to-permission mapping of a verified organization",
+	},
+	{
+		Method: "DELETE", Path: "/api/admin/organizations/{id}/sso", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Remove an organization's SSO configuration",
+	},
+	{
+		Method: "GET", Path: "/api/admin/verifier-applications", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"status"},
+		Summary: "List verifier applications",
+	},
+	{
+		Method: "POST", Path: "/api/admin/verifier-applications/{id}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Approve or reject an application",
+	},
+	{
+		Method: "GET", Path: "/api/admin/broadcasts", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Recent broadcasts",
+	},
+	{
+		Method: "POST", Path: "/api/admin/broadcasts", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Send an emergency alert (title, message, channels) to users whose last known location or followed region is inside area (latitude/longitude/radiusKm or a polygon of [lat, lon] points)",
+	},
+	{
+		Method: "POST", Path: "/api/admin/broadcasts/preview", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Estimate the audience of an emergency broadcast without sending it",
+	},
+	{
+		Method: "GET", Path: "/api/admin/broadcasts/{id}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Per-channel delivery progress",
+	},
+	{
+		Method: "GET", Path: "/api/admin/templates", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Notification templates with the current version per locale (id, en)",
+	},
+	{
+		Method: "GET", Path: "/api/admin/templates/{key}/{locale}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Current content, saved versions and sample data",
+	},
+	{
+		Method: "PUT", Path: "/api/admin/templates/{key}/{locale}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Save a new version (subject, body in Go template syntax)",
+	},
+	{
+		Method: "POST", Path: "/api/admin/templates/{key}/{locale}/preview", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Render a draft (or the current version) with sample data",
+	},
+	{
+		Method: "POST", Path: "/api/admin/templates/{key}/{locale}/versions/{version}/restore", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Make an earlier version current again",
+	},
+	{
+		Method: "GET", Path: "/api/admin/deliveries", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"channel", "status"},
+		Summary: "Email, webhook and broadcast delivery attempts with failure reasons",
+	},
+	{
+		Method: "GET", Path: "/api/admin/deliveries/dead-letters", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"channel"},
+		Summary: "Messages whose automatic retries are exhausted",
+	},
+	{
+		Method: "GET", Path: "/api/admin/deliveries/health", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"hours"},
+		Summary: "Per-channel sent/failed/pending counts, success rate, dead-letter backlog and queue depth",
+	},
+	{
+		Method: "POST", Path: "/api/admin/deliveries/retry", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Retry dead letters on one channel (channel plus ids, or all: true; up to 500)",
+	},
+	{
+		Method: "GET", Path: "/api/admin/inbound-webhooks", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"provider", "status"},
+		Summary: "Archived provider callbacks and their processing status",
+	},
+	{
+		Method: "GET", Path: "/api/admin/inbound-webhooks/{id}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "One callback with its raw payload",
+	},
+	{
+		Method: "POST", Path: "/api/admin/inbound-webhooks/{id}/replay", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Process an archived callback again",
+	},
+	{
+		Method: "GET", Path: "/api/admin/email-suppressions", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess), Query: []string{"email"},
+		Summary: "Suppressed addresses with the bounce or complaint reason",
+	},
+	{
+		Method: "DELETE", Path: "/api/admin/email-suppressions/{email}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Allow mail to an address again",
+	},
+	{
+		Method: "GET", Path: "/api/admin/integrations", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Slack/Discord alert channels",
+	},
+	{
+		Method: "POST", Path: "/api/admin/integrations", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Add a channel (provider, webhookUrl, alerts: report.verified and/or report.funding_milestone, optional minSeverity, currency, fundingMilestones)",
+	},
+	{
+		Method: "DELETE", Path: "/api/admin/integrations/{id}", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Remove a channel",
+	},
+	{
+		Method: "POST", Path: "/api/admin/integrations/{id}/test", Tag: "Admin",
+		Security: openapi.SessionOnly, Permission: string(middleware.PermAdminAccess),
+		Summary: "Post a sample card to the channel",
+	},
+}
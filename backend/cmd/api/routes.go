@@ -4,11 +4,35 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"saferelief/internal/accounts"
+	"saferelief/internal/alerts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/apikeys"
+	"saferelief/internal/audit"
 	"saferelief/internal/auth"
+	"saferelief/internal/broadcast"
+	"saferelief/internal/crosscheck"
+	"saferelief/internal/digest"
+	"saferelief/internal/events"
+	"saferelief/internal/feeds"
+	"saferelief/internal/fx"
 	"saferelief/internal/handlers"
+	"saferelief/internal/inbound"
+	"saferelief/internal/jobs"
+	"saferelief/internal/ledger"
 	"saferelief/internal/middleware"
+	"saferelief/internal/notify"
+	"saferelief/internal/openapi"
+	"saferelief/internal/payments"
+	"saferelief/internal/ratelimit"
+	"saferelief/internal/rpc"
+	"saferelief/internal/telegram"
+	"saferelief/internal/webhooks"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -39,30 +63,242 @@ func setupRoutes() *mux.Router {
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer db.Close()
 
 	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
 	refreshSecret := []byte(os.Getenv("REFRESH_TOKEN_SECRET"))
 	csrfSecret := []byte(os.Getenv("CSRF_SECRET"))
 
+	auditSpoolPath := os.Getenv("AUDIT_SPOOL_PATH")
+	if auditSpoolPath == "" {
+		auditSpoolPath = "./audit-spool.jsonl"
+	}
+	auditLogger := audit.NewLogger(db, auditSpoolPath)
+	if rawPolicy := os.Getenv("AUDIT_POLICY"); rawPolicy != "" {
+		policy, err := audit.ParsePolicy([]byte(rawPolicy))
+		if err != nil {
+			log.Fatal("Invalid AUDIT_POLICY:", err)
+		}
+		auditLogger.SetPolicy(policy)
+	}
+	auditLogger.StartReplay(30 * time.Second)
+	if wormPath := os.Getenv("AUDIT_WORM_PATH"); wormPath != "" {
+		mirror, err := audit.NewWORMSink(wormPath, []byte(os.Getenv("AUDIT_WORM_DIGEST_KEY")))
+		if err != nil {
+			log.Fatal("Failed to open audit WORM storage:", err)
+		}
+		mirror.StartDigests(time.Hour)
+		auditLogger.SetMirror(mirror)
+	}
+
+	accounts.StartPurge(db, time.Hour, func(userID string) {
+		auditLogger.Log(nil, audit.Event{
+			Type:     audit.EventAccountAnonymized,
+			Severity: audit.SeverityHigh,
+			EntityID: userID,
+		})
+	})
+
+	jobQueue := jobs.NewQueue(4, 256)
+	jobQueue.Start()
+	notificationTemplates := notify.NewTemplates(db)
+	mailer := notify.NewMailerFromEnv()
+	mailer.UseTemplates(notificationTemplates)
+	mailer.UseDeliveryLog(db)
+	webhookDispatcher := webhooks.NewDispatcher(db, jobQueue)
+	webhookDispatcher.Start(time.Minute)
+	webhooks.ForwardSecurityEvents(auditLogger, webhookDispatcher)
+	eventStream, err := events.NewStreamFromEnv(jobQueue)
+	if err != nil {
+		log.Fatal("Invalid event stream configuration:", err)
+	}
+	webhookDispatcher.SetStream(eventStream)
+	events.ForwardUserEvents(auditLogger, eventStream)
+	chatNotifier := alerts.NewNotifier(db, jobQueue)
+	telegramBot := telegram.NewBotFromEnv()
+	chatNotifier.SetTelegram(telegramBot)
+	chatNotifier.SetMailer(mailer)
+	broadcastRate, _ := strconv.Atoi(os.Getenv("BROADCAST_RATE_PER_SECOND"))
+	broadcaster := broadcast.NewBroadcaster(db, jobQueue, mailer, telegramBot, broadcastRate,
+		func(broadcastID string, sent, failed int) {
+			auditLogger.Log(nil, audit.Event{
+				Type:       audit.EventBroadcastCompleted,
+				Severity:   audit.SeverityHigh,
+				EntityType: "broadcast",
+				EntityID:   broadcastID,
+				Details:    map[string]interface{}{"sent": sent, "failed": failed},
+			})
+		})
+	digest.Start(db, mailer, time.Hour)
+	var ledgerAnchor ledger.Anchor
+	if calendarURL := os.Getenv("LEDGER_ANCHOR_URL"); calendarURL != "" {
+		ledgerAnchor = ledger.NewOpenTimestamps(calendarURL)
+	}
+	ledger.Start(db, ledgerAnchor, time.Hour)
+	rateProvider, err := fx.ProviderFromEnv()
+	if err != nil {
+		log.Fatal("Invalid exchange rate configuration:", err)
+	}
+	fx.Start(db, rateProvider, 6*time.Hour)
+	var officialRecords crosscheck.Source
+	if bnpb := crosscheck.NewBNPBFromEnv(); bnpb != nil {
+		officialRecords = bnpb
+		crosscheck.Start(db, officialRecords, time.Minute)
+	}
+
 	// Initialize handlers
-	authHandler := auth.NewAuthHandler(jwtSecret, refreshSecret, db)
-	reportHandler := handlers.NewReportHandler(db)
-	donationHandler := handlers.NewDonationHandler(db)
-	userHandler := handlers.NewUserHandler(db)
+	authHandler := auth.NewAuthHandler(jwtSecret, refreshSecret, db, auditLogger)
+	ssoHandler := auth.NewSSOHandler(authHandler, db, auditLogger)
+	magicLinkHandler := auth.NewMagicLinkHandler(authHandler, db, mailer, auditLogger)
+	reportHandler := handlers.NewReportHandler(db, auditLogger, webhookDispatcher, chatNotifier)
+	verificationsRequired, verificationsOf, err := handlers.ParseVerificationQuorum(os.Getenv("REPORT_VERIFICATION_QUORUM"))
+	if err != nil {
+		log.Fatal("Invalid REPORT_VERIFICATION_QUORUM:", err)
+	}
+	reportHandler.SetVerificationQuorum(verificationsRequired, verificationsOf)
+	reportExpiry, err := handlers.ParseReportExpiry(os.Getenv("REPORT_AUTO_CLOSE"),
+		os.Getenv("REPORT_PENDING_TTL_DAYS"), os.Getenv("REPORT_RESOLVED_INACTIVITY_DAYS"))
+	if err != nil {
+		log.Fatal("Invalid report expiry configuration:", err)
+	}
+	reportHandler.StartExpiry(reportExpiry, time.Hour)
+	flagThreshold, _ := strconv.Atoi(os.Getenv("REPORT_FLAG_THRESHOLD"))
+	reportHandler.SetFlagThreshold(flagThreshold)
+	escrowApprovals, _ := strconv.Atoi(os.Getenv("ESCROW_RELEASE_APPROVALS"))
+	reportHandler.SetEscrowApprovals(escrowApprovals)
+	if alertFeeds := feeds.FromEnv(); len(alertFeeds) > 0 {
+		feedFilter, err := feeds.ParseFilter(os.Getenv("FEED_MIN_MAGNITUDE"), os.Getenv("FEED_BOUNDS"))
+		if err != nil {
+			log.Fatal("Invalid feed configuration:", err)
+		}
+		// Feed reports need an account to be filed under
+		feedReporterID := os.Getenv("FEED_REPORTER_ID")
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE id = UUID_TO_BIN(?))", feedReporterID).Scan(&exists); err != nil || !exists {
+			log.Fatal("FEED_REPORTER_ID must be the ID of an existing user when feeds are configured")
+		}
+		reportHandler.StartFeeds(alertFeeds, feedFilter, feedReporterID, 5*time.Minute)
+	}
+	donationHandler := handlers.NewDonationHandler(db, webhookDispatcher, chatNotifier, auditLogger)
+	if midtrans := payments.NewMidtransFromEnv(); midtrans != nil {
+		donationHandler.AddPaymentProvider(midtrans)
+	}
+	if paypal := payments.NewPayPalFromEnv(); paypal != nil {
+		donationHandler.AddPaymentProvider(paypal)
+	}
+	donationHandler.StartIdempotencyPurge(time.Hour)
+	donationHandler.SetMailer(mailer)
+	donationHandler.SetJobQueue(jobQueue)
+	donationHandler.StartSubscriptions(time.Hour)
+	currencyHandler := handlers.NewCurrencyHandler(db)
+	donationLimitHandler := handlers.NewDonationLimitHandler(db, auditLogger)
+	userHandler := handlers.NewUserHandler(db, auditLogger)
 	uploadHandler := handlers.NewUploadHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, mailer, auditLogger)
+	exportHandler := handlers.NewExportHandler(db, jobQueue, mailer, auditLogger)
+	verifierApplicationHandler := handlers.NewVerifierApplicationHandler(db, auditLogger)
+	emailChangeHandler := handlers.NewEmailChangeHandler(db, mailer, auditLogger)
+	organizationHandler := handlers.NewOrganizationHandler(db, mailer, auditLogger)
+	consentHandler := handlers.NewConsentHandler(db, auditLogger)
+	deviceHandler := handlers.NewDeviceHandler(db, auditLogger)
+	userImportHandler := handlers.NewUserImportHandler(db, jobQueue, mailer, auditLogger)
+	volunteerHandler := handlers.NewVolunteerHandler(db, mailer)
+	webhookHandler := handlers.NewWebhookHandler(db, webhookDispatcher)
+	chatIntegrationHandler := handlers.NewChatIntegrationHandler(db, chatNotifier, auditLogger)
+	digestHandler := handlers.NewDigestHandler(db)
+	telegramHandler := handlers.NewTelegramHandler(db, telegramBot, webhookDispatcher)
+	broadcastHandler := handlers.NewBroadcastHandler(db, broadcaster, auditLogger)
+	templateHandler := handlers.NewTemplateHandler(notificationTemplates, auditLogger)
+	transparencyHandler := handlers.NewTransparencyHandler(db)
+	disbursementHandler := handlers.NewDisbursementHandler(db, auditLogger)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db, auditLogger)
+	openDataHandler := handlers.NewOpenDataHandler(db)
+	publicReportHandler := handlers.NewPublicReportHandler(db)
+	crossCheckHandler := handlers.NewCrossCheckHandler(db, officialRecords)
+	emailEventHandler, err := handlers.NewEmailEventHandler(db, auditLogger)
+	if err != nil {
+		log.Fatal("Invalid SENDGRID_WEBHOOK_PUBLIC_KEY:", err)
+	}
+	deliveryHandler := handlers.NewDeliveryHandler(db, mailer, webhookDispatcher, broadcaster, jobQueue, auditLogger)
+	inboundReceiver := inbound.NewReceiver(db, jobQueue)
+	telegramHandler.RegisterInbound(inboundReceiver)
+	emailEventHandler.RegisterInbound(inboundReceiver)
+	donationHandler.RegisterInbound(inboundReceiver)
+	inboundReceiver.Start(time.Minute)
+	inboundWebhookHandler := handlers.NewInboundWebhookHandler(db, inboundReceiver, auditLogger)
+	graphqlHandler := handlers.NewGraphQLHandler(db)
+
+	// Internal gRPC service for other backends, served on its own mTLS port
+	grpcServer, err := rpc.NewServerFromEnv()
+	if err != nil {
+		log.Fatal("Invalid gRPC configuration:", err)
+	}
+	if grpcServer != nil {
+		handlers.NewCoreService(db, auditLogger, webhookDispatcher).Register(grpcServer)
+		go func() {
+			log.Fatal(grpcServer.ListenAndServe())
+		}()
+	}
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtSecret)
+	rateLimits, err := ratelimit.ParseLimits(os.Getenv("RATE_LIMIT_TIERS"))
+	if err != nil {
+		log.Fatal("Invalid RATE_LIMIT_TIERS:", err)
+	}
+	limiter := ratelimit.NewLimiter(rateLimits)
+	ratelimit.Start(db, limiter, time.Minute)
+	rateLimitHandler := handlers.NewRateLimitHandler(db, limiter, auditLogger)
+	apiKeyMiddleware := middleware.NewAPIKeyMiddleware(db, limiter)
+	authMiddleware := middleware.NewAuthMiddleware(jwtSecret, db, apiKeyMiddleware)
 	csrfMiddleware := middleware.NewCSRFMiddleware(csrfSecret)
+	roleMiddleware := middleware.NewRoleMiddleware(db)
+	rateLimitMiddleware := middleware.NewRateLimitMiddleware(limiter, authMiddleware)
+	// Users who have not accepted the latest policies can still see their
+	// profile, consent, and take their data with them
+	consentMiddleware := middleware.NewConsentMiddleware(db,
+		"/api/users/me", "/api/users/me/consents", "/api/users/me/export", "/api/users/me/export/status",
+	)
 
 	// Create main router
 	router := mux.NewRouter()
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+
+	// Inbound webhooks authenticate with provider signatures instead of
+	// cookies, so they sit outside the CSRF-protected API router. POST is the
+	// only method, which leaves the user's own /api/webhooks endpoints below
+	// reachable for GET and DELETE.
+	inboundHandler := rateLimitMiddleware.Limit(middleware.SecurityHeaders(inboundReceiver))
+	router.Handle("/api/webhooks/{provider}", inboundHandler).Methods("POST")
+
+	// Paths registered with providers before the unified receiver existed
+	hooksRouter := router.PathPrefix("/api/hooks").Subrouter()
+	hooksRouter.Use(rateLimitMiddleware.Limit)
+	hooksRouter.Use(middleware.SecurityHeaders)
+	hooksRouter.HandleFunc("/telegram", inboundReceiver.Handler("telegram")).Methods("POST")
+	hooksRouter.HandleFunc("/email/ses", inboundReceiver.Handler("ses")).Methods("POST")
+	hooksRouter.HandleFunc("/email/sendgrid", inboundReceiver.Handler("sendgrid")).Methods("POST")
+
+	// Open data is read with an API key rather than a session
+	openDataRouter := router.PathPrefix("/api/open-data").Subrouter()
+	openDataRouter.Use(middleware.SecurityHeaders)
+	openDataRouter.Use(apiKeyMiddleware.Require(apikeys.ScopeOpenData))
+	openDataRouter.HandleFunc("", openDataHandler.Index).Methods("GET")
+	openDataRouter.HandleFunc("/reports", openDataHandler.ReportCounts).Methods("GET")
+	openDataRouter.HandleFunc("/funding", openDataHandler.FundingTotals).Methods("GET")
+
+	// The public API needs no sign-in, so it has its own per-IP budget that
+	// a session cannot raise
+	publicRouter := router.PathPrefix("/api/public").Subrouter()
+	publicRouter.Use(rateLimitMiddleware.LimitAs(ratelimit.TierPublic))
+	publicRouter.Use(middleware.SecurityHeaders)
+	publicRouter.HandleFunc("/reports", publicReportHandler.ListReports).Methods("GET")
+	publicRouter.HandleFunc("/reports/{id}", publicReportHandler.GetReport).Methods("GET")
 
 	// Router configuration
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
 	// Apply global middleware
+	apiRouter.Use(rateLimitMiddleware.Limit)
 	apiRouter.Use(middleware.SecurityHeaders)
 	apiRouter.Use(middleware.SanitizeInput)
 	apiRouter.Use(csrfMiddleware.ValidateCSRF)
@@ -72,33 +308,284 @@ func setupRoutes() *mux.Router {
 	authRouter.HandleFunc("/login", authHandler.Login).Methods("POST")
 	authRouter.HandleFunc("/logout", authHandler.Logout).Methods("POST")
 	authRouter.HandleFunc("/refresh", authHandler.RefreshToken).Methods("POST")
+	authRouter.HandleFunc("/magic-link", magicLinkHandler.Request).Methods("POST")
+	authRouter.HandleFunc("/magic-link/redeem", magicLinkHandler.Redeem).Methods("POST")
+	authRouter.HandleFunc("/email-change/confirm", emailChangeHandler.ConfirmChange).Methods("POST")
+	authRouter.HandleFunc("/email-change/cancel", emailChangeHandler.CancelChange).Methods("POST")
+	authRouter.HandleFunc("/invitations/accept", userImportHandler.AcceptInvitation).Methods("POST")
+	authRouter.HandleFunc("/sso/discover", ssoHandler.Discover).Methods("GET")
+	authRouter.HandleFunc("/sso/callback", ssoHandler.Callback).Methods("GET")
+	authRouter.HandleFunc("/sso/complete", ssoHandler.Complete).Methods("POST")
+	authRouter.HandleFunc("/sso/{organizationId}/login", ssoHandler.Start).Methods("GET")
+
+	// Public routes
+	apiRouter.HandleFunc("/openapi.json", openapi.SpecHandler(openapi.Document("SafeRelief API", "1.0.0", apiOperations))).Methods("GET")
+	apiRouter.HandleFunc("/docs", openapi.DocsHandler("/api/openapi.json")).Methods("GET")
+	apiRouter.Handle("/errors", apierror.CatalogHandler()).Methods("GET")
+	apiRouter.HandleFunc("/policies", consentHandler.CurrentPolicies).Methods("GET")
+	apiRouter.HandleFunc("/reporters/{id}", reportHandler.GetReporterProfile).Methods("GET")
+	apiRouter.HandleFunc("/organizations/{id}", organizationHandler.GetOrganization).Methods("GET")
+	apiRouter.HandleFunc("/transparency/batches", transparencyHandler.ListBatches).Methods("GET")
+	apiRouter.HandleFunc("/transparency/proof/{donationId}", transparencyHandler.GetProof).Methods("GET")
+	apiRouter.HandleFunc("/transparency/reports/{id}", disbursementHandler.GetReportLedger).Methods("GET")
+	apiRouter.HandleFunc("/transparency/disbursements/{id}/evidence", disbursementHandler.GetEvidence).Methods("GET")
+	apiRouter.HandleFunc("/currencies", currencyHandler.ListCurrencies).Methods("GET")
+	apiRouter.HandleFunc("/donation-limits", donationLimitHandler.ListLimits).Methods("GET")
+	apiRouter.HandleFunc("/donations/guest", donationHandler.CreateGuestDonation).Methods("POST")
+	apiRouter.HandleFunc("/donations/{id}/capture", donationHandler.CaptureDonation).Methods("POST")
+	apiRouter.HandleFunc("/exports/download", exportHandler.DownloadExport).Methods("GET")
 
 	// Protected routes
 	protectedRouter := apiRouter.PathPrefix("").Subrouter()
 	protectedRouter.Use(authMiddleware.Authenticate)
+	protectedRouter.Use(consentMiddleware.RequireCurrentConsent)
 
 	// User routes
 	protectedRouter.HandleFunc("/users/me", userHandler.GetProfile).Methods("GET")
 	protectedRouter.HandleFunc("/users/me", userHandler.UpdateProfile).Methods("PUT")
-	protectedRouter.HandleFunc("/users/me/mfa", userHandler.EnableMFA).Methods("POST")
-	protectedRouter.HandleFunc("/users/me/mfa", userHandler.DisableMFA).Methods("DELETE")
+	protectedRouter.HandleFunc("/users/me/profile", userHandler.UpdateProfileDetails).Methods("PUT")
+	protectedRouter.HandleFunc("/users/me/consents", consentHandler.GetConsents).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/consents", consentHandler.RecordConsent).Methods("POST")
+	protectedRouter.HandleFunc("/users/me/activity", userHandler.GetActivity).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/notifications", userHandler.GetNotificationPreferences).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/subscriptions", reportHandler.ListSubscriptions).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/notifications", userHandler.UpdateNotificationPreferences).Methods("PUT")
+	// Account security stays behind a signed-in session, so a leaked API key
+	// cannot mint keys, end sessions, change credentials or export the account
+	sessionOnly := func(h http.HandlerFunc) http.Handler { return middleware.RequireSession(h) }
+	protectedRouter.Handle("/users/me/devices", sessionOnly(deviceHandler.ListDevices)).Methods("GET")
+	protectedRouter.Handle("/users/me/sso/{organizationId}/link", sessionOnly(ssoHandler.StartLink)).Methods("POST")
+	protectedRouter.Handle("/users/me/devices", sessionOnly(deviceHandler.RevokeOtherDevices)).Methods("DELETE")
+	protectedRouter.Handle("/users/me/devices/trust", sessionOnly(deviceHandler.UntrustAllDevices)).Methods("DELETE")
+	protectedRouter.Handle("/users/me/devices/{id}", sessionOnly(deviceHandler.RevokeDevice)).Methods("DELETE")
+	protectedRouter.Handle("/users/me/devices/{id}/trust", sessionOnly(deviceHandler.UntrustDevice)).Methods("DELETE")
+	protectedRouter.Handle("/users/me/sessions", sessionOnly(deviceHandler.ListSessions)).Methods("GET")
+	protectedRouter.Handle("/users/me/sessions/{id}", sessionOnly(deviceHandler.RevokeSession)).Methods("DELETE")
+	protectedRouter.Handle("/users/me/login-history", sessionOnly(userHandler.GetLoginHistory)).Methods("GET")
+	protectedRouter.Handle("/users/me", sessionOnly(userHandler.DeleteAccount)).Methods("DELETE")
+	protectedRouter.Handle("/users/me/api-keys", sessionOnly(apiKeyHandler.ListKeys)).Methods("GET")
+	protectedRouter.Handle("/users/me/api-keys", sessionOnly(apiKeyHandler.CreateKey)).Methods("POST")
+	protectedRouter.Handle("/users/me/api-keys/{id}", sessionOnly(apiKeyHandler.RevokeKey)).Methods("DELETE")
+	protectedRouter.Handle("/users/me/password", sessionOnly(userHandler.ChangePassword)).Methods("POST")
+	protectedRouter.HandleFunc("/users/me/location", userHandler.UpdateLocation).Methods("PUT")
+	protectedRouter.HandleFunc("/users/me/location", userHandler.ClearLocation).Methods("DELETE")
+	protectedRouter.Handle("/users/me/email-change", sessionOnly(emailChangeHandler.RequestChange)).Methods("POST")
+	protectedRouter.HandleFunc("/users/me/avatar", uploadHandler.UploadAvatar).Methods("POST")
+	protectedRouter.Handle("/users/me/export", sessionOnly(exportHandler.ExportData)).Methods("GET")
+	protectedRouter.Handle("/users/me/export", sessionOnly(exportHandler.RequestExport)).Methods("POST")
+	protectedRouter.Handle("/users/me/export/status", sessionOnly(exportHandler.ExportStatus)).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/verifier-application", verifierApplicationHandler.Apply).Methods("POST")
+	protectedRouter.HandleFunc("/users/me/verifier-application", verifierApplicationHandler.GetOwnApplication).Methods("GET")
+	protectedRouter.Handle("/users/me/mfa/setup", sessionOnly(userHandler.SetupMFA)).Methods("POST")
+	protectedRouter.Handle("/users/me/mfa/confirm", sessionOnly(userHandler.ConfirmMFA)).Methods("POST")
+	protectedRouter.Handle("/users/me/mfa", sessionOnly(userHandler.DisableMFA)).Methods("DELETE")
+	protectedRouter.Handle("/users/me/mfa/recovery-codes", sessionOnly(userHandler.GetRecoveryCodes)).Methods("GET")
+	protectedRouter.Handle("/users/me/mfa/recovery-codes", sessionOnly(userHandler.RegenerateRecoveryCodes)).Methods("POST")
+	protectedRouter.HandleFunc("/users/{id}/stats", userHandler.GetStats).Methods("GET")
 
 	// Disaster report routes
 	protectedRouter.HandleFunc("/reports", reportHandler.CreateReport).Methods("POST")
 	protectedRouter.HandleFunc("/reports", reportHandler.ListReports).Methods("GET")
+	protectedRouter.HandleFunc("/reports/batch", reportHandler.SubmitReportBatch).Methods("POST")
+	protectedRouter.HandleFunc("/reports.geojson", reportHandler.ReportsGeoJSON).Methods("GET")
+	protectedRouter.HandleFunc("/reports/export", reportHandler.ExportReports).Methods("GET")
+	protectedRouter.HandleFunc("/reports/clusters", reportHandler.ReportClusters).Methods("GET")
+	protectedRouter.HandleFunc("/reports/types", reportHandler.ListDisasterTypes).Methods("GET")
+	protectedRouter.HandleFunc("/reports/types/stats", reportHandler.DisasterTypeCounts).Methods("GET")
+	protectedRouter.HandleFunc("/reports/stats", reportHandler.ReportStats).Methods("GET")
+	protectedRouter.Handle("/reports/queue",
+		roleMiddleware.RequirePermission(middleware.PermVerifyReports)(http.HandlerFunc(reportHandler.VerificationQueue)),
+	).Methods("GET")
+	protectedRouter.HandleFunc("/reports/{id}.geojson", reportHandler.ReportGeoJSON).Methods("GET")
 	protectedRouter.HandleFunc("/reports/{id}", reportHandler.GetReport).Methods("GET")
 	protectedRouter.HandleFunc("/reports/{id}", reportHandler.UpdateReport).Methods("PUT")
+	protectedRouter.HandleFunc("/reports/{id}", reportHandler.DeleteReport).Methods("DELETE")
+	// Organization verifiers may verify their own organization's reports, so
+	// the handler checks the token role and falls back to the report's
+	// organization instead of RequireRole
 	protectedRouter.HandleFunc("/reports/{id}/verify", reportHandler.VerifyReport).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/status", reportHandler.UpdateReportStatus).Methods("PATCH")
+	protectedRouter.HandleFunc("/reports/{id}/reject", reportHandler.RejectReport).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/verifications", reportHandler.ListVerifications).Methods("GET")
+	protectedRouter.HandleFunc("/reports/{id}/verifications", reportHandler.SubmitVerification).Methods("POST")
+	protectedRouter.Handle("/reports/{id}/duplicates",
+		roleMiddleware.RequirePermission(middleware.PermVerifyReports)(http.HandlerFunc(reportHandler.ListDuplicates)),
+	).Methods("GET")
+	protectedRouter.Handle("/reports/{id}/history",
+		roleMiddleware.RequirePermission(middleware.PermVerifyReports)(http.HandlerFunc(reportHandler.ReportHistory)),
+	).Methods("GET")
+	protectedRouter.Handle("/reports/{id}/merge",
+		roleMiddleware.RequirePermission(middleware.PermAdminAccess)(http.HandlerFunc(reportHandler.MergeReports)),
+	).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/attachments", reportHandler.AttachUploads).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/subscribe", reportHandler.SubscribeReport).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/flag", reportHandler.FlagReport).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/subscribe", reportHandler.UnsubscribeReport).Methods("DELETE")
+	protectedRouter.HandleFunc("/reports/{id}/goal", reportHandler.SetGoal).Methods("PUT")
+	protectedRouter.HandleFunc("/reports/{id}/escrow", reportHandler.GetEscrow).Methods("GET")
+	protectedRouter.Handle("/reports/{id}/escrow/release",
+		roleMiddleware.RequirePermission(middleware.PermManageDonations)(http.HandlerFunc(reportHandler.ReleaseEscrow)),
+	).Methods("POST")
+	protectedRouter.Handle("/reports/{id}/escrow/release",
+		roleMiddleware.RequirePermission(middleware.PermManageDonations)(http.HandlerFunc(reportHandler.CancelEscrowRelease)),
+	).Methods("DELETE")
+	protectedRouter.HandleFunc("/reports/{id}/needs", reportHandler.ListNeeds).Methods("GET")
+	protectedRouter.HandleFunc("/reports/{id}/needs", reportHandler.CreateNeed).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/needs/{needId}", reportHandler.UpdateNeed).Methods("PATCH")
+	protectedRouter.HandleFunc("/reports/{id}/needs/{needId}", reportHandler.DeleteNeed).Methods("DELETE")
+	protectedRouter.HandleFunc("/reports/{id}/needs/{needId}/fulfillments", reportHandler.ListFulfillments).Methods("GET")
+	protectedRouter.HandleFunc("/reports/{id}/needs/{needId}/fulfillments", reportHandler.RecordFulfillment).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/comments", reportHandler.ListComments).Methods("GET")
+	protectedRouter.HandleFunc("/reports/{id}/comments", reportHandler.CreateComment).Methods("POST")
+	protectedRouter.HandleFunc("/reports/{id}/comments/{commentId}", reportHandler.UpdateComment).Methods("PATCH")
+	protectedRouter.HandleFunc("/reports/{id}/comments/{commentId}", reportHandler.DeleteComment).Methods("DELETE")
+	protectedRouter.Handle("/reports/{id}/crosscheck",
+		roleMiddleware.RequirePermission(middleware.PermVerifyReports)(http.HandlerFunc(crossCheckHandler.GetCrossCheck)),
+	).Methods("GET")
+	protectedRouter.Handle("/reports/{id}/crosscheck",
+		roleMiddleware.RequirePermission(middleware.PermVerifyReports)(http.HandlerFunc(crossCheckHandler.RunCrossCheck)),
+	).Methods("POST")
+
+	// Coordination dashboard queries
+	protectedRouter.HandleFunc("/graphql", graphqlHandler.Query).Methods("POST")
+	protectedRouter.HandleFunc("/graphql", graphqlHandler.Schema).Methods("GET")
+
+	// Telegram account linking
+	protectedRouter.HandleFunc("/users/me/telegram", telegramHandler.GetLink).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/telegram", telegramHandler.Unlink).Methods("DELETE")
+	protectedRouter.HandleFunc("/users/me/telegram/link", telegramHandler.StartLink).Methods("POST")
+
+	// Weekly digest routes
+	protectedRouter.HandleFunc("/users/me/digest", digestHandler.GetSettings).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/digest", digestHandler.UpdatePreferences).Methods("PUT")
+	protectedRouter.HandleFunc("/users/me/digest/preview", digestHandler.Preview).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/digest/regions", digestHandler.FollowRegion).Methods("POST")
+	protectedRouter.HandleFunc("/users/me/digest/regions/{id}", digestHandler.UnfollowRegion).Methods("DELETE")
+
+	// Volunteer routes
+	protectedRouter.HandleFunc("/users/me/volunteer", volunteerHandler.GetOwnProfile).Methods("GET")
+	protectedRouter.HandleFunc("/users/me/volunteer", volunteerHandler.UpdateOwnProfile).Methods("PUT")
+	protectedRouter.HandleFunc("/users/me/volunteer-requests", volunteerHandler.ListOwnRequests).Methods("GET")
+	protectedRouter.HandleFunc("/volunteer-requests/{id}/respond", volunteerHandler.RespondToRequest).Methods("POST")
+	protectedRouter.Handle("/volunteers",
+		roleMiddleware.RequirePermission(middleware.PermCoordinateVolunteers)(http.HandlerFunc(volunteerHandler.SearchVolunteers)),
+	).Methods("GET")
+	protectedRouter.Handle("/reports/{id}/volunteer-requests",
+		roleMiddleware.RequirePermission(middleware.PermCoordinateVolunteers)(http.HandlerFunc(volunteerHandler.RequestVolunteer)),
+	).Methods("POST")
+	protectedRouter.Handle("/reports/{id}/volunteer-requests",
+		roleMiddleware.RequirePermission(middleware.PermCoordinateVolunteers)(http.HandlerFunc(volunteerHandler.ListReportRequests)),
+	).Methods("GET")
+
+	// Webhook routes
+	protectedRouter.HandleFunc("/webhooks", webhookHandler.ListWebhooks).Methods("GET")
+	protectedRouter.HandleFunc("/webhooks", webhookHandler.CreateWebhook).Methods("POST")
+	protectedRouter.HandleFunc("/webhooks/{id}", webhookHandler.DeleteWebhook).Methods("DELETE")
+	protectedRouter.HandleFunc("/webhooks/{id}/deliveries", webhookHandler.ListDeliveries).Methods("GET")
+	protectedRouter.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}", webhookHandler.GetDelivery).Methods("GET")
+	protectedRouter.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/redeliver", webhookHandler.Redeliver).Methods("POST")
 
 	// Donation routes
 	protectedRouter.HandleFunc("/donations", donationHandler.CreateDonation).Methods("POST")
 	protectedRouter.HandleFunc("/donations", donationHandler.ListDonations).Methods("GET")
+	protectedRouter.HandleFunc("/donations/claim", donationHandler.ClaimDonation).Methods("POST")
 	protectedRouter.HandleFunc("/donations/{id}", donationHandler.GetDonation).Methods("GET")
-	protectedRouter.HandleFunc("/donations/{id}/status", donationHandler.UpdateStatus).Methods("PUT")
+	protectedRouter.HandleFunc("/donations/{id}/receipt", donationHandler.GetReceipt).Methods("GET")
+	protectedRouter.HandleFunc("/donations/{id}/refund", donationHandler.RefundDonation).Methods("POST")
+	protectedRouter.HandleFunc("/donations/{id}/logistics", donationHandler.UpdateLogistics).Methods("PATCH")
+	protectedRouter.HandleFunc("/disbursements", disbursementHandler.CreateDisbursement).Methods("POST")
+	protectedRouter.Handle("/disbursements",
+		roleMiddleware.RequirePermission(middleware.PermManageDonations)(http.HandlerFunc(disbursementHandler.ListDisbursements)),
+	).Methods("GET")
+	protectedRouter.Handle("/donations/{id}/status",
+		middleware.RequireRole(middleware.RoleAdmin)(http.HandlerFunc(donationHandler.UpdateStatus)),
+	).Methods("PUT")
+	protectedRouter.HandleFunc("/donation-subscriptions", donationHandler.CreateSubscription).Methods("POST")
+	protectedRouter.HandleFunc("/donation-subscriptions", donationHandler.ListSubscriptions).Methods("GET")
+	protectedRouter.HandleFunc("/donation-subscriptions/{id}/pause", donationHandler.PauseSubscription).Methods("POST")
+	protectedRouter.HandleFunc("/donation-subscriptions/{id}/resume", donationHandler.ResumeSubscription).Methods("POST")
+	protectedRouter.HandleFunc("/donation-subscriptions/{id}/cancel", donationHandler.CancelSubscription).Methods("POST")
+
+	// Organization routes
+	protectedRouter.HandleFunc("/organizations", organizationHandler.CreateOrganization).Methods("POST")
+	protectedRouter.HandleFunc("/organizations/{id}/verification", organizationHandler.SubmitVerification).Methods("POST")
+	protectedRouter.HandleFunc("/organizations/{id}/members", organizationHandler.ListMembers).Methods("GET")
+	protectedRouter.HandleFunc("/organizations/{id}/members", organizationHandler.AddMember).Methods("POST")
+	protectedRouter.HandleFunc("/organizations/{id}/members/{userId}", organizationHandler.UpdateMember).Methods("PUT")
+	protectedRouter.HandleFunc("/organizations/{id}/members/{userId}", organizationHandler.RemoveMember).Methods("DELETE")
+	protectedRouter.HandleFunc("/organizations/{id}/invitations", organizationHandler.ListInvitations).Methods("GET")
+	protectedRouter.HandleFunc("/organizations/{id}/invitations/{invitationId}", organizationHandler.RevokeInvitation).Methods("DELETE")
+	protectedRouter.HandleFunc("/organizations/invitations/accept", organizationHandler.AcceptInvitation).Methods("POST")
+	protectedRouter.HandleFunc("/organizations/{id}/donations", organizationHandler.ListDonations).Methods("GET")
 
 	// File upload routes with specific security measures
 	protectedRouter.HandleFunc("/uploads", uploadHandler.UploadFiles).Methods("POST")
 	protectedRouter.HandleFunc("/uploads/{id}", uploadHandler.GetFile).Methods("GET")
 
+	// Admin routes
+	adminRouter := protectedRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(middleware.RequireSession)
+	adminRouter.Use(roleMiddleware.RequirePermission(middleware.PermAdminAccess))
+	adminRouter.HandleFunc("/security/summary", adminHandler.SecuritySummary).Methods("GET")
+	adminRouter.HandleFunc("/audit-logs/export", adminHandler.ExportAuditLogs).Methods("GET")
+	adminRouter.HandleFunc("/audit-logs/policy", adminHandler.GetAuditPolicy).Methods("GET")
+	adminRouter.HandleFunc("/audit-logs/policy", adminHandler.UpdateAuditPolicy).Methods("PUT")
+	adminRouter.HandleFunc("/audit-logs/stream", adminHandler.StreamAuditEvents).Methods("GET")
+	adminRouter.HandleFunc("/policies", consentHandler.PublishPolicyVersion).Methods("POST")
+	adminRouter.HandleFunc("/users/search", adminHandler.SearchUsers).Methods("GET")
+	adminRouter.HandleFunc("/users/import", userImportHandler.ImportUsers).Methods("POST")
+	adminRouter.HandleFunc("/users/imports/{id}", userImportHandler.GetImport).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}", adminHandler.DeleteUser).Methods("DELETE")
+	adminRouter.HandleFunc("/users/{id}/restore", adminHandler.RestoreUser).Methods("POST")
+	adminRouter.HandleFunc("/users/{id}/suspension", adminHandler.SuspendUser).Methods("POST")
+	adminRouter.HandleFunc("/users/{id}/suspension", adminHandler.LiftSuspension).Methods("DELETE")
+	adminRouter.HandleFunc("/users/{id}/suspensions", adminHandler.SuspensionHistory).Methods("GET")
+	adminRouter.HandleFunc("/users/{id}/unlock", adminHandler.UnlockUser).Methods("POST")
+	adminRouter.HandleFunc("/roles", adminHandler.ListRoles).Methods("GET")
+	adminRouter.HandleFunc("/rate-limits", rateLimitHandler.ListLimits).Methods("GET")
+	adminRouter.HandleFunc("/rate-limits", rateLimitHandler.UpdateLimits).Methods("PUT")
+	adminRouter.HandleFunc("/donation-limits", donationLimitHandler.UpdateLimit).Methods("PUT")
+	adminRouter.HandleFunc("/reports/deleted", reportHandler.ListDeletedReports).Methods("GET")
+	adminRouter.HandleFunc("/reports/{id}/restore", reportHandler.RestoreReport).Methods("POST")
+	adminRouter.HandleFunc("/reports/flagged", reportHandler.ListFlaggedReports).Methods("GET")
+	adminRouter.HandleFunc("/reports/{id}/flags/resolve", reportHandler.ResolveReportFlags).Methods("POST")
+	// Role assignment stays with admins even if admin:access is ever granted more widely
+	adminRouter.Handle("/users/{id}/role",
+		middleware.RequireRole(middleware.RoleAdmin)(http.HandlerFunc(adminHandler.SetRole)),
+	).Methods("PUT")
+	adminRouter.HandleFunc("/organizations", organizationHandler.ListOrganizations).Methods("GET")
+	adminRouter.HandleFunc("/organizations/{id}/verification", organizationHandler.ReviewVerification).Methods("POST")
+	adminRouter.HandleFunc("/organizations/{id}/sso", organizationHandler.GetSSOConfig).Methods("GET")
+	adminRouter.HandleFunc("/organizations/{id}/sso", organizationHandler.UpdateSSOConfig).Methods("PUT")
+	adminRouter.HandleFunc("/organizations/{id}/sso", organizationHandler.DeleteSSOConfig).Methods("DELETE")
+	adminRouter.HandleFunc("/verifier-applications", verifierApplicationHandler.ListApplications).Methods("GET")
+	adminRouter.HandleFunc("/verifier-applications/{id}", verifierApplicationHandler.ReviewApplication).Methods("POST")
+	adminRouter.HandleFunc("/broadcasts", broadcastHandler.ListBroadcasts).Methods("GET")
+	adminRouter.HandleFunc("/broadcasts", broadcastHandler.SendBroadcast).Methods("POST")
+	adminRouter.HandleFunc("/broadcasts/preview", broadcastHandler.Preview).Methods("POST")
+	adminRouter.HandleFunc("/broadcasts/{id}", broadcastHandler.GetBroadcast).Methods("GET")
+	adminRouter.HandleFunc("/templates", templateHandler.ListTemplates).Methods("GET")
+	adminRouter.HandleFunc("/templates/{key}/{locale}", templateHandler.GetTemplate).Methods("GET")
+	adminRouter.HandleFunc("/templates/{key}/{locale}", templateHandler.UpdateTemplate).Methods("PUT")
+	adminRouter.HandleFunc("/templates/{key}/{locale}/preview", templateHandler.PreviewTemplate).Methods("POST")
+	adminRouter.HandleFunc("/templates/{key}/{locale}/versions/{version}/restore", templateHandler.RestoreVersion).Methods("POST")
+	adminRouter.HandleFunc("/deliveries", deliveryHandler.ListDeliveries).Methods("GET")
+	adminRouter.HandleFunc("/deliveries/dead-letters", deliveryHandler.ListDeadLetters).Methods("GET")
+	adminRouter.HandleFunc("/deliveries/health", deliveryHandler.Health).Methods("GET")
+	adminRouter.HandleFunc("/deliveries/retry", deliveryHandler.RetryDeliveries).Methods("POST")
+	adminRouter.HandleFunc("/inbound-webhooks", inboundWebhookHandler.ListEvents).Methods("GET")
+	adminRouter.HandleFunc("/inbound-webhooks/{id}", inboundWebhookHandler.GetEvent).Methods("GET")
+	adminRouter.HandleFunc("/inbound-webhooks/{id}/replay", inboundWebhookHandler.ReplayEvent).Methods("POST")
+	adminRouter.HandleFunc("/email-suppressions", emailEventHandler.ListSuppressions).Methods("GET")
+	adminRouter.HandleFunc("/email-suppressions/{email}", emailEventHandler.RemoveSuppression).Methods("DELETE")
+	adminRouter.HandleFunc("/integrations", chatIntegrationHandler.ListIntegrations).Methods("GET")
+	adminRouter.HandleFunc("/integrations", chatIntegrationHandler.CreateIntegration).Methods("POST")
+	adminRouter.HandleFunc("/integrations/{id}", chatIntegrationHandler.DeleteIntegration).Methods("DELETE")
+	adminRouter.HandleFunc("/integrations/{id}/test", chatIntegrationHandler.TestIntegration).Methods("POST")
+
+	if err := openapi.Check(router, apiOperations); err != nil {
+		log.Fatal(err)
+	}
+
 	return router
 }
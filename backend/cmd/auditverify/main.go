@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+
+	"saferelief/internal/audit"
+)
+
+// auditverify checks the hash chain of a WORM audit mirror and, when
+// AUDIT_WORM_DIGEST_KEY is set, the signatures of its digest file.
+//
+//	go run ./cmd/auditverify /var/log/saferelief/audit.worm
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: auditverify <worm-file>")
+	}
+	path := os.Args[1]
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	count, err := audit.VerifyWORMChain(f)
+	if err != nil {
+		log.Fatalf("Chain verification FAILED after %d records: %v", count, err)
+	}
+	log.Printf("Chain OK: %d records", count)
+
+	key := os.Getenv("AUDIT_WORM_DIGEST_KEY")
+	if key == "" {
+		return
+	}
+
+	digests, err := os.Open(path + ".digests")
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer digests.Close()
+
+	scanner := bufio.NewScanner(digests)
+	var checked int
+	for scanner.Scan() {
+		var digest audit.WORMDigest
+		if err := json.Unmarshal(scanner.Bytes(), &digest); err != nil {
+			log.Fatalf("Digest %d unreadable: %v", checked+1, err)
+		}
+		if !audit.VerifyWORMDigest([]byte(key), digest) {
+			log.Fatalf("Digest for sequence %d has an invalid signature", digest.Sequence)
+		}
+		checked++
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Digests OK: %d signatures", checked)
+}
@@ -0,0 +1,292 @@
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// DeletionGracePeriod is how long a soft-deleted account can still be
+// restored before its personal data is irreversibly anonymized
+const DeletionGracePeriod = 30 * 24 * time.Hour
+
+var (
+	ErrNotFound           = errors.New("account not found")
+	ErrNotDeleted         = errors.New("account is not deleted")
+	ErrGracePeriodExpired = errors.New("deletion grace period has expired")
+	ErrOwnsOrganization   = errors.New("account owns an organization")
+)
+
+const (
+	unusablePasswordHash   = "!" // never produced by bcrypt, so no password can match
+	anonymizedEmailDomain  = "@deleted.saferelief.invalid"
+	anonymizedUserPrefix   = "deleted-"
+	redactedDonationNotice = "[redacted]"
+)
+
+// SoftDelete marks an account deleted; it can no longer sign in but can be
+// restored until the grace period ends. requestedBy is the user themselves or
+// the admin who deleted them, and is kept in the account_deletions trail.
+// It returns when the account's data will be purged.
+func SoftDelete(db *sql.DB, userID, requestedBy string) (time.Time, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE users SET deleted_at = NOW(), status = 'inactive'
+		WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return time.Time{}, ErrNotFound
+	}
+
+	purgeAfter := time.Now().Add(DeletionGracePeriod)
+	_, err = tx.Exec(
+		`INSERT INTO account_deletions (id, user_id, requested_by, self_service, purge_after)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?)`,
+		userID, requestedBy, userID == requestedBy, purgeAfter,
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = UUID_TO_BIN(?)", userID); err != nil {
+		return time.Time{}, err
+	}
+	return purgeAfter, tx.Commit()
+}
+
+// OwnsOrganization reports whether the user still owns an organization, which
+// would be left without an owner if the account were deleted
+func OwnsOrganization(db *sql.DB, userID string) (bool, error) {
+	var owns bool
+	err := db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM organizations WHERE owner_id = UUID_TO_BIN(?))",
+		userID,
+	).Scan(&owns)
+	return owns, err
+}
+
+func Restore(db *sql.DB, userID string) error {
+	var deletedAt, anonymizedAt *time.Time
+	err := db.QueryRow(
+		"SELECT deleted_at, anonymized_at FROM users WHERE id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&deletedAt, &anonymizedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if deletedAt == nil {
+		return ErrNotDeleted
+	}
+	if anonymizedAt != nil || time.Since(*deletedAt) > DeletionGracePeriod {
+		return ErrGracePeriodExpired
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE users SET deleted_at = NULL, status = 'active' WHERE id = UUID_TO_BIN(?) AND anonymized_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`UPDATE account_deletions SET restored_at = NOW()
+		WHERE user_id = UUID_TO_BIN(?) AND restored_at IS NULL AND anonymized_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Anonymize strips personal data from a deleted account and its linked
+// records in a single transaction. Donations and reports are kept for
+// accounting but no longer identify the person; their amounts still count
+// towards every total. Uploaded files are removed from disk once the
+// transaction has committed.
+func Anonymize(ctx context.Context, db *sql.DB, userID string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	files, err := uploadedFiles(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Matched by address, so this has to run before the email is overwritten
+	if _, err := tx.ExecContext(ctx,
+		`DELETE nd FROM notification_deliveries nd
+		JOIN users u ON u.email = nd.recipient
+		WHERE u.id = UUID_TO_BIN(?)`,
+		userID,
+	); err != nil {
+		return err
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{
+			`UPDATE users SET
+				username = CONCAT(?, BIN_TO_UUID(id)),
+				email = CONCAT(BIN_TO_UUID(id), ?),
+				password_hash = ?,
+				mfa_secret = NULL,
+				mfa_enabled = FALSE,
+				avatar_url = NULL,
+				avatar_sizes = NULL,
+				display_name = NULL,
+				phone = NULL,
+				bio = NULL,
+				anonymized_at = NOW()
+			WHERE id = UUID_TO_BIN(?) AND deleted_at IS NOT NULL AND anonymized_at IS NULL`,
+			[]interface{}{anonymizedUserPrefix, anonymizedEmailDomain, unusablePasswordHash, userID},
+		},
+		{
+			"UPDATE donations SET description = ? WHERE donor_id = UUID_TO_BIN(?) AND description IS NOT NULL",
+			[]interface{}{redactedDonationNotice, userID},
+		},
+		{
+			"UPDATE audit_logs SET ip_address = '0.0.0.0', user_agent = NULL WHERE user_id = UUID_TO_BIN(?)",
+			[]interface{}{userID},
+		},
+		{
+			"UPDATE user_consents SET ip_address = '0.0.0.0' WHERE user_id = UUID_TO_BIN(?)",
+			[]interface{}{userID},
+		},
+		{"DELETE FROM sessions WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM user_devices WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM mfa_recovery_codes WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM magic_links WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM notification_preferences WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM sso_identities WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM data_exports WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM user_locations WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM followed_regions WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM report_subscriptions WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM telegram_links WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM api_keys WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM volunteer_profiles WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM telegram_report_drafts WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM email_changes WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		// Report photos and attachments can show the person, so they go too
+		{"DELETE FROM file_uploads WHERE user_id = UUID_TO_BIN(?)", []interface{}{userID}},
+		{"DELETE FROM uploads WHERE user_id = ?", []interface{}{userID}},
+		{
+			`UPDATE account_deletions SET anonymized_at = NOW(), files_deleted = ?
+			WHERE user_id = UUID_TO_BIN(?) AND restored_at IS NULL AND anonymized_at IS NULL`,
+			[]interface{}{len(files), userID},
+		},
+	}
+
+	for i, stmt := range statements {
+		result, err := tx.ExecContext(ctx, stmt.query, stmt.args...)
+		if err != nil {
+			return err
+		}
+		// The user row guards the rest: nothing to do if it was already anonymized
+		if i == 0 {
+			if rows, _ := result.RowsAffected(); rows == 0 {
+				return nil
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove file %s of anonymized account %s: %v", path, userID, err)
+		}
+	}
+	return nil
+}
+
+// uploadedFiles lists the paths on disk of everything the user uploaded,
+// including finished data exports
+func uploadedFiles(ctx context.Context, tx *sql.Tx, userID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT storage_path FROM file_uploads WHERE user_id = UUID_TO_BIN(?)
+		UNION ALL
+		SELECT path FROM uploads WHERE user_id = ?
+		UNION ALL
+		SELECT file_path FROM data_exports WHERE user_id = UUID_TO_BIN(?) AND file_path IS NOT NULL`,
+		userID, userID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, rows.Err()
+}
+
+// StartPurge periodically anonymizes accounts whose grace period has ended
+func StartPurge(db *sql.DB, interval time.Duration, onAnonymized func(userID string)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rows, err := db.Query(
+				`SELECT BIN_TO_UUID(id) FROM users
+				WHERE deleted_at IS NOT NULL AND anonymized_at IS NULL AND deleted_at < ?`,
+				time.Now().Add(-DeletionGracePeriod),
+			)
+			if err != nil {
+				log.Printf("Account purge query failed: %v", err)
+				continue
+			}
+			var userIDs []string
+			for rows.Next() {
+				var id string
+				if err := rows.Scan(&id); err == nil {
+					userIDs = append(userIDs, id)
+				}
+			}
+			rows.Close()
+
+			for _, id := range userIDs {
+				if err := Anonymize(context.Background(), db, id); err != nil {
+					log.Printf("Failed to anonymize account %s: %v", id, err)
+					continue
+				}
+				if onAnonymized != nil {
+					onAnonymized(id)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package accounts
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Unlock clears failed login attempts and any lockout. It returns when the
+// lock would have expired, or nil if the account was not locked.
+func Unlock(db *sql.DB, userID string) (*time.Time, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var lockedUntil *time.Time
+	err = tx.QueryRow(
+		"SELECT locked_until FROM users WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE",
+		userID,
+	).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil != nil && !lockedUntil.After(time.Now()) {
+		lockedUntil = nil
+	}
+
+	_, err = tx.Exec(
+		"UPDATE users SET failed_attempts = 0, locked_until = NULL WHERE id = UUID_TO_BIN(?)",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return lockedUntil, tx.Commit()
+}
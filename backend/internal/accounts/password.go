@@ -0,0 +1,87 @@
+package accounts
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordExpiryWarning is how far ahead of expiry users are warned
+const PasswordExpiryWarning = 14 * 24 * time.Hour
+
+// privilegedRoles are subject to password max-age enforcement
+var privilegedRoles = map[string]bool{
+	"admin":    true,
+	"verifier": true,
+}
+
+// Privileged reports whether role is one of the roles held to stricter
+// sign-in rules
+func Privileged(role string) bool {
+	return privilegedRoles[role]
+}
+
+// HasPassword reports whether a stored hash can ever match a password;
+// accounts made through SSO or invitations start without one
+func HasPassword(hash string) bool {
+	return hash != unusablePasswordHash
+}
+
+// PasswordPolicy limits how long privileged accounts may keep a password.
+// A zero MaxAge disables expiry.
+type PasswordPolicy struct {
+	MaxAge time.Duration
+}
+
+// PasswordPolicyFromEnv reads PASSWORD_MAX_AGE_DAYS; unset or 0 disables expiry
+func PasswordPolicyFromEnv() PasswordPolicy {
+	days, _ := strconv.Atoi(os.Getenv("PASSWORD_MAX_AGE_DAYS"))
+	if days < 0 {
+		days = 0
+	}
+	return PasswordPolicy{MaxAge: time.Duration(days) * 24 * time.Hour}
+}
+
+// ExpiresAt returns when the password stops being accepted, or nil when the
+// role is not subject to expiry
+func (p PasswordPolicy) ExpiresAt(role string, changedAt time.Time) *time.Time {
+	if p.MaxAge <= 0 || !privilegedRoles[role] {
+		return nil
+	}
+	expiresAt := changedAt.Add(p.MaxAge)
+	return &expiresAt
+}
+
+func (p PasswordPolicy) Expired(role string, changedAt time.Time) bool {
+	expiresAt := p.ExpiresAt(role, changedAt)
+	return expiresAt != nil && !time.Now().Before(*expiresAt)
+}
+
+func (p PasswordPolicy) ExpiringSoon(role string, changedAt time.Time) bool {
+	expiresAt := p.ExpiresAt(role, changedAt)
+	return expiresAt != nil && time.Until(*expiresAt) < PasswordExpiryWarning
+}
+
+// ChangePassword stores a new password and restarts its max-age clock
+func ChangePassword(db *sql.DB, userID, newPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(
+		`UPDATE users SET password_hash = ?, last_password_change = NOW(),
+		require_password_change = FALSE, updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL`,
+		hash, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"math/big"
+	"strings"
+
+	"saferelief/internal/tokens"
+)
+
+// RecoveryCodeCount is how many one-time codes a user gets per generation
+const RecoveryCodeCount = 10
+
+// recoveryAlphabet leaves out characters that are easy to misread when the
+// codes are written down
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateRecoveryCodes replaces the user's MFA recovery codes with a fresh
+// set. The plain codes are returned once; only their hashes are stored.
+func GenerateRecoveryCodes(db *sql.DB, userID string) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = UUID_TO_BIN(?)", userID); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		_, err := tx.Exec(
+			`INSERT INTO mfa_recovery_codes (id, user_id, code_hash)
+			VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?)`,
+			userID, tokens.Hash(normalizeRecoveryCode(code)),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, tx.Commit()
+}
+
+// UseRecoveryCode consumes a recovery code, reporting whether it was valid
+// and unused
+func UseRecoveryCode(db *sql.DB, userID, code string) (bool, error) {
+	code = normalizeRecoveryCode(code)
+	if len(code) != 10 {
+		return false, nil
+	}
+	result, err := db.Exec(
+		`UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE user_id = UUID_TO_BIN(?) AND code_hash = ? AND used_at IS NULL`,
+		userID, tokens.Hash(code),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// RemainingRecoveryCodes counts the user's unused recovery codes
+func RemainingRecoveryCodes(db *sql.DB, userID string) (int, error) {
+	var remaining int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = UUID_TO_BIN(?) AND used_at IS NULL",
+		userID,
+	).Scan(&remaining)
+	return remaining, err
+}
+
+// DeleteRecoveryCodes drops all codes, e.g. when MFA is turned off
+func DeleteRecoveryCodes(db *sql.DB, userID string) error {
+	_, err := db.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = UUID_TO_BIN(?)", userID)
+	return err
+}
+
+// newRecoveryCode returns a code like "k7mq2-x9tfa", about 50 bits of entropy
+func newRecoveryCode() (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(recoveryAlphabet)))
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(recoveryAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalizeRecoveryCode accepts codes typed with any case, spaces or dashes
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
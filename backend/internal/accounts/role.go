@@ -0,0 +1,47 @@
+package accounts
+
+import "database/sql"
+
+// ChangeRole assigns a new role and returns the previous one. Access tokens
+// carry the role, so the user's sessions are ended and the next login issues
+// tokens with the new role.
+func ChangeRole(db *sql.DB, userID, role string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	previous, err := ChangeRoleTx(tx, userID, role)
+	if err != nil {
+		return "", err
+	}
+	return previous, tx.Commit()
+}
+
+// ChangeRoleTx is ChangeRole inside the caller's transaction, for role
+// changes that go with other writes. Every role change goes through it.
+func ChangeRoleTx(tx *sql.Tx, userID, role string) (string, error) {
+	var previous string
+	err := tx.QueryRow(
+		"SELECT role FROM users WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE",
+		userID,
+	).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if previous == role {
+		return previous, nil
+	}
+
+	if _, err := tx.Exec("UPDATE users SET role = ? WHERE id = UUID_TO_BIN(?)", role, userID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = UUID_TO_BIN(?)", userID); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
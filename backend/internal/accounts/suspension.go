@@ -0,0 +1,176 @@
+package accounts
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SuspensionKind string
+
+const (
+	KindSuspension SuspensionKind = "suspension"
+	KindBan        SuspensionKind = "ban"
+)
+
+var (
+	ErrAlreadySuspended = errors.New("account already has an active suspension")
+	ErrNotSuspended     = errors.New("account has no active suspension")
+)
+
+// Suspension is a restriction on an account. Bans never expire; suspensions
+// may run until ExpiresAt or indefinitely until lifted.
+type Suspension struct {
+	ID        string         `json:"id"`
+	Kind      SuspensionKind `json:"type"`
+	Reason    string         `json:"reason"`
+	ExpiresAt *time.Time     `json:"expiresAt"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// SuspensionRecord is a past or current restriction with who applied and
+// lifted it
+type SuspensionRecord struct {
+	Suspension
+	CreatedBy string     `json:"createdBy"`
+	LiftedAt  *time.Time `json:"liftedAt"`
+	LiftedBy  *string    `json:"liftedBy"`
+	Active    bool       `json:"active"`
+}
+
+const activeSuspensionClause = `lifted_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
+
+// ActiveSuspension returns the restriction currently in force on the account,
+// or nil when it may use the API normally
+func ActiveSuspension(db *sql.DB, userID string) (*Suspension, error) {
+	var s Suspension
+	err := db.QueryRow(
+		`SELECT BIN_TO_UUID(id), kind, reason, expires_at, created_at
+		FROM user_suspensions
+		WHERE user_id = UUID_TO_BIN(?) AND `+activeSuspensionClause+`
+		ORDER BY kind = 'ban' DESC, created_at DESC LIMIT 1`,
+		userID,
+	).Scan(&s.ID, &s.Kind, &s.Reason, &s.ExpiresAt, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SuspensionHistory lists every suspension and ban on the account, newest first
+func SuspensionHistory(db *sql.DB, userID string) ([]SuspensionRecord, error) {
+	rows, err := db.Query(
+		`SELECT BIN_TO_UUID(id), kind, reason, expires_at, created_at,
+		BIN_TO_UUID(created_by), lifted_at, BIN_TO_UUID(lifted_by), `+activeSuspensionClause+`
+		FROM user_suspensions
+		WHERE user_id = UUID_TO_BIN(?)
+		ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []SuspensionRecord{}
+	for rows.Next() {
+		var s SuspensionRecord
+		if err := rows.Scan(
+			&s.ID, &s.Kind, &s.Reason, &s.ExpiresAt, &s.CreatedAt,
+			&s.CreatedBy, &s.LiftedAt, &s.LiftedBy, &s.Active,
+		); err != nil {
+			return nil, err
+		}
+		history = append(history, s)
+	}
+	return history, rows.Err()
+}
+
+// Suspend places a suspension or ban on the account and ends its sessions
+func Suspend(db *sql.DB, userID, adminID string, kind SuspensionKind, reason string, expiresAt *time.Time) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM users WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE",
+		userID,
+	).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+	if exists == 0 {
+		return "", ErrNotFound
+	}
+
+	var active int
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM user_suspensions WHERE user_id = UUID_TO_BIN(?) AND "+activeSuspensionClause,
+		userID,
+	).Scan(&active)
+	if err != nil {
+		return "", err
+	}
+	if active > 0 {
+		return "", ErrAlreadySuspended
+	}
+
+	var suspensionID string
+	err = tx.QueryRow(
+		`INSERT INTO user_suspensions (id, user_id, kind, reason, expires_at, created_by)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, UUID_TO_BIN(?))
+		RETURNING BIN_TO_UUID(id)`,
+		userID, kind, reason, expiresAt, adminID,
+	).Scan(&suspensionID)
+	if err != nil {
+		return "", err
+	}
+
+	if kind == KindBan {
+		if _, err := tx.Exec("UPDATE users SET status = 'banned' WHERE id = UUID_TO_BIN(?)", userID); err != nil {
+			return "", err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = UUID_TO_BIN(?)", userID); err != nil {
+		return "", err
+	}
+
+	return suspensionID, tx.Commit()
+}
+
+// Lift ends the account's active suspension or ban early
+func Lift(db *sql.DB, userID, adminID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE user_suspensions SET lifted_at = NOW(), lifted_by = UUID_TO_BIN(?)
+		WHERE user_id = UUID_TO_BIN(?) AND `+activeSuspensionClause,
+		adminID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotSuspended
+	}
+
+	_, err = tx.Exec(
+		"UPDATE users SET status = 'active' WHERE id = UUID_TO_BIN(?) AND status = 'banned'",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
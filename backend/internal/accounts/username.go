@@ -0,0 +1,37 @@
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var usernameUnsafeChars = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// AvailableUsername derives a username from a display name, adding a number
+// when the plain form is taken
+func AvailableUsername(ctx context.Context, tx *sql.Tx, name string) (string, error) {
+	base := usernameUnsafeChars.ReplaceAllString(strings.ToLower(strings.ReplaceAll(name, " ", ".")), "")
+	base = strings.Trim(base, ".")
+	if len(base) < 3 {
+		base = "user." + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 2; i < 100; i++ {
+		var taken int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE username = ?", candidate).Scan(&taken); err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", fmt.Errorf("could not find a free username for %q", name)
+}
@@ -0,0 +1,413 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"saferelief/internal/jobs"
+	"saferelief/internal/notify"
+	"saferelief/internal/telegram"
+)
+
+// Alert types an integration can subscribe to
+const (
+	AlertReportVerified   = "report.verified"
+	AlertFundingMilestone = "report.funding_milestone"
+)
+
+var severityRank = map[string]int{"low": 0, "medium": 1, "high": 2, "critical": 3}
+
+func ValidAlert(alert string) bool {
+	return alert == AlertReportVerified || alert == AlertFundingMilestone
+}
+
+func ValidSeverity(severity string) bool {
+	_, ok := severityRank[severity]
+	return ok
+}
+
+type integration struct {
+	id          string
+	provider    Provider
+	url         string
+	minSeverity string
+	currency    string
+	milestones  []float64
+}
+
+type report struct {
+	reporterID  string
+	title       string
+	description string
+	severity    string
+	latitude    float64
+	longitude   float64
+}
+
+// Notifier posts report cards to the Slack and Discord channels configured
+// by admins and to Telegram chats that opted into alerts, and emails the
+// reporter when their preferences allow it
+type Notifier struct {
+	db          *sql.DB
+	queue       *jobs.Queue
+	client      *http.Client
+	frontendURL string
+	telegram    *telegram.Bot
+	mailer      *notify.Mailer
+}
+
+func NewNotifier(db *sql.DB, queue *jobs.Queue) *Notifier {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	return &Notifier{
+		db:          db,
+		queue:       queue,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		frontendURL: frontendURL,
+	}
+}
+
+func (n *Notifier) SetTelegram(bot *telegram.Bot) {
+	n.telegram = bot
+}
+
+func (n *Notifier) SetMailer(mailer *notify.Mailer) {
+	n.mailer = mailer
+}
+
+// ReportVerified announces a newly verified report
+func (n *Notifier) ReportVerified(reportID string) {
+	rep, err := n.loadReport(reportID)
+	if err != nil {
+		log.Printf("Failed to load report %s for chat alert: %v", reportID, err)
+		return
+	}
+	card := n.card(reportID, rep, "Verified disaster: "+rep.title, truncate(rep.description, 300))
+
+	for _, in := range n.integrations(AlertReportVerified, rep.severity) {
+		n.post(in, card)
+	}
+	if n.telegram != nil && n.telegram.Enabled() {
+		n.postTelegram(reportID, card)
+	}
+	n.emailReporter(reportID, rep, notify.EventReportVerified, "report.verified", map[string]interface{}{
+		"Title": rep.title,
+		"Link":  n.frontendURL + "/reports/" + reportID,
+	})
+}
+
+// ReportRejected emails the reporter why their report was turned down; it
+// is not announced in chat channels
+func (n *Notifier) ReportRejected(reportID, reason, note string) {
+	rep, err := n.loadReport(reportID)
+	if err != nil {
+		log.Printf("Failed to load report %s for rejection email: %v", reportID, err)
+		return
+	}
+	n.emailReporter(reportID, rep, notify.EventReportRejected, "report.rejected", map[string]interface{}{
+		"Title":  rep.title,
+		"Reason": reason,
+		"Note":   note,
+		"Link":   n.frontendURL + "/reports/" + reportID,
+	})
+}
+
+// ReportStatusChanged emails the report's followers that its status moved,
+// from a single job. The reporter already gets their own email when a
+// report is verified or rejected, so they are skipped then.
+func (n *Notifier) ReportStatusChanged(reportID, status, note string) {
+	if n.mailer == nil {
+		return
+	}
+	rep, err := n.loadReport(reportID)
+	if err != nil {
+		log.Printf("Failed to load report %s for follower emails: %v", reportID, err)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":  rep.title,
+		"Status": status,
+		"Note":   note,
+		"Link":   n.frontendURL + "/reports/" + reportID,
+	}
+
+	err = n.queue.Enqueue(jobs.Job{
+		Name: "report-followers-" + reportID,
+		Run: func(ctx context.Context) error {
+			rows, err := n.db.QueryContext(ctx,
+				`SELECT BIN_TO_UUID(s.user_id) FROM report_subscriptions s
+				JOIN users u ON u.id = s.user_id AND u.status = 'active' AND u.deleted_at IS NULL
+				WHERE s.report_id = UUID_TO_BIN(?)`,
+				reportID,
+			)
+			if err != nil {
+				return err
+			}
+			var followers []string
+			for rows.Next() {
+				var userID string
+				if rows.Scan(&userID) == nil {
+					followers = append(followers, userID)
+				}
+			}
+			rows.Close()
+
+			for _, userID := range followers {
+				if userID == rep.reporterID && (status == "verified" || status == "rejected") {
+					continue
+				}
+				if err := n.mailer.Notify(ctx, n.db, userID, notify.EventReportUpdate, "report.status_changed", data); err != nil {
+					log.Printf("Failed to email follower %s of report %s: %v", userID, reportID, err)
+				}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue follower notifications for report %s: %v", reportID, err)
+	}
+}
+
+// ReportAutoClosed emails the reporter that the system closed their report
+// after idleDays without activity: a pending report that was never verified,
+// or a resolved one nobody followed up on
+func (n *Notifier) ReportAutoClosed(reportID, previousStatus string, idleDays int) {
+	rep, err := n.loadReport(reportID)
+	if err != nil {
+		log.Printf("Failed to load report %s for closure email: %v", reportID, err)
+		return
+	}
+	n.emailReporter(reportID, rep, notify.EventReportUpdate, "report.auto_closed", map[string]interface{}{
+		"Title":          rep.title,
+		"PreviousStatus": previousStatus,
+		"Days":           idleDays,
+		"Link":           n.frontendURL + "/reports/" + reportID,
+	})
+}
+
+// DonationCompleted checks whether the report's completed donations have
+// crossed any integration's funding milestone and announces each one once
+func (n *Notifier) DonationCompleted(reportID, currency string) {
+	rep, err := n.loadReport(reportID)
+	if err != nil {
+		log.Printf("Failed to load report %s for chat alert: %v", reportID, err)
+		return
+	}
+
+	var total float64
+	if err := n.db.QueryRow(
+		`SELECT COALESCE(SUM(amount), 0) FROM donations
+		WHERE disaster_report_id = UUID_TO_BIN(?) AND currency = ? AND status = 'completed'`,
+		reportID, currency,
+	).Scan(&total); err != nil {
+		log.Printf("Failed to total donations for report %s: %v", reportID, err)
+		return
+	}
+
+	n.emailReporter(reportID, rep, notify.EventDonationReceived, "donation.received", map[string]interface{}{
+		"Title":    rep.title,
+		"Currency": currency,
+		"Total":    fmt.Sprintf("%.2f", total),
+		"Link":     n.frontendURL + "/reports/" + reportID,
+	})
+
+	for _, in := range n.integrations(AlertFundingMilestone, rep.severity) {
+		if in.currency != currency {
+			continue
+		}
+		for _, milestone := range in.milestones {
+			if total < milestone || !n.claimMilestone(in.id, reportID, milestone) {
+				continue
+			}
+			text := fmt.Sprintf("Donations for this report have passed %s %.0f (now %s %.0f).",
+				currency, milestone, currency, total)
+			n.post(in, n.card(reportID, rep, "Funding milestone: "+rep.title, text))
+		}
+	}
+}
+
+// Test sends a sample card so admins can confirm the channel is wired up
+func (n *Notifier) Test(provider Provider, webhookURL string) error {
+	card := Card{
+		Title:     "SafeRelief test alert",
+		Text:      "This channel will receive SafeRelief disaster alerts.",
+		Severity:  "low",
+		DonateURL: n.frontendURL,
+	}
+	return n.send(context.Background(), provider, webhookURL, card)
+}
+
+func (n *Notifier) card(reportID string, rep *report, title, text string) Card {
+	return Card{
+		Title:     title,
+		Text:      text,
+		Severity:  rep.severity,
+		MapURL:    MapURL(rep.latitude, rep.longitude),
+		DonateURL: n.frontendURL + "/reports/" + reportID + "/donate",
+	}
+}
+
+func (n *Notifier) post(in integration, card Card) {
+	err := n.queue.Enqueue(jobs.Job{
+		Name:        "chat-alert-" + in.id,
+		MaxAttempts: 3,
+		Run: func(ctx context.Context) error {
+			return n.send(ctx, in.provider, in.url, card)
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue chat alert for integration %s: %v", in.id, err)
+	}
+}
+
+// emailReporter queues a templated email to the report's author, skipped
+// when they turned off email for the event
+func (n *Notifier) emailReporter(reportID string, rep *report, event, key string, data map[string]interface{}) {
+	if n.mailer == nil {
+		return
+	}
+	err := n.queue.Enqueue(jobs.Job{
+		Name: key + "-" + reportID,
+		Run: func(ctx context.Context) error {
+			return n.mailer.Notify(ctx, n.db, rep.reporterID, event, key, data)
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue %s email for report %s: %v", key, reportID, err)
+	}
+}
+
+// postTelegram messages every subscribed chat from a single job, pacing
+// sends to stay under Telegram's broadcast limits
+func (n *Notifier) postTelegram(reportID string, card Card) {
+	text := fmt.Sprintf("⚠️ %s\nSeverity: %s\n\n%s\n\nMap: %s\nDonate: %s",
+		card.Title, card.Severity, card.Text, card.MapURL, card.DonateURL)
+
+	err := n.queue.Enqueue(jobs.Job{
+		Name: "telegram-alerts-" + reportID,
+		Run: func(ctx context.Context) error {
+			rows, err := n.db.QueryContext(ctx, "SELECT chat_id FROM telegram_links WHERE alerts_enabled = TRUE")
+			if err != nil {
+				return err
+			}
+			var chatIDs []int64
+			for rows.Next() {
+				var chatID int64
+				if rows.Scan(&chatID) == nil {
+					chatIDs = append(chatIDs, chatID)
+				}
+			}
+			rows.Close()
+
+			for _, chatID := range chatIDs {
+				if err := n.telegram.SendMessage(ctx, chatID, text); err != nil {
+					log.Printf("Failed to send Telegram alert to chat %d: %v", chatID, err)
+				}
+				time.Sleep(40 * time.Millisecond)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue Telegram alerts for report %s: %v", reportID, err)
+	}
+}
+
+func (n *Notifier) send(ctx context.Context, provider Provider, webhookURL string, card Card) error {
+	payload, err := card.Payload(provider)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s webhook responded with %d", provider, resp.StatusCode)
+	}
+	return nil
+}
+
+func (n *Notifier) loadReport(reportID string) (*report, error) {
+	var rep report
+	err := n.db.QueryRow(
+		`SELECT BIN_TO_UUID(reporter_id), title, description, severity, latitude, longitude
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?)`,
+		reportID,
+	).Scan(&rep.reporterID, &rep.title, &rep.description, &rep.severity, &rep.latitude, &rep.longitude)
+	if err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// integrations returns the active integrations subscribed to alert whose
+// severity threshold the report meets
+func (n *Notifier) integrations(alert, severity string) []integration {
+	rows, err := n.db.Query(
+		`SELECT BIN_TO_UUID(id), provider, webhook_url, min_severity, currency, funding_milestones
+		FROM chat_integrations
+		WHERE active = TRUE AND JSON_CONTAINS(alerts, JSON_QUOTE(?))`,
+		alert,
+	)
+	if err != nil {
+		log.Printf("Failed to load chat integrations: %v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var matched []integration
+	for rows.Next() {
+		var in integration
+		var milestones []byte
+		if err := rows.Scan(&in.id, &in.provider, &in.url, &in.minSeverity, &in.currency, &milestones); err != nil {
+			continue
+		}
+		if severityRank[severity] < severityRank[in.minSeverity] {
+			continue
+		}
+		json.Unmarshal(milestones, &in.milestones)
+		matched = append(matched, in)
+	}
+	return matched
+}
+
+// claimMilestone records that a milestone was announced, returning false if
+// it already had been
+func (n *Notifier) claimMilestone(integrationID, reportID string, milestone float64) bool {
+	result, err := n.db.Exec(
+		`INSERT IGNORE INTO chat_alert_milestones (integration_id, disaster_report_id, milestone)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?)`,
+		integrationID, reportID, milestone,
+	)
+	if err != nil {
+		log.Printf("Failed to record funding milestone for report %s: %v", reportID, err)
+		return false
+	}
+	rows, _ := result.RowsAffected()
+	return rows == 1
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type Provider string
+
+const (
+	ProviderSlack   Provider = "slack"
+	ProviderDiscord Provider = "discord"
+)
+
+// Card is the provider-neutral content of a chat alert
+type Card struct {
+	Title     string
+	Text      string
+	Severity  string
+	MapURL    string
+	DonateURL string
+}
+
+var severityColors = map[string]int{
+	"low":      0x2eb67d,
+	"medium":   0xecb22e,
+	"high":     0xe8912d,
+	"critical": 0xe01e5a,
+}
+
+// ValidateWebhookURL only accepts incoming-webhook URLs on the provider's
+// own host so integrations cannot be pointed at arbitrary servers
+func ValidateWebhookURL(provider Provider, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" {
+		return errors.New("webhook URL must be an https URL")
+	}
+	switch provider {
+	case ProviderSlack:
+		if u.Host == "hooks.slack.com" && strings.HasPrefix(u.Path, "/services/") {
+			return nil
+		}
+		return errors.New("Slack webhook URLs must start with https://hooks.slack.com/services/")
+	case ProviderDiscord:
+		if (u.Host == "discord.com" || u.Host == "discordapp.com") && strings.HasPrefix(u.Path, "/api/webhooks/") {
+			return nil
+		}
+		return errors.New("Discord webhook URLs must start with https://discord.com/api/webhooks/")
+	}
+	return errors.New("provider must be slack or discord")
+}
+
+// Payload renders the card in the provider's incoming-webhook format
+func (c Card) Payload(provider Provider) ([]byte, error) {
+	color := severityColors[c.Severity]
+	switch provider {
+	case ProviderSlack:
+		fields := fmt.Sprintf("*Severity:* %s", strings.ToUpper(c.Severity))
+		if c.MapURL != "" {
+			fields += fmt.Sprintf("\n<%s|View location>", c.MapURL)
+		}
+		if c.DonateURL != "" {
+			fields += fmt.Sprintf("  •  <%s|Donate>", c.DonateURL)
+		}
+		return json.Marshal(map[string]interface{}{
+			"text": c.Title,
+			"attachments": []map[string]interface{}{{
+				"color": fmt.Sprintf("#%06x", color),
+				"blocks": []map[string]interface{}{
+					{"type": "header", "text": map[string]string{"type": "plain_text", "text": c.Title}},
+					{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": c.Text}},
+					{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": fields}},
+				},
+			}},
+		})
+	case ProviderDiscord:
+		fields := []map[string]interface{}{
+			{"name": "Severity", "value": strings.ToUpper(c.Severity), "inline": true},
+		}
+		if c.MapURL != "" {
+			fields = append(fields, map[string]interface{}{"name": "Location", "value": "[View map](" + c.MapURL + ")", "inline": true})
+		}
+		if c.DonateURL != "" {
+			fields = append(fields, map[string]interface{}{"name": "Help", "value": "[Donate](" + c.DonateURL + ")", "inline": true})
+		}
+		return json.Marshal(map[string]interface{}{
+			"embeds": []map[string]interface{}{{
+				"title":       c.Title,
+				"description": c.Text,
+				"url":         c.DonateURL,
+				"color":       color,
+				"fields":      fields,
+			}},
+		})
+	}
+	return nil, fmt.Errorf("unknown provider %q", provider)
+}
+
+// MapURL links to the coordinates on OpenStreetMap
+func MapURL(latitude, longitude float64) string {
+	return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%.5f&mlon=%.5f#map=13/%.5f/%.5f",
+		latitude, longitude, latitude, longitude)
+}
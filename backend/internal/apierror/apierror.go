@@ -0,0 +1,61 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// RequestIDHeader is set on every response by the request ID middleware and
+// echoed in error bodies so a report can be matched to the server logs
+const RequestIDHeader = "X-Request-ID"
+
+// Envelope is the body of every error response
+type Envelope struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// Error replies with the generic code for status; it is a drop-in
+// replacement for http.Error
+func Error(w http.ResponseWriter, message string, status int) {
+	Write(w, status, CodeForStatus(status), message, nil)
+}
+
+// Write replies with a specific catalog code and optional details
+func Write(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: h.Get(RequestIDHeader),
+	})
+}
+
+// CatalogHandler publishes the error code catalog
+func CatalogHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Catalog)
+	})
+}
+
+// NotFoundHandler and MethodNotAllowedHandler replace the router's plain-text
+// defaults
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, "Not found", http.StatusNotFound)
+	})
+}
+
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+}
@@ -0,0 +1,110 @@
+package apierror
+
+import "net/http"
+
+// Error codes are part of the API contract: clients branch on them, so an
+// existing code is never renamed or reused for a different condition
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeGone             = "gone"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+	CodeUpstreamFailed   = "upstream_failed"
+	CodeUnavailable      = "service_unavailable"
+
+	CodeMFARequired            = "mfa_required"
+	CodeInvalidMFACode         = "invalid_mfa_code"
+	CodeInvalidCredentials     = "invalid_credentials"
+	CodeLoginThrottled         = "login_throttled"
+	CodeAccountLocked          = "account_locked"
+	CodePasswordChangeRequired = "password_change_required"
+	CodeSessionRevoked         = "session_revoked"
+	CodeInvalidCSRFToken       = "invalid_csrf_token"
+	CodeConsentRequired        = "consent_required"
+	CodeAccountSuspended       = "account_suspended"
+	CodeAccountBanned          = "account_banned"
+	CodeInsufficientPermission = "insufficient_permission"
+	CodeInvalidAPIKey          = "invalid_api_key"
+	CodeQuotaExceeded          = "quota_exceeded"
+	CodeInvalidSignature       = "invalid_signature"
+)
+
+// CatalogEntry documents one code for the published catalog
+type CatalogEntry struct {
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// Catalog lists every code a client may receive
+var Catalog = []CatalogEntry{
+	{CodeBadRequest, http.StatusBadRequest, "The request is malformed or has an invalid parameter"},
+	{CodeValidationFailed, http.StatusBadRequest, "One or more fields are invalid; details lists each field and problem"},
+	{CodeUnauthorized, http.StatusUnauthorized, "Authentication is missing or has expired"},
+	{CodeForbidden, http.StatusForbidden, "The caller may not perform this action"},
+	{CodeNotFound, http.StatusNotFound, "The resource does not exist or is not visible to the caller"},
+	{CodeMethodNotAllowed, http.StatusMethodNotAllowed, "The path exists but not for this method"},
+	{CodeConflict, http.StatusConflict, "The request conflicts with the current state, e.g. a duplicate or a limit"},
+	{CodeGone, http.StatusGone, "The resource existed but can no longer be used"},
+	{CodePayloadTooLarge, http.StatusRequestEntityTooLarge, "The request body exceeds the size limit"},
+	{CodeRateLimited, http.StatusTooManyRequests, "Too many requests; retry after the Retry-After header"},
+	{CodeInternal, http.StatusInternalServerError, "An unexpected server error; quote requestId when reporting it"},
+	{CodeUpstreamFailed, http.StatusBadGateway, "An external service the request depends on failed"},
+	{CodeUnavailable, http.StatusServiceUnavailable, "The feature is not configured or temporarily busy"},
+
+	{CodeMFARequired, http.StatusUnauthorized, "The account has MFA enabled; repeat the login with mfaCode"},
+	{CodeInvalidMFACode, http.StatusUnauthorized, "The MFA code is wrong or expired"},
+	{CodeInvalidCredentials, http.StatusUnauthorized, "The email or password is wrong"},
+	{CodeLoginThrottled, http.StatusTooManyRequests, "Too many failed logins for this account or address"},
+	{CodeAccountLocked, http.StatusForbidden, "Too many failed logins locked the account for a while"},
+	{CodePasswordChangeRequired, http.StatusForbidden, "The password has expired; repeat the login with newPassword"},
+	{CodeSessionRevoked, http.StatusUnauthorized, "The session was signed out from another device"},
+	{CodeInvalidCSRFToken, http.StatusForbidden, "The X-CSRF-Token header is missing or does not match the CSRF-Token cookie"},
+	{CodeConsentRequired, http.StatusForbidden, "Updated policies must be accepted first; details.policies lists them"},
+	{CodeAccountSuspended, http.StatusForbidden, "The account is suspended; details has the reason and expiry"},
+	{CodeAccountBanned, http.StatusForbidden, "The account is banned; details has the reason"},
+	{CodeInsufficientPermission, http.StatusForbidden, "The caller's role lacks the permission this route requires"},
+	{CodeInvalidAPIKey, http.StatusUnauthorized, "The X-API-Key header is missing, unknown or revoked"},
+	{CodeQuotaExceeded, http.StatusTooManyRequests, "The API key's daily quota is used up; retry after the Retry-After header"},
+	{CodeInvalidSignature, http.StatusForbidden, "A provider callback failed signature or timestamp verification"},
+}
+
+// CodeForStatus returns the generic code used when a handler does not pick a
+// more specific one
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusBadGateway:
+		return CodeUpstreamFailed
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
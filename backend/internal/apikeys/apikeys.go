@@ -0,0 +1,118 @@
+// Package apikeys issues and resolves keys for programmatic clients. Keys
+// are shown once; only their SHA-256 hash is stored.
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"saferelief/internal/tokens"
+)
+
+// KeyPrefix marks SafeRelief keys so leaked ones are easy to spot in logs
+// and secret scanners
+const KeyPrefix = "sr_"
+
+const (
+	ScopeOpenData = "open-data"
+	// ScopeRead and ScopeWrite let a key act as its owner on the main API
+	// through an Authorization: Bearer header, for safe and unsafe methods
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+var knownScopes = map[string]bool{
+	ScopeOpenData: true,
+	ScopeRead:     true,
+	ScopeWrite:    true,
+}
+
+var ErrInvalidKey = errors.New("invalid API key")
+
+type Key struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	DailyQuota int        `json:"dailyQuota"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func ValidScope(scope string) bool {
+	return knownScopes[scope]
+}
+
+func (k Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate returns a new key and the display prefix and hash to store
+func Generate() (key, prefix, hash string, err error) {
+	token, _, err := tokens.Generate()
+	if err != nil {
+		return "", "", "", err
+	}
+	key = KeyPrefix + token
+	return key, key[:len(KeyPrefix)+8], tokens.Hash(key), nil
+}
+
+// Lookup resolves an active key owned by an active account
+func Lookup(ctx context.Context, db *sql.DB, key string) (Key, error) {
+	if !strings.HasPrefix(key, KeyPrefix) {
+		return Key{}, ErrInvalidKey
+	}
+
+	var k Key
+	var scopes []byte
+	err := db.QueryRowContext(ctx,
+		`SELECT BIN_TO_UUID(k.id), BIN_TO_UUID(k.user_id), k.name, k.prefix, k.scopes,
+			k.daily_quota, k.last_used_at, k.created_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = ? AND k.revoked_at IS NULL
+		AND u.status = 'active' AND u.deleted_at IS NULL`,
+		tokens.Hash(key),
+	).Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &k.DailyQuota, &k.LastUsedAt, &k.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	if err := json.Unmarshal(scopes, &k.Scopes); err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
+
+// RecordUsage counts a request against today's (UTC) quota and returns the
+// number of requests made with the key today, including this one
+func RecordUsage(ctx context.Context, db *sql.DB, keyID string) (int, error) {
+	day := time.Now().UTC().Format("2006-01-02")
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO api_key_usage (key_id, day, requests) VALUES (UUID_TO_BIN(?), ?, 1)
+		ON DUPLICATE KEY UPDATE requests = requests + 1`,
+		keyID, day,
+	); err != nil {
+		return 0, err
+	}
+	db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = NOW() WHERE id = UUID_TO_BIN(?)", keyID)
+
+	var requests int
+	err := db.QueryRowContext(ctx,
+		"SELECT requests FROM api_key_usage WHERE key_id = UUID_TO_BIN(?) AND day = ?",
+		keyID, day,
+	).Scan(&requests)
+	return requests, err
+}
@@ -0,0 +1,300 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type Severity string
+
+const (
+	SeverityLow      Severity = "LOW"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityHigh     Severity = "HIGH"
+	SeverityCritical Severity = "CRITICAL"
+)
+
+// Security event types recorded in the action column of audit_logs
+const (
+	EventLoginSuccess      = "LOGIN_SUCCESS"
+	EventLoginFailed       = "LOGIN_FAILED"
+	EventMagicLinkSent     = "MAGIC_LINK_SENT"
+	EventAccountLocked     = "ACCOUNT_LOCKED"
+	EventAccountUnlocked   = "ACCOUNT_UNLOCKED"
+	EventRateLimited       = "RATE_LIMITED"
+	EventAuditExport       = "AUDIT_EXPORT"
+	EventPolicyUpdated     = "AUDIT_POLICY_UPDATED"
+	EventRateLimitsUpdated = "RATE_LIMITS_UPDATED"
+
+	EventUserRegistered       = "USER_REGISTERED"
+	EventDataExportRequested  = "DATA_EXPORT_REQUESTED"
+	EventDataExportDownloaded = "DATA_EXPORT_DOWNLOADED"
+	EventAccountDeleted       = "ACCOUNT_DELETED"
+	EventAccountRestored      = "ACCOUNT_RESTORED"
+	EventAccountAnonymized    = "ACCOUNT_ANONYMIZED"
+	EventRoleChanged          = "ROLE_CHANGED"
+	EventPasswordChanged      = "PASSWORD_CHANGED"
+	EventRecoveryCodeUsed     = "MFA_RECOVERY_CODE_USED"
+	EventMFAEnabled           = "MFA_ENABLED"
+	EventDeviceRevoked        = "DEVICE_REVOKED"
+	EventDeviceTrusted        = "DEVICE_TRUSTED"
+	EventDeviceUntrusted      = "DEVICE_UNTRUSTED"
+	EventSessionRevoked       = "SESSION_REVOKED"
+	EventAPIKeyCreated        = "API_KEY_CREATED"
+	EventAPIKeyRevoked        = "API_KEY_REVOKED"
+	EventUserImportStarted    = "USER_IMPORT_STARTED"
+	EventUserSuspended        = "USER_SUSPENDED"
+	EventUserBanned           = "USER_BANNED"
+	EventSuspensionLifted     = "SUSPENSION_LIFTED"
+	EventSSOUserProvisioned   = "SSO_USER_PROVISIONED"
+	EventSSOIdentityLinked    = "SSO_IDENTITY_LINKED"
+
+	EventEmailChangeRequested = "EMAIL_CHANGE_REQUESTED"
+	EventEmailChanged         = "EMAIL_CHANGED"
+	EventEmailChangeCancelled = "EMAIL_CHANGE_CANCELLED"
+	EventEmailSuppressed      = "EMAIL_SUPPRESSED"
+	EventSuppressionRemoved   = "EMAIL_SUPPRESSION_REMOVED"
+
+	EventConsentRecorded        = "CONSENT_RECORDED"
+	EventPolicyVersionPublished = "POLICY_VERSION_PUBLISHED"
+
+	EventVerifierApplicationReviewed = "VERIFIER_APPLICATION_REVIEWED"
+	EventOrganizationReviewed        = "ORGANIZATION_REVIEWED"
+	EventOrganizationMemberChanged   = "ORGANIZATION_MEMBER_CHANGED"
+	EventActedOnBehalf               = "ACTED_ON_BEHALF"
+	EventReportCommentRemoved        = "REPORT_COMMENT_REMOVED"
+	EventReportDeleted               = "REPORT_DELETED"
+	EventReportRestored              = "REPORT_RESTORED"
+	EventReportsExported             = "REPORTS_EXPORTED"
+	EventReportStatusChanged         = "REPORT_STATUS_CHANGED"
+	EventReportAutoClosed            = "REPORT_AUTO_CLOSED"
+	EventReportIngested              = "REPORT_INGESTED"
+	EventReportVerificationVote      = "REPORT_VERIFICATION_VOTE"
+	EventReportUpdated               = "REPORT_UPDATED"
+	EventReportsMerged               = "REPORTS_MERGED"
+	EventReportGoalSet               = "REPORT_GOAL_SET"
+	EventReportFlagged               = "REPORT_FLAGGED"
+	EventReportHidden                = "REPORT_HIDDEN"
+	EventReportFlagsResolved         = "REPORT_FLAGS_RESOLVED"
+	EventChatIntegrationChanged      = "CHAT_INTEGRATION_CHANGED"
+	EventTemplateUpdated             = "NOTIFICATION_TEMPLATE_UPDATED"
+	EventDeliveryRetried             = "NOTIFICATION_DELIVERY_RETRIED"
+	EventInboundWebhookReplayed      = "INBOUND_WEBHOOK_REPLAYED"
+	EventInternalServiceCall         = "INTERNAL_SERVICE_CALL"
+	EventSSOConfigChanged            = "SSO_CONFIG_CHANGED"
+	EventDonationLimitChanged        = "DONATION_LIMIT_CHANGED"
+	EventDonationPaymentUpdated      = "DONATION_PAYMENT_UPDATED"
+	EventDisbursementRecorded        = "DISBURSEMENT_RECORDED"
+	EventEscrowReleaseApproved       = "ESCROW_RELEASE_APPROVED"
+	EventEscrowReleaseCancelled      = "ESCROW_RELEASE_CANCELLED"
+	EventEscrowReleased              = "ESCROW_RELEASED"
+
+	EventBroadcastSent      = "EMERGENCY_BROADCAST_SENT"
+	EventBroadcastCompleted = "EMERGENCY_BROADCAST_COMPLETED"
+)
+
+type Event struct {
+	Type       string                 `json:"type"`
+	Severity   Severity               `json:"severity"`
+	UserID     string                 `json:"userId,omitempty"`
+	EntityType string                 `json:"entityType"`
+	EntityID   string                 `json:"entityId,omitempty"`
+	IPAddress  string                 `json:"ipAddress"`
+	UserAgent  string                 `json:"userAgent,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+type Logger struct {
+	db       *sql.DB
+	spool    *Spool
+	mirror   *WORMSink
+	broker   *Broker
+	policy   Policy
+	policyMu sync.RWMutex
+}
+
+// NewLogger creates a logger that falls back to a file spool at spoolPath
+// whenever the database write fails
+func NewLogger(db *sql.DB, spoolPath string) *Logger {
+	return &Logger{
+		db:     db,
+		spool:  NewSpool(spoolPath),
+		broker: NewBroker(),
+		policy: DefaultPolicy(),
+	}
+}
+
+// Subscribe returns a channel receiving every event recorded from now on;
+// callers must release it with Unsubscribe
+func (l *Logger) Subscribe() chan Event {
+	return l.broker.Subscribe()
+}
+
+func (l *Logger) Unsubscribe(ch chan Event) {
+	l.broker.Unsubscribe(ch)
+}
+
+// SetMirror enables write-once mirroring of every recorded event
+func (l *Logger) SetMirror(mirror *WORMSink) {
+	l.mirror = mirror
+}
+
+func (l *Logger) Policy() Policy {
+	l.policyMu.RLock()
+	defer l.policyMu.RUnlock()
+	return l.policy
+}
+
+// SetPolicy replaces the verbosity policy; it takes effect for the next event
+func (l *Logger) SetPolicy(policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	l.policyMu.Lock()
+	l.policy = policy
+	l.policyMu.Unlock()
+	return nil
+}
+
+// Log records an event, filling in the client address and user agent from the request
+func (l *Logger) Log(r *http.Request, event Event) {
+	if r != nil {
+		if event.IPAddress == "" {
+			event.IPAddress = ClientIP(r)
+		}
+		if event.UserAgent == "" {
+			event.UserAgent = r.UserAgent()
+		}
+	}
+	if event.Severity == "" {
+		event.Severity = SeverityLow
+	}
+	if event.EntityType == "" {
+		event.EntityType = "user"
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	// Fit the audit_logs columns so an oversized header cannot make the
+	// event unwritable
+	event.IPAddress = truncate(event.IPAddress, maxIPAddressLen)
+	event.UserAgent = truncate(event.UserAgent, maxUserAgentLen)
+
+	event, keep := l.Policy().apply(event)
+	if !keep {
+		return
+	}
+
+	if l.mirror != nil {
+		if err := l.mirror.Write(event); err != nil {
+			log.Printf("Failed to mirror audit event %s to WORM storage: %v", event.Type, err)
+		}
+	}
+	l.broker.Publish(event)
+
+	if err := l.logToDatabase(event); err != nil {
+		log.Printf("Failed to write audit event %s, spooling to disk: %v", event.Type, err)
+		if err := l.spool.Append(event); err != nil {
+			log.Printf("CRITICAL: audit event %s lost, spool write failed: %v", event.Type, err)
+		}
+	}
+}
+
+// StartReplay periodically flushes spooled events back into the database
+// once it becomes reachable again
+func (l *Logger) StartReplay(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := l.db.Ping(); err != nil {
+				continue
+			}
+			replayed, dead, err := l.spool.Replay(l.logToDatabase, permanentWriteError)
+			if replayed > 0 {
+				log.Printf("Replayed %d spooled audit events", replayed)
+			}
+			if dead > 0 {
+				log.Printf("CRITICAL: %d spooled audit events were rejected by the database and moved to %s",
+					dead, l.spool.DeadLetterPath())
+			}
+			if err != nil {
+				log.Printf("Audit spool replay stopped early: %v", err)
+			}
+		}
+	}()
+}
+
+func (l *Logger) logToDatabase(event Event) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.db.Exec(
+		`INSERT INTO audit_logs (
+			id, user_id, action, severity, entity_type, entity_id,
+			ip_address, user_agent, details, created_at
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(NULLIF(?, '')), ?, ?, ?, UUID_TO_BIN(NULLIF(?, '')),
+			?, ?, ?, ?
+		)`,
+		event.UserID, event.Type, event.Severity, event.EntityType, event.EntityID,
+		event.IPAddress, event.UserAgent, details, event.Timestamp,
+	)
+	return err
+}
+
+// Column sizes of audit_logs
+const (
+	maxIPAddressLen = 45
+	maxUserAgentLen = 255
+)
+
+// permanentSQLErrors are MySQL errors about an event's own values, which
+// retrying cannot fix
+var permanentSQLErrors = map[uint16]bool{
+	1048: true, // column cannot be null
+	1062: true, // duplicate entry
+	1264: true, // value out of range
+	1265: true, // data truncated
+	1292: true, // incorrect value
+	1366: true, // incorrect string value
+	1406: true, // data too long for column
+	1411: true, // incorrect value for function, such as UUID_TO_BIN
+	1452: true, // foreign key constraint fails
+}
+
+// permanentWriteError reports whether an event failed because of its own
+// data rather than the database being unavailable
+func permanentWriteError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && permanentSQLErrors[mysqlErr.Number]
+}
+
+// truncate cuts s to at most n characters, as VARCHAR columns count them
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// ClientIP returns the request's remote address without the port so events
+// from the same client can be grouped together
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+)
+
+const (
+	ModeAlways = "always"
+	ModeSample = "sample"
+	ModeSkip   = "skip"
+)
+
+type Rule struct {
+	Mode string `json:"mode"`
+	// SamplePercent is the share of events kept when Mode is "sample"
+	SamplePercent float64 `json:"samplePercent,omitempty"`
+	// Severity overrides the severity set by the caller
+	Severity Severity `json:"severity,omitempty"`
+}
+
+type Policy struct {
+	Default Rule            `json:"default"`
+	Events  map[string]Rule `json:"events"`
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		Default: Rule{Mode: ModeAlways},
+		Events:  map[string]Rule{},
+	}
+}
+
+// ParsePolicy decodes and validates a JSON policy document
+func ParsePolicy(data []byte) (Policy, error) {
+	policy := DefaultPolicy()
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, err
+	}
+	if policy.Events == nil {
+		policy.Events = map[string]Rule{}
+	}
+	return policy, policy.Validate()
+}
+
+func (p Policy) Validate() error {
+	if err := p.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for eventType, rule := range p.Events {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("%s: %w", eventType, err)
+		}
+	}
+	return nil
+}
+
+func (r Rule) validate() error {
+	switch r.Mode {
+	case ModeAlways, ModeSkip:
+	case ModeSample:
+		if r.SamplePercent < 0 || r.SamplePercent > 100 {
+			return fmt.Errorf("samplePercent must be between 0 and 100")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q", r.Mode)
+	}
+
+	switch r.Severity {
+	case "", SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+	default:
+		return fmt.Errorf("unknown severity %q", r.Severity)
+	}
+	return nil
+}
+
+// apply returns the event with any severity override and whether it should be kept.
+// HIGH and CRITICAL events are always kept regardless of the configured mode.
+func (p Policy) apply(event Event) (Event, bool) {
+	rule, ok := p.Events[event.Type]
+	if !ok {
+		rule = p.Default
+	}
+
+	if rule.Severity != "" {
+		event.Severity = rule.Severity
+	}
+	if event.Severity == SeverityHigh || event.Severity == SeverityCritical {
+		return event, true
+	}
+
+	switch rule.Mode {
+	case ModeSkip:
+		return event, false
+	case ModeSample:
+		return event, rand.Float64()*100 < rule.SamplePercent
+	}
+	return event, true
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Spool is an append-only JSONL file holding events that could not be
+// written to the database, kept until they can be replayed. Events the
+// database rejects for good are moved to a dead-letter file beside it.
+type Spool struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewSpool(path string) *Spool {
+	return &Spool{path: path}
+}
+
+// DeadLetterPath is where events that can never be written are kept for
+// someone to inspect
+func (s *Spool) DeadLetterPath() string {
+	return s.path + ".dead"
+}
+
+func (s *Spool) Append(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Replay writes spooled events in order and removes the ones that succeeded.
+// An event whose failure is permanent is moved to the dead-letter file so it
+// cannot hold back the events behind it; any other failure stops the replay
+// so ordering is preserved for the next attempt. It returns how many events
+// were written and how many dead-lettered.
+func (s *Spool) Replay(write func(Event) error, permanent func(error) bool) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	// done counts the leading lines that are handled and can leave the spool
+	done, replayed, dead := 0, 0, 0
+	var writeErr error
+	for _, line := range lines {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			// A torn write from a crash can't be recovered, skip it
+			done++
+			continue
+		}
+		if err := write(event); err != nil {
+			if !permanent(err) {
+				writeErr = err
+				break
+			}
+			if err := s.deadLetter(line); err != nil {
+				writeErr = err
+				break
+			}
+			dead++
+		} else {
+			replayed++
+		}
+		done++
+	}
+
+	if done == len(lines) {
+		return replayed, dead, os.Remove(s.path)
+	}
+
+	// Rewrite the remaining events atomically
+	tmp := s.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return replayed, dead, err
+	}
+	for _, line := range lines[done:] {
+		out.Write(append(line, '\n'))
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return replayed, dead, err
+	}
+	out.Close()
+	if err := os.Rename(tmp, s.path); err != nil {
+		return replayed, dead, err
+	}
+
+	return replayed, dead, writeErr
+}
+
+// deadLetter appends a spooled line to the dead-letter file
+func (s *Spool) deadLetter(line []byte) error {
+	f, err := os.OpenFile(s.DeadLetterPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
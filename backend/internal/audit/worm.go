@@ -0,0 +1,242 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// WORMRecord is one line of the write-once mirror. Each record carries the
+// hash of the previous one, so editing or deleting any line breaks the chain.
+type WORMRecord struct {
+	Sequence int64  `json:"seq"`
+	Event    Event  `json:"event"`
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash"`
+}
+
+// WORMDigest is a signed checkpoint of the chain head, written periodically
+// to a separate file that can be shipped to an external notary
+type WORMDigest struct {
+	Sequence  int64     `json:"seq"`
+	HeadHash  string    `json:"headHash"`
+	CreatedAt time.Time `json:"createdAt"`
+	Signature string    `json:"signature"`
+}
+
+// WORMSink mirrors audit events to an append-only file. For full immutability
+// the file should live on storage that enforces it (chattr +a, or a volume
+// synced to S3 with Object Lock enabled).
+type WORMSink struct {
+	path       string
+	digestPath string
+	digestKey  []byte
+	file       *os.File
+	sequence   int64
+	headHash   string
+	lastDigest int64
+	mu         sync.Mutex
+}
+
+func NewWORMSink(path string, digestKey []byte) (*WORMSink, error) {
+	sink := &WORMSink{
+		path:       path,
+		digestPath: path + ".digests",
+		digestKey:  digestKey,
+	}
+
+	// Resume the chain from the last record already on disk
+	last, err := lastWORMRecord(path)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		sink.sequence = last.Sequence
+		sink.headHash = last.Hash
+		sink.lastDigest = last.Sequence
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0400)
+	if err != nil {
+		return nil, err
+	}
+	sink.file = file
+
+	return sink, nil
+}
+
+func (s *WORMSink) Write(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := WORMRecord{
+		Sequence: s.sequence + 1,
+		Event:    event,
+		PrevHash: s.headHash,
+	}
+	hash, err := record.computeHash()
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+
+	s.sequence = record.Sequence
+	s.headHash = record.Hash
+	return nil
+}
+
+// StartDigests writes a signed digest of the chain head every interval
+// whenever new records have been appended since the last one
+func (s *WORMSink) StartDigests(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.writeDigest(); err != nil {
+				log.Printf("Failed to write audit WORM digest: %v", err)
+			}
+		}
+	}()
+}
+
+func (s *WORMSink) writeDigest() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sequence == s.lastDigest {
+		return nil
+	}
+
+	digest := WORMDigest{
+		Sequence:  s.sequence,
+		HeadHash:  s.headHash,
+		CreatedAt: time.Now().UTC(),
+	}
+	digest.Signature = signDigest(s.digestKey, digest)
+
+	line, err := json.Marshal(digest)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.digestPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0400)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	s.lastDigest = s.sequence
+	return nil
+}
+
+// VerifyWORMChain checks every record's hash linkage and returns the number of
+// records verified, or an error naming the first broken sequence number
+func VerifyWORMChain(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var prevHash string
+	var count int64
+	for scanner.Scan() {
+		var record WORMRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("record %d: %w", count+1, err)
+		}
+		if record.Sequence != count+1 {
+			return count, fmt.Errorf("record %d: sequence gap, found %d", count+1, record.Sequence)
+		}
+		if record.PrevHash != prevHash {
+			return count, fmt.Errorf("record %d: previous hash mismatch", record.Sequence)
+		}
+		hash, err := record.computeHash()
+		if err != nil {
+			return count, err
+		}
+		if hash != record.Hash {
+			return count, fmt.Errorf("record %d: content hash mismatch", record.Sequence)
+		}
+		prevHash = record.Hash
+		count++
+	}
+
+	return count, scanner.Err()
+}
+
+// VerifyWORMDigest checks a digest signature against the digest key
+func VerifyWORMDigest(key []byte, digest WORMDigest) bool {
+	return hmac.Equal([]byte(digest.Signature), []byte(signDigest(key, digest)))
+}
+
+func (r WORMRecord) computeHash() (string, error) {
+	payload, err := json.Marshal(struct {
+		Sequence int64  `json:"seq"`
+		Event    Event  `json:"event"`
+		PrevHash string `json:"prevHash"`
+	}{r.Sequence, r.Event, r.PrevHash})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func signDigest(key []byte, digest WORMDigest) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d|%s|%s", digest.Sequence, digest.HeadHash, digest.CreatedAt.Format(time.RFC3339Nano))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func lastWORMRecord(path string) (*WORMRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		last = append(last[:0], scanner.Bytes()...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(last) == 0 {
+		return nil, nil
+	}
+
+	var record WORMRecord
+	if err := json.Unmarshal(last, &record); err != nil {
+		return nil, fmt.Errorf("corrupt final WORM record: %w", err)
+	}
+	return &record, nil
+}
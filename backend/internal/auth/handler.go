@@ -4,10 +4,18 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"sync"
 	"time"
 
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/consent"
+	"saferelief/internal/ratelimit"
+	"saferelief/internal/sessions"
+
 	"github.com/go-sql-driver/mysql"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/pquerna/otp/totp"
@@ -18,6 +26,11 @@ type Credentials struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	MFACode  string `json:"mfaCode,omitempty"`
+	// RememberDevice skips the MFA prompt on this device for a while after a
+	// login verified with an authenticator code
+	RememberDevice bool `json:"rememberDevice,omitempty"`
+	// NewPassword completes a login that was refused because the password expired
+	NewPassword string `json:"newPassword,omitempty"`
 }
 
 type User struct {
@@ -29,23 +42,30 @@ type User struct {
 	MFAEnabled     bool       `json:"mfaEnabled"`
 	FailedAttempts int        `json:"-"`
 	LockedUntil    *time.Time `json:"-"`
+	Role           string     `json:"role"`
+	PasswordChange time.Time  `json:"-"`
+	MustChange     bool       `json:"-"`
 	CreatedAt      time.Time  `json:"createdAt"`
 	UpdatedAt      time.Time  `json:"updatedAt"`
 }
 
 type AuthHandler struct {
-	jwtSecret     []byte
-	refreshSecret []byte
-	db            *sql.DB
-	rateLimiter   *RateLimiter
+	jwtSecret      []byte
+	refreshSecret  []byte
+	db             *sql.DB
+	rateLimiter    *RateLimiter
+	auditLogger    *audit.Logger
+	passwordPolicy accounts.PasswordPolicy
 }
 
-func NewAuthHandler(jwtSecret, refreshSecret []byte, db *sql.DB) *AuthHandler {
+func NewAuthHandler(jwtSecret, refreshSecret []byte, db *sql.DB, auditLogger *audit.Logger) *AuthHandler {
 	return &AuthHandler{
-		jwtSecret:     jwtSecret,
-		refreshSecret: refreshSecret,
-		db:            db,
-		rateLimiter:   NewRateLimiter(100, time.Hour), // 100 requests per hour
+		jwtSecret:      jwtSecret,
+		refreshSecret:  refreshSecret,
+		db:             db,
+		rateLimiter:    NewRateLimiter(100, time.Hour), // 100 requests per hour
+		auditLogger:    auditLogger,
+		passwordPolicy: accounts.PasswordPolicyFromEnv(),
 	}
 }
 
@@ -53,131 +73,121 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Rate limiting check
 	ip := r.RemoteAddr
 	if !h.rateLimiter.Allow(ip) {
-		http.Error(w, "Too many login attempts", http.StatusTooManyRequests)
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventRateLimited,
+			Severity:   audit.SeverityMedium,
+			EntityType: "endpoint",
+			Details:    map[string]interface{}{"endpoint": "login"},
+		})
+		apierror.Write(w, http.StatusTooManyRequests, apierror.CodeLoginThrottled, "Too many login attempts", nil)
 		return
 	}
 
 	var creds Credentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	// Get user from database
 	var user User
 	err := h.db.QueryRow(
-		"SELECT id, username, email, password_hash, mfa_secret, mfa_enabled, failed_attempts, locked_until FROM users WHERE email = ?",
+		`SELECT BIN_TO_UUID(id), username, email, password_hash, mfa_secret, mfa_enabled, failed_attempts, locked_until,
+		role, last_password_change, require_password_change
+		FROM users WHERE email = ? AND deleted_at IS NULL`,
 		creds.Email,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.MFASecret, &user.MFAEnabled, &user.FailedAttempts, &user.LockedUntil)
+	).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.MFASecret, &user.MFAEnabled, &user.FailedAttempts, &user.LockedUntil,
+		&user.Role, &user.PasswordChange, &user.MustChange)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
+			h.auditLogger.Log(r, audit.Event{
+				Type:     audit.EventLoginFailed,
+				Severity: audit.SeverityMedium,
+				Details:  map[string]interface{}{"reason": "unknown_email"},
+			})
 			// Use same error message as password mismatch for security
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials", nil)
 			return
 		}
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Check if account is locked
-	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
-		http.Error(w, "Account is temporarily locked", http.StatusForbidden)
+	if !h.checkLocked(w, r, &user, "") {
 		return
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)); err != nil {
-		// Increment failed attempts
-		newFailedAttempts := user.FailedAttempts + 1
-		var lockedUntil *time.Time
-
-		if newFailedAttempts >= 5 {
-			t := time.Now().Add(15 * time.Minute)
-			lockedUntil = &t
-		}
-
-		_, err := h.db.Exec(
-			"UPDATE users SET failed_attempts = ?, locked_until = ? WHERE id = ?",
-			newFailedAttempts, lockedUntil, user.ID,
-		)
-		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if err := h.recordFailure(r, &user, "", "invalid_password"); err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials", nil)
+		return
+	}
 
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	outcome, ok := h.completeSignIn(w, r, &user, signInAttempt{
+		Password:       creds.Password,
+		MFACode:        creds.MFACode,
+		RememberDevice: creds.RememberDevice,
+		NewPassword:    creds.NewPassword,
+	})
+	if !ok {
 		return
 	}
 
-	// Reset failed attempts on successful password verification
-	_, err = h.db.Exec(
-		"UPDATE users SET failed_attempts = 0, locked_until = NULL WHERE id = ?",
-		user.ID,
-	)
+	deviceID, err := h.issueSession(w, r, user.ID, user.Role)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Error(w, "Error starting session", http.StatusInternalServerError)
 		return
 	}
 
-	// Check MFA if enabled
-	if user.MFAEnabled {
-		if creds.MFACode == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{
-				"message": "MFA required",
-			})
-			return
-		}
-
-		if !totp.Validate(creds.MFACode, user.MFASecret) {
-			http.Error(w, "Invalid MFA code", http.StatusUnauthorized)
-			return
-		}
+	var loginDetails map[string]interface{}
+	if outcome.TrustedDevice {
+		loginDetails = map[string]interface{}{"mfa": "trusted_device"}
 	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventLoginSuccess,
+		UserID:   user.ID,
+		EntityID: user.ID,
+		Details:  loginDetails,
+	})
 
-	// Generate tokens
-	accessToken, err := h.generateAccessToken(user.ID)
-	if err != nil {
-		http.Error(w, "Error generating access token", http.StatusInternalServerError)
-		return
+	var deviceTrustedUntil *time.Time
+	if outcome.RememberDevice {
+		deviceTrustedUntil, err = h.trustDevice(r, user.ID, deviceID)
+		if err != nil {
+			log.Printf("Failed to trust device for user %s: %v", user.ID, err)
+		}
 	}
 
-	refreshToken, err := h.generateRefreshToken(user.ID)
+	// Let the client prompt for re-consent right away after a policy bump
+	outstanding, err := consent.Outstanding(h.db, user.ID)
 	if err != nil {
-		http.Error(w, "Error generating refresh token", http.StatusInternalServerError)
-		return
+		outstanding = []consent.PolicyVersion{}
 	}
 
-	// Set tokens in secure HTTP-only cookies
-	http.SetCookie(w, &http.Cookie{
-		Name:     "access_token",
-		Value:    accessToken,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-		Path:     "/",
-		MaxAge:   900, // 15 minutes
-	})
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-		Path:     "/",
-		MaxAge:   604800, // 7 days
-	})
-
 	// Return user data (excluding sensitive information)
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"user": map[string]interface{}{
 			"id":       user.ID,
 			"username": user.Username,
 			"email":    user.Email,
 		},
-	})
+		"consentRequired":     len(outstanding) > 0,
+		"outstandingPolicies": outstanding,
+		"passwordExpiresAt":   h.passwordPolicy.ExpiresAt(user.Role, user.PasswordChange),
+	}
+	if deviceTrustedUntil != nil {
+		response["deviceTrustedUntil"] = deviceTrustedUntil
+	}
+	// Signed in with a recovery code: prompt the user to set up MFA again
+	if outcome.RecoveryCodesRemaining != nil {
+		response["recoveryCodesRemaining"] = *outcome.RecoveryCodesRemaining
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -188,14 +198,14 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	// Generate password hash
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		apierror.Error(w, "Error hashing password", http.StatusInternalServerError)
 		return
 	}
 
@@ -205,25 +215,33 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		AccountName: user.Email,
 	})
 	if err != nil {
-		http.Error(w, "Error generating MFA secret", http.StatusInternalServerError)
+		apierror.Error(w, "Error generating MFA secret", http.StatusInternalServerError)
 		return
 	}
 	// Insert user into database
-	_, err = h.db.Exec(
-		`INSERT INTO users (id, username, email, password_hash, mfa_secret, created_at, updated_at)
-		VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, ?, NOW(), NOW())`,
+	var userID string
+	err = h.db.QueryRow(
+		`INSERT INTO users (id, username, email, password_hash, mfa_secret, last_password_change, created_at, updated_at)
+		VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, ?, NOW(), NOW(), NOW())
+		RETURNING BIN_TO_UUID(id)`,
 		user.Username, user.Email, hashedPassword, secret.Secret(),
-	)
+	).Scan(&userID)
 	if err != nil {
 		// Check for duplicate email
 		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
-			http.Error(w, "Email already registered", http.StatusConflict)
+			apierror.Error(w, "Email already registered", http.StatusConflict)
 			return
 		}
-		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		apierror.Error(w, "Error creating user", http.StatusInternalServerError)
 		return
 	}
 
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventUserRegistered,
+		UserID:   userID,
+		EntityID: userID,
+	})
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "User registered successfully",
@@ -231,6 +249,15 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	// End the server-side session so the tokens stop working everywhere
+	if cookie, err := r.Cookie("refresh_token"); err == nil {
+		if claims, err := h.parseRefreshToken(cookie.Value); err == nil {
+			if sessionID, ok := claims["sid"].(string); ok {
+				sessions.Revoke(h.db, sessionID)
+			}
+		}
+	}
+
 	// Clear cookies
 	http.SetCookie(w, &http.Cookie{
 		Name:     "access_token",
@@ -258,24 +285,70 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *AuthHandler) generateAccessToken(userID string) (string, error) {
+func (h *AuthHandler) generateAccessToken(userID, sessionID, role string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(15 * time.Minute).Unix(),
+		"sub":  userID,
+		"sid":  sessionID,
+		"role": role,
+		"tier": h.rateLimitTier(userID, role),
+		"exp":  time.Now().Add(accessTokenTTL).Unix(),
 	})
 
 	return token.SignedString(h.jwtSecret)
 }
 
-func (h *AuthHandler) generateRefreshToken(userID string) (string, error) {
+// rateLimitTier raises members of a verified organization to the NGO tier.
+// The tier rides in the access token, so a change applies on next refresh.
+func (h *AuthHandler) rateLimitTier(userID, role string) ratelimit.Tier {
+	tier := ratelimit.TierForRole(role)
+	if tier != ratelimit.TierUser {
+		return tier
+	}
+	var verified bool
+	err := h.db.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM organizations o
+			LEFT JOIN organization_members m ON m.organization_id = o.id AND m.user_id = UUID_TO_BIN(?)
+			WHERE o.verification_status = 'verified'
+			AND (o.owner_id = UUID_TO_BIN(?) OR m.user_id IS NOT NULL)
+		)`,
+		userID, userID,
+	).Scan(&verified)
+	if err == nil && verified {
+		return ratelimit.TierNGO
+	}
+	return tier
+}
+
+func (h *AuthHandler) generateRefreshToken(userID, sessionID string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"sub": userID,
-		"exp": time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"sid": sessionID,
+		"exp": time.Now().Add(refreshTokenTTL).Unix(),
+		// Unique per issue so a rotated token never equals its predecessor
+		"jti": time.Now().UnixNano(),
 	})
 
 	return token.SignedString(h.refreshSecret)
 }
 
+func (h *AuthHandler) parseRefreshToken(value string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(value, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return h.refreshSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	return claims, nil
+}
+
 type RateLimiter struct {
 	requests map[string]*requestCount
 	limit    int
@@ -323,68 +396,72 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	// Get refresh token from cookie
 	cookie, err := r.Cookie("refresh_token")
 	if err != nil {
-		http.Error(w, "Refresh token not found", http.StatusUnauthorized)
+		apierror.Error(w, "Refresh token not found", http.StatusUnauthorized)
 		return
 	}
 
 	// Parse and validate refresh token
-	token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return h.refreshSecret, nil
-	})
-
-	if err != nil || !token.Valid {
-		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+	claims, err := h.parseRefreshToken(cookie.Value)
+	if err != nil {
+		apierror.Error(w, "Invalid refresh token", http.StatusUnauthorized)
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	userID, ok := claims["sub"].(string)
 	if !ok {
-		http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+		apierror.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
 		return
 	}
-
-	userID, ok := claims["sub"].(string)
+	sessionID, ok := claims["sid"].(string)
 	if !ok {
-		http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+		apierror.Error(w, "Invalid refresh token", http.StatusUnauthorized)
 		return
 	}
 
 	// Verify user still exists and is not locked
 	var user User
 	err = h.db.QueryRow(`
-		SELECT id, username, email, mfa_enabled, failed_attempts, locked_until 
-		FROM users WHERE id = ?
-	`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.MFAEnabled, &user.FailedAttempts, &user.LockedUntil)
+		SELECT BIN_TO_UUID(id), username, email, mfa_enabled, failed_attempts, locked_until, role
+		FROM users WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL
+	`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.MFAEnabled, &user.FailedAttempts, &user.LockedUntil, &user.Role)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "User not found", http.StatusUnauthorized)
+			apierror.Error(w, "User not found", http.StatusUnauthorized)
 			return
 		}
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
 	// Check if account is locked
 	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
-		http.Error(w, "Account is temporarily locked", http.StatusUnauthorized)
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeAccountLocked, "Account is temporarily locked", nil)
 		return
 	}
 
 	// Generate new access token
-	accessToken, err := h.generateAccessToken(user.ID)
+	accessToken, err := h.generateAccessToken(user.ID, sessionID, user.Role)
 	if err != nil {
-		http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+		apierror.Error(w, "Failed to generate access token", http.StatusInternalServerError)
 		return
 	}
 
 	// Generate new refresh token
-	newRefreshToken, err := h.generateRefreshToken(user.ID)
+	newRefreshToken, err := h.generateRefreshToken(user.ID, sessionID)
+	if err != nil {
+		apierror.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	// Rotation fails once the session was revoked or the old token was reused
+	err = sessions.Rotate(h.db, sessionID, cookie.Value, newRefreshToken, audit.ClientIP(r), time.Now().Add(refreshTokenTTL))
+	if err == sessions.ErrRevoked {
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeSessionRevoked, "Session has been revoked", nil)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
@@ -396,7 +473,7 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteStrictMode,
-		MaxAge:   7 * 24 * 60 * 60, // 7 days
+		MaxAge:   int(refreshTokenTTL.Seconds()),
 	})
 
 	// Return new access token
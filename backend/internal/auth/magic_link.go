@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/notify"
+	"saferelief/internal/tokens"
+	"saferelief/internal/validation"
+)
+
+const (
+	magicLinkTTL = 15 * time.Minute
+	// magicLinksPerHour caps how many links one account can be sent, so the
+	// endpoint cannot be used to flood someone's inbox
+	magicLinksPerHour = 5
+)
+
+// MagicLinkHandler signs users in with a single-use link sent to their email
+// address, for people who cannot manage a password such as field volunteers
+// on shared devices
+type MagicLinkHandler struct {
+	auth        *AuthHandler
+	db          *sql.DB
+	mailer      *notify.Mailer
+	auditLogger *audit.Logger
+	frontendURL string
+}
+
+func NewMagicLinkHandler(authHandler *AuthHandler, db *sql.DB, mailer *notify.Mailer, auditLogger *audit.Logger) *MagicLinkHandler {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	return &MagicLinkHandler{
+		auth:        authHandler,
+		db:          db,
+		mailer:      mailer,
+		auditLogger: auditLogger,
+		frontendURL: strings.TrimSuffix(frontendURL, "/"),
+	}
+}
+
+// Request emails a login link. The response is the same whether or not the
+// address has an account, so it cannot be used to find registered emails.
+func (h *MagicLinkHandler) Request(w http.ResponseWriter, r *http.Request) {
+	if !h.auth.rateLimiter.Allow(r.RemoteAddr) {
+		apierror.Write(w, http.StatusTooManyRequests, apierror.CodeLoginThrottled, "Too many login attempts", nil)
+		return
+	}
+
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	request.Email = strings.TrimSpace(request.Email)
+
+	v := validation.New()
+	if v.Required("email", request.Email) {
+		v.Email("email", request.Email)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	if err := h.send(r, request.Email); err != nil {
+		log.Printf("Failed to send magic link: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If the address belongs to an account, a sign-in link is on its way",
+	})
+}
+
+func (h *MagicLinkHandler) send(r *http.Request, email string) error {
+	var userID string
+	err := h.db.QueryRow(
+		"SELECT BIN_TO_UUID(id) FROM users WHERE email = ? AND deleted_at IS NULL",
+		email,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var recent int
+	err = h.db.QueryRow(
+		"SELECT COUNT(*) FROM magic_links WHERE user_id = UUID_TO_BIN(?) AND created_at > ?",
+		userID, time.Now().Add(-time.Hour),
+	).Scan(&recent)
+	if err != nil {
+		return err
+	}
+	if recent >= magicLinksPerHour {
+		return nil
+	}
+
+	token, hash, err := tokens.Generate()
+	if err != nil {
+		return err
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only the newest link works
+	_, err = tx.Exec(
+		"UPDATE magic_links SET used_at = NOW() WHERE user_id = UUID_TO_BIN(?) AND used_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO magic_links (token_hash, user_id, requested_ip, expires_at)
+		VALUES (?, UUID_TO_BIN(?), ?, ?)`,
+		hash, userID, audit.ClientIP(r), time.Now().Add(magicLinkTTL),
+	)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventMagicLinkSent,
+		UserID:   userID,
+		EntityID: userID,
+	})
+
+	recipient, err := notify.LookupRecipient(r.Context(), h.db, userID)
+	if err != nil {
+		return err
+	}
+	return h.mailer.SendTemplate(r.Context(), recipient, "auth.magic_link", map[string]interface{}{
+		"Link":      h.frontendURL + "/login/magic?token=" + token,
+		"ExpiresIn": int(magicLinkTTL.Minutes()),
+	})
+}
+
+// Redeem exchanges a link's token for a session. Accounts with MFA still need
+// a code; the token stays valid until it is redeemed with one or expires.
+func (h *MagicLinkHandler) Redeem(w http.ResponseWriter, r *http.Request) {
+	if !h.auth.rateLimiter.Allow(r.RemoteAddr) {
+		apierror.Write(w, http.StatusTooManyRequests, apierror.CodeLoginThrottled, "Too many login attempts", nil)
+		return
+	}
+
+	var request struct {
+		Token          string `json:"token"`
+		MFACode        string `json:"mfaCode"`
+		RememberDevice bool   `json:"rememberDevice"`
+		NewPassword    string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		apierror.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Only the link is locked: a wrong MFA code or an expired password
+	// updates the user row outside this transaction
+	var user User
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(u.id), u.role, u.password_hash, COALESCE(u.mfa_secret, ''), u.mfa_enabled, u.failed_attempts,
+		u.locked_until, u.last_password_change, u.require_password_change
+		FROM magic_links m JOIN users u ON u.id = m.user_id
+		WHERE m.token_hash = ? AND m.used_at IS NULL AND m.expires_at > NOW()
+		AND u.deleted_at IS NULL
+		FOR UPDATE OF m`,
+		tokens.Hash(request.Token),
+	).Scan(&user.ID, &user.Role, &user.PasswordHash, &user.MFASecret, &user.MFAEnabled, &user.FailedAttempts,
+		&user.LockedUntil, &user.PasswordChange, &user.MustChange)
+	if err == sql.ErrNoRows {
+		h.auditLogger.Log(r, audit.Event{
+			Type:     audit.EventLoginFailed,
+			Severity: audit.SeverityMedium,
+			Details:  map[string]interface{}{"method": "magic_link", "reason": "invalid_token"},
+		})
+		apierror.Error(w, "Sign-in link is invalid or has expired", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The link stands in for the password only; the rest of the sign-in
+	// checks apply as they do to Login
+	if !h.auth.checkLocked(w, r, &user, "magic_link") {
+		return
+	}
+	outcome, ok := h.auth.completeSignIn(w, r, &user, signInAttempt{
+		Method:         "magic_link",
+		MFACode:        request.MFACode,
+		RememberDevice: request.RememberDevice,
+		NewPassword:    request.NewPassword,
+	})
+	if !ok {
+		return
+	}
+	userID := user.ID
+
+	suspension, err := accounts.ActiveSuspension(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if suspension != nil {
+		h.fail(r, userID, "account_suspended")
+		code, message := apierror.CodeAccountSuspended, "Account is suspended"
+		if suspension.Kind == accounts.KindBan {
+			code, message = apierror.CodeAccountBanned, "Account is banned"
+		}
+		apierror.Write(w, http.StatusForbidden, code, message, map[string]interface{}{
+			"reason":    suspension.Reason,
+			"expiresAt": suspension.ExpiresAt,
+		})
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE magic_links SET used_at = NOW() WHERE token_hash = ?", tokens.Hash(request.Token)); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	deviceID, err := h.auth.issueSession(w, r, userID, user.Role)
+	if err != nil {
+		apierror.Error(w, "Error starting session", http.StatusInternalServerError)
+		return
+	}
+
+	loginDetails := map[string]interface{}{"method": "magic_link"}
+	if outcome.TrustedDevice {
+		loginDetails["mfa"] = "trusted_device"
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventLoginSuccess,
+		UserID:   userID,
+		EntityID: userID,
+		Details:  loginDetails,
+	})
+
+	response := map[string]interface{}{
+		"user":              map[string]string{"id": userID},
+		"message":           "Signed in",
+		"passwordExpiresAt": h.auth.passwordPolicy.ExpiresAt(user.Role, user.PasswordChange),
+	}
+	// Signed in with a recovery code: prompt the user to set up MFA again
+	if outcome.RecoveryCodesRemaining != nil {
+		response["recoveryCodesRemaining"] = *outcome.RecoveryCodesRemaining
+	}
+	if outcome.RememberDevice {
+		until, err := h.auth.trustDevice(r, userID, deviceID)
+		if err != nil {
+			log.Printf("Failed to trust device for user %s: %v", userID, err)
+		} else {
+			response["deviceTrustedUntil"] = until
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *MagicLinkHandler) fail(r *http.Request, userID, reason string) {
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventLoginFailed,
+		Severity: audit.SeverityMedium,
+		UserID:   userID,
+		EntityID: userID,
+		Details:  map[string]interface{}{"method": "magic_link", "reason": reason},
+	})
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"saferelief/internal/audit"
+	"saferelief/internal/sessions"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+	deviceCookieTTL = 365 * 24 * time.Hour
+	// trustedDeviceTTL is how long "remember this device" skips the MFA prompt
+	trustedDeviceTTL = 30 * 24 * time.Hour
+)
+
+// issueSession registers the caller's device, opens a session for it and sets
+// the access, refresh and device cookies. It returns the device's id.
+func (h *AuthHandler) issueSession(w http.ResponseWriter, r *http.Request, userID, role string) (string, error) {
+	var deviceToken string
+	if cookie, err := r.Cookie("device_id"); err == nil {
+		deviceToken = cookie.Value
+	}
+	deviceID, newDeviceToken, err := sessions.EnsureDevice(h.db, userID, deviceToken, r.UserAgent())
+	if err != nil {
+		return "", err
+	}
+	if newDeviceToken != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "device_id",
+			Value:    newDeviceToken,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+			Path:     "/",
+			MaxAge:   int(deviceCookieTTL.Seconds()),
+		})
+	}
+
+	sessionID, err := sessions.NewID(h.db)
+	if err != nil {
+		return "", err
+	}
+	accessToken, err := h.generateAccessToken(userID, sessionID, role)
+	if err != nil {
+		return "", err
+	}
+	refreshToken, err := h.generateRefreshToken(userID, sessionID)
+	if err != nil {
+		return "", err
+	}
+	err = sessions.Create(h.db, sessionID, userID, deviceID, refreshToken, audit.ClientIP(r), r.UserAgent(), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		return "", err
+	}
+
+	// Set tokens in secure HTTP-only cookies
+	http.SetCookie(w, &http.Cookie{
+		Name:     "access_token",
+		Value:    accessToken,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+		MaxAge:   int(accessTokenTTL.Seconds()),
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+		MaxAge:   int(refreshTokenTTL.Seconds()),
+	})
+
+	return deviceID, nil
+}
+
+// deviceTrusted reports whether the request comes from a device the user
+// chose to remember after an MFA-verified login
+func (h *AuthHandler) deviceTrusted(r *http.Request, userID string) (bool, error) {
+	cookie, err := r.Cookie("device_id")
+	if err != nil {
+		return false, nil
+	}
+	return sessions.Trusted(h.db, userID, cookie.Value, r.UserAgent())
+}
+
+// trustDevice remembers the device for MFA and returns when the trust ends
+func (h *AuthHandler) trustDevice(r *http.Request, userID, deviceID string) (*time.Time, error) {
+	until := time.Now().Add(trustedDeviceTTL)
+	if err := sessions.Trust(h.db, deviceID, r.UserAgent(), until); err != nil {
+		return nil, err
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDeviceTrusted,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "device",
+		EntityID:   deviceID,
+		Details:    map[string]interface{}{"trustedUntil": until},
+	})
+	return &until, nil
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/validation"
+
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// maxFailedAttempts wrong passwords or MFA codes in a row lock the
+	// account for lockoutDuration
+	maxFailedAttempts = 5
+	lockoutDuration   = 15 * time.Minute
+)
+
+// signInAttempt is what the client sent along with its first factor
+type signInAttempt struct {
+	// Method goes in the audit details; empty for password logins
+	Method string
+	// Password is the one just verified, empty when the first factor was
+	// something else
+	Password       string
+	MFACode        string
+	RememberDevice bool
+	NewPassword    string
+}
+
+// signInOutcome says how the second factor was satisfied
+type signInOutcome struct {
+	TrustedDevice  bool
+	RememberDevice bool
+	// RecoveryCodesRemaining is set when a recovery code was used
+	RecoveryCodesRemaining *int
+}
+
+func (h *AuthHandler) signInFailed(r *http.Request, user *User, method string, details map[string]interface{}) {
+	if method != "" {
+		details["method"] = method
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventLoginFailed,
+		Severity: audit.SeverityMedium,
+		UserID:   user.ID,
+		EntityID: user.ID,
+		Details:  details,
+	})
+}
+
+// checkLocked writes the error response and returns false while the account
+// is locked
+func (h *AuthHandler) checkLocked(w http.ResponseWriter, r *http.Request, user *User, method string) bool {
+	if user.LockedUntil == nil || !time.Now().Before(*user.LockedUntil) {
+		return true
+	}
+	h.signInFailed(r, user, method, map[string]interface{}{"reason": "account_locked"})
+	apierror.Write(w, http.StatusForbidden, apierror.CodeAccountLocked, "Account is temporarily locked", nil)
+	return false
+}
+
+// recordFailure counts a wrong password or MFA code toward the lockout
+func (h *AuthHandler) recordFailure(r *http.Request, user *User, method, reason string) error {
+	failedAttempts := user.FailedAttempts + 1
+	var lockedUntil *time.Time
+	if failedAttempts >= maxFailedAttempts {
+		t := time.Now().Add(lockoutDuration)
+		lockedUntil = &t
+	}
+
+	_, err := h.db.Exec(
+		"UPDATE users SET failed_attempts = ?, locked_until = ? WHERE id = UUID_TO_BIN(?)",
+		failedAttempts, lockedUntil, user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	h.signInFailed(r, user, method, map[string]interface{}{"reason": reason, "failedAttempts": failedAttempts})
+	if lockedUntil != nil {
+		h.auditLogger.Log(r, audit.Event{
+			Type:     audit.EventAccountLocked,
+			Severity: audit.SeverityHigh,
+			UserID:   user.ID,
+			EntityID: user.ID,
+			Details:  map[string]interface{}{"lockedUntil": lockedUntil},
+		})
+	}
+	return nil
+}
+
+// completeSignIn runs the checks every sign-in method shares once the first
+// factor is proven: the MFA code or a recovery code, with wrong codes counted
+// toward the lockout, and the replacement of an expired password. It writes
+// the error response and returns false when no session may be issued.
+func (h *AuthHandler) completeSignIn(w http.ResponseWriter, r *http.Request, user *User, attempt signInAttempt) (signInOutcome, bool) {
+	var outcome signInOutcome
+	var err error
+	if user.MFAEnabled && attempt.MFACode == "" {
+		outcome.TrustedDevice, err = h.deviceTrusted(r, user.ID)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return outcome, false
+		}
+	}
+	if user.MFAEnabled && !outcome.TrustedDevice {
+		if attempt.MFACode == "" {
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeMFARequired, "MFA required", nil)
+			return outcome, false
+		}
+
+		if !totp.Validate(attempt.MFACode, user.MFASecret) {
+			// A recovery code stands in for the authenticator once
+			recovered, err := accounts.UseRecoveryCode(h.db, user.ID, attempt.MFACode)
+			if err != nil {
+				apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+				return outcome, false
+			}
+			if !recovered {
+				if err := h.recordFailure(r, user, attempt.Method, "invalid_mfa_code"); err != nil {
+					apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+					return outcome, false
+				}
+				apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidMFACode, "Invalid MFA code", nil)
+				return outcome, false
+			}
+
+			// Recovery codes are for a lost authenticator, so they don't
+			// earn the device trust
+			attempt.RememberDevice = false
+			remaining, _ := accounts.RemainingRecoveryCodes(h.db, user.ID)
+			outcome.RecoveryCodesRemaining = &remaining
+			h.auditLogger.Log(r, audit.Event{
+				Type:     audit.EventRecoveryCodeUsed,
+				Severity: audit.SeverityHigh,
+				UserID:   user.ID,
+				EntityID: user.ID,
+				Details:  map[string]interface{}{"remaining": remaining},
+			})
+		}
+		outcome.RememberDevice = attempt.RememberDevice
+	}
+
+	// Every factor checked out, so the failure count starts over
+	if user.FailedAttempts > 0 || user.LockedUntil != nil {
+		_, err = h.db.Exec(
+			"UPDATE users SET failed_attempts = 0, locked_until = NULL WHERE id = UUID_TO_BIN(?)",
+			user.ID,
+		)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return outcome, false
+		}
+	}
+
+	// Expired passwords must be replaced before any tokens are issued
+	if h.passwordExpired(user) {
+		if attempt.NewPassword == "" {
+			apierror.Write(w, http.StatusForbidden, apierror.CodePasswordChangeRequired,
+				"Your password has expired; sign in again with newPassword set", nil)
+			return outcome, false
+		}
+
+		v := validation.New()
+		v.Password("newPassword", attempt.NewPassword)
+		if attempt.Password != "" {
+			v.Check(attempt.NewPassword != attempt.Password, "newPassword", "must differ from the current password")
+		}
+		if !v.Valid() {
+			v.WriteError(w)
+			return outcome, false
+		}
+		if err := accounts.ChangePassword(h.db, user.ID, attempt.NewPassword); err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return outcome, false
+		}
+		user.PasswordChange = time.Now()
+		user.MustChange = false
+		details := map[string]interface{}{"reason": "expired"}
+		if attempt.Method != "" {
+			details["method"] = attempt.Method
+		}
+		h.auditLogger.Log(r, audit.Event{
+			Type:     audit.EventPasswordChanged,
+			Severity: audit.SeverityMedium,
+			UserID:   user.ID,
+			EntityID: user.ID,
+			Details:  details,
+		})
+	}
+	return outcome, true
+}
+
+// passwordExpired reports whether the user must pick a new password before
+// signing in. Accounts without a password have nothing to replace.
+func (h *AuthHandler) passwordExpired(user *User) bool {
+	if !accounts.HasPassword(user.PasswordHash) {
+		return false
+	}
+	return user.MustChange || h.passwordPolicy.Expired(user.Role, user.PasswordChange)
+}
+
+// needsSignInInput reports whether completeSignIn would stop to ask for an
+// MFA code or a new password, for sign-in methods that cannot answer it in
+// the same request
+func (h *AuthHandler) needsSignInInput(r *http.Request, user *User) (bool, error) {
+	if user.MFAEnabled {
+		trusted, err := h.deviceTrusted(r, user.ID)
+		if err != nil {
+			return false, err
+		}
+		if !trusted {
+			return true, nil
+		}
+	}
+	return h.passwordExpired(user), nil
+}
+
+// loadSignInUser loads what completeSignIn needs for a user whose first
+// factor was proven somewhere else
+func (h *AuthHandler) loadSignInUser(userID string) (*User, error) {
+	var user User
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(id), username, email, password_hash, COALESCE(mfa_secret, ''), mfa_enabled, failed_attempts,
+		locked_until, role, last_password_change, require_password_change
+		FROM users WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL`,
+		userID,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.MFASecret, &user.MFAEnabled, &user.FailedAttempts,
+		&user.LockedUntil, &user.Role, &user.PasswordChange, &user.MustChange)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,497 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/sso"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+const (
+	ssoStateCookie = "sso_state"
+	// ssoChallengeCookie carries a provider sign-in that still needs an MFA
+	// code or a new password over to Complete
+	ssoChallengeCookie = "sso_challenge"
+	ssoChallengeTTL    = 5 * time.Minute
+)
+
+// SSOHandler signs organization staff in through their organization's
+// OpenID Connect provider using the authorization code flow with PKCE
+type SSOHandler struct {
+	auth        *AuthHandler
+	db          *sql.DB
+	client      *sso.Client
+	auditLogger *audit.Logger
+	frontendURL string
+	// redirectURL is the callback registered with every provider; empty means
+	// it is derived from the request host
+	redirectURL string
+}
+
+func NewSSOHandler(authHandler *AuthHandler, db *sql.DB, auditLogger *audit.Logger) *SSOHandler {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	return &SSOHandler{
+		auth:        authHandler,
+		db:          db,
+		client:      sso.NewClient(),
+		auditLogger: auditLogger,
+		frontendURL: strings.TrimSuffix(frontendURL, "/"),
+		redirectURL: os.Getenv("SSO_REDIRECT_URL"),
+	}
+}
+
+func (h *SSOHandler) callbackURL(r *http.Request) string {
+	if h.redirectURL != "" {
+		return h.redirectURL
+	}
+	return "https://" + r.Host + "/api/auth/sso/callback"
+}
+
+// Discover tells the login page whether an email belongs to an organization
+// that signs in through SSO
+func (h *SSOHandler) Discover(w http.ResponseWriter, r *http.Request) {
+	email := strings.TrimSpace(r.URL.Query().Get("email"))
+	if email == "" {
+		apierror.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	orgID, err := sso.OrganizationForEmail(h.db, email)
+	if errors.Is(err, sso.ErrNotConfigured) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"sso": false})
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sso":            true,
+		"organizationId": orgID,
+		"loginUrl":       "/api/auth/sso/" + orgID + "/login",
+	})
+}
+
+// Start redirects the browser to the organization's identity provider
+func (h *SSOHandler) Start(w http.ResponseWriter, r *http.Request) {
+	authURL, ok := h.begin(w, r, "")
+	if !ok {
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// StartLink begins a provider sign-in that links the identity to the
+// signed-in account instead of signing in. Accounts with MFA or a privileged
+// role can only be tied to an identity this way.
+func (h *SSOHandler) StartLink(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	authURL, ok := h.begin(w, r, userID)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"authUrl": authURL})
+}
+
+// begin records the login state, sets the state cookie and returns the
+// provider's authorization URL
+func (h *SSOHandler) begin(w http.ResponseWriter, r *http.Request, linkUserID string) (string, bool) {
+	orgID := mux.Vars(r)["organizationId"]
+	cfg, err := sso.LoadConfig(h.db, orgID)
+	if errors.Is(err, sso.ErrNotConfigured) || (err == nil && !cfg.Enabled) {
+		apierror.Error(w, "Single sign-on is not enabled for this organization", http.StatusNotFound)
+		return "", false
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return "", false
+	}
+
+	redirectPath := r.URL.Query().Get("redirect")
+	if !safeRedirectPath(redirectPath) {
+		redirectPath = "/"
+	}
+
+	login, err := sso.BeginLogin(h.db, orgID, redirectPath, linkUserID)
+	if err != nil {
+		apierror.Error(w, "Error starting sign-in", http.StatusInternalServerError)
+		return "", false
+	}
+	authURL, err := h.client.AuthURL(r.Context(), cfg, h.callbackURL(r), login)
+	if err != nil {
+		apierror.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return "", false
+	}
+
+	// Binds the callback to this browser. Lax, because the provider's
+	// redirect back is a cross-site top-level navigation.
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    login.State,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/api/auth/sso",
+		MaxAge:   600,
+	})
+	return authURL, true
+}
+
+// Callback completes the sign-in when the provider redirects back. Every
+// outcome ends in a redirect to the frontend, with ssoError set on failure.
+func (h *SSOHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	state := query.Get("state")
+
+	cookie, err := r.Cookie(ssoStateCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    "",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/api/auth/sso",
+		MaxAge:   -1,
+	})
+	if err != nil || state == "" || cookie.Value != state {
+		h.fail(w, r, "", "", "invalid_state")
+		return
+	}
+
+	login, err := sso.ConsumeLogin(h.db, state)
+	if err != nil {
+		h.fail(w, r, "", "", "invalid_state")
+		return
+	}
+	if idpError := query.Get("error"); idpError != "" {
+		h.fail(w, r, login.OrganizationID, "", "provider_error")
+		return
+	}
+
+	cfg, err := sso.LoadConfig(h.db, login.OrganizationID)
+	if err != nil || !cfg.Enabled {
+		h.fail(w, r, login.OrganizationID, "", "not_configured")
+		return
+	}
+
+	identity, err := h.client.Exchange(r.Context(), cfg, h.callbackURL(r), query.Get("code"), login)
+	if err != nil {
+		h.fail(w, r, login.OrganizationID, "", "invalid_token")
+		return
+	}
+
+	account, err := sso.Provision(r.Context(), h.db, cfg, identity, login.LinkUserID)
+	switch {
+	case errors.Is(err, sso.ErrLinkRequired):
+		h.fail(w, r, login.OrganizationID, "", "link_required")
+		return
+	case errors.Is(err, sso.ErrIdentityTaken):
+		h.fail(w, r, login.OrganizationID, login.LinkUserID, "identity_taken")
+		return
+	case errors.Is(err, sso.ErrEmailNotAllowed), errors.Is(err, sso.ErrEmailUnverified):
+		h.fail(w, r, login.OrganizationID, "", "email_not_allowed")
+		return
+	case errors.Is(err, sso.ErrNoAccess):
+		h.fail(w, r, login.OrganizationID, "", "no_access")
+		return
+	case errors.Is(err, sso.ErrAccountDisabled):
+		h.fail(w, r, login.OrganizationID, "", "account_disabled")
+		return
+	case err != nil:
+		h.fail(w, r, login.OrganizationID, "", "server_error")
+		return
+	}
+
+	if account.Created {
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventSSOUserProvisioned,
+			Severity:   audit.SeverityMedium,
+			UserID:     account.UserID,
+			EntityType: "organization",
+			EntityID:   login.OrganizationID,
+			Details:    map[string]interface{}{"permissions": account.Permissions},
+		})
+	}
+
+	if login.LinkUserID != "" {
+		// The user is already signed in; linking issues no new session
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventSSOIdentityLinked,
+			Severity:   audit.SeverityHigh,
+			UserID:     account.UserID,
+			EntityType: "organization",
+			EntityID:   login.OrganizationID,
+			Details:    map[string]interface{}{"issuer": identity.Issuer, "subject": identity.Subject},
+		})
+		http.Redirect(w, r, h.frontendURL+login.RedirectPath, http.StatusFound)
+		return
+	}
+
+	user, err := h.auth.loadSignInUser(account.UserID)
+	if err != nil {
+		h.fail(w, r, login.OrganizationID, account.UserID, "server_error")
+		return
+	}
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		h.fail(w, r, login.OrganizationID, user.ID, "account_locked")
+		return
+	}
+
+	// The provider stands in for the password only. A redirect cannot ask
+	// for an MFA code or a new password, so those go to Complete.
+	needsInput, err := h.auth.needsSignInInput(r, user)
+	if err != nil {
+		h.fail(w, r, login.OrganizationID, user.ID, "server_error")
+		return
+	}
+	if needsInput {
+		challenge, err := h.challengeToken(user.ID, login.OrganizationID, login.RedirectPath)
+		if err != nil {
+			h.fail(w, r, login.OrganizationID, user.ID, "server_error")
+			return
+		}
+		h.setChallengeCookie(w, challenge, int(ssoChallengeTTL.Seconds()))
+		http.Redirect(w, r, h.frontendURL+"/login/sso", http.StatusFound)
+		return
+	}
+
+	// Nothing is left to ask for, so this fails only on a server error
+	rec := &discardResponse{header: http.Header{}}
+	outcome, ok := h.auth.completeSignIn(rec, r, user, signInAttempt{Method: "sso"})
+	if !ok {
+		h.fail(w, r, login.OrganizationID, user.ID, "server_error")
+		return
+	}
+	if reason := h.suspended(user.ID); reason != "" {
+		h.fail(w, r, login.OrganizationID, user.ID, reason)
+		return
+	}
+
+	if _, err := h.auth.issueSession(w, r, user.ID, user.Role); err != nil {
+		h.fail(w, r, login.OrganizationID, user.ID, "server_error")
+		return
+	}
+	h.loginSucceeded(r, user.ID, login.OrganizationID, outcome)
+	http.Redirect(w, r, h.frontendURL+login.RedirectPath, http.StatusFound)
+}
+
+// Complete finishes a provider sign-in that stopped for an MFA code or a new
+// password. The body takes the same fields as a password login.
+func (h *SSOHandler) Complete(w http.ResponseWriter, r *http.Request) {
+	if !h.auth.rateLimiter.Allow(r.RemoteAddr) {
+		apierror.Write(w, http.StatusTooManyRequests, apierror.CodeLoginThrottled, "Too many login attempts", nil)
+		return
+	}
+
+	var request struct {
+		MFACode        string `json:"mfaCode"`
+		RememberDevice bool   `json:"rememberDevice"`
+		NewPassword    string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(ssoChallengeCookie)
+	if err != nil {
+		apierror.Error(w, "Single sign-on has expired; sign in again", http.StatusUnauthorized)
+		return
+	}
+	userID, orgID, redirectPath, err := h.parseChallengeToken(cookie.Value)
+	if err != nil {
+		apierror.Error(w, "Single sign-on has expired; sign in again", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.auth.loadSignInUser(userID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Single sign-on has expired; sign in again", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !h.auth.checkLocked(w, r, user, "sso") {
+		return
+	}
+	outcome, ok := h.auth.completeSignIn(w, r, user, signInAttempt{
+		Method:         "sso",
+		MFACode:        request.MFACode,
+		RememberDevice: request.RememberDevice,
+		NewPassword:    request.NewPassword,
+	})
+	if !ok {
+		return
+	}
+
+	suspension, err := accounts.ActiveSuspension(h.db, user.ID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if suspension != nil {
+		h.auditFailure(r, orgID, user.ID, "account_suspended")
+		code, message := apierror.CodeAccountSuspended, "Account is suspended"
+		if suspension.Kind == accounts.KindBan {
+			code, message = apierror.CodeAccountBanned, "Account is banned"
+		}
+		apierror.Write(w, http.StatusForbidden, code, message, map[string]interface{}{
+			"reason":    suspension.Reason,
+			"expiresAt": suspension.ExpiresAt,
+		})
+		return
+	}
+
+	h.setChallengeCookie(w, "", -1)
+	deviceID, err := h.auth.issueSession(w, r, user.ID, user.Role)
+	if err != nil {
+		apierror.Error(w, "Error starting session", http.StatusInternalServerError)
+		return
+	}
+	h.loginSucceeded(r, user.ID, orgID, outcome)
+
+	response := map[string]interface{}{
+		"user":              map[string]string{"id": user.ID},
+		"redirectPath":      redirectPath,
+		"passwordExpiresAt": h.auth.passwordPolicy.ExpiresAt(user.Role, user.PasswordChange),
+	}
+	// Signed in with a recovery code: prompt the user to set up MFA again
+	if outcome.RecoveryCodesRemaining != nil {
+		response["recoveryCodesRemaining"] = *outcome.RecoveryCodesRemaining
+	}
+	if outcome.RememberDevice {
+		until, err := h.auth.trustDevice(r, user.ID, deviceID)
+		if err != nil {
+			log.Printf("Failed to trust device for user %s: %v", user.ID, err)
+		} else {
+			response["deviceTrustedUntil"] = until
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// suspended returns the failure reason when the user may not sign in
+func (h *SSOHandler) suspended(userID string) string {
+	suspension, err := accounts.ActiveSuspension(h.db, userID)
+	if err != nil {
+		return "server_error"
+	}
+	if suspension != nil {
+		return "account_suspended"
+	}
+	return ""
+}
+
+func (h *SSOHandler) loginSucceeded(r *http.Request, userID, orgID string, outcome signInOutcome) {
+	details := map[string]interface{}{"method": "sso", "organizationId": orgID}
+	if outcome.TrustedDevice {
+		details["mfa"] = "trusted_device"
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventLoginSuccess,
+		UserID:   userID,
+		EntityID: userID,
+		Details:  details,
+	})
+}
+
+func (h *SSOHandler) challengeToken(userID, orgID, redirectPath string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":      userID,
+		"org":      orgID,
+		"redirect": redirectPath,
+		"purpose":  ssoChallengeCookie,
+		"exp":      time.Now().Add(ssoChallengeTTL).Unix(),
+	})
+	return token.SignedString(h.auth.jwtSecret)
+}
+
+func (h *SSOHandler) parseChallengeToken(value string) (userID, orgID, redirectPath string, err error) {
+	token, err := jwt.Parse(value, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return h.auth.jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return "", "", "", fmt.Errorf("invalid challenge token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != ssoChallengeCookie {
+		return "", "", "", fmt.Errorf("invalid challenge token")
+	}
+	userID, _ = claims["sub"].(string)
+	orgID, _ = claims["org"].(string)
+	redirectPath, _ = claims["redirect"].(string)
+	if userID == "" || !safeRedirectPath(redirectPath) {
+		return "", "", "", fmt.Errorf("invalid challenge token")
+	}
+	return userID, orgID, redirectPath, nil
+}
+
+func (h *SSOHandler) setChallengeCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoChallengeCookie,
+		Value:    value,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/api/auth/sso",
+		MaxAge:   maxAge,
+	})
+}
+
+func (h *SSOHandler) fail(w http.ResponseWriter, r *http.Request, orgID, userID, reason string) {
+	h.auditFailure(r, orgID, userID, reason)
+	http.Redirect(w, r, h.frontendURL+"/login?ssoError="+url.QueryEscape(reason), http.StatusFound)
+}
+
+func (h *SSOHandler) auditFailure(r *http.Request, orgID, userID, reason string) {
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventLoginFailed,
+		Severity: audit.SeverityMedium,
+		UserID:   userID,
+		EntityID: userID,
+		Details:  map[string]interface{}{"method": "sso", "organizationId": orgID, "reason": reason},
+	})
+}
+
+// safeRedirectPath accepts only paths on the frontend, so the login cannot
+// be used to bounce users to another site
+func safeRedirectPath(path string) bool {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.ContainsAny(path, "\\\r\n") {
+		return false
+	}
+	return len(path) <= 512
+}
+
+// discardResponse swallows the error body completeSignIn writes, for the
+// callback, which answers every outcome with a redirect
+type discardResponse struct{ header http.Header }
+
+func (d *discardResponse) Header() http.Header         { return d.header }
+func (d *discardResponse) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardResponse) WriteHeader(int)             {}
@@ -0,0 +1,70 @@
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const maxPolygonPoints = 100
+
+// Area is either a circle (center and radius) or a polygon of
+// [latitude, longitude] vertices
+type Area struct {
+	Latitude  float64      `json:"latitude,omitempty"`
+	Longitude float64      `json:"longitude,omitempty"`
+	RadiusKm  float64      `json:"radiusKm,omitempty"`
+	Polygon   [][2]float64 `json:"polygon,omitempty"`
+}
+
+func (a Area) Validate() error {
+	if len(a.Polygon) > 0 {
+		if a.RadiusKm != 0 {
+			return errors.New("give either a polygon or a radius, not both")
+		}
+		if len(a.Polygon) < 3 || len(a.Polygon) > maxPolygonPoints {
+			return fmt.Errorf("polygon must have between 3 and %d points", maxPolygonPoints)
+		}
+		for _, p := range a.Polygon {
+			if !validCoordinates(p[0], p[1]) {
+				return errors.New("polygon points must be valid [latitude, longitude] pairs")
+			}
+		}
+		return nil
+	}
+	if !validCoordinates(a.Latitude, a.Longitude) {
+		return errors.New("center must be a valid latitude and longitude")
+	}
+	if a.RadiusKm <= 0 || a.RadiusKm > 1000 {
+		return errors.New("radiusKm must be between 0 and 1000")
+	}
+	return nil
+}
+
+// condition returns a SQL predicate matching rows whose latColumn/lonColumn
+// fall inside the area. Coordinates are compared as planar lon/lat (SRID 0),
+// which is accurate enough at broadcast scale and avoids axis-order surprises.
+func (a Area) condition(latColumn, lonColumn string) (string, []interface{}) {
+	point := fmt.Sprintf("POINT(%s, %s)", lonColumn, latColumn)
+	if len(a.Polygon) > 0 {
+		return "ST_Contains(ST_GeomFromText(?), " + point + ")", []interface{}{a.wkt()}
+	}
+	return "ST_Distance_Sphere(" + point + ", POINT(?, ?)) <= ?",
+		[]interface{}{a.Longitude, a.Latitude, a.RadiusKm * 1000}
+}
+
+// wkt renders the polygon as a closed lon/lat ring
+func (a Area) wkt() string {
+	points := make([]string, 0, len(a.Polygon)+1)
+	for _, p := range a.Polygon {
+		points = append(points, fmt.Sprintf("%f %f", p[1], p[0]))
+	}
+	if a.Polygon[0] != a.Polygon[len(a.Polygon)-1] {
+		points = append(points, points[0])
+	}
+	return "POLYGON((" + strings.Join(points, ", ") + "))"
+}
+
+func validCoordinates(latitude, longitude float64) bool {
+	return latitude >= -90 && latitude <= 90 && longitude >= -180 && longitude <= 180
+}
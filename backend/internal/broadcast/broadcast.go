@@ -0,0 +1,290 @@
+package broadcast
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"saferelief/internal/jobs"
+	"saferelief/internal/notify"
+	"saferelief/internal/telegram"
+)
+
+const (
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+
+	batchSize = 200
+)
+
+var ErrUnknownChannel = errors.New("unknown broadcast channel")
+
+// Sender delivers a broadcast over one channel. Push and SMS providers plug
+// in by implementing it and registering with the Broadcaster.
+type Sender interface {
+	// Audience returns the SQL expression selecting the channel address for
+	// a user row aliased u, or "" when the user can't be reached this way
+	Audience() string
+	Send(ctx context.Context, address, title, message string) error
+}
+
+type emailSender struct{ mailer *notify.Mailer }
+
+func (s emailSender) Audience() string { return "u.email" }
+
+func (s emailSender) Send(_ context.Context, address, title, message string) error {
+	return s.mailer.Send(address, title, message)
+}
+
+type telegramSender struct{ bot *telegram.Bot }
+
+func (s telegramSender) Audience() string {
+	return "(SELECT CAST(tl.chat_id AS CHAR) FROM telegram_links tl WHERE tl.user_id = u.id)"
+}
+
+func (s telegramSender) Send(ctx context.Context, address, title, message string) error {
+	chatID, err := strconv.ParseInt(address, 10, 64)
+	if err != nil {
+		return err
+	}
+	return s.bot.SendMessage(ctx, chatID, "🚨 "+title+"\n\n"+message)
+}
+
+// Broadcaster resolves the users inside an area and fans a message out to
+// them over the job queue at a bounded rate
+type Broadcaster struct {
+	db         *sql.DB
+	queue      *jobs.Queue
+	senders    map[string]Sender
+	interval   time.Duration
+	onComplete func(broadcastID string, sent, failed int)
+}
+
+func NewBroadcaster(db *sql.DB, queue *jobs.Queue, mailer *notify.Mailer, bot *telegram.Bot, perSecond int, onComplete func(string, int, int)) *Broadcaster {
+	if perSecond <= 0 {
+		perSecond = 20
+	}
+	b := &Broadcaster{
+		db:         db,
+		queue:      queue,
+		senders:    map[string]Sender{ChannelEmail: emailSender{mailer}},
+		interval:   time.Second / time.Duration(perSecond),
+		onComplete: onComplete,
+	}
+	if bot.Enabled() {
+		b.senders[ChannelTelegram] = telegramSender{bot}
+	}
+	return b
+}
+
+func (b *Broadcaster) Register(channel string, sender Sender) {
+	b.senders[channel] = sender
+}
+
+func (b *Broadcaster) Channels() []string {
+	channels := make([]string, 0, len(b.senders))
+	for name := range b.senders {
+		channels = append(channels, name)
+	}
+	return channels
+}
+
+// audienceQuery selects active users whose last known location or any
+// followed region's center lies within the area
+func audienceQuery(area Area, columns string) (string, []interface{}) {
+	locationCond, locationArgs := area.condition("ul.latitude", "ul.longitude")
+	regionCond, regionArgs := area.condition("fr.latitude", "fr.longitude")
+	query := `SELECT ` + columns + ` FROM users u
+		WHERE u.status = 'active' AND u.deleted_at IS NULL AND (
+			EXISTS (SELECT 1 FROM user_locations ul WHERE ul.user_id = u.id AND ` + locationCond + `)
+			OR EXISTS (SELECT 1 FROM followed_regions fr WHERE fr.user_id = u.id AND ` + regionCond + `)
+		)`
+	return query, append(locationArgs, regionArgs...)
+}
+
+// Estimate counts the users in the area and how many each channel reaches
+func (b *Broadcaster) Estimate(ctx context.Context, area Area, channels []string) (int, map[string]int, error) {
+	var total int
+	query, args := audienceQuery(area, "COUNT(*)")
+	if err := b.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, nil, err
+	}
+
+	reach := map[string]int{}
+	for _, channel := range channels {
+		sender, ok := b.senders[channel]
+		if !ok {
+			return 0, nil, ErrUnknownChannel
+		}
+		query, args := audienceQuery(area, "COUNT("+sender.Audience()+")")
+		var n int
+		if err := b.db.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+			return 0, nil, err
+		}
+		reach[channel] = n
+	}
+	return total, reach, nil
+}
+
+// Start records one recipient row per reachable user and channel, then
+// queues the fan-out. It returns the number of messages to send.
+func (b *Broadcaster) Start(ctx context.Context, broadcastID string, area Area, channels []string) (int, error) {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	queued := 0
+	for _, channel := range channels {
+		sender, ok := b.senders[channel]
+		if !ok {
+			return 0, ErrUnknownChannel
+		}
+		address := sender.Audience()
+		query, args := audienceQuery(area,
+			"UUID_TO_BIN(?), u.id, ?, "+address)
+		query += " AND " + address + " IS NOT NULL"
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO broadcast_recipients (broadcast_id, user_id, channel, address) `+query,
+			append([]interface{}{broadcastID, channel}, args...)...,
+		)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		queued += int(n)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE broadcasts SET status = 'sending', recipient_count = ? WHERE id = UUID_TO_BIN(?)",
+		queued, broadcastID,
+	); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return queued, b.enqueue(broadcastID)
+}
+
+// Retry marks a broadcast's failed messages pending again and resumes the
+// fan-out. It returns how many messages will be resent.
+func (b *Broadcaster) Retry(ctx context.Context, broadcastID string) (int, error) {
+	result, err := b.db.ExecContext(ctx,
+		`UPDATE broadcast_recipients SET status = 'pending', last_error = NULL, sent_at = NULL
+		WHERE broadcast_id = UUID_TO_BIN(?) AND status = 'failed'`,
+		broadcastID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return 0, nil
+	}
+	if _, err := b.db.ExecContext(ctx,
+		"UPDATE broadcasts SET status = 'sending', completed_at = NULL WHERE id = UUID_TO_BIN(?)",
+		broadcastID,
+	); err != nil {
+		return 0, err
+	}
+	return int(n), b.enqueue(broadcastID)
+}
+
+func (b *Broadcaster) enqueue(broadcastID string) error {
+	return b.queue.Enqueue(jobs.Job{
+		Name:        "broadcast-" + broadcastID,
+		MaxAttempts: 3,
+		Run: func(ctx context.Context) error {
+			return b.sendBatch(ctx, broadcastID)
+		},
+	})
+}
+
+type recipient struct {
+	userID, channel, address string
+}
+
+// sendBatch sends the next batch of pending messages, paced to the
+// configured rate, and requeues itself until none are left
+func (b *Broadcaster) sendBatch(ctx context.Context, broadcastID string) error {
+	var title, message string
+	if err := b.db.QueryRowContext(ctx,
+		"SELECT title, message FROM broadcasts WHERE id = UUID_TO_BIN(?)",
+		broadcastID,
+	).Scan(&title, &message); err != nil {
+		return err
+	}
+
+	rows, err := b.db.QueryContext(ctx,
+		`SELECT BIN_TO_UUID(user_id), channel, address FROM broadcast_recipients
+		WHERE broadcast_id = UUID_TO_BIN(?) AND status = 'pending' LIMIT ?`,
+		broadcastID, batchSize,
+	)
+	if err != nil {
+		return err
+	}
+	var batch []recipient
+	for rows.Next() {
+		var r recipient
+		if rows.Scan(&r.userID, &r.channel, &r.address) == nil {
+			batch = append(batch, r)
+		}
+	}
+	rows.Close()
+
+	if len(batch) == 0 {
+		return b.finish(ctx, broadcastID)
+	}
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for _, r := range batch {
+		<-ticker.C
+		status, lastError := "sent", ""
+		if sender, ok := b.senders[r.channel]; !ok {
+			status, lastError = "failed", ErrUnknownChannel.Error()
+		} else if err := sender.Send(ctx, r.address, title, message); err != nil {
+			status, lastError = "failed", err.Error()
+		}
+		if _, err := b.db.ExecContext(ctx,
+			`UPDATE broadcast_recipients SET status = ?, last_error = NULLIF(?, ''), sent_at = NOW()
+			WHERE broadcast_id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?) AND channel = ?`,
+			status, lastError, broadcastID, r.userID, r.channel,
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := b.enqueue(broadcastID); err != nil {
+		log.Printf("Failed to queue next batch of broadcast %s: %v", broadcastID, err)
+		return err
+	}
+	return nil
+}
+
+func (b *Broadcaster) finish(ctx context.Context, broadcastID string) error {
+	var sent, failed int
+	if err := b.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(status = 'sent'), 0), COALESCE(SUM(status = 'failed'), 0)
+		FROM broadcast_recipients WHERE broadcast_id = UUID_TO_BIN(?)`,
+		broadcastID,
+	).Scan(&sent, &failed); err != nil {
+		return err
+	}
+	if _, err := b.db.ExecContext(ctx,
+		"UPDATE broadcasts SET status = 'completed', completed_at = NOW() WHERE id = UUID_TO_BIN(?)",
+		broadcastID,
+	); err != nil {
+		return err
+	}
+	if b.onComplete != nil {
+		b.onComplete(broadcastID, sent, failed)
+	}
+	return nil
+}
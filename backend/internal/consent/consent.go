@@ -0,0 +1,106 @@
+package consent
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Documents a user must agree to before using the API
+const (
+	DocumentPrivacy = "privacy"
+	DocumentTerms   = "terms"
+)
+
+var ErrVersionMismatch = errors.New("consent does not match the current policy version")
+
+type PolicyVersion struct {
+	Document    string    `json:"document"`
+	Version     string    `json:"version"`
+	URL         string    `json:"url"`
+	EffectiveAt time.Time `json:"effectiveAt"`
+}
+
+// currentClause selects the newest version of each document already in effect
+const currentClause = `pv.effective_at <= NOW() AND pv.effective_at = (
+	SELECT MAX(effective_at) FROM policy_versions
+	WHERE document = pv.document AND effective_at <= NOW()
+)`
+
+// Current returns the policy versions in effect right now
+func Current(db *sql.DB) ([]PolicyVersion, error) {
+	return queryVersions(db,
+		`SELECT pv.document, pv.version, pv.url, pv.effective_at
+		FROM policy_versions pv WHERE `+currentClause+` ORDER BY pv.document`,
+	)
+}
+
+// Outstanding returns the current policy versions the user has not yet agreed to
+func Outstanding(db *sql.DB, userID string) ([]PolicyVersion, error) {
+	return queryVersions(db,
+		`SELECT pv.document, pv.version, pv.url, pv.effective_at
+		FROM policy_versions pv WHERE `+currentClause+`
+		AND NOT EXISTS (
+			SELECT 1 FROM user_consents uc
+			WHERE uc.user_id = UUID_TO_BIN(?) AND uc.document = pv.document AND uc.version = pv.version
+		)
+		ORDER BY pv.document`,
+		userID,
+	)
+}
+
+// Record stores the user's agreement to the given document versions. Every
+// version must be the one currently in effect so stale clients cannot consent
+// to superseded terms.
+func Record(db *sql.DB, userID, ipAddress string, versions map[string]string) error {
+	current, err := Current(db)
+	if err != nil {
+		return err
+	}
+	currentByDocument := make(map[string]string, len(current))
+	for _, pv := range current {
+		currentByDocument[pv.Document] = pv.Version
+	}
+	for document, version := range versions {
+		if currentByDocument[document] != version {
+			return ErrVersionMismatch
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for document, version := range versions {
+		_, err := tx.Exec(
+			`INSERT IGNORE INTO user_consents (id, user_id, document, version, ip_address)
+			VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?)`,
+			userID, document, version, ipAddress,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func queryVersions(db *sql.DB, query string, args ...interface{}) ([]PolicyVersion, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []PolicyVersion{}
+	for rows.Next() {
+		var pv PolicyVersion
+		if err := rows.Scan(&pv.Document, &pv.Version, &pv.URL, &pv.EffectiveAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, pv)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,115 @@
+package crosscheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// BNPB reads events from the national disaster agency's DIBI records.
+// BNPB does not publish a stable public API, so BNPB_API_URL points at a
+// DIBI export service or proxy that accepts latitude, longitude, radius_km,
+// from and to (YYYY-MM-DD) and answers with {"data": [records]}.
+type BNPB struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+// NewBNPBFromEnv returns nil when BNPB_API_URL is unset
+func NewBNPBFromEnv() *BNPB {
+	endpoint := os.Getenv("BNPB_API_URL")
+	if endpoint == "" {
+		return nil
+	}
+	return &BNPB{
+		endpoint: endpoint,
+		apiKey:   os.Getenv("BNPB_API_KEY"),
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (b *BNPB) Name() string {
+	return "bnpb"
+}
+
+// dibiRecord uses DIBI's field names
+type dibiRecord struct {
+	ID         string  `json:"id"`
+	Kejadian   string  `json:"kejadian"`
+	Tanggal    string  `json:"tanggal"`
+	Provinsi   string  `json:"provinsi"`
+	Kabupaten  string  `json:"kabupaten"`
+	Lintang    float64 `json:"lintang"`
+	Bujur      float64 `json:"bujur"`
+	Keterangan string  `json:"keterangan"`
+}
+
+func (b *BNPB) Search(ctx context.Context, q Query) ([]Event, error) {
+	params := url.Values{}
+	params.Set("latitude", strconv.FormatFloat(q.Latitude, 'f', 6, 64))
+	params.Set("longitude", strconv.FormatFloat(q.Longitude, 'f', 6, 64))
+	params.Set("radius_km", strconv.FormatFloat(q.RadiusKm, 'f', 0, 64))
+	params.Set("from", q.From.Format("2006-01-02"))
+	params.Set("to", q.To.Format("2006-01-02"))
+
+	u, err := url.Parse(b.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("BNPB responded with %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data []dibiRecord `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 5<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid BNPB response: %w", err)
+	}
+
+	events := make([]Event, 0, len(body.Data))
+	for _, record := range body.Data {
+		date, err := time.Parse("2006-01-02", record.Tanggal)
+		if err != nil {
+			continue
+		}
+		events = append(events, Event{
+			ID:          record.ID,
+			Type:        record.Kejadian,
+			Date:        date,
+			Province:    record.Provinsi,
+			Regency:     record.Kabupaten,
+			Latitude:    record.Lintang,
+			Longitude:   record.Bujur,
+			Description: record.Keterangan,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,228 @@
+// Package crosscheck compares incoming disaster reports with official
+// disaster records so verifiers can see at a glance whether an agency has
+// logged a matching event.
+package crosscheck
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	searchRadiusKm = 50
+	searchWindow   = 3 * 24 * time.Hour
+	// Reports whose best match scores at least this are marked matched
+	matchThreshold = 0.6
+	maxMatches     = 5
+	sweepBatch     = 20
+)
+
+// Event is an official disaster record
+type Event struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Date        time.Time `json:"date"`
+	Province    string    `json:"province,omitempty"`
+	Regency     string    `json:"regency,omitempty"`
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	Description string    `json:"description,omitempty"`
+}
+
+type Query struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+	From      time.Time
+	To        time.Time
+}
+
+// Source searches an agency's records around a place and time
+type Source interface {
+	Name() string
+	Search(ctx context.Context, q Query) ([]Event, error)
+}
+
+type Match struct {
+	Event
+	DistanceKm float64 `json:"distanceKm"`
+	DaysApart  float64 `json:"daysApart"`
+	TypeMatch  bool    `json:"typeMatch"`
+	Score      float64 `json:"score"`
+}
+
+// Report is the part of a disaster report the comparison uses
+type Report struct {
+	Title       string
+	Description string
+	Latitude    float64
+	Longitude   float64
+	CreatedAt   time.Time
+}
+
+// eventKeywords maps official (Indonesian) event types to words a reporter
+// would likely use for the same event, in Indonesian or English
+var eventKeywords = map[string][]string{
+	"banjir":     {"banjir", "flood"},
+	"gempa":      {"gempa", "earthquake", "quake"},
+	"longsor":    {"longsor", "landslide", "mudslide"},
+	"kebakaran":  {"kebakaran", "karhutla", "fire", "wildfire"},
+	"puting":     {"puting beliung", "angin kencang", "tornado", "storm", "badai"},
+	"cuaca":      {"cuaca ekstrem", "angin", "storm", "badai"},
+	"erupsi":     {"erupsi", "letusan", "gunung", "eruption", "volcano"},
+	"tsunami":    {"tsunami"},
+	"kekeringan": {"kekeringan", "drought"},
+	"gelombang":  {"gelombang", "abrasi", "rob", "tidal", "wave"},
+}
+
+// Evaluate scores each event against the report: up to 0.5 for proximity,
+// 0.3 for timing and 0.2 when the event type matches the report's wording
+func Evaluate(report Report, events []Event) []Match {
+	text := strings.ToLower(report.Title + " " + report.Description)
+
+	matches := []Match{}
+	for _, e := range events {
+		distance := distanceKm(report.Latitude, report.Longitude, e.Latitude, e.Longitude)
+		days := math.Abs(e.Date.Sub(report.CreatedAt).Hours()) / 24
+		windowDays := searchWindow.Hours() / 24
+		if distance > searchRadiusKm || days > windowDays+1 {
+			continue
+		}
+
+		m := Match{Event: e, DistanceKm: math.Round(distance*10) / 10, DaysApart: math.Round(days*10) / 10}
+		m.TypeMatch = typeMatches(e.Type, text)
+		m.Score = 0.5*(1-distance/searchRadiusKm) + 0.3*math.Max(0, 1-days/(windowDays+1))
+		if m.TypeMatch {
+			m.Score += 0.2
+		}
+		m.Score = math.Round(m.Score*1000) / 1000
+		matches = append(matches, m)
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > maxMatches {
+		matches = matches[:maxMatches]
+	}
+	return matches
+}
+
+func typeMatches(eventType, reportText string) bool {
+	eventType = strings.ToLower(eventType)
+	for key, words := range eventKeywords {
+		if !strings.Contains(eventType, key) {
+			continue
+		}
+		for _, word := range words {
+			if strings.Contains(reportText, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check runs the comparison for one report and stores the outcome
+func Check(ctx context.Context, db *sql.DB, source Source, reportID string) error {
+	var report Report
+	if err := db.QueryRowContext(ctx,
+		`SELECT title, description, latitude, longitude, created_at
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?)`,
+		reportID,
+	).Scan(&report.Title, &report.Description, &report.Latitude, &report.Longitude, &report.CreatedAt); err != nil {
+		return err
+	}
+
+	events, err := source.Search(ctx, Query{
+		Latitude:  report.Latitude,
+		Longitude: report.Longitude,
+		RadiusKm:  searchRadiusKm,
+		From:      report.CreatedAt.Add(-searchWindow),
+		To:        report.CreatedAt.Add(searchWindow),
+	})
+	if err != nil {
+		lastError := err.Error()
+		if len(lastError) > 500 {
+			lastError = lastError[:500]
+		}
+		db.ExecContext(ctx,
+			`INSERT INTO report_crosschecks (report_id, source, status, last_error, checked_at)
+			VALUES (UUID_TO_BIN(?), ?, 'failed', ?, NOW())
+			ON DUPLICATE KEY UPDATE status = 'failed', last_error = VALUES(last_error), checked_at = NOW()`,
+			reportID, source.Name(), lastError,
+		)
+		return err
+	}
+
+	matches := Evaluate(report, events)
+	status, best := "no_match", 0.0
+	if len(matches) > 0 {
+		best = matches[0].Score
+		if best >= matchThreshold {
+			status = "matched"
+		}
+	}
+	encoded, _ := json.Marshal(matches)
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO report_crosschecks (report_id, source, status, best_score, matches, checked_at)
+		VALUES (UUID_TO_BIN(?), ?, ?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE source = VALUES(source), status = VALUES(status),
+		best_score = VALUES(best_score), matches = VALUES(matches), last_error = NULL, checked_at = NOW()`,
+		reportID, source.Name(), status, best, encoded,
+	)
+	return err
+}
+
+// Start checks pending reports that have not been compared yet, retrying
+// failed lookups after an hour
+func Start(db *sql.DB, source Source, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rows, err := db.Query(
+				`SELECT BIN_TO_UUID(dr.id) FROM disaster_reports dr
+				LEFT JOIN report_crosschecks rc ON rc.report_id = dr.id
+				WHERE dr.status = 'pending' AND dr.deleted_at IS NULL
+				AND (rc.report_id IS NULL OR (rc.status = 'failed' AND rc.checked_at < NOW() - INTERVAL 1 HOUR))
+				ORDER BY dr.created_at LIMIT ?`,
+				sweepBatch,
+			)
+			if err != nil {
+				log.Printf("Failed to load reports for cross-check: %v", err)
+				continue
+			}
+			var ids []string
+			for rows.Next() {
+				var id string
+				if rows.Scan(&id) == nil {
+					ids = append(ids, id)
+				}
+			}
+			rows.Close()
+
+			for _, id := range ids {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				if err := Check(ctx, db, source, id); err != nil {
+					log.Printf("Cross-check of report %s against %s failed: %v", id, source.Name(), err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRad := func(d float64) float64 { return d * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
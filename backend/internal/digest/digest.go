@@ -0,0 +1,246 @@
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"saferelief/internal/notify"
+)
+
+// Period is how far back each digest looks and how often it is sent
+const Period = 7 * 24 * time.Hour
+
+// Preferences select which digest sections a user receives
+type Preferences struct {
+	Enabled         bool `json:"enabled"`
+	NewDisasters    bool `json:"newDisasters"`
+	FundingProgress bool `json:"fundingProgress"`
+	DeliveryUpdates bool `json:"deliveryUpdates"`
+}
+
+type Disaster struct {
+	ReportID   string    `json:"reportId"`
+	Title      string    `json:"title"`
+	Severity   string    `json:"severity"`
+	Region     string    `json:"region"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+type Funding struct {
+	ReportID     string  `json:"reportId"`
+	Title        string  `json:"title"`
+	Currency     string  `json:"currency"`
+	TotalRaised  float64 `json:"totalRaised"`
+	RaisedPeriod float64 `json:"raisedThisWeek"`
+}
+
+type Delivery struct {
+	DonationID string    `json:"donationId"`
+	Title      string    `json:"reportTitle"`
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	Status     string    `json:"status"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Digest is one user's summary of the period ending at Until
+type Digest struct {
+	Since           time.Time  `json:"since"`
+	Until           time.Time  `json:"until"`
+	NewDisasters    []Disaster `json:"newDisasters"`
+	FundingProgress []Funding  `json:"fundingProgress"`
+	DeliveryUpdates []Delivery `json:"deliveryUpdates"`
+}
+
+func (d *Digest) Empty() bool {
+	return len(d.NewDisasters) == 0 && len(d.FundingProgress) == 0 && len(d.DeliveryUpdates) == 0
+}
+
+// LoadPreferences returns the user's digest settings; users who never set
+// them are unsubscribed
+func LoadPreferences(ctx context.Context, db *sql.DB, userID string) (Preferences, error) {
+	var p Preferences
+	err := db.QueryRowContext(ctx,
+		`SELECT enabled, new_disasters, funding_progress, delivery_updates
+		FROM digest_subscriptions WHERE user_id = UUID_TO_BIN(?)`,
+		userID,
+	).Scan(&p.Enabled, &p.NewDisasters, &p.FundingProgress, &p.DeliveryUpdates)
+	if err == sql.ErrNoRows {
+		return Preferences{NewDisasters: true, FundingProgress: true, DeliveryUpdates: true}, nil
+	}
+	return p, err
+}
+
+// Compile gathers the sections the user has enabled for the period since
+func Compile(ctx context.Context, db *sql.DB, userID string, prefs Preferences, since time.Time) (*Digest, error) {
+	d := &Digest{
+		Since:           since.UTC(),
+		Until:           time.Now().UTC(),
+		NewDisasters:    []Disaster{},
+		FundingProgress: []Funding{},
+		DeliveryUpdates: []Delivery{},
+	}
+
+	if prefs.NewDisasters {
+		// A report near several followed regions is listed once, under the nearest
+		rows, err := db.QueryContext(ctx,
+			`SELECT report_id, title, severity, region, verified_at FROM (
+				SELECT BIN_TO_UUID(dr.id) AS report_id, dr.title, dr.severity, fr.name AS region,
+				dr.verified_at,
+				ROW_NUMBER() OVER (PARTITION BY dr.id ORDER BY
+					ST_Distance_Sphere(dr.location, ST_SRID(POINT(fr.longitude, fr.latitude), 4326))) AS rn
+				FROM followed_regions fr
+				JOIN disaster_reports dr ON dr.status != 'rejected' AND dr.deleted_at IS NULL AND dr.hidden_at IS NULL AND dr.verified_at >= ?
+				AND ST_Distance_Sphere(dr.location, ST_SRID(POINT(fr.longitude, fr.latitude), 4326)) <= fr.radius_km * 1000
+				WHERE fr.user_id = UUID_TO_BIN(?)
+			) nearby WHERE rn = 1
+			ORDER BY verified_at DESC LIMIT 20`,
+			since, userID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var item Disaster
+			if err := rows.Scan(&item.ReportID, &item.Title, &item.Severity, &item.Region, &item.VerifiedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			d.NewDisasters = append(d.NewDisasters, item)
+		}
+		rows.Close()
+	}
+
+	if prefs.FundingProgress {
+		rows, err := db.QueryContext(ctx,
+			`SELECT BIN_TO_UUID(dr.id), dr.title, d.currency,
+			SUM(d.amount), SUM(IF(d.updated_at >= ?, d.amount, 0))
+			FROM donations d
+			JOIN disaster_reports dr ON dr.id = d.disaster_report_id
+			WHERE d.status = 'completed' AND dr.deleted_at IS NULL AND d.disaster_report_id IN (
+				SELECT disaster_report_id FROM donations WHERE donor_id = UUID_TO_BIN(?)
+			)
+			GROUP BY dr.id, dr.title, d.currency
+			HAVING SUM(IF(d.updated_at >= ?, d.amount, 0)) > 0
+			ORDER BY dr.title LIMIT 20`,
+			since, userID, since,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var item Funding
+			if err := rows.Scan(&item.ReportID, &item.Title, &item.Currency, &item.TotalRaised, &item.RaisedPeriod); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			d.FundingProgress = append(d.FundingProgress, item)
+		}
+		rows.Close()
+	}
+
+	if prefs.DeliveryUpdates {
+		rows, err := db.QueryContext(ctx,
+			`SELECT BIN_TO_UUID(d.id), dr.title, d.amount, d.currency, d.status, d.updated_at
+			FROM donations d
+			JOIN disaster_reports dr ON dr.id = d.disaster_report_id
+			WHERE d.donor_id = UUID_TO_BIN(?) AND d.status != 'pending' AND dr.deleted_at IS NULL AND d.updated_at >= ?
+			ORDER BY d.updated_at DESC LIMIT 20`,
+			userID, since,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var item Delivery
+			if err := rows.Scan(&item.DonationID, &item.Title, &item.Amount, &item.Currency, &item.Status, &item.UpdatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			d.DeliveryUpdates = append(d.DeliveryUpdates, item)
+		}
+		rows.Close()
+	}
+
+	return d, nil
+}
+
+// Start sends each subscribed user their digest once per Period, checking
+// for users who are due every interval
+func Start(db *sql.DB, mailer *notify.Mailer, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sendDue(db, mailer)
+		}
+	}()
+}
+
+type recipient struct {
+	id, email, locale, timezone string
+	lastSent                    sql.NullTime
+}
+
+func sendDue(db *sql.DB, mailer *notify.Mailer) {
+	rows, err := db.Query(
+		`SELECT BIN_TO_UUID(u.id), u.email, u.locale, u.timezone, ds.last_sent_at
+		FROM digest_subscriptions ds
+		JOIN users u ON u.id = ds.user_id
+		WHERE ds.enabled = TRUE AND u.status = 'active' AND u.deleted_at IS NULL
+		AND (ds.last_sent_at IS NULL OR ds.last_sent_at <= ?)
+		LIMIT 500`,
+		time.Now().Add(-Period),
+	)
+	if err != nil {
+		log.Printf("Digest query failed: %v", err)
+		return
+	}
+	var due []recipient
+	for rows.Next() {
+		var r recipient
+		if err := rows.Scan(&r.id, &r.email, &r.locale, &r.timezone, &r.lastSent); err == nil {
+			due = append(due, r)
+		}
+	}
+	rows.Close()
+
+	for _, r := range due {
+		if err := send(db, mailer, r); err != nil {
+			log.Printf("Failed to send digest to user %s: %v", r.id, err)
+		}
+	}
+}
+
+func send(db *sql.DB, mailer *notify.Mailer, r recipient) error {
+	ctx := context.Background()
+	prefs, err := LoadPreferences(ctx, db, r.id)
+	if err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-Period)
+	if r.lastSent.Valid && r.lastSent.Time.After(since) {
+		since = r.lastSent.Time
+	}
+	d, err := Compile(ctx, db, r.id, prefs, since)
+	if err != nil {
+		return err
+	}
+
+	// Quiet weeks still advance last_sent_at so users aren't emailed an empty digest
+	if !d.Empty() {
+		to := notify.Recipient{Email: r.email, Locale: r.locale, Timezone: r.timezone}
+		if err := mailer.SendTemplate(ctx, to, "digest.weekly", d); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(
+		"UPDATE digest_subscriptions SET last_sent_at = NOW() WHERE user_id = UUID_TO_BIN(?)",
+		r.id,
+	)
+	return err
+}
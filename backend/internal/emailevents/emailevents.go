@@ -0,0 +1,107 @@
+// Package emailevents parses and authenticates bounce and complaint
+// notifications from email providers
+package emailevents
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type Kind string
+
+const (
+	HardBounce Kind = "hard_bounce"
+	Complaint  Kind = "complaint"
+)
+
+// Event is a delivery problem that should stop mail to an address. Soft
+// bounces are not reported; the provider retries those itself.
+type Event struct {
+	Email  string
+	Kind   Kind
+	Detail string
+}
+
+// ParseSES reads an SES notification (the Message of an SNS notification),
+// in either the notification or event-publishing format
+func ParseSES(message []byte) ([]Event, error) {
+	var n struct {
+		NotificationType string `json:"notificationType"`
+		EventType        string `json:"eventType"`
+		Bounce           struct {
+			BounceType        string `json:"bounceType"`
+			BounceSubType     string `json:"bounceSubType"`
+			BouncedRecipients []struct {
+				EmailAddress   string `json:"emailAddress"`
+				DiagnosticCode string `json:"diagnosticCode"`
+			} `json:"bouncedRecipients"`
+		} `json:"bounce"`
+		Complaint struct {
+			ComplaintFeedbackType string `json:"complaintFeedbackType"`
+			ComplainedRecipients  []struct {
+				EmailAddress string `json:"emailAddress"`
+			} `json:"complainedRecipients"`
+		} `json:"complaint"`
+	}
+	if err := json.Unmarshal(message, &n); err != nil {
+		return nil, err
+	}
+
+	kind := n.NotificationType
+	if kind == "" {
+		kind = n.EventType
+	}
+
+	var events []Event
+	switch kind {
+	case "Bounce":
+		if n.Bounce.BounceType != "Permanent" {
+			return nil, nil
+		}
+		for _, r := range n.Bounce.BouncedRecipients {
+			detail := r.DiagnosticCode
+			if detail == "" {
+				detail = n.Bounce.BounceSubType
+			}
+			events = append(events, Event{Email: normalize(r.EmailAddress), Kind: HardBounce, Detail: detail})
+		}
+	case "Complaint":
+		for _, r := range n.Complaint.ComplainedRecipients {
+			events = append(events, Event{
+				Email:  normalize(r.EmailAddress),
+				Kind:   Complaint,
+				Detail: n.Complaint.ComplaintFeedbackType,
+			})
+		}
+	}
+	return events, nil
+}
+
+// ParseSendGrid reads a batch from the SendGrid Event Webhook
+func ParseSendGrid(body []byte) ([]Event, error) {
+	var batch []struct {
+		Email  string `json:"email"`
+		Event  string `json:"event"`
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, e := range batch {
+		switch {
+		// "blocked" bounces are temporary rejections
+		case e.Event == "bounce" && e.Type != "blocked":
+			events = append(events, Event{Email: normalize(e.Email), Kind: HardBounce, Detail: e.Reason})
+		case e.Event == "spamreport":
+			events = append(events, Event{Email: normalize(e.Email), Kind: Complaint, Detail: "spamreport"})
+		}
+	}
+	return events, nil
+}
+
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"saferelief/internal/jobs"
+)
+
+// SchemaVersion is bumped whenever the envelope or an event's data changes
+// incompatibly so consumers can route old and new payloads separately
+const SchemaVersion = 1
+
+const (
+	source      = "saferelief-api"
+	maxAttempts = 5
+)
+
+// Envelope is the JSON document published for every domain event
+type Envelope struct {
+	ID            string      `json:"id"`
+	Type          string      `json:"type"`
+	SchemaVersion int         `json:"schemaVersion"`
+	Source        string      `json:"source"`
+	OccurredAt    time.Time   `json:"occurredAt"`
+	Key           string      `json:"key,omitempty"`
+	Data          interface{} `json:"data"`
+}
+
+// Transport delivers an encoded envelope to a broker topic or subject
+type Transport interface {
+	Publish(ctx context.Context, topic, key string, payload []byte) error
+}
+
+// Stream publishes report.*, donation.* and user.* events to an external
+// broker. A nil Stream is valid and drops everything, so callers never need
+// to check whether streaming is configured.
+type Stream struct {
+	transport Transport
+	queue     *jobs.Queue
+	prefix    string
+}
+
+// NewStreamFromEnv builds a stream from EVENT_STREAM ("nats" or "kafka"),
+// EVENT_STREAM_URL and EVENT_STREAM_PREFIX. It returns nil when
+// EVENT_STREAM is unset.
+func NewStreamFromEnv(queue *jobs.Queue) (*Stream, error) {
+	kind := os.Getenv("EVENT_STREAM")
+	if kind == "" {
+		return nil, nil
+	}
+	rawURL := os.Getenv("EVENT_STREAM_URL")
+	if rawURL == "" {
+		return nil, fmt.Errorf("EVENT_STREAM_URL is required when EVENT_STREAM is set")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_STREAM_URL: %w", err)
+	}
+
+	var transport Transport
+	switch kind {
+	case "nats":
+		transport, err = NewNATS(u)
+	case "kafka":
+		transport, err = NewKafkaREST(u)
+	default:
+		err = fmt.Errorf("unknown EVENT_STREAM %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := os.Getenv("EVENT_STREAM_PREFIX")
+	if prefix == "" {
+		prefix = "saferelief"
+	}
+	return &Stream{transport: transport, queue: queue, prefix: prefix}, nil
+}
+
+// Emit queues an event for publishing; key groups events about the same
+// entity so brokers keep them in order. Failures are logged, never returned.
+func (s *Stream) Emit(eventType, key string, data interface{}) {
+	if s == nil {
+		return
+	}
+
+	envelope := Envelope{
+		ID:            newID(),
+		Type:          eventType,
+		SchemaVersion: SchemaVersion,
+		Source:        source,
+		OccurredAt:    time.Now().UTC(),
+		Key:           key,
+		Data:          data,
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("Failed to encode stream event %s: %v", eventType, err)
+		return
+	}
+
+	topic := s.prefix + "." + eventType
+	err = s.queue.Enqueue(jobs.Job{
+		Name:        "stream-event-" + envelope.ID,
+		MaxAttempts: maxAttempts,
+		Run: func(ctx context.Context) error {
+			return s.transport.Publish(ctx, topic, key, payload)
+		},
+		OnFailure: func(err error) {
+			log.Printf("Dropped stream event %s (%s): %v", envelope.ID, eventType, err)
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue stream event %s: %v", eventType, err)
+	}
+}
+
+// IsDomainEvent reports whether an event name belongs on the stream;
+// security notifications stay with the account owner's webhooks
+func IsDomainEvent(eventType string) bool {
+	for _, prefix := range []string{"report.", "donation.", "user."} {
+		if strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaREST produces records through a Kafka REST Proxy (v2 API), which
+// keeps the binary Kafka protocol and its client library out of the API
+// server
+type KafkaREST struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewKafkaREST accepts the proxy's http(s) base URL; credentials in the URL
+// are sent as basic auth
+func NewKafkaREST(u *url.URL) (*KafkaREST, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("Kafka REST proxy URL must use http:// or https://")
+	}
+	k := &KafkaREST{client: &http.Client{Timeout: 10 * time.Second}}
+	if u.User != nil {
+		k.username = u.User.Username()
+		k.password, _ = u.User.Password()
+	}
+	base := *u
+	base.User = nil
+	k.baseURL = strings.TrimRight(base.String(), "/")
+	return k, nil
+}
+
+func (k *KafkaREST) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	record := map[string]interface{}{"value": json.RawMessage(payload)}
+	if key != "" {
+		record["key"] = key
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []interface{}{record},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		k.baseURL+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.username != "" {
+		req.SetBasicAuth(k.username, k.password)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Kafka REST proxy returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	// The proxy reports per-record failures inside a 200 response
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if json.Unmarshal(respBody, &result) == nil {
+		for _, offset := range result.Offsets {
+			if offset.ErrorCode != nil {
+				return fmt.Errorf("Kafka REST proxy rejected record: %s", offset.Error)
+			}
+		}
+	}
+	return nil
+}
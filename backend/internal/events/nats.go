@@ -0,0 +1,165 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS publishes over the core NATS text protocol. It keeps a single
+// connection, answers server PINGs, and redials on the next publish after
+// the connection drops.
+type NATS struct {
+	addr    string
+	useTLS  bool
+	options map[string]interface{}
+
+	mu     sync.Mutex
+	conn   net.Conn
+	writer *bufio.Writer
+}
+
+// NewNATS accepts nats://[user:pass@]host:port or tls://... URLs; a user
+// without a password is sent as an auth token
+func NewNATS(u *url.URL) (*NATS, error) {
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("NATS URL must use nats:// or tls://")
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     source,
+		"lang":     "go",
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			options["user"] = u.User.Username()
+			options["pass"] = pass
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	return &NATS{addr: addr, useTLS: u.Scheme == "tls", options: options}, nil
+}
+
+func (n *NATS) Publish(ctx context.Context, subject, _ string, payload []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		n.conn.SetWriteDeadline(deadline)
+	}
+	fmt.Fprintf(n.writer, "PUB %s %d\r\n", subject, len(payload))
+	n.writer.Write(payload)
+	n.writer.WriteString("\r\n")
+	if err := n.writer.Flush(); err != nil {
+		n.closeLocked()
+		return err
+	}
+	return nil
+}
+
+func (n *NATS) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return errors.New("NATS server did not send INFO")
+	}
+
+	if n.useTLS || strings.Contains(line, `"tls_required":true`) {
+		host, _, _ := net.SplitHostPort(n.addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	options, _ := json.Marshal(n.options)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", options); err != nil {
+		conn.Close()
+		return err
+	}
+	// The server answers PONG once CONNECT is accepted, or -ERR otherwise
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return fmt.Errorf("NATS: %s", strings.TrimSpace(line))
+		}
+		if strings.HasPrefix(line, "PONG") {
+			break
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	n.conn = conn
+	n.writer = bufio.NewWriter(conn)
+	go n.readLoop(conn, reader)
+	return nil
+}
+
+// readLoop keeps the connection alive by answering PINGs and drops it on
+// any server error so the next publish reconnects
+func (n *NATS) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err == nil && strings.HasPrefix(line, "PING") {
+			n.mu.Lock()
+			if n.conn == conn {
+				n.writer.WriteString("PONG\r\n")
+				err = n.writer.Flush()
+			}
+			n.mu.Unlock()
+		}
+		if err != nil || strings.HasPrefix(line, "-ERR") {
+			n.mu.Lock()
+			if n.conn == conn {
+				n.closeLocked()
+			}
+			n.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (n *NATS) closeLocked() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.conn = nil
+	n.writer = nil
+}
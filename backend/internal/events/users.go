@@ -0,0 +1,49 @@
+package events
+
+import "saferelief/internal/audit"
+
+// userEvents maps account lifecycle audit events to stream events
+var userEvents = map[string]string{
+	audit.EventUserRegistered:    "user.registered",
+	audit.EventEmailChanged:      "user.email_changed",
+	audit.EventRoleChanged:       "user.role_changed",
+	audit.EventUserSuspended:     "user.suspended",
+	audit.EventUserBanned:        "user.banned",
+	audit.EventSuspensionLifted:  "user.reinstated",
+	audit.EventAccountDeleted:    "user.deleted",
+	audit.EventAccountRestored:   "user.restored",
+	audit.EventAccountAnonymized: "user.anonymized",
+}
+
+// ForwardUserEvents publishes account lifecycle events from the audit log.
+// Only identifiers and the event time leave the process; addresses, user
+// agents and audit details stay in the audit log.
+func ForwardUserEvents(logger *audit.Logger, s *Stream) {
+	if s == nil {
+		return
+	}
+	subscription := logger.Subscribe()
+	go func() {
+		for event := range subscription {
+			name, ok := userEvents[event.Type]
+			if !ok {
+				continue
+			}
+			userID := event.UserID
+			if event.EntityType == "user" && event.EntityID != "" {
+				userID = event.EntityID
+			}
+			if userID == "" {
+				continue
+			}
+			data := map[string]interface{}{"userId": userID}
+			if event.UserID != "" && event.UserID != userID {
+				data["actorId"] = event.UserID
+			}
+			if role, ok := event.Details["role"]; ok {
+				data["role"] = role
+			}
+			s.Emit(name, userID, data)
+		}
+	}()
+}
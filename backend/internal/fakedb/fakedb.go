@@ -0,0 +1,132 @@
+// Package fakedb is a database/sql driver for tests that cannot reach MySQL
+package fakedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Rule answers every statement containing Match. Queries get Rows,
+// with Columns named after their position when unset; Err fails the
+// statement instead. Args, when set, is handed each matching statement's
+// arguments.
+type Rule struct {
+	Match   string
+	Columns []string
+	Rows    [][]driver.Value
+	Err     error
+	Args    func(args []driver.NamedValue)
+}
+
+// DB answers statements with the first matching rule, and a statement no
+// rule matches fails the test
+type DB struct {
+	t     *testing.T
+	mu    sync.Mutex
+	rules []Rule
+	// Log holds every statement run, with "COMMIT" and "ROLLBACK" for
+	// transactions
+	Log []string
+}
+
+func New(t *testing.T, rules ...Rule) (*sql.DB, *DB) {
+	f := &DB{t: t, rules: rules}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+
+// Ran reports whether any statement containing fragment was run
+func (f *DB) Ran(fragment string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, stmt := range f.Log {
+		if strings.Contains(stmt, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *DB) answer(query string, args []driver.NamedValue) (Rule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Log = append(f.Log, query)
+	for _, rule := range f.rules {
+		if strings.Contains(query, rule.Match) {
+			if rule.Args != nil {
+				rule.Args(args)
+			}
+			return rule, rule.Err
+		}
+	}
+	f.t.Errorf("unexpected statement: %s", query)
+	return Rule{}, fmt.Errorf("unexpected statement")
+}
+
+func (f *DB) record(stmt string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Log = append(f.Log, stmt)
+}
+
+func (f *DB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *DB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *DB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fakedb does not prepare statements")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{db: c.db}, nil }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rule, err := c.db.answer(query, args)
+	if err != nil {
+		return nil, err
+	}
+	columns := rule.Columns
+	if columns == nil && len(rule.Rows) > 0 {
+		for i := range rule.Rows[0] {
+			columns = append(columns, fmt.Sprintf("c%d", i))
+		}
+	}
+	return &fakeRows{columns: columns, rows: rule.Rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, err := c.db.answer(query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{ db *DB }
+
+func (tx *fakeTx) Commit() error   { tx.db.record("COMMIT"); return nil }
+func (tx *fakeTx) Rollback() error { tx.db.record("ROLLBACK"); return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,105 @@
+package feeds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BMKG reads the Indonesian meteorology and geophysics agency's list of
+// recent magnitude 5+ earthquakes,
+// https://data.bmkg.go.id/DataMKG/TEWS/gempaterkini.json. Weather warnings
+// are published as CAP XML per province and are not read yet.
+type BMKG struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewBMKGFromEnv returns nil when FEED_BMKG_URL is unset
+func NewBMKGFromEnv() *BMKG {
+	endpoint := os.Getenv("FEED_BMKG_URL")
+	if endpoint == "" {
+		return nil
+	}
+	return &BMKG{endpoint: endpoint, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (b *BMKG) Name() string {
+	return "bmkg"
+}
+
+// bmkgQuake uses BMKG's field names; every value is a string
+type bmkgQuake struct {
+	DateTime    string `json:"DateTime"`
+	Coordinates string `json:"Coordinates"`
+	Magnitude   string `json:"Magnitude"`
+	Kedalaman   string `json:"Kedalaman"`
+	Wilayah     string `json:"Wilayah"`
+	Potensi     string `json:"Potensi"`
+}
+
+func (b *BMKG) Fetch(ctx context.Context) ([]Alert, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("BMKG responded with %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Infogempa struct {
+			Gempa []bmkgQuake `json:"gempa"`
+		} `json:"Infogempa"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 5<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid BMKG response: %w", err)
+	}
+
+	alerts := make([]Alert, 0, len(body.Infogempa.Gempa))
+	for _, q := range body.Infogempa.Gempa {
+		occurredAt, err := time.Parse(time.RFC3339, q.DateTime)
+		if err != nil {
+			continue
+		}
+		coords := strings.Split(q.Coordinates, ",")
+		if len(coords) != 2 {
+			continue
+		}
+		latitude, errLat := strconv.ParseFloat(strings.TrimSpace(coords[0]), 64)
+		longitude, errLon := strconv.ParseFloat(strings.TrimSpace(coords[1]), 64)
+		magnitude, errMag := strconv.ParseFloat(strings.TrimSpace(q.Magnitude), 64)
+		if errLat != nil || errLon != nil || errMag != nil {
+			continue
+		}
+		// Potensi reads "Tidak berpotensi tsunami" or "Berpotensi tsunami"
+		potential := strings.ToLower(q.Potensi)
+		alerts = append(alerts, Alert{
+			// BMKG gives no event ID; the origin time is unique in practice
+			ID:          occurredAt.UTC().Format(time.RFC3339),
+			Type:        "earthquake",
+			Title:       fmt.Sprintf("M %.1f - %s", magnitude, q.Wilayah),
+			Description: fmt.Sprintf("Magnitude %.1f earthquake, %s, at a depth of %s, reported by BMKG. %s.", magnitude, q.Wilayah, q.Kedalaman, q.Potensi),
+			Latitude:    latitude,
+			Longitude:   longitude,
+			Magnitude:   magnitude,
+			Tsunami:     strings.Contains(potential, "tsunami") && !strings.Contains(potential, "tidak"),
+			OccurredAt:  occurredAt.UTC(),
+			URL:         "https://www.bmkg.go.id/gempabumi/gempabumi-terkini.bmkg",
+		})
+	}
+	return alerts, nil
+}
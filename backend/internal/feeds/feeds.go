@@ -0,0 +1,119 @@
+// Package feeds reads official earthquake alerts so the system can file
+// them as candidate disaster reports for verifiers to confirm.
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alert is one event from an agency feed
+type Alert struct {
+	// ID is the event's identifier within its feed
+	ID string
+	// Type is a disaster_types code
+	Type        string
+	Title       string
+	Description string
+	Latitude    float64
+	Longitude   float64
+	Magnitude   float64
+	// Tsunami is set when the agency warns of a possible tsunami
+	Tsunami    bool
+	OccurredAt time.Time
+	URL        string
+}
+
+// Feed lists an agency's recent alerts
+type Feed interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Alert, error)
+}
+
+// Severity maps an alert to a report severity by magnitude; a tsunami
+// warning is always critical
+func Severity(a Alert) string {
+	switch {
+	case a.Tsunami || a.Magnitude >= 7:
+		return "critical"
+	case a.Magnitude >= 6:
+		return "high"
+	case a.Magnitude >= 5:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// Filter decides which alerts are worth a report
+type Filter struct {
+	MinMagnitude float64
+	// Bounds is min longitude, min latitude, max longitude, max latitude;
+	// nil accepts alerts anywhere
+	Bounds *[4]float64
+}
+
+// Allows reports whether an alert passes the filter. Tsunami warnings pass
+// whatever their magnitude.
+func (f Filter) Allows(a Alert) bool {
+	if a.Magnitude < f.MinMagnitude && !a.Tsunami {
+		return false
+	}
+	if f.Bounds != nil {
+		b := f.Bounds
+		if a.Longitude < b[0] || a.Latitude < b[1] || a.Longitude > b[2] || a.Latitude > b[3] {
+			return false
+		}
+	}
+	return true
+}
+
+const defaultMinMagnitude = 5.0
+
+// ParseFilter reads FEED_MIN_MAGNITUDE and FEED_BOUNDS
+// (minLon,minLat,maxLon,maxLat). Empty values take the default magnitude
+// and no bounds.
+func ParseFilter(minMagnitude, bounds string) (Filter, error) {
+	f := Filter{MinMagnitude: defaultMinMagnitude}
+	if raw := strings.TrimSpace(minMagnitude); raw != "" {
+		m, err := strconv.ParseFloat(raw, 64)
+		if err != nil || m < 0 {
+			return f, fmt.Errorf("FEED_MIN_MAGNITUDE must be a non-negative number, got %q", minMagnitude)
+		}
+		f.MinMagnitude = m
+	}
+	if raw := strings.TrimSpace(bounds); raw != "" {
+		parts := strings.Split(raw, ",")
+		if len(parts) != 4 {
+			return f, fmt.Errorf("FEED_BOUNDS must be minLon,minLat,maxLon,maxLat, got %q", bounds)
+		}
+		var b [4]float64
+		for i, part := range parts {
+			value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return f, fmt.Errorf("FEED_BOUNDS must be minLon,minLat,maxLon,maxLat, got %q", bounds)
+			}
+			b[i] = value
+		}
+		if b[0] > b[2] || b[1] > b[3] {
+			return f, fmt.Errorf("FEED_BOUNDS minimums must not exceed maximums, got %q", bounds)
+		}
+		f.Bounds = &b
+	}
+	return f, nil
+}
+
+// FromEnv returns the feeds whose URLs are configured
+func FromEnv() []Feed {
+	var configured []Feed
+	if usgs := NewUSGSFromEnv(); usgs != nil {
+		configured = append(configured, usgs)
+	}
+	if bmkg := NewBMKGFromEnv(); bmkg != nil {
+		configured = append(configured, bmkg)
+	}
+	return configured
+}
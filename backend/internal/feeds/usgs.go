@@ -0,0 +1,100 @@
+package feeds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// USGS reads one of the USGS earthquake summary feeds in GeoJSON, such as
+// https://earthquake.usgs.gov/earthquakes/feed/v1.0/summary/4.5_hour.geojson.
+// The feeds are worldwide, so set FEED_BOUNDS to keep to the area served.
+type USGS struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewUSGSFromEnv returns nil when FEED_USGS_URL is unset
+func NewUSGSFromEnv() *USGS {
+	endpoint := os.Getenv("FEED_USGS_URL")
+	if endpoint == "" {
+		return nil
+	}
+	return &USGS{endpoint: endpoint, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (u *USGS) Name() string {
+	return "usgs"
+}
+
+// usgsFeature uses the GeoJSON summary format's field names
+type usgsFeature struct {
+	ID         string `json:"id"`
+	Properties struct {
+		Mag     *float64 `json:"mag"`
+		Place   string   `json:"place"`
+		Time    int64    `json:"time"`
+		URL     string   `json:"url"`
+		Title   string   `json:"title"`
+		Tsunami int      `json:"tsunami"`
+		Type    string   `json:"type"`
+	} `json:"properties"`
+	Geometry struct {
+		// Longitude, latitude and depth in km
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func (u *USGS) Fetch(ctx context.Context) ([]Alert, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/geo+json, application/json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("USGS responded with %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Features []usgsFeature `json:"features"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 5<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid USGS response: %w", err)
+	}
+
+	alerts := make([]Alert, 0, len(body.Features))
+	for _, f := range body.Features {
+		// Quarry blasts and explosions share the feed
+		if f.Properties.Type != "earthquake" || f.Properties.Mag == nil || len(f.Geometry.Coordinates) < 2 {
+			continue
+		}
+		description := fmt.Sprintf("Magnitude %.1f earthquake %s, reported by the USGS.", *f.Properties.Mag, f.Properties.Place)
+		if len(f.Geometry.Coordinates) > 2 {
+			description = fmt.Sprintf("Magnitude %.1f earthquake %s at a depth of %.0f km, reported by the USGS.",
+				*f.Properties.Mag, f.Properties.Place, f.Geometry.Coordinates[2])
+		}
+		alerts = append(alerts, Alert{
+			ID:          f.ID,
+			Type:        "earthquake",
+			Title:       f.Properties.Title,
+			Description: description,
+			Longitude:   f.Geometry.Coordinates[0],
+			Latitude:    f.Geometry.Coordinates[1],
+			Magnitude:   *f.Properties.Mag,
+			Tsunami:     f.Properties.Tsunami == 1,
+			OccurredAt:  time.UnixMilli(f.Properties.Time).UTC(),
+			URL:         f.Properties.URL,
+		})
+	}
+	return alerts, nil
+}
@@ -0,0 +1,105 @@
+// Package fx validates currencies against the ISO 4217 list in the
+// currencies table and converts donation amounts to rupiah and US dollars
+// with exchange rates refreshed from a rate provider, so donations in
+// different currencies can be added up, and keeps each report's amount
+// raised in rupiah toward its fundraising goal.
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// Currency is an ISO 4217 currency. MinorUnits is the number of decimals
+// amounts may have: 2 for rupiah and dollars, 0 for yen, 3 for dinars.
+type Currency struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	MinorUnits int    `json:"minorUnits"`
+	// PerUSD is the latest rate, nil until the provider has quoted one
+	PerUSD        *float64   `json:"perUsd"`
+	RateFetchedAt *time.Time `json:"rateFetchedAt"`
+}
+
+// List returns every currency with its latest rate, by code
+func List(ctx context.Context, db *sql.DB) ([]Currency, error) {
+	rows, err := db.QueryContext(ctx, currencySelect+" ORDER BY c.code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	currencies := []Currency{}
+	for rows.Next() {
+		c, err := scanCurrency(rows)
+		if err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, c)
+	}
+	return currencies, rows.Err()
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Lookup finds an ISO 4217 currency by its code, in any case
+func Lookup(ctx context.Context, db queryer, code string) (Currency, error) {
+	c, err := scanCurrency(db.QueryRowContext(ctx,
+		currencySelect+" WHERE c.code = ?", strings.ToUpper(strings.TrimSpace(code)),
+	))
+	if err == sql.ErrNoRows {
+		return c, ErrUnknownCurrency
+	}
+	return c, err
+}
+
+const currencySelect = `SELECT c.code, c.name, c.minor_units, r.units_per_usd, r.fetched_at
+	FROM currencies c LEFT JOIN exchange_rates r ON r.currency = c.code`
+
+func scanCurrency(row interface{ Scan(...interface{}) error }) (Currency, error) {
+	var c Currency
+	err := row.Scan(&c.Code, &c.Name, &c.MinorUnits, &c.PerUSD, &c.RateFetchedAt)
+	return c, err
+}
+
+// CheckAmount returns a message for the donor when amount has more decimals
+// than the currency uses
+func (c Currency) CheckAmount(amount float64) string {
+	scaled := amount * math.Pow10(c.MinorUnits)
+	if math.Abs(scaled-math.Round(scaled)) > 1e-6 {
+		if c.MinorUnits == 0 {
+			return fmt.Sprintf("%s amounts must be whole numbers", c.Code)
+		}
+		return fmt.Sprintf("%s amounts can have at most %d decimals", c.Code, c.MinorUnits)
+	}
+	return ""
+}
+
+// normalize sets amount_idr and amount_usd from the current rates on the
+// donations matching where. Donations in a currency without a rate stay
+// NULL until a refresh brings one.
+const normalize = `UPDATE donations d
+	JOIN exchange_rates r ON r.currency = d.currency
+	JOIN exchange_rates idr ON idr.currency = 'IDR'
+	SET d.amount_usd = ROUND(d.amount / r.units_per_usd, 2),
+	d.amount_idr = ROUND(d.amount / r.units_per_usd * idr.units_per_usd, 2)
+	WHERE `
+
+// NormalizeDonation converts a new donation at today's rate, which it keeps
+// afterwards; call it in the transaction that inserts the donation
+func NormalizeDonation(ctx context.Context, db execer, donationID string) error {
+	_, err := db.ExecContext(ctx, normalize+"d.id = UUID_TO_BIN(?)", donationID)
+	return err
+}
@@ -0,0 +1,112 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	ecbURL               = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	openExchangeRatesURL = "https://openexchangerates.org/api/latest.json"
+)
+
+// ECB reads the European Central Bank's daily reference rates, about 30
+// currencies including IDR, quoted against the euro
+type ECB struct {
+	url    string
+	client *http.Client
+}
+
+func NewECB() *ECB {
+	return &ECB{url: ecbURL, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (e *ECB) Name() string {
+	return "ecb"
+}
+
+func (e *ECB) Rates(ctx context.Context) (map[string]float64, error) {
+	var envelope struct {
+		Rates []struct {
+			Currency string  `xml:"currency,attr"`
+			Rate     float64 `xml:"rate,attr"`
+		} `xml:"Cube>Cube>Cube"`
+	}
+	if err := fetch(ctx, e.client, e.url, func(body io.Reader) error {
+		return xml.NewDecoder(body).Decode(&envelope)
+	}); err != nil {
+		return nil, err
+	}
+
+	perEUR := map[string]float64{"EUR": 1}
+	for _, r := range envelope.Rates {
+		perEUR[r.Currency] = r.Rate
+	}
+	usd := perEUR["USD"]
+	if usd <= 0 {
+		return nil, fmt.Errorf("ECB rates have no USD quote")
+	}
+	rates := make(map[string]float64, len(perEUR))
+	for code, rate := range perEUR {
+		rates[code] = rate / usd
+	}
+	return rates, nil
+}
+
+// OpenExchangeRates reads openexchangerates.org's latest rates, which are
+// quoted against the dollar on every plan
+type OpenExchangeRates struct {
+	appID  string
+	url    string
+	client *http.Client
+}
+
+func NewOpenExchangeRates(appID string) *OpenExchangeRates {
+	return &OpenExchangeRates{appID: appID, url: openExchangeRatesURL, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (o *OpenExchangeRates) Name() string {
+	return "openexchangerates"
+}
+
+func (o *OpenExchangeRates) Rates(ctx context.Context) (map[string]float64, error) {
+	var result struct {
+		Base  string             `json:"base"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := fetch(ctx, o.client, o.url+"?app_id="+o.appID, func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&result)
+	}); err != nil {
+		return nil, err
+	}
+	if result.Base != "USD" || len(result.Rates) == 0 {
+		return nil, fmt.Errorf("openexchangerates returned no USD-based rates")
+	}
+	return result.Rates, nil
+}
+
+func fetch(ctx context.Context, client *http.Client, endpoint string, decode func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL may carry an app ID, so it is left out
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("rate provider unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rate provider responded with %d", resp.StatusCode)
+	}
+	return decode(io.LimitReader(resp.Body, 1<<20))
+}
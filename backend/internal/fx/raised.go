@@ -0,0 +1,33 @@
+package fx
+
+import "context"
+
+// recount sets amount_raised, the report's completed donations in rupiah,
+// on the reports matching where, then whether the goal is reached. MySQL
+// assigns left to right, so the goal checks see the new amount. A report
+// set to close fundraising at its goal closes when it gets there and opens
+// again if refunds take it back below.
+const recount = `UPDATE disaster_reports dr SET
+	dr.amount_raised = (SELECT COALESCE(SUM(d.amount_idr), 0) FROM donations d
+		WHERE d.disaster_report_id = dr.id AND d.status = 'completed'),
+	dr.goal_reached_at = IF(dr.goal_amount IS NOT NULL AND dr.amount_raised >= dr.goal_amount,
+		COALESCE(dr.goal_reached_at, NOW()), NULL),
+	dr.fundraising_closed_at = IF(dr.goal_auto_close AND dr.goal_reached_at IS NOT NULL,
+		COALESCE(dr.fundraising_closed_at, NOW()), NULL)
+	WHERE `
+
+// RecountReport updates a report's amount raised and goal; call it in the
+// transaction that changes its goal
+func RecountReport(ctx context.Context, db execer, reportID string) error {
+	_, err := db.ExecContext(ctx, recount+"dr.id = UUID_TO_BIN(?)", reportID)
+	return err
+}
+
+// RecountDonationReport updates the amount raised by a donation's report;
+// call it in the transaction that completes or refunds the donation
+func RecountDonationReport(ctx context.Context, db execer, donationID string) error {
+	_, err := db.ExecContext(ctx,
+		recount+"dr.id = (SELECT disaster_report_id FROM donations WHERE id = UUID_TO_BIN(?))", donationID,
+	)
+	return err
+}
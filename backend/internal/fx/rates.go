@@ -0,0 +1,101 @@
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Provider fetches current exchange rates as units of each currency per US
+// dollar
+type Provider interface {
+	Name() string
+	Rates(ctx context.Context) (map[string]float64, error)
+}
+
+// ProviderFromEnv picks the rate source from FX_PROVIDER: ecb (the default,
+// keyless) or openexchangerates, which needs OPENEXCHANGERATES_APP_ID and
+// covers more currencies
+func ProviderFromEnv() (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("FX_PROVIDER"))) {
+	case "", "ecb":
+		return NewECB(), nil
+	case "openexchangerates":
+		appID := os.Getenv("OPENEXCHANGERATES_APP_ID")
+		if appID == "" {
+			return nil, fmt.Errorf("OPENEXCHANGERATES_APP_ID is required for FX_PROVIDER=openexchangerates")
+		}
+		return NewOpenExchangeRates(appID), nil
+	default:
+		return nil, fmt.Errorf("FX_PROVIDER must be ecb or openexchangerates, got %q", os.Getenv("FX_PROVIDER"))
+	}
+}
+
+// Start refreshes rates now and then every interval until the process exits
+func Start(db *sql.DB, provider Provider, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if count, err := Refresh(ctx, db, provider); err != nil {
+				log.Printf("Failed to refresh exchange rates from %s: %v", provider.Name(), err)
+			} else {
+				log.Printf("Refreshed %d exchange rates from %s", count, provider.Name())
+			}
+			cancel()
+			<-ticker.C
+		}
+	}()
+}
+
+// Refresh stores the provider's rates for known currencies, then converts
+// donations still missing normalized amounts and recounts the reports they
+// were raised for. Rates already used by a donation are not revised; a
+// refresh only fills gaps.
+func Refresh(ctx context.Context, db *sql.DB, provider Provider) (int, error) {
+	rates, err := provider.Rates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	count := 0
+	for code, perUSD := range rates {
+		if perUSD <= 0 {
+			continue
+		}
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO exchange_rates (currency, units_per_usd, source, fetched_at)
+			SELECT code, ?, ?, NOW() FROM currencies WHERE code = ?
+			ON DUPLICATE KEY UPDATE units_per_usd = VALUES(units_per_usd), source = VALUES(source), fetched_at = NOW()`,
+			perUSD, provider.Name(), code,
+		)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			count++
+		}
+	}
+	result, err := tx.ExecContext(ctx, normalize+"d.amount_usd IS NULL")
+	if err != nil {
+		return 0, err
+	}
+	// Completed donations converted just now count toward their reports
+	if n, _ := result.RowsAffected(); n > 0 {
+		if _, err := tx.ExecContext(ctx, recount+`dr.amount_raised <> (SELECT COALESCE(SUM(d.amount_idr), 0)
+			FROM donations d WHERE d.disaster_report_id = dr.id AND d.status = 'completed')`); err != nil {
+			return 0, err
+		}
+	}
+	return count, tx.Commit()
+}
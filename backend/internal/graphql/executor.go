@@ -0,0 +1,483 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultMaxDepth      = 8
+	DefaultMaxComplexity = 2000
+
+	// defaultListSize is assumed for a list field without a limit argument
+	// when estimating complexity
+	defaultListSize = 20
+)
+
+// ResolveFunc resolves a field for every parent value at once and returns
+// one value per parent, in order. Resolving a whole level in one call is
+// what lets nested lists load with one query per field instead of one per
+// parent.
+type ResolveFunc func(ctx context.Context, parents []interface{}, args Args) ([]interface{}, error)
+
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+type Field struct {
+	// Type is set for object fields and nil for scalars
+	Type *Object
+	// List fields resolve to a []interface{} per parent
+	List bool
+	// Cost is the field's weight in the complexity estimate, 1 when unset
+	Cost int
+	// Permission, when set, is checked with the request's authorize function;
+	// a denied field resolves to null with an error
+	Permission  string
+	Description string
+	Resolve     ResolveFunc
+}
+
+type Schema struct {
+	Query         *Object
+	MaxDepth      int
+	MaxComplexity int
+}
+
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type Response struct {
+	Data   interface{} `json:"data"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+	Line    int           `json:"-"`
+}
+
+// Prop builds a scalar resolver reading one value from each parent
+func Prop(get func(parent interface{}) interface{}) ResolveFunc {
+	return func(ctx context.Context, parents []interface{}, args Args) ([]interface{}, error) {
+		values := make([]interface{}, len(parents))
+		for i, parent := range parents {
+			values[i] = get(parent)
+		}
+		return values, nil
+	}
+}
+
+// Execute validates and runs a query. authorize decides field permissions
+// for the caller.
+func (s *Schema) Execute(ctx context.Context, req Request, authorize func(permission string) bool) Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	if op.Type != "query" {
+		return Response{Errors: []Error{{Message: "only queries are supported; use the REST API for changes"}}}
+	}
+
+	variables := make(map[string]interface{})
+	for _, def := range op.Variables {
+		if value, ok := req.Variables[def.Name]; ok {
+			variables[def.Name] = value
+		} else if def.HasDef {
+			variables[def.Name] = def.Default
+		}
+	}
+
+	e := &execution{schema: s, doc: doc, variables: variables, authorize: authorize}
+	if errs := e.validate(op); len(errs) > 0 {
+		return Response{Errors: errs}
+	}
+
+	results := e.executeSet(ctx, s.Query, []interface{}{nil}, op.Selections, nil)
+	return Response{Data: results[0], Errors: e.errors}
+}
+
+func selectOperation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type execution struct {
+	schema    *Schema
+	doc       *Document
+	variables map[string]interface{}
+	authorize func(permission string) bool
+	errors    []Error
+}
+
+// validate rejects unknown fields and fragments, and queries deeper or more
+// expensive than the schema allows, before anything touches the database
+func (e *execution) validate(op *Operation) []Error {
+	maxDepth, maxComplexity := e.schema.MaxDepth, e.schema.MaxComplexity
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if maxComplexity <= 0 {
+		maxComplexity = DefaultMaxComplexity
+	}
+
+	// Every selection visited counts toward the limit as well, so fragments
+	// spread over and over cannot make the walk itself expensive
+	visits, tooComplex := 0, false
+
+	var errs []Error
+	var walk func(object *Object, selections []*Selection, depth int, visiting map[string]bool) int
+	walk = func(object *Object, selections []*Selection, depth int, visiting map[string]bool) int {
+		total := 0
+		for _, sel := range selections {
+			if visits++; visits > maxComplexity {
+				tooComplex = true
+				return total
+			}
+			if sel.Spread != "" {
+				fragment, ok := e.doc.Fragments[sel.Spread]
+				if !ok {
+					errs = append(errs, Error{Message: fmt.Sprintf("unknown fragment %q (line %d)", sel.Spread, sel.Line)})
+					continue
+				}
+				if visiting[sel.Spread] {
+					errs = append(errs, Error{Message: fmt.Sprintf("fragment %q spreads itself (line %d)", sel.Spread, sel.Line)})
+					continue
+				}
+				visiting[sel.Spread] = true
+				total += walk(object, fragment.Selections, depth, visiting)
+				delete(visiting, sel.Spread)
+				continue
+			}
+			if sel.Inline {
+				total += walk(object, sel.Selections, depth, visiting)
+				continue
+			}
+			if sel.Name == "__typename" {
+				continue
+			}
+
+			field, ok := object.Fields[sel.Name]
+			if !ok {
+				errs = append(errs, Error{Message: fmt.Sprintf("cannot query field %q on type %s (line %d)", sel.Name, object.Name, sel.Line)})
+				continue
+			}
+			if depth > maxDepth {
+				errs = append(errs, Error{Message: fmt.Sprintf("query is nested deeper than %d levels (line %d)", maxDepth, sel.Line)})
+				return total
+			}
+			switch {
+			case field.Type != nil && len(sel.Selections) == 0:
+				errs = append(errs, Error{Message: fmt.Sprintf("field %q of type %s needs a selection set (line %d)", sel.Name, field.Type.Name, sel.Line)})
+				continue
+			case field.Type == nil && len(sel.Selections) > 0:
+				errs = append(errs, Error{Message: fmt.Sprintf("field %q is a scalar and takes no selection set (line %d)", sel.Name, sel.Line)})
+				continue
+			}
+
+			cost := field.Cost
+			if cost <= 0 {
+				cost = 1
+			}
+			if field.Type != nil {
+				// Capped so a huge limit cannot overflow the estimate
+				children := min(walk(field.Type, sel.Selections, depth+1, visiting), maxComplexity+1)
+				if field.List {
+					children *= min(e.listSize(sel), maxComplexity+1)
+				}
+				cost += children
+			}
+			total += cost
+		}
+		return total
+	}
+
+	complexity := walk(e.schema.Query, op.Selections, 1, map[string]bool{})
+	switch {
+	case tooComplex:
+		errs = append(errs, Error{Message: fmt.Sprintf("query complexity exceeds the limit of %d; request fewer fields or smaller pages", maxComplexity)})
+	case len(errs) == 0 && complexity > maxComplexity:
+		errs = append(errs, Error{Message: fmt.Sprintf("query complexity %d exceeds the limit of %d; request fewer fields or smaller pages", complexity, maxComplexity)})
+	}
+	return errs
+}
+
+func (e *execution) listSize(sel *Selection) int {
+	args := e.arguments(sel)
+	for _, name := range []string{"limit", "first"} {
+		if n, ok := args.Int(name); ok && n > 0 {
+			return n
+		}
+	}
+	return defaultListSize
+}
+
+// executeSet resolves selections for every parent of one object type and
+// returns a result object per parent
+func (e *execution) executeSet(ctx context.Context, object *Object, parents []interface{}, selections []*Selection, path []interface{}) []*orderedObject {
+	results := make([]*orderedObject, len(parents))
+	for i := range results {
+		results[i] = &orderedObject{}
+	}
+	if len(parents) == 0 {
+		return results
+	}
+
+	for _, sel := range e.collectFields(selections) {
+		key := sel.ResponseKey()
+		fieldPath := append(append([]interface{}{}, path...), key)
+
+		if sel.Name == "__typename" {
+			for _, result := range results {
+				result.set(key, object.Name)
+			}
+			continue
+		}
+
+		field := object.Fields[sel.Name]
+		if field.Permission != "" && !e.authorize(field.Permission) {
+			e.fail(fieldPath, sel, fmt.Sprintf("%s requires the %s permission", sel.Name, field.Permission))
+			for _, result := range results {
+				result.set(key, nil)
+			}
+			continue
+		}
+
+		values, err := field.Resolve(ctx, parents, e.arguments(sel))
+		if err == nil && len(values) != len(parents) {
+			err = fmt.Errorf("resolver returned %d values for %d parents", len(values), len(parents))
+		}
+		if err != nil {
+			e.fail(fieldPath, sel, err.Error())
+			for _, result := range results {
+				result.set(key, nil)
+			}
+			continue
+		}
+
+		if field.Type == nil {
+			for i, result := range results {
+				result.set(key, values[i])
+			}
+			continue
+		}
+
+		// Gather the children of every parent so the next level resolves in one pass
+		var children []interface{}
+		for _, value := range values {
+			if value == nil {
+				continue
+			}
+			if field.List {
+				children = append(children, value.([]interface{})...)
+			} else {
+				children = append(children, value)
+			}
+		}
+		resolved := e.executeSet(ctx, field.Type, children, sel.Selections, fieldPath)
+
+		next := 0
+		for i, value := range values {
+			switch {
+			case value == nil:
+				results[i].set(key, nil)
+			case field.List:
+				items := value.([]interface{})
+				list := make([]*orderedObject, len(items))
+				copy(list, resolved[next:next+len(items)])
+				next += len(items)
+				results[i].set(key, list)
+			default:
+				results[i].set(key, resolved[next])
+				next++
+			}
+		}
+	}
+	return results
+}
+
+// collectFields flattens fragments and merges fields requested under the
+// same response key
+func (e *execution) collectFields(selections []*Selection) []*Selection {
+	var fields []*Selection
+	byKey := make(map[string]*Selection)
+
+	var collect func(selections []*Selection)
+	collect = func(selections []*Selection) {
+		for _, sel := range selections {
+			switch {
+			case sel.Spread != "":
+				collect(e.doc.Fragments[sel.Spread].Selections)
+			case sel.Inline:
+				collect(sel.Selections)
+			default:
+				if existing, ok := byKey[sel.ResponseKey()]; ok {
+					existing.Selections = append(existing.Selections, sel.Selections...)
+					continue
+				}
+				merged := *sel
+				merged.Selections = append([]*Selection{}, sel.Selections...)
+				byKey[sel.ResponseKey()] = &merged
+				fields = append(fields, &merged)
+			}
+		}
+	}
+	collect(selections)
+	return fields
+}
+
+func (e *execution) arguments(sel *Selection) Args {
+	args := make(Args, len(sel.Arguments))
+	for name, value := range sel.Arguments {
+		args[name] = e.resolveValue(value)
+	}
+	return args
+}
+
+func (e *execution) resolveValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Variable:
+		return e.variables[string(v)]
+	case Enum:
+		return string(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = e.resolveValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			object[k] = e.resolveValue(item)
+		}
+		return object
+	}
+	return value
+}
+
+func (e *execution) fail(path []interface{}, sel *Selection, message string) {
+	e.errors = append(e.errors, Error{Message: message, Path: path, Line: sel.Line})
+}
+
+// Args holds a field's arguments with variables substituted
+type Args map[string]interface{}
+
+func (a Args) String(name string) (string, bool) {
+	s, ok := a[name].(string)
+	return s, ok
+}
+
+// Int accepts both literal integers and JSON numbers from variables
+func (a Args) Int(name string) (int, bool) {
+	switch v := a[name].(type) {
+	case int64:
+		return int(v), true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// orderedObject keeps fields in the order they were requested, as the spec
+// requires for response maps
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *orderedObject) set(key string, value interface{}) {
+	if o.values == nil {
+		o.values = make(map[string]interface{})
+	}
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Describe lists the schema's types and fields for clients without
+// introspection support
+func (s *Schema) Describe() map[string]map[string]string {
+	types := make(map[string]map[string]string)
+	var visit func(object *Object)
+	visit = func(object *Object) {
+		if _, seen := types[object.Name]; seen {
+			return
+		}
+		fields := make(map[string]string)
+		types[object.Name] = fields
+		names := make([]string, 0, len(object.Fields))
+		for name := range object.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field := object.Fields[name]
+			typeName := "Scalar"
+			if field.Type != nil {
+				typeName = field.Type.Name
+				visit(field.Type)
+			}
+			if field.List {
+				typeName = "[" + typeName + "]"
+			}
+			parts := []string{typeName}
+			if field.Description != "" {
+				parts = append(parts, field.Description)
+			}
+			if field.Permission != "" {
+				parts = append(parts, "requires "+field.Permission)
+			}
+			fields[name] = strings.Join(parts, " - ")
+		}
+	}
+	visit(s.Query)
+	return types
+}
@@ -0,0 +1,398 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUser struct{ Name string }
+
+type testReport struct {
+	ID       string
+	Title    string
+	Reporter *testUser
+	Tags     []string
+}
+
+// testSchema serves two reports and counts resolver calls per field, so
+// tests can check that each level resolves once for all parents
+func testSchema(calls map[string]int) *Schema {
+	reports := []*testReport{
+		{ID: "r1", Title: "Flood", Reporter: &testUser{Name: "ayu"}, Tags: []string{"water", "urgent"}},
+		{ID: "r2", Title: "Landslide", Tags: []string{}},
+	}
+
+	user := &Object{Name: "User", Fields: map[string]*Field{
+		"name": {Resolve: Prop(func(p interface{}) interface{} { return p.(*testUser).Name })},
+	}}
+	tag := &Object{Name: "Tag", Fields: map[string]*Field{
+		"label": {Resolve: Prop(func(p interface{}) interface{} { return p.(string) })},
+	}}
+	report := &Object{Name: "Report", Fields: map[string]*Field{
+		"id":    {Resolve: Prop(func(p interface{}) interface{} { return p.(*testReport).ID })},
+		"title": {Resolve: Prop(func(p interface{}) interface{} { return p.(*testReport).Title })},
+		"reporter": {Type: user, Resolve: func(ctx context.Context, parents []interface{}, args Args) ([]interface{}, error) {
+			calls["reporter"]++
+			values := make([]interface{}, len(parents))
+			for i, p := range parents {
+				if r := p.(*testReport).Reporter; r != nil {
+					values[i] = r
+				}
+			}
+			return values, nil
+		}},
+		"tags": {Type: tag, List: true, Resolve: func(ctx context.Context, parents []interface{}, args Args) ([]interface{}, error) {
+			calls["tags"]++
+			values := make([]interface{}, len(parents))
+			for i, p := range parents {
+				tags := []interface{}{}
+				for _, t := range p.(*testReport).Tags {
+					tags = append(tags, t)
+				}
+				values[i] = tags
+			}
+			return values, nil
+		}},
+		"secret": {Permission: "reports:verify", Resolve: Prop(func(p interface{}) interface{} { return "hidden" })},
+		"broken": {Resolve: func(ctx context.Context, parents []interface{}, args Args) ([]interface{}, error) {
+			return nil, errors.New("database unavailable")
+		}},
+	}}
+	// A report's related reports let tests nest as deep as they like
+	report.Fields["related"] = &Field{Type: report, List: true, Resolve: func(ctx context.Context, parents []interface{}, args Args) ([]interface{}, error) {
+		values := make([]interface{}, len(parents))
+		for i := range values {
+			values[i] = []interface{}{}
+		}
+		return values, nil
+	}}
+
+	query := &Object{Name: "Query", Fields: map[string]*Field{
+		"reports": {Type: report, List: true, Resolve: func(ctx context.Context, parents []interface{}, args Args) ([]interface{}, error) {
+			calls["reports"]++
+			limit, ok := args.Int("limit")
+			if !ok || limit > len(reports) {
+				limit = len(reports)
+			}
+			list := make([]interface{}, limit)
+			for i := range list {
+				list[i] = reports[i]
+			}
+			return []interface{}{list}, nil
+		}},
+		"report": {Type: report, Resolve: func(ctx context.Context, parents []interface{}, args Args) ([]interface{}, error) {
+			id, _ := args.String("id")
+			for _, r := range reports {
+				if r.ID == id {
+					return []interface{}{r}, nil
+				}
+			}
+			return []interface{}{nil}, nil
+		}},
+	}}
+	return &Schema{Query: query}
+}
+
+func execute(t *testing.T, schema *Schema, req Request, permissions ...string) (string, []Error) {
+	t.Helper()
+	authorize := func(permission string) bool {
+		for _, p := range permissions {
+			if p == permission {
+				return true
+			}
+		}
+		return false
+	}
+	resp := schema.Execute(context.Background(), req, authorize)
+	if resp.Data == nil {
+		return "", resp.Errors
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatalf("marshal data: %v", err)
+	}
+	return string(data), resp.Errors
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       Request
+		want      string
+		wantError string
+	}{
+		{
+			name: "nested fields in request order",
+			req:  Request{Query: "{ reports { title id reporter { name } tags { label } } }"},
+			want: `{"reports":[{"title":"Flood","id":"r1","reporter":{"name":"ayu"},"tags":[{"label":"water"},{"label":"urgent"}]},` +
+				`{"title":"Landslide","id":"r2","reporter":null,"tags":[]}]}`,
+		},
+		{
+			name: "aliases and arguments",
+			req:  Request{Query: `{ first: report(id: "r1") { id } missing: report(id: "r9") { id } }`},
+			want: `{"first":{"id":"r1"},"missing":null}`,
+		},
+		{
+			name: "variables and defaults",
+			req: Request{
+				Query:     `query ($id: ID, $limit: Int = 1) { report(id: $id) { title } reports(limit: $limit) { id } }`,
+				Variables: map[string]interface{}{"id": "r2"},
+			},
+			want: `{"report":{"title":"Landslide"},"reports":[{"id":"r1"}]}`,
+		},
+		{
+			name: "JSON numbers as integer variables",
+			req: Request{
+				Query:     `query ($limit: Int) { reports(limit: $limit) { id } }`,
+				Variables: map[string]interface{}{"limit": float64(1)},
+			},
+			want: `{"reports":[{"id":"r1"}]}`,
+		},
+		{
+			name: "fragments merge into one field",
+			req: Request{Query: `
+				{ reports(limit: 1) { ...A ... on Report { reporter { name } } reporter { __typename } } }
+				fragment A on Report { id }`},
+			want: `{"reports":[{"id":"r1","reporter":{"name":"ayu","__typename":"User"}}]}`,
+		},
+		{
+			name: "operation chosen by name",
+			req: Request{
+				Query:         `query One { report(id: "r1") { id } } query Two { report(id: "r2") { id } }`,
+				OperationName: "Two",
+			},
+			want: `{"report":{"id":"r2"}}`,
+		},
+		{
+			name:      "denied field is null with an error",
+			req:       Request{Query: `{ report(id: "r1") { id secret } }`},
+			want:      `{"report":{"id":"r1","secret":null}}`,
+			wantError: "secret requires the reports:verify permission",
+		},
+		{
+			name:      "resolver error",
+			req:       Request{Query: `{ report(id: "r1") { id broken } }`},
+			want:      `{"report":{"id":"r1","broken":null}}`,
+			wantError: "database unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := execute(t, testSchema(map[string]int{}), tt.req)
+			if got != tt.want {
+				t.Errorf("data = %s\nwant   %s", got, tt.want)
+			}
+			switch {
+			case tt.wantError == "" && len(errs) > 0:
+				t.Errorf("unexpected errors: %v", errs)
+			case tt.wantError != "" && (len(errs) != 1 || errs[0].Message != tt.wantError):
+				t.Errorf("errors = %v, want %q", errs, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestExecuteGrantedPermission(t *testing.T) {
+	got, errs := execute(t, testSchema(map[string]int{}), Request{Query: `{ report(id: "r1") { secret } }`}, "reports:verify")
+	if len(errs) > 0 || got != `{"report":{"secret":"hidden"}}` {
+		t.Errorf("got %s with errors %v", got, errs)
+	}
+}
+
+func TestExecuteErrorPath(t *testing.T) {
+	_, errs := execute(t, testSchema(map[string]int{}), Request{Query: `{ r: report(id: "r1") { broken } }`})
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1", len(errs))
+	}
+	if path := fmt.Sprint(errs[0].Path); path != "[r broken]" {
+		t.Errorf("path = %s, want [r broken]", path)
+	}
+}
+
+func TestExecuteResolvesEachLevelOnce(t *testing.T) {
+	calls := map[string]int{}
+	_, errs := execute(t, testSchema(calls), Request{Query: "{ reports { reporter { name } tags { label } } }"})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for _, field := range []string{"reports", "reporter", "tags"} {
+		if calls[field] != 1 {
+			t.Errorf("%s resolved %d times, want once for both reports", field, calls[field])
+		}
+	}
+}
+
+func TestExecuteRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		opName   string
+		contains string
+	}{
+		{"syntax error", "{ reports { id }", "", "syntax error on line 1"},
+		{"mutation", "mutation { reports { id } }", "", "only queries are supported"},
+		{"ambiguous operation", "query A { reports { id } } query B { reports { id } }", "", "operationName is required"},
+		{"unknown operation", "query A { reports { id } }", "B", `unknown operation "B"`},
+		{"unknown field", "{ reports { id colour } }", "", `cannot query field "colour" on type Report (line 1)`},
+		{"object without selections", "{ reports }", "", `field "reports" of type Report needs a selection set`},
+		{"scalar with selections", "{ reports { id { x } } }", "", `field "id" is a scalar and takes no selection set`},
+		{"unknown fragment", "{ reports { ...Missing } }", "", `unknown fragment "Missing"`},
+		{"fragment cycle", "{ reports { ...A } } fragment A on Report { related { ...A } }", "", `fragment "A" spreads itself`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := map[string]int{}
+			got, errs := execute(t, testSchema(calls), Request{Query: tt.query, OperationName: tt.opName})
+			if got != "" {
+				t.Errorf("got data %s, want none", got)
+			}
+			if len(errs) == 0 || !strings.Contains(errs[0].Message, tt.contains) {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.contains)
+			}
+			if calls["reports"] != 0 {
+				t.Error("a rejected query reached a resolver")
+			}
+		})
+	}
+}
+
+// nested builds a reports query with depth levels of related reports below
+// the top-level field
+func nested(depth int) string {
+	return "{ reports(limit: 1) { " + strings.Repeat("related(limit: 1) { ", depth-1) + "id" + strings.Repeat(" }", depth) + " }"
+}
+
+func TestExecuteDepthLimit(t *testing.T) {
+	schema := testSchema(map[string]int{})
+	schema.MaxDepth = 4
+
+	// The leaf scalar sits one level below the deepest object
+	if _, errs := execute(t, schema, Request{Query: nested(3)}); len(errs) > 0 {
+		t.Errorf("depth 4 rejected: %v", errs)
+	}
+	_, errs := execute(t, schema, Request{Query: nested(4)})
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "nested deeper than 4 levels") {
+		t.Errorf("depth 5 errors = %v, want the depth limit", errs)
+	}
+
+	// Fragments count toward the depth where they are spread
+	_, errs = execute(t, schema, Request{Query: `
+		{ reports { related { ...Deep } } }
+		fragment Deep on Report { related { related { id } } }`})
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "nested deeper than 4 levels") {
+		t.Errorf("errors through a fragment = %v, want the depth limit", errs)
+	}
+}
+
+func TestExecuteDefaultDepthLimit(t *testing.T) {
+	schema := testSchema(map[string]int{})
+	schema.MaxComplexity = 1 << 20
+	if _, errs := execute(t, schema, Request{Query: nested(DefaultMaxDepth - 1)}); len(errs) > 0 {
+		t.Errorf("default depth rejected: %v", errs)
+	}
+	if _, errs := execute(t, schema, Request{Query: nested(DefaultMaxDepth)}); len(errs) == 0 {
+		t.Error("query past the default depth was accepted")
+	}
+}
+
+func TestExecuteComplexityLimit(t *testing.T) {
+	schema := testSchema(map[string]int{})
+	schema.MaxComplexity = 100
+
+	tests := []struct {
+		name     string
+		query    string
+		contains string
+	}{
+		// 1 for reports plus 2 per report, times the limit
+		{"within the limit", "{ reports(limit: 33) { id title } }", ""},
+		{"list size from limit", "{ reports(limit: 50) { id title } }", "query complexity 101 exceeds the limit of 100"},
+		{"default list size", "{ reports { related { id title } } }", "query complexity 821 exceeds the limit of 100"},
+		{"huge limit", "{ reports(limit: 9223372036854775807) { related(limit: 9223372036854775807) { id } } }", "exceeds the limit of 100"},
+		{"negative limit uses the default", "{ reports(limit: -5) { id title tags { label } } }", "query complexity 461 exceeds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := execute(t, schema, Request{Query: tt.query})
+			if tt.contains == "" {
+				if len(errs) > 0 {
+					t.Errorf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Message, tt.contains) {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.contains)
+			}
+		})
+	}
+}
+
+func TestExecuteFragmentFanOut(t *testing.T) {
+	// Each fragment spreads the next twice, doubling the work at every step
+	var b strings.Builder
+	b.WriteString("{ reports(limit: 1) { ...F0 } }\n")
+	const steps = 40
+	for i := 0; i < steps; i++ {
+		fmt.Fprintf(&b, "fragment F%d on Report { ...F%d ...F%d }\n", i, i+1, i+1)
+	}
+	fmt.Fprintf(&b, "fragment F%d on Report { __typename }\n", steps)
+
+	start := time.Now()
+	_, errs := execute(t, testSchema(map[string]int{}), Request{Query: b.String()})
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "query complexity exceeds the limit") {
+		t.Errorf("errors = %v, want the complexity limit", errs)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("validation took %s", elapsed)
+	}
+}
+
+func TestExecuteHostileQueries(t *testing.T) {
+	aliases := func(n int) string {
+		var b strings.Builder
+		b.WriteString("{ ")
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "a%d: reports(limit: 3) { id } ", i)
+		}
+		b.WriteString("}")
+		return b.String()
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		contains  string
+	}{
+		{"aliased copies of a field", aliases(30), nil, "query complexity 120 exceeds the limit of 100"},
+		{"page size from a variable", "query ($n: Int) { reports(limit: $n) { related(limit: $n) { id } } }",
+			map[string]interface{}{"n": 1e6}, "exceeds the limit of 100"},
+		{"page size from a variable default", "query ($n: Int = 1000) { reports(limit: $n) { id } }", nil, "exceeds the limit of 100"},
+		{"thousands of repeated fields", "{ reports(limit: 1) { " + strings.Repeat("id ", 5000) + "} }", nil, "query complexity exceeds the limit"},
+		{"repeated inline fragments", "{ reports(limit: 1) { " + strings.Repeat("... on Report { title } ", 5000) + "} }", nil, "query complexity exceeds the limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := map[string]int{}
+			schema := testSchema(calls)
+			schema.MaxComplexity = 100
+
+			start := time.Now()
+			_, errs := execute(t, schema, Request{Query: tt.query, Variables: tt.variables})
+			if len(errs) != 1 || !strings.Contains(errs[0].Message, tt.contains) {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.contains)
+			}
+			if calls["reports"] != 0 {
+				t.Error("a rejected query reached a resolver")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("validation took %s", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,462 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The parser covers the executable subset of GraphQL the dashboard needs:
+// operations with variables, fields with aliases and arguments, named and
+// inline fragments. Directives and type system definitions are rejected.
+
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+type Operation struct {
+	Type       string // query, mutation or subscription
+	Name       string
+	Variables  []VariableDefinition
+	Selections []*Selection
+}
+
+type VariableDefinition struct {
+	Name    string
+	Default interface{}
+	HasDef  bool
+}
+
+type Fragment struct {
+	Name       string
+	Selections []*Selection
+}
+
+// Selection is a field, a fragment spread (Spread set) or an inline
+// fragment (Inline set)
+type Selection struct {
+	Alias      string
+	Name       string
+	Arguments  map[string]interface{}
+	Selections []*Selection
+	Spread     string
+	Inline     bool
+	Line       int
+}
+
+func (s *Selection) ResponseKey() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// Variable is an argument value that refers to a request variable
+type Variable string
+
+// Enum is an unquoted enum value
+type Enum string
+
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  int
+	value string
+	line  int
+}
+
+// maxNesting bounds how deeply selection sets, list and object values and
+// type references may nest. The executor's depth limit only runs after
+// parsing, so this keeps a hostile document from exhausting the stack first.
+const maxNesting = 64
+
+type parser struct {
+	src   string
+	pos   int
+	line  int
+	tok   token
+	depth int
+}
+
+type SyntaxError struct {
+	Line    int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error on line %d: %s", e.Line, e.Message)
+}
+
+// Parse reads a GraphQL document
+func Parse(src string) (doc *Document, err error) {
+	p := &parser{src: strings.TrimPrefix(src, "\uFEFF"), line: 1}
+	defer func() {
+		if r := recover(); r != nil {
+			if se, ok := r.(*SyntaxError); ok {
+				doc, err = nil, se
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	p.next()
+	doc = &Document{Fragments: make(map[string]*Fragment)}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek(tokPunct, "{"):
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: p.selectionSet()})
+		case p.peek(tokName, "query"), p.peek(tokName, "mutation"), p.peek(tokName, "subscription"):
+			doc.Operations = append(doc.Operations, p.operation())
+		case p.peek(tokName, "fragment"):
+			p.next()
+			name := p.expect(tokName, "").value
+			p.expect(tokName, "on")
+			p.expect(tokName, "")
+			p.noDirectives()
+			if _, dup := doc.Fragments[name]; dup {
+				p.fail("fragment " + name + " is defined twice")
+			}
+			doc.Fragments[name] = &Fragment{Name: name, Selections: p.selectionSet()}
+		default:
+			p.fail("unexpected " + p.describe())
+		}
+	}
+	if len(doc.Operations) == 0 {
+		p.fail("document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) operation() *Operation {
+	op := &Operation{Type: p.tok.value}
+	p.next()
+	if p.tok.kind == tokName {
+		op.Name = p.tok.value
+		p.next()
+	}
+	if p.skip(tokPunct, "(") {
+		for !p.skip(tokPunct, ")") {
+			p.expect(tokPunct, "$")
+			def := VariableDefinition{Name: p.expect(tokName, "").value}
+			p.expect(tokPunct, ":")
+			p.typeReference()
+			if p.skip(tokPunct, "=") {
+				def.Default, def.HasDef = p.value(true), true
+			}
+			op.Variables = append(op.Variables, def)
+		}
+	}
+	p.noDirectives()
+	op.Selections = p.selectionSet()
+	return op
+}
+
+// typeReference skips a variable's declared type; values are checked by the
+// resolvers that read them
+func (p *parser) typeReference() {
+	if p.skip(tokPunct, "[") {
+		p.enter()
+		defer p.leave()
+		p.typeReference()
+		p.expect(tokPunct, "]")
+	} else {
+		p.expect(tokName, "")
+	}
+	p.skip(tokPunct, "!")
+}
+
+func (p *parser) selectionSet() []*Selection {
+	p.expect(tokPunct, "{")
+	p.enter()
+	defer p.leave()
+	var selections []*Selection
+	for !p.skip(tokPunct, "}") {
+		line := p.tok.line
+		if p.skip(tokPunct, "...") {
+			if p.peek(tokName, "on") || p.peek(tokPunct, "{") {
+				if p.skip(tokName, "on") {
+					p.expect(tokName, "")
+				}
+				p.noDirectives()
+				selections = append(selections, &Selection{Inline: true, Selections: p.selectionSet(), Line: line})
+				continue
+			}
+			spread := p.expect(tokName, "").value
+			p.noDirectives()
+			selections = append(selections, &Selection{Spread: spread, Line: line})
+			continue
+		}
+
+		s := &Selection{Name: p.expect(tokName, "").value, Line: line}
+		if p.skip(tokPunct, ":") {
+			s.Alias, s.Name = s.Name, p.expect(tokName, "").value
+		}
+		if p.skip(tokPunct, "(") {
+			s.Arguments = make(map[string]interface{})
+			for !p.skip(tokPunct, ")") {
+				name := p.expect(tokName, "").value
+				p.expect(tokPunct, ":")
+				s.Arguments[name] = p.value(false)
+			}
+		}
+		p.noDirectives()
+		if p.peek(tokPunct, "{") {
+			s.Selections = p.selectionSet()
+		}
+		selections = append(selections, s)
+	}
+	if len(selections) == 0 {
+		p.fail("empty selection set")
+	}
+	return selections
+}
+
+func (p *parser) value(constant bool) interface{} {
+	tok := p.tok
+	switch tok.kind {
+	case tokInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			p.fail("invalid integer " + tok.value)
+		}
+		return n
+	case tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			p.fail("invalid float " + tok.value)
+		}
+		return f
+	case tokString:
+		p.next()
+		return tok.value
+	case tokName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return Enum(tok.value)
+	}
+
+	switch {
+	case p.skip(tokPunct, "$"):
+		if constant {
+			p.fail("variables are not allowed in default values")
+		}
+		return Variable(p.expect(tokName, "").value)
+	case p.skip(tokPunct, "["):
+		p.enter()
+		defer p.leave()
+		list := []interface{}{}
+		for !p.skip(tokPunct, "]") {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case p.skip(tokPunct, "{"):
+		p.enter()
+		defer p.leave()
+		object := map[string]interface{}{}
+		for !p.skip(tokPunct, "}") {
+			name := p.expect(tokName, "").value
+			p.expect(tokPunct, ":")
+			object[name] = p.value(constant)
+		}
+		return object
+	}
+	p.fail("expected a value, found " + p.describe())
+	return nil
+}
+
+func (p *parser) enter() {
+	p.depth++
+	if p.depth > maxNesting {
+		p.fail(fmt.Sprintf("document is nested deeper than %d levels", maxNesting))
+	}
+}
+
+func (p *parser) leave() { p.depth-- }
+
+func (p *parser) noDirectives() {
+	if p.peek(tokPunct, "@") {
+		p.fail("directives are not supported")
+	}
+}
+
+func (p *parser) peek(kind int, value string) bool {
+	return p.tok.kind == kind && (value == "" || p.tok.value == value)
+}
+
+func (p *parser) skip(kind int, value string) bool {
+	if p.peek(kind, value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind int, value string) token {
+	tok := p.tok
+	if !p.peek(kind, value) {
+		want := value
+		if want == "" {
+			want = map[int]string{tokName: "a name"}[kind]
+		}
+		p.fail("expected " + want + ", found " + p.describe())
+	}
+	p.next()
+	return tok
+}
+
+func (p *parser) describe() string {
+	if p.tok.kind == tokEOF {
+		return "end of document"
+	}
+	return strconv.Quote(p.tok.value)
+}
+
+func (p *parser) fail(message string) {
+	panic(&SyntaxError{Line: p.tok.line, Message: message})
+}
+
+// next advances to the following token, skipping whitespace, commas and comments
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '\n' {
+			p.line++
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, line: p.line}
+		return
+	}
+	// Errors while scanning report the line the new token starts on
+	p.tok.line = p.line
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokPunct, value: "...", line: p.line}
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokPunct, value: string(c), line: p.line}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, value: p.src[start:p.pos], line: p.line}
+	case c == '-' || isDigit(c):
+		kind := tokInt
+		p.pos++
+		for p.pos < len(p.src) {
+			d := p.src[p.pos]
+			if d == '.' || d == 'e' || d == 'E' {
+				kind = tokFloat
+			} else if !isDigit(d) && !((d == '+' || d == '-') && kind == tokFloat) {
+				break
+			}
+			p.pos++
+		}
+		p.tok = token{kind: kind, value: p.src[start:p.pos], line: p.line}
+	case c == '"':
+		line := p.line
+		p.tok = token{kind: tokString, value: p.stringValue(), line: line}
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.fail("unexpected character " + strconv.QuoteRune(r))
+	}
+}
+
+func (p *parser) stringValue() string {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.fail("unterminated block string")
+		}
+		s := p.src[p.pos+3 : p.pos+3+end]
+		p.line += strings.Count(s, "\n")
+		p.pos += end + 6
+		return strings.TrimSpace(s)
+	}
+
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			return b.String()
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		escape := p.src[p.pos+1]
+		p.pos += 2
+		switch escape {
+		case '"', '\\', '/':
+			b.WriteByte(escape)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.fail("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(code))
+			p.pos += 4
+		default:
+			p.fail("invalid escape \\" + string(escape))
+		}
+	}
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
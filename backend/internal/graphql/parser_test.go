@@ -0,0 +1,168 @@
+package graphql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOperation(t *testing.T) {
+	doc, err := Parse(`
+		# the dashboard query
+		query Dashboard($status: String = "pending", $limit: Int!, $ids: [ID!]) {
+			open: reports(status: $status, limit: $limit, order: NEWEST, near: {lat: -6.2, lng: 106.8}) {
+				id
+				...ReportFields
+				... on Report { severity }
+			}
+		}
+
+		fragment ReportFields on Report { title, reporter { username } }
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(doc.Operations) != 1 {
+		t.Fatalf("got %d operations, want 1", len(doc.Operations))
+	}
+	op := doc.Operations[0]
+	if op.Type != "query" || op.Name != "Dashboard" {
+		t.Errorf("got %s %q, want query \"Dashboard\"", op.Type, op.Name)
+	}
+	wantVariables := []VariableDefinition{
+		{Name: "status", Default: "pending", HasDef: true},
+		{Name: "limit"},
+		{Name: "ids"},
+	}
+	if !reflect.DeepEqual(op.Variables, wantVariables) {
+		t.Errorf("variables = %#v, want %#v", op.Variables, wantVariables)
+	}
+
+	reports := op.Selections[0]
+	if reports.Alias != "open" || reports.Name != "reports" || reports.ResponseKey() != "open" {
+		t.Errorf("got alias %q name %q, want open: reports", reports.Alias, reports.Name)
+	}
+	wantArgs := map[string]interface{}{
+		"status": Variable("status"),
+		"limit":  Variable("limit"),
+		"order":  Enum("NEWEST"),
+		"near":   map[string]interface{}{"lat": -6.2, "lng": 106.8},
+	}
+	if !reflect.DeepEqual(reports.Arguments, wantArgs) {
+		t.Errorf("arguments = %#v, want %#v", reports.Arguments, wantArgs)
+	}
+
+	if len(reports.Selections) != 3 {
+		t.Fatalf("got %d selections under reports, want 3", len(reports.Selections))
+	}
+	if s := reports.Selections[1]; s.Spread != "ReportFields" {
+		t.Errorf("second selection spreads %q, want ReportFields", s.Spread)
+	}
+	if s := reports.Selections[2]; !s.Inline || s.Selections[0].Name != "severity" {
+		t.Errorf("third selection is not the inline fragment selecting severity")
+	}
+
+	fragment, ok := doc.Fragments["ReportFields"]
+	if !ok {
+		t.Fatal("fragment ReportFields is missing")
+	}
+	if len(fragment.Selections) != 2 || fragment.Selections[1].Selections[0].Name != "username" {
+		t.Errorf("fragment selections are not title and reporter { username }")
+	}
+}
+
+func TestParseShorthandQuery(t *testing.T) {
+	doc, err := Parse("{ reports { id } }")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	op := doc.Operations[0]
+	if op.Type != "query" || op.Name != "" {
+		t.Errorf("got %s %q, want an anonymous query", op.Type, op.Name)
+	}
+	if op.Selections[0].Selections[0].Line != 1 {
+		t.Errorf("line = %d, want 1", op.Selections[0].Selections[0].Line)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  interface{}
+	}{
+		{"int", "42", int64(42)},
+		{"negative int", "-7", int64(-7)},
+		{"float", "1.5e3", 1500.0},
+		{"true", "true", true},
+		{"false", "false", false},
+		{"null", "null", nil},
+		{"enum", "HIGH", Enum("HIGH")},
+		{"string escapes", `"a\"b\\c\né"`, "a\"b\\c\né"},
+		{"block string", `"""  flood
+near "river"  """`, "flood\nnear \"river\""},
+		{"list", "[1, 2 3]", []interface{}{int64(1), int64(2), int64(3)}},
+		{"empty list", "[]", []interface{}{}},
+		{"object", `{a: 1, b: [x]}`, map[string]interface{}{"a": int64(1), "b": []interface{}{Enum("x")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse("{ f(v: " + tt.value + ") }")
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got := doc.Operations[0].Selections[0].Arguments["v"]
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		line     int
+		contains string
+	}{
+		{"empty document", "  # nothing\n", 2, "document has no operation"},
+		{"empty selection set", "{ reports { } }", 1, "empty selection set"},
+		{"unclosed selection set", "{ reports { id }", 1, "found end of document"},
+		{"directive", "{ reports @include(if: true) { id } }", 1, "directives are not supported"},
+		{"duplicate fragment", "fragment F on R { id }\nfragment F on R { id }\n{ r { ...F } }", 2, "fragment F is defined twice"},
+		{"variable in default", "query ($a: Int = $b) { r }", 1, "variables are not allowed in default values"},
+		{"unterminated string", "{ r(a: \"abc\n) }", 1, "unterminated string"},
+		{"unterminated block string", `{ r(a: """abc) }`, 1, "unterminated block string"},
+		{"invalid escape", `{ r(a: "\q") }`, 1, `invalid escape \q`},
+		{"unexpected character", "{ r }\n%", 2, "unexpected character '%'"},
+		{"missing argument value", "{ r(a: ) }", 1, "expected a value"},
+		{"after a block string", "{ r(a: \"\"\"x\ny\"\"\", b: ) }", 2, "expected a value"},
+		{"deep selection sets", strings.Repeat("{ a ", maxNesting) + "{ b }" + strings.Repeat(" }", maxNesting), 1, "nested deeper than"},
+		{"deep list value", "{ r(a: " + strings.Repeat("[", maxNesting+1) + strings.Repeat("]", maxNesting+1) + ") }", 1, "nested deeper than"},
+		{"deep type reference", "query ($a: " + strings.Repeat("[", maxNesting+1) + "Int" + strings.Repeat("]", maxNesting+1) + ") { r }", 1, "nested deeper than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			se, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("got error %v, want a *SyntaxError", err)
+			}
+			if se.Line != tt.line {
+				t.Errorf("line = %d, want %d", se.Line, tt.line)
+			}
+			if !strings.Contains(se.Message, tt.contains) {
+				t.Errorf("message %q does not contain %q", se.Message, tt.contains)
+			}
+		})
+	}
+}
+
+func TestParseNestingAtLimit(t *testing.T) {
+	query := strings.Repeat("{ a ", maxNesting-1) + "{ b }" + strings.Repeat(" }", maxNesting-1)
+	if _, err := Parse(query); err != nil {
+		t.Fatalf("Parse rejected %d nested selection sets: %v", maxNesting, err)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DeleteAccount lets a user erase their own account. The account is
+// soft-deleted at once and signed out everywhere; an admin can still restore
+// it during the grace period, after which its personal data is anonymized.
+func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var requestData struct {
+		Password string `json:"password"`
+		MFACode  string `json:"mfaCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var passwordHash, mfaSecret string
+	var mfaEnabled bool
+	err := h.db.QueryRow(`
+		SELECT password_hash, COALESCE(mfa_secret, ''), mfa_enabled FROM users WHERE id = UUID_TO_BIN(?)
+	`, userID).Scan(&passwordHash, &mfaSecret, &mfaEnabled)
+	if err != nil {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(requestData.Password)); err != nil {
+		apierror.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+	if mfaEnabled {
+		if requestData.MFACode == "" {
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeMFARequired, "MFA required", nil)
+			return
+		}
+		if !totp.Validate(requestData.MFACode, mfaSecret) {
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidMFACode, "Invalid MFA code", nil)
+			return
+		}
+	}
+
+	owns, err := accounts.OwnsOrganization(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if owns {
+		apierror.Error(w, "Transfer or close the organizations you own before deleting your account", http.StatusConflict)
+		return
+	}
+
+	purgeAfter, err := accounts.SoftDelete(h.db, userID, userID)
+	if err != nil {
+		apierror.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventAccountDeleted,
+		Severity: audit.SeverityHigh,
+		UserID:   userID,
+		EntityID: userID,
+		Details:  map[string]interface{}{"deletedBy": "self", "purgeAfter": purgeAfter},
+	})
+
+	// The sessions are gone; the cookies go too so the browser stops sending them
+	for _, name := range []string{"access_token", "refresh_token"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+			Path:     "/",
+			MaxAge:   -1,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Account deleted; personal data will be anonymized after the grace period",
+		"purgeAfter": purgeAfter.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saferelief/internal/apierror"
+)
+
+const (
+	defaultActivityLimit = 20
+	maxActivityLimit     = 100
+)
+
+type ActivityItem struct {
+	Type       string    `json:"type"`
+	EntityID   string    `json:"entityId"`
+	ReportID   string    `json:"reportId"`
+	Title      string    `json:"title"`
+	Amount     *float64  `json:"amount,omitempty"`
+	Currency   *string   `json:"currency,omitempty"`
+	Status     string    `json:"status"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// GetActivity returns the user's own reports, donations and verifications as
+// a single feed, newest first
+func (h *UserHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	limit := defaultActivityLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxActivityLimit {
+		limit = maxActivityLimit
+	}
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	rows, err := h.db.Query(
+		`SELECT type, entity_id, report_id, title, amount, currency, status, occurred_at FROM (
+			SELECT 'report_filed' AS type, BIN_TO_UUID(id) AS entity_id, BIN_TO_UUID(id) AS report_id,
+			title, NULL AS amount, NULL AS currency, status, created_at AS occurred_at
+			FROM disaster_reports WHERE reporter_id = UUID_TO_BIN(?) AND deleted_at IS NULL
+
+			UNION ALL
+
+			SELECT 'donation_made', BIN_TO_UUID(d.id), BIN_TO_UUID(d.disaster_report_id),
+			dr.title, d.amount, d.currency, d.status, d.created_at
+			FROM donations d JOIN disaster_reports dr ON dr.id = d.disaster_report_id
+			WHERE d.donor_id = UUID_TO_BIN(?) AND dr.deleted_at IS NULL
+
+			UNION ALL
+
+			SELECT 'report_verified', BIN_TO_UUID(id), BIN_TO_UUID(id),
+			title, NULL, NULL, status, updated_at
+			FROM disaster_reports WHERE verified_by = UUID_TO_BIN(?) AND deleted_at IS NULL
+		) activity
+		ORDER BY occurred_at DESC
+		LIMIT ? OFFSET ?`,
+		userID, userID, userID, limit+1, offset,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching activity", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []ActivityItem{}
+	for rows.Next() {
+		var item ActivityItem
+		if err := rows.Scan(
+			&item.Type, &item.EntityID, &item.ReportID, &item.Title,
+			&item.Amount, &item.Currency, &item.Status, &item.OccurredAt,
+		); err != nil {
+			apierror.Error(w, "Error processing activity", http.StatusInternalServerError)
+			return
+		}
+		items = append(items, item)
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":   items,
+		"limit":   limit,
+		"offset":  offset,
+		"hasMore": hasMore,
+	})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/notify"
+)
+
+const topOffendingIPsLimit = 10
+
+type SecurityWindowSummary struct {
+	FailedLogins        int           `json:"failedLogins"`
+	LockedAccounts      int           `json:"lockedAccounts"`
+	RateLimitRejections int           `json:"rateLimitRejections"`
+	FlaggedDonations    int           `json:"flaggedDonations"`
+	TopOffendingIPs     []OffendingIP `json:"topOffendingIps"`
+}
+
+type OffendingIP struct {
+	IPAddress string `json:"ipAddress"`
+	Events    int    `json:"events"`
+}
+
+type AdminHandler struct {
+	db          *sql.DB
+	mailer      *notify.Mailer
+	auditLogger *audit.Logger
+}
+
+func NewAdminHandler(db *sql.DB, mailer *notify.Mailer, auditLogger *audit.Logger) *AdminHandler {
+	return &AdminHandler{db: db, mailer: mailer, auditLogger: auditLogger}
+}
+
+func (h *AdminHandler) SecuritySummary(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	windows := map[string]time.Duration{
+		"24h": 24 * time.Hour,
+		"7d":  7 * 24 * time.Hour,
+	}
+
+	summaries := make(map[string]SecurityWindowSummary, len(windows))
+	for name, window := range windows {
+		summary, err := h.securityWindowSummary(now.Add(-window))
+		if err != nil {
+			apierror.Error(w, "Error building security summary", http.StatusInternalServerError)
+			return
+		}
+		summaries[name] = summary
+	}
+
+	// Accounts locked right now, independent of the reporting window
+	var currentlyLocked int
+	err := h.db.QueryRow(
+		"SELECT COUNT(*) FROM users WHERE locked_until IS NOT NULL AND locked_until > NOW()",
+	).Scan(&currentlyLocked)
+	if err != nil {
+		apierror.Error(w, "Error building security summary", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"generatedAt":     now.UTC(),
+		"currentlyLocked": currentlyLocked,
+		"windows":         summaries,
+	})
+}
+
+func (h *AdminHandler) securityWindowSummary(since time.Time) (SecurityWindowSummary, error) {
+	var summary SecurityWindowSummary
+
+	err := h.db.QueryRow(
+		`SELECT
+			COALESCE(SUM(action = ?), 0),
+			COALESCE(SUM(action = ?), 0),
+			COALESCE(SUM(action = ?), 0)
+		FROM audit_logs WHERE created_at >= ?`,
+		audit.EventLoginFailed, audit.EventAccountLocked, audit.EventRateLimited, since,
+	).Scan(&summary.FailedLogins, &summary.LockedAccounts, &summary.RateLimitRejections)
+	if err != nil {
+		return summary, err
+	}
+
+	// Donations that ended up failed or refunded are surfaced for review
+	err = h.db.QueryRow(
+		"SELECT COUNT(*) FROM donations WHERE status IN ('failed', 'refunded') AND updated_at >= ?",
+		since,
+	).Scan(&summary.FlaggedDonations)
+	if err != nil {
+		return summary, err
+	}
+
+	rows, err := h.db.Query(
+		`SELECT ip_address, COUNT(*) AS events
+		FROM audit_logs
+		WHERE action IN (?, ?) AND created_at >= ?
+		GROUP BY ip_address
+		ORDER BY events DESC
+		LIMIT ?`,
+		audit.EventLoginFailed, audit.EventRateLimited, since, topOffendingIPsLimit,
+	)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	summary.TopOffendingIPs = []OffendingIP{}
+	for rows.Next() {
+		var ip OffendingIP
+		if err := rows.Scan(&ip.IPAddress, &ip.Events); err != nil {
+			return summary, err
+		}
+		summary.TopOffendingIPs = append(summary.TopOffendingIPs, ip)
+	}
+
+	return summary, rows.Err()
+}
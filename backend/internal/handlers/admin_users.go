@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+	"saferelief/internal/notify"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultUserSearchLimit = 25
+	maxUserSearchLimit     = 100
+)
+
+var userSearchSortColumns = map[string]string{
+	"createdAt":      "created_at",
+	"username":       "username",
+	"email":          "email",
+	"failedAttempts": "failed_attempts",
+	"lockedUntil":    "locked_until",
+}
+
+type AdminUserSummary struct {
+	ID             string     `json:"id"`
+	Username       string     `json:"username"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Status         string     `json:"status"`
+	Suspended      bool       `json:"suspended"`
+	MFAEnabled     bool       `json:"mfaEnabled"`
+	FailedAttempts int        `json:"failedAttempts"`
+	LockedUntil    *time.Time `json:"lockedUntil"`
+	DeletedAt      *time.Time `json:"deletedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// SearchUsers finds accounts by partial username/email match with optional
+// role, lock and MFA filters
+func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	where := " WHERE 1=1"
+	args := []interface{}{}
+
+	if term := strings.TrimSpace(q.Get("q")); term != "" {
+		// Escape LIKE wildcards so the search term is matched literally
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+		where += " AND (username LIKE ? OR email LIKE ?)"
+		args = append(args, "%"+escaped+"%", "%"+escaped+"%")
+	}
+	if role := q.Get("role"); role != "" {
+		where += " AND role = ?"
+		args = append(args, role)
+	}
+	switch q.Get("locked") {
+	case "true":
+		where += " AND locked_until > NOW()"
+	case "false":
+		where += " AND (locked_until IS NULL OR locked_until <= NOW())"
+	}
+	// Suspended covers bans and unexpired suspensions
+	suspendedClause := `EXISTS (SELECT 1 FROM user_suspensions s WHERE s.user_id = users.id
+		AND s.lifted_at IS NULL AND (s.expires_at IS NULL OR s.expires_at > NOW()))`
+	switch q.Get("suspended") {
+	case "true":
+		where += " AND " + suspendedClause
+	case "false":
+		where += " AND NOT " + suspendedClause
+	}
+	switch q.Get("mfa") {
+	case "true":
+		where += " AND mfa_enabled = TRUE"
+	case "false":
+		where += " AND mfa_enabled = FALSE"
+	}
+
+	sortColumn, ok := userSearchSortColumns[q.Get("sort")]
+	if !ok {
+		sortColumn = "created_at"
+	}
+	order := "DESC"
+	if strings.EqualFold(q.Get("order"), "asc") {
+		order = "ASC"
+	}
+
+	limit := defaultUserSearchLimit
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxUserSearchLimit {
+		limit = maxUserSearchLimit
+	}
+	offset := 0
+	if o, err := strconv.Atoi(q.Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		apierror.Error(w, "Error searching users", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), username, email, role, status, `+suspendedClause+`, mfa_enabled,
+		failed_attempts, locked_until, deleted_at, created_at
+		FROM users`+where+" ORDER BY "+sortColumn+" "+order+" LIMIT ? OFFSET ?",
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		apierror.Error(w, "Error searching users", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	users := []AdminUserSummary{}
+	for rows.Next() {
+		var u AdminUserSummary
+		if err := rows.Scan(
+			&u.ID, &u.Username, &u.Email, &u.Role, &u.Status, &u.Suspended, &u.MFAEnabled,
+			&u.FailedAttempts, &u.LockedUntil, &u.DeletedAt, &u.CreatedAt,
+		); err != nil {
+			apierror.Error(w, "Error processing users", http.StatusInternalServerError)
+			return
+		}
+		users = append(users, u)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users":  users,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	targetID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	if _, err := accounts.SoftDelete(h.db, targetID, adminID); err != nil {
+		if err == accounts.ErrNotFound {
+			apierror.Error(w, "User not found or already deleted", http.StatusNotFound)
+			return
+		}
+		apierror.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventAccountDeleted,
+		Severity: audit.SeverityHigh,
+		UserID:   adminID,
+		EntityID: targetID,
+		Details:  map[string]interface{}{"deletedBy": "admin"},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "User deleted; data will be anonymized after the grace period",
+	})
+}
+
+func (h *AdminHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	targetID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	if err := accounts.Restore(h.db, targetID); err != nil {
+		switch err {
+		case accounts.ErrNotFound:
+			apierror.Error(w, "User not found", http.StatusNotFound)
+		case accounts.ErrNotDeleted:
+			apierror.Error(w, "User is not deleted", http.StatusConflict)
+		case accounts.ErrGracePeriodExpired:
+			apierror.Error(w, "Deletion grace period has expired; account cannot be restored", http.StatusGone)
+		default:
+			apierror.Error(w, "Failed to restore user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventAccountRestored,
+		Severity: audit.SeverityHigh,
+		UserID:   adminID,
+		EntityID: targetID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "User restored successfully",
+	})
+}
+
+// SuspendUser suspends (optionally until expiresAt) or permanently bans an account
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	targetID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Type      accounts.SuspensionKind `json:"type"`
+		Reason    string                  `json:"reason"`
+		ExpiresAt *time.Time              `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	switch {
+	case request.Type != accounts.KindSuspension && request.Type != accounts.KindBan:
+		apierror.Error(w, "Type must be suspension or ban", http.StatusBadRequest)
+		return
+	case request.Reason == "":
+		apierror.Error(w, "A reason is required", http.StatusBadRequest)
+		return
+	case request.Type == accounts.KindBan && request.ExpiresAt != nil:
+		apierror.Error(w, "Bans are permanent; use a suspension for a temporary restriction", http.StatusBadRequest)
+		return
+	case request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()):
+		apierror.Error(w, "expiresAt must be in the future", http.StatusBadRequest)
+		return
+	case targetID == adminID:
+		apierror.Error(w, "You cannot suspend your own account", http.StatusBadRequest)
+		return
+	}
+
+	suspensionID, err := accounts.Suspend(h.db, targetID, adminID, request.Type, request.Reason, request.ExpiresAt)
+	if err != nil {
+		switch err {
+		case accounts.ErrNotFound:
+			apierror.Error(w, "User not found", http.StatusNotFound)
+		case accounts.ErrAlreadySuspended:
+			apierror.Error(w, "User already has an active suspension", http.StatusConflict)
+		default:
+			apierror.Error(w, "Failed to suspend user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	eventType := audit.EventUserSuspended
+	if request.Type == accounts.KindBan {
+		eventType = audit.EventUserBanned
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:     eventType,
+		Severity: audit.SeverityHigh,
+		UserID:   adminID,
+		EntityID: targetID,
+		Details: map[string]interface{}{
+			"suspensionId": suspensionID,
+			"reason":       request.Reason,
+			"expiresAt":    request.ExpiresAt,
+		},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      suspensionID,
+		"message": "User " + string(request.Type) + " applied",
+	})
+}
+
+func (h *AdminHandler) LiftSuspension(w http.ResponseWriter, r *http.Request) {
+	targetID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	if err := accounts.Lift(h.db, targetID, adminID); err != nil {
+		if err == accounts.ErrNotSuspended {
+			apierror.Error(w, "User has no active suspension", http.StatusNotFound)
+			return
+		}
+		apierror.Error(w, "Failed to lift suspension", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventSuspensionLifted,
+		Severity: audit.SeverityHigh,
+		UserID:   adminID,
+		EntityID: targetID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Suspension lifted",
+	})
+}
+
+// SuspensionHistory lists every suspension and ban placed on a user
+func (h *AdminHandler) SuspensionHistory(w http.ResponseWriter, r *http.Request) {
+	targetID := mux.Vars(r)["id"]
+
+	history, err := accounts.SuspensionHistory(h.db, targetID)
+	if err != nil {
+		apierror.Error(w, "Error fetching suspensions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// ListRoles describes each role and the permissions it grants
+func (h *AdminHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles := make([]map[string]interface{}, 0, len(middleware.Roles))
+	for _, role := range middleware.Roles {
+		roles = append(roles, map[string]interface{}{
+			"role":        role,
+			"permissions": role.Permissions(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"roles": roles})
+}
+
+// SetRole assigns a role to a user and signs them out everywhere so their
+// next tokens carry it
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	targetID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Role middleware.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !middleware.ValidRole(request.Role) {
+		apierror.Error(w, "Role must be donor, reporter, verifier or admin", http.StatusBadRequest)
+		return
+	}
+	// Keeps at least one admin and stops an admin locking themselves out
+	if targetID == adminID {
+		apierror.Error(w, "You cannot change your own role", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := accounts.ChangeRole(h.db, targetID, string(request.Role))
+	if err == accounts.ErrNotFound {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Failed to change role", http.StatusInternalServerError)
+		return
+	}
+
+	if previous != string(request.Role) {
+		h.auditLogger.Log(r, audit.Event{
+			Type:     audit.EventRoleChanged,
+			Severity: audit.SeverityHigh,
+			UserID:   adminID,
+			EntityID: targetID,
+			Details:  map[string]interface{}{"role": request.Role, "previousRole": previous, "source": "admin"},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":           targetID,
+		"role":         request.Role,
+		"previousRole": previous,
+		"permissions":  request.Role.Permissions(),
+	})
+}
+
+// UnlockUser clears a login lockout before it expires. The user is emailed
+// unless notify is false, so an unlock they did not ask for is noticed.
+func (h *AdminHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	targetID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	request := struct {
+		Notify *bool  `json:"notify"`
+		Reason string `json:"reason"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	notifyUser := request.Notify == nil || *request.Notify
+
+	lockedUntil, err := accounts.Unlock(h.db, targetID)
+	if err == accounts.ErrNotFound {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Failed to unlock account", http.StatusInternalServerError)
+		return
+	}
+
+	notified := false
+	if notifyUser {
+		err := h.mailer.Notify(r.Context(), h.db, targetID, notify.EventSecurityAlert, "account.unlocked", map[string]interface{}{
+			"Reason": request.Reason,
+		})
+		if err != nil {
+			log.Printf("Failed to send unlock notice to user %s: %v", targetID, err)
+		} else {
+			notified = true
+		}
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventAccountUnlocked,
+		Severity: audit.SeverityHigh,
+		UserID:   adminID,
+		EntityID: targetID,
+		Details: map[string]interface{}{
+			"lockedUntil": lockedUntil,
+			"reason":      request.Reason,
+			"notified":    notified,
+		},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":          targetID,
+		"wasLocked":   lockedUntil != nil,
+		"lockedUntil": lockedUntil,
+		"notified":    notified,
+		"message":     "Account unlocked",
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/apikeys"
+	"saferelief/internal/audit"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxAPIKeys              = 5
+	defaultAPIKeyDailyQuota = 10000
+)
+
+type APIKeyHandler struct {
+	db          *sql.DB
+	auditLogger *audit.Logger
+	dailyQuota  int
+}
+
+// NewAPIKeyHandler reads the daily request quota for new keys from
+// API_KEY_DAILY_QUOTA
+func NewAPIKeyHandler(db *sql.DB, auditLogger *audit.Logger) *APIKeyHandler {
+	quota, err := strconv.Atoi(os.Getenv("API_KEY_DAILY_QUOTA"))
+	if err != nil || quota <= 0 {
+		quota = defaultAPIKeyDailyQuota
+	}
+	return &APIKeyHandler{db: db, auditLogger: auditLogger, dailyQuota: quota}
+}
+
+// ListKeys returns the caller's active keys with today's usage
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(k.id), k.name, k.prefix, k.scopes, k.daily_quota, k.last_used_at,
+			k.created_at, COALESCE(u.requests, 0)
+		FROM api_keys k
+		LEFT JOIN api_key_usage u ON u.key_id = k.id AND u.day = UTC_DATE()
+		WHERE k.user_id = UUID_TO_BIN(?) AND k.revoked_at IS NULL
+		ORDER BY k.created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching API keys", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	type keyWithUsage struct {
+		apikeys.Key
+		RequestsToday int `json:"requestsToday"`
+	}
+	keys := []keyWithUsage{}
+	for rows.Next() {
+		var k keyWithUsage
+		var scopes []byte
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &k.DailyQuota, &k.LastUsedAt,
+			&k.CreatedAt, &k.RequestsToday); err != nil {
+			apierror.Error(w, "Error processing API keys", http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal(scopes, &k.Scopes)
+		keys = append(keys, k)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// CreateKey issues a key; the key itself is returned only in this response
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(request.Scopes) == 0 {
+		request.Scopes = []string{apikeys.ScopeOpenData}
+	}
+
+	v := validation.New()
+	if v.Required("name", request.Name) {
+		v.Length("name", request.Name, 1, 100)
+	}
+	for _, scope := range request.Scopes {
+		v.Check(apikeys.ValidScope(scope), "scopes", "unknown scope "+scope)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	var count int
+	if err := h.db.QueryRow(
+		"SELECT COUNT(*) FROM api_keys WHERE user_id = UUID_TO_BIN(?) AND revoked_at IS NULL",
+		userID,
+	).Scan(&count); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxAPIKeys {
+		apierror.Error(w, "API key limit reached; revoke an unused key first", http.StatusConflict)
+		return
+	}
+
+	key, prefix, hash, err := apikeys.Generate()
+	if err != nil {
+		apierror.Error(w, "Error generating API key", http.StatusInternalServerError)
+		return
+	}
+	scopes, _ := json.Marshal(request.Scopes)
+
+	var keyID string
+	err = h.db.QueryRow(
+		`INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, daily_quota)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?, ?)
+		RETURNING BIN_TO_UUID(id)`,
+		userID, request.Name, prefix, hash, scopes, h.dailyQuota,
+	).Scan(&keyID)
+	if err != nil {
+		apierror.Error(w, "Error creating API key", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventAPIKeyCreated,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "api_key",
+		EntityID:   keyID,
+		Details:    map[string]interface{}{"name": request.Name, "scopes": request.Scopes},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         keyID,
+		"name":       request.Name,
+		"prefix":     prefix,
+		"scopes":     request.Scopes,
+		"dailyQuota": h.dailyQuota,
+		"key":        key,
+	})
+}
+
+// RevokeKey disables a key immediately
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	keyID := mux.Vars(r)["id"]
+
+	result, err := h.db.Exec(
+		`UPDATE api_keys SET revoked_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?) AND revoked_at IS NULL`,
+		keyID, userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error revoking API key", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventAPIKeyRevoked,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "api_key",
+		EntityID:   keyID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "API key revoked"})
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+)
+
+const auditExportChunkSize = 500
+
+type AuditLog struct {
+	ID         string          `json:"id"`
+	UserID     *string         `json:"userId"`
+	Action     string          `json:"action"`
+	Severity   string          `json:"severity"`
+	EntityType string          `json:"entityType"`
+	EntityID   *string         `json:"entityId"`
+	IPAddress  string          `json:"ipAddress"`
+	UserAgent  *string         `json:"userAgent"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+type auditLogFilter struct {
+	action   string
+	severity string
+	userID   string
+	from     *time.Time
+	to       *time.Time
+}
+
+func parseAuditLogFilter(r *http.Request) (auditLogFilter, error) {
+	q := r.URL.Query()
+	filter := auditLogFilter{
+		action:   q.Get("action"),
+		severity: strings.ToUpper(q.Get("severity")),
+		userID:   q.Get("userId"),
+	}
+
+	if from := q.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, err
+		}
+		filter.from = &t
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, err
+		}
+		filter.to = &t
+	}
+
+	return filter, nil
+}
+
+func (f auditLogFilter) where() (string, []interface{}) {
+	clause := " WHERE 1=1"
+	args := []interface{}{}
+
+	if f.action != "" {
+		clause += " AND action = ?"
+		args = append(args, f.action)
+	}
+	if f.severity != "" {
+		clause += " AND severity = ?"
+		args = append(args, f.severity)
+	}
+	if f.userID != "" {
+		clause += " AND user_id = UUID_TO_BIN(?)"
+		args = append(args, f.userID)
+	}
+	if f.from != nil {
+		clause += " AND created_at >= ?"
+		args = append(args, *f.from)
+	}
+	if f.to != nil {
+		clause += " AND created_at < ?"
+		args = append(args, *f.to)
+	}
+
+	return clause, args
+}
+
+// ExportAuditLogs streams every audit log matching the filters as CSV or JSONL.
+// Rows are read in keyset-paginated chunks so large exports never sit in memory.
+func (h *AdminHandler) ExportAuditLogs(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "jsonl"
+	}
+	if format != "csv" && format != "jsonl" {
+		apierror.Error(w, "Format must be csv or jsonl", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseAuditLogFilter(r)
+	if err != nil {
+		apierror.Error(w, "Invalid date filter, expected RFC3339", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventAuditExport,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "audit_log",
+		Details: map[string]interface{}{
+			"format":   format,
+			"action":   filter.action,
+			"severity": filter.severity,
+			"userId":   filter.userID,
+			"from":     filter.from,
+			"to":       filter.to,
+		},
+	})
+
+	filename := "audit-logs-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+
+	var csvWriter *csv.Writer
+	if format == "csv" {
+		csvWriter = csv.NewWriter(w)
+		csvWriter.Write([]string{
+			"id", "user_id", "action", "severity", "entity_type", "entity_id",
+			"ip_address", "user_agent", "details", "created_at",
+		})
+	}
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	whereClause, whereArgs := filter.where()
+	var cursorTime time.Time
+	var cursorID string
+
+	for {
+		query := `SELECT BIN_TO_UUID(id), BIN_TO_UUID(user_id), action, severity, entity_type,
+			BIN_TO_UUID(entity_id), ip_address, user_agent, details, created_at
+			FROM audit_logs` + whereClause
+		args := append([]interface{}{}, whereArgs...)
+		if cursorID != "" {
+			query += " AND (created_at, id) > (?, UUID_TO_BIN(?))"
+			args = append(args, cursorTime, cursorID)
+		}
+		query += " ORDER BY created_at, id LIMIT ?"
+		args = append(args, auditExportChunkSize)
+
+		logs, err := h.queryAuditLogs(query, args...)
+		if err != nil {
+			// Headers are already sent, so the best we can do is cut the stream short
+			return
+		}
+
+		for _, entry := range logs {
+			if csvWriter != nil {
+				csvWriter.Write(entry.csvRecord())
+			} else {
+				encoder.Encode(entry)
+			}
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(logs) < auditExportChunkSize {
+			return
+		}
+		last := logs[len(logs)-1]
+		cursorTime, cursorID = last.CreatedAt, last.ID
+	}
+}
+
+func (h *AdminHandler) queryAuditLogs(query string, args ...interface{}) ([]AuditLog, error) {
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []AuditLog
+	for rows.Next() {
+		var entry AuditLog
+		var userID, entityID, userAgent sql.NullString
+		var details []byte
+		if err := rows.Scan(
+			&entry.ID, &userID, &entry.Action, &entry.Severity, &entry.EntityType,
+			&entityID, &entry.IPAddress, &userAgent, &details, &entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			entry.UserID = &userID.String
+		}
+		if entityID.Valid {
+			entry.EntityID = &entityID.String
+		}
+		if userAgent.Valid {
+			entry.UserAgent = &userAgent.String
+		}
+		if len(details) > 0 {
+			entry.Details = json.RawMessage(details)
+		}
+		logs = append(logs, entry)
+	}
+
+	return logs, rows.Err()
+}
+
+func (l AuditLog) csvRecord() []string {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return []string{
+		l.ID, deref(l.UserID), l.Action, l.Severity, l.EntityType, deref(l.EntityID),
+		l.IPAddress, deref(l.UserAgent), string(l.Details), l.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func (h *AdminHandler) GetAuditPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.auditLogger.Policy())
+}
+
+func (h *AdminHandler) UpdateAuditPolicy(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := audit.ParsePolicy(body)
+	if err != nil {
+		apierror.Error(w, "Invalid audit policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	previous := h.auditLogger.Policy()
+	if err := h.auditLogger.SetPolicy(policy); err != nil {
+		apierror.Error(w, "Invalid audit policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventPolicyUpdated,
+		Severity:   audit.SeverityHigh,
+		UserID:     userID,
+		EntityType: "audit_policy",
+		Details: map[string]interface{}{
+			"previous": previous,
+			"current":  policy,
+		},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// StreamAuditEvents pushes MEDIUM and higher audit events to the client as
+// Server-Sent Events. Clients may raise the floor with minSeverity and narrow
+// the feed with a comma-separated types list.
+func (h *AdminHandler) StreamAuditEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	minSeverity := audit.SeverityMedium
+	if s := audit.Severity(strings.ToUpper(r.URL.Query().Get("minSeverity"))); s != "" {
+		switch s {
+		case audit.SeverityMedium, audit.SeverityHigh, audit.SeverityCritical:
+			minSeverity = s
+		default:
+			apierror.Error(w, "minSeverity must be MEDIUM, HIGH or CRITICAL", http.StatusBadRequest)
+			return
+		}
+	}
+
+	types := map[string]bool{}
+	if raw := r.URL.Query().Get("types"); raw != "" {
+		for _, t := range strings.Split(raw, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types[t] = true
+			}
+		}
+	}
+
+	events := h.auditLogger.Subscribe()
+	defer h.auditLogger.Unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event := <-events:
+			if !event.Severity.AtLeast(minSeverity) {
+				continue
+			}
+			if len(types) > 0 && !types[event.Type] {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			io.WriteString(w, "event: "+event.Type+"\ndata: ")
+			w.Write(data)
+			io.WriteString(w, "\n\n")
+			flusher.Flush()
+		}
+	}
+}
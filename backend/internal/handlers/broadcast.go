@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/broadcast"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+type Broadcast struct {
+	ID             string                    `json:"id"`
+	Title          string                    `json:"title"`
+	Message        string                    `json:"message"`
+	Area           broadcast.Area            `json:"area"`
+	Channels       []string                  `json:"channels"`
+	Status         string                    `json:"status"`
+	RecipientCount int                       `json:"recipientCount"`
+	Progress       map[string]map[string]int `json:"progress,omitempty"`
+	CreatedBy      string                    `json:"createdBy"`
+	CreatedAt      time.Time                 `json:"createdAt"`
+	CompletedAt    *time.Time                `json:"completedAt"`
+}
+
+type broadcastRequest struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Area     broadcast.Area `json:"area"`
+	Channels []string       `json:"channels"`
+}
+
+type BroadcastHandler struct {
+	db          *sql.DB
+	broadcaster *broadcast.Broadcaster
+	auditLogger *audit.Logger
+}
+
+func NewBroadcastHandler(db *sql.DB, broadcaster *broadcast.Broadcaster, auditLogger *audit.Logger) *BroadcastHandler {
+	return &BroadcastHandler{db: db, broadcaster: broadcaster, auditLogger: auditLogger}
+}
+
+func (h *BroadcastHandler) decode(w http.ResponseWriter, r *http.Request) (*broadcastRequest, bool) {
+	var request broadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	v := validation.New()
+	if v.Required("title", request.Title) {
+		v.Length("title", request.Title, 1, 150)
+	}
+	if v.Required("message", request.Message) {
+		v.Length("message", request.Message, 1, 2000)
+	}
+	if err := request.Area.Validate(); err != nil {
+		v.AddError("area", err.Error())
+	}
+	v.Check(len(request.Channels) > 0, "channels", "must list at least one channel")
+	available := map[string]bool{}
+	for _, channel := range h.broadcaster.Channels() {
+		available[channel] = true
+	}
+	for _, channel := range request.Channels {
+		v.Check(available[channel], "channels", channel+" is not configured")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return nil, false
+	}
+	return &request, true
+}
+
+// Preview estimates the audience for a broadcast without sending anything
+func (h *BroadcastHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	request, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+
+	total, reach, err := h.broadcaster.Estimate(r.Context(), request.Area, request.Channels)
+	if err != nil {
+		apierror.Error(w, "Error estimating audience", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"audience": total,
+		"reach":    reach,
+		"title":    request.Title,
+		"message":  request.Message,
+	})
+}
+
+// SendBroadcast records the broadcast and queues delivery to everyone in the area
+func (h *BroadcastHandler) SendBroadcast(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("user_id").(string)
+	request, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+
+	area, _ := json.Marshal(request.Area)
+	channels, _ := json.Marshal(request.Channels)
+
+	var broadcastID string
+	err := h.db.QueryRow(
+		`INSERT INTO broadcasts (id, title, message, area, channels, created_by)
+		VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, ?, UUID_TO_BIN(?))
+		RETURNING BIN_TO_UUID(id)`,
+		request.Title, request.Message, area, channels, adminID,
+	).Scan(&broadcastID)
+	if err != nil {
+		apierror.Error(w, "Error creating broadcast", http.StatusInternalServerError)
+		return
+	}
+
+	recipients, err := h.broadcaster.Start(r.Context(), broadcastID, request.Area, request.Channels)
+	if err != nil {
+		h.db.Exec("UPDATE broadcasts SET status = 'failed' WHERE id = UUID_TO_BIN(?)", broadcastID)
+		apierror.Error(w, "Error starting broadcast", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventBroadcastSent,
+		Severity:   audit.SeverityCritical,
+		UserID:     adminID,
+		EntityType: "broadcast",
+		EntityID:   broadcastID,
+		Details: map[string]interface{}{
+			"title":      request.Title,
+			"area":       request.Area,
+			"channels":   request.Channels,
+			"recipients": recipients,
+		},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         broadcastID,
+		"recipients": recipients,
+		"message":    "Broadcast queued",
+	})
+}
+
+func (h *BroadcastHandler) ListBroadcasts(w http.ResponseWriter, r *http.Request) {
+	broadcasts, err := h.queryBroadcasts("ORDER BY b.created_at DESC LIMIT 100")
+	if err != nil {
+		apierror.Error(w, "Error fetching broadcasts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(broadcasts)
+}
+
+// GetBroadcast includes per-channel sent, failed and pending counts
+func (h *BroadcastHandler) GetBroadcast(w http.ResponseWriter, r *http.Request) {
+	broadcastID := mux.Vars(r)["id"]
+
+	broadcasts, err := h.queryBroadcasts("WHERE b.id = UUID_TO_BIN(?)", broadcastID)
+	if err != nil {
+		apierror.Error(w, "Error fetching broadcast", http.StatusInternalServerError)
+		return
+	}
+	if len(broadcasts) == 0 {
+		apierror.Error(w, "Broadcast not found", http.StatusNotFound)
+		return
+	}
+	b := broadcasts[0]
+
+	rows, err := h.db.Query(
+		`SELECT channel, status, COUNT(*) FROM broadcast_recipients
+		WHERE broadcast_id = UUID_TO_BIN(?) GROUP BY channel, status`,
+		broadcastID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching broadcast progress", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	b.Progress = map[string]map[string]int{}
+	for rows.Next() {
+		var channel, status string
+		var count int
+		if err := rows.Scan(&channel, &status, &count); err != nil {
+			apierror.Error(w, "Error processing broadcast progress", http.StatusInternalServerError)
+			return
+		}
+		if b.Progress[channel] == nil {
+			b.Progress[channel] = map[string]int{}
+		}
+		b.Progress[channel][status] = count
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b)
+}
+
+func (h *BroadcastHandler) queryBroadcasts(clause string, args ...interface{}) ([]Broadcast, error) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(b.id), b.title, b.message, b.area, b.channels, b.status,
+		b.recipient_count, BIN_TO_UUID(b.created_by), b.created_at, b.completed_at
+		FROM broadcasts b `+clause,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	broadcasts := []Broadcast{}
+	for rows.Next() {
+		var b Broadcast
+		var area, channels []byte
+		if err := rows.Scan(
+			&b.ID, &b.Title, &b.Message, &area, &channels, &b.Status,
+			&b.RecipientCount, &b.CreatedBy, &b.CreatedAt, &b.CompletedAt,
+		); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(area, &b.Area)
+		json.Unmarshal(channels, &b.Channels)
+		broadcasts = append(broadcasts, b)
+	}
+	return broadcasts, rows.Err()
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/alerts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+type ChatIntegration struct {
+	ID                string          `json:"id"`
+	Name              string          `json:"name"`
+	Provider          alerts.Provider `json:"provider"`
+	WebhookURL        string          `json:"webhookUrl,omitempty"`
+	Alerts            []string        `json:"alerts"`
+	MinSeverity       string          `json:"minSeverity"`
+	Currency          string          `json:"currency"`
+	FundingMilestones []float64       `json:"fundingMilestones"`
+	Active            bool            `json:"active"`
+	CreatedAt         time.Time       `json:"createdAt"`
+}
+
+type ChatIntegrationHandler struct {
+	db          *sql.DB
+	notifier    *alerts.Notifier
+	auditLogger *audit.Logger
+}
+
+func NewChatIntegrationHandler(db *sql.DB, notifier *alerts.Notifier, auditLogger *audit.Logger) *ChatIntegrationHandler {
+	return &ChatIntegrationHandler{db: db, notifier: notifier, auditLogger: auditLogger}
+}
+
+// ListIntegrations returns configured channels; webhook URLs are secrets and
+// are never echoed back
+func (h *ChatIntegrationHandler) ListIntegrations(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), name, provider, alerts, min_severity, currency,
+		funding_milestones, active, created_at
+		FROM chat_integrations ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching integrations", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	integrations := []ChatIntegration{}
+	for rows.Next() {
+		var in ChatIntegration
+		var alertList, milestones []byte
+		if err := rows.Scan(
+			&in.ID, &in.Name, &in.Provider, &alertList, &in.MinSeverity, &in.Currency,
+			&milestones, &in.Active, &in.CreatedAt,
+		); err != nil {
+			apierror.Error(w, "Error processing integrations", http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal(alertList, &in.Alerts)
+		json.Unmarshal(milestones, &in.FundingMilestones)
+		integrations = append(integrations, in)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(integrations)
+}
+
+func (h *ChatIntegrationHandler) CreateIntegration(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("user_id").(string)
+
+	var in ChatIntegration
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if in.MinSeverity == "" {
+		in.MinSeverity = "low"
+	}
+	in.Currency = strings.ToUpper(in.Currency)
+	if in.Currency == "" {
+		in.Currency = "IDR"
+	}
+	if in.FundingMilestones == nil {
+		in.FundingMilestones = []float64{}
+	}
+
+	v := validation.New()
+	if v.Required("name", in.Name) {
+		v.Length("name", in.Name, 1, 100)
+	}
+	if err := alerts.ValidateWebhookURL(in.Provider, in.WebhookURL); err != nil {
+		v.AddError("webhookUrl", err.Error())
+	}
+	v.Check(len(in.Alerts) > 0, "alerts", "must list at least one alert")
+	for _, alert := range in.Alerts {
+		v.Check(alerts.ValidAlert(alert), "alerts", "unknown alert "+alert)
+	}
+	v.Check(alerts.ValidSeverity(in.MinSeverity), "minSeverity", "must be low, medium, high or critical")
+	v.Check(len(in.Currency) == 3, "currency", "must be a 3-letter currency code")
+	for _, milestone := range in.FundingMilestones {
+		v.Check(milestone > 0, "fundingMilestones", "must be positive amounts")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	alertList, _ := json.Marshal(in.Alerts)
+	milestones, _ := json.Marshal(in.FundingMilestones)
+
+	var integrationID string
+	err := h.db.QueryRow(
+		`INSERT INTO chat_integrations (
+			id, name, provider, webhook_url, alerts, min_severity, currency, funding_milestones, created_by
+		) VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, ?, ?, ?, ?, UUID_TO_BIN(?))
+		RETURNING BIN_TO_UUID(id)`,
+		in.Name, in.Provider, in.WebhookURL, alertList, in.MinSeverity, in.Currency, milestones, adminID,
+	).Scan(&integrationID)
+	if err != nil {
+		apierror.Error(w, "Error creating integration", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventChatIntegrationChanged,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "chat_integration",
+		EntityID:   integrationID,
+		Details:    map[string]interface{}{"action": "created", "provider": in.Provider, "name": in.Name},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      integrationID,
+		"message": "Integration created",
+	})
+}
+
+func (h *ChatIntegrationHandler) DeleteIntegration(w http.ResponseWriter, r *http.Request) {
+	integrationID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	result, err := h.db.Exec("DELETE FROM chat_integrations WHERE id = UUID_TO_BIN(?)", integrationID)
+	if err != nil {
+		apierror.Error(w, "Error deleting integration", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Integration not found", http.StatusNotFound)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventChatIntegrationChanged,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "chat_integration",
+		EntityID:   integrationID,
+		Details:    map[string]interface{}{"action": "deleted"},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Integration deleted"})
+}
+
+// TestIntegration posts a sample card to the channel right away
+func (h *ChatIntegrationHandler) TestIntegration(w http.ResponseWriter, r *http.Request) {
+	var provider alerts.Provider
+	var webhookURL string
+	err := h.db.QueryRow(
+		"SELECT provider, webhook_url FROM chat_integrations WHERE id = UUID_TO_BIN(?)",
+		mux.Vars(r)["id"],
+	).Scan(&provider, &webhookURL)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Integration not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.notifier.Test(provider, webhookURL); err != nil {
+		apierror.Error(w, "Test alert failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Test alert sent"})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/consent"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type ConsentHandler struct {
+	db          *sql.DB
+	auditLogger *audit.Logger
+}
+
+func NewConsentHandler(db *sql.DB, auditLogger *audit.Logger) *ConsentHandler {
+	return &ConsentHandler{db: db, auditLogger: auditLogger}
+}
+
+// CurrentPolicies publishes the privacy policy and terms versions in effect
+func (h *ConsentHandler) CurrentPolicies(w http.ResponseWriter, r *http.Request) {
+	versions, err := consent.Current(h.db)
+	if err != nil {
+		apierror.Error(w, "Error fetching policies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// GetConsents lists the user's consent history and anything still outstanding
+func (h *ConsentHandler) GetConsents(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	rows, err := h.db.Query(
+		`SELECT document, version, consented_at FROM user_consents
+		WHERE user_id = UUID_TO_BIN(?) ORDER BY consented_at DESC`,
+		userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching consents", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	type consentRecord struct {
+		Document    string    `json:"document"`
+		Version     string    `json:"version"`
+		ConsentedAt time.Time `json:"consentedAt"`
+	}
+	history := []consentRecord{}
+	for rows.Next() {
+		var c consentRecord
+		if err := rows.Scan(&c.Document, &c.Version, &c.ConsentedAt); err != nil {
+			apierror.Error(w, "Error processing consents", http.StatusInternalServerError)
+			return
+		}
+		history = append(history, c)
+	}
+
+	outstanding, err := consent.Outstanding(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching consents", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"consents":    history,
+		"outstanding": outstanding,
+	})
+}
+
+// RecordConsent stores agreement to policy versions, e.g. {"privacy": "2024-06", "terms": "3"}
+func (h *ConsentHandler) RecordConsent(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var versions map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&versions); err != nil || len(versions) == 0 {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for document := range versions {
+		if document != consent.DocumentPrivacy && document != consent.DocumentTerms {
+			apierror.Error(w, "Unknown policy document: "+document, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := consent.Record(h.db, userID, audit.ClientIP(r), versions); err != nil {
+		if err == consent.ErrVersionMismatch {
+			apierror.Error(w, "Policy version is not current; reload the policies and try again", http.StatusConflict)
+			return
+		}
+		apierror.Error(w, "Error recording consent", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventConsentRecorded,
+		UserID:   userID,
+		EntityID: userID,
+		Details:  map[string]interface{}{"versions": versions},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Consent recorded",
+	})
+}
+
+// PublishPolicyVersion announces a new policy version. Once effectiveAt passes
+// every user is asked to consent again on their next request.
+func (h *ConsentHandler) PublishPolicyVersion(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("user_id").(string)
+
+	var pv consent.PolicyVersion
+	if err := json.NewDecoder(r.Body).Decode(&pv); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	pv.Version = strings.TrimSpace(pv.Version)
+	if pv.Document != consent.DocumentPrivacy && pv.Document != consent.DocumentTerms {
+		apierror.Error(w, "Document must be privacy or terms", http.StatusBadRequest)
+		return
+	}
+	if pv.Version == "" || !strings.HasPrefix(pv.URL, "https://") {
+		apierror.Error(w, "Version and an https:// URL are required", http.StatusBadRequest)
+		return
+	}
+	if pv.EffectiveAt.IsZero() {
+		pv.EffectiveAt = time.Now().UTC()
+	}
+
+	_, err := h.db.Exec(
+		`INSERT INTO policy_versions (id, document, version, url, effective_at)
+		VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, ?)`,
+		pv.Document, pv.Version, pv.URL, pv.EffectiveAt,
+	)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			apierror.Error(w, "Version already published", http.StatusConflict)
+			return
+		}
+		apierror.Error(w, "Error publishing policy version", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventPolicyVersionPublished,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "policy",
+		Details:    map[string]interface{}{"document": pv.Document, "version": pv.Version, "effectiveAt": pv.EffectiveAt},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pv)
+}
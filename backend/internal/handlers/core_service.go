@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/audit"
+	"saferelief/internal/fx"
+	"saferelief/internal/middleware"
+	"saferelief/internal/rpc"
+	"saferelief/internal/triage"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+)
+
+const coreServiceName = "saferelief.v1.CoreService"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// CoreService exposes report, donation and user operations to internal
+// services over gRPC. Message field numbers follow
+// proto/saferelief/v1/core.proto.
+type CoreService struct {
+	db          *sql.DB
+	auditLogger *audit.Logger
+	webhooks    *webhooks.Dispatcher
+}
+
+func NewCoreService(db *sql.DB, auditLogger *audit.Logger, dispatcher *webhooks.Dispatcher) *CoreService {
+	return &CoreService{db: db, auditLogger: auditLogger, webhooks: dispatcher}
+}
+
+func (s *CoreService) Register(server *rpc.Server) {
+	server.Register(coreServiceName, "GetReport", s.GetReport)
+	server.Register(coreServiceName, "CreateReport", s.CreateReport)
+	server.Register(coreServiceName, "RecordDonation", s.RecordDonation)
+	server.Register(coreServiceName, "GetUser", s.GetUser)
+	server.Register(coreServiceName, "CheckPermission", s.CheckPermission)
+}
+
+func (s *CoreService) GetReport(ctx context.Context, req rpc.Message) ([]byte, error) {
+	id := req.String(1)
+	if !uuidPattern.MatchString(id) {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "id must be a UUID")
+	}
+	return s.encodeReport(ctx, id)
+}
+
+func (s *CoreService) encodeReport(ctx context.Context, id string) ([]byte, error) {
+	var reporterID, title, description, disasterType, severity, status string
+	var organizationID sql.NullString
+	var latitude, longitude float64
+	var createdAt, updatedAt time.Time
+	var verifiedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT BIN_TO_UUID(reporter_id), BIN_TO_UUID(organization_id), title, description, disaster_type,
+			latitude, longitude, severity, status, created_at, updated_at, verified_at
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL`,
+		id,
+	).Scan(&reporterID, &organizationID, &title, &description, &disasterType, &latitude, &longitude,
+		&severity, &status, &createdAt, &updatedAt, &verifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, rpc.Errorf(rpc.NotFound, "report not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e := &rpc.Encoder{}
+	e.String(1, id).String(2, reporterID).String(3, organizationID.String).
+		String(4, title).String(5, description).
+		Double(6, latitude).Double(7, longitude).
+		String(8, severity).String(9, status).
+		Int64(10, createdAt.Unix()).Int64(11, updatedAt.Unix())
+	if verifiedAt.Valid {
+		e.Int64(12, verifiedAt.Time.Unix())
+	}
+	e.String(13, disasterType)
+	return e.Bytes(), nil
+}
+
+func (s *CoreService) CreateReport(ctx context.Context, req rpc.Message) ([]byte, error) {
+	reporterID := req.String(1)
+	title, description := strings.TrimSpace(req.String(2)), strings.TrimSpace(req.String(3))
+	latitude, longitude := req.Double(4), req.Double(5)
+	severity := req.String(6)
+	disasterType := req.String(7)
+	if disasterType == "" {
+		disasterType = defaultDisasterType
+	}
+
+	v := validation.New()
+	v.Check(uuidPattern.MatchString(reporterID), "reporter_id", "must be a UUID")
+	if v.Required("title", title) {
+		v.Length("title", title, 1, 255)
+	}
+	v.Required("description", description)
+	v.Check(latitude >= -90 && latitude <= 90, "latitude", "must be between -90 and 90")
+	v.Check(longitude >= -180 && longitude <= 180, "longitude", "must be between -180 and 180")
+	v.Check(severity == "low" || severity == "medium" || severity == "high" || severity == "critical",
+		"severity", "must be low, medium, high or critical")
+	known, err := validDisasterType(s.db, disasterType)
+	if err != nil {
+		return nil, err
+	}
+	v.Check(known, "disaster_type", "is not a known disaster type")
+	if !v.Valid() {
+		return nil, invalidArgument(v)
+	}
+
+	if err := s.checkActingUser(ctx, reporterID, middleware.PermCreateReport); err != nil {
+		return nil, err
+	}
+
+	var reportID string
+	err = s.db.QueryRowContext(ctx,
+		`INSERT INTO disaster_reports (id, reporter_id, title, description, disaster_type, latitude, longitude, severity, status)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?, 'pending')
+		RETURNING BIN_TO_UUID(id)`,
+		reporterID, title, description, disasterType, latitude, longitude, severity,
+	).Scan(&reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditLogger.Log(nil, audit.Event{
+		Type:       audit.EventInternalServiceCall,
+		Severity:   audit.SeverityMedium,
+		UserID:     reporterID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+		Details:    map[string]interface{}{"client": rpc.ClientIdentity(ctx), "method": "CreateReport"},
+	})
+	s.webhooks.Publish(webhooks.EventReportCreated, reportWebhookRecipients(s.db, reportID),
+		map[string]interface{}{
+			"id":       reportID,
+			"title":    title,
+			"severity": severity,
+			"status":   "pending",
+		},
+	)
+	if _, err := triage.ScoreNew(ctx, s.db, reportID); err != nil {
+		log.Printf("Failed to score report %s: %v", reportID, err)
+	}
+
+	return s.encodeReport(ctx, reportID)
+}
+
+func (s *CoreService) RecordDonation(ctx context.Context, req rpc.Message) ([]byte, error) {
+	donorID, reportID := req.String(1), req.String(2)
+	amount := req.Double(3)
+	currency := strings.ToUpper(req.String(4))
+	if currency == "" {
+		currency = "IDR"
+	}
+	description, paymentMethod := req.String(5), req.String(6)
+
+	v := validation.New()
+	v.Check(uuidPattern.MatchString(donorID), "donor_id", "must be a UUID")
+	v.Check(uuidPattern.MatchString(reportID), "disaster_report_id", "must be a UUID")
+	v.Check(amount > 0, "amount", "must be positive")
+	if c, err := fx.Lookup(ctx, s.db, currency); err == fx.ErrUnknownCurrency {
+		v.AddError("currency", "must be an ISO 4217 code")
+	} else if err != nil {
+		return nil, err
+	} else if message := c.CheckAmount(amount); message != "" {
+		v.AddError("amount", message)
+	} else if amount > 0 {
+		if err := checkDonationLimits(ctx, s.db, v, currency, amount, paymentMethod); err != nil {
+			return nil, err
+		}
+	}
+	v.Check(len(paymentMethod) <= 50, "payment_method", "must be at most 50 characters")
+	if !v.Valid() {
+		return nil, invalidArgument(v)
+	}
+
+	if err := s.checkActingUser(ctx, donorID, middleware.PermCreateDonation); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var reportStatus ReportStatus
+	var fundraisingClosed bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, fundraising_closed_at IS NOT NULL FROM disaster_reports
+		WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
+		reportID,
+	).Scan(&reportStatus, &fundraisingClosed)
+	if err == sql.ErrNoRows {
+		return nil, rpc.Errorf(rpc.NotFound, "report not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !reportStatus.AcceptsDonations() {
+		return nil, rpc.Errorf(rpc.FailedPrecondition, "cannot donate to a report that is not verified or is already resolved")
+	}
+	if fundraisingClosed {
+		return nil, rpc.Errorf(rpc.FailedPrecondition, "the report reached its fundraising goal and no longer takes donations")
+	}
+
+	transactionID := generateTransactionID()
+	var donationID string
+	var createdAt time.Time
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO donations (
+			id, donor_id, disaster_report_id, amount, currency,
+			description, status, transaction_id, payment_method
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?,
+			?, 'pending', ?, ?
+		) RETURNING BIN_TO_UUID(id), created_at`,
+		donorID, reportID, amount, currency, description, transactionID, paymentMethod,
+	).Scan(&donationID, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := fx.NormalizeDonation(ctx, tx, donationID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.auditLogger.Log(nil, audit.Event{
+		Type:       audit.EventInternalServiceCall,
+		Severity:   audit.SeverityMedium,
+		UserID:     donorID,
+		EntityType: "donation",
+		EntityID:   donationID,
+		Details: map[string]interface{}{
+			"client": rpc.ClientIdentity(ctx), "method": "RecordDonation",
+			"amount": amount, "currency": currency,
+		},
+	})
+	s.webhooks.Publish(webhooks.EventDonationCreated,
+		reportWebhookRecipients(s.db, reportID, donorID),
+		map[string]interface{}{
+			"id":               donationID,
+			"disasterReportId": reportID,
+			"amount":           amount,
+			"currency":         currency,
+			"status":           "pending",
+		},
+	)
+
+	return (&rpc.Encoder{}).
+		String(1, donationID).String(2, donorID).String(3, reportID).
+		Double(4, amount).String(5, currency).String(6, "pending").
+		String(7, transactionID).String(8, paymentMethod).
+		Int64(9, createdAt.Unix()).
+		Bytes(), nil
+}
+
+func (s *CoreService) GetUser(ctx context.Context, req rpc.Message) ([]byte, error) {
+	id := req.String(1)
+	if !uuidPattern.MatchString(id) {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "id must be a UUID")
+	}
+
+	var username, email string
+	var role middleware.Role
+	var mfaEnabled bool
+	var createdAt time.Time
+	err := s.db.QueryRowContext(ctx,
+		`SELECT username, email, role, mfa_enabled, created_at
+		FROM users WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL`,
+		id,
+	).Scan(&username, &email, &role, &mfaEnabled, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, rpc.Errorf(rpc.NotFound, "user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suspension, err := accounts.ActiveSuspension(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]string, 0, len(role.Permissions()))
+	for _, p := range role.Permissions() {
+		permissions = append(permissions, string(p))
+	}
+	return (&rpc.Encoder{}).
+		String(1, id).String(2, username).String(3, email).String(4, string(role)).
+		Strings(5, permissions).Bool(6, mfaEnabled).Bool(7, suspension != nil).
+		Int64(8, createdAt.Unix()).
+		Bytes(), nil
+}
+
+func (s *CoreService) CheckPermission(ctx context.Context, req rpc.Message) ([]byte, error) {
+	userID, permission := req.String(1), middleware.Permission(req.String(2))
+	if !uuidPattern.MatchString(userID) {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "user_id must be a UUID")
+	}
+	if permission == "" {
+		return nil, rpc.Errorf(rpc.InvalidArgument, "permission is required")
+	}
+
+	role, suspended, err := s.lookupActingUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return (&rpc.Encoder{}).
+		Bool(1, !suspended && role.Can(permission)).
+		String(2, string(role)).
+		Bool(3, suspended).
+		Bytes(), nil
+}
+
+// lookupActingUser returns the role of a live account and whether a
+// suspension or ban is in force on it
+func (s *CoreService) lookupActingUser(ctx context.Context, userID string) (middleware.Role, bool, error) {
+	var role middleware.Role
+	err := s.db.QueryRowContext(ctx,
+		"SELECT role FROM users WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL",
+		userID,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", false, rpc.Errorf(rpc.NotFound, "user not found")
+	}
+	if err != nil {
+		return "", false, err
+	}
+	suspension, err := accounts.ActiveSuspension(s.db, userID)
+	if err != nil {
+		return "", false, err
+	}
+	return role, suspension != nil, nil
+}
+
+// checkActingUser applies the same role and suspension rules as the HTTP API
+// to the user a call acts for
+func (s *CoreService) checkActingUser(ctx context.Context, userID string, permission middleware.Permission) error {
+	role, suspended, err := s.lookupActingUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if suspended {
+		return rpc.Errorf(rpc.PermissionDenied, "account is suspended")
+	}
+	if !role.Can(permission) {
+		return rpc.Errorf(rpc.PermissionDenied, "role %s lacks the %s permission", role, permission)
+	}
+	return nil
+}
+
+func invalidArgument(v *validation.Validator) error {
+	problems := make([]string, len(v.Errors))
+	for i, e := range v.Errors {
+		problems[i] = e.Field + " " + e.Message
+	}
+	return rpc.Errorf(rpc.InvalidArgument, "%s", strings.Join(problems, "; "))
+}
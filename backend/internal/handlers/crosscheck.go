@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/crosscheck"
+
+	"github.com/gorilla/mux"
+)
+
+type CrossCheck struct {
+	Source    string             `json:"source"`
+	Status    string             `json:"status"`
+	BestScore *float64           `json:"bestScore"`
+	Matches   []crosscheck.Match `json:"matches"`
+	LastError *string            `json:"lastError,omitempty"`
+	CheckedAt time.Time          `json:"checkedAt"`
+}
+
+type CrossCheckHandler struct {
+	db     *sql.DB
+	source crosscheck.Source
+}
+
+// NewCrossCheckHandler accepts a nil source when no agency is configured
+func NewCrossCheckHandler(db *sql.DB, source crosscheck.Source) *CrossCheckHandler {
+	return &CrossCheckHandler{db: db, source: source}
+}
+
+// GetCrossCheck returns the latest comparison with official records
+func (h *CrossCheckHandler) GetCrossCheck(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	var c CrossCheck
+	var matches []byte
+	err := h.db.QueryRow(
+		`SELECT source, status, best_score, matches, last_error, checked_at
+		FROM report_crosschecks WHERE report_id = UUID_TO_BIN(?)`,
+		reportID,
+	).Scan(&c.Source, &c.Status, &c.BestScore, &matches, &c.LastError, &c.CheckedAt)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report has not been cross-checked yet", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching cross-check", http.StatusInternalServerError)
+		return
+	}
+	c.Matches = []crosscheck.Match{}
+	if matches != nil {
+		json.Unmarshal(matches, &c.Matches)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// RunCrossCheck compares the report again now, e.g. once the agency has
+// published its records
+func (h *CrossCheckHandler) RunCrossCheck(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	if h.source == nil {
+		apierror.Error(w, "No official data source is configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := crosscheck.Check(ctx, h.db, h.source, reportID); err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		apierror.Error(w, "Official records could not be reached: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	h.GetCrossCheck(w, r)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/fx"
+)
+
+type CurrencyHandler struct {
+	db *sql.DB
+}
+
+func NewCurrencyHandler(db *sql.DB) *CurrencyHandler {
+	return &CurrencyHandler{db: db}
+}
+
+// ListCurrencies returns the ISO 4217 currencies donations accept, with
+// their decimals and latest rate against the dollar
+func (h *CurrencyHandler) ListCurrencies(w http.ResponseWriter, r *http.Request) {
+	currencies, err := fx.List(r.Context(), h.db)
+	if err != nil {
+		apierror.Error(w, "Error fetching currencies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currencies)
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/broadcast"
+	"saferelief/internal/jobs"
+	"saferelief/internal/notify"
+	"saferelief/internal/webhooks"
+)
+
+const maxRetryBatch = 500
+
+// deliverySources exposes each channel's delivery log with the same columns
+// so the dashboard can list and count them together. Statuses are
+// normalized to pending, sent and failed; failed means every automatic
+// attempt is spent and the message is dead-lettered.
+var deliverySources = map[string]string{
+	"email": `SELECT 'email' AS channel, BIN_TO_UUID(id) AS id, template_key AS kind,
+		recipient, status, attempts, last_error, last_attempt_at, created_at
+		FROM notification_deliveries`,
+	"webhook": `SELECT 'webhook' AS channel, BIN_TO_UUID(wd.id) AS id, wd.event AS kind,
+		e.url AS recipient, IF(wd.status = 'succeeded', 'sent', wd.status) AS status,
+		wd.attempts, wd.last_error, wd.last_attempt_at, wd.created_at
+		FROM webhook_deliveries wd
+		JOIN webhook_endpoints e ON e.id = wd.endpoint_id`,
+	// Broadcast messages are retried per broadcast, so the id is the broadcast's
+	"broadcast": `SELECT 'broadcast' AS channel, BIN_TO_UUID(br.broadcast_id) AS id,
+		CONCAT('broadcast.', br.channel) AS kind, br.address AS recipient, br.status,
+		IF(br.status = 'pending', 0, 1) AS attempts, br.last_error,
+		br.sent_at AS last_attempt_at, b.created_at
+		FROM broadcast_recipients br
+		JOIN broadcasts b ON b.id = br.broadcast_id`,
+}
+
+var deliveryChannels = []string{"email", "webhook", "broadcast"}
+
+type DeliveryAttempt struct {
+	Channel       string     `json:"channel"`
+	ID            string     `json:"id"`
+	Kind          string     `json:"kind"`
+	Recipient     string     `json:"recipient"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"lastError"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+type ChannelHealth struct {
+	Channel       string     `json:"channel"`
+	Total         int        `json:"total"`
+	Sent          int        `json:"sent"`
+	Failed        int        `json:"failed"`
+	Pending       int        `json:"pending"`
+	SuccessRate   *float64   `json:"successRate"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt"`
+	LastFailureAt *time.Time `json:"lastFailureAt"`
+	DeadLetters   int        `json:"deadLetters"`
+}
+
+type DeliveryHandler struct {
+	db          *sql.DB
+	mailer      *notify.Mailer
+	webhooks    *webhooks.Dispatcher
+	broadcaster *broadcast.Broadcaster
+	queue       *jobs.Queue
+	auditLogger *audit.Logger
+}
+
+func NewDeliveryHandler(db *sql.DB, mailer *notify.Mailer, dispatcher *webhooks.Dispatcher, broadcaster *broadcast.Broadcaster, queue *jobs.Queue, auditLogger *audit.Logger) *DeliveryHandler {
+	return &DeliveryHandler{
+		db:          db,
+		mailer:      mailer,
+		webhooks:    dispatcher,
+		broadcaster: broadcaster,
+		queue:       queue,
+		auditLogger: auditLogger,
+	}
+}
+
+// deliverySource returns the log for one channel, or all of them for ""
+func deliverySource(channel string) (string, bool) {
+	if channel == "" {
+		parts := make([]string, 0, len(deliveryChannels))
+		for _, c := range deliveryChannels {
+			parts = append(parts, deliverySources[c])
+		}
+		return strings.Join(parts, " UNION ALL "), true
+	}
+	source, ok := deliverySources[channel]
+	return source, ok
+}
+
+// ListDeliveries returns delivery attempts across channels, newest first,
+// filtered by ?channel= and ?status=
+func (h *DeliveryHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	h.listDeliveries(w, r, r.URL.Query().Get("status"))
+}
+
+// ListDeadLetters returns messages whose automatic retries are exhausted
+func (h *DeliveryHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	h.listDeliveries(w, r, "failed")
+}
+
+func (h *DeliveryHandler) listDeliveries(w http.ResponseWriter, r *http.Request, status string) {
+	query := r.URL.Query()
+	source, ok := deliverySource(query.Get("channel"))
+	if !ok {
+		apierror.Error(w, "Unknown channel", http.StatusBadRequest)
+		return
+	}
+	if status != "" && status != "pending" && status != "sent" && status != "failed" {
+		apierror.Error(w, "Status must be pending, sent or failed", http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := h.db.QueryContext(r.Context(),
+		`SELECT channel, id, kind, recipient, status, attempts, last_error, last_attempt_at, created_at
+		FROM (`+source+`) d
+		WHERE (? = '' OR d.status = ?)
+		ORDER BY d.created_at DESC LIMIT ? OFFSET ?`,
+		status, status, limit, offset,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching deliveries", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []DeliveryAttempt{}
+	for rows.Next() {
+		var d DeliveryAttempt
+		if err := rows.Scan(
+			&d.Channel, &d.ID, &d.Kind, &d.Recipient, &d.Status, &d.Attempts,
+			&d.LastError, &d.LastAttemptAt, &d.CreatedAt,
+		); err != nil {
+			apierror.Error(w, "Error processing deliveries", http.StatusInternalServerError)
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": deliveries,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// Health summarizes each channel over the last ?hours= (default 24), with
+// the all-time dead-letter backlog and the background queue depth
+func (h *DeliveryHandler) Health(w http.ResponseWriter, r *http.Request) {
+	hours := 24
+	if v, err := strconv.Atoi(r.URL.Query().Get("hours")); err == nil && v > 0 && v <= 24*30 {
+		hours = v
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	source, _ := deliverySource("")
+
+	health := map[string]*ChannelHealth{}
+	for _, channel := range deliveryChannels {
+		health[channel] = &ChannelHealth{Channel: channel}
+	}
+
+	rows, err := h.db.QueryContext(r.Context(),
+		`SELECT channel, COUNT(*),
+			COALESCE(SUM(status = 'sent'), 0), COALESCE(SUM(status = 'failed'), 0),
+			COALESCE(SUM(status = 'pending'), 0),
+			MAX(IF(status = 'sent', last_attempt_at, NULL)),
+			MAX(IF(status = 'failed', last_attempt_at, NULL))
+		FROM (`+source+`) d
+		WHERE created_at >= ?
+		GROUP BY channel`,
+		since,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching delivery health", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var channel string
+		var c ChannelHealth
+		if err := rows.Scan(&channel, &c.Total, &c.Sent, &c.Failed, &c.Pending,
+			&c.LastSuccessAt, &c.LastFailureAt); err != nil {
+			apierror.Error(w, "Error processing delivery health", http.StatusInternalServerError)
+			return
+		}
+		c.Channel = channel
+		if finished := c.Sent + c.Failed; finished > 0 {
+			rate := float64(c.Sent) / float64(finished)
+			c.SuccessRate = &rate
+		}
+		health[channel] = &c
+	}
+
+	deadRows, err := h.db.QueryContext(r.Context(),
+		`SELECT channel, COUNT(*) FROM (`+source+`) d WHERE status = 'failed' GROUP BY channel`,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching dead letters", http.StatusInternalServerError)
+		return
+	}
+	defer deadRows.Close()
+	for deadRows.Next() {
+		var channel string
+		var count int
+		if deadRows.Scan(&channel, &count) == nil {
+			health[channel].DeadLetters = count
+		}
+	}
+
+	channels := make([]*ChannelHealth, 0, len(deliveryChannels))
+	for _, channel := range deliveryChannels {
+		channels = append(channels, health[channel])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"windowHours": hours,
+		"channels":    channels,
+		"queueDepth":  h.queue.Pending(),
+	})
+}
+
+// RetryDeliveries requeues dead-lettered messages on one channel, either the
+// given ids or, with "all", the most recent ones up to the batch limit
+func (h *DeliveryHandler) RetryDeliveries(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("user_id").(string)
+
+	var req struct {
+		Channel string   `json:"channel"`
+		IDs     []string `json:"ids"`
+		All     bool     `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	source, ok := deliverySources[req.Channel]
+	if !ok {
+		apierror.Error(w, "Channel must be email, webhook or broadcast", http.StatusBadRequest)
+		return
+	}
+	if req.All == (len(req.IDs) > 0) {
+		apierror.Error(w, "Provide either ids or all", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxRetryBatch {
+		apierror.Error(w, "Too many ids in one request", http.StatusBadRequest)
+		return
+	}
+
+	// Only dead letters are retried; unknown or already delivered ids are skipped
+	query := `SELECT DISTINCT id FROM (` + source + `) d WHERE status = 'failed'`
+	args := []interface{}{}
+	if !req.All {
+		query += " AND id IN (?" + strings.Repeat(", ?", len(req.IDs)-1) + ")"
+		for _, id := range req.IDs {
+			args = append(args, id)
+		}
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, maxRetryBatch)
+
+	rows, err := h.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		apierror.Error(w, "Error fetching dead letters", http.StatusInternalServerError)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	queued := 0
+	switch req.Channel {
+	case "email":
+		if len(ids) > 0 {
+			err = h.queue.Enqueue(jobs.Job{
+				Name: "email-retry",
+				Run: func(ctx context.Context) error {
+					for _, id := range ids {
+						if err := h.mailer.Retry(ctx, id); err != nil {
+							log.Printf("Retry of email delivery %s failed: %v", id, err)
+						}
+					}
+					return nil
+				},
+			})
+			if err != nil {
+				apierror.Error(w, "Retry queue is full, try again later", http.StatusServiceUnavailable)
+				return
+			}
+			queued = len(ids)
+		}
+	case "webhook":
+		for _, id := range ids {
+			if err := h.webhooks.Redeliver(id); err == nil {
+				queued++
+			}
+		}
+	case "broadcast":
+		for _, id := range ids {
+			n, err := h.broadcaster.Retry(r.Context(), id)
+			if err != nil {
+				log.Printf("Failed to retry broadcast %s: %v", id, err)
+				continue
+			}
+			queued += n
+		}
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDeliveryRetried,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "notification_delivery",
+		Details:    map[string]interface{}{"channel": req.Channel, "ids": ids, "queued": queued},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": req.Channel,
+		"retried": ids,
+		"queued":  queued,
+	})
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/sessions"
+
+	"github.com/gorilla/mux"
+)
+
+type DeviceHandler struct {
+	db          *sql.DB
+	auditLogger *audit.Logger
+}
+
+func NewDeviceHandler(db *sql.DB, auditLogger *audit.Logger) *DeviceHandler {
+	return &DeviceHandler{db: db, auditLogger: auditLogger}
+}
+
+// ListDevices returns devices with active sessions or MFA trust
+func (h *DeviceHandler) ListDevices(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	sessionID := r.Context().Value("session_id").(string)
+
+	currentDeviceID, err := sessions.DeviceForSession(h.db, sessionID)
+	if err != nil && err != sql.ErrNoRows {
+		apierror.Error(w, "Error fetching devices", http.StatusInternalServerError)
+		return
+	}
+
+	devices, err := sessions.ListDevices(h.db, userID, currentDeviceID)
+	if err != nil {
+		apierror.Error(w, "Error fetching devices", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(devices)
+}
+
+// RevokeDevice signs the device out and removes its MFA trust
+func (h *DeviceHandler) RevokeDevice(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	deviceID := mux.Vars(r)["id"]
+
+	if err := sessions.RevokeDevice(h.db, userID, deviceID); err != nil {
+		if err == sessions.ErrNotFound {
+			apierror.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		apierror.Error(w, "Error revoking device", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDeviceRevoked,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "device",
+		EntityID:   deviceID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Device revoked",
+	})
+}
+
+// UntrustDevice makes the device ask for an MFA code again at its next login
+// without signing it out
+func (h *DeviceHandler) UntrustDevice(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	deviceID := mux.Vars(r)["id"]
+
+	if err := sessions.Untrust(h.db, userID, deviceID); err != nil {
+		if err == sessions.ErrNotFound {
+			apierror.Error(w, "Trusted device not found", http.StatusNotFound)
+			return
+		}
+		apierror.Error(w, "Error revoking device trust", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDeviceUntrusted,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "device",
+		EntityID:   deviceID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Device will ask for an MFA code at its next login",
+	})
+}
+
+// UntrustAllDevices withdraws MFA trust from every device, including this one
+func (h *DeviceHandler) UntrustAllDevices(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	untrusted, err := sessions.UntrustAll(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Error revoking device trust", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDeviceUntrusted,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "device",
+		Details:    map[string]interface{}{"scope": "all_devices", "untrusted": untrusted},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "All devices will ask for an MFA code at their next login",
+		"untrusted": untrusted,
+	})
+}
+
+// RevokeOtherDevices signs out every device except the one making the request
+func (h *DeviceHandler) RevokeOtherDevices(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	sessionID := r.Context().Value("session_id").(string)
+
+	currentDeviceID, err := sessions.DeviceForSession(h.db, sessionID)
+	if err != nil && err != sql.ErrNoRows {
+		apierror.Error(w, "Error revoking devices", http.StatusInternalServerError)
+		return
+	}
+	devices, err := sessions.ListDevices(h.db, userID, currentDeviceID)
+	if err != nil {
+		apierror.Error(w, "Error revoking devices", http.StatusInternalServerError)
+		return
+	}
+
+	revoked := 0
+	for _, device := range devices {
+		if device.Current {
+			continue
+		}
+		if err := sessions.RevokeDevice(h.db, userID, device.ID); err != nil {
+			apierror.Error(w, "Error revoking devices", http.StatusInternalServerError)
+			return
+		}
+		revoked++
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDeviceRevoked,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "device",
+		Details:    map[string]interface{}{"scope": "all_other_devices", "revoked": revoked},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Other devices revoked",
+		"revoked": revoked,
+	})
+}
+
+// ListSessions returns every signed-in session with its device, IP and last use
+func (h *DeviceHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	sessionID := r.Context().Value("session_id").(string)
+
+	list, err := sessions.List(h.db, userID, sessionID)
+	if err != nil {
+		apierror.Error(w, "Error fetching sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// RevokeSession signs out a single session; the device keeps its other
+// sessions and any MFA trust
+func (h *DeviceHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	targetID := mux.Vars(r)["id"]
+
+	if targetID == r.Context().Value("session_id").(string) {
+		apierror.Error(w, "Use logout to end the current session", http.StatusBadRequest)
+		return
+	}
+
+	if err := sessions.RevokeForUser(h.db, userID, targetID); err != nil {
+		if err == sessions.ErrSessionNotFound {
+			apierror.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		apierror.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventSessionRevoked,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "session",
+		EntityID:   targetID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Session revoked",
+	})
+}
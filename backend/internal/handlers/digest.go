@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/digest"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+const maxFollowedRegions = 10
+
+type FollowedRegion struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	RadiusKm  float64   `json:"radiusKm"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type DigestHandler struct {
+	db *sql.DB
+}
+
+func NewDigestHandler(db *sql.DB) *DigestHandler {
+	return &DigestHandler{db: db}
+}
+
+// GetSettings returns the weekly digest preferences and followed regions
+func (h *DigestHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	prefs, err := digest.LoadPreferences(r.Context(), h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching digest settings", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), name, latitude, longitude, radius_km, created_at
+		FROM followed_regions WHERE user_id = UUID_TO_BIN(?) ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching followed regions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	regions := []FollowedRegion{}
+	for rows.Next() {
+		var region FollowedRegion
+		if err := rows.Scan(&region.ID, &region.Name, &region.Latitude, &region.Longitude, &region.RadiusKm, &region.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing followed regions", http.StatusInternalServerError)
+			return
+		}
+		regions = append(regions, region)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preferences": prefs,
+		"regions":     regions,
+	})
+}
+
+func (h *DigestHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var prefs digest.Preferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	_, err := h.db.Exec(
+		`INSERT INTO digest_subscriptions (user_id, enabled, new_disasters, funding_progress, delivery_updates)
+		VALUES (UUID_TO_BIN(?), ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE enabled = VALUES(enabled), new_disasters = VALUES(new_disasters),
+		funding_progress = VALUES(funding_progress), delivery_updates = VALUES(delivery_updates)`,
+		userID, prefs.Enabled, prefs.NewDisasters, prefs.FundingProgress, prefs.DeliveryUpdates,
+	)
+	if err != nil {
+		apierror.Error(w, "Error saving digest preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+func (h *DigestHandler) FollowRegion(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var region FollowedRegion
+	if err := json.NewDecoder(r.Body).Decode(&region); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	if v.Required("name", region.Name) {
+		v.Length("name", region.Name, 1, 100)
+	}
+	v.Check(region.Latitude >= -90 && region.Latitude <= 90, "latitude", "must be between -90 and 90")
+	v.Check(region.Longitude >= -180 && region.Longitude <= 180, "longitude", "must be between -180 and 180")
+	v.Check(region.RadiusKm > 0 && region.RadiusKm <= 500, "radiusKm", "must be between 0 and 500")
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	var count int
+	if err := h.db.QueryRow(
+		"SELECT COUNT(*) FROM followed_regions WHERE user_id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&count); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxFollowedRegions {
+		apierror.Error(w, "You can follow at most 10 regions", http.StatusConflict)
+		return
+	}
+
+	var regionID string
+	err := h.db.QueryRow(
+		`INSERT INTO followed_regions (id, user_id, name, latitude, longitude, radius_km)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?)
+		RETURNING BIN_TO_UUID(id)`,
+		userID, region.Name, region.Latitude, region.Longitude, region.RadiusKm,
+	).Scan(&regionID)
+	if err != nil {
+		apierror.Error(w, "Error saving region", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      regionID,
+		"message": "Region followed",
+	})
+}
+
+func (h *DigestHandler) UnfollowRegion(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	result, err := h.db.Exec(
+		"DELETE FROM followed_regions WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)",
+		mux.Vars(r)["id"], userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error removing region", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Region not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Region unfollowed"})
+}
+
+// Preview compiles the digest the user would receive for the past week
+func (h *DigestHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	prefs, err := digest.LoadPreferences(r.Context(), h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching digest settings", http.StatusInternalServerError)
+		return
+	}
+	d, err := digest.Compile(r.Context(), h.db, userID, prefs, time.Now().Add(-digest.Period))
+	if err != nil {
+		apierror.Error(w, "Error compiling digest", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"saferelief/internal/apierror"
+)
+
+// defaultDisasterType is stored when a report does not say what happened
+const defaultDisasterType = "other"
+
+// DisasterType is one entry of the disaster_types taxonomy
+type DisasterType struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// DisasterTypeCount is how many reports of a type are in each status
+type DisasterTypeCount struct {
+	DisasterType
+	Total      int `json:"total"`
+	Pending    int `json:"pending"`
+	Verified   int `json:"verified"`
+	InProgress int `json:"inProgress"`
+	Resolved   int `json:"resolved"`
+	Closed     int `json:"closed"`
+	Rejected   int `json:"rejected"`
+}
+
+func validDisasterType(db *sql.DB, code string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM disaster_types WHERE code = ?)", code).Scan(&exists)
+	return exists, err
+}
+
+func (h *ReportHandler) ListDisasterTypes(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query("SELECT code, name FROM disaster_types ORDER BY code = 'other', name")
+	if err != nil {
+		apierror.Error(w, "Error fetching disaster types", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	types := []DisasterType{}
+	for rows.Next() {
+		var t DisasterType
+		if err := rows.Scan(&t.Code, &t.Name); err != nil {
+			apierror.Error(w, "Error processing disaster types", http.StatusInternalServerError)
+			return
+		}
+		types = append(types, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types)
+}
+
+// DisasterTypeCounts returns report counts per disaster type and status for
+// dashboards. Types with no reports are included with zero counts.
+func (h *ReportHandler) DisasterTypeCounts(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(
+		`SELECT t.code, t.name, COUNT(dr.id),
+		COALESCE(SUM(dr.status = 'pending'), 0),
+		COALESCE(SUM(dr.status = 'verified'), 0),
+		COALESCE(SUM(dr.status = 'in_progress'), 0),
+		COALESCE(SUM(dr.status = 'resolved'), 0),
+		COALESCE(SUM(dr.status = 'closed'), 0),
+		COALESCE(SUM(dr.status = 'rejected'), 0)
+		FROM disaster_types t
+		LEFT JOIN disaster_reports dr ON dr.disaster_type = t.code AND dr.deleted_at IS NULL
+		GROUP BY t.code, t.name
+		ORDER BY COUNT(dr.id) DESC, t.name`,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching disaster type counts", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	counts := []DisasterTypeCount{}
+	for rows.Next() {
+		var c DisasterTypeCount
+		if err := rows.Scan(&c.Code, &c.Name, &c.Total, &c.Pending, &c.Verified, &c.InProgress, &c.Resolved, &c.Closed, &c.Rejected); err != nil {
+			apierror.Error(w, "Error processing disaster type counts", http.StatusInternalServerError)
+			return
+		}
+		counts = append(counts, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
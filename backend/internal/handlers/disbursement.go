@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// Disbursement kinds
+const (
+	DisbursementTransfer = "transfer"
+	DisbursementPurchase = "purchase"
+)
+
+// maxDisbursementReports bounds how many reports one disbursement is split across
+const maxDisbursementReports = 50
+
+// disbursementEvidenceTypes are the upload extensions accepted as evidence,
+// with the type the file is served as
+var disbursementEvidenceTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".pdf":  "application/pdf",
+}
+
+// Disbursement is money paid out of pooled donations, either transferred to
+// a verified organization or spent on a purchase, and split across the
+// reports it funds. All amounts are in rupiah, like the reports' amount
+// raised.
+type Disbursement struct {
+	ID        string  `json:"id"`
+	Kind      string  `json:"kind"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	Recipient string  `json:"recipient"`
+	// RecipientOrganizationID is set on transfers, Recipient then being
+	// the organization's name
+	RecipientOrganizationID *string                  `json:"recipientOrganizationId"`
+	Description             *string                  `json:"description"`
+	EvidenceURL             string                   `json:"evidenceUrl"`
+	Allocations             []DisbursementAllocation `json:"allocations"`
+	RecordedBy              string                   `json:"recordedBy,omitempty"`
+	DisbursedAt             time.Time                `json:"disbursedAt"`
+	CreatedAt               time.Time                `json:"createdAt"`
+}
+
+// LedgerRelease is an escrow release as shown in a report's public ledger
+type LedgerRelease struct {
+	ID               string    `json:"id"`
+	OrganizationID   string    `json:"organizationId"`
+	OrganizationName string    `json:"organizationName"`
+	Amount           float64   `json:"amount"`
+	ReleasedAt       time.Time `json:"releasedAt"`
+}
+
+// DisbursementAllocation is the part of a disbursement charged to one report
+type DisbursementAllocation struct {
+	ReportID string  `json:"reportId"`
+	Amount   float64 `json:"amount"`
+}
+
+type DisbursementHandler struct {
+	db          *sql.DB
+	auditLogger *audit.Logger
+}
+
+func NewDisbursementHandler(db *sql.DB, auditLogger *audit.Logger) *DisbursementHandler {
+	return &DisbursementHandler{db: db, auditLogger: auditLogger}
+}
+
+// disbursementEvidenceURL is where anyone can download a disbursement's evidence
+func disbursementEvidenceURL(id string) string {
+	return "/api/transparency/disbursements/" + id + "/evidence"
+}
+
+// CreateDisbursement records a transfer or purchase paid from donations,
+// with an upload of the receipt or transfer slip as evidence. Only donation
+// managers record transfers, and only to verified organizations; they can
+// charge any reports. Finance staff of an organization record purchases
+// charged to reports their organization filed. No report can pay out more
+// than it holds after earlier disbursements and escrow releases, pending
+// ones included.
+func (h *DisbursementHandler) CreateDisbursement(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Kind                    string                   `json:"kind"`
+		Recipient               string                   `json:"recipient"`
+		RecipientOrganizationID string                   `json:"recipientOrganizationId"`
+		Description             string                   `json:"description"`
+		EvidenceUploadID        string                   `json:"evidenceUploadId"`
+		DisbursedAt             string                   `json:"disbursedAt"`
+		Allocations             []DisbursementAllocation `json:"allocations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Recipient = strings.TrimSpace(request.Recipient)
+	request.Description = strings.TrimSpace(request.Description)
+
+	v := validation.New()
+	v.Check(request.Kind == DisbursementTransfer || request.Kind == DisbursementPurchase, "kind", "must be transfer or purchase")
+	if request.Kind == DisbursementTransfer {
+		v.Required("recipientOrganizationId", request.RecipientOrganizationID)
+	} else {
+		v.Check(request.RecipientOrganizationID == "", "recipientOrganizationId", "is only for transfers")
+		if v.Required("recipient", request.Recipient) {
+			v.Length("recipient", request.Recipient, 1, 200)
+		}
+	}
+	v.Length("description", request.Description, 0, 2000)
+	v.Required("evidenceUploadId", request.EvidenceUploadID)
+	if request.DisbursedAt == "" {
+		request.DisbursedAt = time.Now().Format("2006-01-02")
+	}
+	disbursedAt, err := time.Parse("2006-01-02", request.DisbursedAt)
+	if err != nil {
+		v.AddError("disbursedAt", "must be a date as YYYY-MM-DD")
+	} else {
+		v.Check(!disbursedAt.After(time.Now()), "disbursedAt", "must not be in the future")
+	}
+	v.Check(len(request.Allocations) > 0, "allocations", "must not be empty")
+	v.Check(len(request.Allocations) <= maxDisbursementReports, "allocations",
+		fmt.Sprintf("must hold at most %d reports", maxDisbursementReports))
+	total := 0.0
+	seen := map[string]bool{}
+	for i, allocation := range request.Allocations {
+		field := "allocations[" + strconv.Itoa(i) + "]"
+		v.Check(allocation.ReportID != "" && !seen[allocation.ReportID], field+".reportId", "must be a report not already listed")
+		v.Check(allocation.Amount > 0 && allocation.Amount <= maxGoalAmount, field+".amount",
+			"must be greater than 0 and at most 9999999999999.99")
+		v.Check(math.Abs(allocation.Amount*100-math.Round(allocation.Amount*100)) < 1e-6, field+".amount",
+			"IDR amounts can have at most 2 decimals")
+		seen[allocation.ReportID] = true
+		total += allocation.Amount
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	role, err := middleware.LookupRole(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	manager := role.Can(middleware.PermManageDonations)
+	// Organizations are otherwise paid through escrow, which takes several
+	// approvals
+	if request.Kind == DisbursementTransfer && !manager {
+		apierror.Error(w, "Only donation managers can record transfers to organizations", http.StatusForbidden)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var evidenceName string
+	err = tx.QueryRow(
+		"SELECT original_name FROM uploads WHERE id = ? AND user_id = ?",
+		request.EvidenceUploadID, userID,
+	).Scan(&evidenceName)
+	if err == sql.ErrNoRows {
+		v.AddError("evidenceUploadId", "was not found among your uploads")
+	} else if err != nil {
+		apierror.Error(w, "Error fetching evidence", http.StatusInternalServerError)
+		return
+	} else if _, ok := disbursementEvidenceTypes[strings.ToLower(filepath.Ext(evidenceName))]; !ok {
+		v.AddError("evidenceUploadId", "must be a JPEG, PNG or PDF file")
+	}
+	var recipientOrgID interface{}
+	if request.Kind == DisbursementTransfer {
+		var status string
+		err := tx.QueryRow(
+			"SELECT name, verification_status FROM organizations WHERE id = UUID_TO_BIN(?)",
+			request.RecipientOrganizationID,
+		).Scan(&request.Recipient, &status)
+		if err == sql.ErrNoRows {
+			v.AddError("recipientOrganizationId", "is not an organization")
+		} else if err != nil {
+			apierror.Error(w, "Error fetching organization", http.StatusInternalServerError)
+			return
+		} else if status != "verified" {
+			v.AddError("recipientOrganizationId", "must be a verified organization")
+		}
+		recipientOrgID = request.RecipientOrganizationID
+	}
+
+	// Locking the reports keeps concurrent disbursements and escrow releases
+	// from together paying out more than a report raised
+	overdrawn := map[string]interface{}{}
+	for i, allocation := range request.Allocations {
+		field := "allocations[" + strconv.Itoa(i) + "].reportId"
+		var orgID sql.NullString
+		err := tx.QueryRow(
+			`SELECT BIN_TO_UUID(organization_id) FROM disaster_reports
+			WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
+			allocation.ReportID,
+		).Scan(&orgID)
+		if err == sql.ErrNoRows {
+			v.AddError(field, "is not a report")
+			continue
+		}
+		if err != nil {
+			apierror.Error(w, "Error fetching report", http.StatusInternalServerError)
+			return
+		}
+		if !manager {
+			allowed := false
+			if orgID.Valid {
+				allowed, err = hasOrganizationPermission(h.db, orgID.String, userID, OrgPermManageDisbursements)
+				if err != nil {
+					apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+			}
+			if !allowed {
+				apierror.Error(w, "Only donation managers and the organization's finance staff can record disbursements", http.StatusForbidden)
+				return
+			}
+		}
+		funds, err := loadReportFunds(tx, allocation.ReportID)
+		if err != nil {
+			apierror.Error(w, "Error fetching report", http.StatusInternalServerError)
+			return
+		}
+		if available := funds.Held() - funds.Pending; allocation.Amount > available+1e-6 {
+			overdrawn[allocation.ReportID] = map[string]float64{
+				"amountRaised":    funds.Raised,
+				"amountDisbursed": funds.Disbursed,
+				"amountReleased":  funds.Released,
+				"pendingRelease":  funds.Pending,
+				"available":       math.Max(available, 0),
+			}
+		}
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+	if len(overdrawn) > 0 {
+		apierror.Write(w, http.StatusConflict, apierror.CodeConflict,
+			"A report cannot pay out more than it raised", overdrawn)
+		return
+	}
+
+	var id string
+	err = tx.QueryRow(
+		`INSERT INTO disbursements (id, kind, amount, recipient, recipient_organization_id, description,
+			evidence_upload_id, recorded_by, disbursed_at)
+		VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, UUID_TO_BIN(?), NULLIF(?, ''), ?, UUID_TO_BIN(?), ?)
+		RETURNING BIN_TO_UUID(id)`,
+		request.Kind, total, request.Recipient, recipientOrgID, request.Description, request.EvidenceUploadID, userID,
+		request.DisbursedAt,
+	).Scan(&id)
+	if err != nil {
+		apierror.Error(w, "Error recording disbursement", http.StatusInternalServerError)
+		return
+	}
+	for _, allocation := range request.Allocations {
+		if _, err := tx.Exec(
+			`INSERT INTO disbursement_allocations (disbursement_id, report_id, amount)
+			VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?)`,
+			id, allocation.ReportID, allocation.Amount,
+		); err != nil {
+			apierror.Error(w, "Error recording disbursement", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error recording disbursement", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDisbursementRecorded,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "disbursement",
+		EntityID:   id,
+		Details: map[string]interface{}{
+			"kind":                    request.Kind,
+			"amount":                  total,
+			"recipient":               request.Recipient,
+			"recipientOrganizationId": recipientOrgID,
+			"allocations":             request.Allocations,
+		},
+	})
+
+	var description, recipientOrg *string
+	if request.Description != "" {
+		description = &request.Description
+	}
+	if request.RecipientOrganizationID != "" {
+		recipientOrg = &request.RecipientOrganizationID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(Disbursement{
+		ID:                      id,
+		Kind:                    request.Kind,
+		Amount:                  total,
+		Currency:                "IDR",
+		Recipient:               request.Recipient,
+		RecipientOrganizationID: recipientOrg,
+		Description:             description,
+		EvidenceURL:             disbursementEvidenceURL(id),
+		Allocations:             request.Allocations,
+		RecordedBy:              userID,
+		DisbursedAt:             disbursedAt,
+		CreatedAt:               time.Now(),
+	})
+}
+
+// ListDisbursements returns disbursements newest first, optionally only those
+// charged to reportId, for donation managers
+func (h *DisbursementHandler) ListDisbursements(w http.ResponseWriter, r *http.Request) {
+	limit, offset := 50, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	disbursements, err := h.disbursements(r.URL.Query().Get("reportId"), true, limit, offset)
+	if err != nil {
+		apierror.Error(w, "Error fetching disbursements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(disbursements)
+}
+
+// GetReportLedger shows donors where a verified report's money went: the
+// amount raised, the amounts disbursed and released from escrow to its
+// organization, and what remains, with every disbursement charged to the
+// report and a link to its evidence, and every escrow release
+func (h *DisbursementHandler) GetReportLedger(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	var visible bool
+	err := h.db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM disaster_reports
+		WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL AND hidden_at IS NULL
+		AND status IN (`+verifiedReportStatuses+`))`,
+		reportID,
+	).Scan(&visible)
+	if err == nil && !visible {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	var funds reportFunds
+	if err == nil {
+		funds, err = loadReportFunds(h.db, reportID)
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching report", http.StatusInternalServerError)
+		return
+	}
+
+	disbursements, err := h.disbursements(reportID, false, 500, 0)
+	if err != nil {
+		apierror.Error(w, "Error fetching disbursements", http.StatusInternalServerError)
+		return
+	}
+	releases, err := h.ledgerReleases(reportID)
+	if err != nil {
+		apierror.Error(w, "Error fetching escrow releases", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reportId":        reportID,
+		"currency":        "IDR",
+		"amountRaised":    funds.Raised,
+		"amountDisbursed": funds.Disbursed,
+		"amountReleased":  funds.Released,
+		"balance":         funds.Held(),
+		"disbursements":   disbursements,
+		"escrowReleases":  releases,
+	})
+}
+
+// ledgerReleases loads the escrow releases paid out from a report, newest
+// first; pending and cancelled ones are left out
+func (h *DisbursementHandler) ledgerReleases(reportID string) ([]LedgerRelease, error) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(er.id), BIN_TO_UUID(er.organization_id), o.name, er.amount, er.released_at
+		FROM escrow_releases er JOIN organizations o ON o.id = er.organization_id
+		WHERE er.report_id = UUID_TO_BIN(?) AND er.status = 'released'
+		ORDER BY er.released_at DESC`,
+		reportID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []LedgerRelease{}
+	for rows.Next() {
+		var release LedgerRelease
+		if err := rows.Scan(&release.ID, &release.OrganizationID, &release.OrganizationName,
+			&release.Amount, &release.ReleasedAt); err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, rows.Err()
+}
+
+// disbursements loads disbursements, those charged to reportID if given,
+// with their allocations. Who recorded them is left out of public listings.
+func (h *DisbursementHandler) disbursements(reportID string, withRecorder bool, limit, offset int) ([]Disbursement, error) {
+	query := `SELECT BIN_TO_UUID(d.id), d.kind, d.amount, d.recipient, BIN_TO_UUID(d.recipient_organization_id), d.description,
+		BIN_TO_UUID(d.recorded_by), d.disbursed_at, d.created_at
+		FROM disbursements d`
+	var args []interface{}
+	if reportID != "" {
+		query += ` WHERE d.id IN (SELECT disbursement_id FROM disbursement_allocations WHERE report_id = UUID_TO_BIN(?))`
+		args = append(args, reportID)
+	}
+	query += " ORDER BY d.disbursed_at DESC, d.created_at DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	disbursements := []Disbursement{}
+	index := map[string]int{}
+	for rows.Next() {
+		d := Disbursement{Currency: "IDR", Allocations: []DisbursementAllocation{}}
+		if err := rows.Scan(&d.ID, &d.Kind, &d.Amount, &d.Recipient, &d.RecipientOrganizationID, &d.Description,
+			&d.RecordedBy, &d.DisbursedAt, &d.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !withRecorder {
+			d.RecordedBy = ""
+		}
+		d.EvidenceURL = disbursementEvidenceURL(d.ID)
+		index[d.ID] = len(disbursements)
+		disbursements = append(disbursements, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(disbursements) == 0 {
+		return disbursements, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("UUID_TO_BIN(?), ", len(disbursements)), ", ")
+	ids := make([]interface{}, len(disbursements))
+	for i, d := range disbursements {
+		ids[i] = d.ID
+	}
+	rows, err = h.db.Query(
+		`SELECT BIN_TO_UUID(disbursement_id), BIN_TO_UUID(report_id), amount FROM disbursement_allocations
+		WHERE disbursement_id IN (`+placeholders+`) ORDER BY amount DESC`,
+		ids...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var a DisbursementAllocation
+		if err := rows.Scan(&id, &a.ReportID, &a.Amount); err != nil {
+			return nil, err
+		}
+		d := &disbursements[index[id]]
+		d.Allocations = append(d.Allocations, a)
+	}
+	return disbursements, rows.Err()
+}
+
+// GetEvidence serves the evidence of a disbursement charged to at least one
+// verified report
+func (h *DisbursementHandler) GetEvidence(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var originalName, path string
+	err := h.db.QueryRow(
+		`SELECT u.original_name, u.path
+		FROM disbursements d JOIN uploads u ON u.id = d.evidence_upload_id
+		WHERE d.id = UUID_TO_BIN(?) AND EXISTS (
+			SELECT 1 FROM disbursement_allocations a JOIN disaster_reports dr ON dr.id = a.report_id
+			WHERE a.disbursement_id = d.id AND dr.deleted_at IS NULL AND dr.hidden_at IS NULL
+			AND dr.status IN (`+verifiedReportStatuses+`)
+		)`,
+		id,
+	).Scan(&originalName, &path)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Disbursement not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching evidence", http.StatusInternalServerError)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		apierror.Error(w, "Evidence file not found", http.StatusNotFound)
+		return
+	}
+
+	// The type follows the checked extension, not what the uploader sent
+	w.Header().Set("Content-Type", disbursementEvidenceTypes[strings.ToLower(filepath.Ext(originalName))])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filepath.Base(originalName)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, path)
+}
@@ -1,89 +1,282 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"saferelief/internal/alerts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/fx"
+	"saferelief/internal/jobs"
+	"saferelief/internal/notify"
+	"saferelief/internal/payments"
+	"saferelief/internal/tokens"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+
 	"github.com/gorilla/mux"
 )
 
 type Donation struct {
-	ID               string    `json:"id"`
-	DonorID          string    `json:"donorId"`
-	DisasterReportID string    `json:"disasterReportId"`
-	Amount           float64   `json:"amount"`
-	Currency         string    `json:"currency"`
-	Description      string    `json:"description"`
-	Status           string    `json:"status"`
-	TransactionID    string    `json:"transactionId"`
-	PaymentMethod    string    `json:"paymentMethod"`
-	CreatedAt        time.Time `json:"createdAt"`
-	UpdatedAt        time.Time `json:"updatedAt"`
+	ID               string  `json:"id"`
+	DonorID          string  `json:"donorId"`
+	DisasterReportID string  `json:"disasterReportId"`
+	Amount           float64 `json:"amount"`
+	Currency         string  `json:"currency"`
+	// AmountIDR and AmountUSD are the amount at the day's exchange rate,
+	// null until a rate for the currency is known
+	AmountIDR     *float64 `json:"amountIdr"`
+	AmountUSD     *float64 `json:"amountUsd"`
+	Description   string   `json:"description"`
+	Status        string   `json:"status"`
+	TransactionID string   `json:"transactionId"`
+	PaymentMethod string   `json:"paymentMethod"`
+	// PaymentProvider is the gateway collecting the donation, if any
+	PaymentProvider *string `json:"paymentProvider"`
+	// SubscriptionID is set on the monthly donations of a subscription
+	SubscriptionID *string `json:"subscriptionId"`
+	// Kind is monetary, goods or services. In-kind donations have no
+	// amount; they pledge a quantity of an item, matched to one of the
+	// report's needs when possible, and track its delivery.
+	Kind            string    `json:"kind"`
+	Quantity        *float64  `json:"quantity"`
+	Unit            *string   `json:"unit"`
+	ItemDescription *string   `json:"itemDescription"`
+	NeedID          *string   `json:"needId"`
+	LogisticsStatus *string   `json:"logisticsStatus"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
 }
 
 type DonationHandler struct {
-	db *sql.DB
+	db          *sql.DB
+	webhooks    *webhooks.Dispatcher
+	alerts      *alerts.Notifier
+	auditLogger *audit.Logger
+	payments    map[string]payments.Provider
+	mailer      *notify.Mailer
+	queue       *jobs.Queue
+	// receiptDir keeps issued PDF receipts, inside the upload storage
+	receiptDir string
 }
 
-func NewDonationHandler(db *sql.DB) *DonationHandler {
-	return &DonationHandler{db: db}
+func NewDonationHandler(db *sql.DB, dispatcher *webhooks.Dispatcher, notifier *alerts.Notifier, auditLogger *audit.Logger) *DonationHandler {
+	receiptDir := filepath.Join("./uploads", "receipts")
+	os.MkdirAll(receiptDir, 0700)
+	return &DonationHandler{
+		db:          db,
+		webhooks:    dispatcher,
+		alerts:      notifier,
+		auditLogger: auditLogger,
+		payments:    make(map[string]payments.Provider),
+		receiptDir:  receiptDir,
+	}
 }
 
+const maxDonationRequestSize = 64 << 10
+
+type donationRequest struct {
+	DisasterReportID string  `json:"disasterReportId"`
+	Amount           float64 `json:"amount"`
+	Currency         string  `json:"currency"`
+	Description      string  `json:"description"`
+	PaymentMethod    string  `json:"paymentMethod"`
+	// Provider names a payment gateway to check out with, e.g. midtrans
+	Provider string `json:"provider"`
+	// Email is only read for guest donations
+	Email string `json:"email"`
+	// Kind defaults to monetary; goods and services take the fields below
+	// instead of an amount
+	Kind            string  `json:"kind"`
+	Quantity        float64 `json:"quantity"`
+	Unit            string  `json:"unit"`
+	ItemDescription string  `json:"itemDescription"`
+	NeedID          string  `json:"needId"`
+}
+
+// CreateDonation records a pending donation. Clients may send an
+// Idempotency-Key so a retried request does not donate twice.
 func (h *DonationHandler) CreateDonation(w http.ResponseWriter, r *http.Request) {
-	var donation struct {
-		DisasterReportID string  `json:"disasterReportId"`
-		Amount           float64 `json:"amount"`
-		Currency         string  `json:"currency"`
-		Description      string  `json:"description"`
-		PaymentMethod    string  `json:"paymentMethod"`
+	userID := r.Context().Value("user_id").(string)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxDonationRequestSize))
+	var donation donationRequest
+	if err != nil || json.Unmarshal(body, &donation) != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.idempotent(w, r, "user:"+userID, body, nil, func(w http.ResponseWriter) {
+		h.createDonation(w, r, donation, userID)
+	})
+}
+
+// CreateGuestDonation accepts a donation without an account. The response
+// carries a claim token that attaches the donation to an account later.
+// Idempotency keys work as for CreateDonation; guests share one key space,
+// so a replay must match the first request's body, email included, and
+// comes back without the claim token.
+func (h *DonationHandler) CreateGuestDonation(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxDonationRequestSize))
+	var donation donationRequest
+	if err != nil || json.Unmarshal(body, &donation) != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&donation); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	donation.Email = strings.ToLower(strings.TrimSpace(donation.Email))
+	v := validation.New()
+	if v.Required("email", donation.Email) {
+		v.Email("email", donation.Email)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
 		return
 	}
 
-	// Validate amount
-	if donation.Amount <= 0 {
-		http.Error(w, "Invalid donation amount", http.StatusBadRequest)
+	h.idempotent(w, r, "guest", body, withoutClaimToken, func(w http.ResponseWriter) {
+		h.createDonation(w, r, donation, "")
+	})
+}
+
+// createDonation records a pending donation from userID, or from a guest
+// identified by donation.Email when userID is empty
+func (h *DonationHandler) createDonation(w http.ResponseWriter, r *http.Request, donation donationRequest, userID string) {
+	donation.Kind = strings.ToLower(strings.TrimSpace(donation.Kind))
+	if donation.Kind == "" {
+		donation.Kind = DonationMonetary
+	}
+	if donation.Kind != DonationMonetary {
+		v := validation.New()
+		validateInKind(v, &donation)
+		if !v.Valid() {
+			v.WriteError(w)
+			return
+		}
+	} else if donation.Amount <= 0 {
+		apierror.Error(w, "Invalid donation amount", http.StatusBadRequest)
+		return
+	}
+
+	donation.Currency = strings.ToUpper(strings.TrimSpace(donation.Currency))
+	if donation.Currency == "" {
+		donation.Currency = "IDR"
+	}
+	v := validation.New()
+	if donation.Kind == DonationMonetary {
+		h.checkCurrency(r.Context(), v, donation.Currency, donation.Amount)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	var provider payments.Provider
+	if donation.Provider != "" {
+		var ok bool
+		provider, ok = h.payments[donation.Provider]
+		v.Check(ok, "provider", "is not a configured payment provider")
+		if ok {
+			if message := provider.Validate(payments.Charge{Amount: donation.Amount, Currency: donation.Currency}); message != "" {
+				v.AddError("amount", message)
+			}
+		}
+		if !v.Valid() {
+			v.WriteError(w)
+			return
+		}
+		if donation.PaymentMethod == "" {
+			donation.PaymentMethod = donation.Provider
+		}
+	}
+	if donation.Kind == DonationMonetary {
+		if err := checkDonationLimits(r.Context(), h.db, v, donation.Currency, donation.Amount, donation.PaymentMethod, donation.Provider); err != nil {
+			apierror.Error(w, "Error checking donation limits", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !v.Valid() {
+		v.WriteError(w)
 		return
 	}
 
 	// Start transaction
 	tx, err := h.db.Begin()
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 	defer tx.Rollback()
 
 	// Verify disaster report exists and is verified
-	var reportStatus string
+	var reportStatus ReportStatus
+	var fundraisingClosed bool
 	err = tx.QueryRow(
-		"SELECT status FROM disaster_reports WHERE id = UUID_TO_BIN(?) FOR UPDATE",
+		`SELECT status, fundraising_closed_at IS NOT NULL FROM disaster_reports
+		WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
 		donation.DisasterReportID,
-	).Scan(&reportStatus)
+	).Scan(&reportStatus, &fundraisingClosed)
 
 	if err == sql.ErrNoRows {
-		http.Error(w, "Disaster report not found", http.StatusNotFound)
+		apierror.Error(w, "Disaster report not found", http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		http.Error(w, "Error verifying disaster report", http.StatusInternalServerError)
+		apierror.Error(w, "Error verifying disaster report", http.StatusInternalServerError)
 		return
 	}
 
-	if reportStatus != "verified" {
-		http.Error(w, "Cannot donate to unverified disaster report", http.StatusBadRequest)
+	if !reportStatus.AcceptsDonations() {
+		apierror.Error(w, "Cannot donate to a disaster report that is not verified or is already resolved", http.StatusBadRequest)
+		return
+	}
+	if fundraisingClosed && donation.Kind == DonationMonetary {
+		apierror.Error(w, "This report reached its fundraising goal and no longer takes donations", http.StatusConflict)
 		return
 	}
+	var needID, logisticsStatus interface{}
+	if donation.Kind != DonationMonetary {
+		matched, message, err := matchReportNeed(tx, donation)
+		if err != nil {
+			apierror.Error(w, "Error matching report needs", http.StatusInternalServerError)
+			return
+		}
+		if message != "" {
+			v.AddError("needId", message)
+			v.WriteError(w)
+			return
+		}
+		if matched != "" {
+			needID = matched
+		}
+		logisticsStatus = LogisticsPledged
+	}
 
-	// Get user ID from context
-	userID := r.Context().Value("user_id").(string)
+	// Guests have no donor; NULL binds leave donor_id and the audit user empty
+	var donorID, guestEmail, claimTokenHash interface{}
+	var claimToken string
+	if userID != "" {
+		donorID = userID
+	} else {
+		claimToken, claimTokenHash, err = tokens.Generate()
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		guestEmail = donation.Email
+	}
 
 	// Generate transaction ID
 	transactionID := generateTransactionID()
@@ -92,18 +285,25 @@ func (h *DonationHandler) CreateDonation(w http.ResponseWriter, r *http.Request)
 	var donationID string
 	err = tx.QueryRow(
 		`INSERT INTO donations (
-			id, donor_id, disaster_report_id, amount, currency, 
-			description, status, transaction_id, payment_method
+			id, donor_id, guest_email, claim_token_hash, disaster_report_id, amount, currency, 
+			description, status, transaction_id, payment_method, payment_provider,
+			kind, quantity, unit, item_description, need_id, logistics_status
 		) VALUES (
-			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, 
-			?, 'pending', ?, ?
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, UUID_TO_BIN(?), ?, ?, 
+			?, 'pending', ?, ?, NULLIF(?, ''),
+			?, NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''), UUID_TO_BIN(?), ?
 		) RETURNING BIN_TO_UUID(id)`,
-		userID, donation.DisasterReportID, donation.Amount, donation.Currency,
-		donation.Description, transactionID, donation.PaymentMethod,
+		donorID, guestEmail, claimTokenHash, donation.DisasterReportID, donation.Amount, donation.Currency,
+		donation.Description, transactionID, donation.PaymentMethod, donation.Provider,
+		donation.Kind, donation.Quantity, donation.Unit, donation.ItemDescription, needID, logisticsStatus,
 	).Scan(&donationID)
 
 	if err != nil {
-		http.Error(w, "Error creating donation", http.StatusInternalServerError)
+		apierror.Error(w, "Error creating donation", http.StatusInternalServerError)
+		return
+	}
+	if err := fx.NormalizeDonation(r.Context(), tx, donationID); err != nil {
+		apierror.Error(w, "Error creating donation", http.StatusInternalServerError)
 		return
 	}
 
@@ -116,28 +316,85 @@ func (h *DonationHandler) CreateDonation(w http.ResponseWriter, r *http.Request)
 			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), 'create_donation', 'donation', 
 			UUID_TO_BIN(?), ?, ?, ?
 		)`,
-		userID, donationID, r.RemoteAddr, r.UserAgent(),
-		json.RawMessage(`{"amount":"`+fmt.Sprintf("%.2f", donation.Amount)+`","currency":"`+donation.Currency+`"}`),
+		donorID, donationID, r.RemoteAddr, r.UserAgent(),
+		json.RawMessage(`{"amount":"`+fmt.Sprintf("%.2f", donation.Amount)+`","currency":"`+donation.Currency+`","kind":"`+donation.Kind+`","guest":`+strconv.FormatBool(userID == "")+`}`),
 	)
 
 	if err != nil {
-		http.Error(w, "Error logging donation", http.StatusInternalServerError)
+		apierror.Error(w, "Error logging donation", http.StatusInternalServerError)
 		return
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		http.Error(w, "Error finalizing donation", http.StatusInternalServerError)
+		apierror.Error(w, "Error finalizing donation", http.StatusInternalServerError)
 		return
 	}
 
+	var donors []string
+	if userID != "" {
+		donors = append(donors, userID)
+	}
+	h.webhooks.Publish(webhooks.EventDonationCreated,
+		reportWebhookRecipients(h.db, donation.DisasterReportID, donors...),
+		map[string]interface{}{
+			"id":               donationID,
+			"disasterReportId": donation.DisasterReportID,
+			"amount":           donation.Amount,
+			"currency":         donation.Currency,
+			"kind":             donation.Kind,
+			"status":           "pending",
+		},
+	)
+
 	// Return donation details
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"id":            donationID,
 		"transactionId": transactionID,
 		"status":        "pending",
 		"message":       "Donation created successfully",
-	})
+	}
+	if donation.Kind != DonationMonetary {
+		response["kind"] = donation.Kind
+		response["needId"] = needID
+		response["logisticsStatus"] = LogisticsPledged
+	}
+	if claimToken != "" {
+		// Shown only here; it is stored hashed
+		response["claimToken"] = claimToken
+	}
+	if provider != nil {
+		email := donation.Email
+		if userID != "" {
+			h.db.QueryRow("SELECT email FROM users WHERE id = UUID_TO_BIN(?)", userID).Scan(&email)
+		}
+		checkout, err := provider.Checkout(r.Context(), payments.Charge{
+			OrderID:     transactionID,
+			Amount:      donation.Amount,
+			Currency:    donation.Currency,
+			Email:       email,
+			Description: donation.Description,
+		})
+		if err != nil {
+			log.Printf("Failed to start %s checkout for donation %s: %v", provider.Name(), donationID, err)
+			h.db.Exec("UPDATE donations SET status = 'failed' WHERE id = UUID_TO_BIN(?)", donationID)
+			apierror.Error(w, "The payment gateway is unavailable; please try again", http.StatusBadGateway)
+			return
+		}
+		if _, ok := provider.(payments.Capturer); ok {
+			if _, err := h.db.Exec(
+				"UPDATE donations SET provider_order_id = ? WHERE id = UUID_TO_BIN(?)", checkout.Token, donationID,
+			); err != nil {
+				log.Printf("Failed to store %s order %s for donation %s: %v", provider.Name(), checkout.Token, donationID, err)
+			}
+		}
+		response["payment"] = map[string]interface{}{
+			"provider":    provider.Name(),
+			"token":       checkout.Token,
+			"redirectUrl": checkout.RedirectURL,
+		}
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *DonationHandler) GetDonation(w http.ResponseWriter, r *http.Request) {
@@ -147,28 +404,32 @@ func (h *DonationHandler) GetDonation(w http.ResponseWriter, r *http.Request) {
 
 	var donation Donation
 	err := h.db.QueryRow(
-		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(donor_id), BIN_TO_UUID(disaster_report_id),
-		amount, currency, description, status, transaction_id, payment_method,
+		`SELECT BIN_TO_UUID(id), COALESCE(BIN_TO_UUID(donor_id), ''), BIN_TO_UUID(disaster_report_id),
+		amount, currency, amount_idr, amount_usd, description, status, transaction_id, payment_method, payment_provider,
+		BIN_TO_UUID(subscription_id), kind, quantity, unit, item_description, BIN_TO_UUID(need_id), logistics_status,
 		created_at, updated_at
 		FROM donations 
 		WHERE id = UUID_TO_BIN(?) AND (donor_id = UUID_TO_BIN(?) OR 
 		disaster_report_id IN (
 			SELECT id FROM disaster_reports WHERE reporter_id = UUID_TO_BIN(?)
-		))`,
+		)) AND disaster_report_id NOT IN (
+			SELECT id FROM disaster_reports WHERE deleted_at IS NOT NULL
+		)`,
 		donationID, userID, userID,
 	).Scan(
 		&donation.ID, &donation.DonorID, &donation.DisasterReportID,
-		&donation.Amount, &donation.Currency, &donation.Description,
-		&donation.Status, &donation.TransactionID, &donation.PaymentMethod,
-		&donation.CreatedAt, &donation.UpdatedAt,
+		&donation.Amount, &donation.Currency, &donation.AmountIDR, &donation.AmountUSD, &donation.Description,
+		&donation.Status, &donation.TransactionID, &donation.PaymentMethod, &donation.PaymentProvider,
+		&donation.SubscriptionID, &donation.Kind, &donation.Quantity, &donation.Unit, &donation.ItemDescription,
+		&donation.NeedID, &donation.LogisticsStatus, &donation.CreatedAt, &donation.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		http.Error(w, "Donation not found", http.StatusNotFound)
+		apierror.Error(w, "Donation not found", http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		http.Error(w, "Error fetching donation", http.StatusInternalServerError)
+		apierror.Error(w, "Error fetching donation", http.StatusInternalServerError)
 		return
 	}
 
@@ -183,16 +444,21 @@ func (h *DonationHandler) ListDonations(w http.ResponseWriter, r *http.Request)
 	offset := 0
 	status := r.URL.Query().Get("status")
 	reportID := r.URL.Query().Get("reportId")
+	subscriptionID := r.URL.Query().Get("subscriptionId")
+	kind := r.URL.Query().Get("kind")
 
 	query := `
-		SELECT BIN_TO_UUID(d.id), BIN_TO_UUID(d.donor_id), BIN_TO_UUID(d.disaster_report_id),
-		d.amount, d.currency, d.description, d.status, d.transaction_id, d.payment_method,
-		d.created_at, d.updated_at
+		SELECT BIN_TO_UUID(d.id), COALESCE(BIN_TO_UUID(d.donor_id), ''), BIN_TO_UUID(d.disaster_report_id),
+		d.amount, d.currency, d.amount_idr, d.amount_usd, d.description, d.status, d.transaction_id, d.payment_method, d.payment_provider,
+		BIN_TO_UUID(d.subscription_id), d.kind, d.quantity, d.unit, d.item_description, BIN_TO_UUID(d.need_id),
+		d.logistics_status, d.created_at, d.updated_at
 		FROM donations d
 		WHERE (d.donor_id = UUID_TO_BIN(?) OR 
 		d.disaster_report_id IN (
 			SELECT id FROM disaster_reports WHERE reporter_id = UUID_TO_BIN(?)
-		))`
+		)) AND d.disaster_report_id NOT IN (
+			SELECT id FROM disaster_reports WHERE deleted_at IS NOT NULL
+		)`
 
 	args := []interface{}{userID, userID}
 
@@ -204,13 +470,21 @@ func (h *DonationHandler) ListDonations(w http.ResponseWriter, r *http.Request)
 		query += " AND d.disaster_report_id = UUID_TO_BIN(?)"
 		args = append(args, reportID)
 	}
+	if subscriptionID != "" {
+		query += " AND d.subscription_id = UUID_TO_BIN(?)"
+		args = append(args, subscriptionID)
+	}
+	if kind != "" {
+		query += " AND d.kind = ?"
+		args = append(args, kind)
+	}
 
 	query += " ORDER BY d.created_at DESC LIMIT ? OFFSET ?"
 	args = append(args, limit, offset)
 
 	rows, err := h.db.Query(query, args...)
 	if err != nil {
-		http.Error(w, "Error fetching donations", http.StatusInternalServerError)
+		apierror.Error(w, "Error fetching donations", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
@@ -220,11 +494,12 @@ func (h *DonationHandler) ListDonations(w http.ResponseWriter, r *http.Request)
 		var d Donation
 		if err := rows.Scan(
 			&d.ID, &d.DonorID, &d.DisasterReportID,
-			&d.Amount, &d.Currency, &d.Description,
-			&d.Status, &d.TransactionID, &d.PaymentMethod,
-			&d.CreatedAt, &d.UpdatedAt,
+			&d.Amount, &d.Currency, &d.AmountIDR, &d.AmountUSD, &d.Description,
+			&d.Status, &d.TransactionID, &d.PaymentMethod, &d.PaymentProvider,
+			&d.SubscriptionID, &d.Kind, &d.Quantity, &d.Unit, &d.ItemDescription,
+			&d.NeedID, &d.LogisticsStatus, &d.CreatedAt, &d.UpdatedAt,
 		); err != nil {
-			http.Error(w, "Error processing donations", http.StatusInternalServerError)
+			apierror.Error(w, "Error processing donations", http.StatusInternalServerError)
 			return
 		}
 		donations = append(donations, d)
@@ -233,6 +508,98 @@ func (h *DonationHandler) ListDonations(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(donations)
 }
 
+// ClaimDonation attaches a guest donation to the signed-in account. The
+// account's email must be verified and match the one given when donating, so
+// a leaked claim token alone is not enough.
+func (h *DonationHandler) ClaimDonation(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		ClaimToken string `json:"claimToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ClaimToken == "" {
+		apierror.Error(w, "claimToken is required", http.StatusBadRequest)
+		return
+	}
+
+	var email string
+	var emailVerifiedAt sql.NullTime
+	err := h.db.QueryRow(
+		"SELECT email, email_verified_at FROM users WHERE id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&email, &emailVerifiedAt)
+	if err != nil {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !emailVerifiedAt.Valid {
+		apierror.Error(w, "Verify your email address before claiming donations", http.StatusForbidden)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var donationID, guestEmail string
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(id), guest_email FROM donations
+		WHERE claim_token_hash = ? AND donor_id IS NULL FOR UPDATE`,
+		tokens.Hash(request.ClaimToken),
+	).Scan(&donationID, &guestEmail)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Claim token not found or already used", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching donation", http.StatusInternalServerError)
+		return
+	}
+	if !strings.EqualFold(guestEmail, email) {
+		apierror.Error(w, "The donation was made with a different email address", http.StatusForbidden)
+		return
+	}
+
+	_, err = tx.Exec(
+		`UPDATE donations SET donor_id = UUID_TO_BIN(?), claim_token_hash = NULL, claimed_at = NOW()
+		WHERE id = UUID_TO_BIN(?)`,
+		userID, donationID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error claiming donation", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO audit_logs (
+			id, user_id, action, entity_type, entity_id, 
+			ip_address, user_agent, details
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), 'claim_donation', 'donation', 
+			UUID_TO_BIN(?), ?, ?, NULL
+		)`,
+		userID, donationID, r.RemoteAddr, r.UserAgent(),
+	)
+	if err != nil {
+		apierror.Error(w, "Error logging donation claim", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error finalizing donation claim", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      donationID,
+		"message": "Donation added to your account",
+	})
+}
+
 func (h *DonationHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	donationID := vars["id"]
@@ -243,38 +610,47 @@ func (h *DonationHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if update.Status == payments.StatusRefunded && !h.refundThroughProvider(w, r, donationID, "") {
 		return
 	}
 
 	// Start transaction
 	tx, err := h.db.Begin()
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 	defer tx.Rollback()
 
-	// Update donation status
+	// Update donation status; access is restricted to donation managers by the router
 	result, err := tx.Exec(
 		`UPDATE donations 
-		SET status = ?, updated_at = NOW()
-		WHERE id = UUID_TO_BIN(?) AND donor_id = UUID_TO_BIN(?)`,
-		update.Status, donationID, userID,
+		SET status = ?, updated_at = NOW(),
+		completed_at = IF(? = 'completed', COALESCE(completed_at, NOW()), completed_at)
+		WHERE id = UUID_TO_BIN(?)`,
+		update.Status, update.Status, donationID,
 	)
 
 	if err != nil {
-		http.Error(w, "Error updating donation status", http.StatusInternalServerError)
+		apierror.Error(w, "Error updating donation status", http.StatusInternalServerError)
 		return
 	}
 
 	rows, err := result.RowsAffected()
 	if err != nil {
-		http.Error(w, "Error checking update result", http.StatusInternalServerError)
+		apierror.Error(w, "Error checking update result", http.StatusInternalServerError)
 		return
 	}
 	if rows == 0 {
-		http.Error(w, "Donation not found or unauthorized", http.StatusNotFound)
+		apierror.Error(w, "Donation not found", http.StatusNotFound)
+		return
+	}
+	if err := fx.RecountDonationReport(r.Context(), tx, donationID); err != nil {
+		apierror.Error(w, "Error updating the report's amount raised", http.StatusInternalServerError)
 		return
 	}
 
@@ -292,22 +668,63 @@ func (h *DonationHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 	)
 
 	if err != nil {
-		http.Error(w, "Error logging status update", http.StatusInternalServerError)
+		apierror.Error(w, "Error logging status update", http.StatusInternalServerError)
 		return
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		http.Error(w, "Error finalizing status update", http.StatusInternalServerError)
+		apierror.Error(w, "Error finalizing status update", http.StatusInternalServerError)
 		return
 	}
 
+	var donorID sql.NullString
+	var reportID, currency string
+	if err := h.db.QueryRow(
+		"SELECT BIN_TO_UUID(donor_id), BIN_TO_UUID(disaster_report_id), currency FROM donations WHERE id = UUID_TO_BIN(?)",
+		donationID,
+	).Scan(&donorID, &reportID, &currency); err == nil {
+		var donors []string
+		if donorID.Valid {
+			donors = append(donors, donorID.String)
+		}
+		h.webhooks.Publish(webhooks.EventDonationStatusChanged,
+			reportWebhookRecipients(h.db, reportID, donors...),
+			map[string]interface{}{
+				"id":               donationID,
+				"disasterReportId": reportID,
+				"status":           update.Status,
+			},
+		)
+		if update.Status == "completed" {
+			h.alerts.DonationCompleted(reportID, currency)
+			h.queueReceipt(donationID)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Donation status updated successfully",
 	})
 }
 
+// checkCurrency records an error when currency is not an ISO 4217 code or
+// amount has more decimals than it uses
+func (h *DonationHandler) checkCurrency(ctx context.Context, v *validation.Validator, currency string, amount float64) {
+	c, err := fx.Lookup(ctx, h.db, currency)
+	if err == fx.ErrUnknownCurrency {
+		v.AddError("currency", "must be an ISO 4217 currency code")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up currency %s: %v", currency, err)
+		return
+	}
+	if message := c.CheckAmount(amount); message != "" {
+		v.AddError("amount", message)
+	}
+}
+
 func generateTransactionID() string {
 	timestamp := time.Now().Format("20060102150405")
 	random := make([]byte, 4)
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyWindow is how long a key replays its first response
+	idempotencyWindow    = 24 * time.Hour
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotentResponse records what a handler wrote so it can be replayed
+type idempotentResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotentResponse) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotentResponse) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// idempotent runs create once per Idempotency-Key from the same owner
+// within idempotencyWindow. A replay with the same body gets the first
+// response again; the same key with a different body is a 422, and one
+// that arrives while the first is still running a 409. Server errors are
+// not kept, so the client can retry them with the same key. Requests
+// without the header run as usual. redact, when set, removes secrets from
+// the response before it is kept for replay.
+func (h *DonationHandler) idempotent(w http.ResponseWriter, r *http.Request, owner string, body []byte, redact func([]byte) []byte, create func(w http.ResponseWriter)) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		create(w)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		apierror.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(sum[:])
+
+	for attempt := 0; ; attempt++ {
+		_, err := h.db.Exec(
+			"INSERT INTO donation_idempotency_keys (owner, idempotency_key, request_hash) VALUES (?, ?, ?)",
+			owner, key, requestHash,
+		)
+		if err == nil {
+			break
+		}
+		if mysqlErr, ok := err.(*mysql.MySQLError); !ok || mysqlErr.Number != 1062 || attempt > 0 {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		var storedHash string
+		var status sql.NullInt64
+		var response []byte
+		var createdAt time.Time
+		err = h.db.QueryRow(
+			`SELECT request_hash, response_status, response_body, created_at
+			FROM donation_idempotency_keys WHERE owner = ? AND idempotency_key = ?`,
+			owner, key,
+		).Scan(&storedHash, &status, &response, &createdAt)
+		if err == sql.ErrNoRows {
+			// Released by a failed first attempt in the meantime
+			continue
+		}
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if time.Since(createdAt) > idempotencyWindow {
+			h.db.Exec(
+				"DELETE FROM donation_idempotency_keys WHERE owner = ? AND idempotency_key = ? AND created_at = ?",
+				owner, key, createdAt,
+			)
+			continue
+		}
+		if storedHash != requestHash {
+			apierror.Error(w, "Idempotency-Key was already used with a different request", http.StatusUnprocessableEntity)
+			return
+		}
+		if !status.Valid {
+			apierror.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(int(status.Int64))
+		w.Write(response)
+		return
+	}
+
+	rec := &idempotentResponse{ResponseWriter: w}
+	create(rec)
+	if rec.status == 0 || rec.status >= 500 {
+		h.db.Exec("DELETE FROM donation_idempotency_keys WHERE owner = ? AND idempotency_key = ?", owner, key)
+		return
+	}
+	stored := rec.body.Bytes()
+	if redact != nil {
+		stored = redact(stored)
+	}
+	if _, err := h.db.Exec(
+		`UPDATE donation_idempotency_keys SET response_status = ?, response_body = ?
+		WHERE owner = ? AND idempotency_key = ?`,
+		rec.status, stored, owner, key,
+	); err != nil {
+		log.Printf("Failed to store idempotent response for key %q: %v", key, err)
+	}
+}
+
+// withoutClaimToken drops the claim token from a guest donation response.
+// Guests share one key space, so anyone who learns a key and the request
+// body could replay it; the token is only ever shown to the first caller.
+func withoutClaimToken(body []byte) []byte {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	if _, ok := response["claimToken"]; !ok {
+		return body
+	}
+	delete(response, "claimToken")
+	redacted, err := json.Marshal(response)
+	if err != nil {
+		return body
+	}
+	return append(redacted, '\n')
+}
+
+// StartIdempotencyPurge deletes keys past idempotencyWindow every interval
+func (h *DonationHandler) StartIdempotencyPurge(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := h.db.Exec(
+				"DELETE FROM donation_idempotency_keys WHERE created_at < ?",
+				time.Now().Add(-idempotencyWindow),
+			); err != nil {
+				log.Printf("Failed to purge donation idempotency keys: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"saferelief/internal/fakedb"
+)
+
+// The first caller gets the claim token; the response kept for replays,
+// which anyone with the same key and body receives, does not carry it
+func TestGuestIdempotencyKeepsNoClaimToken(t *testing.T) {
+	var stored []byte
+	db, _ := fakedb.New(t,
+		fakedb.Rule{Match: "INSERT INTO donation_idempotency_keys"},
+		fakedb.Rule{Match: "UPDATE donation_idempotency_keys", Args: func(args []driver.NamedValue) {
+			stored, _ = args[1].Value.([]byte)
+		}},
+	)
+	h := &DonationHandler{db: db}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/donations/guest", nil)
+	req.Header.Set(IdempotencyKeyHeader, "retry-1")
+	rec := httptest.NewRecorder()
+	h.idempotent(rec, req, "guest", []byte(`{"amount": 50000}`), withoutClaimToken, func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "d-1", "status": "pending", "claimToken": "secret"})
+	})
+
+	if !strings.Contains(rec.Body.String(), `"claimToken":"secret"`) {
+		t.Errorf("first response %s lacks the claim token", rec.Body)
+	}
+	var replay map[string]interface{}
+	if err := json.Unmarshal(stored, &replay); err != nil {
+		t.Fatalf("stored response %q: %v", stored, err)
+	}
+	if _, ok := replay["claimToken"]; ok {
+		t.Errorf("stored response %s keeps the claim token", stored)
+	}
+	if replay["id"] != "d-1" || replay["status"] != "pending" {
+		t.Errorf("stored response %s lost other fields", stored)
+	}
+}
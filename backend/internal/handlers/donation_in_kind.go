@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/middleware"
+	"saferelief/internal/payments"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+// Donation kinds
+const (
+	DonationMonetary = "monetary"
+	DonationGoods    = "goods"
+	DonationServices = "services"
+)
+
+// Logistics statuses of an in-kind donation, in order
+const (
+	LogisticsPledged  = "pledged"
+	LogisticsShipped  = "shipped"
+	LogisticsReceived = "received"
+)
+
+// logisticsTransitions lists where an in-kind donation can go from each
+// status; services are received without being shipped
+var logisticsTransitions = map[string][]string{
+	LogisticsPledged: {LogisticsShipped, LogisticsReceived},
+	LogisticsShipped: {LogisticsReceived},
+}
+
+// validateInKind checks the pledge fields of a goods or services donation,
+// which has no amount, currency or payment
+func validateInKind(v *validation.Validator, donation *donationRequest) {
+	v.Check(donation.Kind == DonationGoods || donation.Kind == DonationServices, "kind",
+		"must be monetary, goods or services")
+	v.Check(donation.Amount == 0, "amount", "is only for monetary donations")
+	v.Check(donation.Provider == "", "provider", "is only for monetary donations")
+	v.Check(donation.Quantity > 0 && donation.Quantity <= maxNeedQuantity, "quantity",
+		"must be greater than 0 and at most 1000000000")
+	donation.Unit = strings.TrimSpace(donation.Unit)
+	if v.Required("unit", donation.Unit) {
+		v.Length("unit", donation.Unit, 1, 30)
+	}
+	donation.ItemDescription = strings.TrimSpace(donation.ItemDescription)
+	if v.Required("itemDescription", donation.ItemDescription) {
+		v.Length("itemDescription", donation.ItemDescription, 1, 100)
+	}
+	donation.Currency = "IDR"
+	donation.PaymentMethod = ""
+}
+
+// matchReportNeed finds the report need an in-kind pledge goes toward: the
+// one named by NeedID, which must be in the same unit, or else a need for
+// the same item and unit, ignoring case. A pledge matching no need is still
+// taken. It returns a message for the donor when NeedID does not fit.
+func matchReportNeed(tx *sql.Tx, donation donationRequest) (string, string, error) {
+	var needID, unit string
+	if donation.NeedID != "" {
+		err := tx.QueryRow(
+			"SELECT BIN_TO_UUID(id), unit FROM report_needs WHERE id = UUID_TO_BIN(?) AND report_id = UUID_TO_BIN(?)",
+			donation.NeedID, donation.DisasterReportID,
+		).Scan(&needID, &unit)
+		if err == sql.ErrNoRows {
+			return "", "is not a need of this report", nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if !strings.EqualFold(unit, donation.Unit) {
+			return "", "is counted in " + unit + "; pledge in the same unit", nil
+		}
+		return needID, "", nil
+	}
+
+	err := tx.QueryRow(
+		`SELECT BIN_TO_UUID(id) FROM report_needs
+		WHERE report_id = UUID_TO_BIN(?) AND LOWER(item) = LOWER(?) AND LOWER(unit) = LOWER(?)
+		ORDER BY quantity_fulfilled >= quantity_needed, created_at LIMIT 1`,
+		donation.DisasterReportID, donation.ItemDescription, donation.Unit,
+	).Scan(&needID)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	return needID, "", err
+}
+
+// UpdateLogistics moves an in-kind donation along pledged → shipped →
+// received. The donor can mark it shipped; the report's reporter, platform
+// verifiers, members of the filing organization who file its reports and
+// donation managers can mark it shipped or received. Receiving completes
+// the donation and adds its quantity to the matched need as a delivery.
+func (h *DonationHandler) UpdateLogistics(w http.ResponseWriter, r *http.Request) {
+	donationID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	request.Note = strings.TrimSpace(request.Note)
+	v := validation.New()
+	v.Check(request.Status == LogisticsShipped || request.Status == LogisticsReceived, "status", "must be shipped or received")
+	v.Check(len([]rune(request.Note)) <= maxFulfillmentNoteLen, "note", "must be at most 500 characters")
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var kind, reportID, reporterID string
+	var donorID, orgID, logistics sql.NullString
+	var needID *string
+	var quantity sql.NullFloat64
+	err = tx.QueryRow(
+		`SELECT d.kind, BIN_TO_UUID(d.donor_id), BIN_TO_UUID(d.disaster_report_id), d.logistics_status,
+		BIN_TO_UUID(d.need_id), d.quantity, BIN_TO_UUID(dr.reporter_id), BIN_TO_UUID(dr.organization_id)
+		FROM donations d JOIN disaster_reports dr ON dr.id = d.disaster_report_id
+		WHERE d.id = UUID_TO_BIN(?) AND dr.deleted_at IS NULL FOR UPDATE`,
+		donationID,
+	).Scan(&kind, &donorID, &reportID, &logistics, &needID, &quantity, &reporterID, &orgID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Donation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching donation", http.StatusInternalServerError)
+		return
+	}
+
+	receiver, err := h.canReceiveInKind(userID, reporterID, orgID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	isDonor := donorID.Valid && donorID.String == userID
+	if !receiver && !isDonor {
+		apierror.Error(w, "Donation not found", http.StatusNotFound)
+		return
+	}
+	if !receiver && request.Status == LogisticsReceived {
+		apierror.Error(w, "Only the report's responders can mark a donation received", http.StatusForbidden)
+		return
+	}
+	if kind == DonationMonetary {
+		apierror.Error(w, "Only goods and services donations are delivered", http.StatusConflict)
+		return
+	}
+	allowed := false
+	for _, next := range logisticsTransitions[logistics.String] {
+		allowed = allowed || next == request.Status
+	}
+	if !allowed {
+		apierror.Write(w, http.StatusConflict, apierror.CodeConflict,
+			"The donation is already "+logistics.String,
+			map[string]interface{}{"logisticsStatus": logistics.String})
+		return
+	}
+
+	if request.Status == LogisticsShipped {
+		_, err = tx.Exec(
+			"UPDATE donations SET logistics_status = 'shipped', shipped_at = NOW() WHERE id = UUID_TO_BIN(?)",
+			donationID,
+		)
+	} else {
+		_, err = tx.Exec(
+			`UPDATE donations SET logistics_status = 'received', received_at = NOW(),
+			status = 'completed', completed_at = COALESCE(completed_at, NOW())
+			WHERE id = UUID_TO_BIN(?)`,
+			donationID,
+		)
+		if err == nil && needID != nil {
+			note := request.Note
+			if note == "" {
+				note = "In-kind donation " + donationID
+			}
+			if _, err = tx.Exec(
+				"UPDATE report_needs SET quantity_fulfilled = quantity_fulfilled + ? WHERE id = UUID_TO_BIN(?)",
+				quantity.Float64, *needID,
+			); err == nil {
+				_, err = tx.Exec(
+					`INSERT INTO report_need_fulfillments (id, need_id, quantity, note, recorded_by)
+					VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, UUID_TO_BIN(?))`,
+					*needID, quantity.Float64, note, userID,
+				)
+			}
+		}
+	}
+	if err != nil {
+		apierror.Error(w, "Error updating donation logistics", http.StatusInternalServerError)
+		return
+	}
+	details, _ := json.Marshal(map[string]string{"logisticsStatus": request.Status, "note": request.Note})
+	if _, err := tx.Exec(
+		`INSERT INTO audit_logs (
+			id, user_id, action, entity_type, entity_id,
+			ip_address, user_agent, details
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), 'update_donation_logistics',
+			'donation', UUID_TO_BIN(?), ?, ?, ?
+		)`,
+		userID, donationID, r.RemoteAddr, r.UserAgent(), details,
+	); err != nil {
+		apierror.Error(w, "Error logging logistics update", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error finalizing logistics update", http.StatusInternalServerError)
+		return
+	}
+
+	if request.Status == LogisticsReceived {
+		var donors []string
+		if donorID.Valid {
+			donors = append(donors, donorID.String)
+		}
+		h.webhooks.Publish(webhooks.EventDonationStatusChanged,
+			reportWebhookRecipients(h.db, reportID, donors...),
+			map[string]interface{}{
+				"id":               donationID,
+				"disasterReportId": reportID,
+				"status":           payments.StatusCompleted,
+				"kind":             kind,
+			},
+		)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":              donationID,
+		"logisticsStatus": request.Status,
+		"needId":          needID,
+	})
+}
+
+// canReceiveInKind reports whether the user responds to the report: its
+// reporter, a platform verifier or donation manager, or a member of the
+// filing organization who files its reports
+func (h *DonationHandler) canReceiveInKind(userID, reporterID string, orgID sql.NullString) (bool, error) {
+	if userID == reporterID {
+		return true, nil
+	}
+	role, err := middleware.LookupRole(h.db, userID)
+	if err != nil {
+		return false, err
+	}
+	if role.Can(middleware.PermVerifyReports) || role.Can(middleware.PermManageDonations) {
+		return true, nil
+	}
+	if !orgID.Valid {
+		return false, nil
+	}
+	return hasOrganizationPermission(h.db, orgID.String, userID, OrgPermCreateReports)
+}
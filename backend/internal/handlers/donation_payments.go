@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/fx"
+	"saferelief/internal/inbound"
+	"saferelief/internal/payments"
+	"saferelief/internal/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+// paymentTransitions lists the statuses a gateway notification may move a
+// donation from. Notifications can arrive out of order, so a late pending
+// notice never undoes a settlement.
+var paymentTransitions = map[string][]string{
+	payments.StatusCompleted: {"pending", "failed"},
+	payments.StatusFailed:    {"pending"},
+	payments.StatusRefunded:  {"completed"},
+}
+
+// AddPaymentProvider lets donors pay through provider
+func (h *DonationHandler) AddPaymentProvider(provider payments.Provider) {
+	h.payments[provider.Name()] = provider
+}
+
+// RegisterInbound adds each payment provider's notifications to the inbound
+// webhook receiver
+func (h *DonationHandler) RegisterInbound(receiver *inbound.Receiver) {
+	for name, provider := range h.payments {
+		provider := provider
+		receiver.Register(name, inbound.Provider{
+			Verify: provider.VerifyNotification,
+			Process: func(ctx context.Context, payload []byte) error {
+				return h.applyPaymentNotification(ctx, provider, payload)
+			},
+		})
+	}
+}
+
+func (h *DonationHandler) applyPaymentNotification(ctx context.Context, provider payments.Provider, payload []byte) error {
+	n, err := provider.ParseNotification(payload)
+	if err != nil {
+		return err
+	}
+	_, err = h.applyPaymentResult(ctx, provider, n)
+	return err
+}
+
+// applyPaymentResult moves the donation a verified notification or capture
+// is about and returns its status afterwards. Results for unknown orders or
+// with a different amount or currency are logged and dropped rather than
+// retried. A refund's amount is what has been returned so far, so a partial
+// refund leaves the donation completed for staff to reconcile.
+func (h *DonationHandler) applyPaymentResult(ctx context.Context, provider payments.Provider, n payments.Notification) (string, error) {
+	from, ok := paymentTransitions[n.Status]
+	if !ok {
+		return "", nil
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var donationID, reportID, status, currency string
+	var donorID sql.NullString
+	var amount float64
+	err = tx.QueryRowContext(ctx,
+		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(donor_id), BIN_TO_UUID(disaster_report_id), status, amount, currency
+		FROM donations WHERE transaction_id = ? AND payment_provider = ? FOR UPDATE`,
+		n.OrderID, provider.Name(),
+	).Scan(&donationID, &donorID, &reportID, &status, &amount, &currency)
+	if err == sql.ErrNoRows {
+		log.Printf("Ignoring %s notification for unknown order %s", provider.Name(), n.OrderID)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if n.Status == payments.StatusRefunded && n.Amount < amount-0.005 {
+		log.Printf("Ignoring %s partial refund for order %s: %.2f of %.2f %s returned",
+			provider.Name(), n.OrderID, n.Amount, amount, currency)
+		return status, nil
+	}
+	if math.Abs(n.Amount-amount) >= 0.005 || n.Currency != "" && n.Currency != currency {
+		log.Printf("Ignoring %s notification for order %s: %.2f %s does not match %.2f %s",
+			provider.Name(), n.OrderID, n.Amount, n.Currency, amount, currency)
+		return status, nil
+	}
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || s == status
+	}
+	if !allowed {
+		return status, nil
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE donations
+		SET status = ?, provider_transaction_id = COALESCE(NULLIF(?, ''), provider_transaction_id),
+		payment_method = COALESCE(NULLIF(?, ''), payment_method),
+		updated_at = NOW(),
+		completed_at = IF(? = 'completed', COALESCE(completed_at, NOW()), completed_at)
+		WHERE id = UUID_TO_BIN(?)`,
+		n.Status, n.TransactionID, n.Method, n.Status, donationID,
+	)
+	if err != nil {
+		return "", err
+	}
+	if err := recordSubscriptionPayment(ctx, tx, donationID, n); err != nil {
+		return "", err
+	}
+	if err := fx.RecountDonationReport(ctx, tx, donationID); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	h.auditLogger.Log(nil, audit.Event{
+		Type:       audit.EventDonationPaymentUpdated,
+		Severity:   audit.SeverityMedium,
+		EntityType: "donation",
+		EntityID:   donationID,
+		UserAgent:  provider.Name() + " notification",
+		Details: map[string]interface{}{
+			"from": status, "status": n.Status, "amount": n.Amount,
+			"provider": provider.Name(), "providerTransactionId": n.TransactionID,
+		},
+	})
+
+	var donors []string
+	if donorID.Valid {
+		donors = append(donors, donorID.String)
+	}
+	h.webhooks.Publish(webhooks.EventDonationStatusChanged,
+		reportWebhookRecipients(h.db, reportID, donors...),
+		map[string]interface{}{
+			"id":               donationID,
+			"disasterReportId": reportID,
+			"status":           n.Status,
+		},
+	)
+	if n.Status == payments.StatusCompleted {
+		h.alerts.DonationCompleted(reportID, currency)
+		h.queueReceipt(donationID)
+	}
+	return n.Status, nil
+}
+
+// refundThroughProvider sends a completed donation's money back through its
+// gateway before it is marked refunded. Donations without a gateway, or on
+// one that cannot refund, are left to be refunded by hand. It writes the
+// error and returns false when the gateway refuses.
+func (h *DonationHandler) refundThroughProvider(w http.ResponseWriter, r *http.Request, donationID, reason string) bool {
+	var providerName, transactionID, currency, status string
+	var providerTransactionID sql.NullString
+	var amount float64
+	err := h.db.QueryRow(
+		`SELECT COALESCE(payment_provider, ''), provider_transaction_id, transaction_id, amount, currency, status
+		FROM donations WHERE id = UUID_TO_BIN(?)`,
+		donationID,
+	).Scan(&providerName, &providerTransactionID, &transactionID, &amount, &currency, &status)
+	if err == sql.ErrNoRows {
+		// UpdateStatus answers 404
+		return true
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching donation", http.StatusInternalServerError)
+		return false
+	}
+	refunder, ok := h.payments[providerName].(payments.Refunder)
+	if !ok || status != payments.StatusCompleted || !providerTransactionID.Valid {
+		return true
+	}
+
+	if err := refunder.Refund(r.Context(), payments.RefundRequest{
+		OrderID:               transactionID,
+		ProviderTransactionID: providerTransactionID.String,
+		Amount:                amount,
+		Currency:              currency,
+		Reason:                reason,
+	}); err != nil {
+		log.Printf("Failed to refund donation %s through %s: %v", donationID, providerName, err)
+		apierror.Error(w, "The payment gateway refused the refund; the donation was not changed", http.StatusBadGateway)
+		return false
+	}
+	return true
+}
+
+// CaptureDonation collects a payment the donor approved on a gateway that
+// needs the server to capture it, such as PayPal. It is open to guests:
+// capturing only completes a payment the donor already approved.
+func (h *DonationHandler) CaptureDonation(w http.ResponseWriter, r *http.Request) {
+	donationID := mux.Vars(r)["id"]
+
+	var providerName, status string
+	var providerOrderID sql.NullString
+	err := h.db.QueryRow(
+		`SELECT COALESCE(payment_provider, ''), provider_order_id, status
+		FROM donations WHERE id = UUID_TO_BIN(?)`,
+		donationID,
+	).Scan(&providerName, &providerOrderID, &status)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Donation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching donation", http.StatusInternalServerError)
+		return
+	}
+	capturer, ok := h.payments[providerName].(payments.Capturer)
+	if !ok || !providerOrderID.Valid {
+		apierror.Error(w, "This donation is not paid through a gateway that needs capturing", http.StatusConflict)
+		return
+	}
+	if status != payments.StatusPending {
+		apierror.Error(w, "Only pending donations can be captured; this one is "+status, http.StatusConflict)
+		return
+	}
+
+	n, err := capturer.Capture(r.Context(), providerOrderID.String)
+	if err != nil {
+		log.Printf("Failed to capture %s order for donation %s: %v", providerName, donationID, err)
+		apierror.Error(w, "The payment could not be captured; check that it was approved and try again", http.StatusBadGateway)
+		return
+	}
+	status, err = h.applyPaymentResult(r.Context(), h.payments[providerName], n)
+	if err != nil {
+		apierror.Error(w, "Error recording payment", http.StatusInternalServerError)
+		return
+	}
+	if status == "" {
+		// Held for review by the gateway; a notification settles it later
+		status = payments.StatusPending
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":     donationID,
+		"status": status,
+	})
+}
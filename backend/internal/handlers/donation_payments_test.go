@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"saferelief/internal/fakedb"
+	"saferelief/internal/payments"
+)
+
+const (
+	paymentTestDonation = "44444444-4444-4444-4444-444444444444"
+	paymentTestReport   = "55555555-5555-5555-5555-555555555555"
+)
+
+func midtransRefund(refundAmount string) []byte {
+	return []byte(`{"order_id": "order-1", "transaction_id": "tx-1", "transaction_status": "partial_refund",
+		"gross_amount": "100000.00", "refund_amount": "` + refundAmount + `", "payment_type": "gopay"}`)
+}
+
+// A refund only marks the donation refunded once the whole amount is back
+func TestMidtransRefundNotifications(t *testing.T) {
+	t.Setenv("MIDTRANS_SERVER_KEY", "server-key")
+	provider := payments.NewMidtransFromEnv()
+	errStop := errors.New("stop after the donation update")
+
+	tests := []struct {
+		name         string
+		refundAmount string
+		wantUpdate   bool
+	}{
+		{"partial refund", "25000.00", false},
+		{"refunded in parts up to the full amount", "100000.00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := fakedb.New(t,
+				fakedb.Rule{Match: "FROM donations WHERE transaction_id", Rows: [][]driver.Value{{
+					paymentTestDonation, nil, paymentTestReport, "completed", 100000.0, "IDR",
+				}}},
+				fakedb.Rule{Match: "UPDATE donations", Err: errStop},
+			)
+			h := &DonationHandler{db: db}
+
+			err := h.applyPaymentNotification(context.Background(), provider, midtransRefund(tt.refundAmount))
+			if tt.wantUpdate {
+				if !errors.Is(err, errStop) || !f.Ran("UPDATE donations") {
+					t.Errorf("donation was not marked refunded: %v", f.Log)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPaymentNotification: %v", err)
+			}
+			if f.Ran("UPDATE donations") {
+				t.Error("a partial refund marked the donation refunded")
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/jobs"
+	"saferelief/internal/notify"
+	"saferelief/internal/pdf"
+
+	"github.com/gorilla/mux"
+)
+
+var errNoReceipt = errors.New("only completed monetary donations have receipts")
+
+// donationReceipt is an issued receipt and what is needed to send it
+type donationReceipt struct {
+	Number      string
+	Path        string
+	EmailedAt   sql.NullTime
+	Recipient   notify.Recipient
+	Amount      string
+	ReportTitle string
+}
+
+// SetJobQueue issues and emails receipts in the background once donations
+// complete
+func (h *DonationHandler) SetJobQueue(queue *jobs.Queue) {
+	h.queue = queue
+}
+
+// GetReceipt downloads the PDF receipt of one of the caller's completed
+// donations, issuing it first if the background job has not yet
+func (h *DonationHandler) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	donationID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var exists bool
+	err := h.db.QueryRow(
+		"SELECT 1 FROM donations WHERE id = UUID_TO_BIN(?) AND donor_id = UUID_TO_BIN(?)",
+		donationID, userID,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Donation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching donation", http.StatusInternalServerError)
+		return
+	}
+
+	receipt, err := h.issueReceipt(r.Context(), donationID)
+	if err == errNoReceipt {
+		apierror.Error(w, "Only completed monetary donations have receipts", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to issue receipt for donation %s: %v", donationID, err)
+		apierror.Error(w, "Error generating receipt", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.pdf\"", receipt.Number))
+	http.ServeFile(w, r, receipt.Path)
+}
+
+// queueReceipt issues and emails the receipt of a donation that just
+// completed
+func (h *DonationHandler) queueReceipt(donationID string) {
+	if h.queue == nil {
+		return
+	}
+	err := h.queue.Enqueue(jobs.Job{
+		Name:        "donation-receipt-" + donationID,
+		MaxAttempts: 3,
+		Run: func(ctx context.Context) error {
+			return h.sendReceipt(ctx, donationID)
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue receipt for donation %s: %v", donationID, err)
+	}
+}
+
+// sendReceipt emails the receipt once; a donation refunded before the job
+// ran gets none
+func (h *DonationHandler) sendReceipt(ctx context.Context, donationID string) error {
+	receipt, err := h.issueReceipt(ctx, donationID)
+	if err == errNoReceipt {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if receipt.EmailedAt.Valid || h.mailer == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(receipt.Path)
+	if err != nil {
+		return err
+	}
+	if err := h.mailer.SendTemplate(ctx, receipt.Recipient, "donation.receipt", map[string]interface{}{
+		"Number": receipt.Number,
+		"Amount": receipt.Amount,
+		"Title":  receipt.ReportTitle,
+	}, notify.Attachment{
+		Filename:    receipt.Number + ".pdf",
+		ContentType: "application/pdf",
+		Data:        data,
+	}); err != nil {
+		return err
+	}
+	_, err = h.db.ExecContext(ctx,
+		"UPDATE donation_receipts SET emailed_at = NOW() WHERE donation_id = UUID_TO_BIN(?)", donationID,
+	)
+	return err
+}
+
+// issueReceipt numbers a completed donation's receipt and writes its PDF to
+// the receipt directory, both only once. Numbers run SR-<year>-<sequence>,
+// the sequence counting every receipt issued.
+func (h *DonationHandler) issueReceipt(ctx context.Context, donationID string) (donationReceipt, error) {
+	var receipt donationReceipt
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return receipt, err
+	}
+	defer tx.Rollback()
+
+	var status, kind, transactionID, currency, reportTitle string
+	var donorName, guestEmail, donorEmail, locale, timezone sql.NullString
+	var amount float64
+	var completedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		`SELECT d.status, d.kind, d.transaction_id, d.amount, d.currency, d.completed_at, d.guest_email,
+		COALESCE(u.display_name, u.username), u.email, u.locale, u.timezone, dr.title
+		FROM donations d
+		JOIN disaster_reports dr ON dr.id = d.disaster_report_id
+		LEFT JOIN users u ON u.id = d.donor_id
+		WHERE d.id = UUID_TO_BIN(?) FOR UPDATE`,
+		donationID,
+	).Scan(&status, &kind, &transactionID, &amount, &currency, &completedAt, &guestEmail,
+		&donorName, &donorEmail, &locale, &timezone, &reportTitle)
+	if err != nil {
+		return receipt, err
+	}
+	if status != "completed" || kind != DonationMonetary {
+		return receipt, errNoReceipt
+	}
+	receipt.Recipient = notify.Recipient{Email: donorEmail.String, Locale: locale.String, Timezone: timezone.String}
+	if !donorEmail.Valid {
+		// Guests get the platform defaults
+		receipt.Recipient = notify.Recipient{Email: guestEmail.String, Locale: "id", Timezone: "Asia/Jakarta"}
+		donorName = guestEmail
+	}
+	receipt.Amount = formatAmount(currency, amount)
+	receipt.ReportTitle = reportTitle
+	issuedAt := completedAt.Time
+	if !completedAt.Valid {
+		issuedAt = time.Now()
+	}
+
+	var path sql.NullString
+	err = tx.QueryRowContext(ctx,
+		"SELECT receipt_number, storage_path, emailed_at FROM donation_receipts WHERE donation_id = UUID_TO_BIN(?)",
+		donationID,
+	).Scan(&receipt.Number, &path, &receipt.EmailedAt)
+	if err == sql.ErrNoRows {
+		result, err := tx.ExecContext(ctx,
+			"INSERT INTO donation_receipts (donation_id) VALUES (UUID_TO_BIN(?))", donationID,
+		)
+		if err != nil {
+			return receipt, err
+		}
+		sequence, err := result.LastInsertId()
+		if err != nil {
+			return receipt, err
+		}
+		receipt.Number = fmt.Sprintf("SR-%d-%06d", issuedAt.Year(), sequence)
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE donation_receipts SET receipt_number = ? WHERE sequence = ?", receipt.Number, sequence,
+		); err != nil {
+			return receipt, err
+		}
+	} else if err != nil {
+		return receipt, err
+	}
+
+	receipt.Path = path.String
+	if _, statErr := os.Stat(receipt.Path); !path.Valid || statErr != nil {
+		receipt.Path = filepath.Join(h.receiptDir, receipt.Number+".pdf")
+		document := renderReceipt(receipt.Number, donorName.String, receipt.Amount, reportTitle, transactionID,
+			notify.FormatTime(issuedAt, receipt.Recipient.Timezone))
+		if err := os.WriteFile(receipt.Path, document, 0600); err != nil {
+			return receipt, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE donation_receipts SET storage_path = ? WHERE donation_id = UUID_TO_BIN(?)", receipt.Path, donationID,
+		); err != nil {
+			return receipt, err
+		}
+	}
+	return receipt, tx.Commit()
+}
+
+// renderReceipt lays out the receipt with English and Indonesian labels
+func renderReceipt(number, donor, amount, reportTitle, transactionID, completedAt string) []byte {
+	doc := pdf.New("SafeRelief donation receipt " + number)
+	const left, right = 60.0, pdf.PageWidth - 60
+	y := pdf.PageHeight - 80
+
+	doc.Text(left, y, pdf.Bold, 22, "SafeRelief")
+	doc.TextRight(right, y, pdf.Bold, 12, number)
+	y -= 22
+	doc.Text(left, y, pdf.Regular, 12, "Donation receipt / Tanda terima donasi")
+	y -= 18
+	doc.Line(left, right, y, 1)
+	y -= 36
+
+	for _, row := range [][2]string{
+		{"Donor / Donatur", donor},
+		{"Amount / Jumlah", amount},
+		{"Disaster report / Laporan bencana", reportTitle},
+		{"Transaction ID / ID transaksi", transactionID},
+		{"Completed / Selesai", completedAt},
+	} {
+		doc.Text(left, y, pdf.Regular, 9, strings.ToUpper(row[0]))
+		y -= 15
+		for _, line := range wrapText(row[1], 80) {
+			doc.Text(left, y, pdf.Bold, 12, line)
+			y -= 15
+		}
+		y -= 14
+	}
+
+	doc.Line(left, right, y, 0.5)
+	y -= 20
+	doc.Text(left, y, pdf.Regular, 9, "Thank you for supporting disaster relief. Keep this receipt for your records.")
+	y -= 12
+	doc.Text(left, y, pdf.Regular, 9, "Terima kasih atas dukungan Anda. Simpan tanda terima ini sebagai arsip.")
+	return doc.Bytes()
+}
+
+// formatAmount groups thousands, e.g. IDR 1,250,000.00
+func formatAmount(currency string, amount float64) string {
+	whole := strconv.FormatFloat(amount, 'f', 2, 64)
+	point := strings.IndexByte(whole, '.')
+	digits, cents := whole[:point], whole[point:]
+	var grouped strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(d)
+	}
+	return currency + " " + grouped.String() + cents
+}
+
+// wrapText breaks s into lines of at most width characters at spaces
+func wrapText(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/fx"
+	"saferelief/internal/jobs"
+	"saferelief/internal/middleware"
+	"saferelief/internal/notify"
+	"saferelief/internal/payments"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+// DonationRefund records why and by whom a donation was refunded
+type DonationRefund struct {
+	ID         string    `json:"id"`
+	DonationID string    `json:"donationId"`
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	Reason     string    `json:"reason"`
+	Provider   *string   `json:"provider"`
+	RefundedBy string    `json:"refundedBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// RefundDonation returns a completed donation in full. Platform donation
+// managers can refund any donation; finance staff of the organization that
+// filed the report can refund donations to it. Money paid through a gateway
+// that can refund goes back through it first; if the gateway refuses, the
+// request fails with 502 and nothing changes. The report's amount raised
+// drops in the same transaction that records the refund.
+func (h *DonationHandler) RefundDonation(w http.ResponseWriter, r *http.Request) {
+	donationID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	request.Reason = strings.TrimSpace(request.Reason)
+	v := validation.New()
+	if v.Required("reason", request.Reason) {
+		v.Length("reason", request.Reason, 1, 500)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// The lock is held through the gateway call so two refunds of the same
+	// donation cannot both reach it
+	var status, kind, reportID, currency string
+	var orgID, donorID sql.NullString
+	var provider *string
+	var amount float64
+	err = tx.QueryRow(
+		`SELECT d.status, d.kind, BIN_TO_UUID(d.disaster_report_id), BIN_TO_UUID(dr.organization_id), BIN_TO_UUID(d.donor_id),
+		d.amount, d.currency, d.payment_provider
+		FROM donations d JOIN disaster_reports dr ON dr.id = d.disaster_report_id
+		WHERE d.id = UUID_TO_BIN(?) FOR UPDATE`,
+		donationID,
+	).Scan(&status, &kind, &reportID, &orgID, &donorID, &amount, &currency, &provider)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Donation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching donation", http.StatusInternalServerError)
+		return
+	}
+
+	allowed := false
+	if role, err := middleware.LookupRole(h.db, userID); err == nil && role.Can(middleware.PermManageDonations) {
+		allowed = true
+	} else if orgID.Valid {
+		allowed, err = hasOrganizationPermission(h.db, orgID.String, userID, OrgPermManageDisbursements)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !allowed {
+		apierror.Error(w, "Only donation managers and the organization's finance staff can refund donations", http.StatusForbidden)
+		return
+	}
+	if status != payments.StatusCompleted || kind != DonationMonetary {
+		apierror.Error(w, "Only completed monetary donations can be refunded", http.StatusConflict)
+		return
+	}
+
+	if !h.refundThroughProvider(w, r, donationID, request.Reason) {
+		return
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE donations SET status = 'refunded', updated_at = NOW() WHERE id = UUID_TO_BIN(?)", donationID,
+	); err != nil {
+		apierror.Error(w, "Error updating donation status", http.StatusInternalServerError)
+		return
+	}
+	if err := fx.RecountDonationReport(r.Context(), tx, donationID); err != nil {
+		apierror.Error(w, "Error updating the report's amount raised", http.StatusInternalServerError)
+		return
+	}
+	var refundID string
+	if err := tx.QueryRow(
+		`INSERT INTO donation_refunds (id, donation_id, amount, currency, reason, payment_provider, refunded_by)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?, UUID_TO_BIN(?))
+		RETURNING BIN_TO_UUID(id)`,
+		donationID, amount, currency, request.Reason, provider, userID,
+	).Scan(&refundID); err != nil {
+		apierror.Error(w, "Error recording refund", http.StatusInternalServerError)
+		return
+	}
+	details, _ := json.Marshal(map[string]interface{}{"refundId": refundID, "reason": request.Reason})
+	if _, err := tx.Exec(
+		`INSERT INTO audit_logs (
+			id, user_id, action, entity_type, entity_id,
+			ip_address, user_agent, details
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), 'refund_donation',
+			'donation', UUID_TO_BIN(?), ?, ?, ?
+		)`,
+		userID, donationID, r.RemoteAddr, r.UserAgent(), details,
+	); err != nil {
+		apierror.Error(w, "Error logging refund", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		// The gateway has already returned the money; its refund
+		// notification will mark the donation refunded
+		log.Printf("Failed to record refund of donation %s: %v", donationID, err)
+		apierror.Error(w, "Error finalizing refund", http.StatusInternalServerError)
+		return
+	}
+
+	var donors []string
+	if donorID.Valid {
+		donors = append(donors, donorID.String)
+	}
+	h.webhooks.Publish(webhooks.EventDonationStatusChanged,
+		reportWebhookRecipients(h.db, reportID, donors...),
+		map[string]interface{}{
+			"id":               donationID,
+			"disasterReportId": reportID,
+			"status":           payments.StatusRefunded,
+		},
+	)
+	h.queueRefundNotice(donationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DonationRefund{
+		ID:         refundID,
+		DonationID: donationID,
+		Amount:     amount,
+		Currency:   currency,
+		Reason:     request.Reason,
+		Provider:   provider,
+		RefundedBy: userID,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// queueRefundNotice emails the donor, or the guest address, that their
+// donation was refunded and why
+func (h *DonationHandler) queueRefundNotice(donationID string) {
+	if h.queue == nil || h.mailer == nil {
+		return
+	}
+	err := h.queue.Enqueue(jobs.Job{
+		Name:        "donation-refund-" + donationID,
+		MaxAttempts: 3,
+		Run: func(ctx context.Context) error {
+			return h.sendRefundNotice(ctx, donationID)
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue refund notice for donation %s: %v", donationID, err)
+	}
+}
+
+func (h *DonationHandler) sendRefundNotice(ctx context.Context, donationID string) error {
+	var amount float64
+	var currency, reason, title string
+	var guestEmail, donorEmail, locale, timezone sql.NullString
+	err := h.db.QueryRowContext(ctx,
+		`SELECT rf.amount, rf.currency, rf.reason, dr.title, d.guest_email, u.email, u.locale, u.timezone
+		FROM donation_refunds rf
+		JOIN donations d ON d.id = rf.donation_id
+		JOIN disaster_reports dr ON dr.id = d.disaster_report_id
+		LEFT JOIN users u ON u.id = d.donor_id
+		WHERE rf.donation_id = UUID_TO_BIN(?)`,
+		donationID,
+	).Scan(&amount, &currency, &reason, &title, &guestEmail, &donorEmail, &locale, &timezone)
+	if err != nil {
+		return err
+	}
+	to := notify.Recipient{Email: donorEmail.String, Locale: locale.String, Timezone: timezone.String}
+	if !donorEmail.Valid {
+		to = notify.Recipient{Email: guestEmail.String, Locale: "id", Timezone: "Asia/Jakarta"}
+	}
+	if to.Email == "" {
+		return nil
+	}
+	return h.mailer.SendTemplate(ctx, to, "donation.refunded", map[string]interface{}{
+		"Amount": formatAmount(currency, amount),
+		"Title":  title,
+		"Reason": reason,
+	})
+}
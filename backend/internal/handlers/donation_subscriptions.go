@@ -0,0 +1,693 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/fx"
+	"saferelief/internal/notify"
+	"saferelief/internal/payments"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// Consecutive failed charges after which a subscription is paused
+	maxSubscriptionFailures = 3
+	// Subscriptions charged in one run; the rest wait for the next tick
+	maxSubscriptionsPerRun = 100
+)
+
+var (
+	errSubscriptionNotDue = errors.New("subscription is not due")
+	errReportClosed       = errors.New("report no longer accepts donations")
+)
+
+// DonationSubscription is a monthly pledge to a report. Each cycle is a
+// donation of its own, linked back through subscriptionId.
+type DonationSubscription struct {
+	ID               string  `json:"id"`
+	DisasterReportID string  `json:"disasterReportId"`
+	Amount           float64 `json:"amount"`
+	Currency         string  `json:"currency"`
+	Description      string  `json:"description"`
+	PaymentProvider  string  `json:"paymentProvider"`
+	Status           string  `json:"status"`
+	// Cycles is how many monthly donations have been started
+	Cycles int `json:"cycles"`
+	// NextChargeAt is nil once the subscription is cancelled
+	NextChargeAt *time.Time `json:"nextChargeAt"`
+	// SavedPaymentMethod is whether cycles are charged without the donor;
+	// otherwise each cycle emails a payment link
+	SavedPaymentMethod bool       `json:"savedPaymentMethod"`
+	FailedAttempts     int        `json:"failedAttempts"`
+	PausedAt           *time.Time `json:"pausedAt"`
+	CancelledAt        *time.Time `json:"cancelledAt"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+type subscriptionRequest struct {
+	DisasterReportID string  `json:"disasterReportId"`
+	Amount           float64 `json:"amount"`
+	Currency         string  `json:"currency"`
+	Description      string  `json:"description"`
+	Provider         string  `json:"provider"`
+}
+
+// subscriptionCycle is one month's donation of a subscription, inserted
+// pending and waiting to be charged
+type subscriptionCycle struct {
+	SubscriptionID string
+	DonationID     string
+	DonorID        string
+	ReportID       string
+	TransactionID  string
+	Provider       string
+	Amount         float64
+	Currency       string
+	Description    string
+	SavedToken     string
+	Cycle          int
+}
+
+// SetMailer sends payment links for cycles that cannot be charged without
+// the donor
+func (h *DonationHandler) SetMailer(mailer *notify.Mailer) {
+	h.mailer = mailer
+}
+
+// CreateSubscription pledges a monthly donation to a report. The first
+// cycle starts right away and its checkout is returned, so the donor pays
+// it now and, where the gateway can, saves the card for later cycles.
+func (h *DonationHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var req subscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = "IDR"
+	}
+
+	v := validation.New()
+	v.Required("disasterReportId", req.DisasterReportID)
+	v.Check(req.Amount > 0, "amount", "must be greater than 0")
+	h.checkCurrency(r.Context(), v, req.Currency, req.Amount)
+	provider, ok := h.payments[req.Provider]
+	if v.Required("provider", req.Provider) {
+		v.Check(ok, "provider", "is not a configured payment provider")
+	}
+	if ok && req.Amount > 0 {
+		if message := provider.Validate(payments.Charge{Amount: req.Amount, Currency: req.Currency}); message != "" {
+			v.AddError("amount", message)
+		}
+		if err := checkDonationLimits(r.Context(), h.db, v, req.Currency, req.Amount, req.Provider); err != nil {
+			apierror.Error(w, "Error checking donation limits", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var reportStatus ReportStatus
+	var fundraisingClosed bool
+	err = tx.QueryRow(
+		`SELECT status, fundraising_closed_at IS NOT NULL FROM disaster_reports
+		WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
+		req.DisasterReportID,
+	).Scan(&reportStatus, &fundraisingClosed)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Disaster report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error verifying disaster report", http.StatusInternalServerError)
+		return
+	}
+	if !reportStatus.AcceptsDonations() {
+		apierror.Error(w, "Cannot donate to a disaster report that is not verified or is already resolved", http.StatusBadRequest)
+		return
+	}
+	if fundraisingClosed {
+		apierror.Error(w, "This report reached its fundraising goal and no longer takes donations", http.StatusConflict)
+		return
+	}
+
+	var subscriptionID string
+	err = tx.QueryRow(
+		`INSERT INTO donation_subscriptions (
+			id, donor_id, disaster_report_id, amount, currency, description, payment_provider, next_charge_at
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, NOW()
+		) RETURNING BIN_TO_UUID(id)`,
+		userID, req.DisasterReportID, req.Amount, req.Currency, req.Description, req.Provider,
+	).Scan(&subscriptionID)
+	if err != nil {
+		apierror.Error(w, "Error creating subscription", http.StatusInternalServerError)
+		return
+	}
+	cycle, err := startSubscriptionCycle(r.Context(), tx, subscriptionID)
+	if err != nil {
+		apierror.Error(w, "Error creating subscription", http.StatusInternalServerError)
+		return
+	}
+	if err := logSubscriptionAudit(r.Context(), tx, userID, "create_donation_subscription", subscriptionID, r, map[string]interface{}{
+		"amount": fmt.Sprintf("%.2f", req.Amount), "currency": req.Currency, "provider": req.Provider,
+	}); err != nil {
+		apierror.Error(w, "Error logging subscription", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error finalizing subscription", http.StatusInternalServerError)
+		return
+	}
+
+	checkout, err := h.chargeSubscriptionCycle(r.Context(), cycle)
+	if err != nil {
+		log.Printf("Failed to start first cycle of subscription %s: %v", subscriptionID, err)
+		h.db.Exec(
+			"UPDATE donation_subscriptions SET status = 'cancelled', cancelled_at = NOW() WHERE id = UUID_TO_BIN(?)",
+			subscriptionID,
+		)
+		apierror.Error(w, "The payment gateway is unavailable; please try again", http.StatusBadGateway)
+		return
+	}
+
+	subscription, err := h.loadSubscription(r.Context(), subscriptionID, userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching subscription", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subscription": subscription,
+		"donationId":   cycle.DonationID,
+		"payment": map[string]interface{}{
+			"provider":    cycle.Provider,
+			"token":       checkout.Token,
+			"redirectUrl": checkout.RedirectURL,
+		},
+	})
+}
+
+// ListSubscriptions returns the caller's subscriptions, newest first
+func (h *DonationHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	rows, err := h.db.QueryContext(r.Context(), subscriptionSelect+
+		" WHERE donor_id = UUID_TO_BIN(?) ORDER BY created_at DESC", userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching subscriptions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	subscriptions := []DonationSubscription{}
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			apierror.Error(w, "Error processing subscriptions", http.StatusInternalServerError)
+			return
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// PauseSubscription stops charging until the donor resumes
+func (h *DonationHandler) PauseSubscription(w http.ResponseWriter, r *http.Request) {
+	h.setSubscriptionStatus(w, r, "paused", "pause_donation_subscription", "active")
+}
+
+// ResumeSubscription charges again from the next billing day; months
+// spent paused are skipped, not made up
+func (h *DonationHandler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	h.setSubscriptionStatus(w, r, "active", "resume_donation_subscription", "paused")
+}
+
+// CancelSubscription ends the pledge for good. A cycle already started
+// stays payable.
+func (h *DonationHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	h.setSubscriptionStatus(w, r, "cancelled", "cancel_donation_subscription", "active", "paused")
+}
+
+func (h *DonationHandler) setSubscriptionStatus(w http.ResponseWriter, r *http.Request, to, action string, from ...string) {
+	subscriptionID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	var nextChargeAt, createdAt, now time.Time
+	err = tx.QueryRow(
+		`SELECT status, next_charge_at, created_at, NOW() FROM donation_subscriptions
+		WHERE id = UUID_TO_BIN(?) AND donor_id = UUID_TO_BIN(?) FOR UPDATE`,
+		subscriptionID, userID,
+	).Scan(&status, &nextChargeAt, &createdAt, &now)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching subscription", http.StatusInternalServerError)
+		return
+	}
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || s == status
+	}
+	if !allowed {
+		apierror.Error(w, "The subscription is "+status, http.StatusConflict)
+		return
+	}
+	if to == "active" && nextChargeAt.Before(now) {
+		nextChargeAt = nextBillingDate(createdAt, now)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE donation_subscriptions
+		SET status = ?, next_charge_at = ?,
+		failed_attempts = IF(? = 'active', 0, failed_attempts),
+		paused_at = IF(? = 'paused', NOW(), NULL),
+		cancelled_at = IF(? = 'cancelled', NOW(), NULL),
+		updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?)`,
+		to, nextChargeAt, to, to, to, subscriptionID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error updating subscription", http.StatusInternalServerError)
+		return
+	}
+	if err := logSubscriptionAudit(r.Context(), tx, userID, action, subscriptionID, r, map[string]interface{}{
+		"from": status, "to": to,
+	}); err != nil {
+		apierror.Error(w, "Error logging subscription update", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error finalizing subscription update", http.StatusInternalServerError)
+		return
+	}
+
+	subscription, err := h.loadSubscription(r.Context(), subscriptionID, userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching subscription", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// StartSubscriptions starts due subscription cycles every interval until
+// the process exits
+func (h *DonationHandler) StartSubscriptions(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.chargeDueSubscriptions()
+		}
+	}()
+}
+
+// chargeDueSubscriptions starts a cycle for each active subscription past
+// its next charge date. Saved cards are charged on the spot; otherwise the
+// donor is emailed a payment link for the cycle.
+func (h *DonationHandler) chargeDueSubscriptions() {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id) FROM donation_subscriptions
+		WHERE status = 'active' AND next_charge_at <= NOW()
+		ORDER BY next_charge_at LIMIT ?`,
+		maxSubscriptionsPerRun,
+	)
+	if err != nil {
+		log.Printf("Failed to find due donation subscriptions: %v", err)
+		return
+	}
+	var due []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			due = append(due, id)
+		}
+	}
+	rows.Close()
+
+	ctx := context.Background()
+	for _, subscriptionID := range due {
+		cycle, err := h.beginSubscriptionCycle(ctx, subscriptionID)
+		if err == errSubscriptionNotDue {
+			// Paused, cancelled or started by another instance since the query
+			continue
+		} else if err == errReportClosed {
+			log.Printf("Cancelled donation subscription %s: its report no longer accepts donations", subscriptionID)
+			continue
+		} else if err != nil {
+			log.Printf("Failed to start cycle of donation subscription %s: %v", subscriptionID, err)
+			continue
+		}
+
+		checkout, err := h.chargeSubscriptionCycle(ctx, cycle)
+		if err != nil {
+			log.Printf("Failed to charge cycle %d of donation subscription %s: %v", cycle.Cycle, subscriptionID, err)
+			continue
+		}
+		if checkout.RedirectURL != "" {
+			h.emailPaymentLink(ctx, cycle, checkout.RedirectURL)
+		}
+	}
+}
+
+// beginSubscriptionCycle starts the next cycle in its own transaction. A
+// subscription whose report stopped taking donations is cancelled instead.
+func (h *DonationHandler) beginSubscriptionCycle(ctx context.Context, subscriptionID string) (subscriptionCycle, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return subscriptionCycle{}, err
+	}
+	defer tx.Rollback()
+
+	cycle, err := startSubscriptionCycle(ctx, tx, subscriptionID)
+	if err == errReportClosed {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE donation_subscriptions SET status = 'cancelled', cancelled_at = NOW(), updated_at = NOW()
+			WHERE id = UUID_TO_BIN(?)`,
+			subscriptionID,
+		); err != nil {
+			return cycle, err
+		}
+		if err := logSubscriptionAudit(ctx, tx, "", "cancel_donation_subscription", subscriptionID, nil, map[string]interface{}{
+			"reason": "report_closed",
+		}); err != nil {
+			return cycle, err
+		}
+		if err := tx.Commit(); err != nil {
+			return cycle, err
+		}
+		return cycle, errReportClosed
+	}
+	if err != nil {
+		return cycle, err
+	}
+	return cycle, tx.Commit()
+}
+
+// startSubscriptionCycle moves a due subscription to its next billing date
+// and inserts the pending donation for the cycle. The unique cycle number
+// per subscription keeps two instances from charging a month twice.
+func startSubscriptionCycle(ctx context.Context, tx *sql.Tx, subscriptionID string) (subscriptionCycle, error) {
+	c := subscriptionCycle{SubscriptionID: subscriptionID}
+	var savedToken sql.NullString
+	var createdAt, now time.Time
+	var reportStatus ReportStatus
+	var reportDeleted, fundraisingClosed bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT BIN_TO_UUID(s.donor_id), BIN_TO_UUID(s.disaster_report_id), s.amount, s.currency,
+		COALESCE(s.description, ''), s.payment_provider, s.payment_method_token, s.cycles, s.created_at, NOW(),
+		dr.status, dr.deleted_at IS NOT NULL, dr.fundraising_closed_at IS NOT NULL
+		FROM donation_subscriptions s JOIN disaster_reports dr ON dr.id = s.disaster_report_id
+		WHERE s.id = UUID_TO_BIN(?) AND s.status = 'active' AND s.next_charge_at <= NOW()
+		FOR UPDATE`,
+		subscriptionID,
+	).Scan(&c.DonorID, &c.ReportID, &c.Amount, &c.Currency, &c.Description, &c.Provider, &savedToken,
+		&c.Cycle, &createdAt, &now, &reportStatus, &reportDeleted, &fundraisingClosed)
+	if err == sql.ErrNoRows {
+		return c, errSubscriptionNotDue
+	}
+	if err != nil {
+		return c, err
+	}
+	if reportDeleted || fundraisingClosed || !reportStatus.AcceptsDonations() {
+		return c, errReportClosed
+	}
+	c.Cycle++
+	c.SavedToken = savedToken.String
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE donation_subscriptions SET cycles = ?, next_charge_at = ?, updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?)`,
+		c.Cycle, nextBillingDate(createdAt, now), subscriptionID,
+	); err != nil {
+		return c, err
+	}
+
+	c.TransactionID = generateTransactionID()
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO donations (
+			id, donor_id, disaster_report_id, amount, currency, description, status,
+			transaction_id, payment_method, payment_provider, subscription_id, subscription_cycle
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, 'pending',
+			?, ?, ?, UUID_TO_BIN(?), ?
+		) RETURNING BIN_TO_UUID(id)`,
+		c.DonorID, c.ReportID, c.Amount, c.Currency, c.Description,
+		c.TransactionID, c.Provider, c.Provider, subscriptionID, c.Cycle,
+	).Scan(&c.DonationID)
+	if err != nil {
+		return c, err
+	}
+	if err := fx.NormalizeDonation(ctx, tx, c.DonationID); err != nil {
+		return c, err
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"amount": fmt.Sprintf("%.2f", c.Amount), "currency": c.Currency,
+		"subscriptionId": subscriptionID, "cycle": c.Cycle,
+	})
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO audit_logs (
+			id, user_id, action, entity_type, entity_id,
+			ip_address, user_agent, details
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), 'create_donation', 'donation',
+			UUID_TO_BIN(?), '', 'donation subscription', ?
+		)`,
+		c.DonorID, c.DonationID, json.RawMessage(details),
+	)
+	return c, err
+}
+
+// chargeSubscriptionCycle collects a started cycle: a saved card is
+// charged directly, otherwise a checkout is opened for the donor. A cycle
+// the gateway cannot take is marked failed and counts toward pausing.
+func (h *DonationHandler) chargeSubscriptionCycle(ctx context.Context, c subscriptionCycle) (payments.Checkout, error) {
+	h.webhooks.Publish(webhooks.EventDonationCreated,
+		reportWebhookRecipients(h.db, c.ReportID, c.DonorID),
+		map[string]interface{}{
+			"id":               c.DonationID,
+			"disasterReportId": c.ReportID,
+			"amount":           c.Amount,
+			"currency":         c.Currency,
+			"status":           "pending",
+			"subscriptionId":   c.SubscriptionID,
+		},
+	)
+
+	provider, ok := h.payments[c.Provider]
+	if !ok {
+		h.failSubscriptionCycle(ctx, c)
+		return payments.Checkout{}, fmt.Errorf("payment provider %q is not configured", c.Provider)
+	}
+	var email string
+	h.db.QueryRowContext(ctx, "SELECT email FROM users WHERE id = UUID_TO_BIN(?)", c.DonorID).Scan(&email)
+	charge := payments.Charge{
+		OrderID:     c.TransactionID,
+		Amount:      c.Amount,
+		Currency:    c.Currency,
+		Email:       email,
+		Description: c.Description,
+		SaveMethod:  true,
+		CustomerID:  c.DonorID,
+	}
+
+	if charger, ok := provider.(payments.RecurringCharger); ok && c.SavedToken != "" {
+		n, err := charger.ChargeSaved(ctx, charge, c.SavedToken)
+		if err != nil {
+			h.failSubscriptionCycle(ctx, c)
+			return payments.Checkout{}, err
+		}
+		_, err = h.applyPaymentResult(ctx, provider, n)
+		return payments.Checkout{}, err
+	}
+
+	checkout, err := provider.Checkout(ctx, charge)
+	if err != nil {
+		h.failSubscriptionCycle(ctx, c)
+		return checkout, err
+	}
+	if _, ok := provider.(payments.Capturer); ok {
+		if _, err := h.db.ExecContext(ctx,
+			"UPDATE donations SET provider_order_id = ? WHERE id = UUID_TO_BIN(?)", checkout.Token, c.DonationID,
+		); err != nil {
+			log.Printf("Failed to store %s order %s for donation %s: %v", provider.Name(), checkout.Token, c.DonationID, err)
+		}
+	}
+	return checkout, nil
+}
+
+func (h *DonationHandler) failSubscriptionCycle(ctx context.Context, c subscriptionCycle) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to mark donation %s failed: %v", c.DonationID, err)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE donations SET status = 'failed' WHERE id = UUID_TO_BIN(?) AND status = 'pending'", c.DonationID,
+	); err != nil {
+		log.Printf("Failed to mark donation %s failed: %v", c.DonationID, err)
+		return
+	}
+	if err := recordSubscriptionPayment(ctx, tx, c.DonationID, payments.Notification{Status: payments.StatusFailed}); err != nil {
+		log.Printf("Failed to record failed charge for subscription %s: %v", c.SubscriptionID, err)
+		return
+	}
+	tx.Commit()
+}
+
+// recordSubscriptionPayment updates the subscription a cycle donation
+// belongs to: a completed payment clears the failure count and keeps any
+// card the donor saved, a failed one counts toward pausing. Donations
+// outside a subscription are left alone.
+func recordSubscriptionPayment(ctx context.Context, tx *sql.Tx, donationID string, n payments.Notification) error {
+	const subscriptionOf = "id = (SELECT subscription_id FROM donations WHERE id = UUID_TO_BIN(?))"
+	switch n.Status {
+	case payments.StatusCompleted:
+		_, err := tx.ExecContext(ctx,
+			`UPDATE donation_subscriptions
+			SET failed_attempts = 0, payment_method_token = COALESCE(NULLIF(?, ''), payment_method_token)
+			WHERE `+subscriptionOf,
+			n.SavedToken, donationID,
+		)
+		return err
+	case payments.StatusFailed:
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE donation_subscriptions SET failed_attempts = failed_attempts + 1 WHERE "+subscriptionOf,
+			donationID,
+		); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`UPDATE donation_subscriptions SET status = 'paused', paused_at = NOW()
+			WHERE status = 'active' AND failed_attempts >= ? AND `+subscriptionOf,
+			maxSubscriptionFailures, donationID,
+		)
+		return err
+	}
+	return nil
+}
+
+// emailPaymentLink asks the donor to pay a cycle that could not be charged
+// without them
+func (h *DonationHandler) emailPaymentLink(ctx context.Context, c subscriptionCycle, link string) {
+	if h.mailer == nil {
+		return
+	}
+	to, err := notify.LookupRecipient(ctx, h.db, c.DonorID)
+	if err != nil {
+		log.Printf("Failed to look up donor of subscription %s: %v", c.SubscriptionID, err)
+		return
+	}
+	if err := h.mailer.SendTemplate(ctx, to, "donation_subscription.payment_due", map[string]interface{}{
+		"Amount":   c.Amount,
+		"Currency": c.Currency,
+		"Cycle":    c.Cycle,
+		"Link":     link,
+	}); err != nil {
+		log.Printf("Failed to email payment link for subscription %s: %v", c.SubscriptionID, err)
+	}
+}
+
+// nextBillingDate is the first monthly charge after the given time, on the
+// subscription's start day and time of day. Short months charge on their
+// last day rather than skipping.
+func nextBillingDate(start, after time.Time) time.Time {
+	for months := 0; ; months++ {
+		first := time.Date(after.Year(), after.Month()+time.Month(months), 1,
+			start.Hour(), start.Minute(), start.Second(), 0, after.Location())
+		t := first.AddDate(0, 0, min(start.Day(), first.AddDate(0, 1, -1).Day())-1)
+		if t.After(after) {
+			return t
+		}
+	}
+}
+
+// logSubscriptionAudit records a subscription change. Changes made by the
+// scheduler have no user and no request.
+func logSubscriptionAudit(ctx context.Context, tx *sql.Tx, userID, action, subscriptionID string, r *http.Request, details map[string]interface{}) error {
+	var actor interface{}
+	if userID != "" {
+		actor = userID
+	}
+	ip, userAgent := "", "donation subscription"
+	if r != nil {
+		ip, userAgent = r.RemoteAddr, r.UserAgent()
+	}
+	raw, _ := json.Marshal(details)
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO audit_logs (
+			id, user_id, action, entity_type, entity_id,
+			ip_address, user_agent, details
+		) VALUES (
+			UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, 'donation_subscription',
+			UUID_TO_BIN(?), ?, ?, ?
+		)`,
+		actor, action, subscriptionID, ip, userAgent, json.RawMessage(raw),
+	)
+	return err
+}
+
+const subscriptionSelect = `SELECT BIN_TO_UUID(id), BIN_TO_UUID(disaster_report_id), amount, currency,
+	COALESCE(description, ''), payment_provider, status, cycles, next_charge_at,
+	payment_method_token IS NOT NULL, failed_attempts, paused_at, cancelled_at, created_at, updated_at
+	FROM donation_subscriptions`
+
+func scanSubscription(row interface{ Scan(...interface{}) error }) (DonationSubscription, error) {
+	var s DonationSubscription
+	var nextChargeAt time.Time
+	err := row.Scan(&s.ID, &s.DisasterReportID, &s.Amount, &s.Currency, &s.Description, &s.PaymentProvider,
+		&s.Status, &s.Cycles, &nextChargeAt, &s.SavedPaymentMethod, &s.FailedAttempts,
+		&s.PausedAt, &s.CancelledAt, &s.CreatedAt, &s.UpdatedAt)
+	if err == nil && s.Status != "cancelled" {
+		s.NextChargeAt = &nextChargeAt
+	}
+	return s, err
+}
+
+func (h *DonationHandler) loadSubscription(ctx context.Context, subscriptionID, userID string) (DonationSubscription, error) {
+	return scanSubscription(h.db.QueryRowContext(ctx,
+		subscriptionSelect+" WHERE id = UUID_TO_BIN(?) AND donor_id = UUID_TO_BIN(?)",
+		subscriptionID, userID,
+	))
+}
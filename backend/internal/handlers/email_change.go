@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/notify"
+	"saferelief/internal/tokens"
+	"saferelief/internal/validation"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const emailChangeTTL = 24 * time.Hour
+
+// EmailChangeHandler implements the change-email flow: both the current and
+// the new address receive a confirmation link, and the email is only swapped
+// once both have confirmed. The current address can cancel instead.
+type EmailChangeHandler struct {
+	db          *sql.DB
+	mailer      *notify.Mailer
+	auditLogger *audit.Logger
+	frontendURL string
+}
+
+func NewEmailChangeHandler(db *sql.DB, mailer *notify.Mailer, auditLogger *audit.Logger) *EmailChangeHandler {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	return &EmailChangeHandler{
+		db:          db,
+		mailer:      mailer,
+		auditLogger: auditLogger,
+		frontendURL: frontendURL,
+	}
+}
+
+func (h *EmailChangeHandler) RequestChange(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		NewEmail string `json:"newEmail"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	request.NewEmail = strings.TrimSpace(request.NewEmail)
+
+	v := validation.New()
+	v.Email("newEmail", request.NewEmail)
+	v.Required("password", request.Password)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	var currentEmail, passwordHash string
+	err := h.db.QueryRow(
+		"SELECT email, password_hash FROM users WHERE id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&currentEmail, &passwordHash)
+	if err != nil {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// Re-authenticate so a stolen session alone can't start the flow
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(request.Password)); err != nil {
+		apierror.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+	if strings.EqualFold(currentEmail, request.NewEmail) {
+		apierror.Error(w, "New email is the same as the current one", http.StatusBadRequest)
+		return
+	}
+
+	var taken int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", request.NewEmail).Scan(&taken); err != nil {
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if taken > 0 {
+		apierror.Error(w, "Email already registered", http.StatusConflict)
+		return
+	}
+
+	oldToken, oldHash, err := tokens.Generate()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	newToken, newHash, err := tokens.Generate()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Only one change can be in flight per user
+	_, err = tx.Exec(
+		"UPDATE email_changes SET cancelled_at = NOW() WHERE user_id = UUID_TO_BIN(?) AND completed_at IS NULL AND cancelled_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	_, err = tx.Exec(
+		`INSERT INTO email_changes (id, user_id, old_email, new_email, old_token_hash, new_token_hash, expires_at)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?, ?)`,
+		userID, currentEmail, request.NewEmail, oldHash, newHash, time.Now().Add(emailChangeTTL),
+	)
+	if err != nil {
+		apierror.Error(w, "Error starting email change", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error starting email change", http.StatusInternalServerError)
+		return
+	}
+
+	link := h.frontendURL + "/account/email-change?token="
+	current, err := notify.LookupRecipient(r.Context(), h.db, userID)
+	if err != nil {
+		current = notify.Recipient{Email: currentEmail}
+	}
+	next := current
+	next.Email = request.NewEmail
+	if err := h.mailer.SendTemplate(r.Context(), next, "email_change.confirm_new",
+		map[string]interface{}{"Link": link + newToken}); err != nil {
+		log.Printf("Failed to send email change confirmation: %v", err)
+	}
+	if err := h.mailer.SendTemplate(r.Context(), current, "email_change.notice_current", map[string]interface{}{
+		"NewEmail":    request.NewEmail,
+		"ConfirmLink": link + oldToken,
+		"CancelLink":  link + oldToken + "&action=cancel",
+	}); err != nil {
+		log.Printf("Failed to send email change notice: %v", err)
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventEmailChangeRequested,
+		Severity: audit.SeverityMedium,
+		UserID:   userID,
+		EntityID: userID,
+		Details:  map[string]interface{}{"newEmail": request.NewEmail},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Confirmation links sent to your current and new email addresses",
+	})
+}
+
+// ConfirmChange accepts a token from either address; the email is swapped
+// once both have been confirmed
+func (h *EmailChangeHandler) ConfirmChange(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	hash := tokens.Hash(request.Token)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var changeID, userID, oldEmail, newEmail, oldHash string
+	var oldConfirmed, newConfirmed *time.Time
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(user_id), old_email, new_email, old_token_hash,
+		old_confirmed_at, new_confirmed_at
+		FROM email_changes
+		WHERE (old_token_hash = ? OR new_token_hash = ?)
+		AND completed_at IS NULL AND cancelled_at IS NULL AND expires_at > NOW()
+		FOR UPDATE`,
+		hash, hash,
+	).Scan(&changeID, &userID, &oldEmail, &newEmail, &oldHash, &oldConfirmed, &newConfirmed)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	column := "new_confirmed_at"
+	if hash == oldHash {
+		column = "old_confirmed_at"
+		oldConfirmed = &now
+	} else {
+		newConfirmed = &now
+	}
+	if _, err := tx.Exec("UPDATE email_changes SET "+column+" = NOW() WHERE id = UUID_TO_BIN(?)", changeID); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	completed := oldConfirmed != nil && newConfirmed != nil
+	if completed {
+		result, err := tx.Exec(
+			`UPDATE users SET email = ?, email_verified_at = NOW(), email_bounced_at = NULL, updated_at = NOW()
+			WHERE id = UUID_TO_BIN(?) AND email = ? AND deleted_at IS NULL`,
+			newEmail, userID, oldEmail,
+		)
+		if err != nil {
+			apierror.Error(w, "Email address is no longer available", http.StatusConflict)
+			return
+		}
+		// The account's address changed some other way since the request, so
+		// the confirmation from the old address no longer speaks for it
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			if _, err := tx.Exec("UPDATE email_changes SET cancelled_at = NOW() WHERE id = UUID_TO_BIN(?)", changeID); err != nil {
+				apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			apierror.Error(w, "This email change is no longer valid; start a new one", http.StatusConflict)
+			return
+		}
+		if _, err := tx.Exec("UPDATE email_changes SET completed_at = NOW() WHERE id = UUID_TO_BIN(?)", changeID); err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !completed {
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Address confirmed; waiting for confirmation from the other address",
+		})
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventEmailChanged,
+		Severity: audit.SeverityHigh,
+		UserID:   userID,
+		EntityID: userID,
+		Details:  map[string]interface{}{"oldEmail": oldEmail, "newEmail": newEmail},
+	})
+	// Falls back to the default locale if the profile can't be read
+	previous, _ := notify.LookupRecipient(r.Context(), h.db, userID)
+	previous.Email = oldEmail
+	if err := h.mailer.SendTemplate(r.Context(), previous, "email_change.completed",
+		map[string]interface{}{"NewEmail": newEmail}); err != nil {
+		log.Printf("Failed to send email change notice: %v", err)
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Email address changed successfully",
+	})
+}
+
+// CancelChange lets the current address abort a pending change
+func (h *EmailChangeHandler) CancelChange(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var changeID, userID string
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(user_id) FROM email_changes
+		WHERE old_token_hash = ? AND completed_at IS NULL AND cancelled_at IS NULL`,
+		tokens.Hash(request.Token),
+	).Scan(&changeID, &userID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := h.db.Exec("UPDATE email_changes SET cancelled_at = NOW() WHERE id = UUID_TO_BIN(?)", changeID); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventEmailChangeCancelled,
+		Severity: audit.SeverityHigh,
+		UserID:   userID,
+		EntityID: userID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Email change cancelled",
+	})
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/emailevents"
+	"saferelief/internal/inbound"
+	"saferelief/internal/notify"
+
+	"github.com/gorilla/mux"
+)
+
+type EmailSuppression struct {
+	Email     string    `json:"email"`
+	Reason    string    `json:"reason"`
+	Source    string    `json:"source"`
+	Detail    *string   `json:"detail"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type EmailEventHandler struct {
+	db          *sql.DB
+	auditLogger *audit.Logger
+	sns         *emailevents.SNSVerifier
+	sesTopicARN string
+	sendGridKey *ecdsa.PublicKey
+}
+
+// NewEmailEventHandler enables the SES receiver when SES_SNS_TOPIC_ARN is set
+// and the SendGrid receiver when SENDGRID_WEBHOOK_PUBLIC_KEY is set
+func NewEmailEventHandler(db *sql.DB, auditLogger *audit.Logger) (*EmailEventHandler, error) {
+	h := &EmailEventHandler{
+		db:          db,
+		auditLogger: auditLogger,
+		sns:         emailevents.NewSNSVerifier(),
+		sesTopicARN: os.Getenv("SES_SNS_TOPIC_ARN"),
+	}
+	if encoded := os.Getenv("SENDGRID_WEBHOOK_PUBLIC_KEY"); encoded != "" {
+		key, err := emailevents.ParseSendGridKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		h.sendGridKey = key
+	}
+	return h, nil
+}
+
+// RegisterInbound adds the configured email providers to the inbound
+// webhook receiver
+func (h *EmailEventHandler) RegisterInbound(receiver *inbound.Receiver) {
+	if h.sesTopicARN != "" {
+		receiver.Register("ses", inbound.Provider{Verify: h.verifySES, Process: h.processSES})
+	}
+	if h.sendGridKey != nil {
+		receiver.Register("sendgrid", inbound.Provider{Verify: h.verifySendGrid, Process: h.processSendGrid})
+	}
+}
+
+// verifySES authenticates a notification from the configured SNS topic
+func (h *EmailEventHandler) verifySES(r *http.Request, body []byte) (string, error) {
+	var message emailevents.SNSMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return "", inbound.ErrInvalidPayload
+	}
+	if message.TopicArn != h.sesTopicARN {
+		return "", inbound.ErrInvalidSignature
+	}
+	if err := h.sns.Verify(r.Context(), message); err != nil {
+		return "", err
+	}
+	if err := inbound.CheckTimestamp(message.Timestamp, inbound.DefaultTolerance); err != nil {
+		return "", err
+	}
+	return message.MessageID, nil
+}
+
+func (h *EmailEventHandler) processSES(ctx context.Context, payload []byte) error {
+	var message emailevents.SNSMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		if err := h.sns.ConfirmSubscription(ctx, message); err != nil {
+			return fmt.Errorf("confirming SNS subscription to %s: %w", message.TopicArn, err)
+		}
+	case "Notification":
+		events, err := emailevents.ParseSES([]byte(message.Message))
+		if err != nil {
+			return err
+		}
+		return h.apply(ctx, "ses", events)
+	}
+	return nil
+}
+
+// verifySendGrid checks the Event Webhook signature; batches carry no single
+// ID, so they are deduplicated by body hash
+func (h *EmailEventHandler) verifySendGrid(r *http.Request, body []byte) (string, error) {
+	timestamp := r.Header.Get(emailevents.SendGridTimestampHeader)
+	if err := emailevents.VerifySendGrid(h.sendGridKey,
+		r.Header.Get(emailevents.SendGridSignatureHeader), timestamp, body,
+	); err != nil {
+		return "", err
+	}
+	return "", inbound.CheckTimestamp(timestamp, inbound.DefaultTolerance)
+}
+
+func (h *EmailEventHandler) processSendGrid(ctx context.Context, payload []byte) error {
+	events, err := emailevents.ParseSendGrid(payload)
+	if err != nil {
+		return err
+	}
+	return h.apply(ctx, "sendgrid", events)
+}
+
+// apply suppresses each address; a failure retries the whole batch, which is
+// safe because suppression is idempotent
+func (h *EmailEventHandler) apply(ctx context.Context, source string, events []emailevents.Event) error {
+	for _, event := range events {
+		if event.Email == "" {
+			continue
+		}
+		userID, err := notify.Suppress(ctx, h.db, event.Email, string(event.Kind), source, event.Detail)
+		if err != nil {
+			return fmt.Errorf("suppressing address from %s %s: %w", source, event.Kind, err)
+		}
+		if userID != "" {
+			h.auditLogger.Log(nil, audit.Event{
+				Type:     audit.EventEmailSuppressed,
+				Severity: audit.SeverityMedium,
+				EntityID: userID,
+				Details:  map[string]interface{}{"reason": event.Kind, "source": source},
+			})
+		}
+	}
+	return nil
+}
+
+// ListSuppressions returns suppressed addresses, optionally filtered by
+// ?email= prefix
+func (h *EmailEventHandler) ListSuppressions(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l < limit {
+		limit = l
+	}
+	prefix := strings.ToLower(r.URL.Query().Get("email"))
+	prefix = strings.NewReplacer("%", "\\%", "_", "\\_").Replace(prefix)
+
+	rows, err := h.db.Query(
+		`SELECT email, reason, source, detail, created_at, updated_at
+		FROM email_suppressions WHERE email LIKE ?
+		ORDER BY updated_at DESC LIMIT ?`,
+		prefix+"%", limit,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching suppressions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	suppressions := []EmailSuppression{}
+	for rows.Next() {
+		var s EmailSuppression
+		if err := rows.Scan(&s.Email, &s.Reason, &s.Source, &s.Detail, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			apierror.Error(w, "Error processing suppressions", http.StatusInternalServerError)
+			return
+		}
+		suppressions = append(suppressions, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suppressions)
+}
+
+// RemoveSuppression allows mail to an address again, e.g. once the user has
+// fixed their mailbox. The account stays unverified until it confirms an
+// address again.
+func (h *EmailEventHandler) RemoveSuppression(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("user_id").(string)
+	email := strings.ToLower(mux.Vars(r)["email"])
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM email_suppressions WHERE email = ?", email)
+	if err != nil {
+		apierror.Error(w, "Error removing suppression", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Address is not suppressed", http.StatusNotFound)
+		return
+	}
+	if _, err := tx.Exec("UPDATE users SET email_bounced_at = NULL WHERE email = ?", email); err != nil {
+		apierror.Error(w, "Error removing suppression", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error removing suppression", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventSuppressionRemoved,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "email_suppression",
+		Details:    map[string]interface{}{"email": email},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Suppression removed"})
+}
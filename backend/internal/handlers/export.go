@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/jobs"
+	"saferelief/internal/notify"
+	"saferelief/internal/tokens"
+)
+
+const (
+	dataExportTTL = 7 * 24 * time.Hour
+	// dataExportLinkTTL bounds how long a download link works without a
+	// session; the status endpoint hands out a fresh one on every call
+	dataExportLinkTTL = time.Hour
+)
+
+type DataExport struct {
+	ID                string     `json:"id"`
+	Status            string     `json:"status"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	CompletedAt       *time.Time `json:"completedAt"`
+	ExpiresAt         *time.Time `json:"expiresAt"`
+	DownloadURL       string     `json:"downloadUrl,omitempty"`
+	DownloadExpiresAt *time.Time `json:"downloadExpiresAt,omitempty"`
+	filePath          string
+}
+
+type ExportHandler struct {
+	db          *sql.DB
+	queue       *jobs.Queue
+	mailer      *notify.Mailer
+	auditLogger *audit.Logger
+	exportDir   string
+}
+
+func NewExportHandler(db *sql.DB, queue *jobs.Queue, mailer *notify.Mailer, auditLogger *audit.Logger) *ExportHandler {
+	exportDir := "./exports"
+	os.MkdirAll(exportDir, 0700)
+	return &ExportHandler{
+		db:          db,
+		queue:       queue,
+		mailer:      mailer,
+		auditLogger: auditLogger,
+		exportDir:   exportDir,
+	}
+}
+
+// ExportData returns the user's personal data archive. If no current archive
+// exists one is generated in the background and the user is emailed when it
+// is ready; until then the endpoint answers 202 with the export status.
+func (h *ExportHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	export, err := h.latestExport(userID)
+	if err != nil && err != sql.ErrNoRows {
+		apierror.Error(w, "Error fetching export", http.StatusInternalServerError)
+		return
+	}
+
+	if err == nil && export.Status == "ready" && export.ExpiresAt != nil && time.Now().Before(*export.ExpiresAt) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"saferelief-data-export.zip\"")
+		http.ServeFile(w, r, export.filePath)
+		return
+	}
+
+	if err == nil && export.Status == "pending" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(export)
+		return
+	}
+
+	export, err = h.startExport(r, userID)
+	if err == jobs.ErrQueueFull {
+		apierror.Error(w, "Export service is busy, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error starting export", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(export)
+}
+
+// RequestExport starts building a new archive of the user's data. While one
+// is still being built it is returned instead of starting another.
+func (h *ExportHandler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	export, err := h.latestExport(userID)
+	if err != nil && err != sql.ErrNoRows {
+		apierror.Error(w, "Error fetching export", http.StatusInternalServerError)
+		return
+	}
+
+	if err != nil || export.Status != "pending" {
+		export, err = h.startExport(r, userID)
+		if err == jobs.ErrQueueFull {
+			apierror.Error(w, "Export service is busy, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			apierror.Error(w, "Error starting export", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(export)
+}
+
+// ExportStatus reports on the user's latest export for polling. Once it is
+// ready the response carries a short-lived download link.
+func (h *ExportHandler) ExportStatus(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	export, err := h.latestExport(userID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "No data export has been requested", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching export", http.StatusInternalServerError)
+		return
+	}
+
+	if export.Status == "ready" {
+		if export.ExpiresAt == nil || !time.Now().Before(*export.ExpiresAt) {
+			export.Status = "expired"
+		} else if err := h.createDownloadLink(&export); err != nil {
+			apierror.Error(w, "Error creating download link", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(export)
+}
+
+// DownloadExport serves an archive to whoever holds a valid download link,
+// so the link can be opened in a browser or download manager without the
+// session cookie
+func (h *ExportHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		apierror.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	var exportID, userID, filePath string
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(e.id), BIN_TO_UUID(e.user_id), e.file_path
+		FROM data_export_links l JOIN data_exports e ON e.id = l.export_id
+		WHERE l.token_hash = ? AND l.expires_at > NOW()
+		AND e.status = 'ready' AND e.expires_at > NOW()`,
+		tokens.Hash(token),
+	).Scan(&exportID, &userID, &filePath)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Download link is invalid or has expired", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching export", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDataExportDownloaded,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "data_export",
+		EntityID:   exportID,
+	})
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"saferelief-data-export.zip\"")
+	http.ServeFile(w, r, filePath)
+}
+
+// createDownloadLink issues a link for a ready export, never outliving the
+// archive itself, and clears the export's links that have lapsed
+func (h *ExportHandler) createDownloadLink(export *DataExport) error {
+	token, hash, err := tokens.Generate()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(dataExportLinkTTL)
+	if export.ExpiresAt.Before(expiresAt) {
+		expiresAt = *export.ExpiresAt
+	}
+
+	if _, err := h.db.Exec(
+		"DELETE FROM data_export_links WHERE export_id = UUID_TO_BIN(?) AND expires_at <= NOW()",
+		export.ID,
+	); err != nil {
+		return err
+	}
+	if _, err := h.db.Exec(
+		`INSERT INTO data_export_links (token_hash, export_id, expires_at)
+		VALUES (?, UUID_TO_BIN(?), ?)`,
+		hash, export.ID, expiresAt,
+	); err != nil {
+		return err
+	}
+
+	export.DownloadURL = "/api/exports/download?token=" + token
+	export.DownloadExpiresAt = &expiresAt
+	return nil
+}
+
+func (h *ExportHandler) latestExport(userID string) (DataExport, error) {
+	var export DataExport
+	var filePath sql.NullString
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(id), status, file_path, created_at, completed_at, expires_at
+		FROM data_exports WHERE user_id = UUID_TO_BIN(?)
+		ORDER BY created_at DESC LIMIT 1`,
+		userID,
+	).Scan(&export.ID, &export.Status, &filePath, &export.CreatedAt, &export.CompletedAt, &export.ExpiresAt)
+	export.filePath = filePath.String
+	return export, err
+}
+
+func (h *ExportHandler) startExport(r *http.Request, userID string) (DataExport, error) {
+	var export DataExport
+	err := h.db.QueryRow(
+		`INSERT INTO data_exports (id, user_id, status)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), 'pending')
+		RETURNING BIN_TO_UUID(id), status, created_at`,
+		userID,
+	).Scan(&export.ID, &export.Status, &export.CreatedAt)
+	if err != nil {
+		return export, err
+	}
+
+	err = h.queue.Enqueue(jobs.Job{
+		Name:        "data_export:" + export.ID,
+		MaxAttempts: 3,
+		Run: func(ctx context.Context) error {
+			return h.buildExport(ctx, export.ID, userID)
+		},
+		OnFailure: func(err error) {
+			h.db.Exec("UPDATE data_exports SET status = 'failed' WHERE id = UUID_TO_BIN(?)", export.ID)
+		},
+	})
+	if err != nil {
+		h.db.Exec("UPDATE data_exports SET status = 'failed' WHERE id = UUID_TO_BIN(?)", export.ID)
+		return export, err
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventDataExportRequested,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "data_export",
+		EntityID:   export.ID,
+	})
+
+	return export, nil
+}
+
+func (h *ExportHandler) buildExport(ctx context.Context, exportID, userID string) error {
+	sections := []struct {
+		name  string
+		query string
+	}{
+		{"profile.json", `SELECT JSON_OBJECT(
+			'id', BIN_TO_UUID(id), 'username', username, 'email', email, 'avatarUrl', avatar_url,
+			'displayName', display_name, 'phone', phone, 'bio', bio,
+			'role', role, 'mfaEnabled', mfa_enabled, 'locale', locale, 'timezone', timezone, 'createdAt', created_at, 'updatedAt', updated_at)
+			FROM users WHERE id = UUID_TO_BIN(?)`},
+		{"reports.json", `SELECT JSON_OBJECT(
+			'id', BIN_TO_UUID(id), 'title', title, 'description', description,
+			'latitude', latitude, 'longitude', longitude, 'severity', severity, 'status', status,
+			'createdAt', created_at, 'updatedAt', updated_at)
+			FROM disaster_reports WHERE reporter_id = UUID_TO_BIN(?) ORDER BY created_at`},
+		{"donations.json", `SELECT JSON_OBJECT(
+			'id', BIN_TO_UUID(id), 'disasterReportId', BIN_TO_UUID(disaster_report_id),
+			'amount', amount, 'currency', currency, 'description', description, 'status', status,
+			'transactionId', transaction_id, 'paymentMethod', payment_method,
+			'createdAt', created_at, 'updatedAt', updated_at)
+			FROM donations WHERE donor_id = UUID_TO_BIN(?) ORDER BY created_at`},
+		{"uploads.json", `SELECT JSON_OBJECT(
+			'id', id, 'filename', filename, 'originalName', original_name,
+			'size', size, 'mimeType', mime_type, 'createdAt', created_at)
+			FROM uploads WHERE user_id = ? ORDER BY created_at`},
+		{"report_files.json", `SELECT JSON_OBJECT(
+			'id', BIN_TO_UUID(id), 'disasterReportId', BIN_TO_UUID(disaster_report_id),
+			'originalFilename', original_filename, 'fileSize', file_size, 'mimeType', mime_type,
+			'fileHash', file_hash, 'createdAt', created_at)
+			FROM file_uploads WHERE user_id = UUID_TO_BIN(?) ORDER BY created_at`},
+		{"report_comments.json", `SELECT JSON_OBJECT(
+			'id', BIN_TO_UUID(id), 'reportId', BIN_TO_UUID(report_id), 'body', body,
+			'editedAt', edited_at, 'deletedAt', deleted_at, 'createdAt', created_at)
+			FROM report_comments WHERE author_id = UUID_TO_BIN(?) ORDER BY created_at`},
+		{"consents.json", `SELECT JSON_OBJECT(
+			'document', document, 'version', version, 'ipAddress', ip_address, 'consentedAt', consented_at)
+			FROM user_consents WHERE user_id = UUID_TO_BIN(?) ORDER BY consented_at`},
+		{"notification_preferences.json", `SELECT JSON_OBJECT(
+			'eventType', event_type, 'channel', channel, 'enabled', enabled IS TRUE, 'updatedAt', updated_at)
+			FROM notification_preferences WHERE user_id = UUID_TO_BIN(?) ORDER BY event_type, channel`},
+		{"audit_trail.json", `SELECT JSON_OBJECT(
+			'action', action, 'severity', severity, 'entityType', entity_type,
+			'ipAddress', ip_address, 'userAgent', user_agent, 'details', details, 'createdAt', created_at)
+			FROM audit_logs WHERE user_id = UUID_TO_BIN(?) ORDER BY created_at`},
+	}
+
+	filePath := filepath.Join(h.exportDir, exportID+".zip")
+	out, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(out)
+	for _, section := range sections {
+		rows, err := h.db.QueryContext(ctx, section.query, userID)
+		if err != nil {
+			out.Close()
+			return err
+		}
+
+		var records []json.RawMessage
+		for rows.Next() {
+			var record []byte
+			if err := rows.Scan(&record); err != nil {
+				rows.Close()
+				out.Close()
+				return err
+			}
+			records = append(records, json.RawMessage(record))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			out.Close()
+			return err
+		}
+
+		entry, err := archive.Create(section.name)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		if records == nil {
+			records = []json.RawMessage{}
+		}
+		if section.name == "profile.json" && len(records) == 1 {
+			entry.Write(records[0])
+			continue
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(records); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(dataExportTTL)
+	_, err = h.db.ExecContext(ctx,
+		`UPDATE data_exports SET status = 'ready', file_path = ?, completed_at = NOW(), expires_at = ?
+		WHERE id = UUID_TO_BIN(?)`,
+		filePath, expiresAt, exportID,
+	)
+	if err != nil {
+		return err
+	}
+
+	to, err := notify.LookupRecipient(ctx, h.db, userID)
+	if err != nil {
+		return nil
+	}
+	if err := h.mailer.SendTemplate(ctx, to, "data_export.ready",
+		map[string]interface{}{"ExpiresAt": expiresAt}); err != nil {
+		log.Printf("Failed to send export notification for %s: %v", exportID, err)
+	}
+
+	return nil
+}
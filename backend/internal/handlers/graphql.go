@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/graphql"
+	"saferelief/internal/middleware"
+)
+
+const maxGraphQLBody = 64 << 10
+
+type gqlReport struct {
+	ID          string
+	ReporterID  string
+	Title       string
+	Description string
+	Latitude    float64
+	Longitude   float64
+	Severity    string
+	Status      string
+	VerifiedAt  *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type gqlUser struct {
+	ID       string
+	Username string
+}
+
+type gqlFile struct {
+	ID        string
+	Filename  string
+	MimeType  string
+	Size      int64
+	Status    string
+	CreatedAt time.Time
+}
+
+type gqlDonationSummary struct {
+	Donations int
+	Completed int
+	Donors    int
+	Totals    []interface{}
+	TotalIDR  float64
+	TotalUSD  float64
+}
+
+type gqlCurrencyTotal struct {
+	Currency string
+	Amount   float64
+}
+
+type gqlDonation struct {
+	ID            string
+	Amount        float64
+	Currency      string
+	Status        string
+	PaymentMethod *string
+	CreatedAt     time.Time
+	CompletedAt   *time.Time
+}
+
+type gqlAssignment struct {
+	ID          string
+	VolunteerID string
+	Status      string
+	Message     *string
+	CreatedAt   time.Time
+	RespondedAt *time.Time
+}
+
+// GraphQLHandler serves the coordination dashboard's read model. Every
+// nested field loads for all of its parents in one query, so a page of
+// reports with files, donations and assignments costs a query per field.
+type GraphQLHandler struct {
+	db     *sql.DB
+	schema *graphql.Schema
+}
+
+func NewGraphQLHandler(db *sql.DB) *GraphQLHandler {
+	h := &GraphQLHandler{db: db}
+	h.schema = h.buildSchema()
+	return h
+}
+
+// Query executes a GraphQL query for the signed-in user
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var req graphql.Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxGraphQLBody)).Decode(&req); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		apierror.Error(w, "query is required", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.LookupRole(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := h.schema.Execute(r.Context(), req, func(permission string) bool {
+		return role.Can(middleware.Permission(permission))
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Schema lists the types and fields that can be queried
+func (h *GraphQLHandler) Schema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"types":         h.schema.Describe(),
+		"maxDepth":      h.schema.MaxDepth,
+		"maxComplexity": h.schema.MaxComplexity,
+	})
+}
+
+func (h *GraphQLHandler) buildSchema() *graphql.Schema {
+	user := &graphql.Object{Name: "User", Fields: map[string]*graphql.Field{
+		"id":       {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlUser).ID })},
+		"username": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlUser).Username })},
+	}}
+
+	file := &graphql.Object{Name: "File", Fields: map[string]*graphql.Field{
+		"id":        {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlFile).ID })},
+		"filename":  {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlFile).Filename })},
+		"mimeType":  {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlFile).MimeType })},
+		"size":      {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlFile).Size })},
+		"status":    {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlFile).Status })},
+		"createdAt": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlFile).CreatedAt })},
+	}}
+
+	currencyTotal := &graphql.Object{Name: "CurrencyTotal", Fields: map[string]*graphql.Field{
+		"currency": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlCurrencyTotal).Currency })},
+		"amount":   {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlCurrencyTotal).Amount })},
+	}}
+
+	donationSummary := &graphql.Object{Name: "DonationSummary", Fields: map[string]*graphql.Field{
+		"donations": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonationSummary).Donations })},
+		"completed": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonationSummary).Completed })},
+		"donors":    {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonationSummary).Donors })},
+		"totals": {
+			Type: currencyTotal, List: true, Description: "Completed amounts per currency",
+			Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonationSummary).Totals }),
+		},
+		"totalIdr": {
+			Description: "Completed amounts in all currencies, converted to IDR at each donation's rate",
+			Resolve:     graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonationSummary).TotalIDR }),
+		},
+		"totalUsd": {
+			Description: "Completed amounts in all currencies, converted to USD at each donation's rate",
+			Resolve:     graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonationSummary).TotalUSD }),
+		},
+	}}
+
+	donation := &graphql.Object{Name: "Donation", Fields: map[string]*graphql.Field{
+		"id":            {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonation).ID })},
+		"amount":        {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonation).Amount })},
+		"currency":      {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonation).Currency })},
+		"status":        {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonation).Status })},
+		"paymentMethod": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonation).PaymentMethod })},
+		"createdAt":     {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonation).CreatedAt })},
+		"completedAt":   {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlDonation).CompletedAt })},
+	}}
+
+	assignment := &graphql.Object{Name: "Assignment", Fields: map[string]*graphql.Field{
+		"id":          {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlAssignment).ID })},
+		"status":      {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlAssignment).Status })},
+		"message":     {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlAssignment).Message })},
+		"createdAt":   {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlAssignment).CreatedAt })},
+		"respondedAt": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlAssignment).RespondedAt })},
+		"volunteer": {Type: user, Cost: 2, Resolve: func(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+			ids := make([]string, len(parents))
+			for i, p := range parents {
+				ids[i] = p.(*gqlAssignment).VolunteerID
+			}
+			return h.loadUsers(ctx, ids)
+		}},
+	}}
+
+	report := &graphql.Object{Name: "Report", Fields: map[string]*graphql.Field{
+		"id":          {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).ID })},
+		"title":       {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).Title })},
+		"description": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).Description })},
+		"latitude":    {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).Latitude })},
+		"longitude":   {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).Longitude })},
+		"severity":    {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).Severity })},
+		"status":      {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).Status })},
+		"verifiedAt":  {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).VerifiedAt })},
+		"createdAt":   {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).CreatedAt })},
+		"updatedAt":   {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(*gqlReport).UpdatedAt })},
+		"reporter": {Type: user, Cost: 2, Resolve: func(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+			ids := make([]string, len(parents))
+			for i, p := range parents {
+				ids[i] = p.(*gqlReport).ReporterID
+			}
+			return h.loadUsers(ctx, ids)
+		}},
+		"files":           {Type: file, List: true, Cost: 2, Resolve: h.resolveFiles},
+		"donationSummary": {Type: donationSummary, Cost: 2, Resolve: h.resolveDonationSummaries},
+		"donations": {
+			Type: donation, List: true, Cost: 2, Permission: string(middleware.PermManageDonations),
+			Description: "Latest donations, newest first (limit, default 20, at most 100)",
+			Resolve:     h.resolveDonations,
+		},
+		"assignments": {
+			Type: assignment, List: true, Cost: 2, Permission: string(middleware.PermCoordinateVolunteers),
+			Description: "Volunteer requests for the report",
+			Resolve:     h.resolveAssignments,
+		},
+	}}
+
+	viewer := &graphql.Object{Name: "Viewer", Fields: map[string]*graphql.Field{
+		"id":       {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(map[string]string)["id"] })},
+		"username": {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(map[string]string)["username"] })},
+		"role":     {Resolve: graphql.Prop(func(p interface{}) interface{} { return p.(map[string]string)["role"] })},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"me": {Type: viewer, Resolve: h.resolveMe},
+		"report": {
+			Type: report, Description: "A report by id",
+			Resolve: h.resolveReport,
+		},
+		"reports": {
+			Type: report, List: true,
+			Description: "Reports, newest first (status, severity, limit up to 50, offset)",
+			Resolve:     h.resolveReports,
+		},
+	}}
+
+	return &graphql.Schema{
+		Query:         query,
+		MaxDepth:      graphql.DefaultMaxDepth,
+		MaxComplexity: graphql.DefaultMaxComplexity,
+	}
+}
+
+func (h *GraphQLHandler) resolveMe(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+	userID, _ := ctx.Value("user_id").(string)
+	var username, role string
+	err := h.db.QueryRowContext(ctx,
+		"SELECT username, role FROM users WHERE id = UUID_TO_BIN(?)", userID,
+	).Scan(&username, &role)
+	if err != nil {
+		return nil, errors.New("error loading the current user")
+	}
+	return []interface{}{map[string]string{"id": userID, "username": username, "role": role}}, nil
+}
+
+const gqlReportColumns = `BIN_TO_UUID(id), BIN_TO_UUID(reporter_id), title, description,
+	latitude, longitude, severity, status, verified_at, created_at, updated_at`
+
+func scanGQLReport(scan func(...interface{}) error) (*gqlReport, error) {
+	var r gqlReport
+	err := scan(&r.ID, &r.ReporterID, &r.Title, &r.Description, &r.Latitude, &r.Longitude,
+		&r.Severity, &r.Status, &r.VerifiedAt, &r.CreatedAt, &r.UpdatedAt)
+	return &r, err
+}
+
+func (h *GraphQLHandler) resolveReport(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+	id, ok := args.String("id")
+	if !ok {
+		return nil, errors.New("id is required")
+	}
+	var orgID sql.NullString
+	report, err := scanGQLReport(func(dest ...interface{}) error {
+		return h.db.QueryRowContext(ctx,
+			"SELECT "+gqlReportColumns+", BIN_TO_UUID(organization_id) FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL AND hidden_at IS NULL", id,
+		).Scan(append(dest, &orgID)...)
+	})
+	if err == sql.ErrNoRows {
+		return []interface{}{nil}, nil
+	}
+	if err != nil {
+		return nil, errors.New("error loading report")
+	}
+	// Rejected reports stay with the people GET /reports/{id} shows them to
+	userID, _ := ctx.Value("user_id").(string)
+	if ReportStatus(report.Status) == ReportRejected && !canSeeRejectedReport(h.db, userID, report.ReporterID, orgID.String) {
+		return []interface{}{nil}, nil
+	}
+	return []interface{}{report}, nil
+}
+
+func (h *GraphQLHandler) resolveReports(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+	limit, offset := 20, 0
+	if l, ok := args.Int("limit"); ok && l > 0 && l <= 50 {
+		limit = l
+	}
+	if o, ok := args.Int("offset"); ok && o > 0 {
+		offset = o
+	}
+
+	query := "SELECT " + gqlReportColumns + " FROM disaster_reports WHERE deleted_at IS NULL AND hidden_at IS NULL"
+	queryArgs := []interface{}{}
+	if status, ok := args.String("status"); ok {
+		// As for GET /reports, only verifiers may list rejected reports
+		if ReportStatus(status) == ReportRejected {
+			userID, _ := ctx.Value("user_id").(string)
+			role, err := middleware.LookupRole(h.db, userID)
+			if err != nil || !role.Can(middleware.PermVerifyReports) {
+				return nil, errors.New("listing rejected reports requires " + string(middleware.PermVerifyReports))
+			}
+		}
+		query += " AND status = ?"
+		queryArgs = append(queryArgs, status)
+	} else {
+		query += " AND status != 'rejected'"
+	}
+	if severity, ok := args.String("severity"); ok {
+		query += " AND severity = ?"
+		queryArgs = append(queryArgs, severity)
+	}
+	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	queryArgs = append(queryArgs, limit, offset)
+
+	rows, err := h.db.QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, errors.New("error loading reports")
+	}
+	defer rows.Close()
+
+	reports := []interface{}{}
+	for rows.Next() {
+		report, err := scanGQLReport(rows.Scan)
+		if err != nil {
+			return nil, errors.New("error loading reports")
+		}
+		reports = append(reports, report)
+	}
+	return []interface{}{reports}, nil
+}
+
+func reportIDs(parents []interface{}) []string {
+	ids := make([]string, len(parents))
+	for i, p := range parents {
+		ids[i] = p.(*gqlReport).ID
+	}
+	return ids
+}
+
+// uuidIn expands ids into an IN list of UUID_TO_BIN placeholders
+func uuidIn(ids []string) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "(UUID_TO_BIN(?)" + strings.Repeat(", UUID_TO_BIN(?)", len(ids)-1) + ")", args
+}
+
+// groupByParent returns each parent's list, empty rather than null when a
+// parent has no rows
+func groupByParent(ids []string, grouped map[string][]interface{}) []interface{} {
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		if list := grouped[id]; list != nil {
+			values[i] = list
+		} else {
+			values[i] = []interface{}{}
+		}
+	}
+	return values
+}
+
+func (h *GraphQLHandler) loadUsers(ctx context.Context, ids []string) ([]interface{}, error) {
+	in, args := uuidIn(ids)
+	rows, err := h.db.QueryContext(ctx,
+		"SELECT BIN_TO_UUID(id), username FROM users WHERE id IN "+in, args...,
+	)
+	if err != nil {
+		return nil, errors.New("error loading users")
+	}
+	defer rows.Close()
+
+	users := make(map[string]*gqlUser)
+	for rows.Next() {
+		var u gqlUser
+		if err := rows.Scan(&u.ID, &u.Username); err != nil {
+			return nil, errors.New("error loading users")
+		}
+		users[u.ID] = &u
+	}
+
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		if u, ok := users[id]; ok {
+			values[i] = u
+		}
+	}
+	return values, nil
+}
+
+func (h *GraphQLHandler) resolveFiles(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+	ids := reportIDs(parents)
+	in, queryArgs := uuidIn(ids)
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT BIN_TO_UUID(disaster_report_id), BIN_TO_UUID(id), original_filename,
+			mime_type, file_size, status, created_at
+		FROM file_uploads WHERE disaster_report_id IN `+in+` ORDER BY created_at`,
+		queryArgs...,
+	)
+	if err != nil {
+		return nil, errors.New("error loading files")
+	}
+	defer rows.Close()
+
+	grouped := make(map[string][]interface{})
+	for rows.Next() {
+		var reportID string
+		var f gqlFile
+		if err := rows.Scan(&reportID, &f.ID, &f.Filename, &f.MimeType, &f.Size, &f.Status, &f.CreatedAt); err != nil {
+			return nil, errors.New("error loading files")
+		}
+		grouped[reportID] = append(grouped[reportID], &f)
+	}
+	return groupByParent(ids, grouped), nil
+}
+
+func (h *GraphQLHandler) resolveDonationSummaries(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+	ids := reportIDs(parents)
+	in, queryArgs := uuidIn(ids)
+	summaries := make(map[string]*gqlDonationSummary, len(ids))
+	for _, id := range ids {
+		summaries[id] = &gqlDonationSummary{Totals: []interface{}{}}
+	}
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT BIN_TO_UUID(disaster_report_id), COUNT(*),
+			SUM(status = 'completed'), COUNT(DISTINCT donor_id),
+			COALESCE(SUM(IF(status = 'completed', amount_idr, 0)), 0),
+			COALESCE(SUM(IF(status = 'completed', amount_usd, 0)), 0)
+		FROM donations WHERE disaster_report_id IN `+in+`
+		GROUP BY disaster_report_id`,
+		queryArgs...,
+	)
+	if err != nil {
+		return nil, errors.New("error loading donation summaries")
+	}
+	for rows.Next() {
+		var id string
+		var s gqlDonationSummary
+		if err := rows.Scan(&id, &s.Donations, &s.Completed, &s.Donors, &s.TotalIDR, &s.TotalUSD); err != nil {
+			rows.Close()
+			return nil, errors.New("error loading donation summaries")
+		}
+		summaries[id].Donations, summaries[id].Completed, summaries[id].Donors = s.Donations, s.Completed, s.Donors
+		summaries[id].TotalIDR, summaries[id].TotalUSD = s.TotalIDR, s.TotalUSD
+	}
+	rows.Close()
+
+	rows, err = h.db.QueryContext(ctx,
+		`SELECT BIN_TO_UUID(disaster_report_id), currency, SUM(amount)
+		FROM donations WHERE status = 'completed' AND disaster_report_id IN `+in+`
+		GROUP BY disaster_report_id, currency ORDER BY currency`,
+		queryArgs...,
+	)
+	if err != nil {
+		return nil, errors.New("error loading donation summaries")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var t gqlCurrencyTotal
+		if err := rows.Scan(&id, &t.Currency, &t.Amount); err != nil {
+			return nil, errors.New("error loading donation summaries")
+		}
+		summaries[id].Totals = append(summaries[id].Totals, &t)
+	}
+
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = summaries[id]
+	}
+	return values, nil
+}
+
+func (h *GraphQLHandler) resolveDonations(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+	limit := 20
+	if l, ok := args.Int("limit"); ok && l > 0 && l <= 100 {
+		limit = l
+	}
+	ids := reportIDs(parents)
+	in, queryArgs := uuidIn(ids)
+	// Numbered per report so the limit applies to each report, not the batch
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT report_id, id, amount, currency, status, payment_method, created_at, completed_at
+		FROM (
+			SELECT BIN_TO_UUID(disaster_report_id) AS report_id, BIN_TO_UUID(id) AS id, amount,
+				currency, status, payment_method, created_at, completed_at,
+				ROW_NUMBER() OVER (PARTITION BY disaster_report_id ORDER BY created_at DESC) AS n
+			FROM donations WHERE disaster_report_id IN `+in+`
+		) d WHERE n <= ?`,
+		append(queryArgs, limit)...,
+	)
+	if err != nil {
+		return nil, errors.New("error loading donations")
+	}
+	defer rows.Close()
+
+	grouped := make(map[string][]interface{})
+	for rows.Next() {
+		var reportID string
+		var d gqlDonation
+		if err := rows.Scan(&reportID, &d.ID, &d.Amount, &d.Currency, &d.Status,
+			&d.PaymentMethod, &d.CreatedAt, &d.CompletedAt); err != nil {
+			return nil, errors.New("error loading donations")
+		}
+		grouped[reportID] = append(grouped[reportID], &d)
+	}
+	return groupByParent(ids, grouped), nil
+}
+
+func (h *GraphQLHandler) resolveAssignments(ctx context.Context, parents []interface{}, args graphql.Args) ([]interface{}, error) {
+	ids := reportIDs(parents)
+	in, queryArgs := uuidIn(ids)
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT BIN_TO_UUID(disaster_report_id), BIN_TO_UUID(id), BIN_TO_UUID(volunteer_id),
+			status, message, created_at, responded_at
+		FROM volunteer_requests WHERE disaster_report_id IN `+in+` ORDER BY created_at`,
+		queryArgs...,
+	)
+	if err != nil {
+		return nil, errors.New("error loading assignments")
+	}
+	defer rows.Close()
+
+	grouped := make(map[string][]interface{})
+	for rows.Next() {
+		var reportID string
+		var a gqlAssignment
+		if err := rows.Scan(&reportID, &a.ID, &a.VolunteerID, &a.Status, &a.Message,
+			&a.CreatedAt, &a.RespondedAt); err != nil {
+			return nil, errors.New("error loading assignments")
+		}
+		grouped[reportID] = append(grouped[reportID], &a)
+	}
+	return groupByParent(ids, grouped), nil
+}
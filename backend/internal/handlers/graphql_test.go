@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"saferelief/internal/fakedb"
+	"saferelief/internal/graphql"
+)
+
+const (
+	gqlTestReporter = "11111111-1111-1111-1111-111111111111"
+	gqlTestStranger = "22222222-2222-2222-2222-222222222222"
+	gqlTestOwner    = "33333333-3333-3333-3333-333333333333"
+)
+
+// rejectedReportDB serves one rejected report filed through an organization
+// the stranger has nothing to do with
+func rejectedReportDB(t *testing.T, role string) (*sql.DB, *fakedb.DB) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return fakedb.New(t,
+		fakedb.Rule{Match: "SELECT role FROM users", Rows: [][]driver.Value{{role}}},
+		fakedb.Rule{Match: "FROM disaster_reports WHERE id", Rows: [][]driver.Value{{
+			"44444444-4444-4444-4444-444444444444", gqlTestReporter, "Flood", "Water rising",
+			-6.2, 106.8, "high", "rejected", nil, created, created,
+			"55555555-5555-5555-5555-555555555555",
+		}}},
+		fakedb.Rule{Match: "FROM organizations o", Rows: [][]driver.Value{{gqlTestOwner, nil, nil}}},
+		fakedb.Rule{Match: "FROM disaster_reports WHERE deleted_at", Rows: nil},
+	)
+}
+
+func queryGraphQL(t *testing.T, db *sql.DB, userID, query string) graphql.Response {
+	t.Helper()
+	h := NewGraphQLHandler(db)
+	body, _ := json.Marshal(graphql.Request{Query: query})
+	r := httptest.NewRequest("POST", "/api/graphql", bytes.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), "user_id", userID))
+	w := httptest.NewRecorder()
+	h.Query(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp graphql.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp
+}
+
+func TestGraphQLRejectedReportHiddenFromStrangers(t *testing.T) {
+	db, _ := rejectedReportDB(t, "donor")
+	resp := queryGraphQL(t, db, gqlTestStranger, `{ report(id: "44444444-4444-4444-4444-444444444444") { id title } }`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", resp.Errors)
+	}
+	if data := resp.Data.(map[string]interface{}); data["report"] != nil {
+		t.Errorf("report = %v, want null for an unrelated user", data["report"])
+	}
+}
+
+func TestGraphQLRejectedReportShownToReporter(t *testing.T) {
+	db, _ := rejectedReportDB(t, "donor")
+	resp := queryGraphQL(t, db, gqlTestReporter, `{ report(id: "44444444-4444-4444-4444-444444444444") { title } }`)
+	report, _ := resp.Data.(map[string]interface{})["report"].(map[string]interface{})
+	if len(resp.Errors) > 0 || report["title"] != "Flood" {
+		t.Errorf("got %v with errors %v, want the report", resp.Data, resp.Errors)
+	}
+}
+
+func TestGraphQLRejectedReportShownToVerifiers(t *testing.T) {
+	db, _ := rejectedReportDB(t, "verifier")
+	resp := queryGraphQL(t, db, gqlTestStranger, `{ report(id: "44444444-4444-4444-4444-444444444444") { title } }`)
+	if report := resp.Data.(map[string]interface{})["report"]; report == nil {
+		t.Errorf("report = null with errors %v, want it shown to a verifier", resp.Errors)
+	}
+}
+
+func TestGraphQLListingRejectedReportsNeedsVerifier(t *testing.T) {
+	db, f := rejectedReportDB(t, "donor")
+	resp := queryGraphQL(t, db, gqlTestStranger, `{ reports(status: "rejected") { id } }`)
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "requires reports:verify") {
+		t.Errorf("errors = %v, want the reports:verify requirement", resp.Errors)
+	}
+	if data := resp.Data.(map[string]interface{}); data["reports"] != nil {
+		t.Errorf("reports = %v, want null", data["reports"])
+	}
+	if f.Ran("FROM disaster_reports") {
+		t.Error("rejected reports were queried for a donor")
+	}
+
+	db, f = rejectedReportDB(t, "verifier")
+	resp = queryGraphQL(t, db, gqlTestStranger, `{ reports(status: "rejected") { id } }`)
+	if len(resp.Errors) > 0 || !f.Ran("FROM disaster_reports WHERE deleted_at") {
+		t.Errorf("verifier listing failed: %v", resp.Errors)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/inbound"
+
+	"github.com/gorilla/mux"
+)
+
+type InboundEvent struct {
+	ID          string            `json:"id"`
+	Provider    string            `json:"provider"`
+	EventID     string            `json:"eventId"`
+	Status      string            `json:"status"`
+	Attempts    int               `json:"attempts"`
+	LastError   *string           `json:"lastError"`
+	ReceivedAt  time.Time         `json:"receivedAt"`
+	ProcessedAt *time.Time        `json:"processedAt"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Payload     json.RawMessage   `json:"payload,omitempty"`
+}
+
+type InboundWebhookHandler struct {
+	db          *sql.DB
+	receiver    *inbound.Receiver
+	auditLogger *audit.Logger
+}
+
+func NewInboundWebhookHandler(db *sql.DB, receiver *inbound.Receiver, auditLogger *audit.Logger) *InboundWebhookHandler {
+	return &InboundWebhookHandler{
+		db:          db,
+		receiver:    receiver,
+		auditLogger: auditLogger,
+	}
+}
+
+// ListEvents returns archived callbacks, newest first, optionally filtered
+// by ?provider= and ?status=
+func (h *InboundWebhookHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l < limit {
+		limit = l
+	}
+
+	query := `SELECT BIN_TO_UUID(id), provider, event_id, status, attempts, last_error,
+		received_at, processed_at
+		FROM inbound_webhook_events WHERE 1 = 1`
+	args := []interface{}{}
+	if provider := r.URL.Query().Get("provider"); provider != "" {
+		query += " AND provider = ?"
+		args = append(args, provider)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY received_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		apierror.Error(w, "Error fetching inbound events", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	events := []InboundEvent{}
+	for rows.Next() {
+		var e InboundEvent
+		if err := rows.Scan(&e.ID, &e.Provider, &e.EventID, &e.Status, &e.Attempts,
+			&e.LastError, &e.ReceivedAt, &e.ProcessedAt); err != nil {
+			apierror.Error(w, "Error processing inbound events", http.StatusInternalServerError)
+			return
+		}
+		events = append(events, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers": h.receiver.Providers(),
+		"events":    events,
+	})
+}
+
+// GetEvent returns one archived callback including its raw payload
+func (h *InboundWebhookHandler) GetEvent(w http.ResponseWriter, r *http.Request) {
+	var e InboundEvent
+	var headers, payload []byte
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(id), provider, event_id, status, attempts, last_error,
+			received_at, processed_at, headers, payload
+		FROM inbound_webhook_events WHERE id = UUID_TO_BIN(?)`,
+		mux.Vars(r)["id"],
+	).Scan(&e.ID, &e.Provider, &e.EventID, &e.Status, &e.Attempts, &e.LastError,
+		&e.ReceivedAt, &e.ProcessedAt, &headers, &payload)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Inbound event not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching inbound event", http.StatusInternalServerError)
+		return
+	}
+	json.Unmarshal(headers, &e.Headers)
+	// Every provider so far posts JSON; anything else is returned as a string
+	if json.Valid(payload) {
+		e.Payload = payload
+	} else {
+		e.Payload, _ = json.Marshal(string(payload))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+// ReplayEvent queues an archived callback to be processed again
+func (h *InboundWebhookHandler) ReplayEvent(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("user_id").(string)
+	id := mux.Vars(r)["id"]
+
+	err := h.receiver.Replay(r.Context(), id)
+	if err == inbound.ErrNotFound {
+		apierror.Error(w, "Inbound event not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error replaying inbound event", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventInboundWebhookReplayed,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "inbound_webhook_event",
+		EntityID:   id,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Replay queued"})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/validation"
+)
+
+// UpdateLocation stores the user's last known position so emergency
+// broadcasts for that area reach them
+func (h *UserHandler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var location struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&location); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	v.Check(location.Latitude >= -90 && location.Latitude <= 90, "latitude", "must be between -90 and 90")
+	v.Check(location.Longitude >= -180 && location.Longitude <= 180, "longitude", "must be between -180 and 180")
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	_, err := h.db.Exec(
+		`INSERT INTO user_locations (user_id, latitude, longitude) VALUES (UUID_TO_BIN(?), ?, ?)
+		ON DUPLICATE KEY UPDATE latitude = VALUES(latitude), longitude = VALUES(longitude), updated_at = NOW()`,
+		userID, location.Latitude, location.Longitude,
+	)
+	if err != nil {
+		apierror.Error(w, "Error saving location", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Location updated"})
+}
+
+func (h *UserHandler) ClearLocation(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	if _, err := h.db.Exec("DELETE FROM user_locations WHERE user_id = UUID_TO_BIN(?)", userID); err != nil {
+		apierror.Error(w, "Error clearing location", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Location cleared"})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+)
+
+type LoginHistoryItem struct {
+	ID        string    `json:"id"`
+	Outcome   string    `json:"outcome"`
+	Method    string    `json:"method"`
+	Reason    string    `json:"reason,omitempty"`
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GetLoginHistory lists the user's recent sign-in attempts from the audit log,
+// newest first. Failures against an unknown email carry no user and so never
+// show up here.
+func (h *UserHandler) GetLoginHistory(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	limit := defaultActivityLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxActivityLimit {
+		limit = maxActivityLimit
+	}
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), action, ip_address, user_agent,
+		JSON_UNQUOTE(JSON_EXTRACT(details, '$.method')),
+		JSON_UNQUOTE(JSON_EXTRACT(details, '$.reason')),
+		created_at
+		FROM audit_logs
+		WHERE user_id = UUID_TO_BIN(?) AND action IN (?, ?)
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`,
+		userID, audit.EventLoginSuccess, audit.EventLoginFailed, limit+1, offset,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching login history", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []LoginHistoryItem{}
+	for rows.Next() {
+		var item LoginHistoryItem
+		var action string
+		var userAgent, method, reason sql.NullString
+		if err := rows.Scan(
+			&item.ID, &action, &item.IPAddress, &userAgent, &method, &reason, &item.CreatedAt,
+		); err != nil {
+			apierror.Error(w, "Error processing login history", http.StatusInternalServerError)
+			return
+		}
+		item.Outcome = "success"
+		if action == audit.EventLoginFailed {
+			item.Outcome = "failed"
+		}
+		// Password sign-ins do not record a method
+		item.Method = "password"
+		if method.Valid && method.String != "" {
+			item.Method = method.String
+		}
+		item.Reason = reason.String
+		item.UserAgent = userAgent.String
+		items = append(items, item)
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":   items,
+		"limit":   limit,
+		"offset":  offset,
+		"hasMore": hasMore,
+	})
+}
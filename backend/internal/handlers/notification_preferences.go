@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/notify"
+	"saferelief/internal/validation"
+)
+
+// GetNotificationPreferences returns, for every event type, which channels
+// the user receives it on
+func (h *UserHandler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	prefs, err := notify.LoadPreferences(r.Context(), h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preferences": prefs,
+		"events":      notify.Events,
+		"channels":    notify.Channels,
+	})
+}
+
+// UpdateNotificationPreferences turns channels on or off per event type.
+// Only the events and channels sent are changed.
+func (h *UserHandler) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var requestData struct {
+		Preferences notify.Preferences `json:"preferences"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	v.Check(len(requestData.Preferences) > 0, "preferences", "is required")
+	for event, channels := range requestData.Preferences {
+		if !notify.ValidEvent(event) {
+			v.AddError("preferences."+event, "must be one of: "+strings.Join(notify.Events, ", "))
+			continue
+		}
+		for channel := range channels {
+			v.Check(notify.ValidChannel(channel), "preferences."+event+"."+channel,
+				"must be one of: "+strings.Join(notify.Channels, ", "))
+		}
+	}
+	if enabled, ok := requestData.Preferences[notify.EventSecurityAlert][notify.ChannelEmail]; ok && !enabled {
+		v.AddError("preferences."+notify.EventSecurityAlert+"."+notify.ChannelEmail, "cannot be turned off")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	if err := notify.SavePreferences(r.Context(), h.db, userID, requestData.Preferences); err != nil {
+		apierror.Error(w, "Failed to update notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	prefs, err := notify.LoadPreferences(r.Context(), h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preferences": prefs,
+		"message":     "Notification preferences updated",
+	})
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"saferelief/internal/apierror"
+)
+
+const (
+	openDataCacheTTL = 10 * time.Minute
+	// Funding groups with fewer donations are withheld so no single
+	// donation amount can be read off the dataset
+	minFundingGroupSize = 3
+	maxOpenDataRange    = 5 * 365 * 24 * time.Hour
+)
+
+// openDataPeriods maps ?interval= to the SQL bucketing the timestamp column
+// {t}; weeks start on Monday
+var openDataPeriods = map[string]string{
+	"day":   "DATE_FORMAT({t}, '%Y-%m-%d')",
+	"week":  "DATE_FORMAT(DATE_SUB({t}, INTERVAL WEEKDAY({t}) DAY), '%Y-%m-%d')",
+	"month": "DATE_FORMAT({t}, '%Y-%m')",
+}
+
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+type OpenDataHandler struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	cache map[string]cachedResponse
+}
+
+func NewOpenDataHandler(db *sql.DB) *OpenDataHandler {
+	return &OpenDataHandler{db: db, cache: make(map[string]cachedResponse)}
+}
+
+// openDataQuery holds the parsed grouping and time range of a request
+type openDataQuery struct {
+	region, severity bool
+	interval         string
+	from, to         time.Time
+}
+
+func parseOpenDataQuery(r *http.Request) (openDataQuery, string) {
+	query := r.URL.Query()
+	q := openDataQuery{interval: query.Get("interval")}
+
+	groupBy := query.Get("groupBy")
+	if groupBy == "" {
+		groupBy = "period"
+	}
+	for _, dim := range strings.Split(groupBy, ",") {
+		switch strings.TrimSpace(dim) {
+		case "region":
+			q.region = true
+		case "severity":
+			q.severity = true
+		case "period":
+			if q.interval == "" {
+				q.interval = "month"
+			}
+		default:
+			return q, "groupBy accepts region, severity and period"
+		}
+	}
+	if _, ok := openDataPeriods[q.interval]; q.interval != "" && !ok {
+		return q, "interval must be day, week or month"
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	q.to = today.AddDate(0, 0, 1)
+	q.from = today.AddDate(-1, 0, 0)
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return q, "from must be a date (YYYY-MM-DD)"
+		}
+		q.from = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return q, "to must be a date (YYYY-MM-DD)"
+		}
+		q.to = t.AddDate(0, 0, 1)
+	}
+	if !q.from.Before(q.to) || q.to.Sub(q.from) > maxOpenDataRange {
+		return q, "from must be before to, at most five years apart"
+	}
+	return q, ""
+}
+
+// columns returns the grouping expressions for a dataset whose report
+// columns are aliased r and whose event time is timeColumn
+func (q openDataQuery) columns(timeColumn string) []string {
+	var columns []string
+	if q.region {
+		columns = append(columns, "FLOOR(r.latitude)", "FLOOR(r.longitude)")
+	}
+	if q.severity {
+		columns = append(columns, "r.severity")
+	}
+	if q.interval != "" {
+		columns = append(columns, strings.ReplaceAll(openDataPeriods[q.interval], "{t}", timeColumn))
+	}
+	return columns
+}
+
+// scanGroup reads the grouping columns in the order columns() produced them
+func (q openDataQuery) scanGroup(values []interface{}) map[string]interface{} {
+	group := map[string]interface{}{}
+	i := 0
+	if q.region {
+		lat, lon := values[i].(*int), values[i+1].(*int)
+		group["region"] = map[string]interface{}{
+			"minLatitude":  *lat,
+			"minLongitude": *lon,
+			"cellDegrees":  1,
+		}
+		i += 2
+	}
+	if q.severity {
+		group["severity"] = *values[i].(*string)
+		i++
+	}
+	if q.interval != "" {
+		group["period"] = *values[i].(*string)
+	}
+	return group
+}
+
+func (q openDataQuery) targets() []interface{} {
+	var targets []interface{}
+	if q.region {
+		targets = append(targets, new(int), new(int))
+	}
+	if q.severity {
+		targets = append(targets, new(string))
+	}
+	if q.interval != "" {
+		targets = append(targets, new(string))
+	}
+	return targets
+}
+
+// Index describes the available datasets
+func (h *OpenDataHandler) Index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"datasets": []map[string]string{
+			{"path": "/api/open-data/reports", "description": "Verified disaster reports counted by region, severity and period"},
+			{"path": "/api/open-data/funding", "description": "Completed donations to verified reports, totaled per currency by region, severity and period"},
+		},
+		"parameters": map[string]string{
+			"groupBy":  "comma-separated region, severity, period (default period)",
+			"interval": "day, week or month (default month)",
+			"from":     "YYYY-MM-DD, default one year ago",
+			"to":       "YYYY-MM-DD inclusive, default today",
+		},
+		"regions": "1-degree latitude/longitude cells identified by their south-west corner",
+		"privacy": "No reporter or donor identities; funding groups with fewer than 3 donations are withheld",
+	})
+}
+
+// ReportCounts returns verified reports per group, by verification date
+func (h *OpenDataHandler) ReportCounts(w http.ResponseWriter, r *http.Request) {
+	h.serveCached(w, r, func(q openDataQuery) (interface{}, error) {
+		columns := q.columns("r.verified_at")
+		query := `SELECT ` + strings.Join(append(append([]string{}, columns...), "COUNT(*)"), ", ") + `
+			FROM disaster_reports r
+			WHERE r.verified_at IS NOT NULL AND r.status != 'rejected' AND r.deleted_at IS NULL
+			AND r.verified_at >= ? AND r.verified_at < ?`
+		if len(columns) > 0 {
+			query += " GROUP BY " + strings.Join(columns, ", ") + " ORDER BY " + strings.Join(columns, ", ")
+		}
+
+		rows, err := h.db.QueryContext(r.Context(), query, q.from, q.to)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		groups := []map[string]interface{}{}
+		for rows.Next() {
+			targets := q.targets()
+			var count int
+			if err := rows.Scan(append(targets, &count)...); err != nil {
+				return nil, err
+			}
+			group := q.scanGroup(targets)
+			group["reports"] = count
+			groups = append(groups, group)
+		}
+		return groups, rows.Err()
+	})
+}
+
+// FundingTotals returns completed donation totals per currency and group,
+// by completion date
+func (h *OpenDataHandler) FundingTotals(w http.ResponseWriter, r *http.Request) {
+	h.serveCached(w, r, func(q openDataQuery) (interface{}, error) {
+		columns := append(q.columns("d.completed_at"), "d.currency")
+		rows, err := h.db.QueryContext(r.Context(),
+			`SELECT `+strings.Join(columns, ", ")+`, CAST(SUM(d.amount) AS CHAR), COUNT(*)
+			FROM donations d
+			JOIN disaster_reports r ON r.id = d.disaster_report_id
+			WHERE d.status = 'completed' AND r.verified_at IS NOT NULL AND r.deleted_at IS NULL
+			AND d.completed_at >= ? AND d.completed_at < ?
+			GROUP BY `+strings.Join(columns, ", ")+`
+			HAVING COUNT(*) >= ?
+			ORDER BY `+strings.Join(columns, ", "),
+			q.from, q.to, minFundingGroupSize,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		groups := []map[string]interface{}{}
+		for rows.Next() {
+			targets := q.targets()
+			var currency, amount string
+			var count int
+			if err := rows.Scan(append(targets, &currency, &amount, &count)...); err != nil {
+				return nil, err
+			}
+			group := q.scanGroup(targets)
+			group["currency"] = currency
+			group["amount"] = json.Number(amount)
+			group["donations"] = count
+			groups = append(groups, group)
+		}
+		return groups, rows.Err()
+	})
+}
+
+// serveCached answers identical queries from memory for openDataCacheTTL;
+// the aggregates only move as reports are verified and donations complete
+func (h *OpenDataHandler) serveCached(w http.ResponseWriter, r *http.Request, build func(openDataQuery) (interface{}, error)) {
+	q, problem := parseOpenDataQuery(r)
+	if problem != "" {
+		apierror.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	cacheKey := r.URL.Path + "?" + r.URL.RawQuery
+	h.mu.Lock()
+	cached, ok := h.cache[cacheKey]
+	h.mu.Unlock()
+
+	if !ok || time.Now().After(cached.expires) {
+		groups, err := build(q)
+		if err != nil {
+			apierror.Error(w, "Error building dataset", http.StatusInternalServerError)
+			return
+		}
+		var body bytes.Buffer
+		json.NewEncoder(&body).Encode(map[string]interface{}{
+			"from":        q.from.Format("2006-01-02"),
+			"to":          q.to.AddDate(0, 0, -1).Format("2006-01-02"),
+			"groups":      groups,
+			"generatedAt": time.Now().UTC(),
+		})
+		cached = cachedResponse{body: body.Bytes(), expires: time.Now().Add(openDataCacheTTL)}
+
+		h.mu.Lock()
+		for key, entry := range h.cache {
+			if time.Now().After(entry.expires) {
+				delete(h.cache, key)
+			}
+		}
+		h.cache[cacheKey] = cached
+		h.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(time.Until(cached.expires).Seconds())))
+	w.Write(cached.body)
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/notify"
+	"saferelief/internal/validation"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+type Organization struct {
+	ID                 string     `json:"id"`
+	Name               string     `json:"name"`
+	RegistrationNumber string     `json:"registrationNumber"`
+	Description        string     `json:"description"`
+	Website            string     `json:"website"`
+	OwnerID            string     `json:"ownerId"`
+	VerificationStatus string     `json:"verificationStatus"`
+	Verified           bool       `json:"verified"`
+	DocumentIDs        []string   `json:"documentIds,omitempty"`
+	ReviewNote         *string    `json:"reviewNote,omitempty"`
+	VerifiedAt         *time.Time `json:"verifiedAt"`
+	CreatedAt          time.Time  `json:"createdAt"`
+}
+
+// OrganizationBadge is the summary shown next to reports filed on behalf of an organization
+type OrganizationBadge struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Verified bool   `json:"verified"`
+}
+
+type OrganizationHandler struct {
+	db          *sql.DB
+	mailer      *notify.Mailer
+	auditLogger *audit.Logger
+	frontendURL string
+}
+
+func NewOrganizationHandler(db *sql.DB, mailer *notify.Mailer, auditLogger *audit.Logger) *OrganizationHandler {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	return &OrganizationHandler{
+		db:          db,
+		mailer:      mailer,
+		auditLogger: auditLogger,
+		frontendURL: frontendURL,
+	}
+}
+
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var org struct {
+		Name               string `json:"name"`
+		RegistrationNumber string `json:"registrationNumber"`
+		Description        string `json:"description"`
+		Website            string `json:"website"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&org); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	org.Name = strings.TrimSpace(org.Name)
+	org.RegistrationNumber = strings.TrimSpace(org.RegistrationNumber)
+
+	v := validation.New()
+	if v.Required("name", org.Name) {
+		v.Length("name", org.Name, 3, 255)
+	}
+	v.Required("registrationNumber", org.RegistrationNumber)
+	v.Check(org.Website == "" || strings.HasPrefix(org.Website, "https://"), "website", "must be an https:// URL")
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	var orgID string
+	err := h.db.QueryRow(
+		`INSERT INTO organizations (id, name, registration_number, description, website, owner_id)
+		VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, ?, UUID_TO_BIN(?))
+		RETURNING BIN_TO_UUID(id)`,
+		org.Name, org.RegistrationNumber, org.Description, org.Website, userID,
+	).Scan(&orgID)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			apierror.Error(w, "Organization already registered", http.StatusConflict)
+			return
+		}
+		apierror.Error(w, "Error creating organization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      orgID,
+		"message": "Organization created successfully",
+	})
+}
+
+// GetOrganization is public; verification documents are never exposed here
+func (h *OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.queryOrganizations("WHERE id = UUID_TO_BIN(?)", mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Error(w, "Error fetching organization", http.StatusInternalServerError)
+		return
+	}
+	if len(orgs) == 0 {
+		apierror.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	org := orgs[0]
+	org.DocumentIDs = nil
+	org.ReviewNote = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// SubmitVerification attaches supporting documents (from the uploads
+// subsystem) and puts the organization in the admin review queue
+func (h *OrganizationHandler) SubmitVerification(w http.ResponseWriter, r *http.Request) {
+	orgID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		DocumentIDs []string `json:"documentIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(request.DocumentIDs) == 0 || len(request.DocumentIDs) > maxApplicationDocuments {
+		apierror.Error(w, "Between 1 and 5 supporting documents are required", http.StatusBadRequest)
+		return
+	}
+
+	for _, documentID := range request.DocumentIDs {
+		var owner string
+		err := h.db.QueryRow("SELECT user_id FROM uploads WHERE id = ?", documentID).Scan(&owner)
+		if err == sql.ErrNoRows || (err == nil && owner != userID) {
+			apierror.Error(w, "Document not found: "+documentID, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	documents, _ := json.Marshal(request.DocumentIDs)
+	result, err := h.db.Exec(
+		`UPDATE organizations
+		SET verification_status = 'pending', verification_documents = ?, review_note = NULL, updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND owner_id = UUID_TO_BIN(?) AND verification_status IN ('unverified', 'rejected')`,
+		documents, orgID, userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error submitting verification", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Organization not found, not owned by you, or already under review", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Verification submitted for review",
+	})
+}
+
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "pending"
+	}
+
+	orgs, err := h.queryOrganizations("WHERE verification_status = ? ORDER BY updated_at ASC LIMIT 100", status)
+	if err != nil {
+		apierror.Error(w, "Error fetching organizations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orgs)
+}
+
+func (h *OrganizationHandler) ReviewVerification(w http.ResponseWriter, r *http.Request) {
+	orgID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	var review struct {
+		Decision string `json:"decision"`
+		Note     string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var status string
+	switch review.Decision {
+	case "approve":
+		status = "verified"
+	case "reject":
+		status = "rejected"
+	default:
+		apierror.Error(w, "Decision must be approve or reject", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(
+		`UPDATE organizations
+		SET verification_status = ?, review_note = ?, verified_by = UUID_TO_BIN(?),
+		verified_at = IF(? = 'verified', NOW(), NULL), updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND verification_status = 'pending'`,
+		status, review.Note, adminID, status, orgID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error reviewing organization", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Organization not found or not pending review", http.StatusNotFound)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventOrganizationReviewed,
+		Severity:   audit.SeverityHigh,
+		UserID:     adminID,
+		EntityType: "organization",
+		EntityID:   orgID,
+		Details:    map[string]interface{}{"decision": status, "note": review.Note},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":                 orgID,
+		"verificationStatus": status,
+		"message":            "Organization reviewed successfully",
+	})
+}
+
+func (h *OrganizationHandler) queryOrganizations(clause string, args ...interface{}) ([]Organization, error) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), name, registration_number, COALESCE(description, ''),
+		COALESCE(website, ''), BIN_TO_UUID(owner_id), verification_status,
+		verification_documents, review_note, verified_at, created_at
+		FROM organizations `+clause,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []Organization{}
+	for rows.Next() {
+		var org Organization
+		var documents []byte
+		if err := rows.Scan(
+			&org.ID, &org.Name, &org.RegistrationNumber, &org.Description, &org.Website,
+			&org.OwnerID, &org.VerificationStatus, &documents, &org.ReviewNote,
+			&org.VerifiedAt, &org.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		org.Verified = org.VerificationStatus == "verified"
+		if len(documents) > 0 {
+			json.Unmarshal(documents, &org.DocumentIDs)
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/notify"
+	"saferelief/internal/tokens"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+const organizationInvitationTTL = 7 * 24 * time.Hour
+
+type OrganizationInvitation struct {
+	ID          string          `json:"id"`
+	Email       string          `json:"email"`
+	Role        OrgRole         `json:"role"`
+	Permissions []OrgPermission `json:"permissions"`
+	InvitedBy   string          `json:"invitedBy"`
+	ExpiresAt   time.Time       `json:"expiresAt"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// invite emails a membership invitation to an address with no account yet.
+// Inviting the same address again replaces the earlier invitation.
+func (h *OrganizationHandler) invite(w http.ResponseWriter, r *http.Request, orgID, inviterID, email string, role OrgRole, permissions []OrgPermission) {
+	v := validation.New()
+	v.Email("email", email)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	token, hash, err := tokens.Generate()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	encoded, _ := json.Marshal(permissions)
+	expiresAt := time.Now().Add(organizationInvitationTTL)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"DELETE FROM organization_invitations WHERE organization_id = UUID_TO_BIN(?) AND email = ? AND accepted_at IS NULL",
+		orgID, email,
+	)
+	if err != nil {
+		apierror.Error(w, "Error creating invitation", http.StatusInternalServerError)
+		return
+	}
+	var invitationID, orgName string
+	err = tx.QueryRow(
+		`INSERT INTO organization_invitations
+		(id, organization_id, email, role, permissions, token_hash, invited_by, expires_at)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?, UUID_TO_BIN(?), ?)
+		RETURNING BIN_TO_UUID(id)`,
+		orgID, email, role, encoded, hash, inviterID, expiresAt,
+	).Scan(&invitationID)
+	if err != nil {
+		apierror.Error(w, "Error creating invitation", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.QueryRow("SELECT name FROM organizations WHERE id = UUID_TO_BIN(?)", orgID).Scan(&orgName); err != nil {
+		apierror.Error(w, "Error creating invitation", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error creating invitation", http.StatusInternalServerError)
+		return
+	}
+
+	invitee := notify.Recipient{Email: email, Locale: notify.DefaultLocale, Timezone: notify.DefaultTimezone}
+	if err := h.mailer.SendTemplate(r.Context(), invitee, "organization.invitation", map[string]interface{}{
+		"OrganizationName": orgName,
+		"Role":             string(role),
+		"Link":             h.frontendURL + "/organizations/invitations?token=" + token,
+		"ExpiresAt":        expiresAt,
+	}); err != nil {
+		log.Printf("Failed to send organization invitation %s: %v", invitationID, err)
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventOrganizationMemberChanged,
+		Severity:   audit.SeverityMedium,
+		UserID:     inviterID,
+		EntityType: "organization",
+		EntityID:   orgID,
+		Details:    map[string]interface{}{"invitationId": invitationID, "email": email, "role": role},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invitationId": invitationID,
+		"email":        email,
+		"role":         role,
+		"permissions":  permissions,
+		"expiresAt":    expiresAt,
+		"message":      "No account uses that email yet, so an invitation was sent",
+	})
+}
+
+// ListInvitations shows invitations that have not been accepted
+func (h *OrganizationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	orgID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+	if _, ok := h.requireOrganizationOwner(w, orgID, userID); !ok {
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), email, role, permissions, BIN_TO_UUID(invited_by), expires_at, created_at
+		FROM organization_invitations
+		WHERE organization_id = UUID_TO_BIN(?) AND accepted_at IS NULL
+		ORDER BY created_at DESC`,
+		orgID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching invitations", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	invitations := []OrganizationInvitation{}
+	for rows.Next() {
+		var inv OrganizationInvitation
+		var permissions []byte
+		if err := rows.Scan(&inv.ID, &inv.Email, &inv.Role, &permissions, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing invitations", http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal(permissions, &inv.Permissions)
+		invitations = append(invitations, inv)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+func (h *OrganizationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orgID, invitationID := vars["id"], vars["invitationId"]
+	userID := r.Context().Value("user_id").(string)
+	if _, ok := h.requireOrganizationOwner(w, orgID, userID); !ok {
+		return
+	}
+
+	result, err := h.db.Exec(
+		`DELETE FROM organization_invitations
+		WHERE id = UUID_TO_BIN(?) AND organization_id = UUID_TO_BIN(?) AND accepted_at IS NULL`,
+		invitationID, orgID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error revoking invitation", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventOrganizationMemberChanged,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "organization",
+		EntityID:   orgID,
+		Details:    map[string]interface{}{"invitationId": invitationID, "revoked": true},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Invitation revoked"})
+}
+
+// AcceptInvitation makes the signed-in user a member. The invitation is tied
+// to the address it was sent to, so the account must have verified that email.
+func (h *OrganizationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		apierror.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	var email string
+	var emailVerifiedAt sql.NullTime
+	err := h.db.QueryRow(
+		"SELECT email, email_verified_at FROM users WHERE id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&email, &emailVerifiedAt)
+	if err != nil {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !emailVerifiedAt.Valid {
+		apierror.Error(w, "Verify your email address before accepting invitations", http.StatusForbidden)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var invitationID, orgID, invitedBy, invitedEmail string
+	var role OrgRole
+	var permissions []byte
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(organization_id), email, role, permissions, BIN_TO_UUID(invited_by)
+		FROM organization_invitations
+		WHERE token_hash = ? AND accepted_at IS NULL AND expires_at > NOW()
+		FOR UPDATE`,
+		tokens.Hash(request.Token),
+	).Scan(&invitationID, &orgID, &invitedEmail, &role, &permissions, &invitedBy)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Invitation is invalid or has expired", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !strings.EqualFold(invitedEmail, email) {
+		apierror.Error(w, "This invitation was sent to a different email address", http.StatusForbidden)
+		return
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO organization_members (organization_id, user_id, role, permissions, granted_by)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, UUID_TO_BIN(?))
+		ON DUPLICATE KEY UPDATE role = VALUES(role), permissions = VALUES(permissions),
+		granted_by = VALUES(granted_by), updated_at = NOW()`,
+		orgID, userID, role, permissions, invitedBy,
+	)
+	if err != nil {
+		apierror.Error(w, "Error accepting invitation", http.StatusInternalServerError)
+		return
+	}
+	_, err = tx.Exec(
+		"UPDATE organization_invitations SET accepted_at = NOW(), accepted_by = UUID_TO_BIN(?) WHERE id = UUID_TO_BIN(?)",
+		userID, invitationID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error accepting invitation", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error accepting invitation", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventOrganizationMemberChanged,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "organization",
+		EntityID:   orgID,
+		Details:    map[string]interface{}{"memberId": userID, "role": role, "invitationId": invitationID},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"organizationId": orgID,
+		"role":           role,
+		"permissions":    json.RawMessage(permissions),
+		"message":        "You have joined the organization",
+	})
+}
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+
+	"github.com/gorilla/mux"
+)
+
+// OrgPermission scopes what a staff member may do on behalf of an organization
+type OrgPermission string
+
+const (
+	OrgPermCreateReports       OrgPermission = "reports:create"
+	OrgPermVerifyReports       OrgPermission = "reports:verify"
+	OrgPermViewDonations       OrgPermission = "donations:view"
+	OrgPermManageDisbursements OrgPermission = "disbursements:manage"
+)
+
+var orgPermissions = map[OrgPermission]bool{
+	OrgPermCreateReports:       true,
+	OrgPermVerifyReports:       true,
+	OrgPermViewDonations:       true,
+	OrgPermManageDisbursements: true,
+}
+
+// OrgRole is a named bundle of permissions; members can hold extra
+// permissions on top of their role
+type OrgRole string
+
+const (
+	OrgRoleOwner    OrgRole = "owner"
+	OrgRoleVerifier OrgRole = "verifier"
+	OrgRoleFinance  OrgRole = "finance"
+	OrgRoleStaff    OrgRole = "staff"
+)
+
+var orgRolePermissions = map[OrgRole][]OrgPermission{
+	OrgRoleOwner: {
+		OrgPermCreateReports, OrgPermVerifyReports, OrgPermViewDonations, OrgPermManageDisbursements,
+	},
+	OrgRoleVerifier: {OrgPermCreateReports, OrgPermVerifyReports},
+	OrgRoleFinance:  {OrgPermViewDonations, OrgPermManageDisbursements},
+	OrgRoleStaff:    {},
+}
+
+const invalidOrgPermissionsMessage = "Permissions must be any of reports:create, reports:verify, donations:view, disbursements:manage"
+
+type OrganizationMember struct {
+	UserID      string          `json:"userId"`
+	Username    string          `json:"username"`
+	Email       string          `json:"email"`
+	Role        OrgRole         `json:"role"`
+	Permissions []OrgPermission `json:"permissions"`
+	GrantedBy   string          `json:"grantedBy"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// organizationRole returns the role the user holds in the organization and
+// any extra permissions. The registered owner is always an owner; an empty
+// role means the user is not a member.
+func organizationRole(db *sql.DB, orgID, userID string) (OrgRole, []OrgPermission, error) {
+	var ownerID string
+	var role sql.NullString
+	var raw []byte
+	err := db.QueryRow(
+		`SELECT BIN_TO_UUID(o.owner_id), m.role, m.permissions
+		FROM organizations o
+		LEFT JOIN organization_members m ON m.organization_id = o.id AND m.user_id = UUID_TO_BIN(?)
+		WHERE o.id = UUID_TO_BIN(?)`,
+		userID, orgID,
+	).Scan(&ownerID, &role, &raw)
+	if err == sql.ErrNoRows {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if ownerID == userID {
+		return OrgRoleOwner, nil, nil
+	}
+	var permissions []OrgPermission
+	json.Unmarshal(raw, &permissions)
+	return OrgRole(role.String), permissions, nil
+}
+
+// hasOrganizationPermission reports whether the user may act for the
+// organization, through their role or an extra permission
+func hasOrganizationPermission(db *sql.DB, orgID, userID string, permission OrgPermission) (bool, error) {
+	role, extra, err := organizationRole(db, orgID, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range append(orgRolePermissions[role], extra...) {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// requireOrganizationOwner writes the error response and returns false unless
+// the caller is an owner of the organization. It also returns the registered
+// owner, whose access cannot be changed through membership.
+func (h *OrganizationHandler) requireOrganizationOwner(w http.ResponseWriter, orgID, userID string) (string, bool) {
+	var ownerID string
+	err := h.db.QueryRow(
+		"SELECT BIN_TO_UUID(owner_id) FROM organizations WHERE id = UUID_TO_BIN(?)",
+		orgID,
+	).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Organization not found", http.StatusNotFound)
+		return "", false
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return "", false
+	}
+	role, _, err := organizationRole(h.db, orgID, userID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return "", false
+	}
+	if role != OrgRoleOwner {
+		apierror.Error(w, "Only organization owners can manage staff", http.StatusForbidden)
+		return "", false
+	}
+	return ownerID, true
+}
+
+// parseOrgRole defaults to staff, which only has the permissions granted
+// alongside it
+func parseOrgRole(raw string) (OrgRole, bool) {
+	if raw == "" {
+		return OrgRoleStaff, true
+	}
+	_, ok := orgRolePermissions[OrgRole(raw)]
+	return OrgRole(raw), ok
+}
+
+func parseOrgPermissions(raw []OrgPermission) ([]OrgPermission, bool) {
+	seen := map[OrgPermission]bool{}
+	permissions := []OrgPermission{}
+	for _, p := range raw {
+		if !orgPermissions[p] {
+			return nil, false
+		}
+		if !seen[p] {
+			seen[p] = true
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions, true
+}
+
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	orgID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+	if _, ok := h.requireOrganizationOwner(w, orgID, userID); !ok {
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(m.user_id), u.username, u.email, m.role, m.permissions,
+		BIN_TO_UUID(m.granted_by), m.created_at, m.updated_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = UUID_TO_BIN(?)
+		ORDER BY m.created_at`,
+		orgID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching members", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	members := []OrganizationMember{}
+	for rows.Next() {
+		var m OrganizationMember
+		var permissions []byte
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Email, &m.Role, &permissions, &m.GrantedBy, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			apierror.Error(w, "Error processing members", http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal(permissions, &m.Permissions)
+		members = append(members, m)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// AddMember gives a user a role in the organization. Someone without an
+// account yet is emailed an invitation instead.
+func (h *OrganizationHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	orgID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+	ownerID, ok := h.requireOrganizationOwner(w, orgID, userID)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Email       string          `json:"email"`
+		Role        string          `json:"role"`
+		Permissions []OrgPermission `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	request.Email = strings.TrimSpace(request.Email)
+	role, permissions, ok := parseMemberAccess(w, request.Role, request.Permissions)
+	if !ok {
+		return
+	}
+
+	var memberID string
+	err := h.db.QueryRow(
+		"SELECT BIN_TO_UUID(id) FROM users WHERE email = ? AND deleted_at IS NULL",
+		request.Email,
+	).Scan(&memberID)
+	if err == sql.ErrNoRows {
+		h.invite(w, r, orgID, userID, request.Email, role, permissions)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if memberID == ownerID {
+		apierror.Error(w, "The owner already has full access", http.StatusBadRequest)
+		return
+	}
+
+	if !h.saveMember(w, r, orgID, memberID, userID, role, permissions) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"userId":      memberID,
+		"role":        role,
+		"permissions": permissions,
+		"message":     "Member added",
+	})
+}
+
+// parseMemberAccess validates a role and extra permissions, writing the
+// error response when they are unusable
+func parseMemberAccess(w http.ResponseWriter, rawRole string, rawPermissions []OrgPermission) (OrgRole, []OrgPermission, bool) {
+	role, ok := parseOrgRole(rawRole)
+	if !ok {
+		apierror.Error(w, "Role must be one of owner, verifier, finance, staff", http.StatusBadRequest)
+		return "", nil, false
+	}
+	permissions, ok := parseOrgPermissions(rawPermissions)
+	if !ok {
+		apierror.Error(w, invalidOrgPermissionsMessage, http.StatusBadRequest)
+		return "", nil, false
+	}
+	if role == OrgRoleStaff && len(permissions) == 0 {
+		apierror.Error(w, "Staff need at least one permission; or give a role", http.StatusBadRequest)
+		return "", nil, false
+	}
+	return role, permissions, true
+}
+
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orgID, memberID := vars["id"], vars["userId"]
+	userID := r.Context().Value("user_id").(string)
+	if _, ok := h.requireOrganizationOwner(w, orgID, userID); !ok {
+		return
+	}
+
+	var request struct {
+		Role        string          `json:"role"`
+		Permissions []OrgPermission `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	role, permissions, ok := parseMemberAccess(w, request.Role, request.Permissions)
+	if !ok {
+		return
+	}
+
+	var exists int
+	err := h.db.QueryRow(
+		"SELECT COUNT(*) FROM organization_members WHERE organization_id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)",
+		orgID, memberID,
+	).Scan(&exists)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if exists == 0 {
+		apierror.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	if !h.saveMember(w, r, orgID, memberID, userID, role, permissions) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"userId":      memberID,
+		"role":        role,
+		"permissions": permissions,
+		"message":     "Member updated",
+	})
+}
+
+func (h *OrganizationHandler) saveMember(w http.ResponseWriter, r *http.Request, orgID, memberID, ownerID string, role OrgRole, permissions []OrgPermission) bool {
+	encoded, _ := json.Marshal(permissions)
+	_, err := h.db.Exec(
+		`INSERT INTO organization_members (organization_id, user_id, role, permissions, granted_by)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, UUID_TO_BIN(?))
+		ON DUPLICATE KEY UPDATE role = VALUES(role), permissions = VALUES(permissions),
+		granted_by = VALUES(granted_by), updated_at = NOW()`,
+		orgID, memberID, role, encoded, ownerID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error saving member", http.StatusInternalServerError)
+		return false
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventOrganizationMemberChanged,
+		Severity:   audit.SeverityMedium,
+		UserID:     ownerID,
+		EntityType: "organization",
+		EntityID:   orgID,
+		Details:    map[string]interface{}{"memberId": memberID, "role": role, "permissions": permissions},
+	})
+	return true
+}
+
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orgID, memberID := vars["id"], vars["userId"]
+	userID := r.Context().Value("user_id").(string)
+	if _, ok := h.requireOrganizationOwner(w, orgID, userID); !ok {
+		return
+	}
+
+	result, err := h.db.Exec(
+		"DELETE FROM organization_members WHERE organization_id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)",
+		orgID, memberID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error removing member", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventOrganizationMemberChanged,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "organization",
+		EntityID:   orgID,
+		Details:    map[string]interface{}{"memberId": memberID, "removed": true},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Member removed"})
+}
+
+// ListDonations shows donations made to reports filed on behalf of the organization
+func (h *OrganizationHandler) ListDonations(w http.ResponseWriter, r *http.Request) {
+	orgID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	allowed, err := hasOrganizationPermission(h.db, orgID, userID, OrgPermViewDonations)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		apierror.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(d.id), BIN_TO_UUID(d.disaster_report_id), dr.title,
+		d.amount, d.currency, d.status, d.created_at
+		FROM donations d
+		JOIN disaster_reports dr ON dr.id = d.disaster_report_id
+		WHERE dr.organization_id = UUID_TO_BIN(?) AND dr.deleted_at IS NULL
+		ORDER BY d.created_at DESC
+		LIMIT 500`,
+		orgID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching donations", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	type organizationDonation struct {
+		ID          string    `json:"id"`
+		ReportID    string    `json:"reportId"`
+		ReportTitle string    `json:"reportTitle"`
+		Amount      float64   `json:"amount"`
+		Currency    string    `json:"currency"`
+		Status      string    `json:"status"`
+		CreatedAt   time.Time `json:"createdAt"`
+	}
+	donations := []organizationDonation{}
+	for rows.Next() {
+		var d organizationDonation
+		if err := rows.Scan(&d.ID, &d.ReportID, &d.ReportTitle, &d.Amount, &d.Currency, &d.Status, &d.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing donations", http.StatusInternalServerError)
+			return
+		}
+		donations = append(donations, d)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(donations)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/sso"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// ssoConfigResponse never carries the client secret, only whether one is set
+func ssoConfigResponse(cfg *sso.Config) map[string]interface{} {
+	return map[string]interface{}{
+		"organizationId":     cfg.OrganizationID,
+		"issuer":             cfg.Issuer,
+		"clientId":           cfg.ClientID,
+		"clientSecretSet":    cfg.ClientSecret != "",
+		"emailDomains":       cfg.EmailDomains,
+		"groupsClaim":        cfg.GroupsClaim,
+		"groupPermissions":   cfg.GroupPermissions,
+		"defaultPermissions": cfg.DefaultPermissions,
+		"enabled":            cfg.Enabled,
+		"loginUrl":           "/api/auth/sso/" + cfg.OrganizationID + "/login",
+	}
+}
+
+func (h *OrganizationHandler) GetSSOConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, err := sso.LoadConfig(h.db, mux.Vars(r)["id"])
+	if errors.Is(err, sso.ErrNotConfigured) {
+		apierror.Error(w, "Organization has no SSO configuration", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching SSO configuration", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ssoConfigResponse(cfg))
+}
+
+// UpdateSSOConfig creates or replaces an organization's identity provider.
+// Only platform admins may do this: the email domains decide which existing
+// accounts the provider can sign in as, so they are checked out of band.
+func (h *OrganizationHandler) UpdateSSOConfig(w http.ResponseWriter, r *http.Request) {
+	orgID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Issuer             string                     `json:"issuer"`
+		ClientID           string                     `json:"clientId"`
+		ClientSecret       string                     `json:"clientSecret"`
+		EmailDomains       []string                   `json:"emailDomains"`
+		GroupsClaim        string                     `json:"groupsClaim"`
+		GroupPermissions   map[string][]OrgPermission `json:"groupPermissions"`
+		DefaultPermissions []OrgPermission            `json:"defaultPermissions"`
+		Enabled            bool                       `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var status string
+	err := h.db.QueryRow(
+		"SELECT verification_status FROM organizations WHERE id = UUID_TO_BIN(?)",
+		orgID,
+	).Scan(&status)
+	if err != nil {
+		apierror.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	if status != "verified" {
+		apierror.Error(w, "Only verified organizations can use single sign-on", http.StatusConflict)
+		return
+	}
+
+	existing, err := sso.LoadConfig(h.db, orgID)
+	if err != nil && !errors.Is(err, sso.ErrNotConfigured) {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// An empty secret on update keeps the stored one
+	if request.ClientSecret == "" && existing != nil {
+		request.ClientSecret = existing.ClientSecret
+	}
+
+	cfg := &sso.Config{
+		OrganizationID:   orgID,
+		Issuer:           strings.TrimSuffix(strings.TrimSpace(request.Issuer), "/"),
+		ClientID:         strings.TrimSpace(request.ClientID),
+		ClientSecret:     request.ClientSecret,
+		GroupsClaim:      strings.TrimSpace(request.GroupsClaim),
+		GroupPermissions: map[string][]string{},
+		Enabled:          request.Enabled,
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	v := validation.New()
+	v.Check(sso.ValidIssuer(cfg.Issuer), "issuer", "must be an https URL")
+	if v.Required("clientId", cfg.ClientID) {
+		v.Length("clientId", cfg.ClientID, 1, 255)
+	}
+	v.Required("clientSecret", cfg.ClientSecret)
+	v.Length("groupsClaim", cfg.GroupsClaim, 1, 64)
+	v.Check(len(request.EmailDomains) > 0, "emailDomains", "at least one domain is required")
+	for _, domain := range request.EmailDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" || strings.ContainsAny(domain, "@/ ") || !strings.Contains(domain, ".") {
+			v.AddError("emailDomains", "must be domain names such as example.org")
+			break
+		}
+		cfg.EmailDomains = append(cfg.EmailDomains, domain)
+	}
+	permissions, ok := parseOrgPermissions(request.DefaultPermissions)
+	v.Check(ok, "defaultPermissions", "must be one or more of reports:create, reports:verify, donations:view, disbursements:manage")
+	cfg.DefaultPermissions = orgPermissionStrings(permissions)
+	for group, list := range request.GroupPermissions {
+		permissions, ok := parseOrgPermissions(list)
+		if !ok || group == "" {
+			v.AddError("groupPermissions", "must map group names to reports:create, reports:verify, donations:view or disbursements:manage")
+			break
+		}
+		cfg.GroupPermissions[group] = orgPermissionStrings(permissions)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	if err := sso.SaveConfig(h.db, cfg, adminID); err != nil {
+		if errors.Is(err, sso.ErrDomainInUse) {
+			apierror.Error(w, "An email domain is already used by another organization's SSO", http.StatusConflict)
+			return
+		}
+		apierror.Error(w, "Error saving SSO configuration", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventSSOConfigChanged,
+		Severity:   audit.SeverityHigh,
+		UserID:     adminID,
+		EntityType: "organization",
+		EntityID:   orgID,
+		Details: map[string]interface{}{
+			"issuer":        cfg.Issuer,
+			"emailDomains":  cfg.EmailDomains,
+			"enabled":       cfg.Enabled,
+			"secretChanged": existing == nil || existing.ClientSecret != cfg.ClientSecret,
+		},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ssoConfigResponse(cfg))
+}
+
+func (h *OrganizationHandler) DeleteSSOConfig(w http.ResponseWriter, r *http.Request) {
+	orgID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	err := sso.DeleteConfig(h.db, orgID)
+	if errors.Is(err, sso.ErrNotConfigured) {
+		apierror.Error(w, "Organization has no SSO configuration", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error deleting SSO configuration", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventSSOConfigChanged,
+		Severity:   audit.SeverityHigh,
+		UserID:     adminID,
+		EntityType: "organization",
+		EntityID:   orgID,
+		Details:    map[string]interface{}{"deleted": true},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "SSO configuration deleted"})
+}
+
+func orgPermissionStrings(permissions []OrgPermission) []string {
+	list := make([]string, len(permissions))
+	for i, p := range permissions {
+		list[i] = string(p)
+	}
+	return list
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/validation"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ChangePassword replaces the signed-in user's password after checking the current one
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	v.Required("currentPassword", request.CurrentPassword)
+	v.Password("newPassword", request.NewPassword)
+	v.Check(request.NewPassword != request.CurrentPassword, "newPassword", "must differ from the current password")
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	var passwordHash string
+	err := h.db.QueryRow("SELECT password_hash FROM users WHERE id = UUID_TO_BIN(?)", userID).Scan(&passwordHash)
+	if err != nil {
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(request.CurrentPassword)); err != nil {
+		apierror.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+
+	if err := accounts.ChangePassword(h.db, userID, request.NewPassword); err != nil {
+		apierror.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password changed successfully"})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/notify"
+	"saferelief/internal/validation"
+)
+
+const (
+	maxDisplayNameLength = 100
+	maxBioLength         = 500
+)
+
+// avatarURLs maps each standard avatar size in pixels to its URL
+type avatarURLs map[string]string
+
+func (a *avatarURLs) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = avatarURLs{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	}
+	return fmt.Errorf("unsupported avatar sizes type %T", src)
+}
+
+// UpdateProfileDetails changes the extended profile fields. Fields left out
+// are kept, and an empty string clears displayName, phone or bio.
+func (h *UserHandler) UpdateProfileDetails(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var updateData struct {
+		DisplayName *string `json:"displayName"`
+		Phone       *string `json:"phone"`
+		Bio         *string `json:"bio"`
+		Locale      *string `json:"locale"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	displayName := optionalText(updateData.DisplayName)
+	if displayName != nil {
+		v.Length("displayName", *displayName, 1, maxDisplayNameLength)
+	}
+	phone := optionalText(updateData.Phone)
+	if phone != nil {
+		*phone = strings.NewReplacer(" ", "", "-", "").Replace(*phone)
+		v.Phone("phone", *phone)
+	}
+	bio := optionalText(updateData.Bio)
+	if bio != nil {
+		v.Length("bio", *bio, 1, maxBioLength)
+	}
+	if updateData.Locale != nil {
+		v.Check(notify.ValidLocale(*updateData.Locale), "locale", "must be one of: id, en")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	// A nil pointer keeps the column; an empty value was turned into NULL
+	_, err := h.db.Exec(`
+		UPDATE users SET
+		display_name = IF(?, ?, display_name),
+		phone = IF(?, ?, phone),
+		bio = IF(?, ?, bio),
+		locale = COALESCE(?, locale),
+		updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?)
+	`, updateData.DisplayName != nil, displayName,
+		updateData.Phone != nil, phone,
+		updateData.Bio != nil, bio,
+		updateData.Locale, userID)
+	if err != nil {
+		apierror.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Profile updated successfully"})
+}
+
+// optionalText trims a field from a partial update; blank becomes nil so it
+// is stored as NULL
+func optionalText(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	publicReportCacheTTL = time.Minute
+	// Every distinct query string is a cache entry; past this many the
+	// response is served uncached rather than growing the map
+	maxPublicCacheEntries = 1000
+)
+
+// PublicReport is a verified report as anyone may see it: nothing that
+// identifies the reporter or the verifier
+type PublicReport struct {
+	ID           string     `json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	DisasterType string     `json:"disasterType"`
+	Latitude     float64    `json:"latitude"`
+	Longitude    float64    `json:"longitude"`
+	Severity     string     `json:"severity"`
+	Status       string     `json:"status"`
+	VerifiedAt   *time.Time `json:"verifiedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	// Fundraising is the amount raised toward the report's goal
+	Fundraising FundraisingProgress `json:"fundraising"`
+}
+
+const publicReportColumns = `SELECT BIN_TO_UUID(id), title, description, disaster_type,
+	latitude, longitude, severity, status, verified_at, created_at, updated_at, ` + fundraisingColumns + `
+	FROM disaster_reports`
+
+func scanPublicReport(scan func(dest ...interface{}) error) (PublicReport, error) {
+	var report PublicReport
+	var verifiedAt sql.NullTime
+	err := scan(append([]interface{}{&report.ID, &report.Title, &report.Description, &report.DisasterType,
+		&report.Latitude, &report.Longitude, &report.Severity, &report.Status,
+		&verifiedAt, &report.CreatedAt, &report.UpdatedAt}, fundraisingDest(&report.Fundraising)...)...)
+	if verifiedAt.Valid {
+		report.VerifiedAt = &verifiedAt.Time
+	}
+	report.Fundraising = report.Fundraising.withPercent()
+	return report, err
+}
+
+// PublicReportHandler serves verified reports without authentication. Its
+// routes have their own rate limit tier, and responses are cached in memory
+// and by clients for publicReportCacheTTL.
+type PublicReportHandler struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	cache map[string]cachedResponse
+}
+
+func NewPublicReportHandler(db *sql.DB) *PublicReportHandler {
+	return &PublicReportHandler{db: db, cache: make(map[string]cachedResponse)}
+}
+
+// ListReports lists verified reports, newest first. It takes the status,
+// severity, type and q filters of the authenticated list; status may only
+// name a verified status.
+func (h *PublicReportHandler) ListReports(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := parseReportFilter(query)
+
+	limit := defaultReportLimit
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxReportLimit)
+	}
+	offset := 0
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	v := validation.New()
+	filter.validate(v)
+	if filter.Status != "" {
+		v.Check(ReportStatus(filter.Status).Verified(), "status", "must be one of: verified, in_progress, resolved, closed")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	h.serveCached(w, r, func() (interface{}, error) {
+		where, args := filter.where()
+		where += " AND status IN (" + verifiedReportStatuses + ")"
+
+		var total int
+		if err := h.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM disaster_reports"+where, args...).Scan(&total); err != nil {
+			return nil, err
+		}
+
+		rows, err := h.db.QueryContext(r.Context(),
+			publicReportColumns+where+" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?",
+			append(args, limit, offset)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		reports := []PublicReport{}
+		for rows.Next() {
+			report, err := scanPublicReport(rows.Scan)
+			if err != nil {
+				return nil, err
+			}
+			reports = append(reports, report)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"items":   reports,
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+			"hasMore": offset+len(reports) < total,
+		}, nil
+	})
+}
+
+// GetReport returns one verified report. Reports that are pending,
+// rejected, hidden, deleted or missing are all the same 404.
+func (h *PublicReportHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	h.serveCached(w, r, func() (interface{}, error) {
+		return scanPublicReport(h.db.QueryRowContext(r.Context(),
+			publicReportColumns+" WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL AND hidden_at IS NULL AND status IN ("+verifiedReportStatuses+")",
+			reportID,
+		).Scan)
+	})
+}
+
+// serveCached answers repeated requests from memory until they expire.
+// Errors are never cached, so a report verified a moment after a 404 shows
+// up on the next request.
+func (h *PublicReportHandler) serveCached(w http.ResponseWriter, r *http.Request, build func() (interface{}, error)) {
+	cacheKey := r.URL.Path + "?" + r.URL.RawQuery
+	h.mu.Lock()
+	cached, ok := h.cache[cacheKey]
+	h.mu.Unlock()
+
+	if !ok || time.Now().After(cached.expires) {
+		result, err := build()
+		if err == sql.ErrNoRows {
+			apierror.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			apierror.Error(w, "Error fetching reports", http.StatusInternalServerError)
+			return
+		}
+		var body bytes.Buffer
+		json.NewEncoder(&body).Encode(result)
+		cached = cachedResponse{body: body.Bytes(), expires: time.Now().Add(publicReportCacheTTL)}
+
+		h.mu.Lock()
+		for key, entry := range h.cache {
+			if time.Now().After(entry.expires) {
+				delete(h.cache, key)
+			}
+		}
+		if len(h.cache) < maxPublicCacheEntries {
+			h.cache[cacheKey] = cached
+		}
+		h.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(time.Until(cached.expires).Seconds())))
+	w.Write(cached.body)
+}
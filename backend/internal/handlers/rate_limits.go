@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/ratelimit"
+	"saferelief/internal/validation"
+)
+
+type RateLimitHandler struct {
+	db          *sql.DB
+	limiter     *ratelimit.Limiter
+	auditLogger *audit.Logger
+}
+
+func NewRateLimitHandler(db *sql.DB, limiter *ratelimit.Limiter, auditLogger *audit.Logger) *RateLimitHandler {
+	return &RateLimitHandler{db: db, limiter: limiter, auditLogger: auditLogger}
+}
+
+// ListLimits shows the effective budget of each tier and whether it comes
+// from configuration or a database override
+func (h *RateLimitHandler) ListLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window": ratelimit.Window.String(),
+		"tiers":  h.limiter.Limits(),
+	})
+}
+
+// UpdateLimits stores per-tier overrides. A limit of 0 removes the override
+// so the configured value applies again. Other instances pick the change up
+// on their next reload.
+func (h *RateLimitHandler) UpdateLimits(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Limits map[ratelimit.Tier]int `json:"limits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	names := make([]string, len(ratelimit.Tiers))
+	for i, tier := range ratelimit.Tiers {
+		names[i] = string(tier)
+	}
+	v := validation.New()
+	v.Check(len(request.Limits) > 0, "limits", "is required")
+	for tier, limit := range request.Limits {
+		if !ratelimit.ValidTier(tier) {
+			v.AddError("limits."+string(tier), "must be one of: "+strings.Join(names, ", "))
+			continue
+		}
+		v.Check(limit >= 0, "limits."+string(tier), "must not be negative")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	previous := h.limiter.Limits()
+	for tier, limit := range request.Limits {
+		if err := ratelimit.SaveOverride(r.Context(), h.db, tier, limit, userID); err != nil {
+			apierror.Error(w, "Failed to update rate limits", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.limiter.Reload(r.Context(), h.db); err != nil {
+		apierror.Error(w, "Failed to reload rate limits", http.StatusInternalServerError)
+		return
+	}
+	current := h.limiter.Limits()
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventRateLimitsUpdated,
+		Severity:   audit.SeverityHigh,
+		UserID:     userID,
+		EntityType: "rate_limits",
+		Details: map[string]interface{}{
+			"previous": previous,
+			"current":  current,
+		},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window": ratelimit.Window.String(),
+		"tiers":  current,
+	})
+}
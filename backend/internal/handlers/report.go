@@ -3,17 +3,30 @@ package handlers
 import (
 	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"saferelief/internal/alerts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+	"saferelief/internal/triage"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+
 	"github.com/gorilla/mux"
 )
 
@@ -22,21 +35,53 @@ const (
 	maxTotalSize = 25 * 1024 * 1024 // 25MB
 	allowedTypes = ".jpg,.jpeg,.png"
 	uploadDir    = "./uploads"
+
+	defaultReportLimit = 20
+	maxReportLimit     = 100
+
+	maxReportSearchLength = 200
 )
 
 type DisasterReport struct {
-	ID          string    `json:"id"`
-	ReporterID  string    `json:"reporterId"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Latitude    float64   `json:"latitude"`
-	Longitude   float64   `json:"longitude"`
-	Severity    string    `json:"severity"`
-	Status      string    `json:"status"`
-	VerifiedBy  *string   `json:"verifiedBy"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
-	Files       []File    `json:"files,omitempty"`
+	ID         string    `json:"id"`
+	ReporterID string    `json:"reporterId"`
+	Reporter   *Reporter `json:"reporter,omitempty"`
+	// Organization is set when the report was filed on behalf of an NGO
+	Organization *OrganizationBadge `json:"organization,omitempty"`
+	Title        string             `json:"title"`
+	Description  string             `json:"description"`
+	DisasterType string             `json:"disasterType"`
+	Latitude     float64            `json:"latitude"`
+	Longitude    float64            `json:"longitude"`
+	Severity     string             `json:"severity"`
+	Status       string             `json:"status"`
+	// Source is user, or feed for candidate reports filed from agency
+	// alerts, which verifiers may edit before confirming
+	Source     string    `json:"source"`
+	VerifiedBy *string   `json:"verifiedBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Files      []File    `json:"files,omitempty"`
+	// LatestUpdate is the newest comment, set on single-report reads
+	LatestUpdate *ReportComment `json:"latestUpdate,omitempty"`
+	// Rejection is set on rejected reports, which only the reporter and
+	// verifiers can see
+	Rejection *ReportRejection `json:"rejection,omitempty"`
+	// Needs summarizes the report's item needs, set on single-report reads
+	// when it has any
+	Needs *NeedsSummary `json:"needs,omitempty"`
+	// Hidden is set on reports hidden after abuse flags, which, like
+	// rejected ones, only the reporter and verifiers can see
+	Hidden bool `json:"hidden,omitempty"`
+	// Fundraising is the amount raised toward the goal, set on
+	// single-report reads
+	Fundraising *FundraisingProgress `json:"fundraising,omitempty"`
+}
+
+// Reporter is the public view of the user who filed a report
+type Reporter struct {
+	Username  string  `json:"username"`
+	AvatarURL *string `json:"avatarUrl"`
 }
 
 type File struct {
@@ -49,11 +94,27 @@ type File struct {
 }
 
 type ReportHandler struct {
-	db *sql.DB
+	db          *sql.DB
+	auditLogger *audit.Logger
+	webhooks    *webhooks.Dispatcher
+	alerts      *alerts.Notifier
+	quorum      verificationQuorum
+	// flagThreshold is how many open abuse flags hide a report
+	flagThreshold int
+	// escrowApprovals is how many donation managers release escrowed funds
+	escrowApprovals int
+
+	statsMu    sync.Mutex
+	statsCache map[string]cachedResponse
 }
 
-func NewReportHandler(db *sql.DB) *ReportHandler {
-	return &ReportHandler{db: db}
+func NewReportHandler(db *sql.DB, auditLogger *audit.Logger, dispatcher *webhooks.Dispatcher, notifier *alerts.Notifier) *ReportHandler {
+	return &ReportHandler{
+		db: db, auditLogger: auditLogger, webhooks: dispatcher, alerts: notifier,
+		quorum: verificationQuorum{Required: 1, Of: 1}, flagThreshold: defaultFlagThreshold,
+		escrowApprovals: defaultEscrowApprovals,
+		statsCache:      make(map[string]cachedResponse),
+	}
 }
 
 func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
@@ -62,7 +123,7 @@ func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
 
 	// Parse multipart form
 	if err := r.ParseMultipartForm(maxTotalSize); err != nil {
-		http.Error(w, "Request too large", http.StatusBadRequest)
+		apierror.Error(w, "Request too large", http.StatusBadRequest)
 		return
 	}
 	defer r.MultipartForm.RemoveAll()
@@ -70,10 +131,42 @@ func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
 	userID := r.Context().Value("user_id").(string)
 
+	// Reports may be filed on behalf of an organization by its owner or by
+	// staff granted reports:create
+	var organizationID *string
+	if orgID := r.FormValue("organizationId"); orgID != "" {
+		allowed, err := hasOrganizationPermission(h.db, orgID, userID, OrgPermCreateReports)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			apierror.Error(w, "You cannot report on behalf of this organization", http.StatusForbidden)
+			return
+		}
+		organizationID = &orgID
+	}
+
+	disasterType := r.FormValue("disasterType")
+	if disasterType == "" {
+		disasterType = defaultDisasterType
+	}
+	valid, err := validDisasterType(h.db, disasterType)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		v := validation.New()
+		v.AddError("disasterType", "is not a known disaster type; see GET /api/reports/types")
+		v.WriteError(w)
+		return
+	}
+
 	// Start transaction
 	tx, err := h.db.Begin()
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 	defer tx.Rollback()
@@ -81,19 +174,21 @@ func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
 	// Insert report
 	var reportID string
 	err = tx.QueryRow(
-		`INSERT INTO disaster_reports (id, reporter_id, title, description, latitude, longitude, severity, status)
-		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?, ?, 'pending')
+		`INSERT INTO disaster_reports (id, reporter_id, organization_id, title, description, disaster_type, latitude, longitude, severity, status)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?, 'pending')
 		RETURNING BIN_TO_UUID(id)`,
 		userID,
+		organizationID,
 		r.FormValue("title"),
 		r.FormValue("description"),
+		disasterType,
 		r.FormValue("latitude"),
 		r.FormValue("longitude"),
 		r.FormValue("severity"),
 	).Scan(&reportID)
 
 	if err != nil {
-		http.Error(w, "Error creating report", http.StatusInternalServerError)
+		apierror.Error(w, "Error creating report", http.StatusInternalServerError)
 		return
 	}
 
@@ -101,21 +196,53 @@ func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
 	files := r.MultipartForm.File["files"]
 	for _, fileHeader := range files {
 		if err := h.validateAndSaveFile(tx, reportID, userID, fileHeader); err != nil {
-			http.Error(w, "Error processing file upload", http.StatusBadRequest)
+			apierror.Error(w, "Error processing file upload", http.StatusBadRequest)
 			return
 		}
 	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
-		http.Error(w, "Error saving report", http.StatusInternalServerError)
+		apierror.Error(w, "Error saving report", http.StatusInternalServerError)
 		return
 	}
 
+	// Record both who acted and the organization they acted for
+	if organizationID != nil {
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventActedOnBehalf,
+			UserID:     userID,
+			EntityType: "disaster_report",
+			EntityID:   reportID,
+			Details:    map[string]interface{}{"actingUserId": userID, "onBehalfOf": *organizationID, "action": "report_created"},
+		})
+	}
+
+	h.webhooks.Publish(webhooks.EventReportCreated, reportWebhookRecipients(h.db, reportID),
+		map[string]interface{}{
+			"id":           reportID,
+			"title":        r.FormValue("title"),
+			"disasterType": disasterType,
+			"severity":     r.FormValue("severity"),
+			"status":       "pending",
+		},
+	)
+
+	// Detection and triage are advisory, so failures here do not fail the report
+	duplicates, err := h.flagDuplicates(reportID)
+	if err != nil {
+		log.Printf("Failed to check report %s for duplicates: %v", reportID, err)
+		duplicates = []DuplicateCandidate{}
+	}
+	if _, err := triage.ScoreNew(r.Context(), h.db, reportID); err != nil {
+		log.Printf("Failed to score report %s: %v", reportID, err)
+	}
+
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"id":      reportID,
-		"message": "Report created successfully",
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":                 reportID,
+		"possibleDuplicates": duplicates,
+		"message":            "Report created successfully",
 	})
 }
 
@@ -138,6 +265,15 @@ func (h *ReportHandler) validateAndSaveFile(tx *sql.Tx, reportID, userID string,
 	}
 	defer file.Close()
 
+	return storeReportFile(tx, reportID, userID, fileHeader.Filename, fileHeader.Header.Get("Content-Type"), fileHeader.Size, file, "")
+}
+
+// storeReportFile writes an already validated attachment to the upload
+// directory and records it against the report. uploadID names the uploads
+// record it was copied from, if any.
+func storeReportFile(tx *sql.Tx, reportID, userID, originalName, mimeType string, size int64, file io.ReadSeeker, uploadID string) error {
+	ext := strings.ToLower(filepath.Ext(originalName))
+
 	// Calculate file hash
 	hash := sha256.New()
 	if _, err := io.Copy(hash, file); err != nil {
@@ -167,9 +303,9 @@ func (h *ReportHandler) validateAndSaveFile(tx *sql.Tx, reportID, userID string,
 
 	// Insert file record
 	_, err = tx.Exec(
-		`INSERT INTO file_uploads (id, user_id, disaster_report_id, filename, original_filename, file_size, mime_type, file_hash, storage_path)
-		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?)`,
-		userID, reportID, filename, fileHeader.Filename, fileHeader.Size, fileHeader.Header.Get("Content-Type"), fileHash, filepath,
+		`INSERT INTO file_uploads (id, user_id, disaster_report_id, filename, original_filename, file_size, mime_type, file_hash, storage_path, upload_id)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?, NULLIF(?, ''))`,
+		userID, reportID, filename, originalName, size, mimeType, fileHash, filepath, uploadID,
 	)
 
 	return err
@@ -180,25 +316,72 @@ func (h *ReportHandler) GetReport(w http.ResponseWriter, r *http.Request) {
 	reportID := vars["id"]
 
 	var report DisasterReport
+	var orgID, orgName, orgStatus sql.NullString
+	var rejectionReason, rejectionNote, rejectedBy, mergedInto sql.NullString
+	var rejectedAt sql.NullTime
+	var fundraising FundraisingProgress
+	report.Reporter = &Reporter{}
 	err := h.db.QueryRow(
-		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(reporter_id), title, description, 
-		latitude, longitude, severity, status, BIN_TO_UUID(verified_by), created_at, updated_at
-		FROM disaster_reports WHERE id = UUID_TO_BIN(?)`,
+		`SELECT BIN_TO_UUID(dr.id), BIN_TO_UUID(dr.reporter_id), u.username, u.avatar_url,
+		BIN_TO_UUID(o.id), o.name, o.verification_status,
+		dr.title, dr.description, dr.disaster_type, dr.latitude, dr.longitude, dr.severity, dr.status, dr.source,
+		BIN_TO_UUID(dr.verified_by), dr.created_at, dr.updated_at,
+		dr.rejection_reason, dr.rejection_note, BIN_TO_UUID(dr.rejected_by), dr.rejected_at,
+		BIN_TO_UUID(dr.merged_into), dr.hidden_at IS NOT NULL, `+fundraisingColumns+`
+		FROM disaster_reports dr
+		JOIN users u ON u.id = dr.reporter_id
+		LEFT JOIN organizations o ON o.id = dr.organization_id
+		WHERE dr.id = UUID_TO_BIN(?) AND dr.deleted_at IS NULL`,
 		reportID,
-	).Scan(
-		&report.ID, &report.ReporterID, &report.Title, &report.Description,
-		&report.Latitude, &report.Longitude, &report.Severity, &report.Status,
+	).Scan(append([]interface{}{
+		&report.ID, &report.ReporterID, &report.Reporter.Username, &report.Reporter.AvatarURL,
+		&orgID, &orgName, &orgStatus,
+		&report.Title, &report.Description, &report.DisasterType,
+		&report.Latitude, &report.Longitude, &report.Severity, &report.Status, &report.Source,
 		&report.VerifiedBy, &report.CreatedAt, &report.UpdatedAt,
-	)
+		&rejectionReason, &rejectionNote, &rejectedBy, &rejectedAt,
+		&mergedInto, &report.Hidden,
+	}, fundraisingDest(&fundraising)...)...)
 
 	if err == sql.ErrNoRows {
-		http.Error(w, "Report not found", http.StatusNotFound)
+		apierror.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		http.Error(w, "Error fetching report", http.StatusInternalServerError)
+		apierror.Error(w, "Error fetching report", http.StatusInternalServerError)
 		return
 	}
+	if ReportStatus(report.Status) == ReportRejected || report.Hidden {
+		userID, _ := r.Context().Value("user_id").(string)
+		if !h.canSeeRejected(userID, report.ReporterID, orgID.String) {
+			apierror.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+	}
+	if ReportStatus(report.Status) == ReportRejected {
+		if rejectionReason.Valid {
+			report.Rejection = &ReportRejection{
+				Reason:     rejectionReason.String,
+				RejectedBy: rejectedBy.String,
+				RejectedAt: rejectedAt.Time,
+			}
+			if rejectionNote.Valid {
+				report.Rejection.Note = &rejectionNote.String
+			}
+			if mergedInto.Valid {
+				report.Rejection.MergedInto = &mergedInto.String
+			}
+		}
+	}
+	fundraising = fundraising.withPercent()
+	report.Fundraising = &fundraising
+	if orgID.Valid {
+		report.Organization = &OrganizationBadge{
+			ID:       orgID.String,
+			Name:     orgName.String,
+			Verified: orgStatus.String == "verified",
+		}
+	}
 
 	// Get associated files
 	rows, err := h.db.Query(
@@ -207,7 +390,7 @@ func (h *ReportHandler) GetReport(w http.ResponseWriter, r *http.Request) {
 		reportID,
 	)
 	if err != nil {
-		http.Error(w, "Error fetching files", http.StatusInternalServerError)
+		apierror.Error(w, "Error fetching files", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
@@ -215,158 +398,504 @@ func (h *ReportHandler) GetReport(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var file File
 		if err := rows.Scan(&file.ID, &file.Filename, &file.FileHash, &file.FileSize, &file.MimeType, &file.CreatedAt); err != nil {
-			http.Error(w, "Error processing files", http.StatusInternalServerError)
+			apierror.Error(w, "Error processing files", http.StatusInternalServerError)
 			return
 		}
 		report.Files = append(report.Files, file)
 	}
 
+	report.LatestUpdate, err = h.latestComment(reportID)
+	if err != nil {
+		apierror.Error(w, "Error fetching report updates", http.StatusInternalServerError)
+		return
+	}
+	needs, err := h.reportNeeds(reportID)
+	if err != nil {
+		apierror.Error(w, "Error fetching report needs", http.StatusInternalServerError)
+		return
+	}
+	if len(needs) > 0 {
+		summary := summarizeNeeds(needs)
+		report.Needs = &summary
+	}
+
 	json.NewEncoder(w).Encode(report)
 }
 
+// ListReports pages through reports. Pass the previous page's nextCursor as
+// after to keep paging without rows shifting under an offset; total counts
+// every report matching the filters. q searches titles and descriptions and
+// orders by relevance unless another sort is asked for.
 func (h *ReportHandler) ListReports(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for filtering and pagination
-	limit := 10
+	query := r.URL.Query()
+	filter := parseReportFilter(query)
+	search := filter.Search
+
+	limit := defaultReportLimit
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxReportLimit {
+		limit = maxReportLimit
+	}
 	offset := 0
-	status := r.URL.Query().Get("status")
-	severity := r.URL.Query().Get("severity")
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "createdAt"
+		if search != "" {
+			sort = "relevance"
+		}
+	}
+	order := strings.ToLower(query.Get("order"))
+	if order == "" {
+		order = "desc"
+	}
 
-	query := `SELECT BIN_TO_UUID(id), BIN_TO_UUID(reporter_id), title, description, 
-		latitude, longitude, severity, status, BIN_TO_UUID(verified_by), created_at, updated_at
-		FROM disaster_reports WHERE 1=1`
-	args := []interface{}{}
+	v := validation.New()
+	filter.validate(v)
+	column, ok := reportSorts[sort]
+	v.Check(ok, "sort", "must be one of: createdAt, updatedAt, severity, relevance")
+	v.Check(sort != "relevance" || search != "", "sort", "relevance needs a q search")
+	v.Check(order == "asc" || order == "desc", "order", "must be asc or desc")
+	var cursor *reportCursor
+	if raw := query.Get("after"); raw != "" {
+		c, err := decodeReportCursor(raw)
+		if sort == "relevance" {
+			// Scores shift as reports are added, so there is no stable position to resume from
+			v.AddError("after", "is not available when sorting by relevance; use offset")
+		} else if err != nil {
+			v.AddError("after", "is not a valid cursor")
+		} else if c.Sort != sort || c.Order != order {
+			v.AddError("after", "was issued for a different sort or order")
+		} else {
+			cursor = &c
+		}
+		v.Check(offset == 0, "offset", "cannot be combined with after")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
 
-	if status != "" {
-		query += " AND status = ?"
-		args = append(args, status)
+	where, args, ok := h.reportFilterWhere(w, r, filter)
+	if !ok {
+		return
 	}
-	if severity != "" {
-		query += " AND severity = ?"
-		args = append(args, severity)
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM disaster_reports"+where, args...).Scan(&total); err != nil {
+		apierror.Error(w, "Error fetching reports", http.StatusInternalServerError)
+		return
 	}
 
-	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
-	args = append(args, limit, offset)
+	// Ties on the sort column are broken by id so every row has one place
+	direction, comparison := "DESC", "<"
+	if order == "asc" {
+		direction, comparison = "ASC", ">"
+	}
+	if cursor != nil {
+		where += fmt.Sprintf(" AND (%s, id) %s (?, UUID_TO_BIN(?))", column, comparison)
+		args = append(args, cursor.Value, cursor.ID)
+	}
+	sqlQuery := `SELECT BIN_TO_UUID(id), BIN_TO_UUID(reporter_id), title, description, disaster_type,
+		latitude, longitude, severity, status, source, BIN_TO_UUID(verified_by), created_at, updated_at
+		FROM disaster_reports` + where +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ? OFFSET ?", column, direction, direction)
+	if sort == "relevance" {
+		args = append(args, search)
+	}
+	args = append(args, limit+1, offset)
 
-	rows, err := h.db.Query(query, args...)
+	rows, err := h.db.Query(sqlQuery, args...)
 	if err != nil {
-		http.Error(w, "Error fetching reports", http.StatusInternalServerError)
+		apierror.Error(w, "Error fetching reports", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
-	var reports []DisasterReport
+	reports := []DisasterReport{}
 	for rows.Next() {
 		var report DisasterReport
 		if err := rows.Scan(
-			&report.ID, &report.ReporterID, &report.Title, &report.Description,
-			&report.Latitude, &report.Longitude, &report.Severity, &report.Status,
+			&report.ID, &report.ReporterID, &report.Title, &report.Description, &report.DisasterType,
+			&report.Latitude, &report.Longitude, &report.Severity, &report.Status, &report.Source,
 			&report.VerifiedBy, &report.CreatedAt, &report.UpdatedAt,
 		); err != nil {
-			http.Error(w, "Error processing reports", http.StatusInternalServerError)
+			apierror.Error(w, "Error processing reports", http.StatusInternalServerError)
 			return
 		}
 		reports = append(reports, report)
 	}
 
-	json.NewEncoder(w).Encode(reports)
+	hasMore := len(reports) > limit
+	var nextCursor *string
+	if hasMore {
+		reports = reports[:limit]
+		if sort != "relevance" {
+			next := newReportCursor(sort, order, reports[limit-1]).encode()
+			nextCursor = &next
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":      reports,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+		"hasMore":    hasMore,
+		"nextCursor": nextCursor,
+	})
 }
 
-func (h *ReportHandler) VerifyReport(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	reportID := vars["id"]
-	userID := r.Context().Value("user_id").(string)
+// reportFilter holds the filters shared by the report list and its exports
+type reportFilter struct {
+	Status       string
+	Severity     string
+	DisasterType string
+	Source       string
+	Search       string
+}
 
-	// Update report status
-	result, err := h.db.Exec(
-		`UPDATE disaster_reports 
-		SET status = 'verified', verified_by = UUID_TO_BIN(?), updated_at = NOW()
-		WHERE id = UUID_TO_BIN(?) AND status = 'pending'`,
-		userID, reportID,
-	)
-	if err != nil {
-		http.Error(w, "Error verifying report", http.StatusInternalServerError)
-		return
+func parseReportFilter(query url.Values) reportFilter {
+	return reportFilter{
+		Status:       query.Get("status"),
+		Severity:     query.Get("severity"),
+		DisasterType: query.Get("type"),
+		Source:       query.Get("source"),
+		Search:       strings.TrimSpace(query.Get("q")),
 	}
+}
 
-	rows, err := result.RowsAffected()
+func (f reportFilter) validate(v *validation.Validator) {
+	v.Check(f.Source == "" || f.Source == "user" || f.Source == "feed", "source", "must be user or feed")
+	v.Check(len(f.Search) <= maxReportSearchLength, "q", fmt.Sprintf("must be at most %d characters", maxReportSearchLength))
+}
+
+// reportFilterWhere builds the WHERE clause for a filter over
+// disaster_reports. Rejected reports are hidden unless a verifier asks for
+// them; anyone else gets a 403 written and false.
+func (h *ReportHandler) reportFilterWhere(w http.ResponseWriter, r *http.Request, f reportFilter) (string, []interface{}, bool) {
+	if f.Status == string(ReportRejected) {
+		userID, _ := r.Context().Value("user_id").(string)
+		role, err := middleware.LookupRole(h.db, userID)
+		if err != nil || !role.Can(middleware.PermVerifyReports) {
+			apierror.Write(w, http.StatusForbidden, apierror.CodeInsufficientPermission,
+				"Listing rejected reports requires "+string(middleware.PermVerifyReports),
+				map[string]string{"permission": string(middleware.PermVerifyReports)})
+			return "", nil, false
+		}
+	}
+	where, args := f.where()
+	return where, args, true
+}
+
+// where builds the WHERE clause for the filter without any permission
+// check; callers must not let it name rejected reports for just anyone.
+// Reports hidden after abuse flags are always left out.
+func (f reportFilter) where() (string, []interface{}) {
+	where := " WHERE deleted_at IS NULL AND hidden_at IS NULL"
+	args := []interface{}{}
+	if f.Status != "" {
+		where += " AND status = ?"
+		args = append(args, f.Status)
+	} else {
+		where += " AND status != 'rejected'"
+	}
+	if f.Severity != "" {
+		where += " AND severity = ?"
+		args = append(args, f.Severity)
+	}
+	if f.DisasterType != "" {
+		where += " AND disaster_type = ?"
+		args = append(args, f.DisasterType)
+	}
+	if f.Source != "" {
+		where += " AND source = ?"
+		args = append(args, f.Source)
+	}
+	if f.Search != "" {
+		where += " AND " + reportSearchMatch
+		args = append(args, f.Search)
+	}
+	return where, args
+}
+
+// reportSearchMatch uses the ft_reports FULLTEXT index; natural language
+// mode ranks reports mentioning more of the words, and rarer words, higher
+const reportSearchMatch = "MATCH(title, description) AGAINST (? IN NATURAL LANGUAGE MODE)"
+
+// reportSorts maps the sort parameter to the expression ordered by. Severity
+// sorts by its rank in the ENUM rather than alphabetically.
+var reportSorts = map[string]string{
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+	"severity":  "severity+0",
+	"relevance": reportSearchMatch,
+}
+
+var severityRanks = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// reportCursor marks the last report of a page. It remembers the sort it was
+// issued for so it cannot be replayed against a different ordering.
+type reportCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func newReportCursor(sort, order string, last DisasterReport) reportCursor {
+	c := reportCursor{Sort: sort, Order: order, ID: last.ID}
+	switch sort {
+	case "updatedAt":
+		c.Value = last.UpdatedAt.Format("2006-01-02 15:04:05")
+	case "severity":
+		c.Value = strconv.Itoa(severityRanks[last.Severity])
+	default:
+		c.Value = last.CreatedAt.Format("2006-01-02 15:04:05")
+	}
+	return c
+}
+
+func (c reportCursor) encode() string {
+	encoded, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeReportCursor(raw string) (reportCursor, error) {
+	var c reportCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
-		http.Error(w, "Error checking update result", http.StatusInternalServerError)
-		return
+		return c, err
 	}
-	if rows == 0 {
-		http.Error(w, "Report not found or already verified", http.StatusNotFound)
-		return
+	if err := json.Unmarshal(decoded, &c); err != nil {
+		return c, err
 	}
+	if c.ID == "" || c.Value == "" {
+		return c, fmt.Errorf("incomplete cursor")
+	}
+	return c, nil
+}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Report verified successfully",
-	})
+// VerifyReport casts an approving verification vote; the report is
+// verified once enough verifiers approve, see SubmitVerification
+func (h *ReportHandler) VerifyReport(w http.ResponseWriter, r *http.Request) {
+	h.castVote(w, r, mux.Vars(r)["id"], VoteApprove, "", "")
+}
+
+// canVerify writes the error response and returns false unless the user may
+// verify the report: platform verifiers can verify any report, and members
+// with reports:verify in the filing organization can verify its reports
+// except the ones they filed themselves. The platform role comes from the
+// access token, see middleware.RequireRole.
+func (h *ReportHandler) canVerify(w http.ResponseWriter, r *http.Request, reportID, userID string) bool {
+	role, ok := r.Context().Value("role").(middleware.Role)
+	if !ok {
+		apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if role.Can(middleware.PermVerifyReports) {
+		return true
+	}
+
+	var reporterID string
+	var orgID sql.NullString
+	err := h.db.QueryRow(
+		"SELECT BIN_TO_UUID(reporter_id), BIN_TO_UUID(organization_id) FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL",
+		reportID,
+	).Scan(&reporterID, &orgID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found or already verified", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	allowed := false
+	if orgID.Valid && reporterID != userID {
+		allowed, err = hasOrganizationPermission(h.db, orgID.String, userID, OrgPermVerifyReports)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return false
+		}
+	}
+	if !allowed {
+		apierror.Write(w, http.StatusForbidden, apierror.CodeInsufficientPermission, "Forbidden",
+			map[string]string{"permission": string(middleware.PermVerifyReports)})
+		return false
+	}
+	return true
 }
 
+// UpdateReport lets the reporter correct a report while it awaits
+// verification. Verifiers may also edit reports filed from agency feeds, to
+// enrich them before confirming. Fields left out are kept. Once a verifier
+// has decided on a report it can no longer be edited; further news goes in
+// a comment.
 func (h *ReportHandler) UpdateReport(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	reportID := vars["id"]
-	userID := r.Context().Value("userID").(string)
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
 
 	var updateData struct {
-		Title       string  `json:"title"`
-		Description string  `json:"description"`
-		Severity    string  `json:"severity"`
-		Location    string  `json:"location"`
-		Latitude    float64 `json:"latitude"`
-		Longitude   float64 `json:"longitude"`
+		Title        *string  `json:"title"`
+		Description  *string  `json:"description"`
+		DisasterType *string  `json:"disasterType"`
+		Severity     *string  `json:"severity"`
+		Latitude     *float64 `json:"latitude"`
+		Longitude    *float64 `json:"longitude"`
 	}
-
 	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	// Validate input
-	if updateData.Title == "" || updateData.Description == "" {
-		http.Error(w, "Title and description are required", http.StatusBadRequest)
+	v := validation.New()
+	changed := []string{}
+	if updateData.Title != nil {
+		*updateData.Title = strings.TrimSpace(*updateData.Title)
+		if v.Required("title", *updateData.Title) {
+			v.Length("title", *updateData.Title, 1, 255)
+		}
+		changed = append(changed, "title")
+	}
+	if updateData.Description != nil {
+		*updateData.Description = strings.TrimSpace(*updateData.Description)
+		v.Required("description", *updateData.Description)
+		changed = append(changed, "description")
+	}
+	if updateData.DisasterType != nil {
+		valid, err := validDisasterType(h.db, *updateData.DisasterType)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		v.Check(valid, "disasterType", "is not a known disaster type; see GET /api/reports/types")
+		changed = append(changed, "disasterType")
+	}
+	if updateData.Severity != nil {
+		_, ok := severityRanks[*updateData.Severity]
+		v.Check(ok, "severity", "must be low, medium, high or critical")
+		changed = append(changed, "severity")
+	}
+	if updateData.Latitude != nil {
+		v.Check(*updateData.Latitude >= -90 && *updateData.Latitude <= 90, "latitude", "must be between -90 and 90")
+		changed = append(changed, "latitude")
+	}
+	if updateData.Longitude != nil {
+		v.Check(*updateData.Longitude >= -180 && *updateData.Longitude <= 180, "longitude", "must be between -180 and 180")
+		changed = append(changed, "longitude")
+	}
+	v.Check(len(changed) > 0, "body", "must change at least one field")
+	if !v.Valid() {
+		v.WriteError(w)
 		return
 	}
 
-	if updateData.Severity != "low" && updateData.Severity != "medium" && updateData.Severity != "high" && updateData.Severity != "critical" {
-		http.Error(w, "Invalid severity level", http.StatusBadRequest)
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	// Check if user owns the report
-	var existingReporterID string
-	err := h.db.QueryRow("SELECT reporter_id FROM disaster_reports WHERE id = ?", reportID).Scan(&existingReporterID)
+	var reporterID, source string
+	var status ReportStatus
+	var current struct {
+		title, description, disasterType, severity string
+		latitude, longitude                        float64
+	}
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(reporter_id), source, status, title, description, disaster_type, severity, latitude, longitude
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
+		reportID,
+	).Scan(&reporterID, &source, &status, &current.title, &current.description, &current.disasterType,
+		&current.severity, &current.latitude, &current.longitude)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Report not found", http.StatusNotFound)
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if reporterID != userID {
+		role, err := middleware.LookupRole(h.db, userID)
+		if source != "feed" || err != nil || !role.Can(middleware.PermVerifyReports) {
+			apierror.Error(w, "Only the reporter can edit this report", http.StatusForbidden)
 			return
 		}
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
 	}
-
-	if existingReporterID != userID {
-		http.Error(w, "Unauthorized to update this report", http.StatusForbidden)
+	if status != ReportPending {
+		apierror.Error(w, "Only pending reports can be edited; post a comment to add news to a "+string(status)+" report",
+			http.StatusConflict)
 		return
 	}
 
-	// Update the report
-	_, err = h.db.Exec(`
-		UPDATE disaster_reports 
-		SET title = ?, description = ?, severity = ?, location = ?, latitude = ?, longitude = ?, updated_at = NOW()
-		WHERE id = ?
-	`, updateData.Title, updateData.Description, updateData.Severity, updateData.Location,
+	// The trigger recomputes location when the coordinates change
+	_, err = tx.Exec(`
+		UPDATE disaster_reports SET
+		title = COALESCE(?, title),
+		description = COALESCE(?, description),
+		disaster_type = COALESCE(?, disaster_type),
+		severity = COALESCE(?, severity),
+		latitude = COALESCE(?, latitude),
+		longitude = COALESCE(?, longitude),
+		updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?)
+	`, updateData.Title, updateData.Description, updateData.DisasterType, updateData.Severity,
 		updateData.Latitude, updateData.Longitude, reportID)
-
 	if err != nil {
-		http.Error(w, "Failed to update report", http.StatusInternalServerError)
+		apierror.Error(w, "Failed to update report", http.StatusInternalServerError)
+		return
+	}
+
+	changes := map[string]FieldChange{}
+	if updateData.Title != nil {
+		changes["title"] = FieldChange{current.title, *updateData.Title}
+	}
+	if updateData.Description != nil {
+		changes["description"] = FieldChange{current.description, *updateData.Description}
+	}
+	if updateData.DisasterType != nil {
+		changes["disasterType"] = FieldChange{current.disasterType, *updateData.DisasterType}
+	}
+	if updateData.Severity != nil {
+		changes["severity"] = FieldChange{current.severity, *updateData.Severity}
+	}
+	if updateData.Latitude != nil {
+		changes["latitude"] = FieldChange{current.latitude, *updateData.Latitude}
+	}
+	if updateData.Longitude != nil {
+		changes["longitude"] = FieldChange{current.longitude, *updateData.Longitude}
+	}
+	if err := recordRevision(tx, reportID, userID, changes, ""); err != nil {
+		apierror.Error(w, "Failed to update report", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Failed to update report", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportUpdated,
+		Severity:   audit.SeverityLow,
+		UserID:     userID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+		Details:    map[string]interface{}{"fields": changed},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      reportID,
+		"updated": changed,
 		"message": "Report updated successfully",
 	})
 }
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/triage"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// AttachUploads links the reporter's earlier uploads, such as photos sent by
+// a mobile background upload, to their report. Uploads already on the report
+// are skipped, so the request can be retried.
+func (h *ReportHandler) AttachUploads(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		UploadIDs []string `json:"uploadIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	v.Check(len(request.UploadIDs) > 0, "uploadIds", "must not be empty")
+	v.Check(len(request.UploadIDs) <= maxReportAttachments, "uploadIds", fmt.Sprintf("must hold at most %d uploads", maxReportAttachments))
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var reporterID string
+	var status ReportStatus
+	var before int
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(reporter_id), status,
+		(SELECT COUNT(*) FROM file_uploads f WHERE f.disaster_report_id = dr.id)
+		FROM disaster_reports dr WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
+		reportID,
+	).Scan(&reporterID, &status, &before)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if reporterID != userID {
+		apierror.Error(w, "Only the reporter can add attachments to this report", http.StatusForbidden)
+		return
+	}
+	if status.Finished() {
+		apierror.Error(w, "Attachments cannot be added to a "+string(status)+" report", http.StatusConflict)
+		return
+	}
+
+	attached, err := attachUploads(tx, reportID, userID, request.UploadIDs)
+	var problem attachmentError
+	if errors.As(err, &problem) {
+		v.AddError("uploadIds", problem.Error())
+		v.WriteError(w)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error attaching uploads", http.StatusInternalServerError)
+		return
+	}
+	if attached > 0 {
+		changes := map[string]FieldChange{"attachments": {before, before + attached}}
+		if err := recordRevision(tx, reportID, userID, changes, ""); err != nil {
+			apierror.Error(w, "Error attaching uploads", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error attaching uploads", http.StatusInternalServerError)
+		return
+	}
+
+	if attached > 0 {
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventReportUpdated,
+			Severity:   audit.SeverityLow,
+			UserID:     userID,
+			EntityType: "disaster_report",
+			EntityID:   reportID,
+			Details:    map[string]interface{}{"fields": []string{"attachments"}, "uploadIds": request.UploadIDs},
+		})
+		// Attachment count is one of the triage signals
+		if _, err := triage.Score(r.Context(), h.db, reportID); err != nil {
+			log.Printf("Failed to score report %s: %v", reportID, err)
+		}
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), filename, file_hash, file_size, mime_type, created_at
+		FROM file_uploads WHERE disaster_report_id = UUID_TO_BIN(?) ORDER BY created_at`,
+		reportID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching files", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	files := []File{}
+	for rows.Next() {
+		var file File
+		if err := rows.Scan(&file.ID, &file.Filename, &file.FileHash, &file.FileSize, &file.MimeType, &file.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing files", http.StatusInternalServerError)
+			return
+		}
+		files = append(files, file)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":       reportID,
+		"attached": attached,
+		"files":    files,
+	})
+}
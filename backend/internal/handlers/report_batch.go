@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/triage"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	maxBatchReports  = 50
+	maxBatchBodySize = 2 * 1024 * 1024 // 2MB, attachments travel as upload IDs
+	// Attachments per report, however they were added
+	maxReportAttachments = 10
+
+	// Offline reports older than this are too stale to file, and clocks
+	// this far ahead are trusted no further than now
+	maxBatchReportAge = 30 * 24 * time.Hour
+	batchClockSkew    = 5 * time.Minute
+)
+
+// Outcomes of one report in a batch
+const (
+	BatchCreated   = "created"
+	BatchUpdated   = "updated"
+	BatchUnchanged = "unchanged"
+	BatchFailed    = "failed"
+)
+
+// BatchReport is a report filed offline. ID is generated by the client, so
+// sending the same report again updates it instead of filing a copy.
+type BatchReport struct {
+	ID           string     `json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	DisasterType string     `json:"disasterType"`
+	Severity     string     `json:"severity"`
+	Latitude     *float64   `json:"latitude"`
+	Longitude    *float64   `json:"longitude"`
+	ReportedAt   *time.Time `json:"reportedAt"`
+	UploadIDs    []string   `json:"uploadIds"`
+}
+
+type BatchReportResult struct {
+	Index   int                     `json:"index"`
+	ID      string                  `json:"id"`
+	Result  string                  `json:"result"`
+	Errors  []validation.FieldError `json:"errors,omitempty"`
+	Message string                  `json:"message,omitempty"`
+}
+
+// attachmentError is a problem with an upload the client can fix
+type attachmentError string
+
+func (e attachmentError) Error() string { return string(e) }
+
+// SubmitReportBatch files or updates up to maxBatchReports reports collected
+// offline. Each report succeeds or fails on its own, and the response has a
+// result for every one in request order.
+func (h *ReportHandler) SubmitReportBatch(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+
+	var request struct {
+		Reports []BatchReport `json:"reports"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	v.Check(len(request.Reports) > 0, "reports", "must not be empty")
+	v.Check(len(request.Reports) <= maxBatchReports, "reports", fmt.Sprintf("must hold at most %d reports", maxBatchReports))
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	results := make([]BatchReportResult, len(request.Reports))
+	counts := map[string]int{}
+	seen := map[string]bool{}
+	for i, item := range request.Reports {
+		item.ID = strings.ToLower(strings.TrimSpace(item.ID))
+		if item.ID != "" && seen[item.ID] {
+			results[i] = BatchReportResult{ID: item.ID, Result: BatchFailed, Message: "The same id appears earlier in this batch"}
+		} else {
+			seen[item.ID] = true
+			results[i] = h.syncBatchReport(r, userID, item)
+		}
+		results[i].Index = i
+		counts[results[i].Result]++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results":   results,
+		"created":   counts[BatchCreated],
+		"updated":   counts[BatchUpdated],
+		"unchanged": counts[BatchUnchanged],
+		"failed":    counts[BatchFailed],
+	})
+}
+
+// syncBatchReport inserts a report under its client ID, or updates it while
+// it is still pending, in its own transaction
+func (h *ReportHandler) syncBatchReport(r *http.Request, userID string, item BatchReport) BatchReportResult {
+	result := BatchReportResult{ID: item.ID, Result: BatchFailed}
+	item.Title = strings.TrimSpace(item.Title)
+	item.Description = strings.TrimSpace(item.Description)
+	if item.DisasterType == "" {
+		item.DisasterType = defaultDisasterType
+	}
+	now := time.Now()
+	reportedAt := now
+	if item.ReportedAt != nil {
+		reportedAt = *item.ReportedAt
+	}
+
+	v := validation.New()
+	v.Check(uuidPattern.MatchString(item.ID), "id", "must be a client-generated UUID")
+	if v.Required("title", item.Title) {
+		v.Length("title", item.Title, 1, 255)
+	}
+	v.Required("description", item.Description)
+	_, ok := severityRanks[item.Severity]
+	v.Check(ok, "severity", "must be low, medium, high or critical")
+	v.Check(item.Latitude != nil && *item.Latitude >= -90 && *item.Latitude <= 90, "latitude", "must be between -90 and 90")
+	v.Check(item.Longitude != nil && *item.Longitude >= -180 && *item.Longitude <= 180, "longitude", "must be between -180 and 180")
+	if v.Valid() {
+		// Stored coordinates keep 8 decimals; rounding the same way keeps a
+		// resent report from looking moved
+		*item.Latitude = math.Round(*item.Latitude*1e8) / 1e8
+		*item.Longitude = math.Round(*item.Longitude*1e8) / 1e8
+	}
+	v.Check(!reportedAt.After(now.Add(batchClockSkew)), "reportedAt", "must not be in the future")
+	v.Check(reportedAt.After(now.Add(-maxBatchReportAge)), "reportedAt", "must be within the last 30 days")
+	v.Check(len(item.UploadIDs) <= maxReportAttachments, "uploadIds", fmt.Sprintf("must hold at most %d uploads", maxReportAttachments))
+	known, err := validDisasterType(h.db, item.DisasterType)
+	if err != nil {
+		result.Message = "Internal server error"
+		return result
+	}
+	v.Check(known, "disasterType", "is not a known disaster type; see GET /api/reports/types")
+	if !v.Valid() {
+		result.Errors = v.Errors
+		return result
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		result.Message = "Internal server error"
+		return result
+	}
+	defer tx.Rollback()
+
+	var reporterID string
+	var status ReportStatus
+	var deleted bool
+	var current struct {
+		title, description, disasterType, severity string
+		latitude, longitude                        float64
+	}
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(reporter_id), status, deleted_at IS NOT NULL,
+		title, description, disaster_type, severity, latitude, longitude
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?) FOR UPDATE`,
+		item.ID,
+	).Scan(&reporterID, &status, &deleted, &current.title, &current.description, &current.disasterType,
+		&current.severity, &current.latitude, &current.longitude)
+
+	outcome := BatchUnchanged
+	var changed []string
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec(
+			`INSERT INTO disaster_reports (id, reporter_id, title, description, disaster_type, latitude, longitude, severity, status, created_at)
+			VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?, 'pending', ?)`,
+			item.ID, userID, item.Title, item.Description, item.DisasterType,
+			*item.Latitude, *item.Longitude, item.Severity, reportedAt.UTC(),
+		)
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			result.Message = "The report was synced by another request at the same time; send it again"
+			return result
+		}
+		if err != nil {
+			result.Message = "Error creating report"
+			return result
+		}
+		outcome = BatchCreated
+	case err != nil:
+		result.Message = "Internal server error"
+		return result
+	case reporterID != userID:
+		result.Message = "The id belongs to another report; generate a new one"
+		return result
+	case deleted:
+		result.Message = "The report was deleted"
+		return result
+	case status != ReportPending:
+		result.Result = BatchUnchanged
+		result.Message = "The report is " + string(status) + " and can no longer be edited; post a comment to add news"
+		return result
+	default:
+		changes := map[string]FieldChange{
+			"title":        {current.title, item.Title},
+			"description":  {current.description, item.Description},
+			"disasterType": {current.disasterType, item.DisasterType},
+			"severity":     {current.severity, item.Severity},
+			"latitude":     {current.latitude, *item.Latitude},
+			"longitude":    {current.longitude, *item.Longitude},
+		}
+		for field, change := range changes {
+			if change.From != change.To {
+				changed = append(changed, field)
+			}
+		}
+		if len(changed) > 0 {
+			// The trigger recomputes location when the coordinates change
+			_, err = tx.Exec(
+				`UPDATE disaster_reports SET title = ?, description = ?, disaster_type = ?, severity = ?,
+				latitude = ?, longitude = ?, updated_at = NOW() WHERE id = UUID_TO_BIN(?)`,
+				item.Title, item.Description, item.DisasterType, item.Severity,
+				*item.Latitude, *item.Longitude, item.ID,
+			)
+			if err == nil {
+				err = recordRevision(tx, item.ID, userID, changes, "Synced from offline")
+			}
+			if err != nil {
+				result.Message = "Error updating report"
+				return result
+			}
+			outcome = BatchUpdated
+		}
+	}
+
+	attached, err := attachUploads(tx, item.ID, userID, item.UploadIDs)
+	var problem attachmentError
+	if errors.As(err, &problem) {
+		result.Errors = []validation.FieldError{{Field: "uploadIds", Message: problem.Error()}}
+		return result
+	}
+	if err != nil {
+		result.Message = "Error attaching uploads"
+		return result
+	}
+	if attached > 0 && outcome == BatchUnchanged {
+		outcome = BatchUpdated
+		changed = append(changed, "attachments")
+	}
+
+	if err := tx.Commit(); err != nil {
+		result.Message = "Error saving report"
+		return result
+	}
+	result.Result = outcome
+
+	switch outcome {
+	case BatchCreated:
+		h.webhooks.Publish(webhooks.EventReportCreated, reportWebhookRecipients(h.db, item.ID),
+			map[string]interface{}{
+				"id":           item.ID,
+				"title":        item.Title,
+				"disasterType": item.DisasterType,
+				"severity":     item.Severity,
+				"status":       "pending",
+			},
+		)
+		if _, err := h.flagDuplicates(item.ID); err != nil {
+			log.Printf("Failed to check report %s for duplicates: %v", item.ID, err)
+		}
+		if _, err := triage.ScoreNew(r.Context(), h.db, item.ID); err != nil {
+			log.Printf("Failed to score report %s: %v", item.ID, err)
+		}
+	case BatchUpdated:
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventReportUpdated,
+			Severity:   audit.SeverityLow,
+			UserID:     userID,
+			EntityType: "disaster_report",
+			EntityID:   item.ID,
+			Details:    map[string]interface{}{"fields": changed, "source": "batch"},
+		})
+		if _, err := triage.Score(r.Context(), h.db, item.ID); err != nil {
+			log.Printf("Failed to score report %s: %v", item.ID, err)
+		}
+	}
+	return result
+}
+
+// attachUploads copies the user's earlier uploads onto a report and returns
+// how many were new. Uploads already attached to the report are skipped, so
+// retrying is safe. Problems the client can fix come back as an
+// attachmentError.
+func attachUploads(tx *sql.Tx, reportID, userID string, uploadIDs []string) (int, error) {
+	if len(uploadIDs) == 0 {
+		return 0, nil
+	}
+
+	var count int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM file_uploads WHERE disaster_report_id = UUID_TO_BIN(?)",
+		reportID,
+	).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	attached := 0
+	for _, uploadID := range uploadIDs {
+		var exists bool
+		if err := tx.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM file_uploads WHERE disaster_report_id = UUID_TO_BIN(?) AND upload_id = ?)",
+			reportID, uploadID,
+		).Scan(&exists); err != nil {
+			return attached, err
+		}
+		if exists {
+			continue
+		}
+
+		var originalName, mimeType, path string
+		var size int64
+		err := tx.QueryRow(
+			"SELECT original_name, mime_type, size, path FROM uploads WHERE id = ? AND user_id = ?",
+			uploadID, userID,
+		).Scan(&originalName, &mimeType, &size, &path)
+		if err == sql.ErrNoRows {
+			return attached, attachmentError("upload " + uploadID + " was not found among your uploads")
+		}
+		if err != nil {
+			return attached, err
+		}
+		ext := strings.ToLower(filepath.Ext(originalName))
+		if ext == "" || !strings.Contains(allowedTypes, ext) {
+			return attached, attachmentError("upload " + uploadID + " is not a JPEG or PNG image")
+		}
+		if size > maxFileSize {
+			return attached, attachmentError("upload " + uploadID + " is larger than 5MB")
+		}
+		if count >= maxReportAttachments {
+			return attached, attachmentError(fmt.Sprintf("a report holds at most %d attachments", maxReportAttachments))
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return attached, attachmentError("upload " + uploadID + " is no longer available; upload it again")
+		}
+		err = storeReportFile(tx, reportID, userID, originalName, mimeType, size, file, uploadID)
+		file.Close()
+		if err != nil {
+			return attached, err
+		}
+		count++
+		attached++
+	}
+	return attached, nil
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/validation"
+)
+
+const (
+	maxClusterZoom      = 22
+	maxGeohashPrecision = 8
+
+	// A viewport is never split into more cells than this; wide boxes at a
+	// deep zoom get coarser cells instead
+	maxClusterCells = 2048
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// ReportCluster is the reports falling in one geohash cell. Latitude and
+// Longitude are their centroid, where the map draws the cluster; Bounds is the
+// cell itself, [minLon, minLat, maxLon, maxLat], to zoom into on click.
+type ReportCluster struct {
+	Geohash    string         `json:"geohash"`
+	Count      int            `json:"count"`
+	Latitude   float64        `json:"latitude"`
+	Longitude  float64        `json:"longitude"`
+	Bounds     [4]float64     `json:"bounds"`
+	Severity   string         `json:"severity"`
+	Severities map[string]int `json:"severities"`
+}
+
+// parseBBox reads "minLon,minLat,maxLon,maxLat". minLon may be greater than
+// maxLon for a box crossing the antimeridian.
+func parseBBox(raw string) ([4]float64, bool) {
+	var box [4]float64
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return box, false
+	}
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) {
+			return box, false
+		}
+		box[i] = value
+	}
+	if box[0] < -180 || box[2] > 180 || box[0] > 180 || box[2] < -180 {
+		return box, false
+	}
+	if box[1] < -90 || box[3] > 90 || box[1] > box[3] {
+		return box, false
+	}
+	return box, true
+}
+
+// geohashCellSize is the width and height in degrees of a cell at precision
+func geohashCellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 360 / math.Exp2(float64(lonBits)), 180 / math.Exp2(float64(latBits))
+}
+
+// clusterPrecision picks the geohash length for a zoom level, roughly one
+// character per two zoom levels, then shortens it until the box fits in
+// maxClusterCells cells
+func clusterPrecision(zoom int, box [4]float64) int {
+	width := box[2] - box[0]
+	if width < 0 {
+		width += 360
+	}
+	height := box[3] - box[1]
+
+	precision := min(max((zoom+1)/2, 1), maxGeohashPrecision)
+	for precision > 1 {
+		cellWidth, cellHeight := geohashCellSize(precision)
+		cells := (math.Floor(width/cellWidth) + 1) * (math.Floor(height/cellHeight) + 1)
+		if cells <= maxClusterCells {
+			break
+		}
+		precision--
+	}
+	return precision
+}
+
+// geohashBounds decodes a geohash into its cell, [minLon, minLat, maxLon, maxLat]
+func geohashBounds(hash string) [4]float64 {
+	minLon, maxLon := -180.0, 180.0
+	minLat, maxLat := -90.0, 90.0
+	even := true
+	for _, c := range hash {
+		index := strings.IndexRune(geohashAlphabet, c)
+		for bit := 4; bit >= 0; bit-- {
+			set := index>>bit&1 == 1
+			if even {
+				mid := (minLon + maxLon) / 2
+				if set {
+					minLon = mid
+				} else {
+					maxLon = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if set {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return [4]float64{minLon, minLat, maxLon, maxLat}
+}
+
+// ReportClusters counts the reports inside a bounding box per geohash cell,
+// so a map can draw clusters instead of thousands of markers. It takes the
+// status, severity, type and q filters of ListReports. Each cell carries its
+// dominant severity: the most common one, the more severe on a tie.
+func (h *ReportHandler) ReportClusters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := parseReportFilter(query)
+
+	v := validation.New()
+	var box [4]float64
+	if v.Required("bbox", query.Get("bbox")) {
+		var ok bool
+		box, ok = parseBBox(query.Get("bbox"))
+		v.Check(ok, "bbox", "must be minLon,minLat,maxLon,maxLat within -180..180 and -90..90")
+	}
+	var zoom int
+	if v.Required("zoom", query.Get("zoom")) {
+		var err error
+		zoom, err = strconv.Atoi(query.Get("zoom"))
+		v.Check(err == nil && zoom >= 0 && zoom <= maxClusterZoom, "zoom", fmt.Sprintf("must be an integer from 0 to %d", maxClusterZoom))
+	}
+	filter.validate(v)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	where, args, ok := h.reportFilterWhere(w, r, filter)
+	if !ok {
+		return
+	}
+	where += " AND latitude BETWEEN ? AND ?"
+	args = append(args, box[1], box[3])
+	if box[0] <= box[2] {
+		where += " AND longitude BETWEEN ? AND ?"
+	} else {
+		where += " AND (longitude >= ? OR longitude <= ?)"
+	}
+	args = append(args, box[0], box[2])
+
+	precision := clusterPrecision(zoom, box)
+	rows, err := h.db.Query(
+		`SELECT ST_GeoHash(longitude, latitude, ?) AS cell, severity, COUNT(*), SUM(latitude), SUM(longitude)
+		FROM disaster_reports`+where+` GROUP BY cell, severity`,
+		append([]interface{}{precision}, args...)...,
+	)
+	if err != nil {
+		apierror.Error(w, "Error clustering reports", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	clusters := map[string]*ReportCluster{}
+	sums := map[string][2]float64{}
+	total := 0
+	for rows.Next() {
+		var cell, severity string
+		var count int
+		var latitudes, longitudes float64
+		if err := rows.Scan(&cell, &severity, &count, &latitudes, &longitudes); err != nil {
+			apierror.Error(w, "Error processing clusters", http.StatusInternalServerError)
+			return
+		}
+		cluster, ok := clusters[cell]
+		if !ok {
+			cluster = &ReportCluster{Geohash: cell, Bounds: geohashBounds(cell), Severities: map[string]int{}}
+			clusters[cell] = cluster
+		}
+		cluster.Count += count
+		cluster.Severities[severity] = count
+		sum := sums[cell]
+		sums[cell] = [2]float64{sum[0] + latitudes, sum[1] + longitudes}
+		total += count
+	}
+
+	result := make([]ReportCluster, 0, len(clusters))
+	for cell, cluster := range clusters {
+		cluster.Latitude = sums[cell][0] / float64(cluster.Count)
+		cluster.Longitude = sums[cell][1] / float64(cluster.Count)
+		for severity, count := range cluster.Severities {
+			dominant := cluster.Severities[cluster.Severity]
+			if count > dominant || (count == dominant && severityRanks[severity] > severityRanks[cluster.Severity]) {
+				cluster.Severity = severity
+			}
+		}
+		result = append(result, *cluster)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Geohash < result[j].Geohash
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"precision": precision,
+		"total":     total,
+		"clusters":  result,
+	})
+}
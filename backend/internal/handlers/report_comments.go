@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxCommentLength = 2000
+	// Authors may correct an update for a while; after that it stands as posted
+	commentEditWindow = time.Hour
+)
+
+// ReportComment is a situation update posted under a report
+type ReportComment struct {
+	ID        string     `json:"id"`
+	ReportID  string     `json:"reportId"`
+	AuthorID  string     `json:"authorId"`
+	Author    Reporter   `json:"author"`
+	Body      string     `json:"body"`
+	EditedAt  *time.Time `json:"editedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+const reportCommentColumns = `BIN_TO_UUID(c.id), BIN_TO_UUID(c.report_id), BIN_TO_UUID(c.author_id),
+	u.username, u.avatar_url, c.body, c.edited_at, c.created_at
+	FROM report_comments c
+	JOIN users u ON u.id = c.author_id`
+
+func scanReportComment(row interface{ Scan(...interface{}) error }) (ReportComment, error) {
+	var c ReportComment
+	err := row.Scan(&c.ID, &c.ReportID, &c.AuthorID, &c.Author.Username, &c.Author.AvatarURL,
+		&c.Body, &c.EditedAt, &c.CreatedAt)
+	return c, err
+}
+
+func (h *ReportHandler) getComment(reportID, commentID string) (ReportComment, error) {
+	return scanReportComment(h.db.QueryRow(
+		"SELECT "+reportCommentColumns+`
+		WHERE c.id = UUID_TO_BIN(?) AND c.report_id = UUID_TO_BIN(?) AND c.deleted_at IS NULL`,
+		commentID, reportID,
+	))
+}
+
+// latestComment returns the newest update on a report, or nil if it has none
+func (h *ReportHandler) latestComment(reportID string) (*ReportComment, error) {
+	c, err := scanReportComment(h.db.QueryRow(
+		"SELECT "+reportCommentColumns+`
+		WHERE c.report_id = UUID_TO_BIN(?) AND c.deleted_at IS NULL
+		ORDER BY c.created_at DESC, c.id DESC LIMIT 1`,
+		reportID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func validateCommentBody(body string) *validation.Validator {
+	v := validation.New()
+	if v.Required("body", body) {
+		v.Length("body", body, 1, maxCommentLength)
+	}
+	return v
+}
+
+// CreateComment posts an update under a report
+func (h *ReportHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Body = strings.TrimSpace(request.Body)
+	if v := validateCommentBody(request.Body); !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	var commentID string
+	err := h.db.QueryRow(
+		`INSERT INTO report_comments (id, report_id, author_id, body)
+		SELECT UUID_TO_BIN(UUID()), id, UUID_TO_BIN(?), ? FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL
+		RETURNING BIN_TO_UUID(id)`,
+		userID, request.Body, reportID,
+	).Scan(&commentID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error posting comment", http.StatusInternalServerError)
+		return
+	}
+
+	comment, err := h.getComment(reportID, commentID)
+	if err != nil {
+		apierror.Error(w, "Error fetching comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// ListComments returns a report's updates oldest first, so the thread reads
+// in the order things happened
+func (h *ReportHandler) ListComments(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	limit := defaultActivityLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxActivityLimit {
+		limit = maxActivityLimit
+	}
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	var exists bool
+	if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL)", reportID).Scan(&exists); err != nil {
+		apierror.Error(w, "Error fetching comments", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := h.db.Query(
+		"SELECT "+reportCommentColumns+`
+		WHERE c.report_id = UUID_TO_BIN(?) AND c.deleted_at IS NULL
+		ORDER BY c.created_at, c.id
+		LIMIT ? OFFSET ?`,
+		reportID, limit+1, offset,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching comments", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	comments := []ReportComment{}
+	for rows.Next() {
+		c, err := scanReportComment(rows)
+		if err != nil {
+			apierror.Error(w, "Error processing comments", http.StatusInternalServerError)
+			return
+		}
+		comments = append(comments, c)
+	}
+
+	hasMore := len(comments) > limit
+	if hasMore {
+		comments = comments[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":   comments,
+		"limit":   limit,
+		"offset":  offset,
+		"hasMore": hasMore,
+	})
+}
+
+// UpdateComment lets the author correct an update within commentEditWindow
+// of posting it
+func (h *ReportHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	reportID, commentID := vars["id"], vars["commentId"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Body = strings.TrimSpace(request.Body)
+	if v := validateCommentBody(request.Body); !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	comment, err := h.getComment(reportID, commentID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching comment", http.StatusInternalServerError)
+		return
+	}
+	if comment.AuthorID != userID {
+		apierror.Error(w, "Only the author can edit a comment", http.StatusForbidden)
+		return
+	}
+	if time.Since(comment.CreatedAt) > commentEditWindow {
+		apierror.Error(w, "Comments can only be edited within an hour of posting", http.StatusConflict)
+		return
+	}
+
+	_, err = h.db.Exec(
+		"UPDATE report_comments SET body = ?, edited_at = NOW() WHERE id = UUID_TO_BIN(?)",
+		request.Body, commentID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error updating comment", http.StatusInternalServerError)
+		return
+	}
+
+	comment, err = h.getComment(reportID, commentID)
+	if err != nil {
+		apierror.Error(w, "Error fetching comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// DeleteComment removes an update. Authors can delete their own at any time;
+// verifiers and admins can remove anyone's, which is audit-logged.
+func (h *ReportHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	reportID, commentID := vars["id"], vars["commentId"]
+	userID := r.Context().Value("user_id").(string)
+
+	comment, err := h.getComment(reportID, commentID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching comment", http.StatusInternalServerError)
+		return
+	}
+	if comment.AuthorID != userID {
+		role, err := middleware.LookupRole(h.db, userID)
+		if err != nil {
+			apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !role.Can(middleware.PermVerifyReports) {
+			apierror.Error(w, "Only the author or a moderator can delete a comment", http.StatusForbidden)
+			return
+		}
+	}
+
+	_, err = h.db.Exec(
+		"UPDATE report_comments SET deleted_at = NOW(), deleted_by = UUID_TO_BIN(?) WHERE id = UUID_TO_BIN(?)",
+		userID, commentID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error deleting comment", http.StatusInternalServerError)
+		return
+	}
+
+	if comment.AuthorID != userID {
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventReportCommentRemoved,
+			Severity:   audit.SeverityMedium,
+			UserID:     userID,
+			EntityType: "report_comment",
+			EntityID:   commentID,
+			Details:    map[string]interface{}{"reportId": reportID, "authorId": comment.AuthorID},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Comment deleted"})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// DeletedReport is a soft-deleted report as admins see it
+type DeletedReport struct {
+	ID         string    `json:"id"`
+	ReporterID string    `json:"reporterId"`
+	Title      string    `json:"title"`
+	Status     string    `json:"status"`
+	DeletedBy  *string   `json:"deletedBy"`
+	DeletedAt  time.Time `json:"deletedAt"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// DeleteReport hides a report, along with its files, comments and
+// donations, everywhere it is listed. The row stays so an admin can restore
+// it. Reporters cannot delete a report that has received money; admins can.
+func (h *ReportHandler) DeleteReport(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	role, err := middleware.LookupRole(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	isAdmin := role.Can(middleware.PermAdminAccess)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var reporterID string
+	var status ReportStatus
+	err = tx.QueryRow(
+		"SELECT BIN_TO_UUID(reporter_id), status FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE",
+		reportID,
+	).Scan(&reporterID, &status)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if reporterID != userID && !isAdmin {
+		apierror.Write(w, http.StatusForbidden, apierror.CodeInsufficientPermission,
+			"Only the reporter or an admin can delete this report",
+			map[string]string{"permission": string(middleware.PermAdminAccess)})
+		return
+	}
+
+	var donations int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM donations WHERE disaster_report_id = UUID_TO_BIN(?) AND status = 'completed'",
+		reportID,
+	).Scan(&donations); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if donations > 0 && !isAdmin {
+		apierror.Error(w, "This report has received donations and can only be removed by an admin", http.StatusConflict)
+		return
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE disaster_reports SET deleted_at = NOW(), deleted_by = UUID_TO_BIN(?) WHERE id = UUID_TO_BIN(?)",
+		userID, reportID,
+	); err != nil {
+		apierror.Error(w, "Failed to delete report", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Failed to delete report", http.StatusInternalServerError)
+		return
+	}
+
+	severity := audit.SeverityLow
+	if reporterID != userID {
+		severity = audit.SeverityMedium
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportDeleted,
+		Severity:   severity,
+		UserID:     userID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+		Details:    map[string]interface{}{"reporterId": reporterID, "status": status, "completedDonations": donations},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Report deleted"})
+}
+
+// ListDeletedReports shows soft-deleted reports, most recently deleted first
+func (h *ReportHandler) ListDeletedReports(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= maxReportLimit {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(reporter_id), title, status,
+		BIN_TO_UUID(deleted_by), deleted_at, created_at
+		FROM disaster_reports WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+		LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching deleted reports", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	reports := []DeletedReport{}
+	for rows.Next() {
+		var report DeletedReport
+		if err := rows.Scan(&report.ID, &report.ReporterID, &report.Title, &report.Status,
+			&report.DeletedBy, &report.DeletedAt, &report.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing deleted reports", http.StatusInternalServerError)
+			return
+		}
+		reports = append(reports, report)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
+
+// RestoreReport brings a soft-deleted report back with the status it had
+func (h *ReportHandler) RestoreReport(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	result, err := h.db.Exec(
+		"UPDATE disaster_reports SET deleted_at = NULL, deleted_by = NULL WHERE id = UUID_TO_BIN(?) AND deleted_at IS NOT NULL",
+		reportID,
+	)
+	if err != nil {
+		apierror.Error(w, "Failed to restore report", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var exists bool
+		if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM disaster_reports WHERE id = UUID_TO_BIN(?))", reportID).Scan(&exists); err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			apierror.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+		apierror.Error(w, "Report is not deleted", http.StatusConflict)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportRestored,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Report restored"})
+}
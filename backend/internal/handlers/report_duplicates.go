@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/fx"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// A new report is flagged as a possible duplicate of reports of the same
+	// type filed this close to it, in space and in time
+	duplicateRadiusMeters = 5000
+	duplicateWindowHours  = 24
+
+	maxMergeDuplicates = 20
+)
+
+// DuplicateCandidate is an earlier report that may describe the same event
+type DuplicateCandidate struct {
+	ReportID   string    `json:"reportId"`
+	Title      string    `json:"title"`
+	Status     string    `json:"status"`
+	DistanceKm float64   `json:"distanceKm"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// flagDuplicates records earlier reports of the same type nearby as
+// duplicate candidates of a new report and returns them
+func (h *ReportHandler) flagDuplicates(reportID string) ([]DuplicateCandidate, error) {
+	_, err := h.db.Exec(
+		`INSERT IGNORE INTO report_duplicate_candidates (report_id, candidate_id, distance_m)
+		SELECT n.id, dr.id, ST_Distance_Sphere(dr.location, n.location)
+		FROM disaster_reports n
+		JOIN disaster_reports dr ON dr.id != n.id AND dr.disaster_type = n.disaster_type
+		AND dr.status != 'rejected' AND dr.deleted_at IS NULL
+		AND dr.created_at >= n.created_at - INTERVAL ? HOUR
+		AND ST_Distance_Sphere(dr.location, n.location) <= ?
+		WHERE n.id = UUID_TO_BIN(?)`,
+		duplicateWindowHours, duplicateRadiusMeters, reportID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return h.duplicateCandidates(reportID)
+}
+
+// duplicateCandidates lists the flagged candidates of a report that have
+// not been rejected or merged since, nearest first
+func (h *ReportHandler) duplicateCandidates(reportID string) ([]DuplicateCandidate, error) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(dr.id), dr.title, dr.status, c.distance_m, dr.created_at
+		FROM report_duplicate_candidates c
+		JOIN disaster_reports dr ON dr.id = c.candidate_id
+		WHERE c.report_id = UUID_TO_BIN(?) AND dr.status != 'rejected' AND dr.deleted_at IS NULL
+		ORDER BY c.distance_m, dr.created_at`,
+		reportID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []DuplicateCandidate{}
+	for rows.Next() {
+		var c DuplicateCandidate
+		var meters float64
+		if err := rows.Scan(&c.ReportID, &c.Title, &c.Status, &meters, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		c.DistanceKm = math.Round(meters/10) / 100
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// ListDuplicates shows the reports a report was flagged as possibly duplicating
+func (h *ReportHandler) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	candidates, err := h.duplicateCandidates(reportID)
+	if err != nil {
+		apierror.Error(w, "Error fetching duplicate candidates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candidates)
+}
+
+// MergeReports folds duplicate reports into the report in the URL. Their
+// donations, files, comments and volunteer requests move to it, and each
+// duplicate is rejected with reason duplicate and a pointer to the survivor.
+func (h *ReportHandler) MergeReports(w http.ResponseWriter, r *http.Request) {
+	targetID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		DuplicateIDs []string `json:"duplicateIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	v.Check(len(request.DuplicateIDs) > 0, "duplicateIds", "is required")
+	v.Check(len(request.DuplicateIDs) <= maxMergeDuplicates, "duplicateIds", "can list at most 20 reports")
+	seen := map[string]bool{}
+	for _, id := range request.DuplicateIDs {
+		v.Check(id != targetID, "duplicateIds", "cannot include the report being merged into")
+		v.Check(!seen[id], "duplicateIds", "must not repeat a report")
+		seen[id] = true
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var targetStatus ReportStatus
+	err = tx.QueryRow("SELECT status FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE", targetID).Scan(&targetStatus)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if targetStatus == ReportRejected {
+		apierror.Error(w, "Cannot merge into a rejected report", http.StatusConflict)
+		return
+	}
+
+	moved := map[string]int64{}
+	for _, duplicateID := range request.DuplicateIDs {
+		var status ReportStatus
+		err := tx.QueryRow("SELECT status FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE", duplicateID).Scan(&status)
+		if err == sql.ErrNoRows {
+			apierror.Error(w, "Report "+duplicateID+" not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if status == ReportRejected {
+			apierror.Error(w, "Report "+duplicateID+" is already rejected or merged", http.StatusConflict)
+			return
+		}
+
+		// Volunteers already asked to help with the survivor keep that
+		// request, and followers of both reports follow the survivor once
+		for table, statement := range map[string]string{
+			"donations":         "UPDATE donations SET disaster_report_id = UUID_TO_BIN(?) WHERE disaster_report_id = UUID_TO_BIN(?)",
+			"files":             "UPDATE file_uploads SET disaster_report_id = UUID_TO_BIN(?) WHERE disaster_report_id = UUID_TO_BIN(?)",
+			"comments":          "UPDATE report_comments SET report_id = UUID_TO_BIN(?) WHERE report_id = UUID_TO_BIN(?)",
+			"volunteerRequests": "UPDATE IGNORE volunteer_requests SET disaster_report_id = UUID_TO_BIN(?) WHERE disaster_report_id = UUID_TO_BIN(?)",
+			"subscriptions":     "UPDATE IGNORE report_subscriptions SET report_id = UUID_TO_BIN(?) WHERE report_id = UUID_TO_BIN(?)",
+			"needs":             "UPDATE report_needs SET report_id = UUID_TO_BIN(?) WHERE report_id = UUID_TO_BIN(?)",
+		} {
+			result, err := tx.Exec(statement, targetID, duplicateID)
+			if err != nil {
+				apierror.Error(w, "Error merging reports", http.StatusInternalServerError)
+				return
+			}
+			n, _ := result.RowsAffected()
+			moved[table] += n
+		}
+
+		_, err = tx.Exec(
+			`UPDATE disaster_reports SET status = 'rejected', merged_into = UUID_TO_BIN(?),
+			rejection_reason = 'duplicate', rejection_note = ?, rejected_by = UUID_TO_BIN(?), rejected_at = NOW(), updated_at = NOW()
+			WHERE id = UUID_TO_BIN(?)`,
+			targetID, "Merged into report "+targetID, userID, duplicateID,
+		)
+		if err != nil {
+			apierror.Error(w, "Error merging reports", http.StatusInternalServerError)
+			return
+		}
+		// Its donations now count toward the survivor
+		if err := fx.RecountReport(r.Context(), tx, duplicateID); err != nil {
+			apierror.Error(w, "Error merging reports", http.StatusInternalServerError)
+			return
+		}
+		if err := recordRevision(tx, duplicateID, userID,
+			map[string]FieldChange{"status": {status, ReportRejected}}, "Merged into report "+targetID); err != nil {
+			apierror.Error(w, "Error merging reports", http.StatusInternalServerError)
+			return
+		}
+		_, err = tx.Exec(
+			"DELETE FROM report_duplicate_candidates WHERE report_id = UUID_TO_BIN(?) OR candidate_id = UUID_TO_BIN(?)",
+			duplicateID, duplicateID,
+		)
+		if err != nil {
+			apierror.Error(w, "Error merging reports", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := fx.RecountReport(r.Context(), tx, targetID); err != nil {
+		apierror.Error(w, "Error merging reports", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error merging reports", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportsMerged,
+		Severity:   audit.SeverityHigh,
+		UserID:     userID,
+		EntityType: "disaster_report",
+		EntityID:   targetID,
+		Details:    map[string]interface{}{"duplicateIds": request.DuplicateIDs, "moved": moved},
+	})
+	for _, duplicateID := range request.DuplicateIDs {
+		h.alerts.ReportRejected(duplicateID, reportRejectionReasons["duplicate"], "Merged into report "+targetID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":           targetID,
+		"duplicateIds": request.DuplicateIDs,
+		"moved":        moved,
+		"message":      "Reports merged",
+	})
+}
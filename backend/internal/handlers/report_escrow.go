@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultEscrowApprovals is how many donation managers approve a release
+// unless configured otherwise
+const defaultEscrowApprovals = 2
+
+// Escrow release statuses
+const (
+	EscrowPending   = "pending"
+	EscrowReleased  = "released"
+	EscrowCancelled = "cancelled"
+)
+
+// EscrowRelease is a release of a report's held donations to the
+// organization that filed it. It is paid out once enough different donation
+// managers have approved it.
+type EscrowRelease struct {
+	ID             string           `json:"id"`
+	ReportID       string           `json:"reportId"`
+	OrganizationID string           `json:"organizationId"`
+	Amount         float64          `json:"amount"`
+	Currency       string           `json:"currency"`
+	Note           *string          `json:"note"`
+	Status         string           `json:"status"`
+	RequestedBy    string           `json:"requestedBy"`
+	Approvals      []EscrowApproval `json:"approvals"`
+	Required       int              `json:"requiredApprovals"`
+	ReleasedAt     *time.Time       `json:"releasedAt"`
+	CreatedAt      time.Time        `json:"createdAt"`
+}
+
+type EscrowApproval struct {
+	ApproverID string    `json:"approverId"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// SetEscrowApprovals changes how many donation managers must approve a
+// release; zero or less keeps the default
+func (h *ReportHandler) SetEscrowApprovals(required int) {
+	if required > 0 {
+		h.escrowApprovals = required
+	}
+}
+
+// reportFunds is what a report has raised and what has left it, either
+// disbursed or released from escrow to its organization, all in rupiah
+type reportFunds struct {
+	Raised    float64
+	Disbursed float64
+	Released  float64
+	// Pending is asked for by the report's pending escrow release
+	Pending float64
+}
+
+// Held is what can still be released or disbursed
+func (f reportFunds) Held() float64 {
+	return f.Raised - f.Disbursed - f.Released
+}
+
+// loadReportFunds totals a report's amount raised, disbursements and
+// escrow releases
+func loadReportFunds(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, reportID string) (reportFunds, error) {
+	var f reportFunds
+	err := q.QueryRow(
+		`SELECT dr.amount_raised,
+		(SELECT COALESCE(SUM(amount), 0) FROM disbursement_allocations WHERE report_id = dr.id),
+		(SELECT COALESCE(SUM(amount), 0) FROM escrow_releases WHERE report_id = dr.id AND status = 'released'),
+		(SELECT COALESCE(SUM(amount), 0) FROM escrow_releases WHERE report_id = dr.id AND status = 'pending')
+		FROM disaster_reports dr WHERE dr.id = UUID_TO_BIN(?)`,
+		reportID,
+	).Scan(&f.Raised, &f.Disbursed, &f.Released, &f.Pending)
+	return f, err
+}
+
+// GetEscrow shows how much of a report's completed donations is held, how
+// much has been released to its organization, and the pending release if
+// any. Donation managers and the organization's finance staff can see it.
+func (h *ReportHandler) GetEscrow(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var orgID sql.NullString
+	err := h.db.QueryRow(
+		"SELECT BIN_TO_UUID(organization_id) FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL",
+		reportID,
+	).Scan(&orgID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching report", http.StatusInternalServerError)
+		return
+	}
+	allowed, err := h.canViewEscrow(userID, orgID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		apierror.Error(w, "Only donation managers and the organization's finance staff can view escrow", http.StatusForbidden)
+		return
+	}
+
+	funds, err := loadReportFunds(h.db, reportID)
+	if err != nil {
+		apierror.Error(w, "Error fetching escrow", http.StatusInternalServerError)
+		return
+	}
+	releases, err := h.escrowReleases(h.db, reportID, "")
+	if err != nil {
+		apierror.Error(w, "Error fetching escrow releases", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reportId":          reportID,
+		"organizationId":    orgID.String,
+		"currency":          "IDR",
+		"amountRaised":      funds.Raised,
+		"disbursed":         funds.Disbursed,
+		"released":          funds.Released,
+		"pendingRelease":    funds.Pending,
+		"held":              funds.Held(),
+		"requiredApprovals": h.escrowApprovals,
+		"releases":          releases,
+	})
+}
+
+func (h *ReportHandler) canViewEscrow(userID string, orgID sql.NullString) (bool, error) {
+	role, err := middleware.LookupRole(h.db, userID)
+	if err != nil {
+		return false, err
+	}
+	if role.Can(middleware.PermManageDonations) {
+		return true, nil
+	}
+	if !orgID.Valid {
+		return false, nil
+	}
+	return hasOrganizationPermission(h.db, orgID.String, userID, OrgPermManageDisbursements)
+}
+
+// ReleaseEscrow starts or approves the release of a report's held
+// donations to the verified organization that filed it. The first donation
+// manager names the amount, which counts as their approval; each further
+// call by a different manager approves the same pending release, and it is
+// released once the required number have. Only one release per report can
+// be pending, and it can never exceed what is held.
+func (h *ReportHandler) ReleaseEscrow(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Amount *float64 `json:"amount"`
+		Note   string   `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Note = strings.TrimSpace(request.Note)
+	v := validation.New()
+	if request.Amount != nil {
+		amount := *request.Amount
+		v.Check(amount > 0 && amount <= maxGoalAmount, "amount", "must be greater than 0 and at most 9999999999999.99")
+		v.Check(math.Abs(amount*100-math.Round(amount*100)) < 1e-6, "amount", "IDR amounts can have at most 2 decimals")
+	}
+	v.Length("note", request.Note, 0, 500)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the report serializes approvals of its release
+	var orgID sql.NullString
+	var orgStatus sql.NullString
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(dr.organization_id), o.verification_status
+		FROM disaster_reports dr LEFT JOIN organizations o ON o.id = dr.organization_id
+		WHERE dr.id = UUID_TO_BIN(?) AND dr.deleted_at IS NULL FOR UPDATE`,
+		reportID,
+	).Scan(&orgID, &orgStatus)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching report", http.StatusInternalServerError)
+		return
+	}
+	if !orgID.Valid || orgStatus.String != "verified" {
+		apierror.Error(w, "Funds are only released to the verified organization that filed the report", http.StatusConflict)
+		return
+	}
+
+	pending, err := h.escrowReleases(tx, reportID, EscrowPending)
+	if err != nil {
+		apierror.Error(w, "Error fetching escrow releases", http.StatusInternalServerError)
+		return
+	}
+	var release EscrowRelease
+	if len(pending) == 0 {
+		if request.Amount == nil {
+			v.AddError("amount", "is required to start a release")
+			v.WriteError(w)
+			return
+		}
+		if !h.checkEscrowHeld(w, tx, reportID, *request.Amount) {
+			return
+		}
+		var note interface{}
+		if request.Note != "" {
+			note = request.Note
+		}
+		var releaseID string
+		if err := tx.QueryRow(
+			`INSERT INTO escrow_releases (id, report_id, organization_id, amount, note, requested_by)
+			VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, UUID_TO_BIN(?))
+			RETURNING BIN_TO_UUID(id)`,
+			reportID, orgID.String, *request.Amount, note, userID,
+		).Scan(&releaseID); err != nil {
+			apierror.Error(w, "Error requesting release", http.StatusInternalServerError)
+			return
+		}
+		release = EscrowRelease{
+			ID: releaseID, ReportID: reportID, OrganizationID: orgID.String, Amount: *request.Amount,
+			Currency: "IDR", Status: EscrowPending, RequestedBy: userID, Approvals: []EscrowApproval{},
+			CreatedAt: time.Now(),
+		}
+		if request.Note != "" {
+			release.Note = &request.Note
+		}
+	} else {
+		release = pending[0]
+		if request.Amount != nil && math.Abs(*request.Amount-release.Amount) > 1e-6 {
+			apierror.Write(w, http.StatusConflict, apierror.CodeConflict,
+				"Another release is pending; approve it or cancel it first",
+				map[string]interface{}{"pendingRelease": release})
+			return
+		}
+		for _, approval := range release.Approvals {
+			if approval.ApproverID == userID {
+				apierror.Write(w, http.StatusConflict, apierror.CodeConflict,
+					"You already approved this release; another donation manager must approve it",
+					map[string]interface{}{"pendingRelease": release})
+				return
+			}
+		}
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO escrow_release_approvals (release_id, approver_id) VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?))`,
+		release.ID, userID,
+	); err != nil {
+		apierror.Error(w, "Error approving release", http.StatusInternalServerError)
+		return
+	}
+	release.Approvals = append(release.Approvals, EscrowApproval{ApproverID: userID, CreatedAt: time.Now()})
+	release.Required = h.escrowApprovals
+
+	if len(release.Approvals) >= h.escrowApprovals {
+		// Refunds since the release was requested may have cut what is held
+		if !h.checkEscrowHeld(w, tx, reportID, release.Amount) {
+			return
+		}
+		if _, err := tx.Exec(
+			"UPDATE escrow_releases SET status = 'released', released_at = NOW() WHERE id = UUID_TO_BIN(?)",
+			release.ID,
+		); err != nil {
+			apierror.Error(w, "Error releasing funds", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		release.Status = EscrowReleased
+		release.ReleasedAt = &now
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error approving release", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventEscrowReleaseApproved,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "escrow_release",
+		EntityID:   release.ID,
+		Details: map[string]interface{}{
+			"reportId":  reportID,
+			"amount":    release.Amount,
+			"approvals": len(release.Approvals),
+			"required":  h.escrowApprovals,
+		},
+	})
+	if release.Status == EscrowReleased {
+		approvers := make([]string, len(release.Approvals))
+		for i, approval := range release.Approvals {
+			approvers[i] = approval.ApproverID
+		}
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventEscrowReleased,
+			Severity:   audit.SeverityHigh,
+			UserID:     userID,
+			EntityType: "escrow_release",
+			EntityID:   release.ID,
+			Details: map[string]interface{}{
+				"reportId":       reportID,
+				"organizationId": release.OrganizationID,
+				"amount":         release.Amount,
+				"approvers":      approvers,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(release)
+}
+
+// checkEscrowHeld writes a 409 and returns false unless the report holds at
+// least amount once disbursements and earlier releases are taken out. A
+// report has at most one pending release, the one being checked if any, so
+// pending amounts are not subtracted.
+func (h *ReportHandler) checkEscrowHeld(w http.ResponseWriter, tx *sql.Tx, reportID string, amount float64) bool {
+	funds, err := loadReportFunds(tx, reportID)
+	if err != nil {
+		apierror.Error(w, "Error fetching escrow", http.StatusInternalServerError)
+		return false
+	}
+	if available := funds.Held(); amount > available+1e-6 {
+		apierror.Write(w, http.StatusConflict, apierror.CodeConflict,
+			"The release is more than the report holds",
+			map[string]interface{}{"available": math.Max(available, 0)})
+		return false
+	}
+	return true
+}
+
+// CancelEscrowRelease drops the report's pending release so a different
+// amount can be requested
+func (h *ReportHandler) CancelEscrowRelease(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var releaseID string
+	var amount float64
+	err := h.db.QueryRow(
+		"SELECT BIN_TO_UUID(id), amount FROM escrow_releases WHERE report_id = UUID_TO_BIN(?) AND status = 'pending'",
+		reportID,
+	).Scan(&releaseID, &amount)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "No release is pending for this report", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching escrow releases", http.StatusInternalServerError)
+		return
+	}
+	result, err := h.db.Exec(
+		"UPDATE escrow_releases SET status = 'cancelled', cancelled_by = UUID_TO_BIN(?) WHERE id = UUID_TO_BIN(?) AND status = 'pending'",
+		userID, releaseID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error cancelling release", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		apierror.Error(w, "The release is no longer pending", http.StatusConflict)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventEscrowReleaseCancelled,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "escrow_release",
+		EntityID:   releaseID,
+		Details:    map[string]interface{}{"reportId": reportID, "amount": amount},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Release cancelled"})
+}
+
+// escrowReleases loads a report's releases with their approvals, newest
+// first, only those in status if given
+func (h *ReportHandler) escrowReleases(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, reportID, status string) ([]EscrowRelease, error) {
+	rows, err := q.Query(
+		`SELECT BIN_TO_UUID(er.id), BIN_TO_UUID(er.organization_id), er.amount, er.note, er.status,
+		BIN_TO_UUID(er.requested_by), er.released_at, er.created_at,
+		BIN_TO_UUID(a.approver_id), a.created_at
+		FROM escrow_releases er LEFT JOIN escrow_release_approvals a ON a.release_id = er.id
+		WHERE er.report_id = UUID_TO_BIN(?) AND (? = '' OR er.status = ?)
+		ORDER BY er.created_at DESC, er.id, a.created_at`,
+		reportID, status, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []EscrowRelease{}
+	for rows.Next() {
+		var release EscrowRelease
+		var approverID *string
+		var approvedAt *time.Time
+		if err := rows.Scan(&release.ID, &release.OrganizationID, &release.Amount, &release.Note, &release.Status,
+			&release.RequestedBy, &release.ReleasedAt, &release.CreatedAt, &approverID, &approvedAt); err != nil {
+			return nil, err
+		}
+		if n := len(releases); n == 0 || releases[n-1].ID != release.ID {
+			release.ReportID = reportID
+			release.Currency = "IDR"
+			release.Required = h.escrowApprovals
+			release.Approvals = []EscrowApproval{}
+			releases = append(releases, release)
+		}
+		if approverID != nil {
+			last := &releases[len(releases)-1]
+			last.Approvals = append(last.Approvals, EscrowApproval{ApproverID: *approverID, CreatedAt: *approvedAt})
+		}
+	}
+	return releases, rows.Err()
+}
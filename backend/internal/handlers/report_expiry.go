@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/audit"
+	"saferelief/internal/webhooks"
+)
+
+const (
+	defaultPendingTTLDays         = 30
+	defaultResolvedInactivityDays = 14
+
+	// Reports closed per status in one run; the rest wait for the next tick
+	maxExpiredPerRun = 200
+)
+
+// ReportExpiry is when the system closes reports nobody acted on. A zero
+// day count turns that half off.
+type ReportExpiry struct {
+	Enabled bool
+	// Pending reports with no edit or verifier vote for this many days are
+	// rejected as expired
+	PendingDays int
+	// Resolved reports with no edit or comment for this many days are closed
+	ResolvedDays int
+}
+
+// ParseReportExpiry reads REPORT_AUTO_CLOSE, REPORT_PENDING_TTL_DAYS and
+// REPORT_RESOLVED_INACTIVITY_DAYS. Auto-closing is off unless enabled is a
+// true value; empty day counts take the defaults.
+func ParseReportExpiry(enabled, pendingDays, resolvedDays string) (ReportExpiry, error) {
+	expiry := ReportExpiry{PendingDays: defaultPendingTTLDays, ResolvedDays: defaultResolvedInactivityDays}
+	if strings.TrimSpace(enabled) != "" {
+		on, err := strconv.ParseBool(strings.TrimSpace(enabled))
+		if err != nil {
+			return expiry, fmt.Errorf("REPORT_AUTO_CLOSE must be true or false, got %q", enabled)
+		}
+		expiry.Enabled = on
+	}
+	for name, field := range map[string]struct {
+		raw  string
+		dest *int
+	}{
+		"REPORT_PENDING_TTL_DAYS":         {pendingDays, &expiry.PendingDays},
+		"REPORT_RESOLVED_INACTIVITY_DAYS": {resolvedDays, &expiry.ResolvedDays},
+	} {
+		if strings.TrimSpace(field.raw) == "" {
+			continue
+		}
+		days, err := strconv.Atoi(strings.TrimSpace(field.raw))
+		if err != nil || days < 0 {
+			return expiry, fmt.Errorf("%s must be a whole number of days, got %q", name, field.raw)
+		}
+		*field.dest = days
+	}
+	return expiry, nil
+}
+
+// StartExpiry closes stale reports every interval until the process exits.
+// It does nothing when expiry is not enabled.
+func (h *ReportHandler) StartExpiry(expiry ReportExpiry, interval time.Duration) {
+	if !expiry.Enabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if expiry.PendingDays > 0 {
+				h.expireReports(ReportPending, expiry.PendingDays)
+			}
+			if expiry.ResolvedDays > 0 {
+				h.expireReports(ReportResolved, expiry.ResolvedDays)
+			}
+		}
+	}()
+}
+
+// expireReports moves reports idle in status for days on: pending reports
+// are rejected as expired, so an admin can still send them back to pending,
+// and resolved reports are closed. Each move is a system transition with
+// its own revision, audit entry, webhook and emails.
+func (h *ReportHandler) expireReports(from ReportStatus, days int) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(dr.id) FROM disaster_reports dr
+		WHERE dr.status = ? AND dr.deleted_at IS NULL
+		AND dr.updated_at < NOW() - INTERVAL ? DAY
+		AND NOT EXISTS (SELECT 1 FROM report_comments c
+			WHERE c.report_id = dr.id AND c.deleted_at IS NULL AND c.created_at >= NOW() - INTERVAL ? DAY)
+		AND NOT EXISTS (SELECT 1 FROM report_verification_votes v
+			WHERE v.report_id = dr.id AND v.round = dr.verification_round AND v.created_at >= NOW() - INTERVAL ? DAY)
+		ORDER BY dr.updated_at LIMIT ?`,
+		from, days, days, days, maxExpiredPerRun,
+	)
+	if err != nil {
+		log.Printf("Failed to find stale %s reports: %v", from, err)
+		return
+	}
+	var stale []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+
+	change := statusChange{To: ReportClosed, Note: fmt.Sprintf("Closed after %d days without activity", days)}
+	if from == ReportPending {
+		change = statusChange{To: ReportRejected, Note: fmt.Sprintf("Not verified within %d days", days), Reason: expiredRejectionReason}
+	}
+	for _, reportID := range stale {
+		if err := commitStatusChange(h.db, reportID, "", from, change); err == errStatusChanged {
+			// Someone acted on it since the query; it is no longer stale
+			continue
+		} else if err != nil {
+			log.Printf("Failed to auto-close report %s: %v", reportID, err)
+			continue
+		}
+
+		details := map[string]interface{}{"from": from, "to": change.To, "idleDays": days}
+		if change.Reason != "" {
+			details["reason"] = change.Reason
+		}
+		h.auditLogger.Log(nil, audit.Event{
+			Type:       audit.EventReportAutoClosed,
+			Severity:   audit.SeverityMedium,
+			EntityType: "disaster_report",
+			EntityID:   reportID,
+			Details:    details,
+		})
+		h.webhooks.Publish(webhooks.EventReportStatusChanged, reportWebhookRecipients(h.db, reportID), map[string]interface{}{
+			"id":             reportID,
+			"previousStatus": from,
+			"status":         change.To,
+			"changedBy":      nil,
+		})
+		h.alerts.ReportAutoClosed(reportID, string(from), days)
+		h.alerts.ReportStatusChanged(reportID, string(change.To), change.Note)
+	}
+}
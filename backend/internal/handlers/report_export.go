@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/validation"
+)
+
+const (
+	// Exports larger than this are cut off; narrow the filters to get the rest
+	maxReportExportRows = 10000
+	reportExportFlush   = 500
+)
+
+var reportExportHeader = []string{
+	"id", "title", "description", "disaster_type", "severity", "status",
+	"latitude", "longitude", "verified_at", "created_at", "updated_at",
+}
+
+// ExportReports streams the reports matching the ListReports filters as
+// CSV, newest first. X-Total-Count carries how many matched and
+// X-Export-Truncated is set when that is more than the export holds.
+func (h *ReportHandler) ExportReports(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	filter := parseReportFilter(query)
+
+	v := validation.New()
+	v.Check(format == "csv", "format", "must be csv")
+	filter.validate(v)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	where, args, ok := h.reportFilterWhere(w, r, filter)
+	if !ok {
+		return
+	}
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM disaster_reports"+where, args...).Scan(&total); err != nil {
+		apierror.Error(w, "Error exporting reports", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), title, description, disaster_type, severity, status,
+		latitude, longitude, verified_at, created_at, updated_at
+		FROM disaster_reports`+where+` ORDER BY created_at DESC, id DESC LIMIT ?`,
+		append(args, maxReportExportRows)...,
+	)
+	if err != nil {
+		apierror.Error(w, "Error exporting reports", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	userID := r.Context().Value("user_id").(string)
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportsExported,
+		Severity:   audit.SeverityLow,
+		UserID:     userID,
+		EntityType: "disaster_report",
+		Details: map[string]interface{}{
+			"format":   format,
+			"status":   filter.Status,
+			"severity": filter.Severity,
+			"type":     filter.DisasterType,
+			"q":        filter.Search,
+			"rows":     min(total, maxReportExportRows),
+			"matched":  total,
+		},
+	})
+
+	filename := "reports-" + time.Now().UTC().Format("20060102T150405Z") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if total > maxReportExportRows {
+		w.Header().Set("X-Export-Truncated", "true")
+	}
+
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Write(reportExportHeader)
+	flusher, _ := w.(http.Flusher)
+	for n := 1; rows.Next(); n++ {
+		var id, title, description, disasterType, severity, status string
+		var latitude, longitude float64
+		var verifiedAt sql.NullTime
+		var createdAt, updatedAt time.Time
+		if err := rows.Scan(&id, &title, &description, &disasterType, &severity, &status,
+			&latitude, &longitude, &verifiedAt, &createdAt, &updatedAt); err != nil {
+			// Headers are already sent, so the best we can do is cut the stream short
+			csvWriter.Flush()
+			return
+		}
+		verified := ""
+		if verifiedAt.Valid {
+			verified = verifiedAt.Time.UTC().Format(time.RFC3339)
+		}
+		csvWriter.Write([]string{
+			id, csvCell(title), csvCell(description), disasterType, severity, status,
+			strconv.FormatFloat(latitude, 'f', -1, 64), strconv.FormatFloat(longitude, 'f', -1, 64),
+			verified, createdAt.UTC().Format(time.RFC3339), updatedAt.UTC().Format(time.RFC3339),
+		})
+		if n%reportExportFlush == 0 {
+			csvWriter.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	csvWriter.Flush()
+}
+
+// csvCell stops spreadsheets from running reporter-written text as a formula
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
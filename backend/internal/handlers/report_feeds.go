@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"saferelief/internal/audit"
+	"saferelief/internal/feeds"
+	"saferelief/internal/triage"
+	"saferelief/internal/webhooks"
+)
+
+const (
+	// Alerts from different agencies are the same event when they are this
+	// close in place and time; origin times and epicentres differ a little
+	// between agencies
+	feedMatchRadiusMeters = 100000
+	feedMatchWindow       = 10 * time.Minute
+	// Alerts older than this when first read are history, not news
+	maxFeedAlertAge = 24 * time.Hour
+)
+
+// StartFeeds polls each feed every interval and files new alerts that pass
+// filter as pending reports under reporterID, the account that stands for
+// the feeds. It does nothing without feeds.
+func (h *ReportHandler) StartFeeds(sources []feeds.Feed, filter feeds.Filter, reporterID string, interval time.Duration) {
+	if len(sources) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for _, source := range sources {
+				h.ingestFeed(source, filter, reporterID)
+			}
+		}
+	}()
+}
+
+func (h *ReportHandler) ingestFeed(source feeds.Feed, filter feeds.Filter, reporterID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	alerts, err := source.Fetch(ctx)
+	if err != nil {
+		log.Printf("Failed to read %s feed: %v", source.Name(), err)
+		return
+	}
+	for _, alert := range alerts {
+		if !filter.Allows(alert) || time.Since(alert.OccurredAt) > maxFeedAlertAge {
+			continue
+		}
+		reportID, created, err := h.ingestAlert(ctx, source.Name(), alert, reporterID)
+		if err != nil {
+			log.Printf("Failed to file %s alert %s: %v", source.Name(), alert.ID, err)
+			continue
+		}
+		if !created {
+			continue
+		}
+
+		severity := feeds.Severity(alert)
+		h.auditLogger.Log(nil, audit.Event{
+			Type:       audit.EventReportIngested,
+			Severity:   audit.SeverityLow,
+			UserID:     reporterID,
+			EntityType: "disaster_report",
+			EntityID:   reportID,
+			Details:    map[string]interface{}{"feed": source.Name(), "alertId": alert.ID, "magnitude": alert.Magnitude},
+		})
+		h.webhooks.Publish(webhooks.EventReportCreated, reportWebhookRecipients(h.db, reportID),
+			map[string]interface{}{
+				"id":           reportID,
+				"title":        alert.Title,
+				"disasterType": alert.Type,
+				"severity":     severity,
+				"status":       "pending",
+				"source":       "feed",
+			},
+		)
+		if _, err := h.flagDuplicates(reportID); err != nil {
+			log.Printf("Failed to check report %s for duplicates: %v", reportID, err)
+		}
+		if _, err := triage.ScoreNew(ctx, h.db, reportID); err != nil {
+			log.Printf("Failed to score report %s: %v", reportID, err)
+		}
+	}
+}
+
+// ingestAlert files alert as a pending report unless it was read before.
+// An alert another feed already reported is linked to that report instead,
+// and created is false.
+func (h *ReportHandler) ingestAlert(ctx context.Context, feedName string, alert feeds.Alert, reporterID string) (string, bool, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback()
+
+	var reportID string
+	err = tx.QueryRowContext(ctx,
+		"SELECT BIN_TO_UUID(report_id) FROM feed_events WHERE source = ? AND external_id = ?",
+		feedName, alert.ID,
+	).Scan(&reportID)
+	if err == nil {
+		return reportID, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", false, err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`SELECT BIN_TO_UUID(fe.report_id) FROM feed_events fe
+		JOIN disaster_reports dr ON dr.id = fe.report_id AND dr.deleted_at IS NULL
+		WHERE fe.disaster_type = ? AND fe.occurred_at BETWEEN ? AND ?
+		AND ST_Distance_Sphere(dr.location, ST_SRID(POINT(?, ?), 4326)) <= ?
+		ORDER BY ABS(TIMESTAMPDIFF(SECOND, fe.occurred_at, ?)) LIMIT 1`,
+		alert.Type, alert.OccurredAt.Add(-feedMatchWindow), alert.OccurredAt.Add(feedMatchWindow),
+		alert.Longitude, alert.Latitude, feedMatchRadiusMeters, alert.OccurredAt,
+	).Scan(&reportID)
+	created := false
+	if err == sql.ErrNoRows {
+		description := alert.Description
+		if alert.URL != "" {
+			description += "\n\nSource: " + alert.URL
+		}
+		title := alert.Title
+		if len(title) > 255 {
+			title = title[:255]
+		}
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO disaster_reports (id, reporter_id, title, description, disaster_type, latitude, longitude, severity, status, source)
+			VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?, 'pending', 'feed')
+			RETURNING BIN_TO_UUID(id)`,
+			reporterID, title, description, alert.Type, alert.Latitude, alert.Longitude, feeds.Severity(alert),
+		).Scan(&reportID)
+		created = true
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO feed_events (source, external_id, report_id, disaster_type, magnitude, occurred_at)
+		VALUES (?, ?, UUID_TO_BIN(?), ?, ?, ?)`,
+		feedName, alert.ID, reportID, alert.Type, alert.Magnitude, alert.OccurredAt,
+	); err != nil {
+		return "", false, fmt.Errorf("recording alert: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", false, err
+	}
+	return reportID, created, nil
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/validation"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// defaultFlagThreshold is how many users must flag a report before it is
+// hidden pending review
+const defaultFlagThreshold = 3
+
+// reportFlagReasons are the reasons a user can flag a report for
+var reportFlagReasons = map[string]bool{
+	"spam":       true,
+	"fraud":      true,
+	"misleading": true,
+	"offensive":  true,
+	"other":      true,
+}
+
+// ReportFlag is one user's abuse flag on a report
+type ReportFlag struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Username  string    `json:"username"`
+	Reason    string    `json:"reason"`
+	Note      *string   `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FlaggedReport is a report waiting in the moderation queue with its open flags
+type FlaggedReport struct {
+	ID         string       `json:"id"`
+	ReporterID string       `json:"reporterId"`
+	Title      string       `json:"title"`
+	Status     string       `json:"status"`
+	FlagCount  int          `json:"flagCount"`
+	HiddenAt   *time.Time   `json:"hiddenAt"`
+	Flags      []ReportFlag `json:"flags"`
+}
+
+// SetFlagThreshold changes how many flags hide a report; zero or less keeps
+// the default
+func (h *ReportHandler) SetFlagThreshold(threshold int) {
+	if threshold > 0 {
+		h.flagThreshold = threshold
+	}
+}
+
+// FlagReport lets any user flag a report as spam, fraud or otherwise
+// abusive. Each user flags a report once. Once flagThreshold users have open
+// flags on it, the report is hidden from listings until an admin resolves
+// the flags.
+func (h *ReportHandler) FlagReport(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Reason string `json:"reason"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Note = strings.TrimSpace(request.Note)
+
+	v := validation.New()
+	if v.Required("reason", request.Reason) {
+		reasons := make([]string, 0, len(reportFlagReasons))
+		for reason := range reportFlagReasons {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		v.Check(reportFlagReasons[request.Reason], "reason", "must be one of: "+strings.Join(reasons, ", "))
+	}
+	v.Check(request.Reason != "other" || request.Note != "", "note", "is required when the reason is other")
+	v.Length("note", request.Note, 0, maxStatusNoteLength)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var reporterID string
+	var orgID sql.NullString
+	var status ReportStatus
+	var hidden bool
+	var flags int
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(reporter_id), BIN_TO_UUID(organization_id), status, hidden_at IS NOT NULL, flag_count
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
+		reportID,
+	).Scan(&reporterID, &orgID, &status, &hidden, &flags)
+	if err == sql.ErrNoRows || (err == nil && (status == ReportRejected || hidden) && !h.canSeeRejected(userID, reporterID, orgID.String)) {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if reporterID == userID {
+		apierror.Error(w, "You cannot flag your own report", http.StatusForbidden)
+		return
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO report_flags (id, report_id, user_id, reason, note)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, NULLIF(?, ''))`,
+		reportID, userID, request.Reason, request.Note,
+	)
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+		apierror.Error(w, "You have already flagged this report", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error flagging report", http.StatusInternalServerError)
+		return
+	}
+
+	flags++
+	hide := !hidden && flags >= h.flagThreshold
+	// Flags are bookkeeping, so they leave updated_at alone
+	update := "UPDATE disaster_reports SET flag_count = ?, updated_at = updated_at"
+	if hide {
+		update += ", hidden_at = NOW()"
+	}
+	if _, err := tx.Exec(update+" WHERE id = UUID_TO_BIN(?)", flags, reportID); err != nil {
+		apierror.Error(w, "Error flagging report", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error flagging report", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportFlagged,
+		Severity:   audit.SeverityLow,
+		UserID:     userID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+		Details:    map[string]interface{}{"reason": request.Reason, "openFlags": flags},
+	})
+	if hide {
+		h.auditLogger.Log(r, audit.Event{
+			Type:       audit.EventReportHidden,
+			Severity:   audit.SeverityMedium,
+			EntityType: "disaster_report",
+			EntityID:   reportID,
+			Details:    map[string]interface{}{"openFlags": flags, "threshold": h.flagThreshold},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reportId": reportID,
+		"reason":   request.Reason,
+		"message":  "Report flagged for review",
+	})
+}
+
+// ListFlaggedReports is the moderation queue: reports with open flags,
+// hidden ones first, then the most flagged
+func (h *ReportHandler) ListFlaggedReports(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= maxReportLimit {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	var total int
+	if err := h.db.QueryRow(
+		"SELECT COUNT(*) FROM disaster_reports WHERE flag_count > 0 AND deleted_at IS NULL",
+	).Scan(&total); err != nil {
+		apierror.Error(w, "Error fetching flagged reports", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(reporter_id), title, status, flag_count, hidden_at
+		FROM disaster_reports WHERE flag_count > 0 AND deleted_at IS NULL
+		ORDER BY hidden_at IS NULL, flag_count DESC, id
+		LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching flagged reports", http.StatusInternalServerError)
+		return
+	}
+	reports := []FlaggedReport{}
+	byID := map[string]int{}
+	for rows.Next() {
+		var report FlaggedReport
+		var hiddenAt sql.NullTime
+		if err := rows.Scan(&report.ID, &report.ReporterID, &report.Title, &report.Status,
+			&report.FlagCount, &hiddenAt); err != nil {
+			rows.Close()
+			apierror.Error(w, "Error processing flagged reports", http.StatusInternalServerError)
+			return
+		}
+		if hiddenAt.Valid {
+			report.HiddenAt = &hiddenAt.Time
+		}
+		report.Flags = []ReportFlag{}
+		byID[report.ID] = len(reports)
+		reports = append(reports, report)
+	}
+	rows.Close()
+
+	if len(reports) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("UUID_TO_BIN(?), ", len(reports)), ", ")
+		args := make([]interface{}, 0, len(reports))
+		for _, report := range reports {
+			args = append(args, report.ID)
+		}
+		flagRows, err := h.db.Query(
+			`SELECT BIN_TO_UUID(f.report_id), BIN_TO_UUID(f.id), BIN_TO_UUID(f.user_id), u.username,
+			f.reason, f.note, f.created_at
+			FROM report_flags f
+			JOIN users u ON u.id = f.user_id
+			WHERE f.status = 'open' AND f.report_id IN (`+placeholders+`)
+			ORDER BY f.created_at`,
+			args...,
+		)
+		if err != nil {
+			apierror.Error(w, "Error fetching report flags", http.StatusInternalServerError)
+			return
+		}
+		defer flagRows.Close()
+		for flagRows.Next() {
+			var reportID string
+			var flag ReportFlag
+			var note sql.NullString
+			if err := flagRows.Scan(&reportID, &flag.ID, &flag.UserID, &flag.Username,
+				&flag.Reason, &note, &flag.CreatedAt); err != nil {
+				apierror.Error(w, "Error processing report flags", http.StatusInternalServerError)
+				return
+			}
+			if note.Valid {
+				flag.Note = &note.String
+			}
+			i := byID[reportID]
+			reports[i].Flags = append(reports[i].Flags, flag)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":   reports,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+		"hasMore": offset+len(reports) < total,
+	})
+}
+
+// ResolveReportFlags closes a report's open flags. Dismissing them puts the
+// report back in listings; upholding them deletes the report, which can
+// still be restored from the deleted reports.
+func (h *ReportHandler) ResolveReportFlags(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Note = strings.TrimSpace(request.Note)
+
+	v := validation.New()
+	if v.Required("action", request.Action) {
+		v.Check(request.Action == "dismiss" || request.Action == "uphold", "action", "must be dismiss or uphold")
+	}
+	v.Length("note", request.Note, 0, maxStatusNoteLength)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var flags int
+	err = tx.QueryRow(
+		"SELECT flag_count FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE",
+		reportID,
+	).Scan(&flags)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if flags == 0 {
+		apierror.Error(w, "Report has no open flags", http.StatusConflict)
+		return
+	}
+
+	status := "dismissed"
+	update := "UPDATE disaster_reports SET flag_count = 0, hidden_at = NULL, updated_at = updated_at"
+	args := []interface{}{}
+	if request.Action == "uphold" {
+		status = "upheld"
+		update += ", deleted_at = NOW(), deleted_by = UUID_TO_BIN(?)"
+		args = append(args, adminID)
+	}
+	if _, err := tx.Exec(
+		`UPDATE report_flags SET status = ?, resolved_by = UUID_TO_BIN(?), resolution_note = NULLIF(?, ''), resolved_at = NOW()
+		WHERE report_id = UUID_TO_BIN(?) AND status = 'open'`,
+		status, adminID, request.Note, reportID,
+	); err != nil {
+		apierror.Error(w, "Error resolving flags", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(update+" WHERE id = UUID_TO_BIN(?)", append(args, reportID)...); err != nil {
+		apierror.Error(w, "Error resolving flags", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error resolving flags", http.StatusInternalServerError)
+		return
+	}
+
+	details := map[string]interface{}{"action": request.Action, "flags": flags}
+	if request.Note != "" {
+		details["note"] = request.Note
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportFlagsResolved,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+		Details:    details,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reportId": reportID,
+		"action":   request.Action,
+		"resolved": flags,
+		"deleted":  request.Action == "uphold",
+	})
+}
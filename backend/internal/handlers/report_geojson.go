@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	geoJSONContentType = "application/geo+json"
+
+	// Mapping layers are meant to be loaded whole, but an unfiltered
+	// collection still has to end somewhere
+	maxGeoJSONFeatures = 10000
+	geoJSONFlushEvery  = 500
+)
+
+// GeoJSONFeature is a report as a point feature. Coordinates are longitude
+// first, as GeoJSON requires.
+type GeoJSONFeature struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Geometry   GeoJSONPoint      `json:"geometry"`
+	Properties GeoJSONProperties `json:"properties"`
+}
+
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type GeoJSONProperties struct {
+	Title        string             `json:"title"`
+	DisasterType string             `json:"disasterType"`
+	Severity     string             `json:"severity"`
+	Status       string             `json:"status"`
+	Donations    map[string]float64 `json:"donations"`
+	// DonationsIDR is every currency converted at each donation's rate
+	DonationsIDR float64    `json:"donationsIdr"`
+	VerifiedAt   *time.Time `json:"verifiedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// geoJSONSelect reads reports with their completed donation totals per
+// currency. The totals subquery exposes no column the report filters use, so
+// those filters stay unqualified.
+const geoJSONSelect = `SELECT BIN_TO_UUID(id), title, disaster_type, severity, status,
+	latitude, longitude, totals.raised, COALESCE(totals.raised_idr, 0), verified_at, created_at
+	FROM disaster_reports
+	LEFT JOIN (
+		SELECT disaster_report_id, JSON_OBJECTAGG(currency, raised) AS raised, SUM(raised_idr) AS raised_idr FROM (
+			SELECT disaster_report_id, currency, SUM(amount) AS raised, SUM(amount_idr) AS raised_idr
+			FROM donations WHERE status = 'completed'
+			GROUP BY disaster_report_id, currency
+		) per_currency GROUP BY disaster_report_id
+	) totals ON totals.disaster_report_id = disaster_reports.id`
+
+func scanGeoJSONFeature(scan func(dest ...interface{}) error) (GeoJSONFeature, error) {
+	f := GeoJSONFeature{Type: "Feature", Geometry: GeoJSONPoint{Type: "Point"}}
+	var latitude, longitude float64
+	var raised []byte
+	var verifiedAt sql.NullTime
+	err := scan(&f.ID, &f.Properties.Title, &f.Properties.DisasterType, &f.Properties.Severity,
+		&f.Properties.Status, &latitude, &longitude, &raised, &f.Properties.DonationsIDR, &verifiedAt, &f.Properties.CreatedAt)
+	if err != nil {
+		return f, err
+	}
+	f.Geometry.Coordinates = [2]float64{longitude, latitude}
+	f.Properties.Donations = map[string]float64{}
+	if raised != nil {
+		json.Unmarshal(raised, &f.Properties.Donations)
+	}
+	if verifiedAt.Valid {
+		f.Properties.VerifiedAt = &verifiedAt.Time
+	}
+	return f, nil
+}
+
+// ReportsGeoJSON streams verified reports as a FeatureCollection. It takes
+// the status, severity, type and q filters of ListReports; status may only
+// name a verified status.
+func (h *ReportHandler) ReportsGeoJSON(w http.ResponseWriter, r *http.Request) {
+	filter := parseReportFilter(r.URL.Query())
+
+	v := validation.New()
+	filter.validate(v)
+	if filter.Status != "" {
+		v.Check(ReportStatus(filter.Status).Verified(), "status", "must be one of: verified, in_progress, resolved, closed")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	where, args, ok := h.reportFilterWhere(w, r, filter)
+	if !ok {
+		return
+	}
+	where += " AND status IN (" + verifiedReportStatuses + ")"
+
+	rows, err := h.db.Query(geoJSONSelect+where+" ORDER BY created_at DESC LIMIT ?", append(args, maxGeoJSONFeatures)...)
+	if err != nil {
+		apierror.Error(w, "Error fetching reports", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", geoJSONContentType)
+	w.Write([]byte(`{"type":"FeatureCollection","features":[`))
+	flusher, _ := w.(http.Flusher)
+	for n := 0; rows.Next(); n++ {
+		feature, err := scanGeoJSONFeature(rows.Scan)
+		if err != nil {
+			// Headers are already sent, so the best we can do is cut the stream short
+			return
+		}
+		if n > 0 {
+			w.Write([]byte(","))
+		}
+		encoded, _ := json.Marshal(feature)
+		w.Write(encoded)
+		if flusher != nil && n%geoJSONFlushEvery == geoJSONFlushEvery-1 {
+			flusher.Flush()
+		}
+	}
+	w.Write([]byte("]}\n"))
+}
+
+// ReportGeoJSON returns one verified report as a Feature
+func (h *ReportHandler) ReportGeoJSON(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	feature, err := scanGeoJSONFeature(h.db.QueryRow(
+		geoJSONSelect+" WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL AND status IN ("+verifiedReportStatuses+")",
+		reportID,
+	).Scan)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found or not verified", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", geoJSONContentType)
+	json.NewEncoder(w).Encode(feature)
+}
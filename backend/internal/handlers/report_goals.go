@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/fx"
+	"saferelief/internal/middleware"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+// maxGoalAmount fits goal_amount, DECIMAL(15,2)
+const maxGoalAmount = 9999999999999.99
+
+// FundraisingProgress is how much a report has raised toward its goal. Both
+// are in rupiah, donations in other currencies converted at their day's
+// rate; a donation counts once it completes and stops counting if refunded.
+type FundraisingProgress struct {
+	Currency     string   `json:"currency"`
+	GoalAmount   *float64 `json:"goalAmount"`
+	AmountRaised float64  `json:"amountRaised"`
+	// Percent of the goal raised, past 100 once it is exceeded
+	Percent       *float64   `json:"percent"`
+	GoalReachedAt *time.Time `json:"goalReachedAt"`
+	// AutoClose stops donations once the goal is reached; Closed is set
+	// while they are stopped
+	AutoClose bool `json:"autoClose"`
+	Closed    bool `json:"closed"`
+}
+
+// fundraisingColumns are read by scanFundraising, in this order
+const fundraisingColumns = "goal_amount, amount_raised, goal_auto_close, goal_reached_at, fundraising_closed_at IS NOT NULL"
+
+// fundraisingDest returns the scan targets for fundraisingColumns
+func fundraisingDest(p *FundraisingProgress) []interface{} {
+	p.Currency = "IDR"
+	return []interface{}{&p.GoalAmount, &p.AmountRaised, &p.AutoClose, &p.GoalReachedAt, &p.Closed}
+}
+
+// withPercent fills in Percent once the columns are scanned
+func (p FundraisingProgress) withPercent() FundraisingProgress {
+	if p.GoalAmount != nil && *p.GoalAmount > 0 {
+		percent := math.Floor(p.AmountRaised / *p.GoalAmount * 1000) / 10
+		p.Percent = &percent
+	}
+	return p
+}
+
+// SetGoal sets or, with a null amount, removes a report's fundraising goal
+// in rupiah. Platform verifiers and verifiers of the filing organization
+// can set it while the report is pending, verified or in progress. With
+// autoClose the report stops taking donations once the goal is reached,
+// and takes them again if the goal is raised or refunds bring the amount
+// back below it.
+func (h *ReportHandler) SetGoal(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Amount    *float64 `json:"amount"`
+		AutoClose bool     `json:"autoClose"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	v := validation.New()
+	if request.Amount != nil {
+		amount := *request.Amount
+		v.Check(amount > 0 && amount <= maxGoalAmount, "amount", "must be greater than 0 and at most 9999999999999.99")
+		v.Check(math.Abs(amount*100-math.Round(amount*100)) < 1e-6, "amount", "IDR amounts can have at most 2 decimals")
+	}
+	v.Check(request.Amount != nil || !request.AutoClose, "autoClose", "needs a goal amount")
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var reporterID string
+	var orgID sql.NullString
+	var status ReportStatus
+	var hidden bool
+	var previous *float64
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(reporter_id), BIN_TO_UUID(organization_id), status, hidden_at IS NOT NULL, goal_amount
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
+		reportID,
+	).Scan(&reporterID, &orgID, &status, &hidden, &previous)
+	if err == sql.ErrNoRows || (err == nil && (status == ReportRejected || hidden) && !h.canSeeRejected(userID, reporterID, orgID.String)) {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching report", http.StatusInternalServerError)
+		return
+	}
+
+	role, err := middleware.LookupRole(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	allowed := role.Can(middleware.PermVerifyReports)
+	if !allowed && orgID.Valid {
+		allowed, err = hasOrganizationPermission(h.db, orgID.String, userID, OrgPermVerifyReports)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !allowed {
+		apierror.Error(w, "Only verifiers can set a report's fundraising goal", http.StatusForbidden)
+		return
+	}
+	if status != ReportPending && !status.AcceptsDonations() {
+		apierror.Error(w, "Fundraising for a "+string(status)+" report has ended", http.StatusConflict)
+		return
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE disaster_reports SET goal_amount = ?, goal_auto_close = ? WHERE id = UUID_TO_BIN(?)",
+		request.Amount, request.AutoClose, reportID,
+	); err != nil {
+		apierror.Error(w, "Error updating fundraising goal", http.StatusInternalServerError)
+		return
+	}
+	if err := fx.RecountReport(r.Context(), tx, reportID); err != nil {
+		apierror.Error(w, "Error updating fundraising goal", http.StatusInternalServerError)
+		return
+	}
+	var progress FundraisingProgress
+	if err := tx.QueryRow(
+		"SELECT "+fundraisingColumns+" FROM disaster_reports WHERE id = UUID_TO_BIN(?)", reportID,
+	).Scan(fundraisingDest(&progress)...); err != nil {
+		apierror.Error(w, "Error fetching fundraising progress", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error updating fundraising goal", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportGoalSet,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+		Details: map[string]interface{}{
+			"previous":  previous,
+			"amount":    request.Amount,
+			"autoClose": request.AutoClose,
+		},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress.withPercent())
+}
@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/middleware"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxNeedsPerReport     = 50
+	maxNeedQuantity       = 1e9
+	maxFulfillmentNoteLen = 500
+)
+
+// ReportNeed is an item responders need for a report, such as 200 blankets.
+// Progress is the fulfilled share in percent, capped at 100.
+type ReportNeed struct {
+	ID                string    `json:"id"`
+	Item              string    `json:"item"`
+	Unit              string    `json:"unit"`
+	QuantityNeeded    float64   `json:"quantityNeeded"`
+	QuantityFulfilled float64   `json:"quantityFulfilled"`
+	Progress          int       `json:"progress"`
+	Met               bool      `json:"met"`
+	CreatedBy         string    `json:"createdBy"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// NeedsSummary is how far a report's needs are met: Progress averages the
+// progress of each need, so one large need cannot hide the rest
+type NeedsSummary struct {
+	Total    int `json:"total"`
+	Met      int `json:"met"`
+	Progress int `json:"progress"`
+}
+
+// NeedFulfillment is one delivery recorded against a need. A negative
+// quantity corrects an earlier entry.
+type NeedFulfillment struct {
+	ID         string    `json:"id"`
+	Quantity   float64   `json:"quantity"`
+	Note       *string   `json:"note"`
+	RecordedBy string    `json:"recordedBy"`
+	Username   string    `json:"username"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+const reportNeedColumns = `SELECT BIN_TO_UUID(id), item, unit, quantity_needed, quantity_fulfilled,
+	BIN_TO_UUID(created_by), created_at, updated_at FROM report_needs`
+
+func scanReportNeed(scan func(dest ...interface{}) error) (ReportNeed, error) {
+	var need ReportNeed
+	err := scan(&need.ID, &need.Item, &need.Unit, &need.QuantityNeeded, &need.QuantityFulfilled,
+		&need.CreatedBy, &need.CreatedAt, &need.UpdatedAt)
+	need.Met = need.QuantityFulfilled >= need.QuantityNeeded
+	need.Progress = 100
+	if !need.Met {
+		need.Progress = int(math.Floor(100 * need.QuantityFulfilled / need.QuantityNeeded))
+	}
+	return need, err
+}
+
+// summarizeNeeds totals the progress of a report's needs
+func summarizeNeeds(needs []ReportNeed) NeedsSummary {
+	summary := NeedsSummary{Total: len(needs)}
+	if len(needs) == 0 {
+		return summary
+	}
+	progress := 0
+	for _, need := range needs {
+		if need.Met {
+			summary.Met++
+		}
+		progress += need.Progress
+	}
+	summary.Progress = progress / len(needs)
+	return summary
+}
+
+func (h *ReportHandler) reportNeeds(reportID string) ([]ReportNeed, error) {
+	rows, err := h.db.Query(reportNeedColumns+" WHERE report_id = UUID_TO_BIN(?) ORDER BY created_at, id", reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	needs := []ReportNeed{}
+	for rows.Next() {
+		need, err := scanReportNeed(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		needs = append(needs, need)
+	}
+	return needs, rows.Err()
+}
+
+// canManageNeeds writes the error response and returns false unless the
+// user may change the report's needs: its reporter, platform verifiers, and
+// members of the filing organization who file reports for it. Needs of
+// closed or rejected reports are final.
+func (h *ReportHandler) canManageNeeds(w http.ResponseWriter, reportID, userID string) bool {
+	var reporterID string
+	var orgID sql.NullString
+	var status ReportStatus
+	var hidden bool
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(reporter_id), BIN_TO_UUID(organization_id), status, hidden_at IS NOT NULL
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL`,
+		reportID,
+	).Scan(&reporterID, &orgID, &status, &hidden)
+	if err == sql.ErrNoRows || (err == nil && (status == ReportRejected || hidden) && !h.canSeeRejected(userID, reporterID, orgID.String)) {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	allowed := reporterID == userID
+	if !allowed {
+		role, err := middleware.LookupRole(h.db, userID)
+		if err != nil {
+			apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return false
+		}
+		allowed = role.Can(middleware.PermVerifyReports)
+	}
+	if !allowed && orgID.Valid {
+		allowed, err = hasOrganizationPermission(h.db, orgID.String, userID, OrgPermCreateReports)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return false
+		}
+	}
+	if !allowed {
+		apierror.Error(w, "Only the reporter, verifiers or the filing organization can manage this report's needs", http.StatusForbidden)
+		return false
+	}
+	if status == ReportClosed || status == ReportRejected {
+		apierror.Error(w, "The needs of a "+string(status)+" report can no longer change", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// ListNeeds returns a report's needs, oldest first, with overall progress
+func (h *ReportHandler) ListNeeds(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	var exists bool
+	if err := h.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL)",
+		reportID,
+	).Scan(&exists); err != nil {
+		apierror.Error(w, "Error fetching needs", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	needs, err := h.reportNeeds(reportID)
+	if err != nil {
+		apierror.Error(w, "Error fetching needs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":   needs,
+		"summary": summarizeNeeds(needs),
+	})
+}
+
+// CreateNeed adds an item need to a report
+func (h *ReportHandler) CreateNeed(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Item           string  `json:"item"`
+		Unit           string  `json:"unit"`
+		QuantityNeeded float64 `json:"quantityNeeded"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Item = strings.TrimSpace(request.Item)
+	request.Unit = strings.TrimSpace(request.Unit)
+
+	v := validation.New()
+	if v.Required("item", request.Item) {
+		v.Length("item", request.Item, 1, 100)
+	}
+	if v.Required("unit", request.Unit) {
+		v.Length("unit", request.Unit, 1, 30)
+	}
+	validateNeedQuantity(v, request.QuantityNeeded)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	if !h.canManageNeeds(w, reportID, userID) {
+		return
+	}
+
+	var count int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM report_needs WHERE report_id = UUID_TO_BIN(?)", reportID).Scan(&count); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxNeedsPerReport {
+		apierror.Error(w, "A report can list at most 50 needs", http.StatusConflict)
+		return
+	}
+
+	var needID string
+	err := h.db.QueryRow(
+		`INSERT INTO report_needs (id, report_id, item, unit, quantity_needed, created_by)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, UUID_TO_BIN(?))
+		RETURNING BIN_TO_UUID(id)`,
+		reportID, request.Item, request.Unit, request.QuantityNeeded, userID,
+	).Scan(&needID)
+	if err != nil {
+		apierror.Error(w, "Error adding need", http.StatusInternalServerError)
+		return
+	}
+
+	need, err := scanReportNeed(h.db.QueryRow(reportNeedColumns+" WHERE id = UUID_TO_BIN(?)", needID).Scan)
+	if err != nil {
+		apierror.Error(w, "Error fetching need", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(need)
+}
+
+// UpdateNeed changes a need's item, unit or quantity needed. Fields left
+// out are kept; deliveries go through RecordFulfillment instead.
+func (h *ReportHandler) UpdateNeed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	reportID, needID := vars["id"], vars["needId"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Item           *string  `json:"item"`
+		Unit           *string  `json:"unit"`
+		QuantityNeeded *float64 `json:"quantityNeeded"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	set := []string{}
+	args := []interface{}{}
+	if request.Item != nil {
+		item := strings.TrimSpace(*request.Item)
+		v.Length("item", item, 1, 100)
+		set = append(set, "item = ?")
+		args = append(args, item)
+	}
+	if request.Unit != nil {
+		unit := strings.TrimSpace(*request.Unit)
+		v.Length("unit", unit, 1, 30)
+		set = append(set, "unit = ?")
+		args = append(args, unit)
+	}
+	if request.QuantityNeeded != nil {
+		validateNeedQuantity(v, *request.QuantityNeeded)
+		set = append(set, "quantity_needed = ?")
+		args = append(args, *request.QuantityNeeded)
+	}
+	v.Check(len(set) > 0, "body", "must change at least one of item, unit or quantityNeeded")
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	if !h.canManageNeeds(w, reportID, userID) {
+		return
+	}
+
+	result, err := h.db.Exec(
+		"UPDATE report_needs SET "+strings.Join(set, ", ")+" WHERE id = UUID_TO_BIN(?) AND report_id = UUID_TO_BIN(?)",
+		append(args, needID, reportID)...,
+	)
+	if err != nil {
+		apierror.Error(w, "Error updating need", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		// Nothing changed or no such need; tell them apart
+		var exists bool
+		if err := h.db.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM report_needs WHERE id = UUID_TO_BIN(?) AND report_id = UUID_TO_BIN(?))",
+			needID, reportID,
+		).Scan(&exists); err != nil || !exists {
+			apierror.Error(w, "Need not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	need, err := scanReportNeed(h.db.QueryRow(reportNeedColumns+" WHERE id = UUID_TO_BIN(?)", needID).Scan)
+	if err != nil {
+		apierror.Error(w, "Error fetching need", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(need)
+}
+
+// DeleteNeed removes a need along with its recorded deliveries
+func (h *ReportHandler) DeleteNeed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	reportID, needID := vars["id"], vars["needId"]
+	userID := r.Context().Value("user_id").(string)
+
+	if !h.canManageNeeds(w, reportID, userID) {
+		return
+	}
+
+	result, err := h.db.Exec(
+		"DELETE FROM report_needs WHERE id = UUID_TO_BIN(?) AND report_id = UUID_TO_BIN(?)",
+		needID, reportID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error deleting need", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Need not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Need deleted"})
+}
+
+// RecordFulfillment logs a delivery against a need and adds it to the
+// quantity fulfilled. Deliveries beyond the quantity needed are kept; the
+// need's progress just stops at 100.
+func (h *ReportHandler) RecordFulfillment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	reportID, needID := vars["id"], vars["needId"]
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		Quantity float64 `json:"quantity"`
+		Note     string  `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Note = strings.TrimSpace(request.Note)
+
+	v := validation.New()
+	request.Quantity = math.Round(request.Quantity*100) / 100
+	v.Check(request.Quantity != 0 && math.Abs(request.Quantity) <= maxNeedQuantity,
+		"quantity", "must be non-zero and at most 1000000000 either way")
+	v.Length("note", request.Note, 0, maxFulfillmentNoteLen)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	if !h.canManageNeeds(w, reportID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var fulfilled float64
+	err = tx.QueryRow(
+		"SELECT quantity_fulfilled FROM report_needs WHERE id = UUID_TO_BIN(?) AND report_id = UUID_TO_BIN(?) FOR UPDATE",
+		needID, reportID,
+	).Scan(&fulfilled)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Need not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if fulfilled+request.Quantity < 0 {
+		apierror.Write(w, http.StatusConflict, apierror.CodeConflict,
+			"A correction cannot take the quantity fulfilled below zero",
+			map[string]interface{}{"quantityFulfilled": fulfilled})
+		return
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE report_needs SET quantity_fulfilled = quantity_fulfilled + ? WHERE id = UUID_TO_BIN(?)",
+		request.Quantity, needID,
+	); err != nil {
+		apierror.Error(w, "Error recording delivery", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO report_need_fulfillments (id, need_id, quantity, note, recorded_by)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, NULLIF(?, ''), UUID_TO_BIN(?))`,
+		needID, request.Quantity, request.Note, userID,
+	); err != nil {
+		apierror.Error(w, "Error recording delivery", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error recording delivery", http.StatusInternalServerError)
+		return
+	}
+
+	need, err := scanReportNeed(h.db.QueryRow(reportNeedColumns+" WHERE id = UUID_TO_BIN(?)", needID).Scan)
+	if err != nil {
+		apierror.Error(w, "Error fetching need", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(need)
+}
+
+// ListFulfillments returns the deliveries recorded against a need, oldest first
+func (h *ReportHandler) ListFulfillments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	reportID, needID := vars["id"], vars["needId"]
+
+	var exists bool
+	if err := h.db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM report_needs n
+		JOIN disaster_reports dr ON dr.id = n.report_id AND dr.deleted_at IS NULL
+		WHERE n.id = UUID_TO_BIN(?) AND n.report_id = UUID_TO_BIN(?))`,
+		needID, reportID,
+	).Scan(&exists); err != nil {
+		apierror.Error(w, "Error fetching deliveries", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		apierror.Error(w, "Need not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(f.id), f.quantity, f.note, BIN_TO_UUID(f.recorded_by), u.username, f.created_at
+		FROM report_need_fulfillments f
+		JOIN users u ON u.id = f.recorded_by
+		WHERE f.need_id = UUID_TO_BIN(?)
+		ORDER BY f.created_at, f.id`,
+		needID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching deliveries", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	fulfillments := []NeedFulfillment{}
+	for rows.Next() {
+		var f NeedFulfillment
+		var note sql.NullString
+		if err := rows.Scan(&f.ID, &f.Quantity, &note, &f.RecordedBy, &f.Username, &f.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing deliveries", http.StatusInternalServerError)
+			return
+		}
+		if note.Valid {
+			f.Note = &note.String
+		}
+		fulfillments = append(fulfillments, f)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fulfillments)
+}
+
+func validateNeedQuantity(v *validation.Validator, quantity float64) {
+	v.Check(quantity > 0 && quantity <= maxNeedQuantity && quantity == math.Round(quantity*100)/100,
+		"quantityNeeded", "must be greater than 0 and at most 1000000000, with up to 2 decimals")
+}
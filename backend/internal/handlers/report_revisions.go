@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// FieldChange is one field's value before and after a revision
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// ReportRevision is one recorded change to a report. Revisions are never
+// updated or deleted. ChangedBy and Username are null for changes the system
+// made on its own.
+type ReportRevision struct {
+	ID        string                 `json:"id"`
+	ChangedBy *string                `json:"changedBy"`
+	Username  *string                `json:"username"`
+	Changes   map[string]FieldChange `json:"changes"`
+	Note      *string                `json:"note,omitempty"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
+
+// recordRevision stores the fields an edit actually changed, inside the
+// transaction that made the edit. Nothing is stored when no value differs.
+// An empty userID records a system change.
+func recordRevision(tx *sql.Tx, reportID, userID string, changes map[string]FieldChange, note string) error {
+	for field, change := range changes {
+		if change.From == change.To {
+			delete(changes, field)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO report_revisions (id, report_id, changed_by, changes, note)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(NULLIF(?, '')), ?, NULLIF(?, ''))`,
+		reportID, userID, encoded, note,
+	)
+	return err
+}
+
+// ReportHistory lists every recorded change to a report, oldest first, so
+// a verifier can see what was edited before trusting it
+func (h *ReportHandler) ReportHistory(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	var exists bool
+	if err := h.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL)",
+		reportID,
+	).Scan(&exists); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(rv.id), BIN_TO_UUID(rv.changed_by), u.username, rv.changes, rv.note, rv.created_at
+		FROM report_revisions rv
+		LEFT JOIN users u ON u.id = rv.changed_by
+		WHERE rv.report_id = UUID_TO_BIN(?)
+		ORDER BY rv.created_at, rv.id`,
+		reportID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching report history", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	revisions := []ReportRevision{}
+	for rows.Next() {
+		var revision ReportRevision
+		var changes []byte
+		var note sql.NullString
+		if err := rows.Scan(&revision.ID, &revision.ChangedBy, &revision.Username, &changes, &note, &revision.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing report history", http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal(changes, &revision.Changes)
+		if note.Valid {
+			revision.Note = &note.String
+		}
+		revisions = append(revisions, revision)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
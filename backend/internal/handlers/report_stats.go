@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/validation"
+)
+
+const (
+	reportStatsCacheTTL = time.Minute
+	maxStatsCacheSize   = 200
+	// Regions beyond the busiest this many are left out of byRegion
+	maxStatsRegions = 50
+)
+
+// maxStatsRanges caps the window per interval so a series stays a few
+// hundred points long
+var maxStatsRanges = map[string]time.Duration{
+	"day":  366 * 24 * time.Hour,
+	"week": 5 * 365 * 24 * time.Hour,
+}
+
+// RegionCount is the number of reports in a 1-degree cell, identified by
+// its south-west corner as in the open data
+type RegionCount struct {
+	MinLatitude  int `json:"minLatitude"`
+	MinLongitude int `json:"minLongitude"`
+	CellDegrees  int `json:"cellDegrees"`
+	Count        int `json:"count"`
+}
+
+// PeriodCount is the number of reports filed in one day or week
+type PeriodCount struct {
+	Period string `json:"period"`
+	Count  int    `json:"count"`
+}
+
+// ReportStats is the shape of GET /reports/stats
+type ReportStats struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Interval   string         `json:"interval"`
+	Total      int            `json:"total"`
+	ByStatus   map[string]int `json:"byStatus"`
+	BySeverity map[string]int `json:"bySeverity"`
+	ByType     map[string]int `json:"byType"`
+	ByRegion   []RegionCount  `json:"byRegion"`
+	Series     []PeriodCount  `json:"series"`
+}
+
+// ReportStats counts the reports filed between from and to by status,
+// severity, disaster type and 1-degree region, with a daily or weekly series
+// for dashboard charts. Deleted reports are left out. Results are cached for
+// reportStatsCacheTTL since dashboards poll.
+func (h *ReportHandler) ReportStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+
+	v := validation.New()
+	_, ok := maxStatsRanges[interval]
+	v.Check(ok, "interval", "must be day or week")
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today.AddDate(0, 0, 1)
+	from := today.AddDate(0, 0, -29)
+	if raw := query.Get("from"); raw != "" {
+		t, err := time.Parse("2006-01-02", raw)
+		v.Check(err == nil, "from", "must be a date (YYYY-MM-DD)")
+		from = t
+	}
+	if raw := query.Get("to"); raw != "" {
+		t, err := time.Parse("2006-01-02", raw)
+		v.Check(err == nil, "to", "must be a date (YYYY-MM-DD)")
+		to = t.AddDate(0, 0, 1)
+	}
+	if v.Valid() {
+		v.Check(from.Before(to) && to.Sub(from) <= maxStatsRanges[interval], "from",
+			"must be before to, at most a year apart for day and five years for week")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	cacheKey := interval + "|" + from.Format("2006-01-02") + "|" + to.Format("2006-01-02")
+	h.statsMu.Lock()
+	cached, ok := h.statsCache[cacheKey]
+	h.statsMu.Unlock()
+
+	if !ok || time.Now().After(cached.expires) {
+		stats, err := h.computeReportStats(r, interval, from, to)
+		if err != nil {
+			apierror.Error(w, "Error computing report stats", http.StatusInternalServerError)
+			return
+		}
+		var body bytes.Buffer
+		json.NewEncoder(&body).Encode(stats)
+		cached = cachedResponse{body: body.Bytes(), expires: time.Now().Add(reportStatsCacheTTL)}
+
+		h.statsMu.Lock()
+		for key, entry := range h.statsCache {
+			if time.Now().After(entry.expires) {
+				delete(h.statsCache, key)
+			}
+		}
+		if len(h.statsCache) < maxStatsCacheSize {
+			h.statsCache[cacheKey] = cached
+		}
+		h.statsMu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(time.Until(cached.expires).Seconds())))
+	w.Write(cached.body)
+}
+
+func (h *ReportHandler) computeReportStats(r *http.Request, interval string, from, to time.Time) (ReportStats, error) {
+	stats := ReportStats{
+		From:       from.Format("2006-01-02"),
+		To:         to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Interval:   interval,
+		ByStatus:   map[string]int{},
+		BySeverity: map[string]int{},
+		ByType:     map[string]int{},
+		ByRegion:   []RegionCount{},
+		Series:     []PeriodCount{},
+	}
+	for _, status := range []ReportStatus{ReportPending, ReportVerified, ReportInProgress, ReportResolved, ReportClosed, ReportRejected} {
+		stats.ByStatus[string(status)] = 0
+	}
+	for severity := range severityRanks {
+		stats.BySeverity[severity] = 0
+	}
+	const window = " FROM disaster_reports WHERE deleted_at IS NULL AND created_at >= ? AND created_at < ?"
+
+	rows, err := h.db.QueryContext(r.Context(),
+		"SELECT status, severity, disaster_type, COUNT(*)"+window+" GROUP BY status, severity, disaster_type",
+		from, to,
+	)
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var status, severity, disasterType string
+		var count int
+		if err := rows.Scan(&status, &severity, &disasterType, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.Total += count
+		stats.ByStatus[status] += count
+		stats.BySeverity[severity] += count
+		stats.ByType[disasterType] += count
+	}
+	rows.Close()
+
+	rows, err = h.db.QueryContext(r.Context(),
+		"SELECT FLOOR(latitude) AS lat, FLOOR(longitude) AS lon, COUNT(*)"+window+
+			" GROUP BY lat, lon ORDER BY COUNT(*) DESC, lat, lon LIMIT ?",
+		from, to, maxStatsRegions,
+	)
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		region := RegionCount{CellDegrees: 1}
+		if err := rows.Scan(&region.MinLatitude, &region.MinLongitude, &region.Count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.ByRegion = append(stats.ByRegion, region)
+	}
+	rows.Close()
+
+	period := strings.ReplaceAll(openDataPeriods[interval], "{t}", "created_at")
+	rows, err = h.db.QueryContext(r.Context(),
+		"SELECT "+period+" AS period, COUNT(*)"+window+" GROUP BY period",
+		from, to,
+	)
+	if err != nil {
+		return stats, err
+	}
+	counts := map[string]int{}
+	for rows.Next() {
+		var label string
+		var count int
+		if err := rows.Scan(&label, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		counts[label] = count
+	}
+	rows.Close()
+
+	// Every bucket is listed, empty ones as zero, so charts need no gap
+	// filling; weeks start on Monday as in the open data
+	start, step := from, 1
+	if interval == "week" {
+		start = from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+		step = 7
+	}
+	for t := start; t.Before(to); t = t.AddDate(0, 0, step) {
+		label := t.Format("2006-01-02")
+		stats.Series = append(stats.Series, PeriodCount{Period: label, Count: counts[label]})
+	}
+	return stats, nil
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+type ReportStatus string
+
+const (
+	ReportPending    ReportStatus = "pending"
+	ReportVerified   ReportStatus = "verified"
+	ReportInProgress ReportStatus = "in_progress"
+	ReportResolved   ReportStatus = "resolved"
+	ReportClosed     ReportStatus = "closed"
+	ReportRejected   ReportStatus = "rejected"
+)
+
+const maxStatusNoteLength = 1000
+
+// verifiedReportStatuses are the statuses of reports that passed
+// verification, for SQL IN lists
+const verifiedReportStatuses = "'verified', 'in_progress', 'resolved', 'closed'"
+
+// AcceptsDonations reports whether relief for the report is still being
+// funded: it has been verified and work on it is not finished
+func (s ReportStatus) AcceptsDonations() bool {
+	return s == ReportVerified || s == ReportInProgress
+}
+
+// Verified reports whether the report passed verification, matching
+// verifiedReportStatuses
+func (s ReportStatus) Verified() bool {
+	return s == ReportVerified || s == ReportInProgress || s == ReportResolved || s == ReportClosed
+}
+
+// Finished reports whether the report needs no more responders
+func (s ReportStatus) Finished() bool {
+	return s == ReportResolved || s == ReportClosed || s == ReportRejected
+}
+
+// reportTransitions lists the statuses each status may move to and the
+// permission needed to make the move. Closed is final. Verifying also admits
+// organization verifiers, see canVerify.
+var reportTransitions = map[ReportStatus]map[ReportStatus]middleware.Permission{
+	ReportPending: {
+		ReportVerified: middleware.PermVerifyReports,
+		ReportRejected: middleware.PermVerifyReports,
+	},
+	ReportVerified: {
+		ReportInProgress: middleware.PermVerifyReports,
+		ReportResolved:   middleware.PermVerifyReports,
+		ReportRejected:   middleware.PermAdminAccess,
+	},
+	ReportInProgress: {
+		ReportResolved: middleware.PermVerifyReports,
+	},
+	ReportResolved: {
+		ReportInProgress: middleware.PermVerifyReports,
+		ReportClosed:     middleware.PermVerifyReports,
+	},
+	ReportRejected: {
+		ReportPending: middleware.PermAdminAccess,
+	},
+}
+
+// reportRejectionReasons are the reason codes a rejection must carry, with
+// the wording shown to the reporter. Only the system rejects as
+// expiredRejectionReason.
+var reportRejectionReasons = map[string]string{
+	"duplicate":             "It duplicates another report",
+	"insufficient_evidence": "There was not enough evidence to confirm it",
+	"inaccurate_location":   "The location could not be confirmed",
+	"not_a_disaster":        "It does not describe a disaster",
+	"spam":                  "It was spam or abusive",
+	expiredRejectionReason:  "It was not verified in time",
+	"other":                 "See the verifier's note",
+}
+
+// expiredRejectionReason marks pending reports that expired unverified
+const expiredRejectionReason = "expired"
+
+// ReportRejection explains why a report was rejected. RejectedBy is empty
+// for reports that expired.
+type ReportRejection struct {
+	Reason     string    `json:"reason"`
+	Note       *string   `json:"note"`
+	RejectedBy string    `json:"rejectedBy"`
+	RejectedAt time.Time `json:"rejectedAt"`
+	// MergedInto is the report a duplicate was merged into
+	MergedInto *string `json:"mergedInto,omitempty"`
+}
+
+// statusChange is one requested transition; Reason is only used when
+// rejecting
+type statusChange struct {
+	To     ReportStatus
+	Note   string
+	Reason string
+}
+
+// validateRejection checks the reason code; "other" needs a note to explain it
+func validateRejection(v *validation.Validator, reason, note string) {
+	codes := make([]string, 0, len(reportRejectionReasons))
+	for code := range reportRejectionReasons {
+		if code != expiredRejectionReason {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	if !v.Required("reason", reason) {
+		return
+	}
+	if _, ok := reportRejectionReasons[reason]; !ok || reason == expiredRejectionReason {
+		v.AddError("reason", "must be one of: "+strings.Join(codes, ", "))
+		return
+	}
+	v.Check(reason != "other" || note != "", "note", "is required when the reason is other")
+}
+
+// canSeeRejected reports whether a user may still read a rejected report:
+// its reporter, platform verifiers and the filing organization's verifiers
+func (h *ReportHandler) canSeeRejected(userID, reporterID, orgID string) bool {
+	return canSeeRejectedReport(h.db, userID, reporterID, orgID)
+}
+
+// canSeeRejectedReport is canSeeRejected for handlers outside ReportHandler
+func canSeeRejectedReport(db *sql.DB, userID, reporterID, orgID string) bool {
+	if userID == "" {
+		return false
+	}
+	if userID == reporterID {
+		return true
+	}
+	if role, err := middleware.LookupRole(db, userID); err == nil && role.Can(middleware.PermVerifyReports) {
+		return true
+	}
+	if orgID == "" {
+		return false
+	}
+	allowed, err := hasOrganizationPermission(db, orgID, userID, OrgPermVerifyReports)
+	return err == nil && allowed
+}
+
+// nextReportStatuses lists where a report in status can go, for error messages
+func nextReportStatuses(status ReportStatus) []string {
+	next := []string{}
+	for to := range reportTransitions[status] {
+		next = append(next, string(to))
+	}
+	sort.Strings(next)
+	return next
+}
+
+// UpdateReportStatus moves a report along its lifecycle:
+// pending → verified → in_progress → resolved → closed, or to rejected
+func (h *ReportHandler) UpdateReportStatus(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	var request struct {
+		Status ReportStatus `json:"status"`
+		Note   string       `json:"note"`
+		Reason string       `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Note = strings.TrimSpace(request.Note)
+
+	v := validation.New()
+	_, known := reportTransitions[request.Status]
+	v.Check(known || request.Status == ReportClosed, "status",
+		"must be one of: pending, verified, in_progress, resolved, closed, rejected")
+	v.Length("note", request.Note, 0, maxStatusNoteLength)
+	if request.Status == ReportRejected {
+		validateRejection(v, request.Reason, request.Note)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	// Verifying takes a quorum of verifiers, so it is a vote rather than a move
+	if request.Status == ReportVerified {
+		h.castVote(w, r, reportID, VoteApprove, "", request.Note)
+		return
+	}
+
+	from, ok := h.changeStatus(w, r, reportID, statusChange{To: request.Status, Note: request.Note, Reason: request.Reason})
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             reportID,
+		"previousStatus": from,
+		"status":         request.Status,
+		"nextStatuses":   nextReportStatuses(request.Status),
+	})
+}
+
+// RejectReport turns down a report with a reason code and an optional note,
+// which are shown to the reporter and emailed to them
+func (h *ReportHandler) RejectReport(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	var request struct {
+		Reason string `json:"reason"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Note = strings.TrimSpace(request.Note)
+
+	v := validation.New()
+	validateRejection(v, request.Reason, request.Note)
+	v.Length("note", request.Note, 0, maxStatusNoteLength)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	from, ok := h.changeStatus(w, r, reportID, statusChange{To: ReportRejected, Note: request.Note, Reason: request.Reason})
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             reportID,
+		"previousStatus": from,
+		"status":         ReportRejected,
+		"reason":         request.Reason,
+		"message":        "Report rejected",
+	})
+}
+
+// errStatusChanged means another transition got to the report first
+var errStatusChanged = errors.New("report status changed in the meantime")
+
+// commitStatusChange stores a transition already known to be allowed, with
+// its revision. An empty userID is the system acting on its own.
+func commitStatusChange(db *sql.DB, reportID, userID string, from ReportStatus, change statusChange) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := applyStatusChange(tx, reportID, userID, from, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applyStatusChange is commitStatusChange inside the caller's transaction
+func applyStatusChange(tx *sql.Tx, reportID, userID string, from ReportStatus, change statusChange) error {
+	to, note := change.To, change.Note
+
+	// The status guard makes concurrent transitions fail instead of both applying
+	set := "status = ?"
+	args := []interface{}{to}
+	switch {
+	case to == ReportVerified:
+		set += ", verified_by = UUID_TO_BIN(?), verified_at = NOW()"
+		args = append(args, userID)
+	case to == ReportRejected:
+		set += ", rejection_reason = ?, rejection_note = NULLIF(?, ''), rejected_by = UUID_TO_BIN(NULLIF(?, '')), rejected_at = NOW()"
+		args = append(args, change.Reason, note, userID)
+	case from == ReportRejected:
+		// Reopening starts a new verification round; earlier votes are kept
+		set += ", rejection_reason = NULL, rejection_note = NULL, rejected_by = NULL, rejected_at = NULL" +
+			", verification_round = verification_round + 1"
+	}
+	args = append(args, reportID, from)
+	result, err := tx.Exec(
+		"UPDATE disaster_reports SET "+set+", updated_at = NOW() WHERE id = UUID_TO_BIN(?) AND status = ? AND deleted_at IS NULL",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errStatusChanged
+	}
+	revisionNote := note
+	if to == ReportRejected {
+		revisionNote = change.Reason
+		if note != "" {
+			revisionNote += ": " + note
+		}
+	}
+	return recordRevision(tx, reportID, userID, map[string]FieldChange{"status": {from, to}}, revisionNote)
+}
+
+// changeStatus applies one transition. It writes the error response and
+// returns false when the move is not allowed or the report changed status
+// in the meantime.
+func (h *ReportHandler) changeStatus(w http.ResponseWriter, r *http.Request, reportID string, change statusChange) (ReportStatus, bool) {
+	userID := r.Context().Value("user_id").(string)
+	to := change.To
+
+	var from ReportStatus
+	err := h.db.QueryRow("SELECT status FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL", reportID).Scan(&from)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return from, false
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return from, false
+	}
+
+	permission, allowed := reportTransitions[from][to]
+	if !allowed {
+		apierror.Write(w, http.StatusConflict, apierror.CodeConflict,
+			"A "+string(from)+" report cannot become "+string(to),
+			map[string]interface{}{"status": from, "allowed": nextReportStatuses(from)})
+		return from, false
+	}
+	// Organization verifiers may decide on their organization's pending reports
+	if to == ReportVerified || (from == ReportPending && to == ReportRejected) {
+		if !h.canVerify(w, r, reportID, userID) {
+			return from, false
+		}
+	} else {
+		role, ok := r.Context().Value("role").(middleware.Role)
+		if !ok {
+			apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return from, false
+		}
+		if !role.Can(permission) {
+			apierror.Error(w, "Moving a report to "+string(to)+" requires "+string(permission), http.StatusForbidden)
+			return from, false
+		}
+	}
+
+	if err := commitStatusChange(h.db, reportID, userID, from, change); err == errStatusChanged {
+		apierror.Error(w, "Report status changed in the meantime; reload and try again", http.StatusConflict)
+		return from, false
+	} else if err != nil {
+		apierror.Error(w, "Error updating report status", http.StatusInternalServerError)
+		return from, false
+	}
+	h.statusChanged(r, reportID, userID, from, change)
+	return from, true
+}
+
+// statusChanged audits and announces a transition once it is committed
+func (h *ReportHandler) statusChanged(r *http.Request, reportID, userID string, from ReportStatus, change statusChange) {
+	to, note := change.To, change.Note
+	details := map[string]interface{}{"from": from, "to": to}
+	if note != "" {
+		details["note"] = note
+	}
+	if to == ReportRejected {
+		details["reason"] = change.Reason
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportStatusChanged,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+		Details:    details,
+	})
+
+	recipients := reportWebhookRecipients(h.db, reportID)
+	h.webhooks.Publish(webhooks.EventReportStatusChanged, recipients, map[string]interface{}{
+		"id":             reportID,
+		"previousStatus": from,
+		"status":         to,
+		"changedBy":      userID,
+	})
+	if to == ReportVerified {
+		h.webhooks.Publish(webhooks.EventReportVerified, recipients, map[string]interface{}{
+			"id":         reportID,
+			"status":     "verified",
+			"verifiedBy": userID,
+		})
+		h.alerts.ReportVerified(reportID)
+	}
+	if to == ReportRejected {
+		h.alerts.ReportRejected(reportID, reportRejectionReasons[change.Reason], note)
+		// Followers get the reason's wording, not the verifier's note
+		note = reportRejectionReasons[change.Reason]
+	}
+	h.alerts.ReportStatusChanged(reportID, string(to), note)
+}
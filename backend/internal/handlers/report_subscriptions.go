@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// ReportSubscription is a report the user follows
+type ReportSubscription struct {
+	ReportID     string    `json:"reportId"`
+	Title        string    `json:"title"`
+	Status       string    `json:"status"`
+	Severity     string    `json:"severity"`
+	SubscribedAt time.Time `json:"subscribedAt"`
+}
+
+// SubscribeReport makes the signed-in user follow a report, so they hear
+// about each status change. Following twice is not an error.
+func (h *ReportHandler) SubscribeReport(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var status ReportStatus
+	err := h.db.QueryRow(
+		"SELECT status FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL",
+		reportID,
+	).Scan(&status)
+	if err == sql.ErrNoRows || status == ReportRejected {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = h.db.Exec(
+		"INSERT IGNORE INTO report_subscriptions (report_id, user_id) VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?))",
+		reportID, userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error following report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reportId":   reportID,
+		"subscribed": true,
+	})
+}
+
+// UnsubscribeReport stops following a report
+func (h *ReportHandler) UnsubscribeReport(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	_, err := h.db.Exec(
+		"DELETE FROM report_subscriptions WHERE report_id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)",
+		reportID, userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error unfollowing report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reportId":   reportID,
+		"subscribed": false,
+	})
+}
+
+// ListSubscriptions lists the reports the signed-in user follows, most
+// recently followed first
+func (h *ReportHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(dr.id), dr.title, dr.status, dr.severity, s.created_at
+		FROM report_subscriptions s
+		JOIN disaster_reports dr ON dr.id = s.report_id AND dr.deleted_at IS NULL
+		WHERE s.user_id = UUID_TO_BIN(?)
+		ORDER BY s.created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching followed reports", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	subscriptions := []ReportSubscription{}
+	for rows.Next() {
+		var s ReportSubscription
+		if err := rows.Scan(&s.ReportID, &s.Title, &s.Status, &s.Severity, &s.SubscribedAt); err != nil {
+			apierror.Error(w, "Error processing followed reports", http.StatusInternalServerError)
+			return
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/validation"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+const (
+	VoteApprove = "approve"
+	VoteReject  = "reject"
+)
+
+// verificationQuorum is the N-of-M rule for verifying a report: it is
+// verified once Required verifiers approve, and rejected once so many reject
+// that Required approvals out of Of votes can no longer be reached
+type verificationQuorum struct {
+	Required int `json:"required"`
+	Of       int `json:"of"`
+}
+
+// rejectAt is the number of rejections that decides a report
+func (q verificationQuorum) rejectAt() int {
+	return q.Of - q.Required + 1
+}
+
+// ParseVerificationQuorum reads REPORT_VERIFICATION_QUORUM, "N/M". Empty
+// means 1/1: one verifier decides alone.
+func ParseVerificationQuorum(raw string) (required, of int, err error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 1, 1, nil
+	}
+	n, m, ok := strings.Cut(raw, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected N/M, got %q", raw)
+	}
+	required, err = strconv.Atoi(strings.TrimSpace(n))
+	if err != nil || required < 1 {
+		return 0, 0, fmt.Errorf("N must be a positive integer")
+	}
+	of, err = strconv.Atoi(strings.TrimSpace(m))
+	if err != nil || of < required {
+		return 0, 0, fmt.Errorf("M must be an integer of at least N")
+	}
+	return required, of, nil
+}
+
+// SetVerificationQuorum changes how many verifiers must approve a report
+func (h *ReportHandler) SetVerificationQuorum(required, of int) {
+	h.quorum = verificationQuorum{Required: required, Of: of}
+}
+
+// VerificationVote is one verifier's decision on a pending report. Reopening
+// a rejected report starts a new round, and only the current round's votes
+// count.
+type VerificationVote struct {
+	Round      int       `json:"round"`
+	VerifierID string    `json:"verifierId"`
+	Username   string    `json:"username"`
+	Decision   string    `json:"decision"`
+	Reason     *string   `json:"reason"`
+	Note       *string   `json:"note"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// SubmitVerification records the signed-in verifier's vote on a pending
+// report; the vote that reaches the quorum verifies or rejects it
+func (h *ReportHandler) SubmitVerification(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+
+	var request struct {
+		Decision string `json:"decision"`
+		Reason   string `json:"reason"`
+		Note     string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Note = strings.TrimSpace(request.Note)
+
+	v := validation.New()
+	v.Check(request.Decision == VoteApprove || request.Decision == VoteReject, "decision", "must be approve or reject")
+	v.Length("note", request.Note, 0, maxStatusNoteLength)
+	if request.Decision == VoteReject {
+		validateRejection(v, request.Reason, request.Note)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	h.castVote(w, r, reportID, request.Decision, request.Reason, request.Note)
+}
+
+// castVote stores a vote and applies the outcome when it completes the
+// quorum. The status changes in the vote's transaction, so a vote whose
+// transition fails is not kept and the next one tries again.
+func (h *ReportHandler) castVote(w http.ResponseWriter, r *http.Request, reportID, decision, reason, note string) {
+	userID := r.Context().Value("user_id").(string)
+	if !h.canVerify(w, r, reportID, userID) {
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var reporterID string
+	var status ReportStatus
+	var round int
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(reporter_id), status, verification_round
+		FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL FOR UPDATE`,
+		reportID,
+	).Scan(&reporterID, &status, &round)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if reporterID == userID {
+		apierror.Error(w, "You cannot verify your own report", http.StatusForbidden)
+		return
+	}
+	if status != ReportPending {
+		apierror.Error(w, "Only pending reports take verification votes; this one is "+string(status), http.StatusConflict)
+		return
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO report_verification_votes (report_id, round, verifier_id, decision, reason, note)
+		VALUES (UUID_TO_BIN(?), ?, UUID_TO_BIN(?), ?, NULLIF(?, ''), NULLIF(?, ''))`,
+		reportID, round, userID, decision, reason, note,
+	)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			apierror.Error(w, "You have already voted on this report", http.StatusConflict)
+			return
+		}
+		apierror.Error(w, "Error recording vote", http.StatusInternalServerError)
+		return
+	}
+
+	var approvals, rejections int
+	if err := tx.QueryRow(
+		`SELECT COALESCE(SUM(decision = 'approve'), 0), COALESCE(SUM(decision = 'reject'), 0)
+		FROM report_verification_votes WHERE report_id = UUID_TO_BIN(?) AND round = ?`,
+		reportID, round,
+	).Scan(&approvals, &rejections); err != nil {
+		apierror.Error(w, "Error recording vote", http.StatusInternalServerError)
+		return
+	}
+
+	// The report is locked and still pending, so a vote at or past the
+	// threshold moves it
+	var outcome *statusChange
+	q := h.quorum
+	switch {
+	case decision == VoteApprove && approvals >= q.Required:
+		outcome = &statusChange{To: ReportVerified, Note: fmt.Sprintf("Approved by %d of %d verifiers", approvals, approvals+rejections)}
+	case decision == VoteReject && rejections >= q.rejectAt():
+		outcome = &statusChange{To: ReportRejected, Note: note, Reason: reason}
+	}
+	if outcome != nil {
+		if err := applyStatusChange(tx, reportID, userID, status, *outcome); err != nil {
+			apierror.Error(w, "Error updating report status", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error recording vote", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventReportVerificationVote,
+		Severity:   audit.SeverityMedium,
+		UserID:     userID,
+		EntityType: "disaster_report",
+		EntityID:   reportID,
+		Details:    map[string]interface{}{"decision": decision, "reason": reason, "approvals": approvals, "rejections": rejections},
+	})
+
+	if outcome != nil {
+		h.statusChanged(r, reportID, userID, status, *outcome)
+		status = outcome.To
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         reportID,
+		"decision":   decision,
+		"approvals":  approvals,
+		"rejections": rejections,
+		"quorum":     q,
+		"status":     status,
+	})
+}
+
+// ListVerifications shows every vote cast on a report with the running tally
+func (h *ReportHandler) ListVerifications(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+	if !h.canVerify(w, r, reportID, userID) {
+		return
+	}
+
+	var round int
+	err := h.db.QueryRow(
+		"SELECT verification_round FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL",
+		reportID,
+	).Scan(&round)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT v.round, BIN_TO_UUID(v.verifier_id), u.username, v.decision, v.reason, v.note, v.created_at
+		FROM report_verification_votes v
+		JOIN users u ON u.id = v.verifier_id
+		WHERE v.report_id = UUID_TO_BIN(?)
+		ORDER BY v.round, v.created_at`,
+		reportID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching votes", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	votes := []VerificationVote{}
+	approvals, rejections := 0, 0
+	for rows.Next() {
+		var vote VerificationVote
+		if err := rows.Scan(&vote.Round, &vote.VerifierID, &vote.Username, &vote.Decision, &vote.Reason, &vote.Note, &vote.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing votes", http.StatusInternalServerError)
+			return
+		}
+		if vote.Round == round && vote.Decision == VoteApprove {
+			approvals++
+		} else if vote.Round == round {
+			rejections++
+		}
+		votes = append(votes, vote)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"votes":      votes,
+		"round":      round,
+		"approvals":  approvals,
+		"rejections": rejections,
+		"quorum":     h.quorum,
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"saferelief/internal/fakedb"
+	"saferelief/internal/middleware"
+)
+
+// A status change that fails once quorum is reached must not leave the vote
+// behind, or later votes would count past the threshold and the report
+// would stay pending for good
+func TestCastVoteFailedStatusChangeKeepsNoVote(t *testing.T) {
+	for _, approvals := range []int64{2, 3} {
+		db, f := fakedb.New(t,
+			fakedb.Rule{Match: "FOR UPDATE", Rows: [][]driver.Value{{gqlTestReporter, "pending", int64(1)}}},
+			fakedb.Rule{Match: "INSERT INTO report_verification_votes"},
+			fakedb.Rule{Match: "FROM report_verification_votes", Rows: [][]driver.Value{{approvals, int64(0)}}},
+			fakedb.Rule{Match: "UPDATE disaster_reports", Err: errors.New("lock wait timeout exceeded")},
+		)
+		h := NewReportHandler(db, nil, nil, nil)
+		h.SetVerificationQuorum(2, 3)
+
+		r := httptest.NewRequest("POST", "/api/reports/r1/verify", nil)
+		ctx := context.WithValue(r.Context(), "user_id", gqlTestStranger)
+		ctx = context.WithValue(ctx, "role", middleware.RoleVerifier)
+		w := httptest.NewRecorder()
+		h.castVote(w, r.WithContext(ctx), "44444444-4444-4444-4444-444444444444", VoteApprove, "", "")
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%d approvals: status = %d, want 500", approvals, w.Code)
+		}
+		if !f.Ran("UPDATE disaster_reports") {
+			t.Errorf("%d approvals: the report was not moved", approvals)
+		}
+		if f.Ran("COMMIT") || !f.Ran("ROLLBACK") {
+			t.Errorf("%d approvals: the vote was committed without the status change: %v", approvals, f.Log)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/triage"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+type ReporterProfile struct {
+	ID               string    `json:"id"`
+	DisplayName      string    `json:"displayName"`
+	AvatarURL        *string   `json:"avatarUrl"`
+	TotalReports     int       `json:"totalReports"`
+	VerifiedReports  int       `json:"verifiedReports"`
+	VerificationRate float64   `json:"verificationRate"`
+	TrustScore       int       `json:"trustScore"`
+	MemberSince      time.Time `json:"memberSince"`
+}
+
+type QueuedReport struct {
+	DisasterReport
+	ReporterTrustScore int `json:"reporterTrustScore"`
+	// PriorityScore is the triage score from 0 to 100 given when the report
+	// was filed, with the points behind it in PriorityFactors
+	PriorityScore   *int            `json:"priorityScore"`
+	PriorityFactors json.RawMessage `json:"priorityFactors"`
+	// OfficialMatch is the cross-check status against official records:
+	// matched, no_match or failed; nil until the report has been checked
+	OfficialMatch *string `json:"officialMatch"`
+}
+
+// GetReporterProfile is public and exposes no contact details
+func (h *ReportHandler) GetReporterProfile(w http.ResponseWriter, r *http.Request) {
+	reporterID := mux.Vars(r)["id"]
+
+	var profile ReporterProfile
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(u.id), u.username, u.avatar_url, u.created_at,
+		COUNT(dr.id), COALESCE(SUM(dr.status IN (`+verifiedReportStatuses+`)), 0)
+		FROM users u
+		LEFT JOIN disaster_reports dr ON dr.reporter_id = u.id AND dr.deleted_at IS NULL
+		WHERE u.id = UUID_TO_BIN(?) AND u.deleted_at IS NULL
+		GROUP BY u.id`,
+		reporterID,
+	).Scan(
+		&profile.ID, &profile.DisplayName, &profile.AvatarURL, &profile.MemberSince,
+		&profile.TotalReports, &profile.VerifiedReports,
+	)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Reporter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching reporter", http.StatusInternalServerError)
+		return
+	}
+
+	if profile.TotalReports > 0 {
+		profile.VerificationRate = float64(profile.VerifiedReports) / float64(profile.TotalReports)
+	}
+	profile.TrustScore = triage.TrustScore(profile.VerifiedReports, profile.TotalReports)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// verificationQueueSorts orders the queue: by triage score, by how reliable
+// the reporter has been, or first come first served. Unscored reports go last.
+var verificationQueueSorts = map[string]string{
+	"priority": "dr.priority_score IS NULL, dr.priority_score DESC, dr.created_at ASC",
+	"trust":    "(stats.verified + 1) / (stats.total + 2) DESC, dr.created_at ASC",
+	"oldest":   "dr.created_at ASC",
+}
+
+// VerificationQueue lists pending reports, highest triage score first unless
+// another sort is asked for. Reports from suspended or banned reporters are
+// held back until the restriction ends.
+func (h *ReportHandler) VerificationQueue(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	offset := 0
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "priority"
+	}
+	orderBy, ok := verificationQueueSorts[sort]
+	if !ok {
+		v := validation.New()
+		v.AddError("sort", "must be one of: priority, trust, oldest")
+		v.WriteError(w)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(dr.id), BIN_TO_UUID(dr.reporter_id), dr.title, dr.description, dr.disaster_type,
+		dr.latitude, dr.longitude, dr.severity, dr.status, BIN_TO_UUID(dr.verified_by),
+		dr.created_at, dr.updated_at, stats.verified, stats.total, rc.status,
+		dr.priority_score, dr.priority_factors
+		FROM disaster_reports dr
+		LEFT JOIN report_crosschecks rc ON rc.report_id = dr.id
+		JOIN (
+			SELECT reporter_id, COUNT(*) AS total,
+			SUM(status IN (`+verifiedReportStatuses+`)) AS verified
+			FROM disaster_reports WHERE deleted_at IS NULL GROUP BY reporter_id
+		) stats ON stats.reporter_id = dr.reporter_id
+		WHERE dr.status = 'pending' AND dr.deleted_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM user_suspensions us
+			WHERE us.user_id = dr.reporter_id AND us.lifted_at IS NULL
+			AND (us.expires_at IS NULL OR us.expires_at > NOW())
+		)
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching verification queue", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	queue := []QueuedReport{}
+	for rows.Next() {
+		var item QueuedReport
+		var verified, total int
+		var factors []byte
+		if err := rows.Scan(
+			&item.ID, &item.ReporterID, &item.Title, &item.Description, &item.DisasterType,
+			&item.Latitude, &item.Longitude, &item.Severity, &item.Status,
+			&item.VerifiedBy, &item.CreatedAt, &item.UpdatedAt, &verified, &total, &item.OfficialMatch,
+			&item.PriorityScore, &factors,
+		); err != nil {
+			apierror.Error(w, "Error processing verification queue", http.StatusInternalServerError)
+			return
+		}
+		item.ReporterTrustScore = triage.TrustScore(verified, total)
+		item.PriorityFactors = factors
+		queue = append(queue, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queue)
+}
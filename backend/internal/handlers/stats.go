@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+type UserStats struct {
+	UserID                 string             `json:"userId"`
+	ReportsFiled           int                `json:"reportsFiled"`
+	ReportsVerified        int                `json:"reportsVerified"`
+	VerificationsPerformed int                `json:"verificationsPerformed"`
+	TotalDonated           map[string]float64 `json:"totalDonated"`
+	DonationCount          int                `json:"donationCount"`
+	ReportsSupported       int                `json:"reportsSupported"`
+	JoinedAt               time.Time          `json:"joinedAt"`
+	// TotalDonatedIDR and TotalDonatedUSD add up every currency, each
+	// donation at its day's rate
+	TotalDonatedIDR float64 `json:"totalDonatedIdr"`
+	TotalDonatedUSD float64 `json:"totalDonatedUsd"`
+}
+
+// GetStats returns contribution counters for a user. Users may read their own
+// stats (id "me" or their own id); anyone else's require admin access.
+func (h *UserHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	requesterID := r.Context().Value("user_id").(string)
+	userID := mux.Vars(r)["id"]
+	if userID == "me" {
+		userID = requesterID
+	}
+
+	if userID != requesterID {
+		role, err := middleware.LookupRole(h.db, requesterID)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !role.Can(middleware.PermAdminAccess) {
+			apierror.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	stats := UserStats{UserID: userID, TotalDonated: map[string]float64{}}
+	err := h.db.QueryRow(
+		`SELECT u.created_at,
+			(SELECT COUNT(*) FROM disaster_reports WHERE reporter_id = u.id AND deleted_at IS NULL),
+			(SELECT COUNT(*) FROM disaster_reports WHERE reporter_id = u.id AND deleted_at IS NULL AND status IN (`+verifiedReportStatuses+`)),
+			(SELECT COUNT(*) FROM disaster_reports WHERE verified_by = u.id AND deleted_at IS NULL),
+			(SELECT COUNT(*) FROM donations WHERE donor_id = u.id AND status = 'completed'),
+			(SELECT COUNT(DISTINCT disaster_report_id) FROM donations WHERE donor_id = u.id AND status = 'completed')
+		FROM users u WHERE u.id = UUID_TO_BIN(?)`,
+		userID,
+	).Scan(
+		&stats.JoinedAt, &stats.ReportsFiled, &stats.ReportsVerified,
+		&stats.VerificationsPerformed, &stats.DonationCount, &stats.ReportsSupported,
+	)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching user stats", http.StatusInternalServerError)
+		return
+	}
+
+	// Donations can be in several currencies, so totals are kept per currency
+	rows, err := h.db.Query(
+		`SELECT currency, SUM(amount), COALESCE(SUM(amount_idr), 0), COALESCE(SUM(amount_usd), 0) FROM donations
+		WHERE donor_id = UUID_TO_BIN(?) AND status = 'completed'
+		GROUP BY currency`,
+		userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching user stats", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var currency string
+		var total, totalIDR, totalUSD float64
+		if err := rows.Scan(&currency, &total, &totalIDR, &totalUSD); err != nil {
+			apierror.Error(w, "Error fetching user stats", http.StatusInternalServerError)
+			return
+		}
+		stats.TotalDonated[currency] = total
+		stats.TotalDonatedIDR += totalIDR
+		stats.TotalDonatedUSD += totalUSD
+	}
+	if err := rows.Err(); err != nil {
+		apierror.Error(w, "Error fetching user stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
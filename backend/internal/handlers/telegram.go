@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/consent"
+	"saferelief/internal/inbound"
+	"saferelief/internal/telegram"
+	"saferelief/internal/tokens"
+	"saferelief/internal/triage"
+	"saferelief/internal/webhooks"
+)
+
+const (
+	telegramLinkCodeTTL = 15 * time.Minute
+	telegramDraftTTL    = time.Hour
+)
+
+// Steps of the chat-based report intake, in order
+const (
+	draftStepTitle    = "title"
+	draftStepLocation = "location"
+	draftStepSeverity = "severity"
+	draftStepPhoto    = "photo"
+)
+
+var reportSeverities = map[string]bool{"low": true, "medium": true, "high": true, "critical": true}
+
+type TelegramHandler struct {
+	db       *sql.DB
+	bot      *telegram.Bot
+	webhooks *webhooks.Dispatcher
+}
+
+func NewTelegramHandler(db *sql.DB, bot *telegram.Bot, dispatcher *webhooks.Dispatcher) *TelegramHandler {
+	return &TelegramHandler{db: db, bot: bot, webhooks: dispatcher}
+}
+
+func (h *TelegramHandler) GetLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var username sql.NullString
+	var alertsEnabled bool
+	var linkedAt time.Time
+	err := h.db.QueryRow(
+		`SELECT telegram_username, alerts_enabled, linked_at
+		FROM telegram_links WHERE user_id = UUID_TO_BIN(?)`,
+		userID,
+	).Scan(&username, &alertsEnabled, &linkedAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err == sql.ErrNoRows {
+		json.NewEncoder(w).Encode(map[string]interface{}{"linked": false})
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching Telegram link", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"linked":        true,
+		"username":      username.String,
+		"alertsEnabled": alertsEnabled,
+		"linkedAt":      linkedAt,
+	})
+}
+
+// StartLink issues a one-time code; sending "/start <code>" to the bot links
+// that chat to the account
+func (h *TelegramHandler) StartLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	if !h.bot.Enabled() {
+		apierror.Error(w, "Telegram integration is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	code, codeHash, err := tokens.Generate()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(telegramLinkCodeTTL)
+
+	_, err = h.db.Exec(
+		`INSERT INTO telegram_link_codes (user_id, code_hash, expires_at)
+		VALUES (UUID_TO_BIN(?), ?, ?)
+		ON DUPLICATE KEY UPDATE code_hash = VALUES(code_hash), expires_at = VALUES(expires_at)`,
+		userID, codeHash, expiresAt,
+	)
+	if err != nil {
+		apierror.Error(w, "Error creating link code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":      code,
+		"link":      h.bot.DeepLink(code),
+		"expiresAt": expiresAt.UTC(),
+	})
+}
+
+func (h *TelegramHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	result, err := h.db.Exec("DELETE FROM telegram_links WHERE user_id = UUID_TO_BIN(?)", userID)
+	if err != nil {
+		apierror.Error(w, "Error unlinking Telegram", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "No Telegram account is linked", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Telegram unlinked"})
+}
+
+// RegisterInbound adds the bot's webhook to the inbound receiver when the
+// bot is configured
+func (h *TelegramHandler) RegisterInbound(receiver *inbound.Receiver) {
+	if !h.bot.Enabled() {
+		return
+	}
+	// A retried update would repeat the bot's reply, so it is tried once
+	receiver.Register("telegram", inbound.Provider{
+		Verify:      h.verifyUpdate,
+		Process:     h.processUpdate,
+		MaxAttempts: 1,
+	})
+}
+
+func (h *TelegramHandler) verifyUpdate(r *http.Request, body []byte) (string, error) {
+	if !h.bot.VerifyRequest(r) {
+		return "", inbound.ErrInvalidSignature
+	}
+	var update telegram.Update
+	if err := json.Unmarshal(body, &update); err != nil {
+		return "", inbound.ErrInvalidPayload
+	}
+	return strconv.FormatInt(update.UpdateID, 10), nil
+}
+
+func (h *TelegramHandler) processUpdate(ctx context.Context, payload []byte) error {
+	var update telegram.Update
+	if err := json.Unmarshal(payload, &update); err != nil {
+		return err
+	}
+	// Only private chats, so one person's report steps can't be mixed with another's
+	if msg := update.Message; msg != nil && msg.Chat.Type == "private" {
+		reply := h.handleMessage(ctx, msg)
+		if reply != "" {
+			if err := h.bot.SendMessage(ctx, msg.Chat.ID, reply); err != nil {
+				log.Printf("Failed to reply to Telegram chat %d: %v", msg.Chat.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (h *TelegramHandler) handleMessage(ctx context.Context, msg *telegram.Message) string {
+	command, arg := parseTelegramCommand(msg.Text)
+	if command == "/start" && arg != "" {
+		return h.link(msg, arg)
+	}
+
+	userID, err := h.linkedUser(msg.Chat.ID)
+	if err == sql.ErrNoRows {
+		return "Link your SafeRelief account first: open Settings → Telegram in the app and follow the link."
+	}
+	if err != nil {
+		return "Something went wrong, please try again later."
+	}
+
+	switch command {
+	case "/start", "/help":
+		return "Commands:\n/report - submit a disaster report\n/cancel - discard the report in progress\n/alerts on|off - verified disaster alerts\n/unlink - disconnect this chat"
+	case "/report":
+		if reason := h.reportingBlocked(userID); reason != "" {
+			return reason
+		}
+		h.db.Exec(
+			`REPLACE INTO telegram_report_drafts (chat_id, user_id, step) VALUES (?, UUID_TO_BIN(?), ?)`,
+			msg.Chat.ID, userID, draftStepTitle,
+		)
+		return "New report. What happened? Send a short title."
+	case "/cancel":
+		h.db.Exec("DELETE FROM telegram_report_drafts WHERE chat_id = ?", msg.Chat.ID)
+		return "Report discarded."
+	case "/alerts":
+		enabled := arg != "off"
+		h.db.Exec("UPDATE telegram_links SET alerts_enabled = ? WHERE chat_id = ?", enabled, msg.Chat.ID)
+		if enabled {
+			return "You'll receive alerts for newly verified disasters."
+		}
+		return "Alerts turned off."
+	case "/unlink":
+		h.db.Exec("DELETE FROM telegram_links WHERE chat_id = ?", msg.Chat.ID)
+		h.db.Exec("DELETE FROM telegram_report_drafts WHERE chat_id = ?", msg.Chat.ID)
+		return "This chat is no longer linked to SafeRelief."
+	case "":
+		return h.continueDraft(ctx, userID, msg)
+	}
+	return "Unknown command. Send /help for the list."
+}
+
+// link consumes a code from StartLink and attaches the chat to its account
+func (h *TelegramHandler) link(msg *telegram.Message, code string) string {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return "Something went wrong, please try again later."
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(user_id) FROM telegram_link_codes
+		WHERE code_hash = ? AND expires_at > NOW() FOR UPDATE`,
+		tokens.Hash(code),
+	).Scan(&userID)
+	if err != nil {
+		return "This link has expired. Generate a new one in the SafeRelief app."
+	}
+
+	username := ""
+	if msg.From != nil {
+		username = msg.From.Username
+	}
+	// A chat and an account can each be linked only once
+	if _, err := tx.Exec(
+		"DELETE FROM telegram_links WHERE chat_id = ? OR user_id = UUID_TO_BIN(?)",
+		msg.Chat.ID, userID,
+	); err != nil {
+		return "Something went wrong, please try again later."
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO telegram_links (chat_id, user_id, telegram_username)
+		VALUES (?, UUID_TO_BIN(?), NULLIF(?, ''))`,
+		msg.Chat.ID, userID, username,
+	); err != nil {
+		return "Something went wrong, please try again later."
+	}
+	tx.Exec("DELETE FROM telegram_link_codes WHERE user_id = UUID_TO_BIN(?)", userID)
+	if err := tx.Commit(); err != nil {
+		return "Something went wrong, please try again later."
+	}
+
+	return "Linked to your SafeRelief account. Send /report to submit a disaster report or /help for more."
+}
+
+func (h *TelegramHandler) linkedUser(chatID int64) (string, error) {
+	var userID string
+	err := h.db.QueryRow(
+		"SELECT BIN_TO_UUID(user_id) FROM telegram_links WHERE chat_id = ?",
+		chatID,
+	).Scan(&userID)
+	return userID, err
+}
+
+// reportingBlocked applies the checks the web API enforces through its
+// middleware: active, unsuspended accounts that accepted current policies
+func (h *TelegramHandler) reportingBlocked(userID string) string {
+	var status string
+	var deleted bool
+	err := h.db.QueryRow(
+		"SELECT status, deleted_at IS NOT NULL FROM users WHERE id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&status, &deleted)
+	if err != nil || status != "active" || deleted {
+		return "Your SafeRelief account can't submit reports."
+	}
+	if suspension, err := accounts.ActiveSuspension(h.db, userID); err != nil || suspension != nil {
+		return "Your SafeRelief account is suspended."
+	}
+	if outstanding, err := consent.Outstanding(h.db, userID); err != nil || len(outstanding) > 0 {
+		return "Please sign in to the SafeRelief app and accept the updated policies first."
+	}
+	return ""
+}
+
+// continueDraft advances the report in progress with the next answer
+func (h *TelegramHandler) continueDraft(ctx context.Context, userID string, msg *telegram.Message) string {
+	var step, title, severity string
+	var latitude, longitude sql.NullFloat64
+	err := h.db.QueryRow(
+		`SELECT step, COALESCE(title, ''), latitude, longitude, COALESCE(severity, '')
+		FROM telegram_report_drafts
+		WHERE chat_id = ? AND user_id = UUID_TO_BIN(?) AND updated_at > ?`,
+		msg.Chat.ID, userID, time.Now().Add(-telegramDraftTTL),
+	).Scan(&step, &title, &latitude, &longitude, &severity)
+	if err != nil {
+		return "Send /report to submit a disaster report or /help for more."
+	}
+
+	switch step {
+	case draftStepTitle:
+		text := strings.TrimSpace(msg.Text)
+		if len(text) < 5 || len(text) > 255 {
+			return "Please send a title between 5 and 255 characters."
+		}
+		h.advanceDraft(msg.Chat.ID, draftStepLocation, "title = ?", text)
+		return "Share the location: tap 📎 → Location."
+	case draftStepLocation:
+		if msg.Location == nil {
+			return "Please share a location: tap 📎 → Location."
+		}
+		h.advanceDraft(msg.Chat.ID, draftStepSeverity, "latitude = ?, longitude = ?", msg.Location.Latitude, msg.Location.Longitude)
+		return "How severe is it? Reply low, medium, high or critical."
+	case draftStepSeverity:
+		answer := strings.ToLower(strings.TrimSpace(msg.Text))
+		if !reportSeverities[answer] {
+			return "Reply low, medium, high or critical."
+		}
+		h.advanceDraft(msg.Chat.ID, draftStepPhoto, "severity = ?", answer)
+		return "Finally, send a photo with a caption describing the situation."
+	case draftStepPhoto:
+		if len(msg.Photo) == 0 || strings.TrimSpace(msg.Caption) == "" {
+			return "Please send a photo with a caption describing the situation."
+		}
+		reportID, err := h.submitDraft(ctx, userID, title, severity, latitude.Float64, longitude.Float64, msg)
+		if err != nil {
+			log.Printf("Telegram report submission failed for chat %d: %v", msg.Chat.ID, err)
+			if err == telegram.ErrFileTooLarge {
+				return "That photo is too large (max 5MB). Please send a smaller one."
+			}
+			return "We couldn't save your report. Please try again."
+		}
+		return fmt.Sprintf("Thank you. Report %s was submitted and is awaiting verification.", reportID)
+	}
+	return "Send /report to start again."
+}
+
+func (h *TelegramHandler) advanceDraft(chatID int64, next, assignments string, args ...interface{}) {
+	args = append([]interface{}{next}, args...)
+	args = append(args, chatID)
+	if _, err := h.db.Exec(
+		"UPDATE telegram_report_drafts SET step = ?, "+assignments+", updated_at = NOW() WHERE chat_id = ?",
+		args...,
+	); err != nil {
+		log.Printf("Failed to update Telegram draft for chat %d: %v", chatID, err)
+	}
+}
+
+func (h *TelegramHandler) submitDraft(ctx context.Context, userID, title, severity string, latitude, longitude float64, msg *telegram.Message) (string, error) {
+	// Telegram lists sizes smallest first
+	photo := msg.Photo[len(msg.Photo)-1]
+	data, filePath, err := h.bot.Download(ctx, photo.FileID, maxFileSize)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(filePath)
+	if !strings.Contains(allowedTypes, strings.ToLower(path.Ext(name))) {
+		return "", fmt.Errorf("unsupported photo type %s", path.Ext(name))
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var reportID string
+	err = tx.QueryRow(
+		`INSERT INTO disaster_reports (id, reporter_id, title, description, latitude, longitude, severity, status)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, ?, ?, 'pending')
+		RETURNING BIN_TO_UUID(id)`,
+		userID, title, strings.TrimSpace(msg.Caption), latitude, longitude, severity,
+	).Scan(&reportID)
+	if err != nil {
+		return "", err
+	}
+	if err := storeReportFile(tx, reportID, userID, name, "image/jpeg", int64(len(data)), bytes.NewReader(data), ""); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("DELETE FROM telegram_report_drafts WHERE chat_id = ?", msg.Chat.ID); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	h.webhooks.Publish(webhooks.EventReportCreated, reportWebhookRecipients(h.db, reportID),
+		map[string]interface{}{
+			"id":       reportID,
+			"title":    title,
+			"severity": severity,
+			"status":   "pending",
+			"source":   "telegram",
+		},
+	)
+	if _, err := triage.ScoreNew(ctx, h.db, reportID); err != nil {
+		log.Printf("Failed to score report %s: %v", reportID, err)
+	}
+	return reportID, nil
+}
+
+// parseTelegramCommand splits "/cmd@BotName arg" into "/cmd" and "arg"
+func parseTelegramCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	command, arg, _ := strings.Cut(text, " ")
+	command, _, _ = strings.Cut(command, "@")
+	return strings.ToLower(command), strings.TrimSpace(arg)
+}
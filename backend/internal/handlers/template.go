@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/notify"
+	"saferelief/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+type TemplateHandler struct {
+	templates   *notify.Templates
+	auditLogger *audit.Logger
+}
+
+func NewTemplateHandler(templates *notify.Templates, auditLogger *audit.Logger) *TemplateHandler {
+	return &TemplateHandler{templates: templates, auditLogger: auditLogger}
+}
+
+// ListTemplates returns every notification with its current version per locale
+func (h *TemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	type entry struct {
+		notify.Definition
+		Versions map[string]int `json:"versions"`
+	}
+
+	list := []entry{}
+	for _, def := range notify.Definitions() {
+		e := entry{Definition: def, Versions: map[string]int{}}
+		for _, locale := range notify.Locales() {
+			current, err := h.templates.Current(r.Context(), def.Key, locale)
+			if err != nil {
+				apierror.Error(w, "Error fetching templates", http.StatusInternalServerError)
+				return
+			}
+			e.Versions[locale] = current.Version
+		}
+		list = append(list, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetTemplate returns the current content and saved history for one locale
+func (h *TemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	key, locale, ok := templateParams(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.templates.Current(r.Context(), key, locale)
+	if err != nil {
+		apierror.Error(w, "Error fetching template", http.StatusInternalServerError)
+		return
+	}
+	history, err := h.templates.History(r.Context(), key, locale)
+	if err != nil {
+		apierror.Error(w, "Error fetching template history", http.StatusInternalServerError)
+		return
+	}
+	def, _ := notify.LookupDefinition(key)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"current":  current,
+		"history":  history,
+		"sample":   def.Sample,
+		"isCustom": current.Version > 0,
+	})
+}
+
+// UpdateTemplate saves new content as the next version
+func (h *TemplateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	key, locale, ok := templateParams(w, r)
+	if !ok {
+		return
+	}
+
+	var content notify.Content
+	if err := json.NewDecoder(r.Body).Decode(&content); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	h.save(w, r, key, locale, content, 0)
+}
+
+// PreviewTemplate renders the given content, or the current version when
+// none is given, with the template's sample data
+func (h *TemplateHandler) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	key, locale, ok := templateParams(w, r)
+	if !ok {
+		return
+	}
+
+	var content notify.Content
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&content); err != nil {
+			apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if content.Subject == "" && content.Body == "" {
+		current, err := h.templates.Current(r.Context(), key, locale)
+		if err != nil {
+			apierror.Error(w, "Error fetching template", http.StatusInternalServerError)
+			return
+		}
+		content = notify.Content{Subject: current.Subject, Body: current.Body}
+	}
+
+	rendered, err := notify.Preview(key, locale, content)
+	if err != nil {
+		v := validation.New()
+		v.AddError("template", err.Error())
+		v.WriteError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rendered)
+}
+
+// RestoreVersion saves an earlier version's content as the newest version
+func (h *TemplateHandler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	key, locale, ok := templateParams(w, r)
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		apierror.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.templates.History(r.Context(), key, locale)
+	if err != nil {
+		apierror.Error(w, "Error fetching template history", http.StatusInternalServerError)
+		return
+	}
+	for _, v := range history {
+		if v.Version == version {
+			h.save(w, r, key, locale, notify.Content{Subject: v.Subject, Body: v.Body}, version)
+			return
+		}
+	}
+	apierror.Error(w, "Version not found", http.StatusNotFound)
+}
+
+func (h *TemplateHandler) save(w http.ResponseWriter, r *http.Request, key, locale string, content notify.Content, restoredFrom int) {
+	adminID := r.Context().Value("user_id").(string)
+
+	v := validation.New()
+	if v.Required("subject", content.Subject) {
+		v.Length("subject", content.Subject, 1, 200)
+	}
+	if v.Required("body", content.Body) {
+		v.Length("body", content.Body, 1, 10000)
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+	if _, err := notify.Preview(key, locale, content); err != nil {
+		v.AddError("template", err.Error())
+		v.WriteError(w)
+		return
+	}
+
+	version, err := h.templates.Save(r.Context(), key, locale, content, adminID)
+	if err != nil {
+		apierror.Error(w, "Error saving template", http.StatusInternalServerError)
+		return
+	}
+
+	details := map[string]interface{}{"key": key, "locale": locale, "version": version}
+	if restoredFrom > 0 {
+		details["restoredFrom"] = restoredFrom
+	}
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventTemplateUpdated,
+		Severity:   audit.SeverityMedium,
+		UserID:     adminID,
+		EntityType: "notification_template",
+		Details:    details,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":     key,
+		"locale":  locale,
+		"version": version,
+	})
+}
+
+func templateParams(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	vars := mux.Vars(r)
+	if _, ok := notify.LookupDefinition(vars["key"]); !ok {
+		apierror.Error(w, "Template not found", http.StatusNotFound)
+		return "", "", false
+	}
+	if !notify.ValidLocale(vars["locale"]) {
+		apierror.Error(w, "Unsupported locale", http.StatusNotFound)
+		return "", "", false
+	}
+	return vars["key"], vars["locale"], true
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/ledger"
+
+	"github.com/gorilla/mux"
+)
+
+type LedgerBatch struct {
+	Sequence          int        `json:"sequence"`
+	MerkleRoot        string     `json:"merkleRoot"`
+	ChainHash         string     `json:"chainHash"`
+	PreviousChainHash string     `json:"previousChainHash"`
+	LeafCount         int        `json:"leafCount"`
+	AnchorMethod      *string    `json:"anchorMethod"`
+	AnchorRef         *string    `json:"anchorRef"`
+	AnchoredAt        *time.Time `json:"anchoredAt"`
+	CreatedAt         time.Time  `json:"createdAt"`
+}
+
+type TransparencyHandler struct {
+	db *sql.DB
+}
+
+func NewTransparencyHandler(db *sql.DB) *TransparencyHandler {
+	return &TransparencyHandler{db: db}
+}
+
+const ledgerBatchColumns = `b.sequence, b.merkle_root, b.chain_hash,
+	COALESCE((SELECT p.chain_hash FROM ledger_batches p WHERE p.sequence = b.sequence - 1), ?),
+	b.leaf_count, b.anchor_method, b.anchor_ref, b.anchored_at, b.created_at`
+
+// genesisChainHash precedes the first batch
+var genesisChainHash = hex.EncodeToString(make([]byte, 32))
+
+func scanLedgerBatch(row interface{ Scan(...interface{}) error }) (LedgerBatch, error) {
+	var b LedgerBatch
+	err := row.Scan(&b.Sequence, &b.MerkleRoot, &b.ChainHash, &b.PreviousChainHash,
+		&b.LeafCount, &b.AnchorMethod, &b.AnchorRef, &b.AnchoredAt, &b.CreatedAt)
+	return b, err
+}
+
+// ListBatches returns sealed ledger batches, newest first, so anyone can
+// check the hash chain and the anchors
+func (h *TransparencyHandler) ListBatches(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	before, _ := strconv.Atoi(r.URL.Query().Get("before"))
+
+	rows, err := h.db.Query(
+		`SELECT `+ledgerBatchColumns+` FROM ledger_batches b
+		WHERE (? = 0 OR b.sequence < ?)
+		ORDER BY b.sequence DESC LIMIT ?`,
+		genesisChainHash, before, before, limit,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching ledger batches", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	batches := []LedgerBatch{}
+	for rows.Next() {
+		b, err := scanLedgerBatch(rows)
+		if err != nil {
+			apierror.Error(w, "Error processing ledger batches", http.StatusInternalServerError)
+			return
+		}
+		batches = append(batches, b)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batches)
+}
+
+// GetProof returns the inclusion proof for a completed donation, and checks
+// both the path to the sealed root and that the donation still matches what
+// was sealed
+func (h *TransparencyHandler) GetProof(w http.ResponseWriter, r *http.Request) {
+	donationID := mux.Vars(r)["donationId"]
+
+	var batchID, leafHash, leafData string
+	var leafIndex int
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(batch_id), leaf_index, leaf_hash, leaf_data FROM ledger_entries
+		WHERE entry_type = ? AND entry_id = UUID_TO_BIN(?)`,
+		ledger.EntryDonation, donationID,
+	).Scan(&batchID, &leafIndex, &leafHash, &leafData)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Donation is not in the ledger; completed donations are sealed hourly", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching ledger entry", http.StatusInternalServerError)
+		return
+	}
+
+	batch, err := scanLedgerBatch(h.db.QueryRow(
+		`SELECT `+ledgerBatchColumns+` FROM ledger_batches b WHERE b.id = UUID_TO_BIN(?)`,
+		genesisChainHash, batchID,
+	))
+	if err != nil {
+		apierror.Error(w, "Error fetching ledger batch", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.db.Query(
+		"SELECT leaf_hash FROM ledger_entries WHERE batch_id = UUID_TO_BIN(?) ORDER BY leaf_index",
+		batchID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching ledger batch", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	var leaves [][]byte
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			apierror.Error(w, "Error processing ledger batch", http.StatusInternalServerError)
+			return
+		}
+		leaf, _ := hex.DecodeString(hash)
+		leaves = append(leaves, leaf)
+	}
+	if leafIndex >= len(leaves) {
+		apierror.Error(w, "Ledger batch is incomplete", http.StatusInternalServerError)
+		return
+	}
+
+	proof := ledger.Proof(leaves, leafIndex)
+	root, _ := hex.DecodeString(batch.MerkleRoot)
+	leaf := ledger.LeafHash([]byte(leafData))
+	previous, _ := hex.DecodeString(batch.PreviousChainHash)
+
+	// Recompute the leaf from the live record to catch edits after sealing
+	matchesRecord := false
+	var reportID, amount, currency string
+	var completedAt sql.NullTime
+	if h.db.QueryRow(
+		`SELECT BIN_TO_UUID(disaster_report_id), CAST(amount AS CHAR), currency, completed_at
+		FROM donations WHERE id = UUID_TO_BIN(?)`,
+		donationID,
+	).Scan(&reportID, &amount, &currency, &completedAt) == nil && completedAt.Valid {
+		matchesRecord = ledger.LeafData(donationID, reportID, amount, currency, completedAt.Time) == leafData
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"donationId": donationID,
+		"leaf": map[string]interface{}{
+			"data":  leafData,
+			"hash":  leafHash,
+			"index": leafIndex,
+		},
+		"proof":          proof,
+		"batch":          batch,
+		"includedInRoot": hex.EncodeToString(leaf) == leafHash && ledger.Verify(leaf, proof, root),
+		"chainValid":     hex.EncodeToString(ledger.ChainHash(previous, root)) == batch.ChainHash,
+		"matchesRecord":  matchesRecord,
+		"algorithm": "leaf = SHA-256(0x00 || data); node = SHA-256(0x01 || left || right), " +
+			"unpaired nodes move up unchanged; chainHash = SHA-256(previousChainHash || merkleRoot)",
+	})
+}
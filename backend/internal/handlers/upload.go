@@ -4,16 +4,30 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"image"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"saferelief/internal/apierror"
+
 	"github.com/gorilla/mux"
 )
 
+const (
+	maxAvatarSize   = 1 * 1024 * 1024 // 1MB
+	avatarDimension = 256
+)
+
+// avatarSizes are the square sizes every avatar is stored in; avatarDimension
+// is the one kept in avatar_url
+var avatarSizes = []int{64, avatarDimension, 512}
+
 type Upload struct {
 	ID           string    `json:"id"`
 	UserID       string    `json:"userId"`
@@ -40,12 +54,12 @@ func NewUploadHandler(db *sql.DB) *UploadHandler {
 }
 
 func (h *UploadHandler) UploadFiles(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
+	userID := r.Context().Value("user_id").(string)
 
 	// Parse multipart form
 	err := r.ParseMultipartForm(25 << 20) // 25MB max
 	if err != nil {
-		http.Error(w, "File too large", http.StatusBadRequest)
+		apierror.Error(w, "File too large", http.StatusBadRequest)
 		return
 	}
 
@@ -55,20 +69,20 @@ func (h *UploadHandler) UploadFiles(w http.ResponseWriter, r *http.Request) {
 	for _, fileHeader := range files {
 		file, err := fileHeader.Open()
 		if err != nil {
-			http.Error(w, "Failed to open file", http.StatusInternalServerError)
+			apierror.Error(w, "Failed to open file", http.StatusInternalServerError)
 			return
 		}
 		defer file.Close()
 
 		// Validate file type
 		if !h.isAllowedFileType(fileHeader.Filename) {
-			http.Error(w, fmt.Sprintf("File type not allowed: %s", fileHeader.Filename), http.StatusBadRequest)
+			apierror.Error(w, fmt.Sprintf("File type not allowed: %s", fileHeader.Filename), http.StatusBadRequest)
 			return
 		}
 
 		// Validate file size
 		if fileHeader.Size > maxFileSize {
-			http.Error(w, "File too large", http.StatusBadRequest)
+			apierror.Error(w, "File too large", http.StatusBadRequest)
 			return
 		}
 
@@ -79,13 +93,13 @@ func (h *UploadHandler) UploadFiles(w http.ResponseWriter, r *http.Request) {
 		// Save file to disk
 		dst, err := os.Create(filePath)
 		if err != nil {
-			http.Error(w, "Failed to save file", http.StatusInternalServerError)
+			apierror.Error(w, "Failed to save file", http.StatusInternalServerError)
 			return
 		}
 		defer dst.Close()
 
 		if _, err := io.Copy(dst, file); err != nil {
-			http.Error(w, "Failed to save file", http.StatusInternalServerError)
+			apierror.Error(w, "Failed to save file", http.StatusInternalServerError)
 			return
 		}
 
@@ -110,7 +124,7 @@ func (h *UploadHandler) UploadFiles(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			// Clean up file if database insert fails
 			os.Remove(filePath)
-			http.Error(w, "Failed to save upload record", http.StatusInternalServerError)
+			apierror.Error(w, "Failed to save upload record", http.StatusInternalServerError)
 			return
 		}
 
@@ -137,16 +151,16 @@ func (h *UploadHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "File not found", http.StatusNotFound)
+			apierror.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
 	// Check if file exists on disk
 	if _, err := os.Stat(upload.Path); os.IsNotExist(err) {
-		http.Error(w, "File not found on disk", http.StatusNotFound)
+		apierror.Error(w, "File not found on disk", http.StatusNotFound)
 		return
 	}
 
@@ -180,3 +194,152 @@ func (h *UploadHandler) generateID() string {
 	// Simple ID generation - in production, use a proper UUID library
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
+
+// UploadAvatar stores a profile picture. Only JPEG/PNG content is accepted,
+// and the image is cropped and resized to each standard square size as PNG.
+// The previous avatar's files are deleted once the new one is in place.
+func (h *UploadHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarSize+1024)
+	if err := r.ParseMultipartForm(maxAvatarSize); err != nil {
+		apierror.Error(w, "Avatar too large", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, fileHeader, err := r.FormFile("avatar")
+	if err != nil {
+		apierror.Error(w, "Avatar file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if fileHeader.Size > maxAvatarSize {
+		apierror.Error(w, "Avatar too large", http.StatusBadRequest)
+		return
+	}
+
+	img, err := decodeImage(file)
+	if err != nil {
+		apierror.Error(w, "Avatar must be a JPEG or PNG image", http.StatusBadRequest)
+		return
+	}
+
+	urls := avatarURLs{}
+	var saved []string
+	for _, size := range avatarSizes {
+		upload, err := h.saveAvatar(userID, fileHeader.Filename, resizeSquare(img, size))
+		if err != nil {
+			h.removeUploads(userID, saved)
+			apierror.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+			return
+		}
+		saved = append(saved, upload.ID)
+		urls[strconv.Itoa(size)] = "/api/uploads/" + upload.ID
+	}
+	avatarURL := urls[strconv.Itoa(avatarDimension)]
+
+	var oldURL sql.NullString
+	var oldURLs avatarURLs
+	err = h.db.QueryRow(
+		"SELECT avatar_url, avatar_sizes FROM users WHERE id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&oldURL, &oldURLs)
+	if err != nil {
+		h.removeUploads(userID, saved)
+		apierror.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+
+	encoded, _ := json.Marshal(urls)
+	_, err = h.db.Exec(
+		"UPDATE users SET avatar_url = ?, avatar_sizes = ?, updated_at = NOW() WHERE id = UUID_TO_BIN(?)",
+		avatarURL, encoded, userID,
+	)
+	if err != nil {
+		h.removeUploads(userID, saved)
+		apierror.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+
+	// Avatars from before sizes were stored only have avatar_url
+	previous := []string{strings.TrimPrefix(oldURL.String, "/api/uploads/")}
+	for _, url := range oldURLs {
+		previous = append(previous, strings.TrimPrefix(url, "/api/uploads/"))
+	}
+	h.removeUploads(userID, previous)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Avatar updated successfully",
+		"avatarUrl":  avatarURL,
+		"avatarUrls": urls,
+	})
+}
+
+// removeUploads deletes the user's uploads with the given IDs and their
+// files; IDs that are not the user's are ignored
+func (h *UploadHandler) removeUploads(userID string, ids []string) {
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		var path string
+		err := h.db.QueryRow("SELECT path FROM uploads WHERE id = ? AND user_id = ?", id, userID).Scan(&path)
+		if err != nil {
+			continue
+		}
+		if _, err := h.db.Exec("DELETE FROM uploads WHERE id = ?", id); err != nil {
+			log.Printf("Failed to delete upload %s: %v", id, err)
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove file %s: %v", path, err)
+		}
+	}
+}
+
+func (h *UploadHandler) saveAvatar(userID, originalName string, img image.Image) (Upload, error) {
+	filename := h.generateUniqueFilename("avatar.png")
+	filePath := filepath.Join(h.uploadDir, filename)
+
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return Upload{}, err
+	}
+	if err := encodePNG(dst, img); err != nil {
+		dst.Close()
+		os.Remove(filePath)
+		return Upload{}, err
+	}
+	info, err := dst.Stat()
+	dst.Close()
+	if err != nil {
+		os.Remove(filePath)
+		return Upload{}, err
+	}
+
+	upload := Upload{
+		ID:           h.generateID(),
+		UserID:       userID,
+		Filename:     filename,
+		OriginalName: originalName,
+		Size:         info.Size(),
+		MimeType:     "image/png",
+		Path:         filePath,
+		CreatedAt:    time.Now(),
+	}
+
+	_, err = h.db.Exec(`
+		INSERT INTO uploads (id, user_id, filename, original_name, size, mime_type, path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, upload.ID, upload.UserID, upload.Filename, upload.OriginalName,
+		upload.Size, upload.MimeType, upload.Path, upload.CreatedAt)
+	if err != nil {
+		os.Remove(filePath)
+		return Upload{}, err
+	}
+
+	return upload, nil
+}
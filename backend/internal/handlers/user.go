@@ -4,75 +4,190 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+	"saferelief/internal/notify"
+	"saferelief/internal/sessions"
+	"saferelief/internal/validation"
+
+	"github.com/go-sql-driver/mysql"
 	"github.com/pquerna/otp/totp"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const usernameChangeCooldown = 30 * 24 * time.Hour
+
 type User struct {
 	ID             string     `json:"id"`
 	Username       string     `json:"username"`
 	Email          string     `json:"email"`
+	EmailVerified  bool       `json:"emailVerified"`
+	EmailBounced   bool       `json:"emailBounced"`
+	AvatarURL      *string    `json:"avatarUrl"`
+	AvatarURLs     avatarURLs `json:"avatarUrls"`
+	DisplayName    *string    `json:"displayName"`
+	Phone          *string    `json:"phone"`
+	Bio            *string    `json:"bio"`
 	PasswordHash   string     `json:"-"`
 	MFASecret      string     `json:"-"`
 	MFAEnabled     bool       `json:"mfaEnabled"`
+	Role           string     `json:"role"`
+	Permissions    []string   `json:"permissions"`
+	Locale         string     `json:"locale"`
+	Timezone       string     `json:"timezone"`
+	UTCOffset      string     `json:"utcOffset"`
 	FailedAttempts int        `json:"-"`
 	LockedUntil    *time.Time `json:"-"`
 	CreatedAt      time.Time  `json:"createdAt"`
 	UpdatedAt      time.Time  `json:"updatedAt"`
+
+	// Set only for roles subject to password expiry
+	PasswordExpiresAt   *time.Time `json:"passwordExpiresAt,omitempty"`
+	PasswordExpiresSoon bool       `json:"passwordExpiresSoon,omitempty"`
 }
 
 type UserHandler struct {
-	db *sql.DB
+	db             *sql.DB
+	auditLogger    *audit.Logger
+	passwordPolicy accounts.PasswordPolicy
 }
 
-func NewUserHandler(db *sql.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *sql.DB, auditLogger *audit.Logger) *UserHandler {
+	return &UserHandler{db: db, auditLogger: auditLogger, passwordPolicy: accounts.PasswordPolicyFromEnv()}
 }
 
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
+	userID := r.Context().Value("user_id").(string)
 
 	var user User
+	var passwordChangedAt time.Time
 	err := h.db.QueryRow(`
-		SELECT id, username, email, mfa_enabled, created_at, updated_at 
-		FROM users WHERE id = ?
-	`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.MFAEnabled, &user.CreatedAt, &user.UpdatedAt)
+		SELECT BIN_TO_UUID(id), username, email, email_verified_at IS NOT NULL, email_bounced_at IS NOT NULL,
+		avatar_url, avatar_sizes, display_name, phone, bio, mfa_enabled, role, locale, timezone,
+		last_password_change, created_at, updated_at 
+		FROM users WHERE id = UUID_TO_BIN(?)
+	`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerified, &user.EmailBounced,
+		&user.AvatarURL, &user.AvatarURLs, &user.DisplayName, &user.Phone, &user.Bio, &user.MFAEnabled, &user.Role,
+		&user.Locale, &user.Timezone, &passwordChangedAt, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "User not found", http.StatusNotFound)
+			apierror.Error(w, "User not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
+	// Timestamps stay UTC; the offset lets clients render local times
+	user.UTCOffset = notify.UTCOffset(user.Timezone)
+
+	user.PasswordExpiresAt = h.passwordPolicy.ExpiresAt(user.Role, passwordChangedAt)
+	user.PasswordExpiresSoon = h.passwordPolicy.ExpiringSoon(user.Role, passwordChangedAt)
+
+	user.Permissions = []string{}
+	for _, p := range middleware.Role(user.Role).Permissions() {
+		user.Permissions = append(user.Permissions, string(p))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
 
 func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
+	userID := r.Context().Value("user_id").(string)
 
 	var updateData struct {
-		Username string `json:"username"`
-		Email    string `json:"email"`
+		Username string  `json:"username"`
+		Email    *string `json:"email"`
+		Locale   *string `json:"locale"`
+		Timezone *string `json:"timezone"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	updateData.Username = strings.TrimSpace(updateData.Username)
+
+	v := validation.New()
+	v.Username("username", updateData.Username)
+	if updateData.Locale != nil {
+		v.Check(notify.ValidLocale(*updateData.Locale), "locale", "must be one of: id, en")
+	}
+	if updateData.Timezone != nil {
+		v.Check(notify.ValidTimezone(*updateData.Timezone), "timezone", "must be an IANA timezone such as Asia/Jakarta")
+	}
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	var currentUsername, currentEmail string
+	var usernameChangedAt *time.Time
+	err := h.db.QueryRow(`
+		SELECT username, email, username_changed_at FROM users WHERE id = UUID_TO_BIN(?)
+	`, userID).Scan(&currentUsername, &currentEmail, &usernameChangedAt)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Email changes must go through the confirmation flow
+	if updateData.Email != nil && !strings.EqualFold(strings.TrimSpace(*updateData.Email), currentEmail) {
+		v.AddError("email", "cannot be changed here; use POST /api/users/me/email-change")
+		v.WriteError(w)
+		return
+	}
+
+	usernameChanged := updateData.Username != currentUsername
+	if usernameChanged && usernameChangedAt != nil {
+		if next := usernameChangedAt.Add(usernameChangeCooldown); time.Now().Before(next) {
+			apierror.Error(w, "Username can only be changed once every 30 days; next change allowed after "+
+				next.UTC().Format(time.RFC3339), http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	// Check uniqueness up front for a clear error; the unique index still
+	// guards against races below
+	var conflicts int
+	err = h.db.QueryRow(`
+		SELECT COUNT(*) FROM users WHERE username = ? AND id != UUID_TO_BIN(?)
+	`, updateData.Username, userID).Scan(&conflicts)
+	if err != nil {
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if conflicts > 0 {
+		v.AddError("username", "is already taken")
+		v.WriteError(w)
 		return
 	}
 
-	_, err := h.db.Exec(`
-		UPDATE users SET username = ?, email = ?, updated_at = NOW() 
-		WHERE id = ?
-	`, updateData.Username, updateData.Email, userID)
+	_, err = h.db.Exec(`
+		UPDATE users SET username = ?, updated_at = NOW(),
+		username_changed_at = IF(?, NOW(), username_changed_at),
+		locale = COALESCE(?, locale), timezone = COALESCE(?, timezone)
+		WHERE id = UUID_TO_BIN(?)
+	`, updateData.Username, usernameChanged, updateData.Locale, updateData.Timezone, userID)
 
 	if err != nil {
-		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			apierror.Error(w, "Username already in use", http.StatusConflict)
+			return
+		}
+		apierror.Error(w, "Failed to update profile", http.StatusInternalServerError)
 		return
 	}
 
@@ -80,8 +195,23 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Profile updated successfully"})
 }
 
-func (h *UserHandler) EnableMFA(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
+// SetupMFA starts enrollment with a fresh TOTP secret. MFA stays off until
+// ConfirmMFA sees a valid code, so a user who never finishes the
+// authenticator setup cannot lock themselves out.
+func (h *UserHandler) SetupMFA(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var mfaEnabled bool
+	if err := h.db.QueryRow(
+		"SELECT mfa_enabled FROM users WHERE id = UUID_TO_BIN(?)", userID,
+	).Scan(&mfaEnabled); err != nil {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if mfaEnabled {
+		apierror.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
+	}
 
 	// Generate TOTP secret
 	secret, err := totp.Generate(totp.GenerateOpts{
@@ -89,31 +219,105 @@ func (h *UserHandler) EnableMFA(w http.ResponseWriter, r *http.Request) {
 		AccountName: userID,
 	})
 	if err != nil {
-		http.Error(w, "Failed to generate MFA secret", http.StatusInternalServerError)
+		apierror.Error(w, "Failed to generate MFA secret", http.StatusInternalServerError)
 		return
 	}
 
-	// Save secret to database
-	_, err = h.db.Exec(`
-		UPDATE users SET mfa_secret = ?, mfa_enabled = true, updated_at = NOW() 
-		WHERE id = ?
+	// Replaces any earlier unfinished setup; the guard keeps an active secret
+	// from being swapped out by a concurrent confirm
+	result, err := h.db.Exec(`
+		UPDATE users SET mfa_secret = ?, updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND mfa_enabled = false
 	`, secret.Secret(), userID)
-
 	if err != nil {
-		http.Error(w, "Failed to enable MFA", http.StatusInternalServerError)
+		apierror.Error(w, "Failed to start MFA setup", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "MFA is already enabled", http.StatusConflict)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "MFA enabled successfully",
+		"message": "Scan the QR code, then confirm with a code from your authenticator",
 		"qrCode":  secret.URL(),
 		"secret":  secret.Secret(),
 	})
 }
 
+// ConfirmMFA turns MFA on once the user proves their authenticator works
+func (h *UserHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var requestData struct {
+		MFACode string `json:"mfaCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var mfaSecret string
+	var mfaEnabled bool
+	err := h.db.QueryRow(`
+		SELECT COALESCE(mfa_secret, ''), mfa_enabled FROM users WHERE id = UUID_TO_BIN(?)
+	`, userID).Scan(&mfaSecret, &mfaEnabled)
+	if err != nil {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if mfaEnabled {
+		apierror.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
+	}
+	if mfaSecret == "" {
+		apierror.Error(w, "Start MFA setup first", http.StatusConflict)
+		return
+	}
+	if !totp.Validate(requestData.MFACode, mfaSecret) {
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidMFACode, "Invalid MFA code", nil)
+		return
+	}
+
+	// Matching on the secret makes sure the code was checked against the one
+	// being enabled, even if setup ran again in the meantime
+	result, err := h.db.Exec(`
+		UPDATE users SET mfa_enabled = true, updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND mfa_secret = ? AND mfa_enabled = false
+	`, userID, mfaSecret)
+	if err != nil {
+		apierror.Error(w, "Failed to enable MFA", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "MFA setup changed; scan the new QR code and try again", http.StatusConflict)
+		return
+	}
+
+	// Recovery codes are the way back in if the authenticator is lost
+	recoveryCodes, err := accounts.GenerateRecoveryCodes(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:     audit.EventMFAEnabled,
+		Severity: audit.SeverityMedium,
+		UserID:   userID,
+		EntityID: userID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "MFA enabled successfully",
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
 func (h *UserHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
+	userID := r.Context().Value("user_id").(string)
 
 	var requestData struct {
 		Password string `json:"password"`
@@ -121,44 +325,121 @@ func (h *UserHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	// Verify password and MFA code before disabling
 	var passwordHash, mfaSecret string
 	err := h.db.QueryRow(`
-		SELECT password_hash, mfa_secret FROM users WHERE id = ?
+		SELECT password_hash, COALESCE(mfa_secret, '') FROM users WHERE id = UUID_TO_BIN(?)
 	`, userID).Scan(&passwordHash, &mfaSecret)
 
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		apierror.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(requestData.Password)); err != nil {
-		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		apierror.Error(w, "Invalid password", http.StatusUnauthorized)
 		return
 	}
 
 	// Verify MFA code
 	if !totp.Validate(requestData.MFACode, mfaSecret) {
-		http.Error(w, "Invalid MFA code", http.StatusUnauthorized)
+		apierror.Error(w, "Invalid MFA code", http.StatusUnauthorized)
 		return
 	}
 
 	// Disable MFA
 	_, err = h.db.Exec(`
 		UPDATE users SET mfa_secret = '', mfa_enabled = false, updated_at = NOW() 
-		WHERE id = ?
+		WHERE id = UUID_TO_BIN(?)
 	`, userID)
 
 	if err != nil {
-		http.Error(w, "Failed to disable MFA", http.StatusInternalServerError)
+		apierror.Error(w, "Failed to disable MFA", http.StatusInternalServerError)
+		return
+	}
+	if err := accounts.DeleteRecoveryCodes(h.db, userID); err != nil {
+		apierror.Error(w, "Failed to disable MFA", http.StatusInternalServerError)
+		return
+	}
+	// Trust only meant something while MFA was on; re-enabling starts fresh
+	if _, err := sessions.UntrustAll(h.db, userID); err != nil {
+		apierror.Error(w, "Failed to disable MFA", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "MFA disabled successfully"})
 }
+
+// GetRecoveryCodes reports how many unused recovery codes remain; the codes
+// themselves are only shown when generated
+func (h *UserHandler) GetRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	remaining, err := accounts.RemainingRecoveryCodes(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"remaining": remaining,
+		"total":     accounts.RecoveryCodeCount,
+	})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not. It
+// needs the password and a current authenticator code.
+func (h *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var requestData struct {
+		Password string `json:"password"`
+		MFACode  string `json:"mfaCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		apierror.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var passwordHash, mfaSecret string
+	var mfaEnabled bool
+	err := h.db.QueryRow(`
+		SELECT password_hash, COALESCE(mfa_secret, ''), mfa_enabled FROM users WHERE id = UUID_TO_BIN(?)
+	`, userID).Scan(&passwordHash, &mfaSecret, &mfaEnabled)
+	if err != nil {
+		apierror.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !mfaEnabled {
+		apierror.Error(w, "MFA is not enabled", http.StatusConflict)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(requestData.Password)); err != nil {
+		apierror.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+	if !totp.Validate(requestData.MFACode, mfaSecret) {
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidMFACode, "Invalid MFA code", nil)
+		return
+	}
+
+	recoveryCodes, err := accounts.GenerateRecoveryCodes(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Recovery codes regenerated; earlier codes no longer work",
+		"recoveryCodes": recoveryCodes,
+	})
+}
@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/jobs"
+	"saferelief/internal/middleware"
+	"saferelief/internal/notify"
+	"saferelief/internal/tokens"
+	"saferelief/internal/validation"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxImportFileSize = 1 << 20 // 1MB
+	maxImportRows     = 1000
+	invitationTTL     = 7 * 24 * time.Hour
+)
+
+type UserImport struct {
+	ID          string          `json:"id"`
+	Status      string          `json:"status"`
+	Total       int             `json:"total"`
+	Succeeded   int             `json:"succeeded"`
+	Failed      int             `json:"failed"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	CompletedAt *time.Time      `json:"completedAt"`
+	Rows        []UserImportRow `json:"rows,omitempty"`
+}
+
+type UserImportRow struct {
+	Row    int     `json:"row"`
+	Email  string  `json:"email"`
+	Name   string  `json:"name"`
+	Role   string  `json:"role"`
+	Status string  `json:"status"`
+	UserID *string `json:"userId"`
+	Error  *string `json:"error"`
+}
+
+// UserImportHandler onboards staff in bulk: an admin uploads a CSV, each row
+// becomes an invited account in the background and the invitee sets their
+// own password from an emailed link
+type UserImportHandler struct {
+	db          *sql.DB
+	queue       *jobs.Queue
+	mailer      *notify.Mailer
+	auditLogger *audit.Logger
+	frontendURL string
+}
+
+func NewUserImportHandler(db *sql.DB, queue *jobs.Queue, mailer *notify.Mailer, auditLogger *audit.Logger) *UserImportHandler {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	return &UserImportHandler{
+		db:          db,
+		queue:       queue,
+		mailer:      mailer,
+		auditLogger: auditLogger,
+		frontendURL: frontendURL,
+	}
+}
+
+// ImportUsers accepts a CSV with an email,name,role header as the "file" form
+// field and queues account creation. Rows are validated up front; invalid
+// rows are recorded as failed and skipped.
+func (h *UserImportHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("user_id").(string)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize+4096)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		apierror.Error(w, "CSV file is required (max 1MB)", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	rows, err := parseImportCSV(file)
+	if err != nil {
+		apierror.Error(w, "Invalid CSV: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var importID string
+	err = tx.QueryRow(
+		`INSERT INTO user_imports (id, created_by, total)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?)
+		RETURNING BIN_TO_UUID(id)`,
+		adminID, len(rows),
+	).Scan(&importID)
+	if err != nil {
+		apierror.Error(w, "Error creating import", http.StatusInternalServerError)
+		return
+	}
+	for _, row := range rows {
+		_, err := tx.Exec(
+			`INSERT INTO user_import_rows (import_id, line_number, email, name, role, status, error)
+			VALUES (UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?)`,
+			importID, row.Row, row.Email, row.Name, row.Role, row.Status, row.Error,
+		)
+		if err != nil {
+			apierror.Error(w, "Error creating import", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error creating import", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.queue.Enqueue(jobs.Job{
+		Name:        "user_import:" + importID,
+		MaxAttempts: 3,
+		Run: func(ctx context.Context) error {
+			return h.processImport(ctx, importID)
+		},
+		OnFailure: func(err error) {
+			h.db.Exec("UPDATE user_imports SET status = 'failed', completed_at = NOW() WHERE id = UUID_TO_BIN(?)", importID)
+		},
+	})
+	if err == jobs.ErrQueueFull {
+		h.db.Exec("UPDATE user_imports SET status = 'failed', completed_at = NOW() WHERE id = UUID_TO_BIN(?)", importID)
+		apierror.Error(w, "Import service is busy, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventUserImportStarted,
+		Severity:   audit.SeverityHigh,
+		UserID:     adminID,
+		EntityType: "user_import",
+		EntityID:   importID,
+		Details:    map[string]interface{}{"rows": len(rows)},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      importID,
+		"total":   len(rows),
+		"message": "Import queued",
+	})
+}
+
+// GetImport reports overall progress and the outcome of every row
+func (h *UserImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	importID := mux.Vars(r)["id"]
+
+	var result UserImport
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(id), status, total, created_at, completed_at
+		FROM user_imports WHERE id = UUID_TO_BIN(?)`,
+		importID,
+	).Scan(&result.ID, &result.Status, &result.Total, &result.CreatedAt, &result.CompletedAt)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Import not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching import", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.db.Query(
+		`SELECT line_number, email, name, role, status, BIN_TO_UUID(user_id), error
+		FROM user_import_rows WHERE import_id = UUID_TO_BIN(?) ORDER BY line_number`,
+		importID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching import", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	result.Rows = []UserImportRow{}
+	for rows.Next() {
+		var row UserImportRow
+		if err := rows.Scan(&row.Row, &row.Email, &row.Name, &row.Role, &row.Status, &row.UserID, &row.Error); err != nil {
+			apierror.Error(w, "Error processing import", http.StatusInternalServerError)
+			return
+		}
+		switch row.Status {
+		case "created":
+			result.Succeeded++
+		case "failed":
+			result.Failed++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// AcceptInvitation lets an invited user choose a password and activates the account
+func (h *UserImportHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	v.Required("token", request.Token)
+	v.Password("password", request.Password)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		apierror.Error(w, "Error hashing password", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(user_id) FROM user_invitations
+		WHERE token_hash = ? AND accepted_at IS NULL AND expires_at > NOW() FOR UPDATE`,
+		tokens.Hash(request.Token),
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Invitation is invalid or has expired", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(
+		`UPDATE users SET password_hash = ?, status = 'active', email_verified_at = NOW(),
+		last_password_change = NOW(), updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?)`,
+		passwordHash, userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error accepting invitation", http.StatusInternalServerError)
+		return
+	}
+	_, err = tx.Exec(
+		"UPDATE user_invitations SET accepted_at = NOW() WHERE user_id = UUID_TO_BIN(?) AND accepted_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error accepting invitation", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error accepting invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Invitation accepted; you can now log in",
+	})
+}
+
+func parseImportCSV(file io.Reader) ([]UserImportRow, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header row")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"email", "name", "role"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("header must include email, name and role")
+		}
+	}
+
+	var rows []UserImportRow
+	seen := map[string]bool{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("at most %d rows per import", maxImportRows)
+		}
+
+		row := UserImportRow{
+			Row:    line,
+			Email:  strings.ToLower(strings.TrimSpace(field(record, columns["email"]))),
+			Name:   strings.TrimSpace(field(record, columns["name"])),
+			Role:   strings.ToLower(strings.TrimSpace(field(record, columns["role"]))),
+			Status: "pending",
+		}
+
+		v := validation.New()
+		v.Email("email", row.Email)
+		if v.Required("name", row.Name) {
+			v.Length("name", row.Name, 1, 100)
+		}
+		// Admins are never created in bulk
+		v.Check(middleware.ValidRole(middleware.Role(row.Role)) && row.Role != string(middleware.RoleAdmin),
+			"role", "must be donor, reporter or verifier")
+		v.Check(!seen[row.Email], "email", "appears more than once in the file")
+		seen[row.Email] = true
+
+		if !v.Valid() {
+			message := v.Errors[0].Field + " " + v.Errors[0].Message
+			row.Status = "failed"
+			row.Error = &message
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows to import")
+	}
+	return rows, nil
+}
+
+func field(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+// processImport creates the pending rows one by one. Finished rows are
+// skipped, so a retried job picks up where the last attempt stopped.
+func (h *UserImportHandler) processImport(ctx context.Context, importID string) error {
+	h.db.ExecContext(ctx, "UPDATE user_imports SET status = 'processing' WHERE id = UUID_TO_BIN(?)", importID)
+
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT line_number, email, name, role FROM user_import_rows
+		WHERE import_id = UUID_TO_BIN(?) AND status = 'pending' ORDER BY line_number`,
+		importID,
+	)
+	if err != nil {
+		return err
+	}
+	var pending []UserImportRow
+	for rows.Next() {
+		var row UserImportRow
+		if err := rows.Scan(&row.Row, &row.Email, &row.Name, &row.Role); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, row := range pending {
+		userID, token, err := h.inviteUser(ctx, row)
+		if err != nil {
+			message := err.Error()
+			if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+				message = "email is already registered"
+			}
+			_, dbErr := h.db.ExecContext(ctx,
+				`UPDATE user_import_rows SET status = 'failed', error = ?
+				WHERE import_id = UUID_TO_BIN(?) AND line_number = ?`,
+				message, importID, row.Row,
+			)
+			if dbErr != nil {
+				return dbErr
+			}
+			continue
+		}
+
+		_, err = h.db.ExecContext(ctx,
+			`UPDATE user_import_rows SET status = 'created', user_id = UUID_TO_BIN(?)
+			WHERE import_id = UUID_TO_BIN(?) AND line_number = ?`,
+			userID, importID, row.Row,
+		)
+		if err != nil {
+			return err
+		}
+
+		invitee := notify.Recipient{Email: row.Email, Locale: notify.DefaultLocale, Timezone: notify.DefaultTimezone}
+		if err := h.mailer.SendTemplate(ctx, invitee, "user_import.invitation", map[string]interface{}{
+			"Name": row.Name,
+			"Role": row.Role,
+			"Link": h.frontendURL + "/invitation?token=" + token,
+		}); err != nil {
+			log.Printf("Failed to send invitation for import %s row %d: %v", importID, row.Row, err)
+		}
+	}
+
+	_, err = h.db.ExecContext(ctx,
+		"UPDATE user_imports SET status = 'completed', completed_at = NOW() WHERE id = UUID_TO_BIN(?)",
+		importID,
+	)
+	return err
+}
+
+// inviteUser creates an inactive account that cannot sign in until the
+// invitation is accepted, and returns the invitation token
+func (h *UserImportHandler) inviteUser(ctx context.Context, row UserImportRow) (string, string, error) {
+	secret, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "SafeRelief",
+		AccountName: row.Email,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	token, tokenHash, err := tokens.Generate()
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	username, err := accounts.AvailableUsername(ctx, tx, row.Name)
+	if err != nil {
+		return "", "", err
+	}
+
+	var userID string
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO users (id, username, email, password_hash, mfa_secret, role, status,
+		last_password_change, created_at, updated_at)
+		VALUES (UUID_TO_BIN(UUID()), ?, ?, '!', ?, ?, 'inactive', NOW(), NOW(), NOW())
+		RETURNING BIN_TO_UUID(id)`,
+		username, row.Email, secret.Secret(), row.Role,
+	).Scan(&userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO user_invitations (id, user_id, token_hash, expires_at)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?)`,
+		userID, tokenHash, time.Now().Add(invitationTTL),
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	return userID, token, tx.Commit()
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+const maxApplicationDocuments = 5
+
+type VerifierApplication struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"userId"`
+	Username     string     `json:"username,omitempty"`
+	Organization string     `json:"organization"`
+	Motivation   string     `json:"motivation"`
+	DocumentIDs  []string   `json:"documentIds"`
+	Status       string     `json:"status"`
+	ReviewedBy   *string    `json:"reviewedBy"`
+	ReviewNote   *string    `json:"reviewNote"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ReviewedAt   *time.Time `json:"reviewedAt"`
+}
+
+type VerifierApplicationHandler struct {
+	db          *sql.DB
+	auditLogger *audit.Logger
+}
+
+func NewVerifierApplicationHandler(db *sql.DB, auditLogger *audit.Logger) *VerifierApplicationHandler {
+	return &VerifierApplicationHandler{db: db, auditLogger: auditLogger}
+}
+
+func (h *VerifierApplicationHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var application struct {
+		Organization string   `json:"organization"`
+		Motivation   string   `json:"motivation"`
+		DocumentIDs  []string `json:"documentIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&application); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	application.Motivation = strings.TrimSpace(application.Motivation)
+	if application.Motivation == "" {
+		apierror.Error(w, "Motivation is required", http.StatusBadRequest)
+		return
+	}
+	if len(application.DocumentIDs) == 0 || len(application.DocumentIDs) > maxApplicationDocuments {
+		apierror.Error(w, "Between 1 and 5 supporting documents are required", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.LookupRole(h.db, userID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role.Can(middleware.PermVerifyReports) {
+		apierror.Error(w, "You can already verify reports", http.StatusConflict)
+		return
+	}
+
+	// Documents must have been uploaded by the applicant
+	for _, documentID := range application.DocumentIDs {
+		var owner string
+		err := h.db.QueryRow("SELECT user_id FROM uploads WHERE id = ?", documentID).Scan(&owner)
+		if err == sql.ErrNoRows || (err == nil && owner != userID) {
+			apierror.Error(w, "Document not found: "+documentID, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var pending int
+	err = h.db.QueryRow(
+		"SELECT COUNT(*) FROM verifier_applications WHERE user_id = UUID_TO_BIN(?) AND status = 'pending'",
+		userID,
+	).Scan(&pending)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if pending > 0 {
+		apierror.Error(w, "You already have a pending application", http.StatusConflict)
+		return
+	}
+
+	documents, _ := json.Marshal(application.DocumentIDs)
+	var applicationID string
+	err = h.db.QueryRow(
+		`INSERT INTO verifier_applications (id, user_id, organization, motivation, document_ids, status)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, 'pending')
+		RETURNING BIN_TO_UUID(id)`,
+		userID, application.Organization, application.Motivation, documents,
+	).Scan(&applicationID)
+	if err != nil {
+		apierror.Error(w, "Error submitting application", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      applicationID,
+		"status":  "pending",
+		"message": "Application submitted successfully",
+	})
+}
+
+func (h *VerifierApplicationHandler) GetOwnApplication(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	applications, err := h.queryApplications(
+		"WHERE va.user_id = UUID_TO_BIN(?) ORDER BY va.created_at DESC LIMIT 1", userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching application", http.StatusInternalServerError)
+		return
+	}
+	if len(applications) == 0 {
+		apierror.Error(w, "No application found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(applications[0])
+}
+
+func (h *VerifierApplicationHandler) ListApplications(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "pending"
+	}
+
+	applications, err := h.queryApplications(
+		"WHERE va.status = ? ORDER BY va.created_at ASC LIMIT 100", status,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching applications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(applications)
+}
+
+func (h *VerifierApplicationHandler) ReviewApplication(w http.ResponseWriter, r *http.Request) {
+	applicationID := mux.Vars(r)["id"]
+	adminID := r.Context().Value("user_id").(string)
+
+	var review struct {
+		Decision string `json:"decision"`
+		Note     string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var status string
+	switch review.Decision {
+	case "approve":
+		status = "approved"
+	case "reject":
+		status = "rejected"
+	default:
+		apierror.Error(w, "Decision must be approve or reject", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var applicantID, applicantRole string
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(va.user_id), u.role FROM verifier_applications va
+		JOIN users u ON u.id = va.user_id
+		WHERE va.id = UUID_TO_BIN(?) AND va.status = 'pending' FOR UPDATE`,
+		applicationID,
+	).Scan(&applicantID, &applicantRole)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Application not found or already reviewed", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(
+		`UPDATE verifier_applications
+		SET status = ?, reviewed_by = UUID_TO_BIN(?), review_note = ?, reviewed_at = NOW()
+		WHERE id = UUID_TO_BIN(?)`,
+		status, adminID, review.Note, applicationID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error reviewing application", http.StatusInternalServerError)
+		return
+	}
+
+	// Never downgrade an admin who happened to apply. The applicant's
+	// sessions end with the change, as their tokens carry the old role.
+	promoted := status == "approved" &&
+		(applicantRole == string(middleware.RoleDonor) || applicantRole == string(middleware.RoleReporter))
+	if promoted {
+		if _, err := accounts.ChangeRoleTx(tx, applicantID, string(middleware.RoleVerifier)); err != nil {
+			apierror.Error(w, "Error updating user role", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		apierror.Error(w, "Error finalizing review", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLogger.Log(r, audit.Event{
+		Type:       audit.EventVerifierApplicationReviewed,
+		Severity:   audit.SeverityHigh,
+		UserID:     adminID,
+		EntityType: "verifier_application",
+		EntityID:   applicationID,
+		Details: map[string]interface{}{
+			"applicantId": applicantID,
+			"decision":    status,
+			"note":        review.Note,
+		},
+	})
+	if promoted {
+		h.auditLogger.Log(r, audit.Event{
+			Type:     audit.EventRoleChanged,
+			Severity: audit.SeverityHigh,
+			UserID:   adminID,
+			EntityID: applicantID,
+			Details: map[string]interface{}{
+				"role": middleware.RoleVerifier, "previousRole": applicantRole, "source": "verifier_application",
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      applicationID,
+		"status":  status,
+		"message": "Application reviewed successfully",
+	})
+}
+
+func (h *VerifierApplicationHandler) queryApplications(clause string, args ...interface{}) ([]VerifierApplication, error) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(va.id), BIN_TO_UUID(va.user_id), u.username, va.organization,
+		va.motivation, va.document_ids, va.status, BIN_TO_UUID(va.reviewed_by), va.review_note,
+		va.created_at, va.reviewed_at
+		FROM verifier_applications va
+		JOIN users u ON u.id = va.user_id `+clause,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applications := []VerifierApplication{}
+	for rows.Next() {
+		var a VerifierApplication
+		var documents []byte
+		if err := rows.Scan(
+			&a.ID, &a.UserID, &a.Username, &a.Organization, &a.Motivation, &documents,
+			&a.Status, &a.ReviewedBy, &a.ReviewNote, &a.CreatedAt, &a.ReviewedAt,
+		); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(documents, &a.DocumentIDs)
+		applications = append(applications, a)
+	}
+
+	return applications, rows.Err()
+}
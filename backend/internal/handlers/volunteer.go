@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/notify"
+	"saferelief/internal/validation"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+const maxVolunteerTags = 20
+
+var volunteerAvailability = map[string]bool{
+	"available":   true,
+	"limited":     true,
+	"unavailable": true,
+}
+
+type VolunteerProfile struct {
+	UserID         string    `json:"userId"`
+	Username       string    `json:"username,omitempty"`
+	Skills         []string  `json:"skills"`
+	Certifications []string  `json:"certifications"`
+	Region         string    `json:"region"`
+	Availability   string    `json:"availability"`
+	Notes          string    `json:"notes"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+type VolunteerRequest struct {
+	ID          string     `json:"id"`
+	ReportID    string     `json:"reportId"`
+	ReportTitle string     `json:"reportTitle"`
+	VolunteerID string     `json:"volunteerId"`
+	RequestedBy string     `json:"requestedBy"`
+	Message     string     `json:"message"`
+	Status      string     `json:"status"`
+	RespondedAt *time.Time `json:"respondedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type VolunteerHandler struct {
+	db     *sql.DB
+	mailer *notify.Mailer
+}
+
+func NewVolunteerHandler(db *sql.DB, mailer *notify.Mailer) *VolunteerHandler {
+	return &VolunteerHandler{db: db, mailer: mailer}
+}
+
+func (h *VolunteerHandler) GetOwnProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	profiles, err := h.queryProfiles("WHERE vp.user_id = UUID_TO_BIN(?)", userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching volunteer profile", http.StatusInternalServerError)
+		return
+	}
+	if len(profiles) == 0 {
+		apierror.Error(w, "You have not registered as a volunteer", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles[0])
+}
+
+// UpdateOwnProfile registers the user as a volunteer or updates their details
+func (h *VolunteerHandler) UpdateOwnProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var profile VolunteerProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	profile.Skills = normalizeTags(profile.Skills)
+	profile.Certifications = normalizeTags(profile.Certifications)
+	profile.Region = strings.TrimSpace(profile.Region)
+
+	v := validation.New()
+	v.Check(len(profile.Skills) > 0 && len(profile.Skills) <= maxVolunteerTags, "skills", "must list between 1 and 20 skills")
+	v.Check(len(profile.Certifications) <= maxVolunteerTags, "certifications", "must list at most 20 certifications")
+	if v.Required("region", profile.Region) {
+		v.Length("region", profile.Region, 2, 100)
+	}
+	v.Check(volunteerAvailability[profile.Availability], "availability", "must be available, limited or unavailable")
+	v.Length("notes", profile.Notes, 0, 1000)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	skills, _ := json.Marshal(profile.Skills)
+	certifications, _ := json.Marshal(profile.Certifications)
+	_, err := h.db.Exec(
+		`INSERT INTO volunteer_profiles (user_id, skills, certifications, region, availability, notes)
+		VALUES (UUID_TO_BIN(?), ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE skills = VALUES(skills), certifications = VALUES(certifications),
+		region = VALUES(region), availability = VALUES(availability), notes = VALUES(notes), updated_at = NOW()`,
+		userID, skills, certifications, profile.Region, profile.Availability, profile.Notes,
+	)
+	if err != nil {
+		apierror.Error(w, "Error saving volunteer profile", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Volunteer profile saved"})
+}
+
+// SearchVolunteers lets coordinators find volunteers by skill, certification,
+// region and availability. Unavailable volunteers are excluded unless asked for.
+func (h *VolunteerHandler) SearchVolunteers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	clause := "WHERE u.deleted_at IS NULL"
+	args := []interface{}{}
+	if skill := strings.ToLower(strings.TrimSpace(q.Get("skill"))); skill != "" {
+		clause += " AND JSON_CONTAINS(vp.skills, JSON_QUOTE(?))"
+		args = append(args, skill)
+	}
+	if certification := strings.ToLower(strings.TrimSpace(q.Get("certification"))); certification != "" {
+		clause += " AND JSON_CONTAINS(vp.certifications, JSON_QUOTE(?))"
+		args = append(args, certification)
+	}
+	if region := strings.TrimSpace(q.Get("region")); region != "" {
+		clause += " AND vp.region = ?"
+		args = append(args, region)
+	}
+	if availability := q.Get("availability"); availability != "" {
+		clause += " AND vp.availability = ?"
+		args = append(args, availability)
+	} else {
+		clause += " AND vp.availability != 'unavailable'"
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l < limit {
+		limit = l
+	}
+	clause += " ORDER BY vp.availability = 'available' DESC, vp.updated_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	profiles, err := h.queryProfiles(clause, args...)
+	if err != nil {
+		apierror.Error(w, "Error searching volunteers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// RequestVolunteer asks a volunteer to help with a report; they are emailed
+// and can accept or decline
+func (h *VolunteerHandler) RequestVolunteer(w http.ResponseWriter, r *http.Request) {
+	reportID := mux.Vars(r)["id"]
+	coordinatorID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		VolunteerID string `json:"volunteerId"`
+		Message     string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var reportTitle string
+	var reportStatus ReportStatus
+	err := h.db.QueryRow(
+		"SELECT title, status FROM disaster_reports WHERE id = UUID_TO_BIN(?) AND deleted_at IS NULL",
+		reportID,
+	).Scan(&reportTitle, &reportStatus)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if reportStatus.Finished() {
+		apierror.Error(w, "Report is already "+string(reportStatus), http.StatusConflict)
+		return
+	}
+
+	var to notify.Recipient
+	var availability string
+	err = h.db.QueryRow(
+		`SELECT u.email, u.locale, u.timezone, vp.availability FROM volunteer_profiles vp
+		JOIN users u ON u.id = vp.user_id
+		WHERE vp.user_id = UUID_TO_BIN(?) AND u.deleted_at IS NULL`,
+		request.VolunteerID,
+	).Scan(&to.Email, &to.Locale, &to.Timezone, &availability)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Volunteer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if availability == "unavailable" {
+		apierror.Error(w, "Volunteer is currently unavailable", http.StatusConflict)
+		return
+	}
+
+	var requestID string
+	err = h.db.QueryRow(
+		`INSERT INTO volunteer_requests (id, disaster_report_id, volunteer_id, requested_by, message)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), UUID_TO_BIN(?), ?)
+		RETURNING BIN_TO_UUID(id)`,
+		reportID, request.VolunteerID, coordinatorID, request.Message,
+	).Scan(&requestID)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			apierror.Error(w, "Volunteer was already requested for this report", http.StatusConflict)
+			return
+		}
+		apierror.Error(w, "Error creating volunteer request", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.mailer.SendTemplate(r.Context(), to, "volunteer.request", map[string]interface{}{
+		"ReportTitle": reportTitle,
+		"Message":     request.Message,
+	}); err != nil {
+		log.Printf("Failed to send volunteer request %s: %v", requestID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      requestID,
+		"message": "Volunteer requested",
+	})
+}
+
+func (h *VolunteerHandler) ListReportRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := h.queryRequests("WHERE vr.disaster_report_id = UUID_TO_BIN(?)", mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Error(w, "Error fetching volunteer requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+func (h *VolunteerHandler) ListOwnRequests(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	requests, err := h.queryRequests("WHERE vr.volunteer_id = UUID_TO_BIN(?)", userID)
+	if err != nil {
+		apierror.Error(w, "Error fetching volunteer requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// RespondToRequest records the volunteer's accept or decline decision
+func (h *VolunteerHandler) RespondToRequest(w http.ResponseWriter, r *http.Request) {
+	requestID := mux.Vars(r)["id"]
+	userID := r.Context().Value("user_id").(string)
+
+	var response struct {
+		Decision string `json:"decision"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var status string
+	switch response.Decision {
+	case "accept":
+		status = "accepted"
+	case "decline":
+		status = "declined"
+	default:
+		apierror.Error(w, "Decision must be accept or decline", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(
+		`UPDATE volunteer_requests SET status = ?, responded_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND volunteer_id = UUID_TO_BIN(?) AND status = 'pending'`,
+		status, requestID, userID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error updating volunteer request", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		apierror.Error(w, "Request not found or already answered", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":     requestID,
+		"status": status,
+	})
+}
+
+func (h *VolunteerHandler) queryProfiles(clause string, args ...interface{}) ([]VolunteerProfile, error) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(vp.user_id), u.username, vp.skills, vp.certifications,
+		vp.region, vp.availability, COALESCE(vp.notes, ''), vp.updated_at
+		FROM volunteer_profiles vp
+		JOIN users u ON u.id = vp.user_id `+clause,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []VolunteerProfile{}
+	for rows.Next() {
+		var p VolunteerProfile
+		var skills, certifications []byte
+		if err := rows.Scan(
+			&p.UserID, &p.Username, &skills, &certifications,
+			&p.Region, &p.Availability, &p.Notes, &p.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(skills, &p.Skills)
+		json.Unmarshal(certifications, &p.Certifications)
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+func (h *VolunteerHandler) queryRequests(clause string, args ...interface{}) ([]VolunteerRequest, error) {
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(vr.id), BIN_TO_UUID(vr.disaster_report_id), dr.title,
+		BIN_TO_UUID(vr.volunteer_id), BIN_TO_UUID(vr.requested_by), COALESCE(vr.message, ''),
+		vr.status, vr.responded_at, vr.created_at
+		FROM volunteer_requests vr
+		JOIN disaster_reports dr ON dr.id = vr.disaster_report_id AND dr.deleted_at IS NULL `+clause+`
+		ORDER BY vr.created_at DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []VolunteerRequest{}
+	for rows.Next() {
+		var vr VolunteerRequest
+		if err := rows.Scan(
+			&vr.ID, &vr.ReportID, &vr.ReportTitle, &vr.VolunteerID, &vr.RequestedBy,
+			&vr.Message, &vr.Status, &vr.RespondedAt, &vr.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		requests = append(requests, vr)
+	}
+	return requests, rows.Err()
+}
+
+// normalizeTags lowercases, trims and de-duplicates skill and certification names
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > 50 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
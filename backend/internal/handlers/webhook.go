@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/middleware"
+	"saferelief/internal/tokens"
+	"saferelief/internal/validation"
+	"saferelief/internal/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+const maxWebhookEndpoints = 10
+
+type WebhookEndpoint struct {
+	ID             string   `json:"id"`
+	OrganizationID *string  `json:"organizationId"`
+	URL            string   `json:"url"`
+	Events         []string `json:"events"`
+	// Scope is own, or all_reports for partner endpoints that receive
+	// report events for every report
+	Scope     string    `json:"scope"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus *int       `json:"responseStatus"`
+	LastError      *string    `json:"lastError"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt"`
+	// NextAttemptAt is when a pending delivery is retried
+	NextAttemptAt *time.Time `json:"nextAttemptAt"`
+	DeliveredAt   *time.Time `json:"deliveredAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	// Payload is the signed body, set when a single delivery is fetched
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type WebhookHandler struct {
+	db         *sql.DB
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhookHandler(db *sql.DB, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{db: db, dispatcher: dispatcher}
+}
+
+// ListWebhooks returns the caller's endpoints, or an owned organization's
+// endpoints when organizationId is given
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	clause, ownerID := "user_id = UUID_TO_BIN(?)", userID
+	if orgID := r.URL.Query().Get("organizationId"); orgID != "" {
+		if !h.requireOrganizationOwner(w, orgID, userID) {
+			return
+		}
+		clause, ownerID = "organization_id = UUID_TO_BIN(?)", orgID
+	}
+
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), BIN_TO_UUID(organization_id), url, events, scope, active, created_at
+		FROM webhook_endpoints WHERE `+clause+` ORDER BY created_at DESC`,
+		ownerID,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching webhooks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	endpoints := []WebhookEndpoint{}
+	for rows.Next() {
+		var e WebhookEndpoint
+		var events []byte
+		if err := rows.Scan(&e.ID, &e.OrganizationID, &e.URL, &events, &e.Scope, &e.Active, &e.CreatedAt); err != nil {
+			apierror.Error(w, "Error processing webhooks", http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal(events, &e.Events)
+		endpoints = append(endpoints, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+// CreateWebhook registers an endpoint. The signing secret is returned only
+// in this response; one is generated when the caller doesn't supply it.
+// Partner endpoints scoped to all reports can be registered by admins and
+// for verified organizations.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var request struct {
+		URL            string   `json:"url"`
+		Secret         string   `json:"secret"`
+		Events         []string `json:"events"`
+		Scope          string   `json:"scope"`
+		OrganizationID string   `json:"organizationId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	v := validation.New()
+	if v.Required("url", request.URL) {
+		if err := webhooks.ValidateURL(request.URL); err != nil {
+			v.AddError("url", err.Error())
+		}
+	}
+	if request.Secret != "" {
+		v.Length("secret", request.Secret, 16, 128)
+	}
+	v.Check(len(request.Events) > 0, "events", "must list at least one event")
+	for _, event := range request.Events {
+		v.Check(webhooks.ValidEvent(event), "events", "unknown event "+event)
+	}
+	if request.Scope == "" {
+		request.Scope = webhooks.ScopeOwn
+	}
+	v.Check(request.Scope == webhooks.ScopeOwn || request.Scope == webhooks.ScopeAllReports,
+		"scope", "must be own or all_reports")
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+
+	ownerColumn, ownerID := "user_id", userID
+	var organizationID *string
+	if request.OrganizationID != "" {
+		if !h.requireOrganizationOwner(w, request.OrganizationID, userID) {
+			return
+		}
+		ownerColumn, ownerID = "organization_id", request.OrganizationID
+		organizationID = &request.OrganizationID
+	}
+	if request.Scope == webhooks.ScopeAllReports {
+		allowed, err := h.canSubscribeToAllReports(userID, organizationID)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			apierror.Error(w, "Only admins and verified organizations can subscribe to every report", http.StatusForbidden)
+			return
+		}
+	}
+
+	var count int
+	if err := h.db.QueryRow(
+		"SELECT COUNT(*) FROM webhook_endpoints WHERE "+ownerColumn+" = UUID_TO_BIN(?)",
+		ownerID,
+	).Scan(&count); err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxWebhookEndpoints {
+		apierror.Error(w, "Webhook endpoint limit reached", http.StatusConflict)
+		return
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		generated, _, err := tokens.Generate()
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		secret = generated
+	}
+
+	var ownerUserID *string
+	if organizationID == nil {
+		ownerUserID = &userID
+	}
+	events, _ := json.Marshal(request.Events)
+
+	var endpointID string
+	err := h.db.QueryRow(
+		`INSERT INTO webhook_endpoints (id, user_id, organization_id, url, secret, events, scope, created_by)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, UUID_TO_BIN(?))
+		RETURNING BIN_TO_UUID(id)`,
+		ownerUserID, organizationID, request.URL, secret, events, request.Scope, userID,
+	).Scan(&endpointID)
+	if err != nil {
+		apierror.Error(w, "Error creating webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     endpointID,
+		"url":    request.URL,
+		"events": request.Events,
+		"scope":  request.Scope,
+		"secret": secret,
+	})
+}
+
+// canSubscribeToAllReports allows admins, and owners of verified
+// organizations for the organization's endpoints
+func (h *WebhookHandler) canSubscribeToAllReports(userID string, organizationID *string) (bool, error) {
+	if organizationID != nil {
+		var verified bool
+		err := h.db.QueryRow(
+			"SELECT verification_status = 'verified' FROM organizations WHERE id = UUID_TO_BIN(?)",
+			*organizationID,
+		).Scan(&verified)
+		if err != nil || verified {
+			return verified, err
+		}
+	}
+	role, err := middleware.LookupRole(h.db, userID)
+	if err != nil {
+		return false, err
+	}
+	return role == middleware.RoleAdmin, nil
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	endpointID := mux.Vars(r)["id"]
+	if !h.requireEndpointAccess(w, r, endpointID) {
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM webhook_endpoints WHERE id = UUID_TO_BIN(?)", endpointID); err != nil {
+		apierror.Error(w, "Error deleting webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted"})
+}
+
+// ListDeliveries returns the delivery log for an endpoint, newest first,
+// optionally narrowed to one status or event
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpointID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+	status, event := query.Get("status"), query.Get("event")
+
+	v := validation.New()
+	v.Check(status == "" || status == "pending" || status == "succeeded" || status == "failed",
+		"status", "must be pending, succeeded or failed")
+	v.Check(event == "" || webhooks.ValidEvent(event), "event", "unknown event "+event)
+	if !v.Valid() {
+		v.WriteError(w)
+		return
+	}
+	if !h.requireEndpointAccess(w, r, endpointID) {
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l < limit {
+		limit = l
+	}
+
+	where, args := "endpoint_id = UUID_TO_BIN(?)", []interface{}{endpointID}
+	if status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+	if event != "" {
+		where += " AND event = ?"
+		args = append(args, event)
+	}
+	rows, err := h.db.Query(
+		`SELECT BIN_TO_UUID(id), event, status, attempts, response_status, last_error,
+		last_attempt_at, next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE `+where+`
+		ORDER BY created_at DESC LIMIT ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		apierror.Error(w, "Error fetching deliveries", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(
+			&d.ID, &d.Event, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError,
+			&d.LastAttemptAt, &d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt,
+		); err != nil {
+			apierror.Error(w, "Error processing deliveries", http.StatusInternalServerError)
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// GetDelivery returns one delivery with the payload that was signed, so a
+// receiver can check its signature verification against it
+func (h *WebhookHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	endpointID, deliveryID := vars["id"], vars["deliveryId"]
+	if !h.requireEndpointAccess(w, r, endpointID) {
+		return
+	}
+
+	var d WebhookDelivery
+	var payload []byte
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(id), event, status, attempts, response_status, last_error,
+		last_attempt_at, next_attempt_at, delivered_at, created_at, payload
+		FROM webhook_deliveries
+		WHERE id = UUID_TO_BIN(?) AND endpoint_id = UUID_TO_BIN(?)`,
+		deliveryID, endpointID,
+	).Scan(
+		&d.ID, &d.Event, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError,
+		&d.LastAttemptAt, &d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt, &payload,
+	)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Error fetching delivery", http.StatusInternalServerError)
+		return
+	}
+	d.Payload = payload
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	endpointID, deliveryID := vars["id"], vars["deliveryId"]
+	if !h.requireEndpointAccess(w, r, endpointID) {
+		return
+	}
+
+	var exists bool
+	err := h.db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM webhook_deliveries
+		WHERE id = UUID_TO_BIN(?) AND endpoint_id = UUID_TO_BIN(?))`,
+		deliveryID, endpointID,
+	).Scan(&exists)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		apierror.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.dispatcher.Redeliver(deliveryID); err != nil {
+		apierror.Error(w, "Error scheduling redelivery", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":      deliveryID,
+		"message": "Redelivery scheduled",
+	})
+}
+
+// requireEndpointAccess allows the endpoint's owning user or, for
+// organization endpoints, the organization owner
+func (h *WebhookHandler) requireEndpointAccess(w http.ResponseWriter, r *http.Request, endpointID string) bool {
+	userID := r.Context().Value("user_id").(string)
+
+	var ownerUserID, organizationID sql.NullString
+	err := h.db.QueryRow(
+		`SELECT BIN_TO_UUID(user_id), BIN_TO_UUID(organization_id)
+		FROM webhook_endpoints WHERE id = UUID_TO_BIN(?)`,
+		endpointID,
+	).Scan(&ownerUserID, &organizationID)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Webhook not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if organizationID.Valid {
+		return h.requireOrganizationOwner(w, organizationID.String, userID)
+	}
+	if ownerUserID.String != userID {
+		apierror.Error(w, "Webhook not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func (h *WebhookHandler) requireOrganizationOwner(w http.ResponseWriter, orgID, userID string) bool {
+	var isOwner bool
+	err := h.db.QueryRow(
+		"SELECT owner_id = UUID_TO_BIN(?) FROM organizations WHERE id = UUID_TO_BIN(?)",
+		userID, orgID,
+	).Scan(&isOwner)
+	if err == sql.ErrNoRows {
+		apierror.Error(w, "Organization not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if !isOwner {
+		apierror.Error(w, "Only the organization owner can manage webhooks", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// reportWebhookRecipients addresses an event to the report's reporter and
+// organization, plus any extra users such as the donor
+func reportWebhookRecipients(db *sql.DB, reportID string, userIDs ...string) webhooks.Recipients {
+	var reporterID string
+	var organizationID sql.NullString
+	err := db.QueryRow(
+		"SELECT BIN_TO_UUID(reporter_id), BIN_TO_UUID(organization_id) FROM disaster_reports WHERE id = UUID_TO_BIN(?)",
+		reportID,
+	).Scan(&reporterID, &organizationID)
+	if err != nil {
+		return webhooks.Recipients{UserIDs: userIDs}
+	}
+	return webhooks.Recipients{
+		UserIDs:        append(userIDs, reporterID),
+		OrganizationID: organizationID.String,
+	}
+}
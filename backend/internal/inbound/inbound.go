@@ -0,0 +1,330 @@
+package inbound
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	MaxPayloadSize = 1 << 20
+
+	// DefaultTolerance bounds how old a signed timestamp may be, which keeps
+	// captured requests from being replayed once their event ID is purged
+	DefaultTolerance = 5 * time.Minute
+
+	// Processed payloads are kept this long for debugging and replays
+	RetentionPeriod = 90 * 24 * time.Hour
+
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusProcessed  = "processed"
+	StatusFailed     = "failed"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidPayload   = errors.New("invalid webhook payload")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside tolerance")
+	ErrNotFound         = errors.New("inbound event not found")
+)
+
+// archivedHeaders are kept with the raw payload so a failed event can be
+// re-verified or debugged later; credentials are never stored
+var archivedHeaders = []string{
+	"Content-Type", "User-Agent",
+	"X-Amz-Sns-Message-Type", "X-Amz-Sns-Message-Id", "X-Amz-Sns-Topic-Arn",
+	"X-Twilio-Email-Event-Webhook-Timestamp",
+}
+
+// Provider authenticates and processes callbacks from one external service
+type Provider struct {
+	// Verify checks the request signature and returns the provider's event
+	// ID; an empty ID falls back to a hash of the body
+	Verify func(r *http.Request, body []byte) (eventID string, err error)
+	// Process runs on the job queue and must tolerate being retried
+	Process     func(ctx context.Context, payload []byte) error
+	MaxAttempts int
+}
+
+// Receiver archives verified callbacks before acknowledging them, so slow or
+// failing processing never makes a provider retry or give up on us
+type Receiver struct {
+	db        *sql.DB
+	queue     *jobs.Queue
+	providers map[string]Provider
+}
+
+func NewReceiver(db *sql.DB, queue *jobs.Queue) *Receiver {
+	return &Receiver{db: db, queue: queue, providers: make(map[string]Provider)}
+}
+
+func (rc *Receiver) Register(name string, provider Provider) {
+	if provider.MaxAttempts <= 0 {
+		provider.MaxAttempts = 5
+	}
+	rc.providers[name] = provider
+}
+
+// Providers returns the registered provider names
+func (rc *Receiver) Providers() []string {
+	names := make([]string, 0, len(rc.providers))
+	for name := range rc.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ServeHTTP receives a callback for the {provider} route variable
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.receive(w, r, mux.Vars(r)["provider"])
+}
+
+// Handler serves a single provider on a fixed path
+func (rc *Receiver) Handler(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc.receive(w, r, name)
+	}
+}
+
+func (rc *Receiver) receive(w http.ResponseWriter, r *http.Request, name string) {
+	provider, ok := rc.providers[name]
+	if !ok {
+		apierror.Error(w, "Unknown provider", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxPayloadSize+1))
+	if err != nil {
+		apierror.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > MaxPayloadSize {
+		apierror.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	eventID, err := provider.Verify(r, body)
+	switch {
+	case errors.Is(err, ErrInvalidPayload):
+		apierror.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	case err != nil:
+		apierror.Write(w, http.StatusForbidden, apierror.CodeInvalidSignature, "Invalid signature", nil)
+		return
+	}
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	headers := make(map[string]string)
+	for _, name := range archivedHeaders {
+		if value := r.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	encodedHeaders, _ := json.Marshal(headers)
+
+	// The unique (provider, event_id) key makes redeliveries a no-op
+	var id string
+	err = rc.db.QueryRow(
+		`INSERT IGNORE INTO inbound_webhook_events (id, provider, event_id, headers, payload)
+		VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, ?)
+		RETURNING BIN_TO_UUID(id)`,
+		name, eventID, encodedHeaders, body,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to archive %s webhook %s: %v", name, eventID, err)
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Stays pending when the queue is full; the sweeper picks it up later
+	rc.enqueue(id, name, provider)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (rc *Receiver) enqueue(id, name string, provider Provider) {
+	err := rc.queue.Enqueue(jobs.Job{
+		Name:        "inbound-" + name,
+		MaxAttempts: provider.MaxAttempts,
+		Run: func(ctx context.Context) error {
+			return rc.process(ctx, id, provider)
+		},
+		OnFailure: func(err error) {
+			rc.db.Exec(
+				"UPDATE inbound_webhook_events SET status = ? WHERE id = UUID_TO_BIN(?) AND status = ?",
+				StatusFailed, id, StatusProcessing,
+			)
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue inbound %s event %s: %v", name, id, err)
+	}
+}
+
+func (rc *Receiver) process(ctx context.Context, id string, provider Provider) error {
+	// Claiming the row keeps the sweeper and a replay from running it twice
+	result, err := rc.db.ExecContext(ctx,
+		`UPDATE inbound_webhook_events
+		SET status = ?, attempts = attempts + 1, updated_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND status IN (?, ?)`,
+		StatusProcessing, id, StatusPending, StatusProcessing,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil
+	}
+
+	var payload []byte
+	if err := rc.db.QueryRowContext(ctx,
+		"SELECT payload FROM inbound_webhook_events WHERE id = UUID_TO_BIN(?)", id,
+	).Scan(&payload); err != nil {
+		return err
+	}
+
+	if err := provider.Process(ctx, payload); err != nil {
+		// Left processing so the next attempt can claim it again
+		rc.db.Exec(
+			"UPDATE inbound_webhook_events SET last_error = ? WHERE id = UUID_TO_BIN(?)",
+			truncate(err.Error(), 1000), id,
+		)
+		return err
+	}
+
+	_, err = rc.db.Exec(
+		`UPDATE inbound_webhook_events
+		SET status = ?, last_error = NULL, processed_at = NOW()
+		WHERE id = UUID_TO_BIN(?)`,
+		StatusProcessed, id,
+	)
+	return err
+}
+
+// Replay processes an archived event again, e.g. after fixing the bug that
+// made it fail
+func (rc *Receiver) Replay(ctx context.Context, id string) error {
+	var name string
+	err := rc.db.QueryRowContext(ctx,
+		"SELECT provider FROM inbound_webhook_events WHERE id = UUID_TO_BIN(?)", id,
+	).Scan(&name)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	provider, ok := rc.providers[name]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if _, err := rc.db.ExecContext(ctx,
+		"UPDATE inbound_webhook_events SET status = ?, updated_at = NOW() WHERE id = UUID_TO_BIN(?)",
+		StatusPending, id,
+	); err != nil {
+		return err
+	}
+	rc.enqueue(id, name, provider)
+	return nil
+}
+
+// Start periodically queues events left pending by a full queue or a
+// restart and those stuck processing when a worker died, and purges
+// processed events past the retention period
+func (rc *Receiver) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rc.sweep()
+		}
+	}()
+}
+
+func (rc *Receiver) sweep() {
+	if _, err := rc.db.Exec(
+		"DELETE FROM inbound_webhook_events WHERE status = ? AND processed_at < ?",
+		StatusProcessed, time.Now().Add(-RetentionPeriod),
+	); err != nil {
+		log.Printf("Failed to purge inbound webhook events: %v", err)
+	}
+
+	rows, err := rc.db.Query(
+		`SELECT BIN_TO_UUID(id), provider FROM inbound_webhook_events
+		WHERE (status = ? AND updated_at < NOW() - INTERVAL 1 MINUTE)
+			OR (status = ? AND updated_at < NOW() - INTERVAL 15 MINUTE)
+		ORDER BY received_at LIMIT 100`,
+		StatusPending, StatusProcessing,
+	)
+	if err != nil {
+		log.Printf("Inbound webhook sweep failed: %v", err)
+		return
+	}
+	type pending struct{ id, provider string }
+	var events []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.provider); err == nil {
+			events = append(events, p)
+		}
+	}
+	rows.Close()
+
+	for _, event := range events {
+		provider, ok := rc.providers[event.provider]
+		if !ok {
+			continue
+		}
+		rc.db.Exec(
+			"UPDATE inbound_webhook_events SET status = ?, updated_at = NOW() WHERE id = UUID_TO_BIN(?)",
+			StatusPending, event.id,
+		)
+		rc.enqueue(event.id, event.provider, provider)
+	}
+}
+
+// CheckTimestamp rejects a signed timestamp (Unix seconds or RFC 3339) that
+// is further than tolerance from now in either direction
+func CheckTimestamp(value string, tolerance time.Duration) error {
+	var ts time.Time
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		ts = time.Unix(seconds, 0)
+	} else if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		ts = parsed
+	} else {
+		return ErrStaleTimestamp
+	}
+	if skew := time.Since(ts); skew > tolerance || skew < -tolerance {
+		return ErrStaleTimestamp
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
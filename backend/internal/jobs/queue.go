@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrQueueFull = errors.New("job queue is full")
+
+type Job struct {
+	Name        string
+	Run         func(ctx context.Context) error
+	MaxAttempts int
+	// OnFailure is called once all attempts have failed
+	OnFailure func(err error)
+}
+
+// Queue runs background jobs on a fixed pool of workers, retrying failed
+// jobs with exponential backoff
+type Queue struct {
+	jobs    chan Job
+	workers int
+	timeout time.Duration
+}
+
+func NewQueue(workers, buffer int) *Queue {
+	return &Queue{
+		jobs:    make(chan Job, buffer),
+		workers: workers,
+		timeout: 5 * time.Minute,
+	}
+}
+
+func (q *Queue) Start() {
+	for i := 0; i < q.workers; i++ {
+		go q.work()
+	}
+}
+
+// Enqueue schedules a job without blocking the caller
+func (q *Queue) Enqueue(job Job) error {
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 1
+	}
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Pending returns the number of jobs waiting for a worker
+func (q *Queue) Pending() int {
+	return len(q.jobs)
+}
+
+func (q *Queue) work() {
+	for job := range q.jobs {
+		q.run(job)
+	}
+}
+
+func (q *Queue) run(job Job) {
+	backoff := time.Second
+	for attempt := 1; attempt <= job.MaxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+		err := job.Run(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		log.Printf("Job %s failed (attempt %d/%d): %v", job.Name, attempt, job.MaxAttempts, err)
+		if attempt < job.MaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+		if job.OnFailure != nil {
+			job.OnFailure(err)
+		}
+	}
+}
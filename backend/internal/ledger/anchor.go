@@ -0,0 +1,62 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Anchor publishes a batch's chain hash somewhere outside our control and
+// returns a reference that proves when it was published
+type Anchor interface {
+	Method() string
+	Submit(ctx context.Context, digest []byte) (string, error)
+}
+
+// OpenTimestamps submits digests to an OpenTimestamps calendar, which
+// aggregates them into a Bitcoin transaction. The reference is the
+// calendar's pending timestamp (base64); `ots upgrade` completes it once the
+// transaction confirms.
+type OpenTimestamps struct {
+	calendarURL string
+	client      *http.Client
+}
+
+func NewOpenTimestamps(calendarURL string) *OpenTimestamps {
+	return &OpenTimestamps{
+		calendarURL: strings.TrimRight(calendarURL, "/"),
+		client:      &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (o *OpenTimestamps) Method() string {
+	return "opentimestamps"
+}
+
+func (o *OpenTimestamps) Submit(ctx context.Context, digest []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.calendarURL+"/digest", bytes.NewReader(digest))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.opentimestamps.v1")
+	req.Header.Set("User-Agent", "SafeRelief-Ledger/1.0")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("calendar responded with %d", resp.StatusCode)
+	}
+	return base64.StdEncoding.EncodeToString(body), nil
+}
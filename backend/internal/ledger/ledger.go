@@ -0,0 +1,170 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	EntryDonation = "donation"
+
+	maxBatchSize = 10000
+)
+
+var ErrNotSealed = errors.New("entry has not been sealed into the ledger yet")
+
+// LeafData is the canonical record committed for a completed donation. Donor
+// identity is left out so proofs can be shared publicly.
+func LeafData(donationID, reportID, amount, currency string, completedAt time.Time) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s", EntryDonation, donationID, reportID, amount, currency,
+		completedAt.UTC().Format(time.RFC3339))
+}
+
+// Start seals newly completed donations into a batch and anchors pending
+// batches on every tick. anchor may be nil, in which case roots are only
+// published through the API.
+func Start(db *sql.DB, anchor Anchor, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			if sequence, count, err := Seal(ctx, db); err != nil {
+				log.Printf("Failed to seal ledger batch: %v", err)
+			} else if count > 0 {
+				log.Printf("Sealed ledger batch %d with %d donations", sequence, count)
+			}
+			if anchor != nil {
+				anchorPending(ctx, db, anchor)
+			}
+			cancel()
+		}
+	}()
+}
+
+// Seal commits every completed donation not yet in the ledger to a new
+// batch, chained to the previous one. It returns the batch sequence and size.
+func Seal(ctx context.Context, db *sql.DB) (int, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Locking the latest batch serializes sealing across instances
+	var sequence int
+	var previousChain string
+	err = tx.QueryRowContext(ctx,
+		"SELECT sequence, chain_hash FROM ledger_batches ORDER BY sequence DESC LIMIT 1 FOR UPDATE",
+	).Scan(&sequence, &previousChain)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, 0, err
+	}
+	previous := make([]byte, 32)
+	if previousChain != "" {
+		if previous, err = hex.DecodeString(previousChain); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT BIN_TO_UUID(d.id), BIN_TO_UUID(d.disaster_report_id), CAST(d.amount AS CHAR),
+			d.currency, d.completed_at
+		FROM donations d
+		LEFT JOIN ledger_entries le ON le.entry_type = ? AND le.entry_id = d.id
+		WHERE d.completed_at IS NOT NULL AND le.entry_id IS NULL
+		ORDER BY d.completed_at, d.id
+		LIMIT ?`,
+		EntryDonation, maxBatchSize,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	var ids, data []string
+	var leaves [][]byte
+	for rows.Next() {
+		var id, reportID, amount, currency string
+		var completedAt time.Time
+		if err := rows.Scan(&id, &reportID, &amount, &currency, &completedAt); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		d := LeafData(id, reportID, amount, currency, completedAt)
+		ids = append(ids, id)
+		data = append(data, d)
+		leaves = append(leaves, LeafHash([]byte(d)))
+	}
+	rows.Close()
+	if len(leaves) == 0 {
+		return sequence, 0, nil
+	}
+
+	root := Root(leaves)
+	sequence++
+	var batchID string
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO ledger_batches (id, sequence, merkle_root, chain_hash, leaf_count)
+		VALUES (UUID_TO_BIN(UUID()), ?, ?, ?, ?)
+		RETURNING BIN_TO_UUID(id)`,
+		sequence, hex.EncodeToString(root), hex.EncodeToString(ChainHash(previous, root)), len(leaves),
+	).Scan(&batchID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range leaves {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO ledger_entries (entry_type, entry_id, batch_id, leaf_index, leaf_hash, leaf_data)
+			VALUES (?, UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?)`,
+			EntryDonation, ids[i], batchID, i, hex.EncodeToString(leaves[i]), data[i],
+		); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return sequence, len(leaves), tx.Commit()
+}
+
+// anchorPending submits every batch that has not been anchored yet, oldest
+// first, leaving failures for the next tick
+func anchorPending(ctx context.Context, db *sql.DB, anchor Anchor) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT BIN_TO_UUID(id), chain_hash FROM ledger_batches WHERE anchored_at IS NULL ORDER BY sequence LIMIT 50",
+	)
+	if err != nil {
+		log.Printf("Failed to load unanchored ledger batches: %v", err)
+		return
+	}
+	type pending struct{ id, chainHash string }
+	var batches []pending
+	for rows.Next() {
+		var p pending
+		if rows.Scan(&p.id, &p.chainHash) == nil {
+			batches = append(batches, p)
+		}
+	}
+	rows.Close()
+
+	for _, batch := range batches {
+		digest, _ := hex.DecodeString(batch.chainHash)
+		ref, err := anchor.Submit(ctx, digest)
+		if err != nil {
+			log.Printf("Failed to anchor ledger batch %s: %v", batch.id, err)
+			db.ExecContext(ctx,
+				"UPDATE ledger_batches SET anchor_error = ? WHERE id = UUID_TO_BIN(?)",
+				err.Error(), batch.id,
+			)
+			continue
+		}
+		db.ExecContext(ctx,
+			`UPDATE ledger_batches SET anchor_method = ?, anchor_ref = ?, anchor_error = NULL,
+			anchored_at = NOW() WHERE id = UUID_TO_BIN(?)`,
+			anchor.Method(), ref, batch.id,
+		)
+	}
+}
@@ -0,0 +1,107 @@
+package ledger
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Leaves and interior nodes are hashed with distinct prefixes so an interior
+// node can never be passed off as a leaf
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// ProofStep is one sibling on the path from a leaf to the root
+type ProofStep struct {
+	Hash string `json:"hash"`
+	// Position of the sibling: "left" means hash(sibling || current)
+	Position string `json:"position"`
+}
+
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Root folds leaf hashes pairwise; an unpaired node moves up a level as is
+func Root(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+	return level[0]
+}
+
+// Proof returns the sibling path for the leaf at index
+func Proof(leaves [][]byte, index int) []ProofStep {
+	steps := []ProofStep{}
+	level := leaves
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			position := "right"
+			if sibling < index {
+				position = "left"
+			}
+			steps = append(steps, ProofStep{Hash: hex.EncodeToString(level[sibling]), Position: position})
+		}
+		level = nextLevel(level)
+		index /= 2
+	}
+	return steps
+}
+
+// Verify recomputes the root from a leaf hash and its proof
+func Verify(leaf []byte, proof []ProofStep, root []byte) bool {
+	current := leaf
+	for _, step := range proof {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return false
+		}
+		switch step.Position {
+		case "left":
+			current = nodeHash(sibling, current)
+		case "right":
+			current = nodeHash(current, sibling)
+		default:
+			return false
+		}
+	}
+	return bytes.Equal(current, root)
+}
+
+// ChainHash links a batch to every batch before it
+func ChainHash(previous, root []byte) []byte {
+	h := sha256.New()
+	h.Write(previous)
+	h.Write(root)
+	return h.Sum(nil)
+}
+
+func nextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+		} else {
+			next = append(next, nodeHash(level[i], level[i+1]))
+		}
+	}
+	return next
+}
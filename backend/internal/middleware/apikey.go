@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/apikeys"
+	"saferelief/internal/ratelimit"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+type APIKeyMiddleware struct {
+	db      *sql.DB
+	limiter *ratelimit.Limiter
+}
+
+func NewAPIKeyMiddleware(db *sql.DB, limiter *ratelimit.Limiter) *APIKeyMiddleware {
+	return &APIKeyMiddleware{db: db, limiter: limiter}
+}
+
+// Require admits requests carrying a key with the given scope, applying the
+// partner tier per minute and the key's own daily quota
+func (m *APIKeyMiddleware) Require(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := apikeys.Lookup(r.Context(), m.db, r.Header.Get(APIKeyHeader))
+			if err == apikeys.ErrInvalidKey {
+				apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidAPIKey,
+					"A valid API key is required in the "+APIKeyHeader+" header", nil)
+				return
+			}
+			if err != nil {
+				apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !key.HasScope(scope) {
+				apierror.Error(w, "API key is not allowed to access this resource", http.StatusForbidden)
+				return
+			}
+			if !m.admit(w, r, key) {
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "api_key_id", key.ID)
+			ctx = context.WithValue(ctx, "user_id", key.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// admit applies the partner tier per minute and the key's daily quota,
+// writing the error response and returning false when either is used up
+func (m *APIKeyMiddleware) admit(w http.ResponseWriter, r *http.Request, key apikeys.Key) bool {
+	decision := m.limiter.Allow("key:"+key.ID, ratelimit.TierPartner)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("X-RateLimit-Tier", string(ratelimit.TierPartner))
+	if !decision.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+		apierror.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+
+	used, err := apikeys.RecordUsage(r.Context(), m.db, key.ID)
+	if err != nil {
+		// Quota accounting must not take the API down
+		log.Printf("Failed to record usage for API key %s: %v", key.ID, err)
+		return true
+	}
+	w.Header().Set("X-Quota-Limit", strconv.Itoa(key.DailyQuota))
+	w.Header().Set("X-Quota-Remaining", strconv.Itoa(max(key.DailyQuota-used, 0)))
+	if used > key.DailyQuota {
+		now := time.Now().UTC()
+		reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+		apierror.Write(w, http.StatusTooManyRequests, apierror.CodeQuotaExceeded, "Daily quota exceeded", nil)
+		return false
+	}
+	return true
+}
+
+// BearerKey returns the API key from an Authorization: Bearer header
+func BearerKey(r *http.Request) (string, bool) {
+	scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	key = strings.TrimSpace(key)
+	return key, key != ""
+}
+
+// RequireSession refuses requests authenticated with an API key, for routes
+// that manage credentials or need an interactive login
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if keyID, _ := r.Context().Value("api_key_id").(string); keyID != "" {
+			apierror.Error(w, "This endpoint requires a signed-in session, not an API key", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,52 +2,202 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
 	"strings"
 
+	"saferelief/internal/accounts"
+	"saferelief/internal/apierror"
+	"saferelief/internal/apikeys"
+	"saferelief/internal/ratelimit"
+	"saferelief/internal/sessions"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthMiddleware struct {
 	jwtSecret []byte
+	db        *sql.DB
+	apiKeys   *APIKeyMiddleware
 }
 
-func NewAuthMiddleware(jwtSecret []byte) *AuthMiddleware {
-	return &AuthMiddleware{jwtSecret: jwtSecret}
+// NewAuthMiddleware authenticates the access token cookie, or an API key
+// sent as a bearer token when apiKeys is set
+func NewAuthMiddleware(jwtSecret []byte, db *sql.DB, apiKeys *APIKeyMiddleware) *AuthMiddleware {
+	return &AuthMiddleware{jwtSecret: jwtSecret, db: db, apiKeys: apiKeys}
+}
+
+// parseAccessToken validates the access token cookie and returns its claims
+func (m *AuthMiddleware) parseAccessToken(r *http.Request) (jwt.MapClaims, bool) {
+	// Get token from cookie
+	cookie, err := r.Cookie("access_token")
+	if err != nil {
+		return nil, false
+	}
+
+	// Parse and validate token
+	token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return m.jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
+
+// Identify resolves the caller's rate-limit identity from a valid access
+// token without rejecting anonymous requests. It skips the session check, so
+// a revoked token still counts against its own quota until it expires.
+func (m *AuthMiddleware) Identify(r *http.Request) (string, ratelimit.Tier, bool) {
+	claims, ok := m.parseAccessToken(r)
+	if !ok {
+		return "", "", false
+	}
+	userID, ok := claims["sub"].(string)
+	if !ok {
+		return "", "", false
+	}
+	tier, _ := claims["tier"].(string)
+	if tier == "" {
+		tier = string(ratelimit.TierUser)
+	}
+	return userID, ratelimit.Tier(tier), true
 }
 
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get token from cookie
-		cookie, err := r.Cookie("access_token")
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		// A bearer key is used on its own; cookies sent alongside are ignored
+		if key, ok := BearerKey(r); ok && m.apiKeys != nil {
+			m.authenticateKey(w, r, next, key)
 			return
 		}
 
-		// Parse and validate token
-		token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return m.jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		claims, ok := m.parseAccessToken(r)
+		if !ok {
+			apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// Extract claims and add user ID to context
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			ctx := context.WithValue(r.Context(), "user_id", claims["sub"])
-			next.ServeHTTP(w, r.WithContext(ctx))
-		} else {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		userID, ok := claims["sub"].(string)
+		if !ok {
+			apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// The session must still exist so revoked devices lose access at once
+		sessionID, ok := claims["sid"].(string)
+		if !ok {
+			apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		active, err := sessions.Active(m.db, sessionID, userID)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeSessionRevoked, "Session has been revoked", nil)
+			return
+		}
+
+		// Suspended and banned accounts keep valid tokens until they expire,
+		// so the restriction is checked on every request
+		if !m.checkSuspension(w, userID) {
+			return
 		}
+
+		// Tokens issued before roles were embedded fall back to the database
+		role := Role(claimString(claims, "role"))
+		if role == "" {
+			role, err = LookupRole(m.db, userID)
+			if err != nil {
+				apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), "user_id", userID)
+		ctx = context.WithValue(ctx, "session_id", sessionID)
+		ctx = context.WithValue(ctx, "role", role)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// authenticateKey admits a request made with an API key as the key's owner.
+// Keys with the read scope may use safe methods, write covers the rest.
+func (m *AuthMiddleware) authenticateKey(w http.ResponseWriter, r *http.Request, next http.Handler, value string) {
+	key, err := apikeys.Lookup(r.Context(), m.db, value)
+	if err == apikeys.ErrInvalidKey {
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Invalid API key", nil)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	scope := apikeys.ScopeWrite
+	if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
+		scope = apikeys.ScopeRead
+	}
+	if !key.HasScope(scope) {
+		apierror.Error(w, "API key lacks the "+scope+" scope", http.StatusForbidden)
+		return
+	}
+	if !m.checkSuspension(w, key.UserID) {
+		return
+	}
+	role, err := LookupRole(m.db, key.UserID)
+	if err != nil {
+		apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !m.apiKeys.admit(w, r, key) {
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "user_id", key.UserID)
+	ctx = context.WithValue(ctx, "api_key_id", key.ID)
+	// No session backs a key; handlers that compare against the current
+	// session see none
+	ctx = context.WithValue(ctx, "session_id", "")
+	ctx = context.WithValue(ctx, "role", role)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// checkSuspension writes the error response and returns false when the
+// account is suspended or banned
+func (m *AuthMiddleware) checkSuspension(w http.ResponseWriter, userID string) bool {
+	suspension, err := accounts.ActiveSuspension(m.db, userID)
+	if err != nil {
+		apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if suspension != nil {
+		code, message := apierror.CodeAccountSuspended, "Account is suspended"
+		if suspension.Kind == accounts.KindBan {
+			code, message = apierror.CodeAccountBanned, "Account is banned"
+		}
+		apierror.Write(w, http.StatusForbidden, code, message, map[string]interface{}{
+			"reason":    suspension.Reason,
+			"expiresAt": suspension.ExpiresAt,
+		})
+		return false
+	}
+	return true
+}
+
+func claimString(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
 type CSRFMiddleware struct {
 	secretKey []byte
 }
@@ -63,13 +213,19 @@ func (m *CSRFMiddleware) ValidateCSRF(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
+		// A browser never attaches a bearer key on its own, and Authenticate
+		// ignores cookies when one is present
+		if _, ok := BearerKey(r); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
 
 		// Get CSRF token from header
 		token := r.Header.Get("X-CSRF-Token")
 		cookie, err := r.Cookie("CSRF-Token")
 
 		if err != nil || cookie == nil || token == "" || token != cookie.Value {
-			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			apierror.Write(w, http.StatusForbidden, apierror.CodeInvalidCSRFToken, "Invalid CSRF token", nil)
 			return
 		}
 
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/consent"
+)
+
+type ConsentMiddleware struct {
+	db     *sql.DB
+	exempt map[string]bool
+}
+
+// NewConsentMiddleware gates requests on agreement to the current policy
+// versions. Paths in exempt stay reachable so users can read their profile
+// and record consent.
+func NewConsentMiddleware(db *sql.DB, exempt ...string) *ConsentMiddleware {
+	m := &ConsentMiddleware{db: db, exempt: map[string]bool{}}
+	for _, path := range exempt {
+		m.exempt[path] = true
+	}
+	return m
+}
+
+// RequireCurrentConsent answers 403 with the outstanding policy versions when
+// the user has not agreed to the latest privacy policy or terms. It must run
+// after AuthMiddleware.Authenticate.
+func (m *ConsentMiddleware) RequireCurrentConsent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.exempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, ok := r.Context().Value("user_id").(string)
+		if !ok {
+			apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		outstanding, err := consent.Outstanding(m.db, userID)
+		if err != nil {
+			apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if len(outstanding) > 0 {
+			apierror.Write(w, http.StatusForbidden, apierror.CodeConsentRequired,
+				"Accept the updated policies to continue", map[string]interface{}{"policies": outstanding})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
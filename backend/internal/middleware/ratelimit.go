@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"saferelief/internal/apierror"
+	"saferelief/internal/audit"
+	"saferelief/internal/ratelimit"
+)
+
+type RateLimitMiddleware struct {
+	limiter *ratelimit.Limiter
+	auth    *AuthMiddleware
+}
+
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter, auth *AuthMiddleware) *RateLimitMiddleware {
+	return &RateLimitMiddleware{limiter: limiter, auth: auth}
+}
+
+// Limit applies the caller's tier quota. Signed-in users are counted per
+// account and anonymous callers per IP address. Requests with a bearer key
+// get the partner tier per IP here; Authenticate limits each key itself.
+func (m *RateLimitMiddleware) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + audit.ClientIP(r)
+		tier := ratelimit.TierAnonymous
+		if _, ok := BearerKey(r); ok {
+			key = "bearer-ip:" + audit.ClientIP(r)
+			tier = ratelimit.TierPartner
+		} else if userID, userTier, ok := m.auth.Identify(r); ok {
+			key = "user:" + userID
+			tier = userTier
+		}
+
+		if m.allow(w, key, tier) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// LimitAs counts every caller per IP against one tier, signed in or not, for
+// routes that carry their own budget
+func (m *RateLimitMiddleware) LimitAs(tier ratelimit.Tier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.allow(w, string(tier)+"-ip:"+audit.ClientIP(r), tier) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// allow records the request, sets the rate limit headers and writes the 429
+// when the quota is spent
+func (m *RateLimitMiddleware) allow(w http.ResponseWriter, key string, tier ratelimit.Tier) bool {
+	decision := m.limiter.Allow(key, tier)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("X-RateLimit-Tier", string(tier))
+	if !decision.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+		apierror.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
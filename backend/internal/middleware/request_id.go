@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"saferelief/internal/apierror"
+)
+
+// A caller-supplied ID is only trusted when it can't inject anything into logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// RequestID tags every request with an ID, kept from the X-Request-ID header
+// of a proxy when present, and returns it on the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(apierror.RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(apierror.RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), "request_id", id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"saferelief/internal/apierror"
+)
+
+type Role string
+
+const (
+	RoleDonor    Role = "donor"
+	RoleReporter Role = "reporter"
+	RoleVerifier Role = "verifier"
+	RoleAdmin    Role = "admin"
+)
+
+// Roles lists every role from least to most privileged
+var Roles = []Role{RoleDonor, RoleReporter, RoleVerifier, RoleAdmin}
+
+type Permission string
+
+const (
+	PermCreateDonation  Permission = "donations:create"
+	PermManageDonations Permission = "donations:manage"
+	PermCreateReport    Permission = "reports:create"
+	PermVerifyReports   Permission = "reports:verify"
+	PermAdminAccess     Permission = "admin:access"
+	// Coordinators search the volunteer registry and request volunteers
+	PermCoordinateVolunteers Permission = "volunteers:coordinate"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleDonor:    {PermCreateDonation, PermCreateReport},
+	RoleReporter: {PermCreateDonation, PermCreateReport},
+	RoleVerifier: {PermCreateDonation, PermCreateReport, PermVerifyReports, PermCoordinateVolunteers},
+	RoleAdmin: {
+		PermCreateDonation, PermManageDonations, PermCreateReport,
+		PermVerifyReports, PermAdminAccess, PermCoordinateVolunteers,
+	},
+}
+
+func ValidRole(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func (role Role) Permissions() []Permission {
+	return rolePermissions[role]
+}
+
+func (role Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupRole fetches the current role of a user from the database
+func LookupRole(db *sql.DB, userID string) (Role, error) {
+	var role Role
+	err := db.QueryRow(
+		"SELECT role FROM users WHERE id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&role)
+	return role, err
+}
+
+// RequireRole admits only users whose role, as carried in the access token,
+// is one of roles. It must run after AuthMiddleware.Authenticate. Every role
+// change goes through accounts.ChangeRoleTx, which ends the user's sessions,
+// and Authenticate rejects tokens whose session is gone, so the token role
+// is never stale. API keys carry no role and are looked up on each request.
+func RequireRole(roles ...Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, ok := r.Context().Value("role").(Role)
+			if !ok {
+				apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			apierror.Write(w, http.StatusForbidden, apierror.CodeInsufficientPermission, "Forbidden",
+				map[string]interface{}{"roles": roles})
+		})
+	}
+}
+
+type RoleMiddleware struct {
+	db *sql.DB
+}
+
+func NewRoleMiddleware(db *sql.DB) *RoleMiddleware {
+	return &RoleMiddleware{db: db}
+}
+
+// RequirePermission rejects requests from users whose role lacks the permission.
+// It must run after AuthMiddleware.Authenticate and stores the role in the context.
+func (m *RoleMiddleware) RequirePermission(permission Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value("user_id").(string)
+			if !ok {
+				apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			role, err := LookupRole(m.db, userID)
+			if err == sql.ErrNoRows {
+				apierror.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				apierror.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			if !role.Can(permission) {
+				apierror.Write(w, http.StatusForbidden, apierror.CodeInsufficientPermission, "Forbidden",
+					map[string]string{"permission": string(permission)})
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "role", role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package notify
+
+import "time"
+
+var sampleTime = time.Date(2025, time.January, 6, 9, 30, 0, 0, time.UTC)
+
+// definitions lists every notification the application sends. The wording
+// here is used until an admin saves a version through the template API.
+var definitions = map[string]Definition{
+	"email_change.confirm_new": {
+		Key:         "email_change.confirm_new",
+		Description: "Sent to the new address to confirm an email change",
+		Sample:      map[string]interface{}{"Link": "https://saferelief.id/account/email-change?token=sample"},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Confirm your new SafeRelief email address",
+				Body: "Someone asked to use this address for a SafeRelief account.\n\n" +
+					"Confirm it within 24 hours: {{.Link}}\n\n" +
+					"If this wasn't you, ignore this email.",
+			},
+			"id": {
+				Subject: "Konfirmasi alamat email SafeRelief baru Anda",
+				Body: "Seseorang meminta alamat ini digunakan untuk akun SafeRelief.\n\n" +
+					"Konfirmasi dalam 24 jam: {{.Link}}\n\n" +
+					"Jika ini bukan Anda, abaikan email ini.",
+			},
+		},
+	},
+	"email_change.notice_current": {
+		Key:         "email_change.notice_current",
+		Description: "Sent to the current address when an email change is requested",
+		Sample: map[string]interface{}{
+			"NewEmail":    "new@example.com",
+			"ConfirmLink": "https://saferelief.id/account/email-change?token=sample",
+			"CancelLink":  "https://saferelief.id/account/email-change?token=sample&action=cancel",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your SafeRelief email address is being changed",
+				Body: "A request was made to change your SafeRelief email to {{.NewEmail}}.\n\n" +
+					"Confirm the change within 24 hours: {{.ConfirmLink}}\n\n" +
+					"If this wasn't you, cancel it and change your password: {{.CancelLink}}",
+			},
+			"id": {
+				Subject: "Alamat email SafeRelief Anda sedang diubah",
+				Body: "Ada permintaan untuk mengubah email SafeRelief Anda menjadi {{.NewEmail}}.\n\n" +
+					"Konfirmasi perubahan dalam 24 jam: {{.ConfirmLink}}\n\n" +
+					"Jika ini bukan Anda, batalkan dan ganti kata sandi Anda: {{.CancelLink}}",
+			},
+		},
+	},
+	"email_change.completed": {
+		Key:         "email_change.completed",
+		Description: "Sent to the old address once an email change completes",
+		Sample:      map[string]interface{}{"NewEmail": "new@example.com"},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your SafeRelief email address was changed",
+				Body: "The email address on your SafeRelief account is now {{.NewEmail}}.\n\n" +
+					"If you didn't make this change, contact support@saferelief.id immediately.",
+			},
+			"id": {
+				Subject: "Alamat email SafeRelief Anda telah diubah",
+				Body: "Alamat email akun SafeRelief Anda sekarang {{.NewEmail}}.\n\n" +
+					"Jika Anda tidak melakukan perubahan ini, segera hubungi support@saferelief.id.",
+			},
+		},
+	},
+	"user_import.invitation": {
+		Key:         "user_import.invitation",
+		Description: "Invitation for staff created by a bulk CSV import",
+		Sample: map[string]interface{}{
+			"Name": "Siti Rahma",
+			"Role": "verifier",
+			"Link": "https://saferelief.id/invitation?token=sample",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "You're invited to SafeRelief",
+				Body: "Hi {{.Name}},\n\nYou've been invited to SafeRelief as a {{.Role}}.\n\n" +
+					"Set your password within 7 days to activate your account: {{.Link}}",
+			},
+			"id": {
+				Subject: "Anda diundang ke SafeRelief",
+				Body: "Halo {{.Name}},\n\nAnda diundang ke SafeRelief sebagai {{.Role}}.\n\n" +
+					"Atur kata sandi Anda dalam 7 hari untuk mengaktifkan akun: {{.Link}}",
+			},
+		},
+	},
+	"donation.receipt": {
+		Key:         "donation.receipt",
+		Description: "Receipt for a completed donation, with the PDF attached",
+		Sample: map[string]interface{}{
+			"Number": "SR-2025-000042",
+			"Amount": "IDR 250,000.00",
+			"Title":  "Gempa Cianjur",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your SafeRelief donation receipt {{.Number}}",
+				Body: "Thank you for your donation of {{.Amount}} to {{.Title}}.\n\n" +
+					"Your receipt {{.Number}} is attached. You can download it again from your donations in your SafeRelief account.",
+			},
+			"id": {
+				Subject: "Tanda terima donasi SafeRelief {{.Number}}",
+				Body: "Terima kasih atas donasi Anda sebesar {{.Amount}} untuk {{.Title}}.\n\n" +
+					"Tanda terima {{.Number}} terlampir. Anda dapat mengunduhnya lagi dari daftar donasi di akun SafeRelief Anda.",
+			},
+		},
+	},
+	"donation.refunded": {
+		Key:         "donation.refunded",
+		Description: "Notice that a donation was refunded, with the reason",
+		Sample: map[string]interface{}{
+			"Amount": "IDR 250,000.00",
+			"Title":  "Gempa Cianjur",
+			"Reason": "Duplicate payment",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your SafeRelief donation has been refunded",
+				Body: "Your donation of {{.Amount}} to {{.Title}} has been refunded.\n\n" +
+					"Reason: {{.Reason}}\n\n" +
+					"Depending on how you paid, the money can take a few days to reach your account.",
+			},
+			"id": {
+				Subject: "Donasi SafeRelief Anda telah dikembalikan",
+				Body: "Donasi Anda sebesar {{.Amount}} untuk {{.Title}} telah dikembalikan.\n\n" +
+					"Alasan: {{.Reason}}\n\n" +
+					"Tergantung metode pembayaran, dana dapat memerlukan beberapa hari untuk masuk ke rekening Anda.",
+			},
+		},
+	},
+	"donation_subscription.payment_due": {
+		Key:         "donation_subscription.payment_due",
+		Description: "Payment link for a monthly donation that could not be charged without the donor",
+		Sample: map[string]interface{}{
+			"Amount":   100000.0,
+			"Currency": "IDR",
+			"Cycle":    3,
+			"Link":     "https://app.sandbox.midtrans.com/snap/v2/vtweb/sample",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your monthly SafeRelief donation is ready to pay",
+				Body: "Donation {{.Cycle}} of your monthly pledge, {{money .Currency .Amount}}, is ready.\n\n" +
+					"Pay it here: {{.Link}}\n\n" +
+					"You can pause or cancel the pledge in your SafeRelief account.",
+			},
+			"id": {
+				Subject: "Donasi bulanan SafeRelief Anda siap dibayar",
+				Body: "Donasi ke-{{.Cycle}} dari komitmen bulanan Anda, {{money .Currency .Amount}}, sudah siap.\n\n" +
+					"Bayar di sini: {{.Link}}\n\n" +
+					"Anda dapat menjeda atau membatalkan komitmen ini di akun SafeRelief Anda.",
+			},
+		},
+	},
+	"organization.invitation": {
+		Key:         "organization.invitation",
+		Description: "Invitation to join an organization, sent to an address with no account yet",
+		Sample: map[string]interface{}{
+			"OrganizationName": "Yayasan Peduli Bencana",
+			"Role":             "verifier",
+			"Link":             "https://saferelief.id/organizations/invitations?token=sample",
+			"ExpiresAt":        sampleTime,
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Join {{.OrganizationName}} on SafeRelief",
+				Body: "You've been invited to join {{.OrganizationName}} on SafeRelief as {{.Role}}.\n\n" +
+					"Create an account with this email address, verify it, then accept the invitation " +
+					"before {{datetime .ExpiresAt}}: {{.Link}}",
+			},
+			"id": {
+				Subject: "Bergabung dengan {{.OrganizationName}} di SafeRelief",
+				Body: "Anda diundang bergabung dengan {{.OrganizationName}} di SafeRelief sebagai {{.Role}}.\n\n" +
+					"Buat akun dengan alamat email ini, verifikasi, lalu terima undangan " +
+					"sebelum {{datetime .ExpiresAt}}: {{.Link}}",
+			},
+		},
+	},
+	"account.unlocked": {
+		Key:         "account.unlocked",
+		Description: "Sent when an administrator unlocks an account locked by failed sign-ins",
+		Sample:      map[string]interface{}{"Reason": "Verified by phone with the account owner"},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your SafeRelief account has been unlocked",
+				Body: "Your SafeRelief account was locked after several failed sign-in attempts. " +
+					"An administrator has unlocked it, so you can sign in again now.\n\n" +
+					"{{if .Reason}}Note from the administrator: {{.Reason}}\n\n{{end}}" +
+					"If the failed attempts weren't you, change your password after signing in " +
+					"and turn on two-factor authentication.",
+			},
+			"id": {
+				Subject: "Akun SafeRelief Anda telah dibuka",
+				Body: "Akun SafeRelief Anda terkunci setelah beberapa kali gagal masuk. " +
+					"Administrator telah membukanya, jadi Anda bisa masuk lagi sekarang.\n\n" +
+					"{{if .Reason}}Catatan dari administrator: {{.Reason}}\n\n{{end}}" +
+					"Jika percobaan yang gagal itu bukan Anda, ganti kata sandi setelah masuk " +
+					"dan aktifkan autentikasi dua faktor.",
+			},
+		},
+	},
+	"auth.magic_link": {
+		Key:         "auth.magic_link",
+		Description: "Single-use sign-in link requested from the login page",
+		Sample:      map[string]interface{}{"Link": "https://saferelief.example/login/magic?token=sample", "ExpiresIn": 15},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your SafeRelief sign-in link",
+				Body: "Use this link to sign in to SafeRelief:\n\n{{.Link}}\n\n" +
+					"It works once and expires in {{.ExpiresIn}} minutes. " +
+					"If you did not ask to sign in, you can ignore this email.",
+			},
+			"id": {
+				Subject: "Tautan masuk SafeRelief Anda",
+				Body: "Gunakan tautan ini untuk masuk ke SafeRelief:\n\n{{.Link}}\n\n" +
+					"Tautan hanya bisa dipakai sekali dan kedaluwarsa dalam {{.ExpiresIn}} menit. " +
+					"Jika Anda tidak meminta untuk masuk, abaikan email ini.",
+			},
+		},
+	},
+	"report.verified": {
+		Key:         "report.verified",
+		Description: "Sent to the reporter when an administrator verifies their disaster report",
+		Sample:      map[string]interface{}{"Title": "Flooding in Kampung Melayu", "Link": "https://saferelief.example/reports/sample"},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your report has been verified",
+				Body: "Your report \"{{.Title}}\" has been verified and is now visible to donors " +
+					"and responders.\n\n{{.Link}}\n\n" +
+					"You can turn these emails off in your notification settings.",
+			},
+			"id": {
+				Subject: "Laporan Anda telah diverifikasi",
+				Body: "Laporan Anda \"{{.Title}}\" telah diverifikasi dan kini terlihat oleh donatur " +
+					"dan relawan.\n\n{{.Link}}\n\n" +
+					"Anda bisa mematikan email ini di pengaturan notifikasi.",
+			},
+		},
+	},
+	"report.status_changed": {
+		Key:         "report.status_changed",
+		Description: "Sent to the followers of a disaster report when its status changes",
+		Sample: map[string]interface{}{
+			"Title": "Flooding in Kampung Melayu", "Status": "in_progress", "Note": "Evacuation boats deployed",
+			"Link": "https://saferelief.example/reports/sample",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Update on \"{{.Title}}\"",
+				Body: "The report \"{{.Title}}\" you follow " +
+					"{{if eq .Status \"verified\"}}has been verified" +
+					"{{else if eq .Status \"in_progress\"}}is now being responded to" +
+					"{{else if eq .Status \"resolved\"}}has been resolved" +
+					"{{else if eq .Status \"closed\"}}has been closed" +
+					"{{else if eq .Status \"rejected\"}}was rejected after review" +
+					"{{else}}is back under review{{end}}." +
+					"{{if .Note}}\n\n{{.Note}}{{end}}\n\n{{.Link}}\n\n" +
+					"You can unfollow the report, or turn these emails off in your notification settings.",
+			},
+			"id": {
+				Subject: "Kabar terbaru untuk \"{{.Title}}\"",
+				Body: "Laporan \"{{.Title}}\" yang Anda ikuti " +
+					"{{if eq .Status \"verified\"}}telah diverifikasi" +
+					"{{else if eq .Status \"in_progress\"}}sedang ditangani" +
+					"{{else if eq .Status \"resolved\"}}telah tertangani" +
+					"{{else if eq .Status \"closed\"}}telah ditutup" +
+					"{{else if eq .Status \"rejected\"}}ditolak setelah ditinjau" +
+					"{{else}}kembali ditinjau{{end}}." +
+					"{{if .Note}}\n\n{{.Note}}{{end}}\n\n{{.Link}}\n\n" +
+					"Anda bisa berhenti mengikuti laporan ini, atau mematikan email ini di pengaturan notifikasi.",
+			},
+		},
+	},
+	"report.auto_closed": {
+		Key:         "report.auto_closed",
+		Description: "Sent to the reporter when a pending or resolved disaster report is closed for inactivity",
+		Sample: map[string]interface{}{
+			"Title": "Flooding in Kampung Melayu", "PreviousStatus": "pending", "Days": 30,
+			"Link": "https://saferelief.example/reports/sample",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your report has been closed",
+				Body: "Your report \"{{.Title}}\" " +
+					"{{if eq .PreviousStatus \"pending\"}}could not be verified within {{.Days}} days and has been closed. " +
+					"If the situation is still ongoing, you can submit a new report." +
+					"{{else}}was resolved and has had no activity for {{.Days}} days, so it has been closed.{{end}}" +
+					"\n\n{{.Link}}\n\n" +
+					"You can turn these emails off in your notification settings.",
+			},
+			"id": {
+				Subject: "Laporan Anda telah ditutup",
+				Body: "Laporan Anda \"{{.Title}}\" " +
+					"{{if eq .PreviousStatus \"pending\"}}tidak dapat diverifikasi dalam {{.Days}} hari dan telah ditutup. " +
+					"Jika situasinya masih berlangsung, Anda bisa mengirim laporan baru." +
+					"{{else}}telah tertangani dan tidak ada aktivitas selama {{.Days}} hari, sehingga ditutup.{{end}}" +
+					"\n\n{{.Link}}\n\n" +
+					"Anda bisa mematikan email ini di pengaturan notifikasi.",
+			},
+		},
+	},
+	"donation.received": {
+		Key:         "donation.received",
+		Description: "Sent to the reporter when a donation to their report completes",
+		Sample: map[string]interface{}{
+			"Title": "Flooding in Kampung Melayu", "Currency": "IDR", "Total": "1500000.00",
+			"Link": "https://saferelief.example/reports/sample",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your report received a donation",
+				Body: "A donation to \"{{.Title}}\" has just completed. " +
+					"Donations in {{.Currency}} now total {{.Total}}.\n\n{{.Link}}\n\n" +
+					"You can turn these emails off in your notification settings.",
+			},
+			"id": {
+				Subject: "Laporan Anda menerima donasi",
+				Body: "Sebuah donasi untuk \"{{.Title}}\" baru saja selesai. " +
+					"Total donasi dalam {{.Currency}} kini {{.Total}}.\n\n{{.Link}}\n\n" +
+					"Anda bisa mematikan email ini di pengaturan notifikasi.",
+			},
+		},
+	},
+	"report.rejected": {
+		Key:         "report.rejected",
+		Description: "Sent to the reporter when a verifier rejects their disaster report",
+		Sample: map[string]interface{}{
+			"Title": "Flooding in Kampung Melayu", "Reason": "The location could not be confirmed",
+			"Note": "The photos show a different district.", "Link": "https://saferelief.example/reports/sample",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your report was not verified",
+				Body: "Your report \"{{.Title}}\" was reviewed and rejected. {{.Reason}}." +
+					"{{if .Note}}\n\nNote from the verifier: {{.Note}}{{end}}\n\n{{.Link}}\n\n" +
+					"If you have more information, you can submit a new report.",
+			},
+			"id": {
+				Subject: "Laporan Anda tidak diverifikasi",
+				Body: "Laporan Anda \"{{.Title}}\" telah ditinjau dan ditolak. Alasan: {{.Reason}}." +
+					"{{if .Note}}\n\nCatatan dari verifikator: {{.Note}}{{end}}\n\n{{.Link}}\n\n" +
+					"Jika Anda memiliki informasi tambahan, Anda bisa mengirim laporan baru.",
+			},
+		},
+	},
+	"data_export.ready": {
+		Key:         "data_export.ready",
+		Description: "Sent when a personal data export is ready to download",
+		Sample:      map[string]interface{}{"ExpiresAt": sampleTime},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your SafeRelief data export is ready",
+				Body: "Your SafeRelief data export is ready.\n\n" +
+					"Sign in and download it from /api/users/me/export before {{datetime .ExpiresAt}}.",
+			},
+			"id": {
+				Subject: "Ekspor data SafeRelief Anda sudah siap",
+				Body: "Ekspor data SafeRelief Anda sudah siap.\n\n" +
+					"Masuk dan unduh dari /api/users/me/export sebelum {{datetime .ExpiresAt}}.",
+			},
+		},
+	},
+	"volunteer.request": {
+		Key:         "volunteer.request",
+		Description: "Sent to a volunteer when a coordinator asks for their help",
+		Sample: map[string]interface{}{
+			"ReportTitle": "Banjir di Kampung Melayu",
+			"Message":     "We need two people with first aid training on Saturday.",
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Volunteer request: {{.ReportTitle}}",
+				Body: "You've been asked to volunteer for \"{{.ReportTitle}}\".\n\n{{.Message}}\n\n" +
+					"Sign in to SafeRelief to accept or decline.",
+			},
+			"id": {
+				Subject: "Permintaan relawan: {{.ReportTitle}}",
+				Body: "Anda diminta menjadi relawan untuk \"{{.ReportTitle}}\".\n\n{{.Message}}\n\n" +
+					"Masuk ke SafeRelief untuk menerima atau menolak.",
+			},
+		},
+	},
+	"digest.weekly": {
+		Key:         "digest.weekly",
+		Description: "Weekly digest of followed regions, funding progress and donation updates",
+		Sample: map[string]interface{}{
+			"Since": sampleTime.AddDate(0, 0, -7),
+			"Until": sampleTime,
+			"NewDisasters": []map[string]interface{}{
+				{"Title": "Banjir di Kampung Melayu", "Severity": "high", "Region": "Jakarta Timur"},
+			},
+			"FundingProgress": []map[string]interface{}{
+				{"Title": "Gempa Cianjur", "Currency": "IDR", "TotalRaised": 12500000.0, "RaisedPeriod": 2000000.0},
+			},
+			"DeliveryUpdates": []map[string]interface{}{
+				{"Title": "Gempa Cianjur", "Currency": "IDR", "Amount": 250000.0, "Status": "completed"},
+			},
+		},
+		Defaults: map[string]Content{
+			"en": {
+				Subject: "Your SafeRelief weekly digest",
+				Body: `Your SafeRelief weekly digest
+{{date .Since}} – {{date .Until}}
+{{if .NewDisasters}}
+New verified disasters in regions you follow:
+{{range .NewDisasters}}- [{{upper .Severity}}] {{.Title}} ({{.Region}})
+{{end}}{{end}}{{if .FundingProgress}}
+Funding progress on reports you supported:
+{{range .FundingProgress}}- {{.Title}}: {{money .Currency .TotalRaised}} raised, +{{money .Currency .RaisedPeriod}} this week
+{{end}}{{end}}{{if .DeliveryUpdates}}
+Updates on your donations:
+{{range .DeliveryUpdates}}- {{money .Currency .Amount}} to {{.Title}}: {{.Status}}
+{{end}}{{end}}
+Change your digest preferences in your SafeRelief account settings.
+`,
+			},
+			"id": {
+				Subject: "Ringkasan mingguan SafeRelief",
+				Body: `Ringkasan mingguan SafeRelief
+{{date .Since}} – {{date .Until}}
+{{if .NewDisasters}}
+Bencana terverifikasi baru di wilayah yang Anda ikuti:
+{{range .NewDisasters}}- [{{upper .Severity}}] {{.Title}} ({{.Region}})
+{{end}}{{end}}{{if .FundingProgress}}
+Perkembangan penggalangan dana untuk laporan yang Anda dukung:
+{{range .FundingProgress}}- {{.Title}}: {{money .Currency .TotalRaised}} terkumpul, +{{money .Currency .RaisedPeriod}} minggu ini
+{{end}}{{end}}{{if .DeliveryUpdates}}
+Pembaruan status donasi Anda:
+{{range .DeliveryUpdates}}- {{money .Currency .Amount}} untuk {{.Title}}: {{.Status}}
+{{end}}{{end}}
+Ubah preferensi ringkasan di pengaturan akun SafeRelief Anda.
+`,
+			},
+		},
+	},
+}
@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+var ErrDeliveryNotFound = errors.New("failed email delivery not found")
+
+// UseDeliveryLog records every templated email in notification_deliveries so
+// failed sends can be inspected and retried rather than only logged
+func (m *Mailer) UseDeliveryLog(db *sql.DB) {
+	m.db = db
+}
+
+// deliver sends and logs a templated email. Attachments are not logged, so
+// a retried delivery goes out without them.
+func (m *Mailer) deliver(ctx context.Context, key, to, subject, body string, attachments []Attachment) error {
+	if m.db == nil {
+		return m.send(to, subject, body, attachments)
+	}
+
+	var deliveryID string
+	err := m.db.QueryRowContext(ctx,
+		`INSERT INTO notification_deliveries (id, channel, template_key, recipient, subject, body)
+		VALUES (UUID_TO_BIN(UUID()), 'email', ?, ?, ?, ?)
+		RETURNING BIN_TO_UUID(id)`,
+		key, to, subject, body,
+	).Scan(&deliveryID)
+	if err != nil {
+		log.Printf("Failed to record email delivery %s: %v", key, err)
+		return m.send(to, subject, body, attachments)
+	}
+
+	sendErr := m.send(to, subject, body, attachments)
+	m.record(deliveryID, sendErr)
+	return sendErr
+}
+
+// Retry sends a failed email again with the content rendered originally
+func (m *Mailer) Retry(ctx context.Context, deliveryID string) error {
+	if m.db == nil {
+		return ErrDeliveryNotFound
+	}
+
+	var to, subject, body string
+	err := m.db.QueryRowContext(ctx,
+		`SELECT recipient, subject, body FROM notification_deliveries
+		WHERE id = UUID_TO_BIN(?) AND status = 'failed' AND body IS NOT NULL`,
+		deliveryID,
+	).Scan(&to, &subject, &body)
+	if err == sql.ErrNoRows {
+		return ErrDeliveryNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	sendErr := m.Send(to, subject, body)
+	m.record(deliveryID, sendErr)
+	return sendErr
+}
+
+// record stores the outcome of one attempt. Sent messages drop their body
+// because it may carry single-use links that must not outlive the send.
+func (m *Mailer) record(deliveryID string, sendErr error) {
+	var err error
+	if sendErr == nil {
+		_, err = m.db.Exec(
+			`UPDATE notification_deliveries
+			SET status = 'sent', attempts = attempts + 1, body = NULL, last_error = NULL,
+			last_attempt_at = NOW(), sent_at = NOW()
+			WHERE id = UUID_TO_BIN(?)`,
+			deliveryID,
+		)
+	} else {
+		lastError := sendErr.Error()
+		if len(lastError) > 500 {
+			lastError = lastError[:500]
+		}
+		_, err = m.db.Exec(
+			`UPDATE notification_deliveries
+			SET status = 'failed', attempts = attempts + 1, last_error = ?, last_attempt_at = NOW()
+			WHERE id = UUID_TO_BIN(?)`,
+			lastError, deliveryID,
+		)
+	}
+	if err != nil {
+		log.Printf("Failed to record email delivery %s: %v", deliveryID, err)
+	}
+}
@@ -0,0 +1,47 @@
+package notify
+
+import "time"
+
+// Defaults for users who have not chosen a locale or timezone
+const (
+	DefaultLocale   = "id"
+	DefaultTimezone = "Asia/Jakarta"
+)
+
+var supportedLocales = map[string]bool{
+	"id": true,
+	"en": true,
+}
+
+func ValidLocale(locale string) bool {
+	return supportedLocales[locale]
+}
+
+// ValidTimezone accepts IANA zone names such as Asia/Makassar
+func ValidTimezone(timezone string) bool {
+	if timezone == "" || timezone == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(timezone)
+	return err == nil
+}
+
+// FormatTime renders t for people rather than clients: in the recipient's
+// timezone with the UTC offset spelled out, falling back to UTC. API
+// responses keep using UTC timestamps.
+func FormatTime(t time.Time, timezone string) string {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format("2 January 2006 15:04 MST (UTC-07:00)")
+}
+
+// UTCOffset returns the zone's current offset from UTC, e.g. "+07:00"
+func UTCOffset(timezone string) string {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return time.Now().In(loc).Format("-07:00")
+}
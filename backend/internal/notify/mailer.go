@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// Mailer sends plain-text email over SMTP. Without SMTP_HOST configured it
+// logs messages instead, which keeps local development self-contained.
+type Mailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+
+	templates *Templates
+	db        *sql.DB
+}
+
+// Recipient is who a templated notification goes to and how to localize it
+type Recipient struct {
+	Email    string
+	Locale   string
+	Timezone string
+}
+
+func NewMailerFromEnv() *Mailer {
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@saferelief.id"
+	}
+
+	return &Mailer{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     port,
+		username: os.Getenv("SMTP_USER"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     from,
+	}
+}
+
+// UseTemplates enables SendTemplate with content from the template store
+func (m *Mailer) UseTemplates(templates *Templates) {
+	m.templates = templates
+}
+
+// Attachment is a file sent along with an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SendTemplate renders the template in the recipient's locale and sends it
+// with any attachments
+func (m *Mailer) SendTemplate(ctx context.Context, to Recipient, key string, data interface{}, attachments ...Attachment) error {
+	if m.templates == nil {
+		return errors.New("notification templates are not configured")
+	}
+	subject, body, err := m.templates.Render(ctx, key, to.Locale, to.Timezone, data)
+	if err != nil {
+		return err
+	}
+	return m.deliver(ctx, key, to.Email, subject, body, attachments)
+}
+
+func (m *Mailer) Send(to, subject, body string) error {
+	return m.send(to, subject, body, nil)
+}
+
+func (m *Mailer) send(to, subject, body string, attachments []Attachment) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("invalid header value")
+	}
+	for _, a := range attachments {
+		if strings.ContainsAny(a.Filename+a.ContentType, "\r\n\"") {
+			return fmt.Errorf("invalid attachment header value")
+		}
+	}
+	if m.db != nil && m.isSuppressed(to) {
+		return ErrSuppressed
+	}
+
+	if m.host == "" {
+		log.Printf("Email to %s: %s\n%s", to, subject, body)
+		for _, a := range attachments {
+			log.Printf("Attachment %s (%s, %d bytes)", a.Filename, a.ContentType, len(a.Data))
+		}
+		return nil
+	}
+
+	msg := "From: " + m.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n"
+	if len(attachments) == 0 {
+		msg += "Content-Type: text/plain; charset=UTF-8\r\n" +
+			"\r\n" + body
+	} else {
+		msg += multipartBody(body, attachments)
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.host+":"+m.port, auth, m.from, []string{to}, []byte(msg))
+}
+
+// multipartBody is the Content-Type header and body of a multipart/mixed
+// message: the text first, then each attachment in base64
+func multipartBody(body string, attachments []Attachment) string {
+	var b strings.Builder
+	w := multipart.NewWriter(&b)
+	for _, part := range append([]Attachment{{ContentType: "text/plain; charset=UTF-8", Data: []byte(body)}}, attachments...) {
+		header := textproto.MIMEHeader{"Content-Type": {part.ContentType}}
+		if part.Filename == "" {
+			header.Set("Content-Transfer-Encoding", "8bit")
+			pw, _ := w.CreatePart(header)
+			pw.Write(part.Data)
+			continue
+		}
+		header.Set("Content-Transfer-Encoding", "base64")
+		header.Set("Content-Disposition", `attachment; filename="`+part.Filename+`"`)
+		pw, _ := w.CreatePart(header)
+		encoded := base64.StdEncoding.EncodeToString(part.Data)
+		for len(encoded) > 76 {
+			pw.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		pw.Write([]byte(encoded + "\r\n"))
+	}
+	w.Close()
+	return "Content-Type: multipart/mixed; boundary=" + w.Boundary() + "\r\n\r\n" + b.String()
+}
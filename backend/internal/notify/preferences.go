@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Notification event types users can opt in or out of
+const (
+	EventReportVerified   = "report_verified"
+	EventReportRejected   = "report_rejected"
+	EventReportUpdate     = "report_update"
+	EventDonationReceived = "donation_received"
+	EventSecurityAlert    = "security_alert"
+)
+
+// Channels a notification can be delivered on
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+)
+
+var (
+	Events   = []string{EventReportVerified, EventReportRejected, EventReportUpdate, EventDonationReceived, EventSecurityAlert}
+	Channels = []string{ChannelEmail, ChannelSMS, ChannelPush}
+)
+
+// ErrRequiredChannel is returned when a preference would turn off security
+// alerts by email, the one channel every account must keep
+var ErrRequiredChannel = errors.New("security alerts by email cannot be turned off")
+
+// Preferences maps event type to channel to whether it is enabled
+type Preferences map[string]map[string]bool
+
+// DefaultPreferences apply until a user changes them: email and push on,
+// SMS off because it costs money per message
+func DefaultPreferences() Preferences {
+	prefs := Preferences{}
+	for _, event := range Events {
+		prefs[event] = map[string]bool{ChannelEmail: true, ChannelSMS: false, ChannelPush: true}
+	}
+	return prefs
+}
+
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidChannel(channel string) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadPreferences returns the user's stored choices on top of the defaults
+func LoadPreferences(ctx context.Context, db *sql.DB, userID string) (Preferences, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT event_type, channel, enabled FROM notification_preferences WHERE user_id = UUID_TO_BIN(?)",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := DefaultPreferences()
+	for rows.Next() {
+		var event, channel string
+		var enabled bool
+		if err := rows.Scan(&event, &channel, &enabled); err != nil {
+			return nil, err
+		}
+		if channels, ok := prefs[event]; ok {
+			channels[channel] = enabled
+		}
+	}
+	return prefs, rows.Err()
+}
+
+// SavePreferences stores the given choices; events and channels not
+// mentioned are left as they are
+func SavePreferences(ctx context.Context, db *sql.DB, userID string, changes Preferences) error {
+	if enabled, ok := changes[EventSecurityAlert][ChannelEmail]; ok && !enabled {
+		return ErrRequiredChannel
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for event, channels := range changes {
+		for channel, enabled := range channels {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO notification_preferences (user_id, event_type, channel, enabled)
+				VALUES (UUID_TO_BIN(?), ?, ?, ?)
+				ON DUPLICATE KEY UPDATE enabled = VALUES(enabled), updated_at = NOW()`,
+				userID, event, channel, enabled,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Allows reports whether the user wants event on channel. Lookup failures
+// fall back to the default so a database hiccup does not drop security mail.
+func Allows(ctx context.Context, db *sql.DB, userID, event, channel string) bool {
+	if event == EventSecurityAlert && channel == ChannelEmail {
+		return true
+	}
+	var enabled bool
+	err := db.QueryRowContext(ctx,
+		`SELECT enabled FROM notification_preferences
+		WHERE user_id = UUID_TO_BIN(?) AND event_type = ? AND channel = ?`,
+		userID, event, channel,
+	).Scan(&enabled)
+	if err != nil {
+		return DefaultPreferences()[event][channel]
+	}
+	return enabled
+}
+
+// Notify sends a templated email about event to a user unless they turned
+// off email for it
+func (m *Mailer) Notify(ctx context.Context, db *sql.DB, userID, event, key string, data interface{}) error {
+	if !Allows(ctx, db, userID, event, ChannelEmail) {
+		return nil
+	}
+	to, err := LookupRecipient(ctx, db, userID)
+	if err != nil {
+		return err
+	}
+	return m.SendTemplate(ctx, to, key, data)
+}
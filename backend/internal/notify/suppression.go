@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+)
+
+var ErrSuppressed = errors.New("address is on the suppression list")
+
+// isSuppressed fails open: a lookup error should not stop mail
+func (m *Mailer) isSuppressed(to string) bool {
+	var exists bool
+	err := m.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM email_suppressions WHERE email = ?)",
+		strings.ToLower(to),
+	).Scan(&exists)
+	if err != nil {
+		log.Printf("Failed to check email suppression list: %v", err)
+		return false
+	}
+	return exists
+}
+
+// Suppress stops all mail to an address. A hard bounce also marks the
+// account using the address as undeliverable and unverified. It returns the
+// affected account's ID, or "" when no account uses the address.
+func Suppress(ctx context.Context, db *sql.DB, email, reason, source, detail string) (string, error) {
+	email = strings.ToLower(email)
+	if len(detail) > 500 {
+		detail = detail[:500]
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO email_suppressions (email, reason, source, detail) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE reason = VALUES(reason), source = VALUES(source),
+		detail = VALUES(detail), updated_at = NOW()`,
+		email, reason, source, detail,
+	); err != nil {
+		return "", err
+	}
+
+	var userID string
+	err = tx.QueryRowContext(ctx,
+		"SELECT BIN_TO_UUID(id) FROM users WHERE email = ?", email,
+	).Scan(&userID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	if userID != "" && reason == "hard_bounce" {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET email_bounced_at = NOW(), email_verified_at = NULL
+			WHERE id = UUID_TO_BIN(?)`,
+			userID,
+		); err != nil {
+			return "", err
+		}
+	}
+
+	return userID, tx.Commit()
+}
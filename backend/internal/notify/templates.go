@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var ErrUnknownTemplate = errors.New("unknown notification template")
+
+// Content is the subject and body of one template in one locale, both
+// written in Go text/template syntax
+type Content struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Definition describes a notification the application sends, with the
+// built-in wording used until an admin saves a version, and sample data for
+// previews
+type Definition struct {
+	Key         string                 `json:"key"`
+	Description string                 `json:"description"`
+	Sample      map[string]interface{} `json:"sample"`
+	Defaults    map[string]Content     `json:"-"`
+}
+
+type TemplateVersion struct {
+	Key       string    `json:"key"`
+	Locale    string    `json:"locale"`
+	Version   int       `json:"version"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	CreatedBy *string   `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Templates stores versioned notification content in the database; the
+// newest version of a key and locale is the one sent
+type Templates struct {
+	db *sql.DB
+}
+
+func NewTemplates(db *sql.DB) *Templates {
+	return &Templates{db: db}
+}
+
+func LookupDefinition(key string) (Definition, bool) {
+	d, ok := definitions[key]
+	return d, ok
+}
+
+func Definitions() []Definition {
+	list := make([]Definition, 0, len(definitions))
+	for _, d := range definitions {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// Locales lists the supported locales in a stable order
+func Locales() []string {
+	locales := make([]string, 0, len(supportedLocales))
+	for locale := range supportedLocales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Current returns the content that would be sent for key in locale. Version
+// 0 means the built-in default is in use.
+func (t *Templates) Current(ctx context.Context, key, locale string) (TemplateVersion, error) {
+	def, ok := definitions[key]
+	if !ok {
+		return TemplateVersion{}, ErrUnknownTemplate
+	}
+
+	v := TemplateVersion{Key: key, Locale: locale}
+	err := t.db.QueryRowContext(ctx,
+		`SELECT version, subject, body, BIN_TO_UUID(created_by), created_at
+		FROM notification_templates
+		WHERE template_key = ? AND locale = ?
+		ORDER BY version DESC LIMIT 1`,
+		key, locale,
+	).Scan(&v.Version, &v.Subject, &v.Body, &v.CreatedBy, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		content := def.Defaults[locale]
+		v.Subject, v.Body = content.Subject, content.Body
+		return v, nil
+	}
+	return v, err
+}
+
+func (t *Templates) History(ctx context.Context, key, locale string) ([]TemplateVersion, error) {
+	if _, ok := definitions[key]; !ok {
+		return nil, ErrUnknownTemplate
+	}
+	rows, err := t.db.QueryContext(ctx,
+		`SELECT version, subject, body, BIN_TO_UUID(created_by), created_at
+		FROM notification_templates
+		WHERE template_key = ? AND locale = ?
+		ORDER BY version DESC`,
+		key, locale,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []TemplateVersion{}
+	for rows.Next() {
+		v := TemplateVersion{Key: key, Locale: locale}
+		if err := rows.Scan(&v.Version, &v.Subject, &v.Body, &v.CreatedBy, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// Save validates the content against the template's sample data and stores
+// it as the next version
+func (t *Templates) Save(ctx context.Context, key, locale string, content Content, adminID string) (int, error) {
+	if _, err := Preview(key, locale, content); err != nil {
+		return 0, err
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) + 1 FROM notification_templates
+		WHERE template_key = ? AND locale = ? FOR UPDATE`,
+		key, locale,
+	).Scan(&version); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO notification_templates (template_key, locale, version, subject, body, created_by)
+		VALUES (?, ?, ?, ?, ?, UUID_TO_BIN(?))`,
+		key, locale, version, content.Subject, content.Body, adminID,
+	); err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// Render fills in the current template for the recipient's locale, falling
+// back to the default locale for unsupported ones. Dates are shown in the
+// recipient's timezone.
+func (t *Templates) Render(ctx context.Context, key, locale, timezone string, data interface{}) (string, string, error) {
+	if !ValidLocale(locale) {
+		locale = DefaultLocale
+	}
+	current, err := t.Current(ctx, key, locale)
+	if err != nil {
+		return "", "", err
+	}
+	return render(Content{Subject: current.Subject, Body: current.Body}, timezone, data)
+}
+
+// Preview renders content with the template's sample data, reporting syntax
+// and field errors
+func Preview(key, locale string, content Content) (Content, error) {
+	def, ok := definitions[key]
+	if !ok {
+		return Content{}, ErrUnknownTemplate
+	}
+	if !ValidLocale(locale) {
+		return Content{}, fmt.Errorf("unsupported locale %q", locale)
+	}
+	subject, body, err := render(content, DefaultTimezone, def.Sample)
+	if err != nil {
+		return Content{}, err
+	}
+	return Content{Subject: subject, Body: body}, nil
+}
+
+func render(content Content, timezone string, data interface{}) (string, string, error) {
+	funcs := template.FuncMap{
+		"upper": strings.ToUpper,
+		"money": func(currency string, amount float64) string {
+			return fmt.Sprintf("%s %.2f", currency, amount)
+		},
+		"date": func(t time.Time) string {
+			loc, err := time.LoadLocation(timezone)
+			if err != nil {
+				loc = time.UTC
+			}
+			return t.In(loc).Format("2 Jan 2006")
+		},
+		"datetime": func(t time.Time) string {
+			return FormatTime(t, timezone)
+		},
+	}
+
+	var out [2]strings.Builder
+	for i, text := range []string{content.Subject, content.Body} {
+		tmpl, err := template.New("").Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return "", "", err
+		}
+		if err := tmpl.Execute(&out[i], data); err != nil {
+			return "", "", err
+		}
+	}
+	// Subjects become mail headers and must stay on one line
+	subject := strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(out[0].String()))
+	return subject, out[1].String(), nil
+}
+
+// LookupRecipient loads a user's address, locale and timezone
+func LookupRecipient(ctx context.Context, db *sql.DB, userID string) (Recipient, error) {
+	var to Recipient
+	err := db.QueryRowContext(ctx,
+		"SELECT email, locale, timezone FROM users WHERE id = UUID_TO_BIN(?)",
+		userID,
+	).Scan(&to.Email, &to.Locale, &to.Timezone)
+	return to, err
+}
@@ -0,0 +1,267 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"saferelief/internal/apierror"
+
+	"github.com/gorilla/mux"
+)
+
+// Security says how a caller authenticates for an operation
+type Security string
+
+const (
+	Public  Security = ""
+	Session Security = "session" // session cookie, or an API key as a bearer token
+	// SessionOnly operations refuse API keys
+	SessionOnly Security = "sessionOnly"
+	APIKey      Security = "apiKey"
+	Signature   Security = "signature" // callbacks signed by an external provider
+)
+
+// Operation describes one registered route. The table is maintained by hand
+// next to the routes and Check keeps the two from drifting apart.
+type Operation struct {
+	Method     string
+	Path       string // mux template, e.g. /api/reports/{id}
+	Tag        string
+	Summary    string
+	Query      []string
+	Security   Security
+	Permission string
+	Multipart  bool
+}
+
+var pathVariable = regexp.MustCompile(`\{(\w+)(?::[^}]*)?\}`)
+
+// Document builds an OpenAPI 3 document for the operations
+func Document(title, version string, operations []Operation) map[string]interface{} {
+	paths := make(map[string]map[string]interface{})
+	tagSet := make(map[string]bool)
+
+	for _, op := range operations {
+		path := pathVariable.ReplaceAllString(op.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		tagSet[op.Tag] = true
+
+		parameters := []map[string]interface{}{}
+		for _, match := range pathVariable.FindAllStringSubmatch(op.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true,
+				"schema": map[string]string{"type": "string"},
+			})
+		}
+		for _, name := range op.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "query",
+				"schema": map[string]string{"type": "string"},
+			})
+		}
+
+		description := op.Summary
+		if op.Permission != "" {
+			description += "\n\nRequires the `" + op.Permission + "` permission."
+		}
+		operation := map[string]interface{}{
+			"operationId": operationID(op),
+			"tags":        []string{op.Tag},
+			"summary":     summarize(op.Summary),
+			"description": description,
+			"parameters":  parameters,
+			"responses": map[string]interface{}{
+				"200": map[string]string{"description": "Success"},
+				"default": map[string]interface{}{
+					"description": "Error; code is one of the catalog at /api/errors",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]string{"$ref": "#/components/schemas/Error"},
+						},
+					},
+				},
+			},
+		}
+
+		switch op.Security {
+		case Public, Signature:
+			operation["security"] = []interface{}{}
+		case Session:
+			operation["security"] = []map[string][]string{{"sessionCookie": {}}, {"bearerKey": {}}}
+		case SessionOnly:
+			operation["security"] = []map[string][]string{{"sessionCookie": {}}}
+		case APIKey:
+			operation["security"] = []map[string][]string{{"apiKey": {}}}
+		}
+		if op.Security == Signature {
+			operation["description"] = description + "\n\nAuthenticated by the provider's own signature."
+		}
+
+		if op.Method == http.MethodPost || op.Method == http.MethodPut || op.Method == http.MethodPatch {
+			contentType := "application/json"
+			if op.Multipart {
+				contentType = "multipart/form-data"
+			}
+			operation["requestBody"] = map[string]interface{}{
+				"content": map[string]interface{}{
+					contentType: map[string]interface{}{"schema": map[string]string{"type": "object"}},
+				},
+			}
+		}
+
+		paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	tags := []map[string]string{}
+	for tag := range tagSet {
+		tags = append(tags, map[string]string{"name": tag})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i]["name"] < tags[j]["name"] })
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": title, "version": version},
+		"tags":    tags,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"code", "message"},
+					"properties": map[string]interface{}{
+						"code":      map[string]string{"type": "string"},
+						"message":   map[string]string{"type": "string"},
+						"details":   map[string]string{"description": "Code-specific context, e.g. the failing fields for validation_failed"},
+						"requestId": map[string]string{"type": "string"},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"sessionCookie": map[string]string{
+					"type": "apiKey", "in": "cookie", "name": "access_token",
+					"description": "Set by login; state-changing requests also need the X-CSRF-Token header matching the CSRF-Token cookie",
+				},
+				"apiKey": map[string]string{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearerKey": map[string]string{
+					"type": "http", "scheme": "bearer",
+					"description": "An API key with the read scope (GET) or write scope (other methods), acting as its owner",
+				},
+			},
+		},
+	}
+}
+
+// Check compares the routes registered on router with the operations and
+// describes every route missing from either side
+func Check(router *mux.Router, operations []Operation) error {
+	documented := make(map[string]bool)
+	for _, op := range operations {
+		documented[op.Method+" "+op.Path] = true
+	}
+
+	var undocumented []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// Subrouter prefixes have no methods of their own
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			key := method + " " + path
+			if documented[key] {
+				delete(documented, key)
+				continue
+			}
+			undocumented = append(undocumented, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for key := range documented {
+		stale = append(stale, key)
+	}
+	if len(undocumented) == 0 && len(stale) == 0 {
+		return nil
+	}
+	sort.Strings(undocumented)
+	sort.Strings(stale)
+	return fmt.Errorf("OpenAPI spec out of sync with routes: undocumented %v, not registered %v", undocumented, stale)
+}
+
+// SpecHandler serves the document as JSON, encoded once up front
+func SpecHandler(document map[string]interface{}) http.HandlerFunc {
+	encoded, err := json.Marshal(document)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			apierror.Error(w, "Error encoding API specification", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(encoded)
+	}
+}
+
+// DocsHandler serves a Redoc page rendering the spec at specURL
+func DocsHandler(specURL string) http.HandlerFunc {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+<title>SafeRelief API</title>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+<redoc spec-url="` + specURL + `"></redoc>
+<script src="https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"></script>
+</body>
+</html>`
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Redoc needs its bundle, inline styles and a blob worker; nothing else is allowed
+		w.Header().Set("Content-Security-Policy",
+			"default-src 'self'; script-src https://cdn.redoc.ly; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; "+
+				"font-src https://fonts.gstatic.com; img-src 'self' data: https:; worker-src blob:")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}
+}
+
+func operationID(op Operation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(strings.TrimPrefix(op.Path, "/api"), func(r rune) bool {
+		return r == '/' || r == '-' || r == '.'
+	}) {
+		if match := pathVariable.FindStringSubmatch(part); match != nil {
+			part = "By" + strings.ToUpper(match[1][:1]) + match[1][1:]
+		} else {
+			part = strings.ToUpper(part[:1]) + part[1:]
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// summarize keeps the first clause of a summary; the full text stays in the description
+func summarize(s string) string {
+	for _, sep := range []string{"; ", " (", ". "} {
+		if i := strings.Index(s, sep); i > 0 {
+			s = s[:i]
+		}
+	}
+	return s
+}
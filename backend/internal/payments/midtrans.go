@@ -0,0 +1,293 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"saferelief/internal/inbound"
+)
+
+const (
+	midtransSandboxURL    = "https://app.sandbox.midtrans.com/snap/v1/transactions"
+	midtransProductionURL = "https://app.midtrans.com/snap/v1/transactions"
+
+	midtransSandboxChargeURL    = "https://api.sandbox.midtrans.com/v2/charge"
+	midtransProductionChargeURL = "https://api.midtrans.com/v2/charge"
+)
+
+// Midtrans takes payments through Snap, which offers GoPay, QRIS, bank
+// virtual accounts and cards on one hosted page. Set the dashboard's payment
+// notification URL to /api/webhooks/midtrans. Cards saved at checkout are
+// charged again through the Core API, which needs one-click or recurring
+// card payments enabled on the merchant account.
+type Midtrans struct {
+	serverKey      string
+	endpoint       string
+	chargeEndpoint string
+	client         *http.Client
+}
+
+// NewMidtransFromEnv returns nil when MIDTRANS_SERVER_KEY is unset. The
+// sandbox is used unless MIDTRANS_PRODUCTION is true.
+func NewMidtransFromEnv() *Midtrans {
+	serverKey := os.Getenv("MIDTRANS_SERVER_KEY")
+	if serverKey == "" {
+		return nil
+	}
+	endpoint, chargeEndpoint := midtransSandboxURL, midtransSandboxChargeURL
+	if production, _ := strconv.ParseBool(os.Getenv("MIDTRANS_PRODUCTION")); production {
+		endpoint, chargeEndpoint = midtransProductionURL, midtransProductionChargeURL
+	}
+	return &Midtrans{
+		serverKey:      serverKey,
+		endpoint:       endpoint,
+		chargeEndpoint: chargeEndpoint,
+		client:         &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (m *Midtrans) Name() string {
+	return "midtrans"
+}
+
+// Validate allows whole rupiah amounts only; Midtrans charges in IDR and
+// rejects fractional gross amounts
+func (m *Midtrans) Validate(c Charge) string {
+	if c.Currency != "IDR" {
+		return "Midtrans only accepts IDR"
+	}
+	if c.Amount != math.Trunc(c.Amount) {
+		return "Midtrans needs a whole rupiah amount"
+	}
+	return ""
+}
+
+// Checkout creates a Snap transaction; the token opens Snap.js and the
+// redirect URL the hosted payment page
+func (m *Midtrans) Checkout(ctx context.Context, c Charge) (Checkout, error) {
+	request := map[string]interface{}{
+		"transaction_details": map[string]interface{}{
+			"order_id":     c.OrderID,
+			"gross_amount": int64(c.Amount),
+		},
+		"item_details": []map[string]interface{}{{
+			"id":       "donation",
+			"price":    int64(c.Amount),
+			"quantity": 1,
+			"name":     truncateRunes(firstNonEmpty(c.Description, "SafeRelief donation"), 50),
+		}},
+	}
+	if c.Email != "" {
+		request["customer_details"] = map[string]string{"email": c.Email}
+	}
+	if c.SaveMethod && c.CustomerID != "" {
+		// Snap offers to save the card; other methods pay once
+		request["user_id"] = c.CustomerID
+		request["credit_card"] = map[string]interface{}{"save_card": true}
+	}
+	body, _ := json.Marshal(request)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Checkout{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(m.serverKey, "")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return Checkout{}, fmt.Errorf("%w: %v", ErrGateway, err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Token         string   `json:"token"`
+		RedirectURL   string   `json:"redirect_url"`
+		ErrorMessages []string `json:"error_messages"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+	if resp.StatusCode != http.StatusCreated || result.Token == "" {
+		return Checkout{}, fmt.Errorf("%w: Midtrans responded with %d: %s", ErrGateway, resp.StatusCode,
+			strings.Join(result.ErrorMessages, "; "))
+	}
+	return Checkout{Token: result.Token, RedirectURL: result.RedirectURL}, nil
+}
+
+// midtransNotification uses Midtrans' field names; amounts are strings
+type midtransNotification struct {
+	OrderID           string `json:"order_id"`
+	StatusCode        string `json:"status_code"`
+	GrossAmount       string `json:"gross_amount"`
+	SignatureKey      string `json:"signature_key"`
+	TransactionID     string `json:"transaction_id"`
+	TransactionStatus string `json:"transaction_status"`
+	FraudStatus       string `json:"fraud_status"`
+	PaymentType       string `json:"payment_type"`
+	SavedTokenID      string `json:"saved_token_id"`
+	// RefundAmount is the total refunded so far, on refund notifications
+	RefundAmount string `json:"refund_amount"`
+}
+
+// VerifyNotification checks signature_key, the SHA-512 of order_id,
+// status_code, gross_amount and the server key. Each status of a
+// transaction is a separate event, so a later settlement is not mistaken
+// for a redelivered pending notice.
+func (m *Midtrans) VerifyNotification(r *http.Request, body []byte) (string, error) {
+	var n midtransNotification
+	if err := json.Unmarshal(body, &n); err != nil || n.OrderID == "" || n.TransactionID == "" {
+		return "", inbound.ErrInvalidPayload
+	}
+	sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + m.serverKey))
+	expected := hex.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(n.SignatureKey))) != 1 {
+		return "", inbound.ErrInvalidSignature
+	}
+	return n.TransactionID + ":" + n.TransactionStatus, nil
+}
+
+func (m *Midtrans) ParseNotification(body []byte) (Notification, error) {
+	var n midtransNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return Notification{}, err
+	}
+	return n.notification()
+}
+
+// ChargeSaved charges a card saved at an earlier Snap checkout. The result
+// has the same shape as a notification; a declined card comes back as a
+// failed payment rather than an error.
+func (m *Midtrans) ChargeSaved(ctx context.Context, c Charge, savedToken string) (Notification, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"payment_type": "credit_card",
+		"transaction_details": map[string]interface{}{
+			"order_id":     c.OrderID,
+			"gross_amount": int64(c.Amount),
+		},
+		"credit_card": map[string]interface{}{"token_id": savedToken},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.chargeEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return Notification{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(m.serverKey, "")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return Notification{}, fmt.Errorf("%w: %v", ErrGateway, err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		midtransNotification
+		StatusMessage string `json:"status_message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+	if result.TransactionID == "" || !strings.HasPrefix(result.StatusCode, "2") {
+		return Notification{}, fmt.Errorf("%w: Midtrans responded with %s: %s", ErrGateway,
+			firstNonEmpty(result.StatusCode, strconv.Itoa(resp.StatusCode)), result.StatusMessage)
+	}
+	return result.midtransNotification.notification()
+}
+
+// Refund returns a settled payment in full through the Core API. Midtrans
+// refunds cards, GoPay, ShopeePay and QRIS; bank transfers are refused.
+func (m *Midtrans) Refund(ctx context.Context, r RefundRequest) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"refund_key": r.OrderID + "-refund",
+		"amount":     int64(r.Amount),
+		"reason":     truncateRunes(firstNonEmpty(r.Reason, "SafeRelief donation refund"), 255),
+	})
+	endpoint := strings.TrimSuffix(m.chargeEndpoint, "/charge") + "/" + url.PathEscape(r.OrderID) + "/refund"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(m.serverKey, "")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrGateway, err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		StatusCode    string `json:"status_code"`
+		StatusMessage string `json:"status_message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+	if !strings.HasPrefix(result.StatusCode, "2") {
+		return fmt.Errorf("%w: Midtrans responded with %s: %s", ErrGateway,
+			firstNonEmpty(result.StatusCode, strconv.Itoa(resp.StatusCode)), result.StatusMessage)
+	}
+	return nil
+}
+
+func (n midtransNotification) notification() (Notification, error) {
+	amount, err := strconv.ParseFloat(n.GrossAmount, 64)
+	if err != nil {
+		return Notification{}, fmt.Errorf("invalid gross_amount %q", n.GrossAmount)
+	}
+
+	status := StatusPending
+	switch n.TransactionStatus {
+	case "settlement":
+		status = StatusCompleted
+	case "capture":
+		// Card payments held for fraud review stay pending until accepted
+		if n.FraudStatus == "" || n.FraudStatus == "accept" {
+			status = StatusCompleted
+		}
+	case "deny", "cancel", "expire", "failure":
+		status = StatusFailed
+	case "refund", "partial_refund":
+		// Refunds carry the amount returned, so a partial one is not taken
+		// for the whole donation
+		status = StatusRefunded
+		if n.RefundAmount != "" {
+			amount, err = strconv.ParseFloat(n.RefundAmount, 64)
+			if err != nil {
+				return Notification{}, fmt.Errorf("invalid refund_amount %q", n.RefundAmount)
+			}
+		}
+	}
+	return Notification{
+		OrderID:       n.OrderID,
+		Status:        status,
+		Amount:        amount,
+		TransactionID: n.TransactionID,
+		Method:        n.PaymentType,
+		SavedToken:    n.SavedTokenID,
+	}, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+// Package payments connects donations to payment gateways. A gateway starts
+// a checkout for a pending donation and later reports the outcome through a
+// signed HTTP notification.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Donation statuses a notification can move a donation to
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusRefunded  = "refunded"
+)
+
+var ErrGateway = errors.New("payment gateway unavailable")
+
+// Charge is what the donor is asked to pay
+type Charge struct {
+	// OrderID is the donation's transaction ID, which notifications quote
+	OrderID     string
+	Amount      float64
+	Currency    string
+	Email       string
+	Description string
+	// SaveMethod asks the gateway to keep the payment method for later
+	// charges without the donor, filed under CustomerID
+	SaveMethod bool
+	CustomerID string
+}
+
+// Checkout tells the client how the donor completes the payment
+type Checkout struct {
+	Token       string `json:"token"`
+	RedirectURL string `json:"redirectUrl"`
+}
+
+// Notification is a gateway's report on a payment
+type Notification struct {
+	OrderID string
+	// Status is one of the donation statuses above
+	Status string
+	// Amount is what was paid, or for refunds what has been returned
+	Amount float64
+	// Currency is checked against the donation's when the gateway gives it
+	Currency string
+	// TransactionID is the gateway's ID for the payment, used for refunds
+	TransactionID string
+	// Method is how the donor paid, e.g. gopay, qris or bank_transfer
+	Method string
+	// SavedToken charges the same payment method again, when the checkout
+	// asked to save it and the donor agreed
+	SavedToken string
+}
+
+// Provider is a payment gateway
+type Provider interface {
+	Name() string
+	// Validate returns a message for the donor when the gateway cannot take
+	// the charge, e.g. in an unsupported currency
+	Validate(c Charge) string
+	Checkout(ctx context.Context, c Charge) (Checkout, error)
+	// VerifyNotification authenticates a callback and returns its event ID
+	VerifyNotification(r *http.Request, body []byte) (string, error)
+	ParseNotification(body []byte) (Notification, error)
+}
+
+// Capturer is a gateway whose payments are collected by the server once the
+// donor approves them, rather than settled by the gateway
+type Capturer interface {
+	// Capture collects the payment for the gateway's order ID, the token
+	// Checkout returned
+	Capture(ctx context.Context, providerOrderID string) (Notification, error)
+}
+
+// RefundRequest identifies a completed payment to return
+type RefundRequest struct {
+	OrderID               string
+	ProviderTransactionID string
+	Amount                float64
+	Currency              string
+	// Reason is passed on to gateways that record one
+	Reason string
+}
+
+// Refunder is a gateway that can return a payment, so marking a donation
+// refunded also sends the money back
+type Refunder interface {
+	Refund(ctx context.Context, r RefundRequest) error
+}
+
+// RecurringCharger is a gateway that can charge a saved payment method
+// without the donor present, for recurring donations
+type RecurringCharger interface {
+	ChargeSaved(ctx context.Context, c Charge, savedToken string) (Notification, error)
+}
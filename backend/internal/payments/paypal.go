@@ -0,0 +1,350 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"saferelief/internal/inbound"
+)
+
+const (
+	paypalSandboxURL    = "https://api-m.sandbox.paypal.com"
+	paypalProductionURL = "https://api-m.paypal.com"
+)
+
+// paypalCurrencies are the currencies PayPal accepts for payments; the
+// zero-decimal ones take whole amounts only. PayPal does not take IDR.
+var paypalCurrencies = map[string]bool{
+	"AUD": false, "BRL": false, "CAD": false, "CHF": false, "CNY": false, "CZK": false,
+	"DKK": false, "EUR": false, "GBP": false, "HKD": false, "HUF": true, "ILS": false,
+	"JPY": true, "MXN": false, "MYR": false, "NOK": false, "NZD": false, "PHP": false,
+	"PLN": false, "SEK": false, "SGD": false, "THB": false, "TWD": true, "USD": false,
+}
+
+// PayPal takes payments through the Orders v2 API: Checkout creates an
+// order the donor approves on PayPal, and Capture collects it once they
+// return. Webhooks (PAYMENT.CAPTURE.*) cover captures and refunds made
+// elsewhere; point one at /api/webhooks/paypal and set PAYPAL_WEBHOOK_ID.
+type PayPal struct {
+	clientID  string
+	secret    string
+	webhookID string
+	baseURL   string
+	returnURL string
+	cancelURL string
+	client    *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+// NewPayPalFromEnv returns nil unless PAYPAL_CLIENT_ID and
+// PAYPAL_CLIENT_SECRET are set. The sandbox is used unless
+// PAYPAL_PRODUCTION is true. Donors return to FRONTEND_URL.
+func NewPayPalFromEnv() *PayPal {
+	clientID, secret := os.Getenv("PAYPAL_CLIENT_ID"), os.Getenv("PAYPAL_CLIENT_SECRET")
+	if clientID == "" || secret == "" {
+		return nil
+	}
+	baseURL := paypalSandboxURL
+	if production, _ := strconv.ParseBool(os.Getenv("PAYPAL_PRODUCTION")); production {
+		baseURL = paypalProductionURL
+	}
+	frontend := strings.TrimRight(os.Getenv("FRONTEND_URL"), "/")
+	return &PayPal{
+		clientID:  clientID,
+		secret:    secret,
+		webhookID: os.Getenv("PAYPAL_WEBHOOK_ID"),
+		baseURL:   baseURL,
+		returnURL: frontend + "/donations/paypal/return",
+		cancelURL: frontend + "/donations/paypal/cancel",
+		client:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *PayPal) Name() string {
+	return "paypal"
+}
+
+func (p *PayPal) Validate(c Charge) string {
+	zeroDecimal, ok := paypalCurrencies[c.Currency]
+	if !ok {
+		return "PayPal does not accept " + c.Currency + "; donate in a currency such as USD, EUR, SGD or AUD"
+	}
+	if zeroDecimal && c.Amount != float64(int64(c.Amount)) {
+		return "PayPal needs a whole amount in " + c.Currency
+	}
+	return ""
+}
+
+// Checkout creates an order; the token is PayPal's order ID and the
+// redirect URL the page where the donor approves it
+func (p *PayPal) Checkout(ctx context.Context, c Charge) (Checkout, error) {
+	description := firstNonEmpty(c.Description, "SafeRelief donation")
+	request := map[string]interface{}{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]interface{}{{
+			"custom_id":   c.OrderID,
+			"invoice_id":  c.OrderID,
+			"description": truncateRunes(description, 127),
+			"amount":      map[string]string{"currency_code": c.Currency, "value": p.formatAmount(c.Amount, c.Currency)},
+		}},
+		"payment_source": map[string]interface{}{
+			"paypal": map[string]interface{}{
+				"experience_context": map[string]string{
+					"brand_name":          "SafeRelief",
+					"shipping_preference": "NO_SHIPPING",
+					"user_action":         "PAY_NOW",
+					"return_url":          p.returnURL,
+					"cancel_url":          p.cancelURL,
+				},
+			},
+		},
+	}
+	var order struct {
+		ID    string `json:"id"`
+		Links []struct {
+			Rel  string `json:"rel"`
+			Href string `json:"href"`
+		} `json:"links"`
+	}
+	if err := p.call(ctx, http.MethodPost, "/v2/checkout/orders", c.OrderID, request, &order); err != nil {
+		return Checkout{}, err
+	}
+	checkout := Checkout{Token: order.ID}
+	for _, link := range order.Links {
+		if link.Rel == "payer-action" || link.Rel == "approve" {
+			checkout.RedirectURL = link.Href
+		}
+	}
+	return checkout, nil
+}
+
+// paypalCapture is the part of a capture or refund resource that is used
+type paypalCapture struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	CustomID string `json:"custom_id"`
+	Amount   struct {
+		CurrencyCode string `json:"currency_code"`
+		Value        string `json:"value"`
+	} `json:"amount"`
+	Links []struct {
+		Rel  string `json:"rel"`
+		Href string `json:"href"`
+	} `json:"links"`
+}
+
+// Capture collects an order the donor has approved
+func (p *PayPal) Capture(ctx context.Context, providerOrderID string) (Notification, error) {
+	var order struct {
+		Status        string `json:"status"`
+		PurchaseUnits []struct {
+			Payments struct {
+				Captures []paypalCapture `json:"captures"`
+			} `json:"payments"`
+		} `json:"purchase_units"`
+	}
+	path := "/v2/checkout/orders/" + url.PathEscape(providerOrderID) + "/capture"
+	if err := p.call(ctx, http.MethodPost, path, providerOrderID+"-capture", map[string]string{}, &order); err != nil {
+		return Notification{}, err
+	}
+	if len(order.PurchaseUnits) == 0 || len(order.PurchaseUnits[0].Payments.Captures) == 0 {
+		return Notification{}, fmt.Errorf("%w: PayPal returned no capture for order %s", ErrGateway, providerOrderID)
+	}
+	return captureNotification(order.PurchaseUnits[0].Payments.Captures[0], "paypal")
+}
+
+// Refund returns a captured payment in full
+func (p *PayPal) Refund(ctx context.Context, r RefundRequest) error {
+	path := "/v2/payments/captures/" + url.PathEscape(r.ProviderTransactionID) + "/refund"
+	request := map[string]interface{}{
+		"amount":        map[string]string{"currency_code": r.Currency, "value": p.formatAmount(r.Amount, r.Currency)},
+		"note_to_payer": "SafeRelief donation refund",
+	}
+	var refund struct {
+		Status string `json:"status"`
+	}
+	if err := p.call(ctx, http.MethodPost, path, r.OrderID+"-refund", request, &refund); err != nil {
+		return err
+	}
+	if refund.Status != "COMPLETED" && refund.Status != "PENDING" {
+		return fmt.Errorf("%w: PayPal refund is %s", ErrGateway, refund.Status)
+	}
+	return nil
+}
+
+// VerifyNotification asks PayPal to verify the transmission signature
+// against PAYPAL_WEBHOOK_ID and returns the event ID
+func (p *PayPal) VerifyNotification(r *http.Request, body []byte) (string, error) {
+	var event struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		return "", inbound.ErrInvalidPayload
+	}
+	if p.webhookID == "" {
+		return "", inbound.ErrInvalidSignature
+	}
+	if err := inbound.CheckTimestamp(r.Header.Get("Paypal-Transmission-Time"), inbound.DefaultTolerance); err != nil {
+		return "", err
+	}
+	request := map[string]interface{}{
+		"auth_algo":         r.Header.Get("Paypal-Auth-Algo"),
+		"cert_url":          r.Header.Get("Paypal-Cert-Url"),
+		"transmission_id":   r.Header.Get("Paypal-Transmission-Id"),
+		"transmission_sig":  r.Header.Get("Paypal-Transmission-Sig"),
+		"transmission_time": r.Header.Get("Paypal-Transmission-Time"),
+		"webhook_id":        p.webhookID,
+		"webhook_event":     json.RawMessage(body),
+	}
+	var result struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := p.call(r.Context(), http.MethodPost, "/v1/notifications/verify-webhook-signature", "", request, &result); err != nil {
+		return "", err
+	}
+	if result.VerificationStatus != "SUCCESS" {
+		return "", inbound.ErrInvalidSignature
+	}
+	return event.ID, nil
+}
+
+// ParseNotification reads PAYMENT.CAPTURE.* events; other events come back
+// as pending, which changes nothing
+func (p *PayPal) ParseNotification(body []byte) (Notification, error) {
+	var event struct {
+		EventType string        `json:"event_type"`
+		Resource  paypalCapture `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return Notification{}, err
+	}
+	switch event.EventType {
+	case "PAYMENT.CAPTURE.COMPLETED", "PAYMENT.CAPTURE.DENIED", "PAYMENT.CAPTURE.DECLINED":
+		return captureNotification(event.Resource, "paypal")
+	case "PAYMENT.CAPTURE.REFUNDED", "PAYMENT.CAPTURE.REVERSED":
+		n, err := captureNotification(event.Resource, "paypal")
+		n.Status = StatusRefunded
+		// The resource is the refund; the capture it returns is linked as "up"
+		n.TransactionID = ""
+		for _, link := range event.Resource.Links {
+			if link.Rel == "up" {
+				n.TransactionID = link.Href[strings.LastIndex(link.Href, "/")+1:]
+			}
+		}
+		return n, err
+	}
+	return Notification{Status: StatusPending}, nil
+}
+
+func captureNotification(c paypalCapture, method string) (Notification, error) {
+	amount, err := strconv.ParseFloat(c.Amount.Value, 64)
+	if err != nil {
+		return Notification{}, fmt.Errorf("invalid PayPal amount %q", c.Amount.Value)
+	}
+	status := StatusPending
+	switch c.Status {
+	case "COMPLETED":
+		status = StatusCompleted
+	case "DECLINED", "FAILED":
+		status = StatusFailed
+	}
+	return Notification{
+		OrderID:       c.CustomID,
+		Status:        status,
+		Amount:        amount,
+		Currency:      c.Amount.CurrencyCode,
+		TransactionID: c.ID,
+		Method:        method,
+	}, nil
+}
+
+func (p *PayPal) formatAmount(amount float64, currency string) string {
+	if paypalCurrencies[currency] {
+		return strconv.FormatFloat(amount, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// call sends a JSON request with a fresh OAuth token. requestID makes
+// retried creates, captures and refunds idempotent on PayPal's side.
+func (p *PayPal) call(ctx context.Context, method, path, requestID string, body, result interface{}) error {
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+	encoded, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if requestID != "" {
+		req.Header.Set("PayPal-Request-Id", requestID)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrGateway, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var failure struct {
+			Name    string `json:"name"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(raw, &failure)
+		return fmt.Errorf("%w: PayPal responded with %d: %s %s", ErrGateway, resp.StatusCode, failure.Name, failure.Message)
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// token returns a cached OAuth access token, fetching a new one shortly
+// before it expires
+func (p *PayPal) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.accessToken != "" && time.Now().Before(p.expires) {
+		return p.accessToken, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/oauth2/token",
+		strings.NewReader("grant_type=client_credentials"))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.clientID, p.secret)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrGateway, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: PayPal token request responded with %d", ErrGateway, resp.StatusCode)
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return "", err
+	}
+	p.accessToken = result.AccessToken
+	p.expires = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
@@ -0,0 +1,117 @@
+// Package pdf writes simple single-page text documents, enough for receipts,
+// without a third-party library. Text uses the standard Helvetica fonts,
+// which every reader has, so nothing is embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+type Font int
+
+const (
+	Regular Font = iota
+	Bold
+)
+
+// Document is one A4 page built from text lines and rules. Coordinates are
+// in points from the bottom-left corner.
+type Document struct {
+	title   string
+	content bytes.Buffer
+}
+
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// Text draws s with its baseline starting at x, y
+func (d *Document) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(&d.content, "BT /F%d %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font+1, size, x, y, escape(s))
+}
+
+// TextRight draws s ending at x. Widths are estimated from average
+// Helvetica glyph widths, which is close enough for amounts and dates.
+func (d *Document) TextRight(x, y float64, font Font, size float64, s string) {
+	d.Text(x-estimateWidth(s, font, size), y, font, size, s)
+}
+
+// Line draws a horizontal rule from x1 to x2
+func (d *Document) Line(x1, x2, y, width float64) {
+	fmt.Fprintf(&d.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y, x2, y)
+}
+
+// Bytes renders the document
+func (d *Document) Bytes() []byte {
+	stream := d.content.Bytes()
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", PageWidth, PageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(stream), stream),
+		fmt.Sprintf("<< /Title (%s) /Producer (SafeRelief) >>", escape(d.title)),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, len(objects), xref)
+	return out.Bytes()
+}
+
+// escape encodes s as WinAnsi inside a PDF string literal. Characters the
+// standard fonts cannot show become '?'.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case winAnsi[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsi[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// winAnsi maps the punctuation WinAnsiEncoding places in 0x80-0x9f
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+func estimateWidth(s string, font Font, size float64) float64 {
+	perGlyph := 0.55
+	if font == Bold {
+		perGlyph = 0.6
+	}
+	return float64(len([]rune(s))) * perGlyph * size
+}
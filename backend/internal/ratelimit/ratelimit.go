@@ -0,0 +1,194 @@
+// Package ratelimit enforces per-consumer request quotas. Each consumer is
+// assigned a tier by the auth layer and every tier has its own budget, so
+// verified NGOs, partners and staff tools are not throttled like anonymous
+// traffic.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Tier string
+
+const (
+	// TierPublic is the unauthenticated public API, counted per IP whoever
+	// the caller is
+	TierPublic    Tier = "public"
+	TierAnonymous Tier = "anonymous"
+	TierUser      Tier = "user"
+	TierNGO       Tier = "ngo"
+	TierPartner   Tier = "partner"
+	TierAdmin     Tier = "admin"
+)
+
+// Tiers lists every tier in ascending order of trust
+var Tiers = []Tier{TierPublic, TierAnonymous, TierUser, TierNGO, TierPartner, TierAdmin}
+
+// Window is the period each tier's limit applies to
+const Window = time.Minute
+
+// DefaultLimits are requests per minute for each tier
+var DefaultLimits = map[Tier]int{
+	TierPublic:    30,
+	TierAnonymous: 60,
+	TierUser:      300,
+	TierNGO:       900,
+	TierPartner:   1200,
+	TierAdmin:     600,
+}
+
+// TierForRole maps an account role to its tier. Members of a verified
+// organization are raised to the NGO tier by the auth layer, and API keys
+// are assigned the partner tier directly.
+func TierForRole(role string) Tier {
+	if role == "admin" {
+		return TierAdmin
+	}
+	return TierUser
+}
+
+func ValidTier(tier Tier) bool {
+	_, ok := DefaultLimits[tier]
+	return ok
+}
+
+// ParseLimits reads overrides such as "anonymous=30,partner=5000" on top of
+// the defaults
+func ParseLimits(raw string) (map[Tier]int, error) {
+	limits := make(map[Tier]int, len(DefaultLimits))
+	for tier, limit := range DefaultLimits {
+		limits[tier] = limit
+	}
+	if strings.TrimSpace(raw) == "" {
+		return limits, nil
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("expected tier=limit, got %q", pair)
+		}
+		tier := Tier(strings.TrimSpace(name))
+		if !ValidTier(tier) {
+			return nil, fmt.Errorf("unknown tier %q", name)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("limit for %s must be a positive integer", tier)
+		}
+		limits[tier] = limit
+	}
+	return limits, nil
+}
+
+type window struct {
+	count int
+	start time.Time
+}
+
+// Limiter counts requests per key in fixed one-minute windows. The base
+// limits come from configuration; overrides stored in the database replace
+// them tier by tier.
+type Limiter struct {
+	base      map[Tier]int
+	overrides map[Tier]int
+	windows   map[string]*window
+	mu        sync.Mutex
+}
+
+func NewLimiter(limits map[Tier]int) *Limiter {
+	l := &Limiter{base: limits, overrides: map[Tier]int{}, windows: make(map[string]*window)}
+	go l.sweep()
+	return l
+}
+
+// TierLimit is the effective budget of one tier and where it came from
+type TierLimit struct {
+	Tier              Tier   `json:"tier"`
+	RequestsPerMinute int    `json:"requestsPerMinute"`
+	Source            string `json:"source"`
+}
+
+// Limits reports the effective budget of every tier
+func (l *Limiter) Limits() []TierLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits := make([]TierLimit, 0, len(Tiers))
+	for _, tier := range Tiers {
+		if limit, ok := l.overrides[tier]; ok {
+			limits = append(limits, TierLimit{Tier: tier, RequestsPerMinute: limit, Source: "database"})
+			continue
+		}
+		limits = append(limits, TierLimit{Tier: tier, RequestsPerMinute: l.base[tier], Source: "config"})
+	}
+	return limits
+}
+
+// SetOverrides replaces the database overrides; tiers left out fall back to
+// the configured limit
+func (l *Limiter) SetOverrides(overrides map[Tier]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides = overrides
+}
+
+// limit must be called with mu held
+func (l *Limiter) limit(tier Tier) int {
+	if limit, ok := l.overrides[tier]; ok {
+		return limit
+	}
+	if limit, ok := l.base[tier]; ok {
+		return limit
+	}
+	return l.base[TierAnonymous]
+}
+
+// Decision describes the outcome of a request against its quota
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Allow records a request for key and reports whether it is within the
+// tier's budget. Unknown tiers get the anonymous budget.
+func (l *Limiter) Allow(key string, tier Tier) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.limit(tier)
+
+	now := time.Now()
+	w, exists := l.windows[key]
+	if !exists || now.Sub(w.start) >= Window {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+
+	if w.count >= limit {
+		return Decision{Limit: limit, RetryAfter: w.start.Add(Window).Sub(now)}
+	}
+	w.count++
+	return Decision{Allowed: true, Limit: limit, Remaining: limit - w.count}
+}
+
+// sweep drops expired windows so idle clients don't accumulate in memory
+func (l *Limiter) sweep() {
+	ticker := time.NewTicker(5 * Window)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.mu.Lock()
+		for key, w := range l.windows {
+			if time.Since(w.start) >= Window {
+				delete(l.windows, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// LoadOverrides reads the per-tier limits admins have stored in the database
+func LoadOverrides(ctx context.Context, db *sql.DB) (map[Tier]int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT tier, requests_per_minute FROM rate_limit_tiers")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := map[Tier]int{}
+	for rows.Next() {
+		var tier Tier
+		var limit int
+		if err := rows.Scan(&tier, &limit); err != nil {
+			return nil, err
+		}
+		if ValidTier(tier) && limit > 0 {
+			overrides[tier] = limit
+		}
+	}
+	return overrides, rows.Err()
+}
+
+// SaveOverride stores a tier's limit, or removes the override when limit is
+// zero so the configured value applies again
+func SaveOverride(ctx context.Context, db *sql.DB, tier Tier, limit int, updatedBy string) error {
+	if limit == 0 {
+		_, err := db.ExecContext(ctx, "DELETE FROM rate_limit_tiers WHERE tier = ?", tier)
+		return err
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO rate_limit_tiers (tier, requests_per_minute, updated_by)
+		VALUES (?, ?, UUID_TO_BIN(?))
+		ON DUPLICATE KEY UPDATE requests_per_minute = VALUES(requests_per_minute),
+		updated_by = VALUES(updated_by), updated_at = NOW()`,
+		tier, limit, updatedBy,
+	)
+	return err
+}
+
+// Reload applies the stored overrides to the limiter
+func (l *Limiter) Reload(ctx context.Context, db *sql.DB) error {
+	overrides, err := LoadOverrides(ctx, db)
+	if err != nil {
+		return err
+	}
+	l.SetOverrides(overrides)
+	return nil
+}
+
+// Start loads the stored overrides and re-reads them every interval so a
+// change made on one instance reaches the others
+func Start(db *sql.DB, limiter *Limiter, interval time.Duration) {
+	if err := limiter.Reload(context.Background(), db); err != nil {
+		log.Printf("Failed to load rate limit overrides: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := limiter.Reload(context.Background(), db); err != nil {
+				log.Printf("Failed to reload rate limit overrides: %v", err)
+			}
+		}
+	}()
+}
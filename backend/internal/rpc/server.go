@@ -0,0 +1,270 @@
+// Package rpc serves unary gRPC methods for internal services. It speaks the
+// gRPC HTTP/2 protocol directly on net/http, so standard gRPC clients
+// generated from proto/saferelief/v1/core.proto work against it, without
+// pulling the grpc-go runtime into the API server.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMessageSize matches the grpc-go default receive limit
+const maxMessageSize = 4 << 20
+
+// Code is a gRPC status code
+type Code int
+
+const (
+	OK                 Code = 0
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	PermissionDenied   Code = 7
+	FailedPrecondition Code = 9
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	Unauthenticated    Code = 16
+)
+
+// Status is an error carrying the gRPC code returned to the client
+type Status struct {
+	Code    Code
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", s.Code, s.Message)
+}
+
+func Errorf(code Code, format string, args ...interface{}) error {
+	return &Status{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler decodes a request message and returns the encoded response
+type Handler func(ctx context.Context, req Message) ([]byte, error)
+
+type clientKey struct{}
+
+// ClientIdentity is the calling service's certificate name, its common name
+// or an allowed DNS name
+func ClientIdentity(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+type Server struct {
+	addr      string
+	tlsConfig *tls.Config
+	// allowed limits callers to these certificate names; empty allows any
+	// certificate signed by the client CA
+	allowed  map[string]bool
+	handlers map[string]Handler
+}
+
+// NewServerFromEnv configures the mTLS listener from GRPC_* variables. It
+// returns nil when GRPC_ADDR is unset.
+func NewServerFromEnv() (*Server, error) {
+	addr := os.Getenv("GRPC_ADDR")
+	if addr == "" {
+		return nil, nil
+	}
+	certPath, keyPath, caPath := os.Getenv("GRPC_TLS_CERT"), os.Getenv("GRPC_TLS_KEY"), os.Getenv("GRPC_CLIENT_CA")
+	if certPath == "" || keyPath == "" || caPath == "" {
+		return nil, errors.New("GRPC_TLS_CERT, GRPC_TLS_KEY and GRPC_CLIENT_CA are required when GRPC_ADDR is set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading gRPC server certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading GRPC_CLIENT_CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("GRPC_CLIENT_CA contains no PEM certificates")
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv("GRPC_ALLOWED_CLIENTS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
+
+	return NewServer(addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
+	}, allowed), nil
+}
+
+func NewServer(addr string, tlsConfig *tls.Config, allowed map[string]bool) *Server {
+	s := &Server{addr: addr, tlsConfig: tlsConfig, allowed: allowed, handlers: make(map[string]Handler)}
+	s.Register("grpc.health.v1.Health", "Check", func(ctx context.Context, req Message) ([]byte, error) {
+		// HealthCheckResponse.status = SERVING
+		return (&Encoder{}).Int64(1, 1).Bytes(), nil
+	})
+	return s
+}
+
+// Register adds a unary method, served at /<service>/<method>
+func (s *Server) Register(service, method string, h Handler) {
+	s.handlers["/"+service+"/"+method] = h
+}
+
+func (s *Server) ListenAndServe() error {
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           s,
+		TLSConfig:         s.tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       5 * time.Minute,
+	}
+	log.Printf("gRPC server listening on %s", s.addr)
+	return srv.ListenAndServeTLS("", "")
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 ||
+		!strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+
+	client, ok := s.identify(r)
+	if !ok {
+		writeError(w, Unauthenticated, "client certificate is not allowed")
+		return
+	}
+	handler, ok := s.handlers[r.URL.Path]
+	if !ok {
+		writeError(w, Unimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), clientKey{}, client)
+	if timeout, ok := parseTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	payload, status := readMessage(r.Body)
+	if status != nil {
+		writeError(w, status.Code, status.Message)
+		return
+	}
+	req, err := Unmarshal(payload)
+	if err != nil {
+		writeError(w, InvalidArgument, err.Error())
+		return
+	}
+
+	resp, err := handler(ctx, req)
+	if err == nil && ctx.Err() == context.DeadlineExceeded {
+		err = Errorf(DeadlineExceeded, "deadline exceeded")
+	}
+	if err != nil {
+		var status *Status
+		if !errors.As(err, &status) {
+			log.Printf("gRPC %s from %s failed: %v", r.URL.Path, client, err)
+			status = &Status{Code: Internal, Message: "internal error"}
+		}
+		writeError(w, status.Code, status.Message)
+		return
+	}
+
+	frame := make([]byte, 5, 5+len(resp))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(resp)))
+	w.Header().Set("Trailer", "Grpc-Status")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(frame, resp...))
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(OK)))
+}
+
+// identify returns the caller's certificate name. The TLS handshake has
+// already verified the chain against the client CA.
+func (s *Server) identify(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", false
+	}
+	cert := r.TLS.PeerCertificates[0]
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, name := range names {
+		if name != "" && (len(s.allowed) == 0 || s.allowed[name]) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// readMessage reads the single length-prefixed message of a unary call
+func readMessage(body io.Reader) ([]byte, *Status) {
+	data, err := io.ReadAll(io.LimitReader(body, maxMessageSize+6))
+	if err != nil {
+		return nil, &Status{Code: Internal, Message: "reading request: " + err.Error()}
+	}
+	if len(data) < 5 {
+		return nil, &Status{Code: InvalidArgument, Message: "missing request message"}
+	}
+	if data[0] != 0 {
+		return nil, &Status{Code: Unimplemented, Message: "compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(data[1:5])
+	if size > maxMessageSize {
+		return nil, &Status{Code: InvalidArgument, Message: "request message is too large"}
+	}
+	if uint32(len(data)-5) != size {
+		return nil, &Status{Code: InvalidArgument, Message: "unary calls take exactly one request message"}
+	}
+	return data[5:], nil
+}
+
+// writeError sends a trailers-only response: the status travels in the
+// headers of a response without a body
+func writeError(w http.ResponseWriter, code Code, message string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	w.Header().Set("Grpc-Message", url.PathEscape(message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseTimeout reads the grpc-timeout header, an integer of at most eight
+// digits followed by a unit
+func parseTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
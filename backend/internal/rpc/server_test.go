@@ -0,0 +1,336 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for one test
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startServer serves s over mutual TLS as NewServerFromEnv configures it
+func startServer(t *testing.T, allowed map[string]bool) (*Server, *testCA, string) {
+	t.Helper()
+	ca := newTestCA(t)
+	s := NewServer("", &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "api", x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
+	}, allowed)
+	srv := httptest.NewUnstartedServer(s)
+	srv.TLS = s.tlsConfig
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return s, ca, srv.URL
+}
+
+func grpcClient(ca *testCA, cert tls.Certificate) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		ForceAttemptHTTP2: true,
+		TLSClientConfig: &tls.Config{
+			RootCAs:      ca.pool,
+			Certificates: []tls.Certificate{cert},
+		},
+	}}
+}
+
+func frame(message []byte) []byte {
+	b := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(message)))
+	return append(b, message...)
+}
+
+type callResult struct {
+	status  Code
+	message string
+	body    []byte
+}
+
+func call(t *testing.T, client *http.Client, url string, body []byte, header http.Header) callResult {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("call %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("response over HTTP/%d, want HTTP/2", resp.ProtoMajor)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors come trailers-only, in the headers; successes in the trailers
+	status := resp.Header.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Trailer.Get("Grpc-Status")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		t.Fatalf("grpc-status %q is not a code", status)
+	}
+	result := callResult{status: Code(code), message: resp.Header.Get("Grpc-Message")}
+	if len(data) > 0 {
+		if len(data) < 5 || int(binary.BigEndian.Uint32(data[1:5])) != len(data)-5 {
+			t.Fatalf("response is not one length-prefixed message: % x", data)
+		}
+		result.body = data[5:]
+	}
+	return result
+}
+
+func TestServerCallOverMutualTLS(t *testing.T) {
+	s, ca, url := startServer(t, nil)
+	s.Register("saferelief.v1.Test", "Echo", func(ctx context.Context, req Message) ([]byte, error) {
+		return (&Encoder{}).
+			String(1, req.String(1)).
+			String(2, ClientIdentity(ctx)).
+			Bool(3, hasDeadline(ctx)).
+			Bytes(), nil
+	})
+	client := grpcClient(ca, ca.issue(t, "reports-worker", x509.ExtKeyUsageClientAuth))
+
+	result := call(t, client, url+"/saferelief.v1.Test/Echo", frame((&Encoder{}).String(1, "hello").Bytes()),
+		http.Header{"Grpc-Timeout": {"5S"}})
+	if result.status != OK {
+		t.Fatalf("status %d %q, want OK", result.status, result.message)
+	}
+	resp, err := Unmarshal(result.body)
+	if err != nil {
+		t.Fatalf("Unmarshal response: %v", err)
+	}
+	if got := resp.String(1); got != "hello" {
+		t.Errorf("echoed %q, want hello", got)
+	}
+	if got := resp.String(2); got != "reports-worker" {
+		t.Errorf("client identity %q, want reports-worker", got)
+	}
+	if !resp.Bool(3) {
+		t.Error("grpc-timeout did not set a deadline")
+	}
+
+	health := call(t, client, url+"/grpc.health.v1.Health/Check", frame(nil), nil)
+	if health.status != OK {
+		t.Fatalf("health check status %d %q", health.status, health.message)
+	}
+	if m, _ := Unmarshal(health.body); m.Int64(1) != 1 {
+		t.Errorf("health check body % x, want SERVING", health.body)
+	}
+}
+
+func hasDeadline(ctx context.Context) bool {
+	_, ok := ctx.Deadline()
+	return ok
+}
+
+func TestServerErrors(t *testing.T) {
+	s, ca, url := startServer(t, nil)
+	s.Register("saferelief.v1.Test", "Missing", func(ctx context.Context, req Message) ([]byte, error) {
+		return nil, Errorf(NotFound, "report %s not found", req.String(1))
+	})
+	s.Register("saferelief.v1.Test", "Broken", func(ctx context.Context, req Message) ([]byte, error) {
+		return nil, io.ErrUnexpectedEOF
+	})
+	client := grpcClient(ca, ca.issue(t, "reports-worker", x509.ExtKeyUsageClientAuth))
+	valid := frame((&Encoder{}).String(1, "r-1").Bytes())
+
+	tests := []struct {
+		name    string
+		method  string
+		body    []byte
+		code    Code
+		message string
+	}{
+		{"status from the handler", "/saferelief.v1.Test/Missing", valid, NotFound, "report%20r-1%20not%20found"},
+		{"other handler errors are hidden", "/saferelief.v1.Test/Broken", valid, Internal, "internal%20error"},
+		{"unknown method", "/saferelief.v1.Test/Nope", valid, Unimplemented, ""},
+		{"no message", "/saferelief.v1.Test/Missing", nil, InvalidArgument, "missing%20request%20message"},
+		{"short prefix", "/saferelief.v1.Test/Missing", []byte{0, 0, 0}, InvalidArgument, ""},
+		{"compressed", "/saferelief.v1.Test/Missing", append([]byte{1}, valid[1:]...), Unimplemented, ""},
+		{"length past the body", "/saferelief.v1.Test/Missing", append([]byte{0, 0, 0, 0, 9}, 0x0a, 0x01, 'x'), InvalidArgument, ""},
+		{"two messages", "/saferelief.v1.Test/Missing", append(frame(nil), valid...), InvalidArgument, ""},
+		{"oversized", "/saferelief.v1.Test/Missing", []byte{0, 0xff, 0xff, 0xff, 0xff}, InvalidArgument, "request%20message%20is%20too%20large"},
+		{"malformed protobuf", "/saferelief.v1.Test/Missing", frame([]byte{0x0a, 0x05, 'a'}), InvalidArgument, "malformed%20protobuf%20message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := call(t, client, url+tt.method, tt.body, nil)
+			if result.status != tt.code {
+				t.Errorf("status %d %q, want %d", result.status, result.message, tt.code)
+			}
+			if tt.message != "" && result.message != tt.message {
+				t.Errorf("message %q, want %q", result.message, tt.message)
+			}
+			if result.body != nil {
+				t.Errorf("error response has a message body: % x", result.body)
+			}
+		})
+	}
+}
+
+func TestServerClientCertificates(t *testing.T) {
+	_, ca, url := startServer(t, map[string]bool{"reports-worker": true})
+	method := url + "/grpc.health.v1.Health/Check"
+
+	allowed := grpcClient(ca, ca.issue(t, "reports-worker", x509.ExtKeyUsageClientAuth))
+	if result := call(t, allowed, method, frame(nil), nil); result.status != OK {
+		t.Errorf("allowed client got status %d %q", result.status, result.message)
+	}
+
+	other := grpcClient(ca, ca.issue(t, "billing", x509.ExtKeyUsageClientAuth))
+	if result := call(t, other, method, frame(nil), nil); result.status != Unauthenticated {
+		t.Errorf("client outside GRPC_ALLOWED_CLIENTS got status %d, want Unauthenticated", result.status)
+	}
+
+	// Certificates from another CA, or none at all, fail the handshake
+	stranger := newTestCA(t)
+	for name, client := range map[string]*http.Client{
+		"untrusted CA":   grpcClient(ca, stranger.issue(t, "reports-worker", x509.ExtKeyUsageClientAuth)),
+		"no certificate": grpcClient(ca, tls.Certificate{}),
+	} {
+		req, _ := http.NewRequest(http.MethodPost, method, bytes.NewReader(frame(nil)))
+		req.Header.Set("Content-Type", "application/grpc")
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: call succeeded with HTTP %d, want a handshake failure", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestServerRejectsNonGRPCRequests(t *testing.T) {
+	_, ca, url := startServer(t, nil)
+	client := grpcClient(ca, ca.issue(t, "reports-worker", x509.ExtKeyUsageClientAuth))
+
+	for name, req := range map[string]func() *http.Request{
+		"GET": func() *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, url+"/grpc.health.v1.Health/Check", nil)
+			req.Header.Set("Content-Type", "application/grpc")
+			return req
+		},
+		"JSON": func() *http.Request {
+			req, _ := http.NewRequest(http.MethodPost, url+"/grpc.health.v1.Health/Check", bytes.NewReader([]byte("{}")))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		},
+	} {
+		resp, err := client.Do(req())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("%s: HTTP %d, want 415", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"1H", time.Hour, true},
+		{"30M", 30 * time.Minute, true},
+		{"5S", 5 * time.Second, true},
+		{"250m", 250 * time.Millisecond, true},
+		{"10u", 10 * time.Microsecond, true},
+		{"99999999n", 99999999 * time.Nanosecond, true},
+		{"", 0, false},
+		{"S", 0, false},
+		{"100", 0, false},
+		{"5s", 0, false},
+		{"-5S", 0, false},
+		{"123456789S", 0, false},
+		{"1.5S", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseTimeout(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseTimeout(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Protocol buffer wire types used by the service messages
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformed = errors.New("malformed protobuf message")
+
+type rawField struct {
+	wire  int
+	value uint64
+	bytes []byte
+}
+
+// Message is a decoded protobuf message. Fields are read by number with the
+// accessor for their declared type; absent fields read as the proto3 zero
+// value and for repeated scalars the last occurrence wins.
+type Message struct {
+	fields map[int][]rawField
+}
+
+// Unmarshal decodes the wire format without a schema. Unknown fields are
+// kept and simply never read, so older servers accept newer clients.
+func Unmarshal(b []byte) (Message, error) {
+	m := Message{fields: make(map[int][]rawField)}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return m, errMalformed
+		}
+		b = b[n:]
+		number, wire := int(tag>>3), int(tag&7)
+		if number <= 0 {
+			return m, errMalformed
+		}
+
+		var f rawField
+		f.wire = wire
+		switch wire {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return m, errMalformed
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return m, errMalformed
+			}
+			f.value, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return m, errMalformed
+			}
+			f.value, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return m, errMalformed
+			}
+			f.bytes, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			// Groups are deprecated and never used by this API
+			return m, errMalformed
+		}
+		m.fields[number] = append(m.fields[number], f)
+	}
+	return m, nil
+}
+
+func (m Message) last(number, wire int) (rawField, bool) {
+	values := m.fields[number]
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i].wire == wire {
+			return values[i], true
+		}
+	}
+	return rawField{}, false
+}
+
+func (m Message) String(number int) string {
+	f, _ := m.last(number, wireBytes)
+	return string(f.bytes)
+}
+
+func (m Message) Double(number int) float64 {
+	f, _ := m.last(number, wireFixed64)
+	return math.Float64frombits(f.value)
+}
+
+func (m Message) Int64(number int) int64 {
+	f, _ := m.last(number, wireVarint)
+	return int64(f.value)
+}
+
+func (m Message) Bool(number int) bool {
+	f, _ := m.last(number, wireVarint)
+	return f.value != 0
+}
+
+// Strings reads a repeated string field
+func (m Message) Strings(number int) []string {
+	var values []string
+	for _, f := range m.fields[number] {
+		if f.wire == wireBytes {
+			values = append(values, string(f.bytes))
+		}
+	}
+	return values
+}
+
+// Encoder builds a message in field order. Zero values are omitted as
+// proto3 requires.
+type Encoder struct {
+	buf []byte
+}
+
+func (e *Encoder) tag(number, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(number)<<3|uint64(wire))
+}
+
+func (e *Encoder) String(number int, v string) *Encoder {
+	if v != "" {
+		e.tag(number, wireBytes)
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	}
+	return e
+}
+
+func (e *Encoder) Double(number int, v float64) *Encoder {
+	if v != 0 {
+		e.tag(number, wireFixed64)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+	}
+	return e
+}
+
+func (e *Encoder) Int64(number int, v int64) *Encoder {
+	if v != 0 {
+		e.tag(number, wireVarint)
+		e.buf = binary.AppendUvarint(e.buf, uint64(v))
+	}
+	return e
+}
+
+func (e *Encoder) Bool(number int, v bool) *Encoder {
+	if v {
+		e.tag(number, wireVarint)
+		e.buf = append(e.buf, 1)
+	}
+	return e
+}
+
+// Strings writes a repeated string field
+func (e *Encoder) Strings(number int, values []string) *Encoder {
+	for _, v := range values {
+		e.tag(number, wireBytes)
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	}
+	return e
+}
+
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
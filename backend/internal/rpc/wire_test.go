@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestWireRoundTrip(t *testing.T) {
+	b := (&Encoder{}).
+		String(1, "report-1").
+		Double(2, -6.2088).
+		Int64(3, 1500000).
+		Int64(4, -42).
+		Bool(5, true).
+		Strings(6, []string{"flood", "", "jakarta"}).
+		Double(7, math.MaxFloat64).
+		Bytes()
+
+	m, err := Unmarshal(b)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got := m.String(1); got != "report-1" {
+		t.Errorf("String(1) = %q, want report-1", got)
+	}
+	if got := m.Double(2); got != -6.2088 {
+		t.Errorf("Double(2) = %v, want -6.2088", got)
+	}
+	if got := m.Int64(3); got != 1500000 {
+		t.Errorf("Int64(3) = %d, want 1500000", got)
+	}
+	if got := m.Int64(4); got != -42 {
+		t.Errorf("Int64(4) = %d, want -42", got)
+	}
+	if !m.Bool(5) {
+		t.Error("Bool(5) = false, want true")
+	}
+	if got, want := m.Strings(6), []string{"flood", "", "jakarta"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Strings(6) = %q, want %q", got, want)
+	}
+	if got := m.Double(7); got != math.MaxFloat64 {
+		t.Errorf("Double(7) = %v, want MaxFloat64", got)
+	}
+}
+
+// Bytes produced by protoc-generated code for the same values, so the codec
+// stays compatible with real clients
+func TestWireMatchesProtobufEncoding(t *testing.T) {
+	got := (&Encoder{}).String(1, "testing").Int64(2, 150).Int64(3, -1).Bool(4, true).Double(5, 1).Bytes()
+	want := []byte{
+		0x0a, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g',
+		0x10, 0x96, 0x01,
+		0x18, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01,
+		0x20, 0x01,
+		0x29, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x\nwant % x", got, want)
+	}
+}
+
+func TestWireZeroValuesAreOmitted(t *testing.T) {
+	b := (&Encoder{}).String(1, "").Double(2, 0).Int64(3, 0).Bool(4, false).Strings(5, nil).Bytes()
+	if len(b) != 0 {
+		t.Errorf("got % x, want no bytes", b)
+	}
+	m, err := Unmarshal(b)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if m.String(1) != "" || m.Double(2) != 0 || m.Int64(3) != 0 || m.Bool(4) || m.Strings(5) != nil {
+		t.Error("absent fields do not read as zero values")
+	}
+}
+
+func TestWireFieldRules(t *testing.T) {
+	b := (&Encoder{}).
+		Int64(1, 7).
+		String(2, "first").
+		String(99, "added by a newer client").
+		String(2, "second").
+		Int64(1, 9).
+		Bytes()
+	m, err := Unmarshal(b)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got := m.Int64(1); got != 9 {
+		t.Errorf("Int64(1) = %d, want the last occurrence 9", got)
+	}
+	if got := m.String(2); got != "second" {
+		t.Errorf("String(2) = %q, want the last occurrence", got)
+	}
+	// A field sent with another wire type than the one read is absent
+	if got := m.String(1); got != "" {
+		t.Errorf("String(1) on a varint field = %q, want empty", got)
+	}
+	if got := m.Double(2); got != 0 {
+		t.Errorf("Double(2) on a string field = %v, want 0", got)
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"truncated tag", []byte{0x80}},
+		{"field number zero", []byte{0x00, 0x01}},
+		{"overlong tag", bytes.Repeat([]byte{0xff}, 11)},
+		{"truncated varint", []byte{0x08, 0x96}},
+		{"overlong varint", append([]byte{0x08}, bytes.Repeat([]byte{0xff}, 11)...)},
+		{"short fixed64", []byte{0x11, 1, 2, 3, 4, 5, 6, 7}},
+		{"short fixed32", []byte{0x15, 1, 2, 3}},
+		{"missing length", []byte{0x0a}},
+		{"length past the end", []byte{0x0a, 0x05, 'a', 'b'}},
+		{"huge length", []byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 'a'}},
+		{"start group", []byte{0x0b, 0x0c}},
+		{"end group", []byte{0x0c}},
+		{"reserved wire type", []byte{0x0e, 0x00}},
+		{"garbage after a valid field", []byte{0x08, 0x01, 0x0a}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Unmarshal(tt.input); err != errMalformed {
+				t.Errorf("Unmarshal(% x) = %v, want errMalformed", tt.input, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Package sessions tracks refresh-token sessions and the devices they belong
+// to. A session row is what keeps a login alive: deleting it revokes both the
+// refresh token and any access token carrying its id.
+package sessions
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"saferelief/internal/tokens"
+)
+
+var (
+	ErrRevoked         = errors.New("session revoked or expired")
+	ErrNotFound        = errors.New("device not found")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+type Session struct {
+	ID         string     `json:"id"`
+	DeviceID   *string    `json:"deviceId"`
+	DeviceName string     `json:"deviceName"`
+	IPAddress  string     `json:"ipAddress"`
+	UserAgent  string     `json:"userAgent"`
+	LastSeenAt *time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	Current    bool       `json:"current"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type Device struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	UserAgent      string     `json:"userAgent"`
+	LastSeenAt     time.Time  `json:"lastSeenAt"`
+	TrustedUntil   *time.Time `json:"trustedUntil"`
+	Trusted        bool       `json:"trusted"`
+	ActiveSessions int        `json:"activeSessions"`
+	Current        bool       `json:"current"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// EnsureDevice resolves the device cookie to one of the user's devices,
+// registering a new device (and returning a fresh cookie token) when the
+// cookie is missing or belongs to someone else
+func EnsureDevice(db *sql.DB, userID, deviceToken, userAgent string) (deviceID, newToken string, err error) {
+	if deviceToken != "" {
+		err = db.QueryRow(
+			`SELECT BIN_TO_UUID(id) FROM user_devices
+			WHERE token_hash = ? AND user_id = UUID_TO_BIN(?)`,
+			tokens.Hash(deviceToken), userID,
+		).Scan(&deviceID)
+		if err == nil {
+			_, err = db.Exec(
+				"UPDATE user_devices SET last_seen_at = NOW(), user_agent = ? WHERE id = UUID_TO_BIN(?)",
+				truncate(userAgent, 255), deviceID,
+			)
+			return deviceID, "", err
+		}
+		if err != sql.ErrNoRows {
+			return "", "", err
+		}
+	}
+
+	newToken, hash, err := tokens.Generate()
+	if err != nil {
+		return "", "", err
+	}
+	err = db.QueryRow(
+		`INSERT INTO user_devices (id, user_id, token_hash, name, user_agent, last_seen_at)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, ?, NOW())
+		RETURNING BIN_TO_UUID(id)`,
+		userID, hash, DeviceName(userAgent), truncate(userAgent, 255),
+	).Scan(&deviceID)
+	if err != nil {
+		return "", "", err
+	}
+	return deviceID, newToken, nil
+}
+
+// NewID reserves a session id before the refresh token embedding it is signed
+func NewID(db *sql.DB) (string, error) {
+	var id string
+	err := db.QueryRow("SELECT UUID()").Scan(&id)
+	return id, err
+}
+
+// Create opens a session under an id from NewID for a freshly issued refresh token
+func Create(db *sql.DB, sessionID, userID, deviceID, refreshToken, ipAddress, userAgent string, expiresAt time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO sessions (id, user_id, device_id, token_hash, ip_address, user_agent, expires_at, last_seen_at)
+		VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), UUID_TO_BIN(?), ?, ?, ?, ?, NOW())`,
+		sessionID, userID, deviceID, tokens.Hash(refreshToken), ipAddress, truncate(userAgent, 255), expiresAt,
+	)
+	return err
+}
+
+// Rotate swaps the session's refresh token for a new one. Presenting a token
+// that was already rotated out means it leaked, so the session is revoked.
+func Rotate(db *sql.DB, sessionID, oldToken, newToken, ipAddress string, expiresAt time.Time) error {
+	result, err := db.Exec(
+		`UPDATE sessions SET token_hash = ?, ip_address = ?, expires_at = ?, last_seen_at = NOW()
+		WHERE id = UUID_TO_BIN(?) AND token_hash = ? AND expires_at > NOW()`,
+		tokens.Hash(newToken), ipAddress, expiresAt, sessionID, tokens.Hash(oldToken),
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		Revoke(db, sessionID)
+		return ErrRevoked
+	}
+	_, err = db.Exec(
+		`UPDATE user_devices SET last_seen_at = NOW()
+		WHERE id = (SELECT device_id FROM sessions WHERE id = UUID_TO_BIN(?))`,
+		sessionID,
+	)
+	return err
+}
+
+// Active reports whether the session still exists for the user
+func Active(db *sql.DB, sessionID, userID string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM sessions
+		WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?) AND expires_at > NOW()`,
+		sessionID, userID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+func Revoke(db *sql.DB, sessionID string) error {
+	_, err := db.Exec("DELETE FROM sessions WHERE id = UUID_TO_BIN(?)", sessionID)
+	return err
+}
+
+// RevokeForUser ends one of the user's sessions
+func RevokeForUser(db *sql.DB, userID, sessionID string) error {
+	result, err := db.Exec(
+		"DELETE FROM sessions WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)",
+		sessionID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// List returns the user's unexpired sessions, most recently used first.
+// currentSessionID marks the caller's own session.
+func List(db *sql.DB, userID, currentSessionID string) ([]Session, error) {
+	rows, err := db.Query(
+		`SELECT BIN_TO_UUID(s.id), BIN_TO_UUID(s.device_id), COALESCE(d.name, ''),
+		COALESCE(s.ip_address, ''), COALESCE(s.user_agent, d.user_agent, ''),
+		s.last_seen_at, s.expires_at, s.created_at
+		FROM sessions s
+		LEFT JOIN user_devices d ON d.id = s.device_id
+		WHERE s.user_id = UUID_TO_BIN(?) AND s.expires_at > NOW()
+		ORDER BY COALESCE(s.last_seen_at, s.created_at) DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(
+			&s.ID, &s.DeviceID, &s.DeviceName, &s.IPAddress, &s.UserAgent, &s.LastSeenAt, &s.ExpiresAt, &s.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if s.DeviceName == "" {
+			s.DeviceName = DeviceName(s.UserAgent)
+		}
+		s.Current = s.ID == currentSessionID
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// DeviceForSession returns the device a session was opened on
+func DeviceForSession(db *sql.DB, sessionID string) (string, error) {
+	var deviceID sql.NullString
+	err := db.QueryRow(
+		"SELECT BIN_TO_UUID(device_id) FROM sessions WHERE id = UUID_TO_BIN(?)",
+		sessionID,
+	).Scan(&deviceID)
+	return deviceID.String, err
+}
+
+// RevokeDevice ends every session on the device and withdraws any MFA trust
+func RevokeDevice(db *sql.DB, userID, deviceID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE user_devices SET trusted_until = NULL
+		WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)`,
+		deviceID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		// No change can also mean the device exists but was not trusted
+		var exists int
+		err := tx.QueryRow(
+			"SELECT COUNT(*) FROM user_devices WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?)",
+			deviceID, userID,
+		).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return ErrNotFound
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM sessions WHERE device_id = UUID_TO_BIN(?)", deviceID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListDevices returns devices with a live session or MFA trust, most recently
+// used first. currentDeviceID marks the caller's own device.
+func ListDevices(db *sql.DB, userID, currentDeviceID string) ([]Device, error) {
+	rows, err := db.Query(
+		`SELECT BIN_TO_UUID(d.id), d.name, COALESCE(d.user_agent, ''), d.last_seen_at,
+		IF(d.trusted_until > NOW(), d.trusted_until, NULL), d.created_at,
+		(SELECT COUNT(*) FROM sessions s WHERE s.device_id = d.id AND s.expires_at > NOW()) AS active_sessions
+		FROM user_devices d
+		WHERE d.user_id = UUID_TO_BIN(?)
+		HAVING active_sessions > 0 OR d.trusted_until > NOW()
+		ORDER BY d.last_seen_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []Device{}
+	for rows.Next() {
+		var d Device
+		if err := rows.Scan(
+			&d.ID, &d.Name, &d.UserAgent, &d.LastSeenAt, &d.TrustedUntil, &d.CreatedAt, &d.ActiveSessions,
+		); err != nil {
+			return nil, err
+		}
+		d.Trusted = d.TrustedUntil != nil
+		d.Current = d.ID == currentDeviceID
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// DeviceName gives a readable label such as "Chrome on Android" from a user agent
+func DeviceName(userAgent string) string {
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"}, {"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	os := "unknown OS"
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"}, {"iPhone", "iPhone"}, {"iPad", "iPad"},
+		{"Windows", "Windows"}, {"Mac OS X", "macOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	return browser + " on " + os
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
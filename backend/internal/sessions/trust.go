@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"database/sql"
+	"time"
+
+	"saferelief/internal/tokens"
+)
+
+// Trust lets the device skip the MFA prompt until the given time, as long as
+// it keeps presenting the same user agent
+func Trust(db *sql.DB, deviceID, userAgent string, until time.Time) error {
+	_, err := db.Exec(
+		"UPDATE user_devices SET trusted_until = ?, trusted_agent_hash = ? WHERE id = UUID_TO_BIN(?)",
+		until, tokens.Hash(userAgent), deviceID,
+	)
+	return err
+}
+
+// Trusted reports whether the device cookie belongs to one of the user's
+// devices that is trusted for MFA from this user agent
+func Trusted(db *sql.DB, userID, deviceToken, userAgent string) (bool, error) {
+	if deviceToken == "" {
+		return false, nil
+	}
+	var trusted bool
+	err := db.QueryRow(
+		`SELECT EXISTS (
+			SELECT 1 FROM user_devices
+			WHERE token_hash = ? AND user_id = UUID_TO_BIN(?)
+			AND trusted_until > NOW() AND trusted_agent_hash = ?
+		)`,
+		tokens.Hash(deviceToken), userID, tokens.Hash(userAgent),
+	).Scan(&trusted)
+	return trusted, err
+}
+
+// Untrust withdraws one device's MFA trust without signing it out
+func Untrust(db *sql.DB, userID, deviceID string) error {
+	result, err := db.Exec(
+		`UPDATE user_devices SET trusted_until = NULL, trusted_agent_hash = NULL
+		WHERE id = UUID_TO_BIN(?) AND user_id = UUID_TO_BIN(?) AND trusted_until > NOW()`,
+		deviceID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UntrustAll withdraws MFA trust from every device of the user and returns
+// how many were trusted
+func UntrustAll(db *sql.DB, userID string) (int64, error) {
+	result, err := db.Exec(
+		`UPDATE user_devices SET trusted_until = NULL, trusted_agent_hash = NULL
+		WHERE user_id = UUID_TO_BIN(?) AND trusted_until IS NOT NULL`,
+		userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,169 @@
+// Package sso lets staff of partner organizations sign in through their
+// organization's OpenID Connect identity provider
+package sso
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNotConfigured = errors.New("organization has no SSO configuration")
+	ErrDomainInUse   = errors.New("email domain is already claimed by another organization")
+)
+
+// Config is one organization's identity provider. Group names from the
+// GroupsClaim of the ID token map to organization permissions; everyone the
+// provider signs in also gets DefaultPermissions.
+type Config struct {
+	OrganizationID     string              `json:"organizationId"`
+	Issuer             string              `json:"issuer"`
+	ClientID           string              `json:"clientId"`
+	ClientSecret       string              `json:"-"`
+	EmailDomains       []string            `json:"emailDomains"`
+	GroupsClaim        string              `json:"groupsClaim"`
+	GroupPermissions   map[string][]string `json:"groupPermissions"`
+	DefaultPermissions []string            `json:"defaultPermissions"`
+	Enabled            bool                `json:"enabled"`
+}
+
+func LoadConfig(db *sql.DB, organizationID string) (*Config, error) {
+	cfg := Config{OrganizationID: organizationID}
+	var domains, groupPermissions, defaultPermissions []byte
+	err := db.QueryRow(
+		`SELECT issuer, client_id, client_secret, email_domains, groups_claim,
+			group_permissions, default_permissions, enabled
+		FROM organization_sso_configs WHERE organization_id = UUID_TO_BIN(?)`,
+		organizationID,
+	).Scan(&cfg.Issuer, &cfg.ClientID, &cfg.ClientSecret, &domains, &cfg.GroupsClaim,
+		&groupPermissions, &defaultPermissions, &cfg.Enabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(domains, &cfg.EmailDomains)
+	json.Unmarshal(groupPermissions, &cfg.GroupPermissions)
+	json.Unmarshal(defaultPermissions, &cfg.DefaultPermissions)
+	return &cfg, nil
+}
+
+// SaveConfig creates or replaces the organization's configuration. An email
+// domain can belong to only one organization, since it decides where
+// sign-in by email is routed.
+func SaveConfig(db *sql.DB, cfg *Config, updatedBy string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, domain := range cfg.EmailDomains {
+		var claimed int
+		err := tx.QueryRow(
+			`SELECT COUNT(*) FROM organization_sso_configs
+			WHERE organization_id <> UUID_TO_BIN(?) AND JSON_CONTAINS(email_domains, JSON_QUOTE(?))`,
+			cfg.OrganizationID, domain,
+		).Scan(&claimed)
+		if err != nil {
+			return err
+		}
+		if claimed > 0 {
+			return ErrDomainInUse
+		}
+	}
+
+	domains, _ := json.Marshal(cfg.EmailDomains)
+	groupPermissions, _ := json.Marshal(cfg.GroupPermissions)
+	defaultPermissions, _ := json.Marshal(cfg.DefaultPermissions)
+	_, err = tx.Exec(
+		`INSERT INTO organization_sso_configs (organization_id, issuer, client_id, client_secret,
+			email_domains, groups_claim, group_permissions, default_permissions, enabled, updated_by)
+		VALUES (UUID_TO_BIN(?), ?, ?, ?, ?, ?, ?, ?, ?, UUID_TO_BIN(?))
+		ON DUPLICATE KEY UPDATE issuer = VALUES(issuer), client_id = VALUES(client_id),
+			client_secret = VALUES(client_secret), email_domains = VALUES(email_domains),
+			groups_claim = VALUES(groups_claim), group_permissions = VALUES(group_permissions),
+			default_permissions = VALUES(default_permissions), enabled = VALUES(enabled),
+			updated_by = VALUES(updated_by), updated_at = NOW()`,
+		cfg.OrganizationID, cfg.Issuer, cfg.ClientID, cfg.ClientSecret, domains, cfg.GroupsClaim,
+		groupPermissions, defaultPermissions, cfg.Enabled, updatedBy,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func DeleteConfig(db *sql.DB, organizationID string) error {
+	result, err := db.Exec(
+		"DELETE FROM organization_sso_configs WHERE organization_id = UUID_TO_BIN(?)",
+		organizationID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotConfigured
+	}
+	return nil
+}
+
+// OrganizationForEmail finds the enabled configuration whose domains cover
+// the address
+func OrganizationForEmail(db *sql.DB, email string) (string, error) {
+	domain := EmailDomain(email)
+	if domain == "" {
+		return "", ErrNotConfigured
+	}
+	var organizationID string
+	err := db.QueryRow(
+		`SELECT BIN_TO_UUID(organization_id) FROM organization_sso_configs
+		WHERE enabled AND JSON_CONTAINS(email_domains, JSON_QUOTE(?))`,
+		domain,
+	).Scan(&organizationID)
+	if err == sql.ErrNoRows {
+		return "", ErrNotConfigured
+	}
+	return organizationID, err
+}
+
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// AllowsEmail reports whether accounts may be provisioned for the address
+func (c *Config) AllowsEmail(email string) bool {
+	domain := EmailDomain(email)
+	for _, d := range c.EmailDomains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the organization permissions for a user in groups
+func (c *Config) Permissions(groups []string) []string {
+	seen := map[string]bool{}
+	var permissions []string
+	add := func(list []string) {
+		for _, p := range list {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	add(c.DefaultPermissions)
+	for _, group := range groups {
+		add(c.GroupPermissions[group])
+	}
+	return permissions
+}
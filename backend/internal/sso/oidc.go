@@ -0,0 +1,360 @@
+package sso
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// metadataTTL is how long discovery documents and signing keys are reused
+	metadataTTL = time.Hour
+	// keyRefreshInterval limits refetching the key set for unknown key IDs
+	keyRefreshInterval = time.Minute
+)
+
+// Identity is the verified subject of an ID token
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	fetchedAt             time.Time
+}
+
+type keySet struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// Client talks to identity providers and caches their metadata
+type Client struct {
+	http *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*discovery
+	keys      map[string]*keySet
+}
+
+func NewClient() *Client {
+	return &Client{
+		http:      &http.Client{Timeout: 10 * time.Second},
+		providers: make(map[string]*discovery),
+		keys:      make(map[string]*keySet),
+	}
+}
+
+// ValidIssuer accepts https URLs, and http only for a provider on localhost
+// during development
+func ValidIssuer(issuer string) bool {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+	return u.Scheme == "https" || (u.Scheme == "http" && u.Hostname() == "localhost")
+}
+
+// AuthURL is where the browser is sent to sign in
+func (c *Client) AuthURL(ctx context.Context, cfg *Config, redirectURI string, login *Login) (string, error) {
+	d, err := c.discover(ctx, cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", "openid email profile")
+	q.Set("state", login.State)
+	q.Set("nonce", login.Nonce)
+	q.Set("code_challenge", login.CodeChallenge())
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems the authorization code and verifies the returned ID token
+func (c *Client) Exchange(ctx context.Context, cfg *Config, redirectURI, code string, login *Login) (*Identity, error) {
+	d, err := c.discover(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {login.CodeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return c.verify(ctx, cfg, d, token.IDToken, login.Nonce)
+}
+
+func (c *Client) verify(ctx context.Context, cfg *Config, d *discovery, idToken, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return c.key(ctx, d.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("id token: %w", err)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("id token has no expiry")
+	}
+	if claimString(claims, "nonce") != nonce {
+		return nil, errors.New("id token nonce does not match")
+	}
+	// With several audiences the token must name us as its authorized party
+	if aud, _ := claims.GetAudience(); len(aud) > 1 && claimString(claims, "azp") != cfg.ClientID {
+		return nil, errors.New("id token azp does not match")
+	}
+
+	identity := &Identity{
+		Issuer:  d.Issuer,
+		Subject: claimString(claims, "sub"),
+		Email:   strings.ToLower(claimString(claims, "email")),
+		Name:    claimString(claims, "name"),
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+	// Some providers send email_verified as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+	groupsClaim := cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	switch v := claims[groupsClaim].(type) {
+	case string:
+		identity.Groups = []string{v}
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	}
+	return identity, nil
+}
+
+func claimString(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+func (c *Client) discover(ctx context.Context, issuer string) (*discovery, error) {
+	c.mu.Lock()
+	d, ok := c.providers[issuer]
+	c.mu.Unlock()
+	if ok && time.Since(d.fetchedAt) < metadataTTL {
+		return d, nil
+	}
+
+	d = &discovery{}
+	if err := c.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", d); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return nil, fmt.Errorf("discovery: issuer %q does not match configured %q", d.Issuer, issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery: document is missing endpoints")
+	}
+	d.fetchedAt = time.Now()
+
+	c.mu.Lock()
+	c.providers[issuer] = d
+	c.mu.Unlock()
+	return d, nil
+}
+
+// key finds the signing key by ID, refetching the key set when the provider
+// has rotated to a key we have not seen
+func (c *Client) key(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	c.mu.Lock()
+	set, ok := c.keys[jwksURI]
+	c.mu.Unlock()
+
+	if ok {
+		if key, found := set.lookup(kid); found && time.Since(set.fetchedAt) < metadataTTL {
+			return key, nil
+		}
+		if time.Since(set.fetchedAt) < keyRefreshInterval {
+			if key, found := set.lookup(kid); found {
+				return key, nil
+			}
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := c.getJSON(ctx, jwksURI, &document); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	set = &keySet{keys: make(map[string]interface{}), fetchedAt: time.Now()}
+	for _, k := range document.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			set.keys[k.Kid] = key
+		}
+	}
+
+	c.mu.Lock()
+	c.keys[jwksURI] = set
+	c.mu.Unlock()
+
+	if key, found := set.lookup(kid); found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup matches by key ID; a token without one may use a lone key
+func (s *keySet) lookup(kid string) (interface{}, bool) {
+	if key, ok := s.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Client) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,171 @@
+package sso
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"saferelief/internal/accounts"
+)
+
+var (
+	ErrEmailNotAllowed = errors.New("email domain is not covered by the organization's SSO configuration")
+	ErrEmailUnverified = errors.New("identity provider has not verified the email address")
+	ErrNoAccess        = errors.New("identity provider groups grant no organization permissions")
+	ErrAccountDisabled = errors.New("account is deleted")
+	// ErrLinkRequired means the email belongs to an account an identity
+	// provider must not take over on its own; the owner links it while
+	// signed in
+	ErrLinkRequired  = errors.New("account must be linked from a signed-in session")
+	ErrIdentityTaken = errors.New("identity is linked to another account")
+)
+
+// Account is the local user an identity signed in as
+type Account struct {
+	UserID      string
+	Role        string
+	Created     bool
+	Permissions []string
+}
+
+// Provision finds or creates the local user for a verified identity and
+// brings their organization membership in line with the provider's groups.
+// An identity is tied to its user on first sign-in, by email when an account
+// already exists; after that the provider's subject decides, so a later email
+// change at the provider does not move the link. Privileged and MFA-protected
+// accounts are never matched by email: their owners link them while signed
+// in, which is when linkUserID is set.
+func Provision(ctx context.Context, db *sql.DB, cfg *Config, identity *Identity, linkUserID string) (*Account, error) {
+	permissions := cfg.Permissions(identity.Groups)
+	if len(permissions) == 0 {
+		return nil, ErrNoAccess
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	account := &Account{Permissions: permissions}
+	err = tx.QueryRowContext(ctx,
+		`SELECT BIN_TO_UUID(user_id) FROM sso_identities
+		WHERE organization_id = UUID_TO_BIN(?) AND issuer = ? AND subject = ? FOR UPDATE`,
+		cfg.OrganizationID, identity.Issuer, identity.Subject,
+	).Scan(&account.UserID)
+	switch {
+	case err == sql.ErrNoRows:
+		if err := link(ctx, tx, cfg, identity, account, linkUserID); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case linkUserID != "" && account.UserID != linkUserID:
+		return nil, ErrIdentityTaken
+	default:
+		_, err := tx.ExecContext(ctx,
+			`UPDATE sso_identities SET last_login_at = NOW()
+			WHERE organization_id = UUID_TO_BIN(?) AND issuer = ? AND subject = ?`,
+			cfg.OrganizationID, identity.Issuer, identity.Subject,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT role, deleted_at FROM users WHERE id = UUID_TO_BIN(?)",
+		account.UserID,
+	).Scan(&account.Role, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		return nil, ErrAccountDisabled
+	}
+
+	// The provider's groups are authoritative: permissions are replaced on
+	// every sign-in. The owner already holds every permission.
+	var ownerID string
+	err = tx.QueryRowContext(ctx,
+		"SELECT BIN_TO_UUID(owner_id) FROM organizations WHERE id = UUID_TO_BIN(?)",
+		cfg.OrganizationID,
+	).Scan(&ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != account.UserID {
+		encoded, _ := json.Marshal(permissions)
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO organization_members (organization_id, user_id, permissions, granted_by)
+			VALUES (UUID_TO_BIN(?), UUID_TO_BIN(?), ?, UUID_TO_BIN(?))
+			ON DUPLICATE KEY UPDATE permissions = VALUES(permissions), updated_at = NOW()`,
+			cfg.OrganizationID, account.UserID, encoded, ownerID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return account, tx.Commit()
+}
+
+// link ties a first-time identity to linkUserID when set, otherwise to the
+// account with its email, creating the account if there is none
+func link(ctx context.Context, tx *sql.Tx, cfg *Config, identity *Identity, account *Account, linkUserID string) error {
+	if !cfg.AllowsEmail(identity.Email) {
+		return ErrEmailNotAllowed
+	}
+	if !identity.EmailVerified {
+		return ErrEmailUnverified
+	}
+
+	var role string
+	var mfaEnabled bool
+	var err error
+	if linkUserID != "" {
+		account.UserID = linkUserID
+	} else {
+		err = tx.QueryRowContext(ctx,
+			"SELECT BIN_TO_UUID(id), role, mfa_enabled FROM users WHERE email = ?",
+			identity.Email,
+		).Scan(&account.UserID, &role, &mfaEnabled)
+	}
+	if err == nil && linkUserID == "" && (accounts.Privileged(role) || mfaEnabled) {
+		return ErrLinkRequired
+	}
+	if err == sql.ErrNoRows {
+		name := identity.Name
+		if name == "" {
+			name = strings.SplitN(identity.Email, "@", 2)[0]
+		}
+		username, err := accounts.AvailableUsername(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		// Staff sign in only through the provider, so there is no usable password
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO users (id, username, email, email_verified_at, password_hash, status,
+			last_password_change, created_at, updated_at)
+			VALUES (UUID_TO_BIN(UUID()), ?, ?, NOW(), '!', 'active', NOW(), NOW(), NOW())
+			RETURNING BIN_TO_UUID(id)`,
+			username, identity.Email,
+		).Scan(&account.UserID)
+		if err != nil {
+			return err
+		}
+		account.Created = true
+	} else if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO sso_identities (id, organization_id, issuer, subject, user_id, last_login_at)
+		VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, UUID_TO_BIN(?), NOW())`,
+		cfg.OrganizationID, identity.Issuer, identity.Subject, account.UserID,
+	)
+	return err
+}
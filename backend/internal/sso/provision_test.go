@@ -0,0 +1,115 @@
+package sso
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"saferelief/internal/fakedb"
+)
+
+const (
+	testOrganization = "11111111-1111-1111-1111-111111111111"
+	testUser         = "22222222-2222-2222-2222-222222222222"
+	testOtherUser    = "33333333-3333-3333-3333-333333333333"
+	testOwner        = "44444444-4444-4444-4444-444444444444"
+)
+
+var testConfig = &Config{
+	OrganizationID:     testOrganization,
+	EmailDomains:       []string{"relief.example"},
+	DefaultPermissions: []string{"reports:create"},
+	Enabled:            true,
+}
+
+func testIdentity() *Identity {
+	return &Identity{
+		Issuer:        "https://idp.relief.example",
+		Subject:       "staff-1",
+		Email:         "staff@relief.example",
+		EmailVerified: true,
+	}
+}
+
+// provisionRules answers Provision's queries for a first-time identity
+// whose email belongs to an existing account
+func provisionRules(role string, mfaEnabled bool) []fakedb.Rule {
+	return []fakedb.Rule{
+		{Match: "FROM sso_identities"},
+		{Match: "FROM users WHERE email", Rows: [][]driver.Value{{testUser, role, mfaEnabled}}},
+		{Match: "INSERT INTO sso_identities"},
+		{Match: "SELECT role, deleted_at FROM users", Rows: [][]driver.Value{{role, nil}}},
+		{Match: "FROM organizations", Rows: [][]driver.Value{{testOwner}}},
+		{Match: "INSERT INTO organization_members"},
+	}
+}
+
+func TestProvisionLinksOrdinaryAccountByEmail(t *testing.T) {
+	db, f := fakedb.New(t, provisionRules("user", false)...)
+	account, err := Provision(context.Background(), db, testConfig, testIdentity(), "")
+	if err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	if account.UserID != testUser || account.Created {
+		t.Errorf("got user %s created %v, want the existing %s", account.UserID, account.Created, testUser)
+	}
+	if !f.Ran("INSERT INTO sso_identities") || !f.Ran("COMMIT") {
+		t.Errorf("identity was not linked: %v", f.Log)
+	}
+}
+
+func TestProvisionRefusesToLinkProtectedAccountByEmail(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		mfaEnabled bool
+	}{
+		{"admin", "admin", false},
+		{"verifier", "verifier", false},
+		{"mfa enabled", "user", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := fakedb.New(t, provisionRules(tt.role, tt.mfaEnabled)...)
+			_, err := Provision(context.Background(), db, testConfig, testIdentity(), "")
+			if !errors.Is(err, ErrLinkRequired) {
+				t.Fatalf("got error %v, want ErrLinkRequired", err)
+			}
+			if f.Ran("INSERT INTO sso_identities") || f.Ran("COMMIT") {
+				t.Errorf("identity was linked: %v", f.Log)
+			}
+		})
+	}
+}
+
+func TestProvisionLinksSignedInAccount(t *testing.T) {
+	db, f := fakedb.New(t, provisionRules("admin", true)...)
+	account, err := Provision(context.Background(), db, testConfig, testIdentity(), testUser)
+	if err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	if account.UserID != testUser {
+		t.Errorf("linked to %s, want %s", account.UserID, testUser)
+	}
+	if f.Ran("FROM users WHERE email") {
+		t.Error("an explicit link looked the account up by email")
+	}
+	if !f.Ran("INSERT INTO sso_identities") || !f.Ran("COMMIT") {
+		t.Errorf("identity was not linked: %v", f.Log)
+	}
+}
+
+func TestProvisionRefusesIdentityLinkedElsewhere(t *testing.T) {
+	rules := append([]fakedb.Rule{
+		{Match: "FROM sso_identities", Rows: [][]driver.Value{{testOtherUser}}},
+	}, provisionRules("user", false)...)
+	db, f := fakedb.New(t, rules...)
+	_, err := Provision(context.Background(), db, testConfig, testIdentity(), testUser)
+	if !errors.Is(err, ErrIdentityTaken) {
+		t.Fatalf("got error %v, want ErrIdentityTaken", err)
+	}
+	if f.Ran("COMMIT") {
+		t.Errorf("provisioning committed: %v", f.Log)
+	}
+}
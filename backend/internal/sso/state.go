@@ -0,0 +1,94 @@
+package sso
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"saferelief/internal/tokens"
+)
+
+// loginTTL bounds how long a user may spend at the identity provider
+const loginTTL = 10 * time.Minute
+
+var ErrLoginNotFound = errors.New("SSO login not found or expired")
+
+// Login is an authorization request in flight. The state travels through the
+// provider; the nonce and PKCE verifier stay here and are checked when the
+// provider redirects back.
+type Login struct {
+	State          string
+	OrganizationID string
+	Nonce          string
+	CodeVerifier   string
+	RedirectPath   string
+	// LinkUserID is set when a signed-in user started the flow to link the
+	// identity to their account rather than to sign in
+	LinkUserID string
+}
+
+// CodeChallenge is the S256 PKCE challenge for the verifier
+func (l *Login) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(l.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// BeginLogin starts an authorization request. linkUserID is empty for a
+// sign-in.
+func BeginLogin(db *sql.DB, organizationID, redirectPath, linkUserID string) (*Login, error) {
+	login := &Login{OrganizationID: organizationID, RedirectPath: redirectPath, LinkUserID: linkUserID}
+	for _, field := range []*string{&login.State, &login.Nonce, &login.CodeVerifier} {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		*field = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	// Abandoned logins are cleared as new ones start
+	if _, err := db.Exec("DELETE FROM sso_login_states WHERE expires_at < NOW()"); err != nil {
+		return nil, err
+	}
+	_, err := db.Exec(
+		`INSERT INTO sso_login_states (state_hash, organization_id, nonce, code_verifier, redirect_path, link_user_id, expires_at)
+		VALUES (?, UUID_TO_BIN(?), ?, ?, ?, UUID_TO_BIN(NULLIF(?, '')), ?)`,
+		tokens.Hash(login.State), organizationID, login.Nonce, login.CodeVerifier, redirectPath, linkUserID,
+		time.Now().Add(loginTTL),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return login, nil
+}
+
+// ConsumeLogin returns the login for state and deletes it, so a callback URL
+// works only once
+func ConsumeLogin(db *sql.DB, state string) (*Login, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	login := &Login{State: state}
+	var linkUserID sql.NullString
+	err = tx.QueryRow(
+		`SELECT BIN_TO_UUID(organization_id), nonce, code_verifier, redirect_path, BIN_TO_UUID(link_user_id)
+		FROM sso_login_states WHERE state_hash = ? AND expires_at > NOW() FOR UPDATE`,
+		tokens.Hash(state),
+	).Scan(&login.OrganizationID, &login.Nonce, &login.CodeVerifier, &login.RedirectPath, &linkUserID)
+	if err == sql.ErrNoRows {
+		return nil, ErrLoginNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	login.LinkUserID = linkUserID.String
+	if _, err := tx.Exec("DELETE FROM sso_login_states WHERE state_hash = ?", tokens.Hash(state)); err != nil {
+		return nil, err
+	}
+	return login, tx.Commit()
+}
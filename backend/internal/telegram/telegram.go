@@ -0,0 +1,175 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const apiBase = "https://api.telegram.org"
+
+var ErrFileTooLarge = errors.New("telegram file exceeds the size limit")
+
+// SecretHeader carries the secret token Telegram echoes back on every
+// webhook call, set when the webhook is registered with setWebhook
+const SecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+type Message struct {
+	MessageID int64       `json:"message_id"`
+	Chat      Chat        `json:"chat"`
+	From      *User       `json:"from"`
+	Text      string      `json:"text"`
+	Caption   string      `json:"caption"`
+	Location  *Location   `json:"location"`
+	Photo     []PhotoSize `json:"photo"`
+}
+
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type PhotoSize struct {
+	FileID   string `json:"file_id"`
+	FileSize int64  `json:"file_size"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// Bot talks to the Telegram Bot API. A bot without a token is disabled and
+// its methods are no-ops.
+type Bot struct {
+	token    string
+	username string
+	secret   string
+	client   *http.Client
+}
+
+func NewBotFromEnv() *Bot {
+	return &Bot{
+		token:    os.Getenv("TELEGRAM_BOT_TOKEN"),
+		username: os.Getenv("TELEGRAM_BOT_USERNAME"),
+		secret:   os.Getenv("TELEGRAM_WEBHOOK_SECRET"),
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (b *Bot) Enabled() bool {
+	return b.token != "" && b.secret != ""
+}
+
+// VerifyRequest checks the webhook secret so only Telegram can post updates
+func (b *Bot) VerifyRequest(r *http.Request) bool {
+	got := r.Header.Get(SecretHeader)
+	return b.Enabled() && subtle.ConstantTimeCompare([]byte(got), []byte(b.secret)) == 1
+}
+
+// DeepLink opens a chat with the bot that sends "/start <payload>"
+func (b *Bot) DeepLink(payload string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", b.username, payload)
+}
+
+func (b *Bot) SendMessage(ctx context.Context, chatID int64, text string) error {
+	if !b.Enabled() {
+		return nil
+	}
+	return b.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}, nil)
+}
+
+// Download fetches a file sent to the bot, refusing anything over maxSize
+func (b *Bot) Download(ctx context.Context, fileID string, maxSize int64) ([]byte, string, error) {
+	var file struct {
+		FilePath string `json:"file_path"`
+		FileSize int64  `json:"file_size"`
+	}
+	if err := b.call(ctx, "getFile", map[string]string{"file_id": fileID}, &file); err != nil {
+		return nil, "", err
+	}
+	if file.FileSize > maxSize {
+		return nil, "", ErrFileTooLarge
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/file/bot%s/%s", apiBase, b.token, file.FilePath), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("telegram file download failed with %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", ErrFileTooLarge
+	}
+	return data, file.FilePath, nil
+}
+
+func (b *Bot) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/bot%s/%s", apiBase, b.token, method), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return err
+	}
+	if !envelope.OK {
+		return fmt.Errorf("telegram %s failed: %s", method, envelope.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(envelope.Result, result)
+	}
+	return nil
+}
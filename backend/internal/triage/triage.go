@@ -0,0 +1,160 @@
+// Package triage gives incoming disaster reports a priority score from 0 to
+// 100 so verifiers look at the likely-urgent ones first. The score is a hint
+// for ordering the queue, not a verdict on the report.
+package triage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Most points each signal can contribute; they add up to 100
+const (
+	maxKeywordPoints    = 35
+	maxAttachmentPoints = 15
+	maxReputationPoints = 25
+	maxDensityPoints    = 25
+
+	pointsPerAttachment = 5
+	pointsPerNearby     = 5
+
+	// Reports this close and this recent count towards density
+	densityRadiusMeters = 10000
+	densityWindowHours  = 24
+
+	// A new report also rescores this many pending reports around it
+	maxRescoredNeighbors = 20
+)
+
+// urgentKeywords weighs words that point to people in danger, in Indonesian
+// and English. Each keyword counts once however often it appears.
+var urgentKeywords = map[string]int{
+	"meninggal": 15, "tewas": 15, "korban jiwa": 15, "dead": 15, "killed": 15, "casualties": 15,
+	"terjebak": 12, "tertimbun": 12, "trapped": 12, "buried": 12,
+	"hilang": 10, "missing": 10, "luka": 8, "injured": 8, "injuries": 8,
+	"runtuh": 8, "ambruk": 8, "collapsed": 8, "roboh": 8,
+	"evakuasi": 6, "evacuate": 6, "evacuation": 6, "mengungsi": 6, "pengungsi": 6, "refugees": 6,
+	"anak": 4, "children": 4, "lansia": 4, "elderly": 4,
+	"darurat": 5, "emergency": 5, "urgent": 5, "segera": 3,
+}
+
+// Signals are the inputs to a score
+type Signals struct {
+	Text             string
+	Attachments      int
+	ReporterVerified int
+	ReporterTotal    int
+	NearbyReports    int
+}
+
+// Breakdown is a score with the points each signal contributed, so a
+// verifier can see why a report ranks where it does
+type Breakdown struct {
+	Keywords    int      `json:"keywords"`
+	Matched     []string `json:"matched,omitempty"`
+	Attachments int      `json:"attachments"`
+	Reputation  int      `json:"reputation"`
+	Density     int      `json:"density"`
+	Total       int      `json:"total"`
+}
+
+// TrustScore is a reporter's verification rate with a Laplace prior, so a
+// brand-new reporter starts at 50 and a single report can't swing it to 0 or 100
+func TrustScore(verified, total int) int {
+	return int(math.Round(100 * float64(verified+1) / float64(total+2)))
+}
+
+// Compute scores a set of signals
+func Compute(s Signals) Breakdown {
+	var b Breakdown
+	text := strings.ToLower(s.Text)
+	for keyword, points := range urgentKeywords {
+		if strings.Contains(text, keyword) {
+			b.Keywords += points
+			b.Matched = append(b.Matched, keyword)
+		}
+	}
+	sort.Strings(b.Matched)
+	b.Keywords = min(b.Keywords, maxKeywordPoints)
+	b.Attachments = min(s.Attachments*pointsPerAttachment, maxAttachmentPoints)
+	b.Reputation = TrustScore(s.ReporterVerified, s.ReporterTotal) * maxReputationPoints / 100
+	b.Density = min(s.NearbyReports*pointsPerNearby, maxDensityPoints)
+	b.Total = b.Keywords + b.Attachments + b.Reputation + b.Density
+	return b
+}
+
+// Score gathers a report's signals, stores its score and returns it
+func Score(ctx context.Context, db *sql.DB, reportID string) (Breakdown, error) {
+	var s Signals
+	var title, description string
+	err := db.QueryRowContext(ctx,
+		`SELECT dr.title, dr.description,
+		(SELECT COUNT(*) FROM file_uploads f WHERE f.disaster_report_id = dr.id),
+		(SELECT COUNT(*) FROM disaster_reports p
+			WHERE p.reporter_id = dr.reporter_id AND p.id != dr.id AND p.deleted_at IS NULL
+			AND p.status IN ('verified', 'in_progress', 'resolved', 'closed')),
+		(SELECT COUNT(*) FROM disaster_reports p
+			WHERE p.reporter_id = dr.reporter_id AND p.id != dr.id AND p.deleted_at IS NULL
+			AND p.status != 'pending'),
+		(SELECT COUNT(*) FROM disaster_reports n
+			WHERE n.id != dr.id AND n.deleted_at IS NULL AND n.status != 'rejected'
+			AND n.created_at BETWEEN dr.created_at - INTERVAL ? HOUR AND dr.created_at + INTERVAL ? HOUR
+			AND ST_Distance_Sphere(n.location, dr.location) <= ?)
+		FROM disaster_reports dr WHERE dr.id = UUID_TO_BIN(?)`,
+		densityWindowHours, densityWindowHours, densityRadiusMeters, reportID,
+	).Scan(&title, &description, &s.Attachments, &s.ReporterVerified, &s.ReporterTotal, &s.NearbyReports)
+	if err != nil {
+		return Breakdown{}, err
+	}
+	s.Text = title + "\n" + description
+
+	b := Compute(s)
+	encoded, _ := json.Marshal(b)
+	// Scoring is bookkeeping, so it leaves updated_at alone
+	_, err = db.ExecContext(ctx,
+		"UPDATE disaster_reports SET priority_score = ?, priority_factors = ?, updated_at = updated_at WHERE id = UUID_TO_BIN(?)",
+		b.Total, encoded, reportID,
+	)
+	return b, err
+}
+
+// ScoreNew scores a newly filed report, then rescores the pending reports
+// around it because their density has gone up
+func ScoreNew(ctx context.Context, db *sql.DB, reportID string) (Breakdown, error) {
+	b, err := Score(ctx, db, reportID)
+	if err != nil {
+		return b, err
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT BIN_TO_UUID(n.id) FROM disaster_reports n
+		JOIN disaster_reports dr ON dr.id = UUID_TO_BIN(?)
+		WHERE n.id != dr.id AND n.status = 'pending' AND n.deleted_at IS NULL
+		AND n.created_at >= dr.created_at - INTERVAL ? HOUR
+		AND ST_Distance_Sphere(n.location, dr.location) <= ?
+		ORDER BY n.created_at DESC LIMIT ?`,
+		reportID, densityWindowHours, densityRadiusMeters, maxRescoredNeighbors,
+	)
+	if err != nil {
+		return b, err
+	}
+	var neighbors []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			neighbors = append(neighbors, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range neighbors {
+		if _, err := Score(ctx, db, id); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,94 @@
+package validation
+
+import (
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"saferelief/internal/apierror"
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	phonePattern    = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validator collects field errors so a client gets every problem with a
+// request in one response
+type Validator struct {
+	Errors []FieldError
+}
+
+func New() *Validator {
+	return &Validator{}
+}
+
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+func (v *Validator) AddError(field, message string) {
+	v.Errors = append(v.Errors, FieldError{Field: field, Message: message})
+}
+
+// Check records message for field when ok is false
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.AddError(field, message)
+	}
+}
+
+func (v *Validator) Required(field, value string) bool {
+	ok := strings.TrimSpace(value) != ""
+	v.Check(ok, field, "is required")
+	return ok
+}
+
+func (v *Validator) Length(field, value string, min, max int) {
+	n := utf8.RuneCountInString(value)
+	v.Check(n >= min && n <= max, field, "must be between "+strconv.Itoa(min)+" and "+strconv.Itoa(max)+" characters")
+}
+
+func (v *Validator) Email(field, value string) {
+	addr, err := mail.ParseAddress(value)
+	v.Check(err == nil && addr.Address == value && len(value) <= 255, field, "must be a valid email address")
+}
+
+// Phone accepts numbers in international E.164 form, such as +6281234567890
+func (v *Validator) Phone(field, value string) {
+	v.Check(phonePattern.MatchString(value), field, "must be an international number such as +6281234567890")
+}
+
+func (v *Validator) Username(field, value string) {
+	if !v.Required(field, value) {
+		return
+	}
+	v.Length(field, value, 3, 50)
+	v.Check(usernamePattern.MatchString(value), field, "may only contain letters, numbers, '.', '_' and '-'")
+}
+
+// Password enforces the minimum strength for new passwords; bcrypt ignores
+// anything past 72 bytes so longer values are rejected
+func (v *Validator) Password(field, value string) {
+	if !v.Required(field, value) {
+		return
+	}
+	v.Check(len(value) >= 8 && len(value) <= 72, field, "must be between 8 and 72 characters")
+	hasLetter := strings.IndexFunc(value, unicode.IsLetter) >= 0
+	hasDigit := strings.IndexFunc(value, unicode.IsDigit) >= 0
+	v.Check(hasLetter && hasDigit, field, "must contain at least one letter and one number")
+}
+
+// WriteError responds with 400 and the collected field errors as details
+func (v *Validator) WriteError(w http.ResponseWriter) {
+	apierror.Write(w, http.StatusBadRequest, apierror.CodeValidationFailed, "Validation failed", v.Errors)
+}
@@ -0,0 +1,44 @@
+package webhooks
+
+import "saferelief/internal/audit"
+
+// securityEvents maps audit events to the webhook events account owners can
+// subscribe to
+var securityEvents = map[string]string{
+	audit.EventLoginFailed:     EventSecurityLoginFailed,
+	audit.EventAccountLocked:   EventSecurityAccountLocked,
+	audit.EventPasswordChanged: EventSecurityPasswordChanged,
+	audit.EventDeviceRevoked:   EventSecurityDeviceRevoked,
+	audit.EventEmailChanged:    EventSecurityEmailChanged,
+	audit.EventUserSuspended:   EventSecurityAccountSuspended,
+	audit.EventUserBanned:      EventSecurityAccountSuspended,
+}
+
+// ForwardSecurityEvents publishes security-relevant audit events to the
+// affected account's webhooks for as long as the process runs
+func ForwardSecurityEvents(logger *audit.Logger, d *Dispatcher) {
+	events := logger.Subscribe()
+	go func() {
+		for event := range events {
+			name, ok := securityEvents[event.Type]
+			if !ok {
+				continue
+			}
+			userID := event.UserID
+			if event.EntityType == "user" && event.EntityID != "" {
+				userID = event.EntityID
+			}
+			if userID == "" {
+				continue
+			}
+			d.Publish(name, Recipients{UserIDs: []string{userID}}, map[string]interface{}{
+				"userId":    userID,
+				"type":      event.Type,
+				"severity":  event.Severity,
+				"ipAddress": event.IPAddress,
+				"userAgent": event.UserAgent,
+				"timestamp": event.Timestamp,
+			})
+		}
+	}()
+}
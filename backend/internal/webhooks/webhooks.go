@@ -0,0 +1,418 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"saferelief/internal/events"
+	"saferelief/internal/jobs"
+)
+
+// Event names a subscriber can filter on; "*" matches every event
+const (
+	EventDonationCreated       = "donation.created"
+	EventDonationStatusChanged = "donation.status_changed"
+	EventReportCreated         = "report.created"
+	EventReportVerified        = "report.verified"
+	EventReportStatusChanged   = "report.status_changed"
+
+	EventSecurityLoginFailed      = "security.login_failed"
+	EventSecurityAccountLocked    = "security.account_locked"
+	EventSecurityPasswordChanged  = "security.password_changed"
+	EventSecurityDeviceRevoked    = "security.device_revoked"
+	EventSecurityEmailChanged     = "security.email_changed"
+	EventSecurityAccountSuspended = "security.account_suspended"
+
+	AllEvents = "*"
+)
+
+var knownEvents = map[string]bool{
+	EventDonationCreated: true, EventDonationStatusChanged: true,
+	EventReportCreated: true, EventReportVerified: true, EventReportStatusChanged: true,
+	EventSecurityLoginFailed: true, EventSecurityAccountLocked: true,
+	EventSecurityPasswordChanged: true, EventSecurityDeviceRevoked: true,
+	EventSecurityEmailChanged: true, EventSecurityAccountSuspended: true,
+	AllEvents: true,
+}
+
+const (
+	SignatureHeader = "X-SafeRelief-Signature"
+	TimestampHeader = "X-SafeRelief-Timestamp"
+	EventHeader     = "X-SafeRelief-Event"
+	DeliveryHeader  = "X-SafeRelief-Delivery"
+
+	deliveryTimeout = 10 * time.Second
+	// A queued delivery not finished within this is picked up again by the
+	// sweep, so deliveries lost with a full queue or a restart still go out
+	deliveryLease = 10 * time.Minute
+	sweepBatch    = 100
+)
+
+// retrySchedule is how long to wait after each failed attempt. A delivery
+// that fails once more after the last wait is marked failed.
+var retrySchedule = []time.Duration{
+	time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour,
+}
+
+var (
+	ErrNotFound         = errors.New("webhook delivery not found")
+	ErrForbiddenAddress = errors.New("webhook target resolves to a private or local address")
+)
+
+func ValidEvent(event string) bool {
+	return knownEvents[event]
+}
+
+// Endpoint scopes. Own endpoints receive events about their owner's reports
+// and donations; all-reports endpoints, for partner systems, also receive
+// report events for every report.
+const (
+	ScopeOwn        = "own"
+	ScopeAllReports = "all_reports"
+)
+
+// IsReportEvent reports whether event is about a report's lifecycle
+func IsReportEvent(event string) bool {
+	return strings.HasPrefix(event, "report.")
+}
+
+// ValidateURL accepts only absolute https URLs; the target address is checked
+// again when the delivery connects
+func ValidateURL(raw string) error {
+	if len(raw) > 2048 {
+		return errors.New("URL is too long")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("URL must be an absolute https URL")
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "timestamp.body" under the endpoint
+// secret. Receivers recompute it to verify the payload and reject replays
+// with a stale timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Recipients selects which registered endpoints receive an event
+type Recipients struct {
+	UserIDs        []string
+	OrganizationID string
+}
+
+// Dispatcher records a delivery per matching endpoint and sends it on the
+// job queue. Failed attempts are retried on retrySchedule; the next attempt
+// time is stored with the delivery so retries survive a restart.
+type Dispatcher struct {
+	db     *sql.DB
+	queue  *jobs.Queue
+	client *http.Client
+	stream *events.Stream
+}
+
+func NewDispatcher(db *sql.DB, queue *jobs.Queue) *Dispatcher {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: rejectPrivateAddress}
+	return &Dispatcher{
+		db:    db,
+		queue: queue,
+		client: &http.Client{
+			Timeout:   deliveryTimeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// SetStream mirrors report and donation events to an external event stream
+func (d *Dispatcher) SetStream(stream *events.Stream) {
+	d.stream = stream
+}
+
+// Publish queues event for every active endpoint owned by the recipients
+// that subscribes to it. Failures are logged; they never fail the caller.
+func (d *Dispatcher) Publish(event string, to Recipients, data interface{}) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":      event,
+		"occurredAt": time.Now().UTC(),
+		"data":       data,
+	})
+	if err != nil {
+		log.Printf("Failed to encode webhook event %s: %v", event, err)
+		return
+	}
+
+	if events.IsDomainEvent(event) {
+		var key string
+		if fields, ok := data.(map[string]interface{}); ok {
+			key, _ = fields["id"].(string)
+		}
+		d.stream.Emit(event, key, data)
+	}
+
+	for _, endpointID := range d.matchingEndpoints(event, to) {
+		var deliveryID string
+		err := d.db.QueryRow(
+			`INSERT INTO webhook_deliveries (id, endpoint_id, event, payload, next_attempt_at)
+			VALUES (UUID_TO_BIN(UUID()), UUID_TO_BIN(?), ?, ?, NOW() + INTERVAL ? SECOND)
+			RETURNING BIN_TO_UUID(id)`,
+			endpointID, event, payload, int(deliveryLease.Seconds()),
+		).Scan(&deliveryID)
+		if err != nil {
+			log.Printf("Failed to record webhook delivery for endpoint %s: %v", endpointID, err)
+			continue
+		}
+		d.enqueue(deliveryID)
+	}
+}
+
+// Redeliver resets a delivery and sends it again with a fresh retry budget
+func (d *Dispatcher) Redeliver(deliveryID string) error {
+	result, err := d.db.Exec(
+		`UPDATE webhook_deliveries SET status = 'pending', attempts = 0, last_error = NULL,
+		next_attempt_at = NOW() + INTERVAL ? SECOND
+		WHERE id = UUID_TO_BIN(?)`,
+		int(deliveryLease.Seconds()), deliveryID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	d.enqueue(deliveryID)
+	return nil
+}
+
+// Start resends deliveries whose retry is due, or whose queued attempt was
+// lost, every interval until the process exits
+func (d *Dispatcher) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			d.sweep()
+		}
+	}()
+}
+
+func (d *Dispatcher) sweep() {
+	rows, err := d.db.Query(
+		`SELECT BIN_TO_UUID(id) FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at LIMIT ?`,
+		sweepBatch,
+	)
+	if err != nil {
+		log.Printf("Failed to load due webhook deliveries: %v", err)
+		return
+	}
+	var due []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			due = append(due, id)
+		}
+	}
+	rows.Close()
+
+	for _, deliveryID := range due {
+		// Extending the lease claims the delivery, so another instance
+		// sweeping at the same time skips it
+		result, err := d.db.Exec(
+			`UPDATE webhook_deliveries SET next_attempt_at = NOW() + INTERVAL ? SECOND
+			WHERE id = UUID_TO_BIN(?) AND status = 'pending' AND next_attempt_at <= NOW()`,
+			int(deliveryLease.Seconds()), deliveryID,
+		)
+		if err != nil {
+			continue
+		}
+		if claimed, _ := result.RowsAffected(); claimed == 1 {
+			d.enqueue(deliveryID)
+		}
+	}
+}
+
+// matchingEndpoints finds the active endpoints owned by the recipients, and
+// for report events the partner endpoints subscribed to every report, that
+// subscribe to event
+func (d *Dispatcher) matchingEndpoints(event string, to Recipients) []string {
+	var ids []string
+	query := `SELECT BIN_TO_UUID(id) FROM webhook_endpoints
+		WHERE active = TRUE
+		AND (JSON_CONTAINS(events, JSON_QUOTE(?)) OR JSON_CONTAINS(events, '"*"'))
+		AND `
+
+	owners := []struct{ column, id string }{}
+	for _, userID := range to.UserIDs {
+		owners = append(owners, struct{ column, id string }{"user_id", userID})
+	}
+	if to.OrganizationID != "" {
+		owners = append(owners, struct{ column, id string }{"organization_id", to.OrganizationID})
+	}
+
+	seen := map[string]bool{}
+	collect := func(condition string, args ...interface{}) {
+		rows, err := d.db.Query(query+condition, append([]interface{}{event}, args...)...)
+		if err != nil {
+			log.Printf("Failed to look up webhook endpoints for %s: %v", event, err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			if rows.Scan(&id) == nil && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	for _, owner := range owners {
+		collect(owner.column+" = UUID_TO_BIN(?)", owner.id)
+	}
+	if IsReportEvent(event) {
+		collect("scope = ?", ScopeAllReports)
+	}
+	return ids
+}
+
+// enqueue makes one attempt on the job queue. A delivery that cannot be
+// queued keeps its lease and is picked up by the sweep once it runs out.
+func (d *Dispatcher) enqueue(deliveryID string) {
+	err := d.queue.Enqueue(jobs.Job{
+		Name:        "webhook-delivery-" + deliveryID,
+		MaxAttempts: 1,
+		Run: func(ctx context.Context) error {
+			return d.deliver(ctx, deliveryID)
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to queue webhook delivery %s: %v", deliveryID, err)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, deliveryID string) error {
+	var endpointURL, secret, event string
+	var payload []byte
+	var active bool
+	var attempts int
+	err := d.db.QueryRowContext(ctx,
+		`SELECT e.url, e.secret, e.active, wd.event, wd.payload, wd.attempts
+		FROM webhook_deliveries wd
+		JOIN webhook_endpoints e ON e.id = wd.endpoint_id
+		WHERE wd.id = UUID_TO_BIN(?) AND wd.status = 'pending'`,
+		deliveryID,
+	).Scan(&endpointURL, &secret, &active, &event, &payload, &attempts)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !active {
+		d.record(deliveryID, "failed", 0, "endpoint disabled", nil)
+		return nil
+	}
+
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(payload))
+	if err != nil {
+		d.record(deliveryID, "failed", 0, err.Error(), nil)
+		return nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SafeRelief-Webhooks/1.0")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		d.retry(deliveryID, attempts, 0, err)
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+		d.retry(deliveryID, attempts, resp.StatusCode, err)
+		return err
+	}
+	d.record(deliveryID, "succeeded", resp.StatusCode, "", nil)
+	return nil
+}
+
+// retry records a failed attempt and schedules the next one, or marks the
+// delivery failed once retrySchedule is spent. attempts counts the attempts
+// before this one.
+func (d *Dispatcher) retry(deliveryID string, attempts, responseStatus int, cause error) {
+	if attempts >= len(retrySchedule) {
+		d.record(deliveryID, "failed", responseStatus, cause.Error(), nil)
+		return
+	}
+	next := time.Now().Add(retrySchedule[attempts])
+	d.record(deliveryID, "pending", responseStatus, cause.Error(), &next)
+}
+
+// record stores the outcome of one attempt on the delivery log. next is
+// when to try again; nil clears it.
+func (d *Dispatcher) record(deliveryID, status string, responseStatus int, lastError string, next *time.Time) {
+	_, err := d.db.Exec(
+		`UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, response_status = NULLIF(?, 0),
+		last_error = NULLIF(?, ''), last_attempt_at = NOW(), next_attempt_at = ?,
+		delivered_at = IF(? = 'succeeded', NOW(), delivered_at)
+		WHERE id = UUID_TO_BIN(?)`,
+		status, responseStatus, truncate(lastError, 500), next, status, deliveryID,
+	)
+	if err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", deliveryID, err)
+	}
+}
+
+// rejectPrivateAddress stops deliveries from reaching internal services once
+// DNS has been resolved, so a hostname cannot be pointed at them later
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
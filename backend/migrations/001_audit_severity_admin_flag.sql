@@ -0,0 +1,10 @@
+-- Security events carry a severity and admin routes need an admin flag
+USE saferelief_db;
+
+ALTER TABLE users
+    ADD COLUMN is_admin BOOLEAN DEFAULT FALSE AFTER status;
+
+ALTER TABLE audit_logs
+    ADD COLUMN severity ENUM('LOW', 'MEDIUM', 'HIGH', 'CRITICAL') NOT NULL DEFAULT 'LOW' AFTER action,
+    ADD INDEX idx_severity (severity),
+    ADD INDEX idx_ip_address (ip_address);
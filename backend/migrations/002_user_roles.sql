@@ -0,0 +1,19 @@
+-- Replace the is_admin flag with a role column and backfill existing users
+USE saferelief_db;
+
+ALTER TABLE users
+    ADD COLUMN role ENUM('donor', 'reporter', 'verifier', 'admin') NOT NULL DEFAULT 'donor' AFTER status,
+    ADD INDEX idx_role (role);
+
+-- Users who have verified reports become verifiers
+UPDATE users SET role = 'verifier'
+WHERE id IN (SELECT DISTINCT verified_by FROM disaster_reports WHERE verified_by IS NOT NULL);
+
+-- Users who have filed reports (and aren't verifiers) become reporters
+UPDATE users SET role = 'reporter'
+WHERE role = 'donor'
+AND id IN (SELECT DISTINCT reporter_id FROM disaster_reports);
+
+UPDATE users SET role = 'admin' WHERE is_admin = TRUE;
+
+ALTER TABLE users DROP COLUMN is_admin;
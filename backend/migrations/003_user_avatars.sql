@@ -0,0 +1,17 @@
+-- Profile avatars stored through the uploads subsystem
+USE saferelief_db;
+
+ALTER TABLE users
+    ADD COLUMN avatar_url VARCHAR(255) AFTER email;
+
+CREATE TABLE IF NOT EXISTS uploads (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    original_name VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    mime_type VARCHAR(127) NOT NULL,
+    path VARCHAR(512) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB;
@@ -0,0 +1,7 @@
+-- Soft delete with deferred anonymization
+USE saferelief_db;
+
+ALTER TABLE users
+    ADD COLUMN deleted_at DATETIME AFTER role,
+    ADD COLUMN anonymized_at DATETIME AFTER deleted_at,
+    ADD INDEX idx_deleted_at (deleted_at);
@@ -0,0 +1,5 @@
+-- Track username changes to enforce the change cooldown
+USE saferelief_db;
+
+ALTER TABLE users
+    ADD COLUMN username_changed_at DATETIME AFTER username;
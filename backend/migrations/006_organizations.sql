@@ -0,0 +1,25 @@
+-- NGO registry and report attribution
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id BINARY(16) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    registration_number VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    website VARCHAR(255),
+    owner_id BINARY(16) NOT NULL,
+    verification_status ENUM('unverified', 'pending', 'verified', 'rejected') NOT NULL DEFAULT 'unverified',
+    verification_documents JSON,
+    review_note TEXT,
+    verified_by BINARY(16),
+    verified_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id) REFERENCES users(id),
+    FOREIGN KEY (verified_by) REFERENCES users(id),
+    INDEX idx_verification_status (verification_status)
+) ENGINE=InnoDB;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN organization_id BINARY(16) AFTER reporter_id,
+    ADD FOREIGN KEY (organization_id) REFERENCES organizations(id);
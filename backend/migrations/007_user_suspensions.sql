@@ -0,0 +1,18 @@
+-- Account suspensions and bans
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS user_suspensions (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    kind ENUM('suspension', 'ban') NOT NULL,
+    reason TEXT NOT NULL,
+    expires_at DATETIME,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    lifted_at DATETIME,
+    lifted_by BINARY(16),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (lifted_by) REFERENCES users(id),
+    INDEX idx_user_active (user_id, lifted_at, expires_at)
+) ENGINE=InnoDB;
@@ -0,0 +1,25 @@
+-- Policy versions and user consent records
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS policy_versions (
+    id BINARY(16) PRIMARY KEY,
+    document ENUM('privacy', 'terms') NOT NULL,
+    version VARCHAR(32) NOT NULL,
+    url VARCHAR(255) NOT NULL,
+    effective_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_document_version (document, version),
+    INDEX idx_document_effective (document, effective_at)
+) ENGINE=InnoDB;
+
+-- Record of which policy versions each user agreed to and when
+CREATE TABLE IF NOT EXISTS user_consents (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    document ENUM('privacy', 'terms') NOT NULL,
+    version VARCHAR(32) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    consented_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE KEY uq_user_document_version (user_id, document, version)
+) ENGINE=InnoDB;
@@ -0,0 +1,6 @@
+-- Locale and timezone preferences for emails and scheduled notifications
+USE saferelief_db;
+
+ALTER TABLE users
+    ADD COLUMN locale VARCHAR(10) NOT NULL DEFAULT 'id' AFTER avatar_url,
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Jakarta' AFTER locale;
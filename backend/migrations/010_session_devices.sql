@@ -0,0 +1,22 @@
+-- Device registry backing session revocation and MFA trust
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS user_devices (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    user_agent VARCHAR(255),
+    trusted_until DATETIME,
+    last_seen_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_last_seen (user_id, last_seen_at)
+) ENGINE=InnoDB;
+
+-- Sessions opened before this migration carry no device; signing in again fixes that
+ALTER TABLE sessions
+    ADD COLUMN device_id BINARY(16) AFTER user_id,
+    ADD COLUMN ip_address VARCHAR(45) AFTER token_hash,
+    ADD COLUMN last_seen_at DATETIME AFTER expires_at,
+    ADD FOREIGN KEY (device_id) REFERENCES user_devices(id) ON DELETE CASCADE;
@@ -0,0 +1,37 @@
+-- Bulk staff imports and invitations
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS user_imports (
+    id BINARY(16) PRIMARY KEY,
+    created_by BINARY(16) NOT NULL,
+    status ENUM('queued', 'processing', 'completed', 'failed') NOT NULL DEFAULT 'queued',
+    total INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS user_import_rows (
+    import_id BINARY(16) NOT NULL,
+    line_number INT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    status ENUM('pending', 'created', 'failed') NOT NULL DEFAULT 'pending',
+    user_id BINARY(16),
+    error VARCHAR(255),
+    PRIMARY KEY (import_id, line_number),
+    FOREIGN KEY (import_id) REFERENCES user_imports(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- Invitations for accounts created on someone's behalf
+CREATE TABLE IF NOT EXISTS user_invitations (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,15 @@
+-- Delegated organization staff access
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id BINARY(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    permissions JSON NOT NULL,
+    granted_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (granted_by) REFERENCES users(id)
+) ENGINE=InnoDB;
@@ -0,0 +1,32 @@
+-- Volunteer registry and requests
+USE saferelief_db;
+
+-- Volunteer registry
+CREATE TABLE IF NOT EXISTS volunteer_profiles (
+    user_id BINARY(16) PRIMARY KEY,
+    skills JSON NOT NULL,
+    certifications JSON NOT NULL,
+    region VARCHAR(100) NOT NULL,
+    availability ENUM('available', 'limited', 'unavailable') NOT NULL DEFAULT 'available',
+    notes TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_region_availability (region, availability)
+) ENGINE=InnoDB;
+
+-- Coordinator requests for volunteers on a report and the volunteer's answer
+CREATE TABLE IF NOT EXISTS volunteer_requests (
+    id BINARY(16) PRIMARY KEY,
+    disaster_report_id BINARY(16) NOT NULL,
+    volunteer_id BINARY(16) NOT NULL,
+    requested_by BINARY(16) NOT NULL,
+    message TEXT,
+    status ENUM('pending', 'accepted', 'declined') NOT NULL DEFAULT 'pending',
+    responded_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (disaster_report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (volunteer_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (requested_by) REFERENCES users(id),
+    UNIQUE KEY uq_report_volunteer (disaster_report_id, volunteer_id)
+) ENGINE=InnoDB;
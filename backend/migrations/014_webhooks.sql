@@ -0,0 +1,37 @@
+-- Outbound webhooks
+USE saferelief_db;
+
+-- Outbound webhook endpoints owned by a user or an organization
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16),
+    organization_id BINARY(16),
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    events JSON NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    INDEX idx_user (user_id),
+    INDEX idx_organization (organization_id)
+) ENGINE=InnoDB;
+
+-- Delivery log; one row per event sent to an endpoint
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BINARY(16) PRIMARY KEY,
+    endpoint_id BINARY(16) NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    payload JSON NOT NULL,
+    status ENUM('pending', 'succeeded', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    response_status INT,
+    last_error VARCHAR(500),
+    last_attempt_at DATETIME,
+    delivered_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    INDEX idx_endpoint_created (endpoint_id, created_at)
+) ENGINE=InnoDB;
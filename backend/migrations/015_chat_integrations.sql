@@ -0,0 +1,29 @@
+-- Slack/Discord alert integrations
+USE saferelief_db;
+
+-- Slack/Discord channels that receive report alerts
+CREATE TABLE IF NOT EXISTS chat_integrations (
+    id BINARY(16) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    provider ENUM('slack', 'discord') NOT NULL,
+    webhook_url VARCHAR(512) NOT NULL,
+    alerts JSON NOT NULL,
+    min_severity ENUM('low', 'medium', 'high', 'critical') NOT NULL DEFAULT 'low',
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    funding_milestones JSON NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- Funding milestones already announced, so each is posted once per channel
+CREATE TABLE IF NOT EXISTS chat_alert_milestones (
+    integration_id BINARY(16) NOT NULL,
+    disaster_report_id BINARY(16) NOT NULL,
+    milestone DECIMAL(14,2) NOT NULL,
+    sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (integration_id, disaster_report_id, milestone),
+    FOREIGN KEY (integration_id) REFERENCES chat_integrations(id) ON DELETE CASCADE,
+    FOREIGN KEY (disaster_report_id) REFERENCES disaster_reports(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,29 @@
+-- Weekly digest subscriptions and followed regions
+USE saferelief_db;
+
+ALTER TABLE disaster_reports ADD COLUMN verified_at DATETIME AFTER verified_by;
+UPDATE disaster_reports SET verified_at = updated_at WHERE status = 'verified';
+
+-- Weekly digest preferences; users without a row are not subscribed
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    user_id BINARY(16) PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    new_disasters BOOLEAN NOT NULL DEFAULT TRUE,
+    funding_progress BOOLEAN NOT NULL DEFAULT TRUE,
+    delivery_updates BOOLEAN NOT NULL DEFAULT TRUE,
+    last_sent_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Areas a user follows for new verified disasters
+CREATE TABLE IF NOT EXISTS followed_regions (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    latitude DECIMAL(10,8) NOT NULL,
+    longitude DECIMAL(11,8) NOT NULL,
+    radius_km DECIMAL(6,2) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user (user_id)
+) ENGINE=InnoDB;
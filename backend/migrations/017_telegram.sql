@@ -0,0 +1,32 @@
+-- Telegram bot account links and report drafts
+USE saferelief_db;
+
+-- Telegram chats linked to SafeRelief accounts
+CREATE TABLE IF NOT EXISTS telegram_links (
+    chat_id BIGINT PRIMARY KEY,
+    user_id BINARY(16) NOT NULL UNIQUE,
+    telegram_username VARCHAR(64),
+    alerts_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    linked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS telegram_link_codes (
+    user_id BINARY(16) PRIMARY KEY,
+    code_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Reports being composed step by step in a Telegram chat
+CREATE TABLE IF NOT EXISTS telegram_report_drafts (
+    chat_id BIGINT PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    step ENUM('title', 'location', 'severity', 'photo') NOT NULL,
+    title VARCHAR(255),
+    latitude DECIMAL(10,8),
+    longitude DECIMAL(11,8),
+    severity ENUM('low', 'medium', 'high', 'critical'),
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
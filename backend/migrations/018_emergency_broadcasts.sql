@@ -0,0 +1,40 @@
+-- Geo-targeted emergency broadcasts
+USE saferelief_db;
+
+-- Last known location reported by the user's device
+CREATE TABLE IF NOT EXISTS user_locations (
+    user_id BINARY(16) PRIMARY KEY,
+    latitude DECIMAL(10,8) NOT NULL,
+    longitude DECIMAL(11,8) NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Geo-targeted emergency broadcasts and their per-recipient delivery state
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id BINARY(16) PRIMARY KEY,
+    title VARCHAR(150) NOT NULL,
+    message TEXT NOT NULL,
+    area JSON NOT NULL,
+    channels JSON NOT NULL,
+    status ENUM('queued', 'sending', 'completed', 'failed') NOT NULL DEFAULT 'queued',
+    recipient_count INT NOT NULL DEFAULT 0,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS broadcast_recipients (
+    broadcast_id BINARY(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    status ENUM('pending', 'sent', 'failed') NOT NULL DEFAULT 'pending',
+    last_error VARCHAR(500),
+    sent_at DATETIME,
+    PRIMARY KEY (broadcast_id, user_id, channel),
+    FOREIGN KEY (broadcast_id) REFERENCES broadcasts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_broadcast_status (broadcast_id, status)
+) ENGINE=InnoDB;
@@ -0,0 +1,15 @@
+-- Localized notification templates
+USE saferelief_db;
+
+-- Versioned notification content; the highest version per key and locale is sent
+CREATE TABLE IF NOT EXISTS notification_templates (
+    template_key VARCHAR(64) NOT NULL,
+    locale VARCHAR(10) NOT NULL,
+    version INT NOT NULL,
+    subject VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    created_by BINARY(16),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_key, locale, version),
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB;
@@ -0,0 +1,19 @@
+-- Email delivery log for the notification dashboard
+USE saferelief_db;
+
+-- Outgoing templated email; the body is kept only until the message is sent
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id BINARY(16) PRIMARY KEY,
+    channel VARCHAR(20) NOT NULL DEFAULT 'email',
+    template_key VARCHAR(64) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT,
+    status ENUM('pending', 'sent', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(500),
+    last_attempt_at DATETIME,
+    sent_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_status_created (status, created_at)
+) ENGINE=InnoDB;
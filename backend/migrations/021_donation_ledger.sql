@@ -0,0 +1,31 @@
+-- Merkle ledger of completed donations
+USE saferelief_db;
+
+ALTER TABLE donations ADD COLUMN completed_at DATETIME AFTER payment_method;
+UPDATE donations SET completed_at = updated_at WHERE status = 'completed';
+
+-- Hash-chained batches of completed donations; the chain hash is anchored externally
+CREATE TABLE IF NOT EXISTS ledger_batches (
+    id BINARY(16) PRIMARY KEY,
+    sequence INT NOT NULL UNIQUE,
+    merkle_root CHAR(64) NOT NULL,
+    chain_hash CHAR(64) NOT NULL,
+    leaf_count INT NOT NULL,
+    anchor_method VARCHAR(32),
+    anchor_ref TEXT,
+    anchor_error TEXT,
+    anchored_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    entry_type VARCHAR(20) NOT NULL,
+    entry_id BINARY(16) NOT NULL,
+    batch_id BINARY(16) NOT NULL,
+    leaf_index INT NOT NULL,
+    leaf_hash CHAR(64) NOT NULL,
+    leaf_data VARCHAR(255) NOT NULL,
+    PRIMARY KEY (entry_type, entry_id),
+    FOREIGN KEY (batch_id) REFERENCES ledger_batches(id),
+    UNIQUE KEY uniq_batch_leaf (batch_id, leaf_index)
+) ENGINE=InnoDB;
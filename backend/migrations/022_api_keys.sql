@@ -0,0 +1,26 @@
+-- API keys and daily usage for the open-data API
+USE saferelief_db;
+
+-- Keys for programmatic clients; only the SHA-256 of the key is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes JSON NOT NULL,
+    daily_quota INT NOT NULL,
+    last_used_at DATETIME,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user (user_id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    key_id BINARY(16) NOT NULL,
+    day DATE NOT NULL,
+    requests INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day),
+    FOREIGN KEY (key_id) REFERENCES api_keys(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,14 @@
+-- Cross-checks of reports against BNPB records
+USE saferelief_db;
+
+-- Latest comparison of each report with official disaster records
+CREATE TABLE IF NOT EXISTS report_crosschecks (
+    report_id BINARY(16) PRIMARY KEY,
+    source VARCHAR(32) NOT NULL,
+    status ENUM('matched', 'no_match', 'failed') NOT NULL,
+    best_score DECIMAL(4,3),
+    matches JSON,
+    last_error VARCHAR(500),
+    checked_at DATETIME NOT NULL,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,16 @@
+-- Email bounce and complaint handling
+USE saferelief_db;
+
+ALTER TABLE users
+    ADD COLUMN email_verified_at DATETIME AFTER email,
+    ADD COLUMN email_bounced_at DATETIME AFTER email_verified_at;
+
+-- Addresses that hard-bounced or complained; nothing is sent to them
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    email VARCHAR(255) PRIMARY KEY,
+    reason ENUM('hard_bounce', 'complaint') NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    detail VARCHAR(500),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
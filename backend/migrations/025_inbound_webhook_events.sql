@@ -0,0 +1,21 @@
+-- Unified inbound webhook receiver
+USE saferelief_db;
+
+-- Raw callbacks from payment, SMS and email providers, archived before
+-- processing; (provider, event_id) makes redeliveries idempotent
+CREATE TABLE IF NOT EXISTS inbound_webhook_events (
+    id BINARY(16) PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    event_id VARCHAR(128) NOT NULL,
+    headers JSON,
+    payload MEDIUMBLOB NOT NULL,
+    status ENUM('pending', 'processing', 'processed', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1000),
+    received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    processed_at DATETIME,
+    UNIQUE KEY uq_inbound_event (provider, event_id),
+    INDEX idx_inbound_status (status, updated_at),
+    INDEX idx_inbound_received (received_at)
+) ENGINE=InnoDB;
@@ -0,0 +1,13 @@
+-- MFA backup/recovery codes
+USE saferelief_db;
+
+-- One-time MFA recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY uniq_user_code (user_id, code_hash)
+) ENGINE=InnoDB;
@@ -0,0 +1,47 @@
+-- OpenID Connect single sign-on for partner organizations
+USE saferelief_db;
+
+-- Per-organization OpenID Connect identity provider for staff sign-in
+CREATE TABLE IF NOT EXISTS organization_sso_configs (
+    organization_id BINARY(16) PRIMARY KEY,
+    issuer VARCHAR(255) NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    client_secret VARCHAR(512) NOT NULL,
+    email_domains JSON NOT NULL,
+    groups_claim VARCHAR(64) NOT NULL DEFAULT 'groups',
+    group_permissions JSON NOT NULL,
+    default_permissions JSON NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (updated_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- Provider subjects linked to local users
+CREATE TABLE IF NOT EXISTS sso_identities (
+    id BINARY(16) PRIMARY KEY,
+    organization_id BINARY(16) NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    last_login_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_sso_subject (organization_id, issuer, subject),
+    INDEX idx_user (user_id)
+) ENGINE=InnoDB;
+
+-- Sign-ins in flight at a provider; the state is stored as a SHA-256 hash
+CREATE TABLE IF NOT EXISTS sso_login_states (
+    state_hash CHAR(64) PRIMARY KEY,
+    organization_id BINARY(16) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(64) NOT NULL,
+    redirect_path VARCHAR(512) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    INDEX idx_expires_at (expires_at)
+) ENGINE=InnoDB;
@@ -0,0 +1,6 @@
+-- Per-session user agent for the session list; older sessions show none
+USE saferelief_db;
+
+ALTER TABLE sessions
+    ADD COLUMN user_agent VARCHAR(255) AFTER ip_address,
+    ADD INDEX idx_user_expires (user_id, expires_at);
@@ -0,0 +1,5 @@
+-- Index for reading a user's own login history from the audit log
+USE saferelief_db;
+
+ALTER TABLE audit_logs
+    ADD INDEX idx_user_action_created (user_id, action, created_at);
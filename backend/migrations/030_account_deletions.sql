@@ -0,0 +1,17 @@
+-- Trail of account deletions for erasure requests
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    requested_by BINARY(16) NOT NULL,
+    self_service BOOLEAN NOT NULL DEFAULT FALSE,
+    requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    purge_after DATETIME NOT NULL,
+    restored_at DATETIME,
+    anonymized_at DATETIME,
+    files_deleted INT,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (requested_by) REFERENCES users(id),
+    INDEX idx_user_requested (user_id, requested_at)
+) ENGINE=InnoDB;
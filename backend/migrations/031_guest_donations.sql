@@ -0,0 +1,9 @@
+-- Guest donations: no donor account, an email and a claim token instead
+USE saferelief_db;
+
+ALTER TABLE donations
+    MODIFY donor_id BINARY(16),
+    ADD COLUMN guest_email VARCHAR(255) AFTER donor_id,
+    ADD COLUMN claim_token_hash CHAR(64) AFTER guest_email,
+    ADD COLUMN claimed_at DATETIME AFTER claim_token_hash,
+    ADD UNIQUE KEY uq_claim_token (claim_token_hash);
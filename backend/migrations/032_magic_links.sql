@@ -0,0 +1,13 @@
+-- Passwordless sign-in links
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS magic_links (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    requested_ip VARCHAR(45) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_created (user_id, created_at)
+) ENGINE=InnoDB;
@@ -0,0 +1,8 @@
+-- Public profile fields and the standard avatar sizes
+USE saferelief_db;
+
+ALTER TABLE users
+    ADD COLUMN avatar_sizes JSON AFTER avatar_url,
+    ADD COLUMN display_name VARCHAR(100) AFTER avatar_sizes,
+    ADD COLUMN phone VARCHAR(16) AFTER display_name,
+    ADD COLUMN bio VARCHAR(500) AFTER phone;
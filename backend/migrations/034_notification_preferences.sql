@@ -0,0 +1,12 @@
+-- Notification preferences per event type and channel
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BINARY(16) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    channel ENUM('email', 'sms', 'push') NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, event_type, channel),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,11 @@
+-- Expiring download links for data exports
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS data_export_links (
+    token_hash CHAR(64) PRIMARY KEY,
+    export_id BINARY(16) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (export_id) REFERENCES data_exports(id) ON DELETE CASCADE,
+    INDEX idx_export_expires (export_id, expires_at)
+) ENGINE=InnoDB;
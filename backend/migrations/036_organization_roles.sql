@@ -0,0 +1,23 @@
+-- Organization member roles and email invitations
+USE saferelief_db;
+
+ALTER TABLE organization_members
+    ADD COLUMN role ENUM('owner', 'verifier', 'finance', 'staff') NOT NULL DEFAULT 'staff' AFTER user_id;
+
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id BINARY(16) PRIMARY KEY,
+    organization_id BINARY(16) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role ENUM('owner', 'verifier', 'finance', 'staff') NOT NULL,
+    permissions JSON NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    invited_by BINARY(16) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME,
+    accepted_by BINARY(16),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES users(id),
+    FOREIGN KEY (accepted_by) REFERENCES users(id),
+    INDEX idx_org_email (organization_id, email)
+) ENGINE=InnoDB;
@@ -0,0 +1,5 @@
+-- Bind "remember this device" MFA trust to the user agent that earned it
+USE saferelief_db;
+
+ALTER TABLE user_devices
+    ADD COLUMN trusted_agent_hash CHAR(64) AFTER trusted_until;
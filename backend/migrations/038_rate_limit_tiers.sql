@@ -0,0 +1,10 @@
+-- Per-tier rate limit overrides set by admins; tiers without a row use RATE_LIMIT_TIERS
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS rate_limit_tiers (
+    tier VARCHAR(20) PRIMARY KEY,
+    requests_per_minute INT NOT NULL,
+    updated_by BINARY(16),
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (updated_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB;
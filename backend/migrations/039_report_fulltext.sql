@@ -0,0 +1,5 @@
+-- Full-text search over report titles and descriptions
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD FULLTEXT INDEX ft_reports (title, description);
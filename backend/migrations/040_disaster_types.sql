@@ -0,0 +1,27 @@
+-- Disaster type taxonomy and the type of each report
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS disaster_types (
+    code VARCHAR(30) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+INSERT IGNORE INTO disaster_types (code, name) VALUES
+    ('flood', 'Flood'),
+    ('flash_flood', 'Flash flood'),
+    ('earthquake', 'Earthquake'),
+    ('tsunami', 'Tsunami'),
+    ('landslide', 'Landslide'),
+    ('volcanic_eruption', 'Volcanic eruption'),
+    ('fire', 'Fire'),
+    ('forest_fire', 'Forest fire'),
+    ('storm', 'Storm'),
+    ('drought', 'Drought'),
+    ('tidal_flood', 'Tidal flood'),
+    ('other', 'Other');
+
+ALTER TABLE disaster_reports
+    ADD COLUMN disaster_type VARCHAR(30) NOT NULL DEFAULT 'other' AFTER description,
+    ADD CONSTRAINT fk_disaster_reports_type FOREIGN KEY (disaster_type) REFERENCES disaster_types(code),
+    ADD INDEX idx_disaster_type (disaster_type);
@@ -0,0 +1,17 @@
+-- Situation updates posted under reports
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS report_comments (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    author_id BINARY(16) NOT NULL,
+    body TEXT NOT NULL,
+    edited_at DATETIME,
+    deleted_at DATETIME,
+    deleted_by BINARY(16),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(id),
+    FOREIGN KEY (deleted_by) REFERENCES users(id),
+    INDEX idx_report_created (report_id, created_at)
+) ENGINE=InnoDB;
@@ -0,0 +1,5 @@
+-- Report lifecycle statuses for the status state machine
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    MODIFY COLUMN status ENUM('pending', 'verified', 'in_progress', 'resolved', 'closed', 'rejected') DEFAULT 'pending';
@@ -0,0 +1,9 @@
+-- Why and by whom a report was rejected
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN rejection_reason VARCHAR(40) AFTER verified_at,
+    ADD COLUMN rejection_note TEXT AFTER rejection_reason,
+    ADD COLUMN rejected_by BINARY(16) AFTER rejection_note,
+    ADD COLUMN rejected_at DATETIME AFTER rejected_by,
+    ADD CONSTRAINT fk_disaster_reports_rejected_by FOREIGN KEY (rejected_by) REFERENCES users(id);
@@ -0,0 +1,16 @@
+-- Duplicate report candidates and merged reports
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN merged_into BINARY(16) AFTER rejected_at,
+    ADD CONSTRAINT fk_disaster_reports_merged_into FOREIGN KEY (merged_into) REFERENCES disaster_reports(id);
+
+CREATE TABLE IF NOT EXISTS report_duplicate_candidates (
+    report_id BINARY(16) NOT NULL,
+    candidate_id BINARY(16) NOT NULL,
+    distance_m DOUBLE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (report_id, candidate_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id) ON DELETE CASCADE,
+    FOREIGN KEY (candidate_id) REFERENCES disaster_reports(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,8 @@
+-- Soft-deleted reports stay in the table so an admin can restore them
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN deleted_at DATETIME AFTER merged_into,
+    ADD COLUMN deleted_by BINARY(16) AFTER deleted_at,
+    ADD CONSTRAINT fk_disaster_reports_deleted_by FOREIGN KEY (deleted_by) REFERENCES users(id),
+    ADD INDEX idx_deleted_at (deleted_at);
@@ -0,0 +1,30 @@
+-- Append-only history of changes to a report's content and status
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS report_revisions (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    changed_by BINARY(16) NOT NULL,
+    changes JSON NOT NULL,
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (changed_by) REFERENCES users(id),
+    INDEX idx_report_created (report_id, created_at)
+) ENGINE=InnoDB;
+
+DELIMITER //
+CREATE TRIGGER report_revisions_no_update
+BEFORE UPDATE ON report_revisions
+FOR EACH ROW
+BEGIN
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'report_revisions is append-only';
+END//
+
+CREATE TRIGGER report_revisions_no_delete
+BEFORE DELETE ON report_revisions
+FOR EACH ROW
+BEGIN
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'report_revisions is append-only';
+END//
+DELIMITER ;
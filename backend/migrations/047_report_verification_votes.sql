@@ -0,0 +1,18 @@
+-- Verifier votes on pending reports; a report is verified once a quorum approves
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN verification_round INT NOT NULL DEFAULT 1 AFTER merged_into;
+
+CREATE TABLE IF NOT EXISTS report_verification_votes (
+    report_id BINARY(16) NOT NULL,
+    round INT NOT NULL,
+    verifier_id BINARY(16) NOT NULL,
+    decision ENUM('approve', 'reject') NOT NULL,
+    reason VARCHAR(40),
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (report_id, round, verifier_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (verifier_id) REFERENCES users(id)
+) ENGINE=InnoDB;
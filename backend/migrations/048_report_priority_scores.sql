@@ -0,0 +1,7 @@
+-- Priority score computed when a report is filed, used to order the verification queue
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN priority_score TINYINT UNSIGNED AFTER verification_round,
+    ADD COLUMN priority_factors JSON AFTER priority_score,
+    ADD INDEX idx_priority_score (status, priority_score);
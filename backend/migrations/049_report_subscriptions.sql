@@ -0,0 +1,12 @@
+-- Users following a report to hear about its status changes
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS report_subscriptions (
+    report_id BINARY(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (report_id, user_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user (user_id, created_at)
+) ENGINE=InnoDB;
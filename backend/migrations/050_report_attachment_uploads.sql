@@ -0,0 +1,6 @@
+-- Remember which upload a report attachment was copied from, so it is attached once
+USE saferelief_db;
+
+ALTER TABLE file_uploads
+    ADD COLUMN upload_id VARCHAR(36) AFTER storage_path,
+    ADD INDEX idx_report_upload (disaster_report_id, upload_id);
@@ -0,0 +1,4 @@
+-- Let report revisions record changes made by the system, such as expiry
+USE saferelief_db;
+
+ALTER TABLE report_revisions MODIFY changed_by BINARY(16) NULL;
@@ -0,0 +1,24 @@
+-- Abuse flags on reports and hiding reports flagged past the threshold
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN flag_count INT UNSIGNED NOT NULL DEFAULT 0 AFTER priority_factors,
+    ADD COLUMN hidden_at DATETIME AFTER flag_count;
+
+CREATE TABLE IF NOT EXISTS report_flags (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    note TEXT,
+    status ENUM('open', 'upheld', 'dismissed') NOT NULL DEFAULT 'open',
+    resolved_by BINARY(16),
+    resolution_note TEXT,
+    resolved_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_report_user (report_id, user_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (resolved_by) REFERENCES users(id),
+    INDEX idx_status_report (status, report_id)
+) ENGINE=InnoDB;
@@ -0,0 +1,29 @@
+-- Structured item needs per report, with a log of what has been delivered
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS report_needs (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    item VARCHAR(100) NOT NULL,
+    unit VARCHAR(30) NOT NULL,
+    quantity_needed DECIMAL(12,2) NOT NULL,
+    quantity_fulfilled DECIMAL(12,2) NOT NULL DEFAULT 0,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    INDEX idx_report (report_id, created_at)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS report_need_fulfillments (
+    id BINARY(16) PRIMARY KEY,
+    need_id BINARY(16) NOT NULL,
+    quantity DECIMAL(12,2) NOT NULL,
+    note VARCHAR(500),
+    recorded_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (need_id) REFERENCES report_needs(id) ON DELETE CASCADE,
+    FOREIGN KEY (recorded_by) REFERENCES users(id),
+    INDEX idx_need (need_id, created_at)
+) ENGINE=InnoDB;
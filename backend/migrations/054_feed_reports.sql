@@ -0,0 +1,18 @@
+-- Candidate reports filed from agency alert feeds
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN source ENUM('user', 'feed') NOT NULL DEFAULT 'user' AFTER priority_factors;
+
+CREATE TABLE IF NOT EXISTS feed_events (
+    source VARCHAR(20) NOT NULL,
+    external_id VARCHAR(100) NOT NULL,
+    report_id BINARY(16) NOT NULL,
+    disaster_type VARCHAR(30) NOT NULL,
+    magnitude DECIMAL(4,2),
+    occurred_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source, external_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    INDEX idx_type_occurred (disaster_type, occurred_at)
+) ENGINE=InnoDB;
@@ -0,0 +1,13 @@
+-- Persisted webhook retry schedule and partner endpoints for every report
+USE saferelief_db;
+
+ALTER TABLE webhook_endpoints
+    ADD COLUMN scope ENUM('own', 'all_reports') NOT NULL DEFAULT 'own' AFTER events,
+    ADD INDEX idx_scope (scope);
+
+ALTER TABLE webhook_deliveries
+    ADD COLUMN next_attempt_at DATETIME AFTER last_attempt_at,
+    ADD INDEX idx_status_next (status, next_attempt_at);
+
+-- Deliveries waiting on the old in-memory retries are picked up by the sweep
+UPDATE webhook_deliveries SET next_attempt_at = NOW() WHERE status = 'pending';
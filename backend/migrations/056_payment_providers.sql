@@ -0,0 +1,7 @@
+-- Donations collected through a payment gateway such as Midtrans
+USE saferelief_db;
+
+ALTER TABLE donations
+    ADD COLUMN payment_provider VARCHAR(20) AFTER payment_method,
+    ADD COLUMN provider_transaction_id VARCHAR(100) AFTER payment_provider,
+    ADD INDEX idx_provider_transaction (payment_provider, transaction_id);
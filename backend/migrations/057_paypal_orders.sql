@@ -0,0 +1,5 @@
+-- PayPal orders are captured by the server once the donor approves them
+USE saferelief_db;
+
+ALTER TABLE donations
+    ADD COLUMN provider_order_id VARCHAR(100) AFTER payment_provider;
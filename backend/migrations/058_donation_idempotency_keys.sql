@@ -0,0 +1,13 @@
+-- Idempotency-Key replays for donation creation
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS donation_idempotency_keys (
+    owner VARCHAR(64) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    response_status SMALLINT,
+    response_body MEDIUMBLOB,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (owner, idempotency_key),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB;
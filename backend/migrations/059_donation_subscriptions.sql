@@ -0,0 +1,33 @@
+-- Monthly donation subscriptions; each cycle is a donation of its own
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS donation_subscriptions (
+    id BINARY(16) PRIMARY KEY,
+    donor_id BINARY(16) NOT NULL,
+    disaster_report_id BINARY(16) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    description TEXT,
+    payment_provider VARCHAR(20) NOT NULL,
+    -- Gateway token for a card the donor saved at checkout
+    payment_method_token VARCHAR(255),
+    status ENUM('active', 'paused', 'cancelled') NOT NULL DEFAULT 'active',
+    -- Monthly donations started so far
+    cycles INT NOT NULL DEFAULT 0,
+    next_charge_at DATETIME NOT NULL,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    paused_at DATETIME,
+    cancelled_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (donor_id) REFERENCES users(id),
+    FOREIGN KEY (disaster_report_id) REFERENCES disaster_reports(id),
+    INDEX idx_donor (donor_id),
+    INDEX idx_status_next (status, next_charge_at)
+) ENGINE=InnoDB;
+
+ALTER TABLE donations
+    ADD COLUMN subscription_id BINARY(16) AFTER provider_transaction_id,
+    ADD COLUMN subscription_cycle INT AFTER subscription_id,
+    ADD UNIQUE KEY uq_subscription_cycle (subscription_id, subscription_cycle),
+    ADD FOREIGN KEY (subscription_id) REFERENCES donation_subscriptions(id);
@@ -0,0 +1,15 @@
+-- Numbered PDF receipts for completed donations
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS donation_receipts (
+    sequence BIGINT AUTO_INCREMENT PRIMARY KEY,
+    donation_id BINARY(16) NOT NULL,
+    -- SR-<year>-<sequence>, set right after the row is numbered
+    receipt_number VARCHAR(30),
+    storage_path VARCHAR(512),
+    emailed_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (donation_id) REFERENCES donations(id),
+    UNIQUE KEY uq_donation (donation_id),
+    UNIQUE KEY uq_receipt_number (receipt_number)
+) ENGINE=InnoDB;
@@ -0,0 +1,24 @@
+-- Minimum and maximum donation amounts per currency and payment method
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS donation_amount_limits (
+    currency CHAR(3) NOT NULL,
+    -- Empty for every payment method
+    payment_method VARCHAR(50) NOT NULL DEFAULT '',
+    min_amount DECIMAL(15,2),
+    max_amount DECIMAL(15,2),
+    updated_by BINARY(16),
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (currency, payment_method),
+    FOREIGN KEY (currency) REFERENCES currencies(code),
+    FOREIGN KEY (updated_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+-- Keep out token amounts and stay inside gateway limits: QRIS payments are
+-- capped at IDR 10 million, card payments start at IDR 10,000
+INSERT IGNORE INTO donation_amount_limits (currency, payment_method, min_amount, max_amount) VALUES
+    ('IDR', '', 1000, NULL),
+    ('IDR', 'qris', NULL, 10000000),
+    ('IDR', 'credit_card', 10000, NULL),
+    ('USD', '', 1, NULL),
+    ('EUR', '', 1, NULL);
@@ -0,0 +1,17 @@
+-- Refunds issued through POST /api/donations/{id}/refund, with their reason
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS donation_refunds (
+    id BINARY(16) PRIMARY KEY,
+    donation_id BINARY(16) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    reason TEXT NOT NULL,
+    -- Gateway the money went back through; NULL when refunded by hand
+    payment_provider VARCHAR(20),
+    refunded_by BINARY(16),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (donation_id) REFERENCES donations(id),
+    FOREIGN KEY (refunded_by) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE KEY uq_donation (donation_id)
+) ENGINE=InnoDB;
@@ -0,0 +1,14 @@
+-- Fundraising goals and the amount raised per report, in rupiah
+USE saferelief_db;
+
+ALTER TABLE disaster_reports
+    ADD COLUMN goal_amount DECIMAL(15,2) AFTER hidden_at,
+    ADD COLUMN amount_raised DECIMAL(15,2) NOT NULL DEFAULT 0 AFTER goal_amount,
+    ADD COLUMN goal_auto_close BOOLEAN NOT NULL DEFAULT FALSE AFTER amount_raised,
+    ADD COLUMN goal_reached_at DATETIME AFTER goal_auto_close,
+    ADD COLUMN fundraising_closed_at DATETIME AFTER goal_reached_at;
+
+UPDATE disaster_reports dr SET amount_raised = (
+    SELECT COALESCE(SUM(d.amount_idr), 0) FROM donations d
+    WHERE d.disaster_report_id = dr.id AND d.status = 'completed'
+);
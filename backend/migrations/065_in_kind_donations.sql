@@ -0,0 +1,14 @@
+-- Goods and services donations, matched to report needs and tracked until delivered
+USE saferelief_db;
+
+ALTER TABLE donations
+    ADD COLUMN kind ENUM('monetary', 'goods', 'services') NOT NULL DEFAULT 'monetary' AFTER subscription_cycle,
+    ADD COLUMN quantity DECIMAL(12,2) AFTER kind,
+    ADD COLUMN unit VARCHAR(30) AFTER quantity,
+    ADD COLUMN item_description VARCHAR(100) AFTER unit,
+    ADD COLUMN need_id BINARY(16) AFTER item_description,
+    ADD COLUMN logistics_status ENUM('pledged', 'shipped', 'received') AFTER need_id,
+    ADD COLUMN shipped_at DATETIME AFTER logistics_status,
+    ADD COLUMN received_at DATETIME AFTER shipped_at,
+    ADD FOREIGN KEY (need_id) REFERENCES report_needs(id) ON DELETE SET NULL,
+    ADD INDEX idx_kind (disaster_report_id, kind);
@@ -0,0 +1,28 @@
+-- Disbursements of donations to NGOs and purchases, charged to the reports they fund
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS disbursements (
+    id BINARY(16) PRIMARY KEY,
+    kind ENUM('transfer', 'purchase') NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    recipient VARCHAR(200) NOT NULL,
+    description TEXT,
+    evidence_upload_id VARCHAR(36) NOT NULL,
+    recorded_by BINARY(16) NOT NULL,
+    disbursed_at DATE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (evidence_upload_id) REFERENCES uploads(id),
+    FOREIGN KEY (recorded_by) REFERENCES users(id),
+    INDEX idx_disbursed (disbursed_at, created_at)
+) ENGINE=InnoDB;
+
+-- The part of a disbursement charged to each report it funds
+CREATE TABLE IF NOT EXISTS disbursement_allocations (
+    disbursement_id BINARY(16) NOT NULL,
+    report_id BINARY(16) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    PRIMARY KEY (disbursement_id, report_id),
+    FOREIGN KEY (disbursement_id) REFERENCES disbursements(id) ON DELETE CASCADE,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    INDEX idx_report (report_id)
+) ENGINE=InnoDB;
@@ -0,0 +1,31 @@
+-- Escrow releases of report donations to organizations, approved by several donation managers
+USE saferelief_db;
+
+CREATE TABLE IF NOT EXISTS escrow_releases (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    organization_id BINARY(16) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    note VARCHAR(500),
+    status ENUM('pending', 'released', 'cancelled') NOT NULL DEFAULT 'pending',
+    requested_by BINARY(16) NOT NULL,
+    cancelled_by BINARY(16),
+    released_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (requested_by) REFERENCES users(id),
+    FOREIGN KEY (cancelled_by) REFERENCES users(id),
+    INDEX idx_report_status (report_id, status)
+) ENGINE=InnoDB;
+
+-- One row per donation manager approving a release
+CREATE TABLE IF NOT EXISTS escrow_release_approvals (
+    release_id BINARY(16) NOT NULL,
+    approver_id BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (release_id, approver_id),
+    FOREIGN KEY (release_id) REFERENCES escrow_releases(id) ON DELETE CASCADE,
+    FOREIGN KEY (approver_id) REFERENCES users(id)
+) ENGINE=InnoDB;
@@ -0,0 +1,6 @@
+-- Transfers go to a verified organization, recorded by ID
+USE saferelief_db;
+
+ALTER TABLE disbursements
+    ADD COLUMN recipient_organization_id BINARY(16) AFTER recipient,
+    ADD FOREIGN KEY (recipient_organization_id) REFERENCES organizations(id);
@@ -0,0 +1,6 @@
+-- SSO logins started by a signed-in user to link an identity to their account
+USE saferelief_db;
+
+ALTER TABLE sso_login_states
+    ADD COLUMN link_user_id BINARY(16) AFTER redirect_path,
+    ADD FOREIGN KEY (link_user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -9,7 +9,17 @@ USE saferelief_db;
 CREATE TABLE IF NOT EXISTS users (
     id BINARY(16) PRIMARY KEY,
     username VARCHAR(50) UNIQUE NOT NULL,
+    username_changed_at DATETIME,
     email VARCHAR(255) UNIQUE NOT NULL,
+    email_verified_at DATETIME,
+    email_bounced_at DATETIME,
+    avatar_url VARCHAR(255),
+    avatar_sizes JSON,
+    display_name VARCHAR(100),
+    phone VARCHAR(16),
+    bio VARCHAR(500),
+    locale VARCHAR(10) NOT NULL DEFAULT 'id',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Jakarta',
     password_hash CHAR(60) NOT NULL,
     mfa_secret VARCHAR(32),
     mfa_enabled BOOLEAN DEFAULT FALSE,
@@ -18,43 +28,143 @@ CREATE TABLE IF NOT EXISTS users (
     last_password_change DATETIME NOT NULL,
     require_password_change BOOLEAN DEFAULT FALSE,
     status ENUM('active', 'inactive', 'banned') DEFAULT 'inactive',
+    role ENUM('donor', 'reporter', 'verifier', 'admin') NOT NULL DEFAULT 'donor',
+    deleted_at DATETIME,
+    anonymized_at DATETIME,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     INDEX idx_email (email),
-    INDEX idx_username (username)
+    INDEX idx_username (username),
+    INDEX idx_role (role),
+    INDEX idx_deleted_at (deleted_at)
+) ENGINE=InnoDB;
+
+-- Browsers/apps a user has signed in from, identified by a long-lived device cookie
+CREATE TABLE IF NOT EXISTS user_devices (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    user_agent VARCHAR(255),
+    trusted_until DATETIME,
+    trusted_agent_hash CHAR(64),
+    last_seen_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_last_seen (user_id, last_seen_at)
 ) ENGINE=InnoDB;
 
 -- Sessions table for secure session management
 CREATE TABLE IF NOT EXISTS sessions (
     id BINARY(16) PRIMARY KEY,
     user_id BINARY(16) NOT NULL,
+    device_id BINARY(16),
     token_hash CHAR(64) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(255),
     expires_at DATETIME NOT NULL,
+    last_seen_at DATETIME,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (device_id) REFERENCES user_devices(id) ON DELETE CASCADE,
     INDEX idx_token_hash (token_hash),
     INDEX idx_expires_at (expires_at)
 ) ENGINE=InnoDB;
 
+-- NGO / organization registry with document-backed verification
+CREATE TABLE IF NOT EXISTS organizations (
+    id BINARY(16) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    registration_number VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    website VARCHAR(255),
+    owner_id BINARY(16) NOT NULL,
+    verification_status ENUM('unverified', 'pending', 'verified', 'rejected') NOT NULL DEFAULT 'unverified',
+    verification_documents JSON,
+    review_note TEXT,
+    verified_by BINARY(16),
+    verified_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id) REFERENCES users(id),
+    FOREIGN KEY (verified_by) REFERENCES users(id),
+    INDEX idx_verification_status (verification_status)
+) ENGINE=InnoDB;
+
+-- Disaster type taxonomy; add rows to offer new types without a deploy
+CREATE TABLE IF NOT EXISTS disaster_types (
+    code VARCHAR(30) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+INSERT IGNORE INTO disaster_types (code, name) VALUES
+    ('flood', 'Flood'),
+    ('flash_flood', 'Flash flood'),
+    ('earthquake', 'Earthquake'),
+    ('tsunami', 'Tsunami'),
+    ('landslide', 'Landslide'),
+    ('volcanic_eruption', 'Volcanic eruption'),
+    ('fire', 'Fire'),
+    ('forest_fire', 'Forest fire'),
+    ('storm', 'Storm'),
+    ('drought', 'Drought'),
+    ('tidal_flood', 'Tidal flood'),
+    ('other', 'Other');
+
 -- Disaster reports with location data
 CREATE TABLE IF NOT EXISTS disaster_reports (
     id BINARY(16) PRIMARY KEY,
     reporter_id BINARY(16) NOT NULL,
+    organization_id BINARY(16),
     title VARCHAR(255) NOT NULL,
     description TEXT NOT NULL,
+    disaster_type VARCHAR(30) NOT NULL DEFAULT 'other',
     latitude DECIMAL(10,8) NOT NULL,
     longitude DECIMAL(11,8) NOT NULL,
     location POINT NOT NULL SRID 4326,
     severity ENUM('low', 'medium', 'high', 'critical') NOT NULL,
-    status ENUM('pending', 'verified', 'resolved') DEFAULT 'pending',
+    status ENUM('pending', 'verified', 'in_progress', 'resolved', 'closed', 'rejected') DEFAULT 'pending',
     verified_by BINARY(16),
+    verified_at DATETIME,
+    rejection_reason VARCHAR(40),
+    rejection_note TEXT,
+    rejected_by BINARY(16),
+    rejected_at DATETIME,
+    merged_into BINARY(16),
+    verification_round INT NOT NULL DEFAULT 1,
+    priority_score TINYINT UNSIGNED,
+    priority_factors JSON,
+    -- 'feed' marks candidate reports filed from an agency alert feed
+    source ENUM('user', 'feed') NOT NULL DEFAULT 'user',
+    -- Open abuse flags; past REPORT_FLAG_THRESHOLD the report is hidden until reviewed
+    flag_count INT UNSIGNED NOT NULL DEFAULT 0,
+    hidden_at DATETIME,
+    -- Fundraising goal and completed donations so far, both in rupiah;
+    -- amount_raised is kept up to date with every completion and refund
+    goal_amount DECIMAL(15,2),
+    amount_raised DECIMAL(15,2) NOT NULL DEFAULT 0,
+    goal_auto_close BOOLEAN NOT NULL DEFAULT FALSE,
+    goal_reached_at DATETIME,
+    fundraising_closed_at DATETIME,
+    deleted_at DATETIME,
+    deleted_by BINARY(16),
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (reporter_id) REFERENCES users(id),
     FOREIGN KEY (verified_by) REFERENCES users(id),
+    FOREIGN KEY (rejected_by) REFERENCES users(id),
+    FOREIGN KEY (merged_into) REFERENCES disaster_reports(id),
+    FOREIGN KEY (deleted_by) REFERENCES users(id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (disaster_type) REFERENCES disaster_types(code),
     INDEX idx_status (status),
+    INDEX idx_disaster_type (disaster_type),
+    INDEX idx_deleted_at (deleted_at),
+    INDEX idx_priority_score (status, priority_score),
     INDEX idx_coords (latitude, longitude),
-    SPATIAL INDEX idx_location (location)
+    SPATIAL INDEX idx_location (location),
+    FULLTEXT INDEX ft_reports (title, description)
 ) ENGINE=InnoDB;
 
 -- Create trigger to set POINT data from latitude and longitude
@@ -76,23 +186,80 @@ BEGIN
 END//
 DELIMITER ;
 
+-- Monthly donation pledges; each cycle is a row in donations
+CREATE TABLE IF NOT EXISTS donation_subscriptions (
+    id BINARY(16) PRIMARY KEY,
+    donor_id BINARY(16) NOT NULL,
+    disaster_report_id BINARY(16) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    description TEXT,
+    payment_provider VARCHAR(20) NOT NULL,
+    -- Gateway token for a card the donor saved at checkout
+    payment_method_token VARCHAR(255),
+    status ENUM('active', 'paused', 'cancelled') NOT NULL DEFAULT 'active',
+    -- Monthly donations started so far
+    cycles INT NOT NULL DEFAULT 0,
+    next_charge_at DATETIME NOT NULL,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    paused_at DATETIME,
+    cancelled_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (donor_id) REFERENCES users(id),
+    FOREIGN KEY (disaster_report_id) REFERENCES disaster_reports(id),
+    INDEX idx_donor (donor_id),
+    INDEX idx_status_next (status, next_charge_at)
+) ENGINE=InnoDB;
+
 -- Donations with transaction tracking
 CREATE TABLE IF NOT EXISTS donations (
     id BINARY(16) PRIMARY KEY,
-    donor_id BINARY(16) NOT NULL,
+    donor_id BINARY(16),
+    guest_email VARCHAR(255),
+    claim_token_hash CHAR(64),
+    claimed_at DATETIME,
     disaster_report_id BINARY(16) NOT NULL,
     amount DECIMAL(10,2) NOT NULL,
     currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    -- amount converted at the rate of the day the donation was made, NULL
+    -- until a rate for its currency is known
+    amount_idr DECIMAL(15,2),
+    amount_usd DECIMAL(12,2),
     description TEXT,
     status ENUM('pending', 'completed', 'failed', 'refunded') DEFAULT 'pending',
     transaction_id VARCHAR(100),
     payment_method VARCHAR(50),
+    -- Gateway collecting the payment; transaction_id is the order ID sent to it
+    payment_provider VARCHAR(20),
+    -- The gateway's order, for gateways where the server captures payment
+    provider_order_id VARCHAR(100),
+    provider_transaction_id VARCHAR(100),
+    -- Set on the monthly donations of a donation_subscriptions row
+    subscription_id BINARY(16),
+    subscription_cycle INT,
+    -- Goods and services are pledged in quantity instead of paid; amount is 0
+    kind ENUM('monetary', 'goods', 'services') NOT NULL DEFAULT 'monetary',
+    quantity DECIMAL(12,2),
+    unit VARCHAR(30),
+    item_description VARCHAR(100),
+    -- The report need an in-kind pledge goes toward, if any
+    need_id BINARY(16),
+    logistics_status ENUM('pledged', 'shipped', 'received'),
+    shipped_at DATETIME,
+    received_at DATETIME,
+    completed_at DATETIME,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (donor_id) REFERENCES users(id),
     FOREIGN KEY (disaster_report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (subscription_id) REFERENCES donation_subscriptions(id),
     INDEX idx_status (status),
-    INDEX idx_transaction (transaction_id)
+    INDEX idx_transaction (transaction_id),
+    INDEX idx_provider_transaction (payment_provider, transaction_id),
+    INDEX idx_kind (disaster_report_id, kind),
+    UNIQUE KEY uq_claim_token (claim_token_hash),
+    UNIQUE KEY uq_subscription_cycle (subscription_id, subscription_cycle)
 ) ENGINE=InnoDB;
 
 -- Audit logs for security tracking
@@ -100,6 +267,7 @@ CREATE TABLE IF NOT EXISTS audit_logs (
     id BINARY(16) PRIMARY KEY,
     user_id BINARY(16),
     action VARCHAR(100) NOT NULL,
+    severity ENUM('LOW', 'MEDIUM', 'HIGH', 'CRITICAL') NOT NULL DEFAULT 'LOW',
     entity_type VARCHAR(50) NOT NULL,
     entity_id BINARY(16),
     ip_address VARCHAR(45) NOT NULL,
@@ -108,8 +276,11 @@ CREATE TABLE IF NOT EXISTS audit_logs (
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
     INDEX idx_action (action),
+    INDEX idx_severity (severity),
+    INDEX idx_ip_address (ip_address),
     INDEX idx_entity (entity_type, entity_id),
-    INDEX idx_created_at (created_at)
+    INDEX idx_created_at (created_at),
+    INDEX idx_user_action_created (user_id, action, created_at)
 ) ENGINE=InnoDB;
 
 -- Rate limiting table
@@ -135,14 +306,1069 @@ CREATE TABLE IF NOT EXISTS file_uploads (
     mime_type VARCHAR(127) NOT NULL,
     file_hash CHAR(64) NOT NULL,
     storage_path VARCHAR(512) NOT NULL,
+    upload_id VARCHAR(36),
     status ENUM('pending', 'verified', 'rejected') DEFAULT 'pending',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id),
     FOREIGN KEY (disaster_report_id) REFERENCES disaster_reports(id),
     INDEX idx_file_hash (file_hash),
+    INDEX idx_report_upload (disaster_report_id, upload_id),
     INDEX idx_status (status)
 ) ENGINE=InnoDB;
 
+-- General-purpose uploads (attachments, avatars)
+CREATE TABLE IF NOT EXISTS uploads (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    original_name VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    mime_type VARCHAR(127) NOT NULL,
+    path VARCHAR(512) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB;
+
+-- Personal data exports (GDPR/PDP portability)
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    status ENUM('pending', 'ready', 'failed') NOT NULL DEFAULT 'pending',
+    file_path VARCHAR(512),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    expires_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_created (user_id, created_at)
+) ENGINE=InnoDB;
+
+-- Applications from users asking for the verifier role
+CREATE TABLE IF NOT EXISTS verifier_applications (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    organization VARCHAR(255),
+    motivation TEXT NOT NULL,
+    document_ids JSON NOT NULL,
+    status ENUM('pending', 'approved', 'rejected') NOT NULL DEFAULT 'pending',
+    reviewed_by BINARY(16),
+    review_note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (reviewed_by) REFERENCES users(id),
+    INDEX idx_status (status),
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB;
+
+-- Pending email address changes awaiting confirmation from both addresses
+CREATE TABLE IF NOT EXISTS email_changes (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    old_email VARCHAR(255) NOT NULL,
+    new_email VARCHAR(255) NOT NULL,
+    old_token_hash CHAR(64) NOT NULL,
+    new_token_hash CHAR(64) NOT NULL,
+    old_confirmed_at DATETIME,
+    new_confirmed_at DATETIME,
+    completed_at DATETIME,
+    cancelled_at DATETIME,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_old_token_hash (old_token_hash),
+    INDEX idx_new_token_hash (new_token_hash)
+) ENGINE=InnoDB;
+
+-- Account suspensions and bans; a row is active until lifted or expired
+CREATE TABLE IF NOT EXISTS user_suspensions (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    kind ENUM('suspension', 'ban') NOT NULL,
+    reason TEXT NOT NULL,
+    expires_at DATETIME,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    lifted_at DATETIME,
+    lifted_by BINARY(16),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (lifted_by) REFERENCES users(id),
+    INDEX idx_user_active (user_id, lifted_at, expires_at)
+) ENGINE=InnoDB;
+
+-- Published privacy policy / terms versions; the newest one in effect is current
+CREATE TABLE IF NOT EXISTS policy_versions (
+    id BINARY(16) PRIMARY KEY,
+    document ENUM('privacy', 'terms') NOT NULL,
+    version VARCHAR(32) NOT NULL,
+    url VARCHAR(255) NOT NULL,
+    effective_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_document_version (document, version),
+    INDEX idx_document_effective (document, effective_at)
+) ENGINE=InnoDB;
+
+-- Record of which policy versions each user agreed to and when
+CREATE TABLE IF NOT EXISTS user_consents (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    document ENUM('privacy', 'terms') NOT NULL,
+    version VARCHAR(32) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    consented_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE KEY uq_user_document_version (user_id, document, version)
+) ENGINE=InnoDB;
+
+-- Bulk staff imports and their per-row outcome
+CREATE TABLE IF NOT EXISTS user_imports (
+    id BINARY(16) PRIMARY KEY,
+    created_by BINARY(16) NOT NULL,
+    status ENUM('queued', 'processing', 'completed', 'failed') NOT NULL DEFAULT 'queued',
+    total INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS user_import_rows (
+    import_id BINARY(16) NOT NULL,
+    line_number INT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    status ENUM('pending', 'created', 'failed') NOT NULL DEFAULT 'pending',
+    user_id BINARY(16),
+    error VARCHAR(255),
+    PRIMARY KEY (import_id, line_number),
+    FOREIGN KEY (import_id) REFERENCES user_imports(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- Invitations for accounts created on someone's behalf
+CREATE TABLE IF NOT EXISTS user_invitations (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Staff granted scoped permissions to act on behalf of an organization
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id BINARY(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    role ENUM('owner', 'verifier', 'finance', 'staff') NOT NULL DEFAULT 'staff',
+    permissions JSON NOT NULL,
+    granted_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (granted_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- Volunteer registry
+CREATE TABLE IF NOT EXISTS volunteer_profiles (
+    user_id BINARY(16) PRIMARY KEY,
+    skills JSON NOT NULL,
+    certifications JSON NOT NULL,
+    region VARCHAR(100) NOT NULL,
+    availability ENUM('available', 'limited', 'unavailable') NOT NULL DEFAULT 'available',
+    notes TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_region_availability (region, availability)
+) ENGINE=InnoDB;
+
+-- Coordinator requests for volunteers on a report and the volunteer's answer
+CREATE TABLE IF NOT EXISTS volunteer_requests (
+    id BINARY(16) PRIMARY KEY,
+    disaster_report_id BINARY(16) NOT NULL,
+    volunteer_id BINARY(16) NOT NULL,
+    requested_by BINARY(16) NOT NULL,
+    message TEXT,
+    status ENUM('pending', 'accepted', 'declined') NOT NULL DEFAULT 'pending',
+    responded_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (disaster_report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (volunteer_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (requested_by) REFERENCES users(id),
+    UNIQUE KEY uq_report_volunteer (disaster_report_id, volunteer_id)
+) ENGINE=InnoDB;
+
+-- Outbound webhook endpoints owned by a user or an organization
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16),
+    organization_id BINARY(16),
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    events JSON NOT NULL,
+    -- 'all_reports' endpoints belong to partners and get every report's events
+    scope ENUM('own', 'all_reports') NOT NULL DEFAULT 'own',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    INDEX idx_user (user_id),
+    INDEX idx_organization (organization_id),
+    INDEX idx_scope (scope)
+) ENGINE=InnoDB;
+
+-- Delivery log; one row per event sent to an endpoint
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BINARY(16) PRIMARY KEY,
+    endpoint_id BINARY(16) NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    payload JSON NOT NULL,
+    status ENUM('pending', 'succeeded', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    response_status INT,
+    last_error VARCHAR(500),
+    last_attempt_at DATETIME,
+    -- When a pending delivery is retried, or picked up again if its queued
+    -- attempt was lost
+    next_attempt_at DATETIME,
+    delivered_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    INDEX idx_endpoint_created (endpoint_id, created_at),
+    INDEX idx_status_next (status, next_attempt_at)
+) ENGINE=InnoDB;
+
+-- Slack/Discord channels that receive report alerts
+CREATE TABLE IF NOT EXISTS chat_integrations (
+    id BINARY(16) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    provider ENUM('slack', 'discord') NOT NULL,
+    webhook_url VARCHAR(512) NOT NULL,
+    alerts JSON NOT NULL,
+    min_severity ENUM('low', 'medium', 'high', 'critical') NOT NULL DEFAULT 'low',
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    funding_milestones JSON NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- Funding milestones already announced, so each is posted once per channel
+CREATE TABLE IF NOT EXISTS chat_alert_milestones (
+    integration_id BINARY(16) NOT NULL,
+    disaster_report_id BINARY(16) NOT NULL,
+    milestone DECIMAL(14,2) NOT NULL,
+    sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (integration_id, disaster_report_id, milestone),
+    FOREIGN KEY (integration_id) REFERENCES chat_integrations(id) ON DELETE CASCADE,
+    FOREIGN KEY (disaster_report_id) REFERENCES disaster_reports(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Weekly digest preferences; users without a row are not subscribed
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    user_id BINARY(16) PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    new_disasters BOOLEAN NOT NULL DEFAULT TRUE,
+    funding_progress BOOLEAN NOT NULL DEFAULT TRUE,
+    delivery_updates BOOLEAN NOT NULL DEFAULT TRUE,
+    last_sent_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Areas a user follows for new verified disasters
+CREATE TABLE IF NOT EXISTS followed_regions (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    latitude DECIMAL(10,8) NOT NULL,
+    longitude DECIMAL(11,8) NOT NULL,
+    radius_km DECIMAL(6,2) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user (user_id)
+) ENGINE=InnoDB;
+
+-- Telegram chats linked to SafeRelief accounts
+CREATE TABLE IF NOT EXISTS telegram_links (
+    chat_id BIGINT PRIMARY KEY,
+    user_id BINARY(16) NOT NULL UNIQUE,
+    telegram_username VARCHAR(64),
+    alerts_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    linked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS telegram_link_codes (
+    user_id BINARY(16) PRIMARY KEY,
+    code_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Reports being composed step by step in a Telegram chat
+CREATE TABLE IF NOT EXISTS telegram_report_drafts (
+    chat_id BIGINT PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    step ENUM('title', 'location', 'severity', 'photo') NOT NULL,
+    title VARCHAR(255),
+    latitude DECIMAL(10,8),
+    longitude DECIMAL(11,8),
+    severity ENUM('low', 'medium', 'high', 'critical'),
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Last known location reported by the user's device
+CREATE TABLE IF NOT EXISTS user_locations (
+    user_id BINARY(16) PRIMARY KEY,
+    latitude DECIMAL(10,8) NOT NULL,
+    longitude DECIMAL(11,8) NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Geo-targeted emergency broadcasts and their per-recipient delivery state
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id BINARY(16) PRIMARY KEY,
+    title VARCHAR(150) NOT NULL,
+    message TEXT NOT NULL,
+    area JSON NOT NULL,
+    channels JSON NOT NULL,
+    status ENUM('queued', 'sending', 'completed', 'failed') NOT NULL DEFAULT 'queued',
+    recipient_count INT NOT NULL DEFAULT 0,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS broadcast_recipients (
+    broadcast_id BINARY(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    status ENUM('pending', 'sent', 'failed') NOT NULL DEFAULT 'pending',
+    last_error VARCHAR(500),
+    sent_at DATETIME,
+    PRIMARY KEY (broadcast_id, user_id, channel),
+    FOREIGN KEY (broadcast_id) REFERENCES broadcasts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_broadcast_status (broadcast_id, status)
+) ENGINE=InnoDB;
+
+-- Versioned notification content; the highest version per key and locale is sent
+CREATE TABLE IF NOT EXISTS notification_templates (
+    template_key VARCHAR(64) NOT NULL,
+    locale VARCHAR(10) NOT NULL,
+    version INT NOT NULL,
+    subject VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    created_by BINARY(16),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_key, locale, version),
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+-- Outgoing templated email; the body is kept only until the message is sent
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id BINARY(16) PRIMARY KEY,
+    channel VARCHAR(20) NOT NULL DEFAULT 'email',
+    template_key VARCHAR(64) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT,
+    status ENUM('pending', 'sent', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(500),
+    last_attempt_at DATETIME,
+    sent_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_status_created (status, created_at)
+) ENGINE=InnoDB;
+
+-- Hash-chained batches of completed donations; the chain hash is anchored externally
+CREATE TABLE IF NOT EXISTS ledger_batches (
+    id BINARY(16) PRIMARY KEY,
+    sequence INT NOT NULL UNIQUE,
+    merkle_root CHAR(64) NOT NULL,
+    chain_hash CHAR(64) NOT NULL,
+    leaf_count INT NOT NULL,
+    anchor_method VARCHAR(32),
+    anchor_ref TEXT,
+    anchor_error TEXT,
+    anchored_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    entry_type VARCHAR(20) NOT NULL,
+    entry_id BINARY(16) NOT NULL,
+    batch_id BINARY(16) NOT NULL,
+    leaf_index INT NOT NULL,
+    leaf_hash CHAR(64) NOT NULL,
+    leaf_data VARCHAR(255) NOT NULL,
+    PRIMARY KEY (entry_type, entry_id),
+    FOREIGN KEY (batch_id) REFERENCES ledger_batches(id),
+    UNIQUE KEY uniq_batch_leaf (batch_id, leaf_index)
+) ENGINE=InnoDB;
+
+-- Keys for programmatic clients; only the SHA-256 of the key is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes JSON NOT NULL,
+    daily_quota INT NOT NULL,
+    last_used_at DATETIME,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user (user_id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    key_id BINARY(16) NOT NULL,
+    day DATE NOT NULL,
+    requests INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day),
+    FOREIGN KEY (key_id) REFERENCES api_keys(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Latest comparison of each report with official disaster records
+CREATE TABLE IF NOT EXISTS report_crosschecks (
+    report_id BINARY(16) PRIMARY KEY,
+    source VARCHAR(32) NOT NULL,
+    status ENUM('matched', 'no_match', 'failed') NOT NULL,
+    best_score DECIMAL(4,3),
+    matches JSON,
+    last_error VARCHAR(500),
+    checked_at DATETIME NOT NULL,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Addresses that hard-bounced or complained; nothing is sent to them
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    email VARCHAR(255) PRIMARY KEY,
+    reason ENUM('hard_bounce', 'complaint') NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    detail VARCHAR(500),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+-- Raw callbacks from payment, SMS and email providers, archived before
+-- processing; (provider, event_id) makes redeliveries idempotent
+CREATE TABLE IF NOT EXISTS inbound_webhook_events (
+    id BINARY(16) PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    event_id VARCHAR(128) NOT NULL,
+    headers JSON,
+    payload MEDIUMBLOB NOT NULL,
+    status ENUM('pending', 'processing', 'processed', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1000),
+    received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    processed_at DATETIME,
+    UNIQUE KEY uq_inbound_event (provider, event_id),
+    INDEX idx_inbound_status (status, updated_at),
+    INDEX idx_inbound_received (received_at)
+) ENGINE=InnoDB;
+
+-- One-time MFA recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY uniq_user_code (user_id, code_hash)
+) ENGINE=InnoDB;
+
+-- Per-organization OpenID Connect identity provider for staff sign-in
+CREATE TABLE IF NOT EXISTS organization_sso_configs (
+    organization_id BINARY(16) PRIMARY KEY,
+    issuer VARCHAR(255) NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    client_secret VARCHAR(512) NOT NULL,
+    email_domains JSON NOT NULL,
+    groups_claim VARCHAR(64) NOT NULL DEFAULT 'groups',
+    group_permissions JSON NOT NULL,
+    default_permissions JSON NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (updated_by) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- Provider subjects linked to local users
+CREATE TABLE IF NOT EXISTS sso_identities (
+    id BINARY(16) PRIMARY KEY,
+    organization_id BINARY(16) NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    last_login_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_sso_subject (organization_id, issuer, subject),
+    INDEX idx_user (user_id)
+) ENGINE=InnoDB;
+
+-- Sign-ins in flight at a provider; the state is stored as a SHA-256 hash
+CREATE TABLE IF NOT EXISTS sso_login_states (
+    state_hash CHAR(64) PRIMARY KEY,
+    organization_id BINARY(16) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(64) NOT NULL,
+    redirect_path VARCHAR(512) NOT NULL,
+    -- Set when a signed-in user is linking the identity to their account
+    link_user_id BINARY(16),
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (link_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_expires_at (expires_at)
+) ENGINE=InnoDB;
+
+-- Trail of account deletions, kept after the account is anonymized as
+-- evidence that an erasure request was carried out
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id BINARY(16) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    requested_by BINARY(16) NOT NULL,
+    self_service BOOLEAN NOT NULL DEFAULT FALSE,
+    requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    purge_after DATETIME NOT NULL,
+    restored_at DATETIME,
+    anonymized_at DATETIME,
+    files_deleted INT,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (requested_by) REFERENCES users(id),
+    INDEX idx_user_requested (user_id, requested_at)
+) ENGINE=InnoDB;
+
+-- Single-use passwordless sign-in links; only the token's hash is stored
+CREATE TABLE IF NOT EXISTS magic_links (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id BINARY(16) NOT NULL,
+    requested_ip VARCHAR(45) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_created (user_id, created_at)
+) ENGINE=InnoDB;
+
+-- Per-user opt-in/out for each notification event and channel; missing rows
+-- fall back to the defaults in code
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BINARY(16) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    channel ENUM('email', 'sms', 'push') NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, event_type, channel),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Short-lived links for downloading a ready data export without a session
+CREATE TABLE IF NOT EXISTS data_export_links (
+    token_hash CHAR(64) PRIMARY KEY,
+    export_id BINARY(16) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (export_id) REFERENCES data_exports(id) ON DELETE CASCADE,
+    INDEX idx_export_expires (export_id, expires_at)
+) ENGINE=InnoDB;
+
+-- Membership invitations for addresses with no account yet
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id BINARY(16) PRIMARY KEY,
+    organization_id BINARY(16) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role ENUM('owner', 'verifier', 'finance', 'staff') NOT NULL,
+    permissions JSON NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    invited_by BINARY(16) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME,
+    accepted_by BINARY(16),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES users(id),
+    FOREIGN KEY (accepted_by) REFERENCES users(id),
+    INDEX idx_org_email (organization_id, email)
+) ENGINE=InnoDB;
+
+-- Per-tier rate limit overrides set by admins; tiers without a row use RATE_LIMIT_TIERS
+CREATE TABLE IF NOT EXISTS rate_limit_tiers (
+    tier VARCHAR(20) PRIMARY KEY,
+    requests_per_minute INT NOT NULL,
+    updated_by BINARY(16),
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (updated_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+-- Situation updates posted under reports by responders
+CREATE TABLE IF NOT EXISTS report_comments (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    author_id BINARY(16) NOT NULL,
+    body TEXT NOT NULL,
+    edited_at DATETIME,
+    deleted_at DATETIME,
+    deleted_by BINARY(16),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(id),
+    FOREIGN KEY (deleted_by) REFERENCES users(id),
+    INDEX idx_report_created (report_id, created_at)
+) ENGINE=InnoDB;
+
+-- Earlier reports flagged as possibly describing the same event as a new report
+CREATE TABLE IF NOT EXISTS report_duplicate_candidates (
+    report_id BINARY(16) NOT NULL,
+    candidate_id BINARY(16) NOT NULL,
+    distance_m DOUBLE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (report_id, candidate_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id) ON DELETE CASCADE,
+    FOREIGN KEY (candidate_id) REFERENCES disaster_reports(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Append-only history of changes to a report's content and status
+CREATE TABLE IF NOT EXISTS report_revisions (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    -- NULL for changes the system made on its own, such as expiry
+    changed_by BINARY(16),
+    changes JSON NOT NULL,
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (changed_by) REFERENCES users(id),
+    INDEX idx_report_created (report_id, created_at)
+) ENGINE=InnoDB;
+
+DELIMITER //
+CREATE TRIGGER report_revisions_no_update
+BEFORE UPDATE ON report_revisions
+FOR EACH ROW
+BEGIN
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'report_revisions is append-only';
+END//
+
+CREATE TRIGGER report_revisions_no_delete
+BEFORE DELETE ON report_revisions
+FOR EACH ROW
+BEGIN
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'report_revisions is append-only';
+END//
+DELIMITER ;
+
+-- Verifier votes on pending reports; a report is verified once a quorum approves
+CREATE TABLE IF NOT EXISTS report_verification_votes (
+    report_id BINARY(16) NOT NULL,
+    round INT NOT NULL,
+    verifier_id BINARY(16) NOT NULL,
+    decision ENUM('approve', 'reject') NOT NULL,
+    reason VARCHAR(40),
+    note TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (report_id, round, verifier_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (verifier_id) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- Users following a report to hear about its status changes
+CREATE TABLE IF NOT EXISTS report_subscriptions (
+    report_id BINARY(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (report_id, user_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user (user_id, created_at)
+) ENGINE=InnoDB;
+
+-- Abuse flags on reports, one per user per report, resolved by admins
+CREATE TABLE IF NOT EXISTS report_flags (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    user_id BINARY(16) NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    note TEXT,
+    status ENUM('open', 'upheld', 'dismissed') NOT NULL DEFAULT 'open',
+    resolved_by BINARY(16),
+    resolution_note TEXT,
+    resolved_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_report_user (report_id, user_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (resolved_by) REFERENCES users(id),
+    INDEX idx_status_report (status, report_id)
+) ENGINE=InnoDB;
+
+-- Items responders need for a report, such as blankets or drinking water
+CREATE TABLE IF NOT EXISTS report_needs (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    item VARCHAR(100) NOT NULL,
+    unit VARCHAR(30) NOT NULL,
+    quantity_needed DECIMAL(12,2) NOT NULL,
+    quantity_fulfilled DECIMAL(12,2) NOT NULL DEFAULT 0,
+    created_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    INDEX idx_report (report_id, created_at)
+) ENGINE=InnoDB;
+
+-- Each delivery recorded against a need; quantity_fulfilled is their sum
+CREATE TABLE IF NOT EXISTS report_need_fulfillments (
+    id BINARY(16) PRIMARY KEY,
+    need_id BINARY(16) NOT NULL,
+    quantity DECIMAL(12,2) NOT NULL,
+    note VARCHAR(500),
+    recorded_by BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (need_id) REFERENCES report_needs(id) ON DELETE CASCADE,
+    FOREIGN KEY (recorded_by) REFERENCES users(id),
+    INDEX idx_need (need_id, created_at)
+) ENGINE=InnoDB;
+
+-- Alerts already read from agency feeds, so each is filed once. Alerts
+-- that match an earlier feed report point at that report instead.
+CREATE TABLE IF NOT EXISTS feed_events (
+    source VARCHAR(20) NOT NULL,
+    external_id VARCHAR(100) NOT NULL,
+    report_id BINARY(16) NOT NULL,
+    disaster_type VARCHAR(30) NOT NULL,
+    magnitude DECIMAL(4,2),
+    occurred_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source, external_id),
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    INDEX idx_type_occurred (disaster_type, occurred_at)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS donation_idempotency_keys (
+    owner VARCHAR(64) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    response_status SMALLINT,
+    response_body MEDIUMBLOB,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (owner, idempotency_key),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS donation_receipts (
+    sequence BIGINT AUTO_INCREMENT PRIMARY KEY,
+    donation_id BINARY(16) NOT NULL,
+    -- SR-<year>-<sequence>, set right after the row is numbered
+    receipt_number VARCHAR(30),
+    storage_path VARCHAR(512),
+    emailed_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (donation_id) REFERENCES donations(id),
+    UNIQUE KEY uq_donation (donation_id),
+    UNIQUE KEY uq_receipt_number (receipt_number)
+) ENGINE=InnoDB;
+
+-- ISO 4217 currencies donations may be made in; minor_units is the number
+-- of decimals an amount may have
+CREATE TABLE IF NOT EXISTS currencies (
+    code CHAR(3) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    minor_units TINYINT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+INSERT IGNORE INTO currencies (code, name, minor_units) VALUES
+    ('AED', 'UAE Dirham', 2),
+    ('AFN', 'Afghani', 2),
+    ('ALL', 'Lek', 2),
+    ('AMD', 'Armenian Dram', 2),
+    ('ANG', 'Netherlands Antillean Guilder', 2),
+    ('AOA', 'Kwanza', 2),
+    ('ARS', 'Argentine Peso', 2),
+    ('AUD', 'Australian Dollar', 2),
+    ('AWG', 'Aruban Florin', 2),
+    ('AZN', 'Azerbaijan Manat', 2),
+    ('BAM', 'Convertible Mark', 2),
+    ('BBD', 'Barbados Dollar', 2),
+    ('BDT', 'Taka', 2),
+    ('BGN', 'Bulgarian Lev', 2),
+    ('BHD', 'Bahraini Dinar', 3),
+    ('BIF', 'Burundi Franc', 0),
+    ('BMD', 'Bermudian Dollar', 2),
+    ('BND', 'Brunei Dollar', 2),
+    ('BOB', 'Boliviano', 2),
+    ('BRL', 'Brazilian Real', 2),
+    ('BSD', 'Bahamian Dollar', 2),
+    ('BTN', 'Ngultrum', 2),
+    ('BWP', 'Pula', 2),
+    ('BYN', 'Belarusian Ruble', 2),
+    ('BZD', 'Belize Dollar', 2),
+    ('CAD', 'Canadian Dollar', 2),
+    ('CDF', 'Congolese Franc', 2),
+    ('CHF', 'Swiss Franc', 2),
+    ('CLP', 'Chilean Peso', 0),
+    ('CNY', 'Yuan Renminbi', 2),
+    ('COP', 'Colombian Peso', 2),
+    ('CRC', 'Costa Rican Colon', 2),
+    ('CUP', 'Cuban Peso', 2),
+    ('CVE', 'Cabo Verde Escudo', 2),
+    ('CZK', 'Czech Koruna', 2),
+    ('DJF', 'Djibouti Franc', 0),
+    ('DKK', 'Danish Krone', 2),
+    ('DOP', 'Dominican Peso', 2),
+    ('DZD', 'Algerian Dinar', 2),
+    ('EGP', 'Egyptian Pound', 2),
+    ('ERN', 'Nakfa', 2),
+    ('ETB', 'Ethiopian Birr', 2),
+    ('EUR', 'Euro', 2),
+    ('FJD', 'Fiji Dollar', 2),
+    ('FKP', 'Falkland Islands Pound', 2),
+    ('GBP', 'Pound Sterling', 2),
+    ('GEL', 'Lari', 2),
+    ('GHS', 'Ghana Cedi', 2),
+    ('GIP', 'Gibraltar Pound', 2),
+    ('GMD', 'Dalasi', 2),
+    ('GNF', 'Guinean Franc', 0),
+    ('GTQ', 'Quetzal', 2),
+    ('GYD', 'Guyana Dollar', 2),
+    ('HKD', 'Hong Kong Dollar', 2),
+    ('HNL', 'Lempira', 2),
+    ('HTG', 'Gourde', 2),
+    ('HUF', 'Forint', 2),
+    ('IDR', 'Rupiah', 2),
+    ('ILS', 'New Israeli Sheqel', 2),
+    ('INR', 'Indian Rupee', 2),
+    ('IQD', 'Iraqi Dinar', 3),
+    ('IRR', 'Iranian Rial', 2),
+    ('ISK', 'Iceland Krona', 0),
+    ('JMD', 'Jamaican Dollar', 2),
+    ('JOD', 'Jordanian Dinar', 3),
+    ('JPY', 'Yen', 0),
+    ('KES', 'Kenyan Shilling', 2),
+    ('KGS', 'Som', 2),
+    ('KHR', 'Riel', 2),
+    ('KMF', 'Comorian Franc', 0),
+    ('KPW', 'North Korean Won', 2),
+    ('KRW', 'Won', 0),
+    ('KWD', 'Kuwaiti Dinar', 3),
+    ('KYD', 'Cayman Islands Dollar', 2),
+    ('KZT', 'Tenge', 2),
+    ('LAK', 'Lao Kip', 2),
+    ('LBP', 'Lebanese Pound', 2),
+    ('LKR', 'Sri Lanka Rupee', 2),
+    ('LRD', 'Liberian Dollar', 2),
+    ('LSL', 'Loti', 2),
+    ('LYD', 'Libyan Dinar', 3),
+    ('MAD', 'Moroccan Dirham', 2),
+    ('MDL', 'Moldovan Leu', 2),
+    ('MGA', 'Malagasy Ariary', 2),
+    ('MKD', 'Denar', 2),
+    ('MMK', 'Kyat', 2),
+    ('MNT', 'Tugrik', 2),
+    ('MOP', 'Pataca', 2),
+    ('MRU', 'Ouguiya', 2),
+    ('MUR', 'Mauritius Rupee', 2),
+    ('MVR', 'Rufiyaa', 2),
+    ('MWK', 'Malawi Kwacha', 2),
+    ('MXN', 'Mexican Peso', 2),
+    ('MYR', 'Malaysian Ringgit', 2),
+    ('MZN', 'Mozambique Metical', 2),
+    ('NAD', 'Namibia Dollar', 2),
+    ('NGN', 'Naira', 2),
+    ('NIO', 'Cordoba Oro', 2),
+    ('NOK', 'Norwegian Krone', 2),
+    ('NPR', 'Nepalese Rupee', 2),
+    ('NZD', 'New Zealand Dollar', 2),
+    ('OMR', 'Rial Omani', 3),
+    ('PAB', 'Balboa', 2),
+    ('PEN', 'Sol', 2),
+    ('PGK', 'Kina', 2),
+    ('PHP', 'Philippine Peso', 2),
+    ('PKR', 'Pakistan Rupee', 2),
+    ('PLN', 'Zloty', 2),
+    ('PYG', 'Guarani', 0),
+    ('QAR', 'Qatari Rial', 2),
+    ('RON', 'Romanian Leu', 2),
+    ('RSD', 'Serbian Dinar', 2),
+    ('RUB', 'Russian Ruble', 2),
+    ('RWF', 'Rwanda Franc', 0),
+    ('SAR', 'Saudi Riyal', 2),
+    ('SBD', 'Solomon Islands Dollar', 2),
+    ('SCR', 'Seychelles Rupee', 2),
+    ('SDG', 'Sudanese Pound', 2),
+    ('SEK', 'Swedish Krona', 2),
+    ('SGD', 'Singapore Dollar', 2),
+    ('SHP', 'Saint Helena Pound', 2),
+    ('SLE', 'Leone', 2),
+    ('SOS', 'Somali Shilling', 2),
+    ('SRD', 'Surinam Dollar', 2),
+    ('SSP', 'South Sudanese Pound', 2),
+    ('STN', 'Dobra', 2),
+    ('SVC', 'El Salvador Colon', 2),
+    ('SYP', 'Syrian Pound', 2),
+    ('SZL', 'Lilangeni', 2),
+    ('THB', 'Baht', 2),
+    ('TJS', 'Somoni', 2),
+    ('TMT', 'Turkmenistan New Manat', 2),
+    ('TND', 'Tunisian Dinar', 3),
+    ('TOP', 'Pa''anga', 2),
+    ('TRY', 'Turkish Lira', 2),
+    ('TTD', 'Trinidad and Tobago Dollar', 2),
+    ('TWD', 'New Taiwan Dollar', 2),
+    ('TZS', 'Tanzanian Shilling', 2),
+    ('UAH', 'Hryvnia', 2),
+    ('UGX', 'Uganda Shilling', 0),
+    ('USD', 'US Dollar', 2),
+    ('UYU', 'Peso Uruguayo', 2),
+    ('UZS', 'Uzbekistan Sum', 2),
+    ('VES', 'Bolivar Soberano', 2),
+    ('VND', 'Dong', 0),
+    ('VUV', 'Vatu', 0),
+    ('WST', 'Tala', 2),
+    ('XAF', 'CFA Franc BEAC', 0),
+    ('XCD', 'East Caribbean Dollar', 2),
+    ('XCG', 'Caribbean Guilder', 2),
+    ('XOF', 'CFA Franc BCEAO', 0),
+    ('XPF', 'CFP Franc', 0),
+    ('YER', 'Yemeni Rial', 2),
+    ('ZAR', 'Rand', 2),
+    ('ZMW', 'Zambian Kwacha', 2),
+    ('ZWG', 'Zimbabwe Gold', 2);
+
+-- Latest rate per currency, as units of the currency per US dollar
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency CHAR(3) PRIMARY KEY,
+    units_per_usd DECIMAL(24,10) NOT NULL,
+    source VARCHAR(30) NOT NULL,
+    fetched_at DATETIME NOT NULL,
+    FOREIGN KEY (currency) REFERENCES currencies(code)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS donation_amount_limits (
+    currency CHAR(3) NOT NULL,
+    -- Empty for every payment method
+    payment_method VARCHAR(50) NOT NULL DEFAULT '',
+    min_amount DECIMAL(15,2),
+    max_amount DECIMAL(15,2),
+    updated_by BINARY(16),
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (currency, payment_method),
+    FOREIGN KEY (currency) REFERENCES currencies(code),
+    FOREIGN KEY (updated_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+-- Keep out token amounts and stay inside gateway limits: QRIS payments are
+-- capped at IDR 10 million, card payments start at IDR 10,000
+INSERT IGNORE INTO donation_amount_limits (currency, payment_method, min_amount, max_amount) VALUES
+    ('IDR', '', 1000, NULL),
+    ('IDR', 'qris', NULL, 10000000),
+    ('IDR', 'credit_card', 10000, NULL),
+    ('USD', '', 1, NULL),
+    ('EUR', '', 1, NULL);
+
+CREATE TABLE IF NOT EXISTS donation_refunds (
+    id BINARY(16) PRIMARY KEY,
+    donation_id BINARY(16) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    reason TEXT NOT NULL,
+    -- Gateway the money went back through; NULL when refunded by hand
+    payment_provider VARCHAR(20),
+    refunded_by BINARY(16),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (donation_id) REFERENCES donations(id),
+    FOREIGN KEY (refunded_by) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE KEY uq_donation (donation_id)
+) ENGINE=InnoDB;
+
+-- Money paid out of donations to an NGO or for a purchase, with evidence
+-- from uploads; amounts are in rupiah
+CREATE TABLE IF NOT EXISTS disbursements (
+    id BINARY(16) PRIMARY KEY,
+    kind ENUM('transfer', 'purchase') NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    recipient VARCHAR(200) NOT NULL,
+    -- The verified organization a transfer went to; recipient holds its name
+    recipient_organization_id BINARY(16),
+    description TEXT,
+    evidence_upload_id VARCHAR(36) NOT NULL,
+    recorded_by BINARY(16) NOT NULL,
+    disbursed_at DATE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (recipient_organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (evidence_upload_id) REFERENCES uploads(id),
+    FOREIGN KEY (recorded_by) REFERENCES users(id),
+    INDEX idx_disbursed (disbursed_at, created_at)
+) ENGINE=InnoDB;
+
+-- The part of a disbursement charged to each report it funds
+CREATE TABLE IF NOT EXISTS disbursement_allocations (
+    disbursement_id BINARY(16) NOT NULL,
+    report_id BINARY(16) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    PRIMARY KEY (disbursement_id, report_id),
+    FOREIGN KEY (disbursement_id) REFERENCES disbursements(id) ON DELETE CASCADE,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    INDEX idx_report (report_id)
+) ENGINE=InnoDB;
+
+-- Releases of a report's held donations to the organization that filed it,
+-- paid out once enough donation managers approve; amounts are in rupiah
+CREATE TABLE IF NOT EXISTS escrow_releases (
+    id BINARY(16) PRIMARY KEY,
+    report_id BINARY(16) NOT NULL,
+    organization_id BINARY(16) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    note VARCHAR(500),
+    status ENUM('pending', 'released', 'cancelled') NOT NULL DEFAULT 'pending',
+    requested_by BINARY(16) NOT NULL,
+    cancelled_by BINARY(16),
+    released_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (report_id) REFERENCES disaster_reports(id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    FOREIGN KEY (requested_by) REFERENCES users(id),
+    FOREIGN KEY (cancelled_by) REFERENCES users(id),
+    INDEX idx_report_status (report_id, status)
+) ENGINE=InnoDB;
+
+-- One row per donation manager approving a release
+CREATE TABLE IF NOT EXISTS escrow_release_approvals (
+    release_id BINARY(16) NOT NULL,
+    approver_id BINARY(16) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (release_id, approver_id),
+    FOREIGN KEY (release_id) REFERENCES escrow_releases(id) ON DELETE CASCADE,
+    FOREIGN KEY (approver_id) REFERENCES users(id)
+) ENGINE=InnoDB;
+
+-- report_needs is created after donations
+ALTER TABLE donations
+    ADD FOREIGN KEY (need_id) REFERENCES report_needs(id) ON DELETE SET NULL;
+
 -- Create secure user for application
 CREATE USER IF NOT EXISTS 'saferelief_user'@'localhost' IDENTIFIED BY 'your-strong-password-here';
 GRANT SELECT, INSERT, UPDATE, DELETE ON saferelief_db.* TO 'saferelief_user'@'localhost';
@@ -58,7 +58,7 @@ export default function DashboardPage() {
         });
         if (!reportsResponse.ok) throw new Error('Failed to fetch reports');
         const reportsData = await reportsResponse.json();
-        setReports(reportsData);
+        setReports(reportsData.items);
 
         // Fetch user's donations
         const donationsResponse = await fetch('http://localhost:8080/api/donations', {
@@ -93,10 +93,14 @@ export default function DisasterDetailPage() {
 
       if (!response.ok) throw new Error('Failed to verify report');
 
-      toast.success('Report verified successfully');
-      // Refresh report data
-      const updatedReport = await response.json();
-      setReport(updatedReport);
+      // Verification is a vote; the report flips once enough verifiers approve
+      const vote = await response.json();
+      if (vote.status === 'verified') {
+        toast.success('Report verified successfully');
+      } else {
+        toast.success(`Vote recorded (${vote.approvals} of ${vote.quorum.required} approvals)`);
+      }
+      setReport((current) => current && { ...current, status: vote.status });
     } catch (error) {
       toast.error('Failed to verify report');
     }
@@ -54,7 +54,7 @@ export default function DisastersPage() {
       if (!response.ok) throw new Error('Failed to fetch reports');
 
       const data = await response.json();
-      setReports(data);
+      setReports(data.items);
     } catch (error) {
       console.error('Error fetching reports:', error);
     } finally {